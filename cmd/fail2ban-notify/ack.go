@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+)
+
+func init() {
+	registerCommand("ack", "Acknowledge an event, or list unacknowledged critical bans (ack <event-id> | ack -pending)", runAck)
+}
+
+// pendingEvent is an unacknowledged critical ban in the ack -pending list
+type pendingEvent struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip,omitempty"`
+	Jail    string    `json:"jail"`
+	Country string    `json:"country,omitempty"`
+	// Deadline is when the jail's SLA escalates the ban, nil without an SLA
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// runAck records an acknowledgment from the command line or lists the
+// critical bans waiting for one
+func runAck(args []string) error {
+	// The event ID may come before the flags
+	var id string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("ack", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	pending := fs.Bool("pending", false, "List critical bans nobody acknowledged instead")
+	by := fs.String("by", os.Getenv("USER"), "Who acknowledges the event")
+	note := fs.String("note", "", "Note stored with the acknowledgment")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if id == "" {
+		id = fs.Arg(0)
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, st, err := openHistoryStore(*configPath)
+	if err != nil {
+		return err
+	}
+	if *pending {
+		return listPending(cfg, st, *output)
+	}
+	if id == "" {
+		return fmt.Errorf("usage: fail2ban-notify ack <event-id> [-by name] [-note text] | ack -pending")
+	}
+
+	ack, err := st.Acknowledge(id, *by, store.AckSourceCLI, *note, time.Now())
+	if errors.Is(err, store.ErrAlreadyAcknowledged) {
+		return fmt.Errorf("event %s was already acknowledged by %s via %s at %s",
+			ack.EventID, ackBy(ack), ack.Source, cfg.Humanize().FormatDateTime(ack.Time))
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output != OutputText {
+		return writeOutput(*output, ack, func(w io.Writer) {
+			fmt.Fprintln(w, "EVENT\tTIME\tBY\tSOURCE\tNOTE")
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ack.EventID, ack.Time.Format(time.RFC3339), ack.By, ack.Source, ack.Note)
+		})
+	}
+	fmt.Printf("✅ Acknowledged event %s as %s\n", ack.EventID, ackBy(ack))
+	return nil
+}
+
+// listPending prints the critical bans within the ack window nobody
+// acknowledged, newest first
+func listPending(cfg *config.Config, st *store.Store, output string) error {
+	now := time.Now()
+	since := now.Add(-time.Duration(cfg.Ack.Window) * time.Second)
+	events, err := st.Unacknowledged(cfg.AckRequired, since)
+	if err != nil {
+		return err
+	}
+
+	list := make([]pendingEvent, 0, len(events))
+	for i := range events {
+		e := &events[i]
+		pending := pendingEvent{ID: e.ID(), Time: e.Time, IP: e.IP, Jail: e.Jail, Country: e.Country}
+		if sla, ok := cfg.SLA.Deadline(e.Jail); ok {
+			deadline := e.Time.Add(sla)
+			pending.Deadline = &deadline
+		}
+		list = append(list, pending)
+	}
+
+	// The text output is the table
+	format := output
+	if format == OutputText {
+		if len(list) == 0 {
+			fmt.Println("✅ No unacknowledged critical bans")
+			return nil
+		}
+		format = OutputTable
+	}
+	return writeOutput(format, list, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTIME\tIP\tJAIL\tCOUNTRY\tSLA")
+		for _, e := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Format(time.RFC3339), e.IP, e.Jail, e.Country, slaState(e.Deadline, now))
+		}
+	})
+}
+
+// slaState describes how a pending ban stands against its SLA deadline
+func slaState(deadline *time.Time, now time.Time) string {
+	switch {
+	case deadline == nil:
+		return "-"
+	case now.Before(*deadline):
+		return "due in " + deadline.Sub(now).Round(time.Second).String()
+	default:
+		return "overdue"
+	}
+}
+
+// ackBy names who acknowledged an event
+func ackBy(ack *store.Ack) string {
+	if ack.By == "" {
+		return "unknown"
+	}
+	return ack.By
+}
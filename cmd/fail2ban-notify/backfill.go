@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/f2blog"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/geoip"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// DefaultFail2banLog is where fail2ban logs by default
+const DefaultFail2banLog = "/var/log/fail2ban.log"
+
+func init() {
+	registerCommand("backfill", "Import ban history from fail2ban log files into the event store, without notifying", runBackfill)
+}
+
+// backfillReport is the output of the backfill command
+type backfillReport struct {
+	Files      []string `json:"files"`
+	Entries    int      `json:"entries"`    // Bans and unbans found in the logs
+	Skipped    int      `json:"skipped"`    // Restored bans and entries outside the time range or jails
+	Imported   int      `json:"imported"`   // Events added to the store
+	Duplicates int      `json:"duplicates"` // Events the store already had
+	DryRun     bool     `json:"dry_run,omitempty"`
+}
+
+// runBackfill parses fail2ban log files and adds their bans and unbans to
+// the event store
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	since := fs.Duration("since", 0, "Only import events of this last period, e.g. 720h (default: the store's retention)")
+	jails := fs.String("jails", "", "Comma-separated jails to import (default: all)")
+	lookup := fs.Bool("geoip", false, "Look up the country of each address with the configured GeoIP service")
+	dryRun := fs.Bool("dry-run", false, "Parse the logs and report what would be imported")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Store.Enabled {
+		return fmt.Errorf("the event store is disabled in %s", *configPath)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if files, err = rotatedLogs(cfg); err != nil {
+			return err
+		}
+	}
+
+	parser, err := f2blog.NewParser(cfg.Daemon.TailPattern)
+	if err != nil {
+		return err
+	}
+
+	// Older events would be pruned, and handed to the retention hook, right away
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(cfg.Store.Retention) * time.Second)
+	if *since > 0 && now.Add(-*since).After(cutoff) {
+		cutoff = now.Add(-*since)
+	}
+
+	wanted := make(map[string]bool)
+	for _, jail := range strings.Split(*jails, ",") {
+		if jail = strings.TrimSpace(jail); jail != "" {
+			wanted[jail] = true
+		}
+	}
+
+	report := backfillReport{Files: files, DryRun: *dryRun}
+	var entries []f2blog.Entry
+	for _, file := range files {
+		err := readLogFile(file, func(line string) {
+			entry, ok := parser.Parse(line)
+			if !ok || net.ParseIP(entry.IP) == nil {
+				return
+			}
+			report.Entries++
+			// Restored bans repeat a ban that is already in the log
+			if entry.Restore || entry.Time.Before(cutoff) || (len(wanted) > 0 && !wanted[entry.Jail]) {
+				report.Skipped++
+				return
+			}
+			entries = append(entries, entry)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	events, err := backfillEvents(cfg, entries, *lookup)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		report.Imported = len(events)
+	} else {
+		if report.Imported, err = store.New(cfg.Store).Import(events); err != nil {
+			return fmt.Errorf("failed to import events: %w", err)
+		}
+		report.Duplicates = len(events) - report.Imported
+	}
+
+	if *output == OutputText {
+		printBackfill(&report)
+		return nil
+	}
+	return writeOutput(*output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "FILES\tENTRIES\tSKIPPED\tIMPORTED\tDUPLICATES")
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\n", len(report.Files), report.Entries, report.Skipped, report.Imported, report.Duplicates)
+	})
+}
+
+// rotatedLogs returns the fail2ban log the daemon tails, or the default one,
+// followed by its rotated copies such as fail2ban.log.1 and fail2ban.log.2.gz
+func rotatedLogs(cfg *config.Config) ([]string, error) {
+	base := cfg.Daemon.TailLog
+	if base == "" {
+		base = DefaultFail2banLog
+	}
+
+	rotated, err := filepath.Glob(base + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rotated logs: %w", err)
+	}
+	sort.Strings(rotated)
+
+	var files []string
+	if _, err := os.Stat(base); err == nil {
+		files = append(files, base)
+	}
+	files = append(files, rotated...)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fail2ban log found at %s, pass the log files as arguments", base)
+	}
+	return files, nil
+}
+
+// readLogFile calls fn for every line of a log file, decompressing
+// gzipped rotations
+func readLogFile(path string, fn func(line string)) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// backfillEvents turns log entries into events as the pipeline would store
+// them, without the enrichments that only make sense at ban time
+func backfillEvents(cfg *config.Config, entries []f2blog.Entry, lookup bool) ([]types.NotificationData, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	var hasher *privacy.Hasher
+	if cfg.Privacy.Enabled() {
+		hasher = privacy.NewHasher(cfg.Privacy)
+	}
+	var geo *geoip.Manager
+	if lookup && cfg.GeoIP.Enabled {
+		geo = geoip.NewManager(cfg.GeoIP, log.New(io.Discard, "", 0))
+	}
+
+	events := make([]types.NotificationData, 0, len(entries))
+	for _, entry := range entries {
+		data := &types.NotificationData{
+			EventID:  types.NewEventID(),
+			IP:       entry.IP,
+			Jail:     entry.Jail,
+			Action:   entry.Action,
+			Time:     entry.Time,
+			Hostname: hostname,
+			Labels:   cfg.LabelsForJail(entry.Jail),
+		}
+		if geo != nil && entry.Action == types.ActionBan {
+			if info, err := geo.Lookup(entry.IP); err == nil {
+				data.Country = info.Country
+				data.CountryCode = info.CountryCode
+				data.Continent = info.Continent
+				data.ISP = info.ISP
+				data.ASN = info.ASN
+			}
+		}
+		if hasher != nil {
+			if data.IPHash, err = hasher.Hash(entry.IP); err != nil {
+				return nil, fmt.Errorf("failed to hash IP: %w", err)
+			}
+		}
+		if cfg.Privacy.Store {
+			data = privacy.Apply(&cfg.Privacy, data)
+		}
+		events = append(events, *data)
+	}
+	return events, nil
+}
+
+// printBackfill prints the outcome of a backfill for people
+func printBackfill(r *backfillReport) {
+	fmt.Printf("📜 Read %d log files: %d bans and unbans, %d skipped\n", len(r.Files), r.Entries, r.Skipped)
+	if r.DryRun {
+		fmt.Printf("   Would import up to %d events (dry run)\n", r.Imported)
+		return
+	}
+	fmt.Printf("✅ Imported %d events, %d were already in the store\n", r.Imported, r.Duplicates)
+}
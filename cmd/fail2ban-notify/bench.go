@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/bench"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline"   //nolint:depguard
+)
+
+func init() {
+	registerCommand("bench", "Load-test the pipeline with synthetic events and report throughput and latencies", runBench)
+}
+
+// runBench submits synthetic events at a fixed rate and reports how the
+// pipeline keeps up
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	rate := fs.Int("rate", 50, "Events per second")
+	duration := fs.Duration("duration", 30*time.Second, "How long events are submitted")
+	jails := fs.String("jails", "sshd,postfix,dovecot", "Comma-separated jails the events are spread over")
+	live := fs.Bool("live", false, "Deliver through the configured connectors instead of mock ones")
+	mocks := fs.Int("mock-connectors", 3, "Number of mock connectors")
+	latency := fs.Duration("mock-latency", 100*time.Millisecond, "Average response time of the mock connectors")
+	failureRate := fs.Float64("mock-failures", 0, "Share of mock deliveries that fail, 0 to 1")
+	enrich := fs.Bool("enrich", false, "Keep GeoIP, anonymity and passive DNS lookups enabled")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if *failureRate < 0 || *failureRate > 1 {
+		return fmt.Errorf("-mock-failures must be between 0 and 1")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Keep the synthetic events out of the real store, spool and throttles
+	stateDir, err := os.MkdirTemp("", "fail2ban-notify-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	defer os.RemoveAll(stateDir) //nolint:errcheck
+	isolateBenchState(cfg, stateDir)
+
+	if !*enrich {
+		// Lookups of benchmark addresses would only use up API quotas
+		cfg.GeoIP.Enabled = false
+		cfg.Anonymity.Enabled = false
+		cfg.PassiveDNS.Enabled = false
+	}
+
+	if *live {
+		if len(cfg.GetEnabledConnectors()) == 0 {
+			return fmt.Errorf("no connectors enabled in %s", *configPath)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: delivering %d events through the configured connectors\n", *rate*int(duration.Seconds()))
+	} else {
+		if *mocks <= 0 {
+			return fmt.Errorf("-mock-connectors must be positive")
+		}
+		mock, err := bench.StartMock(*latency, *failureRate)
+		if err != nil {
+			return err
+		}
+		defer mock.Close() //nolint:errcheck
+
+		// Profiles and observers would bring back real endpoints
+		cfg.Connectors = mock.Connectors(*mocks)
+		cfg.Profiles = nil
+		cfg.Observer.URL = ""
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Deliver like the daemon, including connector batching
+	connectors.EnableBatching()
+	logger := log.New(io.Discard, "", 0)
+	if cfg.Debug {
+		logger = log.New(os.Stderr, "[bench] ", log.LstdFlags)
+	}
+	p := pipeline.New(cfg, logger)
+
+	if *output == OutputText {
+		fmt.Printf("Submitting %d events/s for %s...\n", *rate, *duration)
+	}
+	result, err := bench.Run(ctx, bench.Options{
+		Rate:      *rate,
+		Duration:  *duration,
+		QueueSize: cfg.Daemon.QueueSize,
+		Jails:     strings.Split(*jails, ","),
+	}, p.Process)
+	if err != nil {
+		return err
+	}
+	if err := connectors.FlushBatches(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush connector batches: %v\n", err)
+	}
+
+	if *output == OutputText {
+		printBench(result)
+		return nil
+	}
+	return writeOutput(*output, result, func(w io.Writer) {
+		writeBenchTable(w, result)
+	})
+}
+
+// isolateBenchState moves all state the pipeline writes into dir
+func isolateBenchState(cfg *config.Config, dir string) {
+	cfg.StateDir = dir
+	cfg.Store.Dir = filepath.Join(dir, "store")
+	cfg.Spool.Dir = filepath.Join(dir, "spool")
+	cfg.Artifacts.Dir = filepath.Join(dir, "artifacts")
+	cfg.Privacy.KeyFile = filepath.Join(dir, "privacy.key")
+	cfg.Store.RetentionHook = ""
+	cfg.RBL.Enabled = false
+}
+
+// printBench prints the benchmark result for people
+func printBench(r *bench.Result) {
+	fmt.Printf("\n⏱️  %d events in %s: %.1f events/s (target %d/s)\n",
+		r.Processed, r.Elapsed.Round(time.Millisecond), r.Throughput, r.Rate)
+	if r.Errors > 0 {
+		fmt.Printf("   %d events completed with connector errors\n", r.Errors)
+	}
+	fmt.Printf("   Queue: peak %d of %d, %d submissions blocked for %s, drained in %s\n",
+		r.MaxQueueDepth, r.QueueSize, r.Blocked, r.BlockedTime.Round(time.Millisecond), r.Drain.Round(time.Millisecond))
+	if r.Blocked > 0 {
+		fmt.Println("   ⚠️  The pipeline can't keep up with this rate")
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	writeBenchTable(w, r)
+	_ = w.Flush()
+}
+
+// writeBenchTable writes the latencies as plain columns
+func writeBenchTable(w io.Writer, r *bench.Result) {
+	fmt.Fprintln(w, "NAME\tRUNS\tFAILED\tP50\tP90\tP99\tMAX")
+	fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", "pipeline", r.Processed, r.Errors, formatLatency(&r.Pipeline))
+	fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", "end-to-end", r.Processed, r.Errors, formatLatency(&r.EndToEnd))
+	for i := range r.Connectors {
+		c := &r.Connectors[i]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", c.Name, c.Runs, c.Failures, formatLatency(&c.Latency))
+	}
+}
+
+// formatLatency formats the percentiles as tab-separated columns
+func formatLatency(l *bench.Latency) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s", l.P50.Round(time.Millisecond), l.P90.Round(time.Millisecond),
+		l.P99.Round(time.Millisecond), l.Max.Round(time.Millisecond))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/blocklist" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+)
+
+func init() {
+	registerCommand("blocklist", "Print the top repeat offenders from the event store as a plain, nginx, ipset, MikroTik or EDL blocklist", runBlocklist)
+}
+
+// runBlocklist prints the addresses banned most often in the last days
+func runBlocklist(args []string) error {
+	fs := flag.NewFlagSet("blocklist", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	format := fs.String("format", blocklist.FormatPlain, "Blocklist format: "+strings.Join(blocklist.Formats, ", "))
+	top := fs.Int("top", store.DefaultOffenderLimit, "List the N addresses banned most often, 0 for all")
+	minBans := fs.Int("min-bans", store.DefaultOffenderMinBans, "Only addresses banned at least this often")
+	days := fs.Int("days", store.DefaultOffenderDays, "Count the bans of the last N days")
+	listName := fs.String("list", blocklist.DefaultListName, "Name of the ipset or MikroTik address list")
+	output := fs.String("output", OutputText, "Output format: text (the blocklist), json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if !blocklist.ValidFormat(*format) {
+		return fmt.Errorf("invalid blocklist format '%s', must be one of: %s", *format, strings.Join(blocklist.Formats, ", "))
+	}
+	if *top < 0 || *minBans < 1 || *days < 1 {
+		return fmt.Errorf("-top must not be negative, -min-bans and -days must be positive")
+	}
+
+	_, st, err := openHistoryStore(*configPath)
+	if err != nil {
+		return err
+	}
+	offenders, err := st.TopOffenders(store.OffenderQuery{
+		Since:   time.Now().AddDate(0, 0, -*days),
+		MinBans: *minBans,
+		Limit:   *top,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *output == OutputText {
+		ips := make([]string, len(offenders))
+		for i := range offenders {
+			ips[i] = offenders[i].IP
+		}
+		_, err := os.Stdout.Write(blocklist.Render(*format, "top offenders", *listName, ips))
+		return err
+	}
+	return writeOutput(*output, offenders, func(w io.Writer) {
+		fmt.Fprintln(w, "IP\tBANS\tFIRST BAN\tLAST BAN\tJAILS\tCOUNTRY")
+		for _, o := range offenders {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", o.IP, o.Bans, o.FirstBan.Format(time.RFC3339),
+				o.LastBan.Format(time.RFC3339), strings.Join(o.Jails, ","), o.Country)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/pki" //nolint:depguard
+)
+
+// DefaultCADir is where the built-in certificate authority is kept
+const DefaultCADir = "/etc/fail2ban/fail2ban-notify-ca"
+
+func init() {
+	registerCommand("ca", "Manage the certificate authority for agent/collector mTLS (init, issue)", runCA)
+}
+
+// runCA dispatches the ca subcommands
+func runCA(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: fail2ban-notify ca <init|issue> [options]")
+	}
+
+	switch args[0] {
+	case "init":
+		return handleCAInit(args[1:])
+	case "issue":
+		return handleCAIssue(args[1:])
+	default:
+		return fmt.Errorf("unknown ca command: %s", args[0])
+	}
+}
+
+// handleCAInit creates a new certificate authority
+func handleCAInit(args []string) error {
+	fs := flag.NewFlagSet("ca init", flag.ExitOnError)
+	dir := fs.String("dir", DefaultCADir, "CA directory")
+	name := fs.String("name", "fail2ban-notify CA", "CA common name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := pki.InitCA(*dir, *name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Certificate authority created in %s\n", *dir)
+	fmt.Printf("Distribute %s to agents and the collector; keep %s private.\n",
+		filepath.Join(*dir, pki.CACertFile), filepath.Join(*dir, pki.CAKeyFile))
+	return nil
+}
+
+// handleCAIssue issues a server or client certificate from the CA
+func handleCAIssue(args []string) error {
+	fs := flag.NewFlagSet("ca issue", flag.ExitOnError)
+	dir := fs.String("dir", DefaultCADir, "CA directory")
+	out := fs.String("out", "", "Output directory (default: CA directory)")
+	name := fs.String("name", "", "Certificate common name and file name (agent or collector host name)")
+	kind := fs.String("kind", pki.KindClient, "Certificate kind (server/client)")
+	hosts := fs.String("hosts", "", "Comma-separated DNS names and IPs for server certificates")
+	force := fs.Bool("force", false, "Replace an existing certificate and key of the same name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	outDir := *out
+	if outDir == "" {
+		outDir = *dir
+	}
+
+	var hostList []string
+	for _, host := range strings.Split(*hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hostList = append(hostList, host)
+		}
+	}
+
+	certPath, keyPath, err := pki.Issue(*dir, outDir, *name, *kind, hostList, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Issued %s certificate for %s:\n", *kind, *name)
+	fmt.Printf("  Certificate: %s\n", certPath)
+	fmt.Printf("  Key:         %s\n", keyPath)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/campaign" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"    //nolint:depguard
+)
+
+func init() {
+	registerCommand("campaigns", "Report campaigns of related bans or re-cluster the event store (list, show, rebuild)", runCampaigns)
+}
+
+// runCampaigns dispatches the campaigns subcommands
+func runCampaigns(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: fail2ban-notify campaigns <list|show|rebuild> [options]")
+	}
+
+	fs := flag.NewFlagSet("campaigns "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	days := fs.Int("days", 7, "List campaigns active in the last N days")
+	id := fs.Int("id", 0, "Campaign to show")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !cfg.Campaigns.Enabled {
+		return fmt.Errorf("campaigns are disabled in %s", *configPath)
+	}
+
+	tracker := campaign.NewTracker(cfg.Campaigns, cfg.Store, cfg.Privacy)
+	switch args[0] {
+	case "list":
+		return handleCampaignsList(tracker, time.Now().AddDate(0, 0, -*days), cfg.Humanize())
+	case "show":
+		return handleCampaignsShow(tracker, *id, cfg.Humanize())
+	case "rebuild":
+		count, err := tracker.Rebuild(store.New(cfg.Store))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Found %d campaigns in the event store\n", count)
+		return nil
+	default:
+		return fmt.Errorf("unknown campaigns command: %s", args[0])
+	}
+}
+
+// handleCampaignsList prints the campaigns active since the given time
+func handleCampaignsList(tracker *campaign.Tracker, since time.Time, locale humanize.Locale) error {
+	campaigns, err := tracker.Campaigns(since)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tGROUPED BY\tIPS\tJAILS\tFIRST\tLAST\tDURATION")
+	for _, c := range campaigns {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", c.ID, c.Key, len(c.IPs), strings.Join(c.Jails, ","),
+			locale.FormatDateTime(c.First), locale.FormatDateTime(c.Last), locale.Duration(c.Last.Sub(c.First)))
+	}
+	return w.Flush()
+}
+
+// handleCampaignsShow prints a campaign with all of its IPs
+func handleCampaignsShow(tracker *campaign.Tracker, id int, locale humanize.Locale) error {
+	if id <= 0 {
+		return fmt.Errorf("usage: fail2ban-notify campaigns show -id <campaign>")
+	}
+
+	campaigns, err := tracker.Campaigns(time.Time{})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range campaigns {
+		if c.ID != id {
+			continue
+		}
+		fmt.Printf("Campaign #%d\n", c.ID)
+		fmt.Printf("  Grouped by: %s %s\n", c.Kind, c.Key)
+		fmt.Printf("  Jails:      %s\n", strings.Join(c.Jails, ", "))
+		now := time.Now()
+		fmt.Printf("  First ban:  %s (%s)\n", locale.FormatDateTime(c.First), locale.Ago(c.First, now))
+		fmt.Printf("  Last ban:   %s (%s)\n", locale.FormatDateTime(c.Last), locale.Ago(c.Last, now))
+		fmt.Printf("  IPs (%d):\n", len(c.IPs))
+		for _, ip := range c.IPs {
+			fmt.Printf("    %s\n", ip)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("campaign not found: %d", id)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand invoked as "fail2ban-notify <name> [args]"
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// commands holds all registered subcommands
+var commands = map[string]command{}
+
+// registerCommand adds a subcommand to the command table
+func registerCommand(name, usage string, run func(args []string) error) {
+	commands[name] = command{usage: usage, run: run}
+}
+
+// runCommand runs the subcommand named in args[0]. It returns false if
+// args don't start with a known subcommand.
+func runCommand(args []string) bool {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" {
+			printCommands()
+			return true
+		}
+		return false
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// printCommands prints the list of subcommands
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Commands:")
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].usage)
+	}
+}
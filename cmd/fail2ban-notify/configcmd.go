@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	registerCommand("config", "Merge a partial configuration into the config file for configuration management (merge)", runConfig)
+}
+
+// mergeReport is the structured output of config merge
+type mergeReport struct {
+	Config  string `json:"config"`
+	Changed bool   `json:"changed"`
+	Check   bool   `json:"check,omitempty"`
+}
+
+// runConfig dispatches the config subcommands
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "merge" {
+		return fmt.Errorf("usage: fail2ban-notify config merge [-config path] [-file partial.json] [-check] [-output format]")
+	}
+
+	fs := flag.NewFlagSet("config merge", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	file := fs.String("file", "-", "Partial configuration to merge, - for stdin")
+	check := fs.Bool("check", false, "Report whether the merge would change the file without writing it")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	var partial []byte
+	var err error
+	if *file == "-" {
+		partial, err = io.ReadAll(os.Stdin)
+	} else {
+		partial, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read partial config: %w", err)
+	}
+
+	changed, err := config.MergeConfig(*configPath, partial, *check)
+	if err != nil {
+		return err
+	}
+
+	report := mergeReport{Config: *configPath, Changed: changed, Check: *check}
+	if *output != OutputText {
+		return writeOutput(*output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "CONFIG\tCHANGED\tCHECK")
+			fmt.Fprintf(w, "%s\t%t\t%t\n", report.Config, report.Changed, report.Check)
+		})
+	}
+
+	switch {
+	case !changed:
+		fmt.Printf("unchanged: %s\n", *configPath)
+	case *check:
+		fmt.Printf("would change: %s\n", *configPath)
+	default:
+		fmt.Printf("changed: %s\n", *configPath)
+	}
+	return nil
+}
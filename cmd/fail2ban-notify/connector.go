@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/scaffold" //nolint:depguard
+)
+
+func init() {
+	registerCommand("connector", "Generate the skeleton of a custom connector (scaffold <bash|python|node|go>)", runConnector)
+}
+
+// runConnector dispatches the connector subcommands
+func runConnector(args []string) error {
+	if len(args) == 0 || args[0] != "scaffold" {
+		return fmt.Errorf("usage: fail2ban-notify connector scaffold <%s> -name <name> [-dir path] [-force] [-stdout]", scaffoldLanguages())
+	}
+	return runConnectorScaffold(args[1:])
+}
+
+// runConnectorScaffold writes a connector skeleton in the requested
+// language and prints the configuration it needs
+func runConnectorScaffold(args []string) error {
+	// The language may come before the flags
+	var langName string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		langName, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("connector scaffold", flag.ExitOnError)
+	name := fs.String("name", "", "Connector name, also the file name and the prefix of its settings")
+	dir := fs.String("dir", "", "Directory to write the connector to (default: the connector path, or the current directory for go)")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	stdout := fs.Bool("stdout", false, "Print the skeleton instead of writing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if langName == "" {
+		langName = fs.Arg(0)
+	}
+	if langName == "" || *name == "" {
+		return fmt.Errorf("usage: fail2ban-notify connector scaffold <%s> -name <name> [-dir path] [-force] [-stdout]", scaffoldLanguages())
+	}
+
+	lang, err := scaffold.Lookup(langName)
+	if err != nil {
+		return err
+	}
+
+	// Scripts run from the connector path; Go sources are built into it
+	connectorPath := config.DefaultConfig().ConnectorPath
+	if *dir == "" {
+		*dir = connectorPath
+		if lang.Type == config.ConnectorTypeExecutable {
+			*dir = "."
+		}
+	}
+	file := filepath.Join(*dir, *name+lang.Ext)
+	installed := file
+	if lang.Type == config.ConnectorTypeExecutable {
+		installed = filepath.Join(connectorPath, *name)
+	} else if abs, err := filepath.Abs(file); err == nil {
+		installed = abs
+	}
+
+	source, err := scaffold.Render(lang, scaffold.Options{Name: *name, Path: installed})
+	if err != nil {
+		return err
+	}
+	if *stdout {
+		_, err := os.Stdout.Write(source)
+		return err
+	}
+
+	if _, err := os.Stat(file); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", file)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil { //nolint:gosec // The connector path is read by the notifier
+		return fmt.Errorf("failed to create %s: %w", *dir, err)
+	}
+	perm := os.FileMode(0755)
+	if lang.Type == config.ConnectorTypeExecutable {
+		perm = 0644
+	}
+	if err := os.WriteFile(file, source, perm); err != nil {
+		return fmt.Errorf("failed to write connector: %w", err)
+	}
+
+	connector := config.ConnectorConfig{
+		Name:        *name,
+		Type:        lang.Type,
+		Enabled:     true,
+		Path:        installed,
+		Settings:    scaffold.Settings(*name),
+		Timeout:     30,
+		RetryCount:  2,
+		RetryDelay:  5,
+		Description: fmt.Sprintf("Custom %s connector", lang.Name),
+		Probe:       config.ProbeHealthcheck,
+	}
+	snippet, err := json.MarshalIndent(map[string][]config.ConnectorConfig{"connectors": {connector}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Created %s (%s)\n\n", file, lang.Runtime)
+	steps := []string{"Replace deliver() with the request your service expects"}
+	if lang.Type == config.ConnectorTypeExecutable {
+		steps = append(steps, fmt.Sprintf("Build it: go build -o %s %s", installed, file))
+	}
+	steps = append(steps,
+		fmt.Sprintf("Try it: %s_URL=https://example.com/fail2ban F2B_IP=192.0.2.1 F2B_JAIL=sshd %s --dry-run", scaffold.Prefix(*name), installed),
+		fmt.Sprintf("Add it to the configuration and run: fail2ban-notify -test %s", *name),
+	)
+	fmt.Println("Next steps:")
+	for i, step := range steps {
+		fmt.Printf("  %d. %s\n", i+1, step)
+	}
+	fmt.Println()
+	fmt.Printf("Configuration, for fail2ban-notify config merge:\n%s\n", snippet)
+	return nil
+}
+
+// scaffoldLanguages lists the languages connectors can be scaffolded in
+func scaffoldLanguages() string {
+	languages := scaffold.Languages()
+	names := make([]string, len(languages))
+	for i, lang := range languages {
+		names[i] = lang.Name
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/daemon" //nolint:depguard
+)
+
+func init() {
+	registerCommand("daemon", "Run as a long-lived daemon receiving events from configured sources", runDaemon)
+}
+
+// runDaemon starts the daemon and runs until SIGINT or SIGTERM
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	tailLog := fs.String("tail", "", "Follow this fail2ban log file (overrides daemon.tail_log)")
+	container := fs.Bool("container", config.ContainerMode(), "Container mode: configure from F2BN_* environment variables")
+	runAs := fs.String("user", "", "Switch to this user when started as root (overrides daemon.user)")
+	debug := fs.Bool("debug", false, "Enable debug logging")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "[fail2ban-notify] ", log.LstdFlags)
+
+	var (
+		cfg *config.Config
+		err error
+	)
+	if *container {
+		cfg, err = config.LoadContainerConfig(*configPath)
+	} else {
+		cfg, err = config.LoadConfig(*configPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if *debug {
+		cfg.Debug = true
+	}
+	if *tailLog != "" {
+		cfg.Daemon.TailLog = *tailLog
+	}
+
+	if *runAs != "" {
+		cfg.Daemon.User = *runAs
+	}
+	if cfg.Daemon.User != "" {
+		if err := daemon.DropPrivileges(cfg, cfg.Daemon.User); err != nil {
+			return fmt.Errorf("failed to drop privileges: %w", err)
+		}
+		logger.Printf("Running as user %s", cfg.Daemon.User)
+	} else if os.Geteuid() == 0 && !*container {
+		logger.Printf("Warning: running as root, set daemon.user to run unprivileged")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return daemon.New(cfg, logger).Run(ctx)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/lsm"    //nolint:depguard
+)
+
+func init() {
+	registerCommand("diagnose", "Check SELinux/AppArmor and report denials that block connector scripts", runDiagnose)
+}
+
+// diagnoseScript is the security view of a script connector
+type diagnoseScript struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Executable bool   `json:"executable"`
+	Label      string `json:"label,omitempty"`   // SELinux file label
+	Context    string `json:"context,omitempty"` // selinux_context the script runs in
+	Denials    int    `json:"denials"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// diagnoseReport is the output of the diagnose command
+type diagnoseReport struct {
+	Security *lsm.Status      `json:"security"`
+	Scripts  []diagnoseScript `json:"scripts"`
+	Denials  []*lsm.Denial    `json:"denials"`
+}
+
+// runDiagnose reports the active security module, the labels of the
+// connector scripts and the recent denials concerning them
+func runDiagnose(args []string) error {
+	fs := flag.NewFlagSet("diagnose", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	hours := fs.Int("hours", 24, "Look for denials logged in the last N hours")
+	all := fs.Bool("all", false, "Show all denials, not only those concerning connector scripts")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	report := &diagnoseReport{Security: lsm.Detect(), Scripts: []diagnoseScript{}, Denials: []*lsm.Denial{}}
+	denials := lsm.RecentDenials(time.Now().Add(-time.Duration(*hours) * time.Hour))
+
+	for i := range cfg.Connectors {
+		connector := &cfg.Connectors[i]
+		if connector.Path == "" {
+			continue
+		}
+		script := diagnoseScript{
+			Name:    connector.Name,
+			Path:    connector.Path,
+			Label:   lsm.FileLabel(connector.Path),
+			Context: cfg.ScriptContext(connector),
+		}
+		if info, err := os.Stat(connector.Path); err == nil {
+			script.Executable = info.Mode()&0111 != 0
+		}
+		for _, d := range denials {
+			if d.Matches(connector.Path) {
+				script.Denials++
+				script.Suggestion = d.Suggestion(connector.Path)
+			}
+		}
+		report.Scripts = append(report.Scripts, script)
+	}
+
+	for _, d := range denials {
+		if *all || concernsScripts(d, report.Scripts) {
+			report.Denials = append(report.Denials, d)
+		}
+	}
+
+	if *output != OutputText {
+		return writeOutput(*output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "CONNECTOR\tPATH\tEXECUTABLE\tLABEL\tCONTEXT\tDENIALS")
+			for _, s := range report.Scripts {
+				fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%d\n", s.Name, s.Path, s.Executable, s.Label, s.Context, s.Denials)
+			}
+		})
+	}
+
+	printDiagnose(report, *hours)
+	return nil
+}
+
+// concernsScripts reports whether a denial concerns a connector script or
+// the notifier itself
+func concernsScripts(d *lsm.Denial, scripts []diagnoseScript) bool {
+	if d.Matches(os.Args[0]) || d.Comm == filepath.Base(os.Args[0]) {
+		return true
+	}
+	for _, s := range scripts {
+		if d.Matches(s.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// printDiagnose prints the report for people
+func printDiagnose(report *diagnoseReport, hours int) {
+	status := report.Security
+	switch {
+	case !status.Active():
+		fmt.Println("🛡️  No SELinux or AppArmor detected")
+	case status.Enforcing:
+		fmt.Printf("🛡️  %s is enforcing, running in %s\n", status.Module, status.Context)
+	default:
+		fmt.Printf("🛡️  %s is permissive, denials are logged but not enforced (running in %s)\n", status.Module, status.Context)
+	}
+
+	fmt.Println("\nConnector scripts:")
+	for _, s := range report.Scripts {
+		state := "✅"
+		if !s.Executable || s.Denials > 0 {
+			state = "❌"
+		}
+		fmt.Printf("  %s %s: %s", state, s.Name, s.Path)
+		if s.Label != "" {
+			fmt.Printf(" [%s]", s.Label)
+		}
+		if s.Context != "" {
+			fmt.Printf(" runs in %s", s.Context)
+		}
+		fmt.Println()
+		if !s.Executable {
+			fmt.Println("     not executable or missing")
+		}
+		if s.Denials > 0 {
+			fmt.Printf("     %d denials, to fix: %s\n", s.Denials, s.Suggestion)
+		}
+	}
+
+	if len(report.Denials) == 0 {
+		fmt.Printf("\nNo denials found in the last %d hours", hours)
+		if os.Geteuid() != 0 {
+			fmt.Print(" (run as root to read the audit log)")
+		}
+		fmt.Println()
+		return
+	}
+	fmt.Printf("\nDenials in the last %d hours:\n", hours)
+	for _, d := range report.Denials {
+		fmt.Printf("  %s %s\n", d.Time.Format(time.RFC3339), d)
+	}
+}
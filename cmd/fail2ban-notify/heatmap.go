@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/heatmap"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+)
+
+// heatmapTop is the number of busiest cells printed in text output
+const heatmapTop = 10
+
+func init() {
+	registerCommand("heatmap", "Generate the ban map image and GeoJSON layer from the event store", runHeatmap)
+}
+
+// runHeatmap regenerates the ban map on demand
+func runHeatmap(args []string) error {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	days := fs.Int("days", 0, "Map the bans of the last N days (default: heatmap.days)")
+	dir := fs.String("dir", "", "Directory to write the files to (default: heatmap.dir)")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Store.Enabled {
+		return fmt.Errorf("the heatmap is built from the event store, which is disabled")
+	}
+	if *days > 0 {
+		cfg.Heatmap.Days = *days
+	}
+	if *dir != "" {
+		cfg.Heatmap.Dir = *dir
+	}
+
+	m, err := heatmap.Generate(cfg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if *output == OutputText {
+		printHeatmap(m, cfg.Heatmap.Dir, cfg.Humanize())
+		return nil
+	}
+	return writeOutput(*output, m, func(w io.Writer) {
+		fmt.Fprintln(w, "LAT\tLON\tBANS\tCOUNTRIES")
+		for _, cell := range m.Cells {
+			fmt.Fprintf(w, "%.2f\t%.2f\t%d\t%s\n", cell.Lat, cell.Lon, cell.Bans, strings.Join(cell.Countries, ", "))
+		}
+	})
+}
+
+// printHeatmap prints where the map was written and its busiest cells
+func printHeatmap(m *heatmap.Map, dir string, locale humanize.Locale) {
+	fmt.Printf("🗺️  Mapped %s bans in %s since %s", locale.Count(int64(m.Bans)),
+		locale.Plural(int64(len(m.Cells)), "cell", "cells"), locale.FormatDate(m.Since))
+	if m.Unplaced > 0 {
+		fmt.Printf(" (%s without a location)", locale.Number(int64(m.Unplaced)))
+	}
+	fmt.Println()
+	fmt.Printf("   Image:   %s\n", filepath.Join(dir, heatmap.ImageFile))
+	fmt.Printf("   GeoJSON: %s\n", filepath.Join(dir, heatmap.GeoJSONFile))
+
+	for i, cell := range m.Cells {
+		if i == heatmapTop {
+			break
+		}
+		if i == 0 {
+			fmt.Println("\n🔥 Busiest areas:")
+		}
+		where := strings.Join(cell.Countries, ", ")
+		if where == "" {
+			where = "unknown"
+		}
+		fmt.Printf("   %6.2f, %7.2f  %9s  %s\n", cell.Lat, cell.Lon, locale.Plural(int64(cell.Bans), "ban", "bans"), where)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+func init() {
+	registerCommand("history", "List stored events and show how each connector handled one (list, show <event-id>)", runHistory)
+}
+
+// historyEvent is an event in the history list
+type historyEvent struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	IP       string    `json:"ip,omitempty"`
+	Jail     string    `json:"jail"`
+	Action   string    `json:"action"`
+	Country  string    `json:"country,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+}
+
+// historyShow is the output of history show
+type historyShow struct {
+	ID      string                  `json:"id"`
+	Event   *types.NotificationData `json:"event"`
+	Results []store.Result          `json:"results"`
+}
+
+// runHistory dispatches the history subcommands
+func runHistory(args []string) error {
+	if len(args) > 0 && args[0] == "show" {
+		return runHistoryShow(args[1:])
+	}
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: fail2ban-notify history [list] [-ip ip] [-jail jail] [-limit n] | history show <event-id>")
+	}
+	return runHistoryList(args)
+}
+
+// openHistoryStore loads the configuration and returns it with its event
+// store
+func openHistoryStore(configPath string) (*config.Config, *store.Store, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Store.Enabled {
+		return nil, nil, fmt.Errorf("the event store is disabled in %s", configPath)
+	}
+	return cfg, store.New(cfg.Store), nil
+}
+
+// runHistoryList prints the most recent events with their IDs
+func runHistoryList(args []string) error {
+	fs := flag.NewFlagSet("history list", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	ip := fs.String("ip", "", "Only events of this IP")
+	jail := fs.String("jail", "", "Only events of this jail")
+	limit := fs.Int("limit", 20, "Number of events to list, newest first")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	_, st, err := openHistoryStore(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var events []historyEvent
+	err = st.Scan(func(data *types.NotificationData) error {
+		if (*ip != "" && data.IP != *ip) || (*jail != "" && data.Jail != *jail) {
+			return nil
+		}
+		events = append(events, historyEvent{
+			ID: data.ID(), Time: data.Time, IP: data.IP, Jail: data.Jail,
+			Action: data.Action, Country: data.Country, Failures: data.Failures,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Newest first
+	newest := make([]historyEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0 && (*limit <= 0 || len(newest) < *limit); i-- {
+		newest = append(newest, events[i])
+	}
+
+	// The text output is the table
+	format := *output
+	if format == OutputText {
+		if len(newest) == 0 {
+			fmt.Println("No events stored")
+			return nil
+		}
+		format = OutputTable
+	}
+	return writeOutput(format, newest, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTIME\tACTION\tIP\tJAIL\tCOUNTRY")
+		for _, e := range newest {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Format(time.RFC3339), e.Action, e.IP, e.Jail, e.Country)
+		}
+	})
+}
+
+// runHistoryShow prints an event and the result and output of every
+// connector that handled it
+func runHistoryShow(args []string) error {
+	// The event ID may come before the flags
+	var id string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("history show", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if id == "" {
+		id = fs.Arg(0)
+	}
+	if id == "" {
+		return fmt.Errorf("usage: fail2ban-notify history show <event-id> [-config path] [-output format]")
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, st, err := openHistoryStore(*configPath)
+	if err != nil {
+		return err
+	}
+	event, err := st.Event(id)
+	if err != nil {
+		return err
+	}
+	results, err := st.EventResults(event.ID())
+	if err != nil {
+		return err
+	}
+
+	report := historyShow{ID: event.ID(), Event: event, Results: results}
+	if report.Results == nil {
+		report.Results = []store.Result{}
+	}
+
+	if *output != OutputText {
+		return writeOutput(*output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "CONNECTOR\tSUCCESS\tATTEMPTS\tDURATION\tERROR")
+			for _, r := range report.Results {
+				fmt.Fprintf(w, "%s\t%t\t%d\t%s\t%s\n", r.ConnectorName, r.Success, r.Attempts, r.Duration.Round(time.Millisecond), r.Error)
+			}
+		})
+	}
+
+	printHistoryShow(&report, cfg.Humanize())
+	return nil
+}
+
+// printHistoryShow prints an event and its connector results for people
+func printHistoryShow(report *historyShow, locale humanize.Locale) {
+	event := report.Event
+	fmt.Printf("Event %s: %s\n", report.ID, event)
+	fmt.Printf("  Time:     %s (%s)\n", locale.FormatDateTime(event.Time), locale.Ago(event.Time, time.Now()))
+	if location := event.GetLocationString(); location != "" {
+		fmt.Printf("  Location: %s\n", location)
+	}
+	if event.Failures > 0 {
+		fmt.Printf("  Failures: %d\n", event.Failures)
+	}
+
+	if len(report.Results) == 0 {
+		fmt.Println("\nNo connector results recorded for this event")
+		return
+	}
+
+	fmt.Println("\nConnectors:")
+	for _, r := range report.Results {
+		state := "✅"
+		if !r.Success {
+			state = "❌"
+		}
+		fmt.Printf("  %s %s: %d attempts in %s", state, r.ConnectorName, r.Attempts, r.Duration.Round(time.Millisecond))
+		if r.DeliveredBy != "" {
+			fmt.Printf(", delivered by %s", r.DeliveredBy)
+		}
+		fmt.Println()
+		if r.Error != "" {
+			fmt.Printf("     error: %s\n", r.Error)
+		}
+		if r.Output != "" {
+			for _, line := range strings.Split(r.Output, "\n") {
+				fmt.Printf("     | %s\n", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	registerCommand("install", "Set up the service user, directories, systemd units and fail2ban action", runInstall)
+}
+
+// Unit modes selected with install -unit
+const (
+	UnitDaemon = "daemon" // Daemon started at boot
+	UnitSocket = "socket" // Daemon started by systemd on the first event
+	UnitNone   = "none"   // No daemon, fail2ban runs the notifier for every event
+)
+
+// Install settings
+const (
+	DefaultServiceUser = "fail2ban-notify"
+	DefaultBinaryPath  = "/usr/local/bin/fail2ban-notify"
+	serviceName        = "fail2ban-notify"
+	actionPath         = "/etc/fail2ban/action.d/notify.conf"
+	binaryPermission   = 0755
+	publicPermission   = 0644
+	configPermission   = 0640
+)
+
+// installPaths are the directories of the systemd integration files. Local
+// installs use /etc, packages (-root) the vendor directories under /usr/lib.
+type installPaths struct {
+	units    string
+	sysusers string
+	tmpfiles string
+}
+
+var (
+	localPaths   = installPaths{units: "/etc/systemd/system", sysusers: "/etc/sysusers.d", tmpfiles: "/etc/tmpfiles.d"}
+	packagePaths = installPaths{units: "/usr/lib/systemd/system", sysusers: "/usr/lib/sysusers.d", tmpfiles: "/usr/lib/tmpfiles.d"}
+)
+
+// installer sets up fail2ban-notify on a server or in a package build root
+type installer struct {
+	root       string
+	configPath string
+	binary     string
+	unit       string
+	user       string
+	paths      installPaths
+	cfg        *config.Config
+}
+
+// installStep is one action of the installation
+type installStep struct {
+	desc string
+	run  func() error
+}
+
+// runInstall performs a one-shot server setup. With -root the files are
+// written below a package build root and nothing is started: the package
+// scripts apply the sysusers.d and tmpfiles.d files and enable the unit.
+func runInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	unit := fs.String("unit", UnitDaemon, "systemd unit to install: daemon, socket or none")
+	serviceUser := fs.String("user", DefaultServiceUser, "User the daemon runs as")
+	binary := fs.String("bin", DefaultBinaryPath, "Path the binary is installed to")
+	root := fs.String("root", "", "Install below this directory for packaging, without creating the user or starting the service")
+	dryRun := fs.Bool("dry-run", false, "Print the installation steps without performing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *unit {
+	case UnitDaemon, UnitSocket, UnitNone:
+	default:
+		return fmt.Errorf("invalid unit '%s', must be '%s', '%s' or '%s'", *unit, UnitDaemon, UnitSocket, UnitNone)
+	}
+	if *root == "" && !*dryRun && os.Geteuid() != 0 {
+		return fmt.Errorf("install must be run as root")
+	}
+
+	inst := &installer{
+		root:       *root,
+		configPath: *configPath,
+		binary:     *binary,
+		unit:       *unit,
+		user:       *serviceUser,
+		paths:      localPaths,
+	}
+	if inst.root != "" {
+		inst.paths = packagePaths
+	}
+	if inst.unit == UnitNone {
+		// fail2ban runs the notifier as root
+		inst.user = "root"
+	}
+
+	if err := inst.loadConfig(*dryRun); err != nil {
+		return err
+	}
+
+	for _, step := range inst.steps() {
+		if *dryRun {
+			fmt.Printf("would %s\n", step.desc)
+			continue
+		}
+		if err := step.run(); err != nil {
+			return fmt.Errorf("failed to %s: %w", step.desc, err)
+		}
+		fmt.Printf("✅ %s\n", step.desc)
+	}
+
+	if !*dryRun {
+		fmt.Println("")
+		fmt.Println("Next steps:")
+		fmt.Printf("1. Enable and configure your notification services in %s\n", inst.configPath)
+		fmt.Println("2. Add the 'notify' action to your fail2ban jails and reload fail2ban")
+	}
+	return nil
+}
+
+// path returns p below the install root
+func (i *installer) path(p string) string {
+	return filepath.Join(i.root, p)
+}
+
+// loadConfig reads the configuration, creating it if it doesn't exist. The
+// daemon units need daemon.socket, which is set to the default if empty.
+func (i *installer) loadConfig(dryRun bool) error {
+	configFile := i.path(i.configPath)
+	if _, err := os.Stat(configFile); os.IsNotExist(err) && dryRun {
+		i.cfg = config.DefaultConfig()
+	} else {
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		i.cfg = cfg
+	}
+	// A newly created configuration has no defaults filled in yet
+	if err := config.ValidateConfig(i.cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if i.unit == UnitNone || i.cfg.Daemon.Socket != "" {
+		return nil
+	}
+	i.cfg.Daemon.Socket = config.DefaultDaemonSocket
+	if dryRun {
+		return nil
+	}
+	partial := fmt.Sprintf(`{"daemon": {"socket": %q}}`, config.DefaultDaemonSocket)
+	if _, err := config.MergeConfig(configFile, []byte(partial), false); err != nil {
+		return fmt.Errorf("failed to set daemon.socket: %w", err)
+	}
+	return nil
+}
+
+// steps lists the installation steps in order
+func (i *installer) steps() []installStep {
+	var steps []installStep
+
+	if self, err := os.Executable(); err == nil && !sameFile(self, i.path(i.binary)) {
+		steps = append(steps, installStep{
+			desc: "install binary " + i.path(i.binary),
+			run: func() error {
+				return copyFile(self, i.path(i.binary), binaryPermission)
+			},
+		})
+	}
+
+	if i.unit != UnitNone {
+		sysusers := i.path(filepath.Join(i.paths.sysusers, serviceName+".conf"))
+		steps = append(steps, installStep{
+			desc: "write " + sysusers,
+			run: func() error {
+				return writeInstallFile(sysusers, i.sysusersConf(), publicPermission)
+			},
+		})
+	}
+
+	tmpfiles := i.path(filepath.Join(i.paths.tmpfiles, serviceName+".conf"))
+	steps = append(steps, installStep{
+		desc: "write " + tmpfiles,
+		run: func() error {
+			return writeInstallFile(tmpfiles, i.tmpfilesConf(), publicPermission)
+		},
+	})
+
+	for _, name := range i.unitNames() {
+		unitFile := i.path(filepath.Join(i.paths.units, name))
+		content := i.unitFile(name)
+		steps = append(steps, installStep{
+			desc: "write " + unitFile,
+			run: func() error {
+				return writeInstallFile(unitFile, content, publicPermission)
+			},
+		})
+	}
+
+	action := i.path(actionPath)
+	steps = append(steps, installStep{
+		desc: "write fail2ban action " + action,
+		run: func() error {
+			return writeInstallFile(action, i.actionConf(), publicPermission)
+		},
+	})
+
+	if i.root != "" {
+		return steps
+	}
+
+	if i.unit != UnitNone {
+		steps = append(steps, installStep{
+			desc: "create user " + i.user,
+			run:  i.createUser,
+		})
+	}
+	steps = append(steps, installStep{
+		desc: "create directories owned by " + i.user,
+		run:  i.createDirs,
+	})
+	if i.unit != UnitNone {
+		enable := i.unitNames()[0]
+		steps = append(steps,
+			installStep{
+				desc: "reload systemd",
+				run: func() error {
+					return runTool("systemctl", "daemon-reload")
+				},
+			},
+			installStep{
+				desc: "enable and start " + enable,
+				run: func() error {
+					return runTool("systemctl", "enable", "--now", enable)
+				},
+			},
+		)
+	}
+
+	return steps
+}
+
+// unitNames returns the systemd units to install, the one to enable first
+func (i *installer) unitNames() []string {
+	switch i.unit {
+	case UnitDaemon:
+		return []string{serviceName + ".service"}
+	case UnitSocket:
+		return []string{serviceName + ".socket", serviceName + ".service"}
+	}
+	return nil
+}
+
+// dirs returns the directories written by the notifier
+func (i *installer) dirs() []string {
+	dirs := []string{i.cfg.StateDir, i.cfg.Spool.Dir}
+	if i.cfg.Store.Enabled {
+		dirs = append(dirs, i.cfg.Store.Dir)
+	}
+	return dirs
+}
+
+// sysusersConf returns the sysusers.d entry of the service user
+func (i *installer) sysusersConf() string {
+	return fmt.Sprintf("# fail2ban-notifier service user\nu %s - \"fail2ban-notifier\" %s -\n", i.user, i.cfg.StateDir)
+}
+
+// tmpfilesConf returns the tmpfiles.d entries creating the state directories
+// and making the configuration readable by the service user
+func (i *installer) tmpfilesConf() string {
+	var b strings.Builder
+	b.WriteString("# fail2ban-notifier directories\n")
+	for _, dir := range i.dirs() {
+		fmt.Fprintf(&b, "d %s %04o %s %s -\n", dir, config.DirPermission, i.user, i.user)
+	}
+	fmt.Fprintf(&b, "d %s %04o root root -\n", i.cfg.ConnectorPath, binaryPermission)
+	if i.unit != UnitNone {
+		fmt.Fprintf(&b, "z %s %04o root %s -\n", i.configPath, configPermission, i.user)
+	}
+	return b.String()
+}
+
+// unitFile returns the content of a systemd unit
+func (i *installer) unitFile(name string) string {
+	socket := i.cfg.Daemon.Socket
+
+	if strings.HasSuffix(name, ".socket") {
+		return fmt.Sprintf(`[Unit]
+Description=fail2ban-notifier event socket
+Documentation=https://github.com/eyeskiller/fail2ban-notifier
+
+[Socket]
+ListenStream=%s
+SocketUser=%s
+SocketGroup=%s
+SocketMode=%04o
+DirectoryMode=%04o
+
+[Install]
+WantedBy=sockets.target
+`, socket, i.user, i.socketGroup(), 0660, config.DirPermission)
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=fail2ban-notifier daemon\nDocumentation=https://github.com/eyeskiller/fail2ban-notifier\n")
+	b.WriteString("After=network-online.target\nWants=network-online.target\n")
+	if i.unit == UnitSocket {
+		fmt.Fprintf(&b, "Requires=%s.socket\n", serviceName)
+	}
+
+	fmt.Fprintf(&b, "\n[Service]\nType=simple\nUser=%s\nGroup=%s\n", i.user, i.user)
+	fmt.Fprintf(&b, "ExecStart=%s daemon -config %s\n", i.binary, i.configPath)
+	b.WriteString("Restart=on-failure\nRestartSec=5\n")
+	// The socket unit owns the socket directory when socket-activated
+	if dir := filepath.Dir(socket); i.unit == UnitDaemon && filepath.Dir(dir) == "/run" {
+		fmt.Fprintf(&b, "RuntimeDirectory=%s\nRuntimeDirectoryMode=%04o\n", filepath.Base(dir), config.DirPermission)
+	}
+	b.WriteString("NoNewPrivileges=yes\nCapabilityBoundingSet=\nAmbientCapabilities=\nProtectSystem=full\nPrivateTmp=yes\n")
+	// Credentials referenced by systemd-cred:// settings, looked up in
+	// /etc/credstore
+	for _, name := range i.cfg.SystemdCredentials() {
+		fmt.Fprintf(&b, "LoadCredential=%s\n", name)
+	}
+
+	if i.unit == UnitDaemon {
+		b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	}
+	return b.String()
+}
+
+// socketGroup returns the group allowed to send events on the socket
+func (i *installer) socketGroup() string {
+	if i.cfg.Daemon.SocketGroup != "" {
+		return i.cfg.Daemon.SocketGroup
+	}
+	return i.user
+}
+
+// actionConf returns the fail2ban action. With a daemon the events are
+// forwarded to its socket, otherwise processed by the notifier itself.
+func (i *installer) actionConf() string {
+	command := i.binary
+	if i.unit == UnitNone {
+		if i.configPath != DefaultConfigPath {
+			command += fmt.Sprintf(" -config=%q", i.configPath)
+		}
+	} else {
+		command += fmt.Sprintf(" -socket=%q", i.cfg.Daemon.Socket)
+	}
+
+	return fmt.Sprintf(`# Fail2Ban notification action configuration, written by fail2ban-notify install
+
+[Definition]
+
+actionstart =
+
+actionstop =
+
+actioncheck =
+
+actionban = %s -ip="<ip>" -jail="<name>" -action="ban" -failures="<failures>" -bantime="<bantime>" -restored="<restored>"
+
+actionunban = %s -ip="<ip>" -jail="<name>" -action="unban" -failures="<failures>"
+
+[Init]
+
+name = default
+`, command, command)
+}
+
+// createUser creates the service user with systemd-sysusers, or useradd on
+// systems without systemd-sysusers
+func (i *installer) createUser() error {
+	if _, err := user.Lookup(i.user); err == nil {
+		return nil
+	}
+	if _, err := exec.LookPath("systemd-sysusers"); err == nil {
+		return runTool("systemd-sysusers", filepath.Join(i.paths.sysusers, serviceName+".conf"))
+	}
+	return runTool("useradd", "--system", "--no-create-home", "--home-dir", i.cfg.StateDir,
+		"--shell", "/usr/sbin/nologin", "--user-group", i.user)
+}
+
+// createDirs creates the notifier's directories owned by the service user
+// and makes the configuration readable by it
+func (i *installer) createDirs() error {
+	uid, gid, err := lookupIDs(i.user)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range i.dirs() {
+		if err := os.MkdirAll(dir, config.DirPermission); err != nil {
+			return err
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(i.cfg.ConnectorPath, binaryPermission); err != nil {
+		return err
+	}
+
+	if i.unit == UnitNone {
+		return nil
+	}
+	if err := os.Chown(i.configPath, 0, gid); err != nil {
+		return err
+	}
+	return os.Chmod(i.configPath, configPermission)
+}
+
+// lookupIDs returns the numeric user and group ID of a user
+func lookupIDs(name string) (int, int, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid uid %s: %w", u.Uid, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid gid %s: %w", u.Gid, err)
+	}
+	return uid, gid, nil
+}
+
+// runTool runs a system command, including its output in the error
+func runTool(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// writeInstallFile writes a file, creating its directory
+func writeInstallFile(path, content string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), binaryPermission); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), perm)
+}
+
+// copyFile copies src to dst through a temporary file, so a running binary
+// can be replaced
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(dst), binaryPermission); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// sameFile reports whether two paths refer to the same file
+func sameFile(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
@@ -3,23 +3,31 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/eyeskiller/fail2ban-notifier/internal/config"     //nolint:depguard
 	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
-	"github.com/eyeskiller/fail2ban-notifier/internal/geoip"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/daemon"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/spool"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"      //nolint:depguard
 	"github.com/eyeskiller/fail2ban-notifier/internal/version"    //nolint:depguard
 	"github.com/eyeskiller/fail2ban-notifier/pkg/types"           //nolint:depguard
 )
 
 // Action types
 const (
-	ActionBan   = "ban"
-	ActionUnban = "unban"
+	ActionBan   = types.ActionBan
+	ActionUnban = types.ActionUnban
 )
 
+// DefaultConfigPath is the configuration file used when -config is not given
+const DefaultConfigPath = "/etc/fail2ban/fail2ban-notify.json"
+
 func handleInitConfig(configPath string, cfg *config.Config, logger *log.Logger) {
 	sampleConfig := config.CreateSampleConfig()
 
@@ -50,14 +58,44 @@ func handleInitConfig(configPath string, cfg *config.Config, logger *log.Logger)
 	fmt.Println("3. Add 'notify' action to your fail2ban jails")
 }
 
+// discoveredConnector is a connector found by -discover
+type discoveredConnector struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// discoverReport is the structured output of -discover
+type discoverReport struct {
+	ConnectorPath string                `json:"connector_path"`
+	Connectors    []discoveredConnector `json:"connectors"`
+}
+
 // handleDiscoverConnectors discovers available connectors
-func handleDiscoverConnectors(configPath string, cfg *config.Config, logger *log.Logger) {
+func handleDiscoverConnectors(configPath string, cfg *config.Config, output string, logger *log.Logger) {
 	connectorManager := connectors.NewManager(cfg, logger)
 	discovered, discoverErr := connectorManager.DiscoverConnectors()
 	if discoverErr != nil {
 		logger.Fatalf("Failed to discover connectors: %v", discoverErr)
 	}
 
+	if output != OutputText {
+		report := discoverReport{ConnectorPath: cfg.ConnectorPath, Connectors: []discoveredConnector{}}
+		for _, conn := range discovered {
+			report.Connectors = append(report.Connectors, discoveredConnector{Name: conn.Name, Type: conn.Type, Path: conn.Path})
+		}
+		err := writeOutput(output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "NAME\tTYPE\tPATH")
+			for _, conn := range report.Connectors {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", conn.Name, conn.Type, conn.Path)
+			}
+		})
+		if err != nil {
+			logger.Fatalf("Failed to write output: %v", err)
+		}
+		return
+	}
+
 	fmt.Printf("Connector directory: %s\n", cfg.ConnectorPath)
 	fmt.Printf("Found %d connectors:\n", len(discovered))
 	for _, conn := range discovered {
@@ -72,11 +110,83 @@ func handleDiscoverConnectors(configPath string, cfg *config.Config, logger *log
 	}
 }
 
+// statusReport is the structured output of -status
+type statusReport struct {
+	Connectors []connectors.ConnectorStatus `json:"connectors"`
+	Spool      *spool.Stats                 `json:"spool,omitempty"`
+	Metrics    *types.Metrics               `json:"metrics,omitempty"`
+	// Unacknowledged counts the critical bans nobody acknowledged, nil
+	// when acknowledgments are not tracked
+	Unacknowledged *int `json:"unacknowledged_critical,omitempty"`
+}
+
+// unacknowledgedCritical counts the critical bans within the ack window
+// nobody acknowledged
+func unacknowledgedCritical(cfg *config.Config) (int, error) {
+	since := time.Now().Add(-time.Duration(cfg.Ack.Window) * time.Second)
+	pending, err := store.New(cfg.Store).Unacknowledged(cfg.AckRequired, since)
+	return len(pending), err
+}
+
+// healthLabel summarizes the latest health probe of a connector
+func healthLabel(h *connectors.ProbeResult) string {
+	switch {
+	case h == nil:
+		return "-"
+	case h.Healthy:
+		return "healthy"
+	default:
+		return "failed"
+	}
+}
+
 // handleConnectorStatus shows the status of all connectors
-func handleConnectorStatus(cfg *config.Config, logger *log.Logger) {
+func handleConnectorStatus(cfg *config.Config, output string, logger *log.Logger) {
 	connectorManager := connectors.NewManager(cfg, logger)
 	statuses := connectorManager.GetConnectorStatus()
 
+	if output != OutputText {
+		report := statusReport{Connectors: []connectors.ConnectorStatus{}}
+		for _, status := range statuses {
+			report.Connectors = append(report.Connectors, status)
+		}
+		sort.Slice(report.Connectors, func(i, j int) bool {
+			return report.Connectors[i].Name < report.Connectors[j].Name
+		})
+		if cfg.Spool.Enabled {
+			stats, err := connectorManager.SpoolStats()
+			if err != nil {
+				logger.Fatalf("Failed to read spool: %v", err)
+			}
+			report.Spool = &stats
+
+			metrics, err := connectorManager.Metrics()
+			if err != nil {
+				logger.Fatalf("Failed to read metrics: %v", err)
+			}
+			report.Metrics = &metrics
+		}
+		if cfg.Ack.Enabled {
+			count, err := unacknowledgedCritical(cfg)
+			if err != nil {
+				logger.Fatalf("Failed to count unacknowledged events: %v", err)
+			}
+			report.Unacknowledged = &count
+		}
+
+		err := writeOutput(output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "NAME\tTYPE\tENABLED\tSTATUS\tHEALTH\tSUPPRESSED\tQUEUED\tERROR")
+			for _, s := range report.Connectors {
+				fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%d\t%d\t%s\n", s.Name, s.Type, s.Enabled, s.Status, healthLabel(s.Health),
+					s.Suppressed, s.Queued, s.Error)
+			}
+		})
+		if err != nil {
+			logger.Fatalf("Failed to write output: %v", err)
+		}
+		return
+	}
+
 	fmt.Printf("Connector Status (%d total):\n", len(statuses))
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
@@ -94,6 +204,9 @@ func handleConnectorStatus(cfg *config.Config, logger *log.Logger) {
 		} else if status.Status == "ready" {
 			statusText = "enabled"
 		}
+		if status.BestEffort {
+			statusText += ", best-effort"
+		}
 		fmt.Printf("%s %s [%s] - %s\n", statusIcon, name, statusText, status.Type)
 		if status.Description != "" {
 			fmt.Printf("   %s\n", status.Description)
@@ -101,14 +214,65 @@ func handleConnectorStatus(cfg *config.Config, logger *log.Logger) {
 		if status.Error != "" {
 			fmt.Printf("   Error: %s\n", status.Error)
 		}
+		if h := status.Health; h != nil {
+			ago := cfg.Humanize().Ago(h.Time, time.Now())
+			if h.Healthy {
+				fmt.Printf("   Probe: healthy %s (%s)\n", ago, h.Duration.Round(time.Millisecond))
+			} else {
+				fmt.Printf("   Probe: ⚠️  failed %s: %s\n", ago, h.Error)
+			}
+		}
+		if status.Suppressed > 0 {
+			fmt.Printf("   Throttled: %s suppressed\n", cfg.Humanize().Plural(int64(status.Suppressed), "notification", "notifications"))
+		}
+		if status.Queued > 0 {
+			fmt.Printf("   Queued: %s waiting for redelivery\n", cfg.Humanize().Plural(int64(status.Queued), "notification", "notifications"))
+		}
+	}
+
+	if cfg.Spool.Enabled {
+		stats, err := connectorManager.SpoolStats()
+		if err != nil {
+			logger.Printf("Failed to read spool: %v", err)
+		} else {
+			fmt.Println("")
+			locale := cfg.Humanize()
+			fmt.Printf("Spool: %s queued, %s KB", locale.Plural(int64(stats.Events), "notification", "notifications"),
+				locale.Float(float64(stats.Bytes)/1024, 1))
+			if stats.Events > 0 {
+				fmt.Printf(", oldest from %s", locale.Ago(stats.Oldest, time.Now()))
+			}
+			fmt.Println("")
+		}
+	}
+
+	if cfg.Ack.Enabled {
+		count, err := unacknowledgedCritical(cfg)
+		switch {
+		case err != nil:
+			logger.Printf("Failed to count unacknowledged events: %v", err)
+		case count > 0:
+			fmt.Printf("\n🔔 Unacknowledged: %s waiting for a responder (fail2ban-notify ack -pending)\n",
+				cfg.Humanize().Plural(int64(count), "critical ban", "critical bans"))
+		default:
+			fmt.Println("\n✅ Unacknowledged: no critical bans waiting")
+		}
 	}
 
 	fmt.Println("")
 	fmt.Println("Legend: ✅ Enabled  ⚪ Disabled  ❌ Invalid")
 }
 
+// testReport is the structured output of -test
+type testReport struct {
+	Connector string `json:"connector"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration"`
+}
+
 // handleTestConnector tests a specific connector
-func handleTestConnector(testConnector string, cfg *config.Config, logger *log.Logger) {
+func handleTestConnector(testConnector string, cfg *config.Config, output string, logger *log.Logger) {
 	// Get local hostname for test data
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -119,6 +283,7 @@ func handleTestConnector(testConnector string, cfg *config.Config, logger *log.L
 	}
 
 	testData := &types.NotificationData{
+		EventID:  types.NewEventID(),
 		IP:       "192.168.1.100",
 		Jail:     "test",
 		Action:   ActionBan,
@@ -131,8 +296,29 @@ func handleTestConnector(testConnector string, cfg *config.Config, logger *log.L
 		Failures: 5,
 	}
 
-	fmt.Printf("Testing connector: %s\n", testConnector)
 	connectorManager := connectors.NewManager(cfg, logger)
+	if output != OutputText {
+		start := time.Now()
+		testErr := connectorManager.TestConnector(testConnector, testData)
+		report := testReport{Connector: testConnector, Success: testErr == nil, Duration: time.Since(start).Round(time.Millisecond).String()}
+		if testErr != nil {
+			report.Error = testErr.Error()
+		}
+
+		err := writeOutput(output, report, func(w io.Writer) {
+			fmt.Fprintln(w, "CONNECTOR\tSUCCESS\tDURATION\tERROR")
+			fmt.Fprintf(w, "%s\t%t\t%s\t%s\n", report.Connector, report.Success, report.Duration, report.Error)
+		})
+		if err != nil {
+			logger.Fatalf("Failed to write output: %v", err)
+		}
+		if testErr != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("Testing connector: %s\n", testConnector)
 	testErr := connectorManager.TestConnector(testConnector, testData)
 	if testErr != nil {
 		logger.Fatalf("Connector test failed: %v", testErr)
@@ -140,12 +326,10 @@ func handleTestConnector(testConnector string, cfg *config.Config, logger *log.L
 	fmt.Println("✅ Connector test passed!")
 }
 
-// handleNotification processes a notification
-//
-//nolint:funlen
-func handleNotification(ip, jail, action string, failures int, cfg *config.Config, logger *log.Logger) {
+// validateEvent exits with usage information if the event is incomplete
+func validateEvent(event *pipeline.Event, logger *log.Logger) {
 	// Validate required parameters
-	if ip == "" || jail == "" {
+	if event.IP == "" || event.Jail == "" {
 		_, err := fmt.Fprintf(os.Stderr, "Error: ip and jail parameters are required\n\n")
 		if err != nil {
 			return
@@ -154,124 +338,40 @@ func handleNotification(ip, jail, action string, failures int, cfg *config.Confi
 		os.Exit(1)
 	}
 
-	// Validate action
-	if action != ActionBan && action != ActionUnban {
-		logger.Fatalf("Invalid action: %s (must be '%s' or '%s')", action, ActionBan, ActionUnban)
-	}
-
-	if cfg.Debug {
-		logger.Printf("Processing %s action for IP %s in jail %s", action, ip, jail)
-	}
-
-	// Setup GeoIP manager
-	geoManager := geoip.NewManager(cfg.GeoIP, logger)
-
-	// Perform GeoIP lookup
-	var geoInfo *geoip.Info
-	if cfg.GeoIP.Enabled {
-		geoInfo, lookupErr := geoManager.Lookup(ip)
-		if lookupErr != nil {
-			if cfg.Debug {
-				logger.Printf("GeoIP lookup failed: %v", lookupErr)
-			}
-			// Continue with empty geo info
-			geoInfo = &geoip.Info{IP: ip}
-		} else if cfg.Debug {
-			logger.Printf("GeoIP lookup successful: %s -> %s", ip, geoInfo.Country)
-		}
-	} else {
-		geoInfo = &geoip.Info{IP: ip}
-	}
-
-	// Get local hostname
-	hostname, err := os.Hostname()
-	if err != nil {
-		if cfg.Debug {
-			logger.Printf("Failed to get hostname: %v", err)
-		}
-		hostname = "unknown"
-	}
-
-	// Create notification data
-	notificationData := types.NotificationData{
-		IP:     ip,
-		Jail:   jail,
-		Action: action,
-		Time:   time.Now(),
-		Country: func() string {
-			if geoInfo != nil {
-				return geoInfo.Country
-			}
-			return ""
-		}(),
-		Region: func() string {
-			if geoInfo != nil {
-				return geoInfo.Region
-			}
-			return ""
-		}(),
-		City: func() string {
-			if geoInfo != nil {
-				return geoInfo.City
-			}
-			return ""
-		}(),
-		ISP: func() string {
-			if geoInfo != nil {
-				return geoInfo.ISP
-			}
-			return ""
-		}(),
-		Hostname: hostname, // Local hostname of the server that was attacked
-		Failures: failures,
-		Timezone: func() string {
-			if geoInfo != nil {
-				return geoInfo.Timezone
-			}
-			return ""
-		}(),
-		Latitude: func() float64 {
-			if geoInfo != nil {
-				return geoInfo.Lat
-			}
-			return 0.0
-		}(),
-		Longitude: func() float64 {
-			if geoInfo != nil {
-				return geoInfo.Lon
-			}
-			return 0.0
-		}(),
-	}
-
-	if cfg.Debug {
-		logger.Printf("Notification data: %+v", notificationData)
-	}
-
-	// Get enabled connectors
-	enabledConnectors := cfg.GetEnabledConnectors()
-	if len(enabledConnectors) == 0 {
-		logger.Printf("Warning: No connectors enabled. Edit %s to enable notification services.", cfg.ConnectorPath)
-		return
+	if err := event.Validate(); err != nil {
+		logger.Fatalf("Invalid notification: %v", err)
 	}
+}
 
-	if cfg.Debug {
-		logger.Printf("Found %d enabled connectors", len(enabledConnectors))
-	}
+// handleNotification processes a notification
+func handleNotification(event *pipeline.Event, cfg *config.Config, logger *log.Logger) {
+	validateEvent(event, logger)
 
-	// Execute all enabled connectors
-	connectorManager := connectors.NewManager(cfg, logger)
-	execErr := connectorManager.ExecuteAll(&notificationData)
+	batch, execErr := pipeline.New(cfg, logger).Process(event)
 	if execErr != nil {
 		logger.Printf("Connector execution completed with errors: %v", execErr)
-		// Don't exit with error code as some connectors may have succeeded
-		// The connector manager logs individual failures
+		// Don't exit with error code as some connectors may have succeeded,
+		// unless a configured quorum was missed. The connector manager logs
+		// individual failures; those of best-effort connectors never get here.
+		if cfg.Delivery.Quorum > 0 && batch != nil && !batch.IsSuccess() {
+			os.Exit(1)
+		}
 	} else if cfg.Debug {
 		logger.Printf("All connectors executed successfully")
 	}
 
 	if cfg.Debug {
-		logger.Printf("Notification processing completed for IP %s", ip)
+		logger.Printf("Notification processing completed for IP %s", event.IP)
+	}
+}
+
+// handleForward sends a notification to a running daemon instead of
+// processing it in this process
+func handleForward(socket string, event *pipeline.Event, logger *log.Logger) {
+	validateEvent(event, logger)
+
+	if err := daemon.SendEvent(socket, event); err != nil {
+		logger.Fatalf("Failed to forward notification: %v", err)
 	}
 }
 
@@ -279,29 +379,63 @@ func main() {
 	// Initialize build information
 	version.InitBuildInfo()
 
+	// Dispatch subcommands like "fail2ban-notify token issue"
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	var (
 		ip          = flag.String("ip", "", "IP address that was banned/unbanned")
 		jail        = flag.String("jail", "", "Fail2ban jail name")
 		action      = flag.String("action", ActionBan, "Action performed (ban/unban)")
 		failures    = flag.Int("failures", 0, "Number of failures")
-		configPath  = flag.String("config", "/etc/fail2ban/fail2ban-notify.json", "Path to configuration file")
+		bantime     = flag.Int("bantime", 0, "Ban duration in seconds (-1 for permanent)")
+		matches     = flag.String("matches", "", "Log lines fail2ban matched, one per line")
+		restored    = flag.Bool("restored", false, "The ban was restored after a fail2ban restart (fail2ban's <restored> tag)")
+		configPath  = flag.String("config", DefaultConfigPath, "Path to configuration file")
+		profile     = flag.String("profile", "", "Configuration profile to use (default: mapped from jail)")
 		initConfig  = flag.Bool("init", false, "Initialize configuration file")
 		discover    = flag.Bool("discover", false, "Discover available connectors")
 		test        = flag.String("test", "", "Test specific connector")
 		status      = flag.Bool("status", false, "Show connector status")
 		debug       = flag.Bool("debug", false, "Enable debug logging")
 		versionFlag = flag.Bool("version", false, "Show version information")
+		socket      = flag.String("socket", "", "Forward the event to a daemon listening on this unix socket")
+		output      = flag.String("output", OutputText, "Output format of -status, -discover and -test: text, json, yaml or table")
 	)
 	flag.Parse()
 
 	// Setup logging
 	logger := log.New(os.Stderr, "[fail2ban-notify] ", log.LstdFlags)
 
+	if err := validateOutput(*output); err != nil {
+		logger.Fatalf("%v", err)
+	}
+
 	if *versionFlag {
 		fmt.Println(version.GetBuildInfo())
 		return
 	}
 
+	event := &pipeline.Event{
+		IP:       *ip,
+		Jail:     *jail,
+		Action:   *action,
+		Failures: *failures,
+		BanTime:  *bantime,
+		Time:     time.Now(),
+		Restored: *restored,
+	}
+	if *matches != "" {
+		event.Matches = []string{*matches}
+	}
+
+	// Hand the event to a daemon, e.g. one running in another container
+	if *socket != "" {
+		handleForward(*socket, event, logger)
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
@@ -316,18 +450,33 @@ func main() {
 		logger.Printf("Loaded configuration from %s", *configPath)
 	}
 
+	// Select a profile explicitly or through the jail mapping
+	profileName := *profile
+	if profileName == "" && !*initConfig {
+		profileName = cfg.ProfileForJail(*jail)
+	}
+	if profileName != "" {
+		cfg, err = cfg.WithProfile(profileName)
+		if err != nil {
+			logger.Fatalf("Failed to select profile: %v", err)
+		}
+		if cfg.Debug {
+			logger.Printf("Using profile %s", profileName)
+		}
+	}
+
 	// Handle different command modes
 	switch {
 	case *initConfig:
 		handleInitConfig(*configPath, cfg, logger)
 	case *discover:
-		handleDiscoverConnectors(*configPath, cfg, logger)
+		handleDiscoverConnectors(*configPath, cfg, *output, logger)
 	case *status:
-		handleConnectorStatus(cfg, logger)
+		handleConnectorStatus(cfg, *output, logger)
 	case *test != "":
-		handleTestConnector(*test, cfg, logger)
+		handleTestConnector(*test, cfg, *output, logger)
 	default:
 		// Process notification
-		handleNotification(*ip, *jail, *action, *failures, cfg, logger)
+		handleNotification(event, cfg, logger)
 	}
 }
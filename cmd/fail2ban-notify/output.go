@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats selected with -output
+const (
+	OutputText  = "text" // Human-readable, the default
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputTable = "table" // Aligned columns without decoration
+)
+
+// validateOutput checks an -output value
+func validateOutput(format string) error {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML, OutputTable:
+		return nil
+	}
+	return fmt.Errorf("invalid output format '%s', must be '%s', '%s', '%s' or '%s'",
+		format, OutputText, OutputJSON, OutputYAML, OutputTable)
+}
+
+// writeOutput prints v as JSON or YAML, or as a table written by table. The
+// text format is printed by the commands themselves.
+func writeOutput(format string, v interface{}, table func(w io.Writer)) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case OutputYAML:
+		return writeYAML(os.Stdout, v)
+	case OutputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// writeYAML prints v as YAML, going through its JSON encoding so the field
+// names match the JSON output
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	var b strings.Builder
+	switch tree.(type) {
+	case map[string]interface{}, []interface{}:
+		yamlBlock(&b, tree, 0)
+	default:
+		b.WriteString(yamlScalar(tree) + "\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// yamlBlock writes a mapping or sequence at the given indentation
+func yamlBlock(b *strings.Builder, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(pad + yamlScalar(key) + ":")
+			yamlChild(b, v[key], indent)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+				// Start the mapping on the line of its "-"
+				var nested strings.Builder
+				yamlBlock(&nested, m, indent+2)
+				b.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+				continue
+			}
+			b.WriteString(pad + "-")
+			yamlChild(b, item, indent)
+		}
+	}
+}
+
+// yamlChild writes the value following a "key:" or "-"
+func yamlChild(b *strings.Builder, v interface{}, indent int) {
+	switch child := v.(type) {
+	case map[string]interface{}:
+		if len(child) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlBlock(b, child, indent+2)
+	case []interface{}:
+		if len(child) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlBlock(b, child, indent+2)
+	default:
+		b.WriteString(" " + yamlScalar(child) + "\n")
+	}
+}
+
+// yamlPlain matches strings that can be written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./@+-]*( [A-Za-z0-9_./@+()-]+)*$`)
+
+// yamlReserved are plain words YAML would not read as strings
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "null": true, "y": true, "n": true,
+}
+
+// yamlScalar formats a scalar, quoting strings that YAML would misread
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlain.MatchString(v) && !yamlReserved[strings.ToLower(v)] {
+			return v
+		}
+		return strconv.Quote(v)
+	default:
+		return strconv.Quote(fmt.Sprint(v))
+	}
+}
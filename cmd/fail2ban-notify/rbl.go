@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/rbl"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+)
+
+func init() {
+	registerCommand("rbl", "Regenerate the DNS blocklist zone or list its entries (write, list)", runRBL)
+}
+
+// runRBL dispatches the rbl subcommands
+func runRBL(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: fail2ban-notify rbl <write|list> [options]")
+	}
+
+	fs := flag.NewFlagSet("rbl "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !cfg.RBL.Enabled {
+		return fmt.Errorf("rbl is disabled in %s", *configPath)
+	}
+
+	st := store.New(cfg.Store)
+	switch args[0] {
+	case "write":
+		if err := rbl.WriteZone(&cfg.RBL, st, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("Zone %s written to %s\n", cfg.RBL.Zone, cfg.RBL.ZoneFile)
+		return nil
+	case "list":
+		return handleRBLList(st)
+	default:
+		return fmt.Errorf("unknown rbl command: %s", args[0])
+	}
+}
+
+// handleRBLList prints the currently listed IPs
+func handleRBLList(st *store.Store) error {
+	bans, err := st.ActiveBans(time.Now())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tJAIL\tSINCE\tEXPIRES")
+	for _, ban := range bans {
+		expires := "never"
+		if !ban.Expires.IsZero() {
+			expires = ban.Expires.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ban.IP, ban.Jail, ban.Since.Local().Format(time.RFC3339), expires)
+	}
+	return w.Flush()
+}
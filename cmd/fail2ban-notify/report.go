@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/report"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// Report formats besides the common -output formats
+const (
+	OutputHTML = "html"
+	OutputPDF  = "pdf"
+)
+
+func init() {
+	registerCommand("report", "Compile an incident report on an IP for legal or abuse teams (incident <ip>)", runReport)
+}
+
+// runReport dispatches the report subcommands
+func runReport(args []string) error {
+	if len(args) == 0 || args[0] != "incident" {
+		return fmt.Errorf("usage: fail2ban-notify report incident <ip> [-output text|json|yaml|html|pdf] [-file path]")
+	}
+	return runReportIncident(args[1:])
+}
+
+// runReportIncident compiles everything the event store knows about an IP
+func runReportIncident(args []string) error {
+	// The IP may come before the flags
+	var ip string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		ip, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("report incident", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml, html or pdf")
+	file := fs.String("file", "", "Write the report to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if ip == "" {
+		ip = fs.Arg(0)
+	}
+	if ip == "" {
+		return fmt.Errorf("usage: fail2ban-notify report incident <ip> [-config path] [-output format] [-file path]")
+	}
+	switch *output {
+	case OutputText, OutputJSON, OutputYAML, OutputHTML, OutputPDF:
+	default:
+		return fmt.Errorf("invalid output format '%s', must be '%s', '%s', '%s', '%s' or '%s'",
+			*output, OutputText, OutputJSON, OutputYAML, OutputHTML, OutputPDF)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Store.Enabled {
+		return fmt.Errorf("the event store is disabled in %s", *configPath)
+	}
+
+	matches, err := incidentMatcher(&cfg.Privacy, ip)
+	if err != nil {
+		return err
+	}
+	incident, err := report.Collect(store.New(cfg.Store), ip, matches, time.Now())
+	if err != nil {
+		return err
+	}
+	if incident == nil {
+		return fmt.Errorf("no events stored for %s", ip)
+	}
+
+	var b bytes.Buffer
+	switch *output {
+	case OutputHTML:
+		err = report.WriteHTML(&b, incident)
+	case OutputPDF:
+		err = report.WritePDF(&b, incident)
+	case OutputText:
+		err = report.WriteText(&b, incident)
+	case OutputYAML:
+		err = writeYAML(&b, incident)
+	default:
+		encoder := json.NewEncoder(&b)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(incident)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if *file == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	if err := os.WriteFile(filepath.Clean(*file), b.Bytes(), state.FilePermission); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	fmt.Printf("Wrote the report on %s (%d events) to %s\n", ip, len(incident.Timeline), *file)
+	return nil
+}
+
+// incidentMatcher matches the events of ip. In privacy mode, stored events
+// are matched by the keyed hash of the IP too, since the store may hold
+// pseudonyms only.
+func incidentMatcher(cfg *config.PrivacyConfig, ip string) (report.Matcher, error) {
+	if !cfg.Enabled() {
+		return func(data *types.NotificationData) bool {
+			return data.IP == ip
+		}, nil
+	}
+
+	hash, err := privacy.NewHasher(*cfg).Hash(ip)
+	if err != nil {
+		return nil, err
+	}
+	return func(data *types.NotificationData) bool {
+		return data.IP == ip || data.IP == hash || data.IPHash == hash
+	}, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/spool"      //nolint:depguard
+)
+
+func init() {
+	registerCommand("spool", "Inspect or flush notifications queued after failed delivery (list, flush)", runSpool)
+}
+
+// runSpool dispatches the spool subcommands
+func runSpool(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: fail2ban-notify spool <list|flush> [options]")
+	}
+
+	fs := flag.NewFlagSet("spool "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !cfg.Spool.Enabled {
+		return fmt.Errorf("spool is disabled in %s", *configPath)
+	}
+
+	switch args[0] {
+	case "list":
+		return handleSpoolList(cfg)
+	case "flush":
+		return handleSpoolFlush(cfg)
+	default:
+		return fmt.Errorf("unknown spool command: %s", args[0])
+	}
+}
+
+// handleSpoolList prints all queued notifications
+func handleSpoolList(cfg *config.Config) error {
+	entries, err := spool.New(cfg.Spool, cfg.Store.Encryption).Entries()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUED\tCONNECTOR\tEVENT\tATTEMPTS\tLAST ERROR")
+	for _, entry := range entries {
+		event := entry.Data.String()
+		if entry.Data.Suppressed > 0 {
+			event = fmt.Sprintf("%s (+%d)", event, entry.Data.Suppressed)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", entry.Queued.Format(time.RFC3339), entry.Connector,
+			event, entry.Attempts, entry.LastError)
+	}
+	return w.Flush()
+}
+
+// handleSpoolFlush retries delivery of all queued notifications
+func handleSpoolFlush(cfg *config.Config) error {
+	logger := log.New(os.Stderr, "[fail2ban-notify] ", log.LstdFlags)
+	delivered, failed, err := connectors.NewManager(cfg, logger).FlushSpool()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Delivered %d queued notifications, %d still failing\n", delivered, failed)
+	return nil
+}
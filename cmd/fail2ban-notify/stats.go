@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+func init() {
+	registerCommand("stats", "Summarize the event store: bans, active bans and bans per jail and country", runStats)
+}
+
+// statsCount is the number of bans for a jail or country
+type statsCount struct {
+	Key  string `json:"key"`
+	Bans int    `json:"bans"`
+}
+
+// statsReport is the output of the stats command
+type statsReport struct {
+	Since      time.Time    `json:"since"`
+	Events     int          `json:"events"`
+	Bans       int          `json:"bans"`
+	Unbans     int          `json:"unbans"`
+	UniqueIPs  *int         `json:"unique_ips,omitempty"` // Unknown when counted from rollups
+	ActiveBans int          `json:"active_bans"`
+	Jails      []statsCount `json:"jails"`
+	Countries  []statsCount `json:"countries"`
+}
+
+// runStats summarizes the events of the last days in the event store
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	days := fs.Int("days", 7, "Summarize the last N days")
+	raw := fs.Bool("raw", false, "Read every raw event instead of the rollups, to count unique IPs")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Store.Enabled {
+		return fmt.Errorf("the event store is disabled in %s", *configPath)
+	}
+
+	now := time.Now()
+	st := store.New(cfg.Store)
+	var report *statsReport
+	if cfg.Store.Rollups.Enabled && !*raw {
+		report, err = collectRollupStats(st, now.AddDate(0, 0, -*days), now)
+	} else {
+		report, err = collectStats(st, now.AddDate(0, 0, -*days), now)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == OutputText {
+		printStats(report, *days, cfg.Humanize())
+		return nil
+	}
+	return writeOutput(*output, report, func(w io.Writer) {
+		writeStatsTable(w, report)
+	})
+}
+
+// collectStats counts the events since the given time
+func collectStats(st *store.Store, since, now time.Time) (*statsReport, error) {
+	report := &statsReport{Since: since, Jails: []statsCount{}, Countries: []statsCount{}}
+	ips := make(map[string]bool)
+	jails := make(map[string]int)
+	countries := make(map[string]int)
+
+	err := st.Scan(func(data *types.NotificationData) error {
+		if data.Time.Before(since) {
+			return nil
+		}
+		report.Events++
+		switch {
+		case data.IsBan():
+			report.Bans++
+			ips[data.IP] = true
+			jails[data.Jail]++
+			if country := data.CountryCode; country != "" {
+				countries[country]++
+			} else if data.Country != "" {
+				countries[data.Country]++
+			}
+		case data.IsUnban():
+			report.Unbans++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := st.ActiveBans(now)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueIPs := len(ips)
+	report.UniqueIPs = &uniqueIPs
+	report.ActiveBans = len(active)
+	report.Jails = sortedCounts(jails)
+	report.Countries = sortedCounts(countries)
+	return report, nil
+}
+
+// collectRollupStats counts the bans and unbans since the given time from
+// the store's rollups, without unique IPs
+func collectRollupStats(st *store.Store, since, now time.Time) (*statsReport, error) {
+	rollups, err := st.Rollups(since, time.Time{}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &statsReport{Since: since}
+	jails := make(map[string]int)
+	countries := make(map[string]int)
+	for _, r := range rollups {
+		report.Bans += r.Bans
+		report.Unbans += r.Unbans
+		if r.Bans == 0 {
+			continue
+		}
+		jails[r.Jail] += r.Bans
+		if r.CountryCode != "" {
+			countries[r.CountryCode] += r.Bans
+		} else if r.Country != "" {
+			countries[r.Country] += r.Bans
+		}
+	}
+	report.Events = report.Bans + report.Unbans
+
+	active, err := st.ActiveBans(now)
+	if err != nil {
+		return nil, err
+	}
+	report.ActiveBans = len(active)
+	report.Jails = sortedCounts(jails)
+	report.Countries = sortedCounts(countries)
+	return report, nil
+}
+
+// sortedCounts returns the counts ordered by bans, most first
+func sortedCounts(counts map[string]int) []statsCount {
+	sorted := make([]statsCount, 0, len(counts))
+	for key, bans := range counts {
+		sorted = append(sorted, statsCount{Key: key, Bans: bans})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bans != sorted[j].Bans {
+			return sorted[i].Bans > sorted[j].Bans
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// printStats prints the report for people
+func printStats(report *statsReport, days int, locale humanize.Locale) {
+	bans := locale.Plural(int64(report.Bans), "ban", "bans")
+	if report.UniqueIPs != nil {
+		bans += " of " + locale.Plural(int64(*report.UniqueIPs), "IP", "IPs")
+	}
+	fmt.Printf("📊 Last %d days: %s, %s, %s currently banned\n", days,
+		bans, locale.Plural(int64(report.Unbans), "unban", "unbans"), locale.Number(int64(report.ActiveBans)))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(report.Jails) > 0 {
+		fmt.Fprintln(w, "\nBans per jail:")
+		for _, c := range report.Jails {
+			fmt.Fprintf(w, "  %s\t%s\n", c.Key, locale.Number(int64(c.Bans)))
+		}
+	}
+	if len(report.Countries) > 0 {
+		fmt.Fprintln(w, "\nBans per country:")
+		for _, c := range report.Countries {
+			fmt.Fprintf(w, "  %s\t%s\n", c.Key, locale.Number(int64(c.Bans)))
+		}
+	}
+	_ = w.Flush()
+}
+
+// writeStatsTable writes the report as plain columns: the totals, then the
+// bans per jail and per country
+func writeStatsTable(w io.Writer, report *statsReport) {
+	fmt.Fprintln(w, "EVENTS\tBANS\tUNBANS\tUNIQUE IPS\tACTIVE BANS")
+	uniqueIPs := "-"
+	if report.UniqueIPs != nil {
+		uniqueIPs = fmt.Sprint(*report.UniqueIPs)
+	}
+	fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%d\n", report.Events, report.Bans, report.Unbans, uniqueIPs, report.ActiveBans)
+
+	fmt.Fprintln(w, "\nJAIL\tBANS")
+	for _, c := range report.Jails {
+		fmt.Fprintf(w, "%s\t%d\n", c.Key, c.Bans)
+	}
+
+	fmt.Fprintln(w, "\nCOUNTRY\tBANS")
+	for _, c := range report.Countries {
+		fmt.Fprintf(w, "%s\t%d\n", c.Key, c.Bans)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/bansync" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"   //nolint:depguard
+)
+
+func init() {
+	registerCommand("sync", "Reconcile the active bans in the event store with those fail2ban-client reports", runSync)
+}
+
+// runSync updates the store's active bans from fail2ban
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	jails := fs.String("jails", "", "Comma-separated jails to reconcile (default: all running jails)")
+	dryRun := fs.Bool("dry-run", false, "Show the changes without saving them")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var selected []string
+	for _, jail := range strings.Split(*jails, ",") {
+		if jail = strings.TrimSpace(jail); jail != "" {
+			selected = append(selected, jail)
+		}
+	}
+
+	result, err := bansync.Sync(context.Background(), cfg, selected, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *output == OutputText {
+		printSync(result, *dryRun)
+		return nil
+	}
+	return writeOutput(*output, result, func(w io.Writer) {
+		fmt.Fprintln(w, "CHANGE\tIP\tJAIL\tSINCE")
+		for _, ban := range result.Added {
+			fmt.Fprintf(w, "added\t%s\t%s\t%s\n", ban.IP, ban.Jail, ban.Since.Format(time.RFC3339))
+		}
+		for _, ban := range result.Removed {
+			fmt.Fprintf(w, "removed\t%s\t%s\t%s\n", ban.IP, ban.Jail, ban.Since.Format(time.RFC3339))
+		}
+	})
+}
+
+// printSync prints the reconciled bans for people
+func printSync(r *store.SyncResult, dryRun bool) {
+	verb := "Synced"
+	if dryRun {
+		verb = "Would sync (dry run)"
+	}
+	fmt.Printf("🔄 %s %d jails: %d bans added, %d removed, %d unchanged\n",
+		verb, len(r.Jails), len(r.Added), len(r.Removed), r.Kept)
+	for _, ban := range r.Added {
+		fmt.Printf("   + %s in %s since %s\n", ban.IP, ban.Jail, ban.Since.Format(time.RFC3339))
+	}
+	for _, ban := range r.Removed {
+		fmt.Printf("   - %s in %s\n", ban.IP, ban.Jail)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/auth"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	registerCommand("token", "Manage daemon API tokens (issue, list, revoke)", runToken)
+}
+
+// runToken dispatches the token subcommands
+func runToken(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: fail2ban-notify token <issue|list|revoke> [options]")
+	}
+
+	switch args[0] {
+	case "issue":
+		return handleTokenIssue(args[1:])
+	case "list":
+		return handleTokenList(args[1:])
+	case "revoke":
+		return handleTokenRevoke(args[1:])
+	default:
+		return fmt.Errorf("unknown token command: %s", args[0])
+	}
+}
+
+// handleTokenIssue creates a new token and stores its hash in the config
+func handleTokenIssue(args []string) error {
+	fs := flag.NewFlagSet("token issue", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	name := fs.String("name", "", "Token name")
+	role := fs.String("role", config.RoleRead, "Token role (read/operator)")
+	rateLimit := fs.Int("rate-limit", 60, "Requests per minute, 0 for unlimited")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.API.FindToken(*name) >= 0 {
+		return fmt.Errorf("token %s already exists", *name)
+	}
+
+	plain, token, err := auth.Issue(*name, *role, *rateLimit)
+	if err != nil {
+		return err
+	}
+
+	cfg.API.Tokens = append(cfg.API.Tokens, token)
+	if err := config.SaveConfig(*configPath, cfg); err != nil {
+		return err
+	}
+
+	fmt.Printf("Issued %s token %s:\n\n  %s\n\n", token.Role, token.Name, plain)
+	fmt.Println("Store it now, it cannot be shown again.")
+	return nil
+}
+
+// handleTokenList prints the configured tokens
+func handleTokenList(args []string) error {
+	fs := flag.NewFlagSet("token list", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tRATE LIMIT\tCREATED")
+	for _, token := range cfg.API.Tokens {
+		limit := "unlimited"
+		if token.RateLimit > 0 {
+			limit = fmt.Sprintf("%d/min", token.RateLimit)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", token.Name, token.Role, limit, token.Created.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+// handleTokenRevoke removes a token from the config
+func handleTokenRevoke(args []string) error {
+	fs := flag.NewFlagSet("token revoke", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	name := fs.String("name", "", "Token name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	i := cfg.API.FindToken(*name)
+	if i < 0 {
+		return fmt.Errorf("token %s not found", *name)
+	}
+
+	cfg.API.Tokens = append(cfg.API.Tokens[:i], cfg.API.Tokens[i+1:]...)
+	if err := config.SaveConfig(*configPath, cfg); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked token %s\n", *name)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	registerCommand("validate", "Check the configuration file for errors", runValidate)
+}
+
+// validateReport is the structured output of the validate command
+type validateReport struct {
+	Config     string `json:"config"`
+	Valid      bool   `json:"valid"`
+	Error      string `json:"error,omitempty"`
+	Connectors int    `json:"connectors"`
+	Enabled    int    `json:"enabled"`
+	Profiles   int    `json:"profiles"`
+}
+
+// runValidate loads the configuration and reports whether it is valid. It
+// exits with status 1 if it is not.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	report := validateReport{Config: *configPath}
+	var loadErr error
+	if _, err := os.Stat(*configPath); err != nil {
+		// LoadConfig would create a default configuration instead
+		loadErr = fmt.Errorf("failed to read config file: %w", err)
+	} else {
+		var cfg *config.Config
+		if cfg, loadErr = config.LoadConfig(*configPath); loadErr == nil {
+			report.Connectors = len(cfg.Connectors)
+			report.Enabled = len(cfg.GetEnabledConnectors())
+			report.Profiles = len(cfg.Profiles)
+		}
+	}
+	report.Valid = loadErr == nil
+	if loadErr != nil {
+		report.Error = loadErr.Error()
+	}
+
+	if *output == OutputText {
+		if loadErr != nil {
+			return fmt.Errorf("%s is invalid: %w", *configPath, loadErr)
+		}
+		fmt.Printf("✅ %s is valid: %d connectors (%d enabled), %d profiles\n",
+			*configPath, report.Connectors, report.Enabled, report.Profiles)
+		return nil
+	}
+
+	err := writeOutput(*output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "CONFIG\tVALID\tCONNECTORS\tENABLED\tPROFILES\tERROR")
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%d\t%s\n", report.Config, report.Valid, report.Connectors, report.Enabled, report.Profiles, report.Error)
+	})
+	if err != nil {
+		return err
+	}
+	if loadErr != nil {
+		os.Exit(1)
+	}
+	return nil
+}
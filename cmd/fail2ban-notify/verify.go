@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/chain"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	registerCommand("verify", "Check the hash-chained event log for tampering", runVerify)
+}
+
+// verifyReport is the structured output of the verify command
+type verifyReport struct {
+	Chain   string `json:"chain"`
+	Intact  bool   `json:"intact"`
+	Error   string `json:"error,omitempty"`
+	Records uint64 `json:"records"` // Intact records, up to the first broken one
+	Signed  uint64 `json:"signed"`
+	Head    string `json:"head,omitempty"`
+	First   string `json:"first,omitempty"`
+	Last    string `json:"last,omitempty"`
+}
+
+// runVerify checks the chain record by record. It exits with status 1 if
+// the chain is broken.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath, "Path to configuration file")
+	file := fs.String("file", "", "Chain to verify, such as a copy on another host (default: store.chain.path)")
+	publicKeyPath := fs.String("public-key", "", "Public key checking the signatures (default: <store.chain.key_file>.pub when signing)")
+	output := fs.String("output", OutputText, "Output format: text, json, yaml or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	// A copy verified with its public key needs no configuration
+	path, keyPath := *file, *publicKeyPath
+	if path == "" || keyPath == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if path == "" {
+			if !cfg.Store.Chain.Enabled {
+				return fmt.Errorf("the event chain is disabled in %s", *configPath)
+			}
+			path = cfg.Store.Chain.Path
+		}
+		if keyPath == "" && cfg.Store.Chain.Sign {
+			keyPath = chain.PublicKeyPath(cfg.Store.Chain.KeyFile)
+		}
+	}
+
+	var publicKey ed25519.PublicKey
+	if keyPath != "" {
+		var err error
+		if publicKey, err = chain.LoadPublicKey(keyPath); err != nil {
+			return err
+		}
+	}
+
+	result, verifyErr := chain.Verify(path, publicKey)
+	if result == nil {
+		return verifyErr
+	}
+	report := verifyReport{
+		Chain:   path,
+		Intact:  verifyErr == nil,
+		Records: result.Records,
+		Signed:  result.Signed,
+		Head:    result.Head,
+		First:   result.First,
+		Last:    result.Last,
+	}
+	if verifyErr != nil {
+		report.Error = verifyErr.Error()
+	}
+
+	if *output == OutputText {
+		if verifyErr != nil {
+			return fmt.Errorf("%s is broken at %w (%d records before it are intact)", path, verifyErr, result.Records)
+		}
+		signed := "unsigned"
+		if publicKey != nil {
+			signed = "all signed"
+		}
+		fmt.Printf("✅ %s is intact: %d records, %s\n", path, result.Records, signed)
+		if result.Records > 0 {
+			fmt.Printf("   From %s to %s\n", result.First, result.Last)
+			fmt.Printf("   Head %s\n", result.Head)
+		}
+		return nil
+	}
+
+	err := writeOutput(*output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "CHAIN\tINTACT\tRECORDS\tSIGNED\tHEAD\tERROR")
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%s\t%s\n", report.Chain, report.Intact, report.Records, report.Signed, report.Head, report.Error)
+	})
+	if err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		os.Exit(1)
+	}
+	return nil
+}
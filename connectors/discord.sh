@@ -15,6 +15,13 @@ if [[ -z "$WEBHOOK_URL" ]]; then
     exit 1
 fi
 
+# Health probe: Discord returns the webhook's details for a GET, so this
+# checks it still exists without posting
+if [[ "${1:-}" == "--healthcheck" ]]; then
+    curl -fsS -o /dev/null --max-time 10 "$WEBHOOK_URL"
+    exit 0
+fi
+
 # Read JSON data from stdin (optional - we also have env vars)
 JSON_DATA=""
 if [[ -p /dev/stdin ]]; then
@@ -27,16 +34,33 @@ JAIL="${F2B_JAIL:-unknown}"
 ACTION="${F2B_ACTION:-ban}"
 TIME="${F2B_TIME:-$(date -Iseconds)}"
 COUNTRY="${F2B_COUNTRY:-}"
+FLAG="${F2B_FLAG:-}"
 REGION="${F2B_REGION:-}"
 CITY="${F2B_CITY:-}"
 ISP="${F2B_ISP:-}"
 HOSTNAME="${F2B_HOSTNAME:-}"
 FAILURES="${F2B_FAILURES:-0}"
+SUPPRESSED="${F2B_SUPPRESSED:-0}"
+HONEYPOT="${F2B_HONEYPOT_SESSIONS:-0}"
+ANONYMITY="${F2B_ANONYMITY:-}"
+DOMAINS="${F2B_DOMAINS:-}"
+RISK="${F2B_RISK_SCORE:-0}"
+HISTORY="${F2B_HISTORY:-}"
+ESCALATION="${F2B_ESCALATION:-}"
+CAMPAIGN="${F2B_CAMPAIGN:-}"
+SURGE_BANS="${F2B_SURGE_BANS:-0}"
+SURGE_WINDOW="${F2B_SURGE_WINDOW:-3600}"
+SURGE_BASELINE="${F2B_SURGE_BASELINE:-0}"
+BULK_UNBANS="${F2B_BULK_UNBANS:-0}"
+BULK_WINDOW="${F2B_BULK_WINDOW:-0}"
 
 # Determine color based on action
-if [[ "$ACTION" == "unban" ]]; then
+if [[ "$ACTION" == "unban" || "$ACTION" == "bulk_unban" ]]; then
     COLOR="4505434"  # Green
     EMOJI="✅"
+elif [[ "$ACTION" == "surge" ]]; then
+    COLOR="16753920"  # Orange
+    EMOJI="📈"
 else
     COLOR="16711684"  # Red
     EMOJI="🚫"
@@ -52,15 +76,64 @@ if [[ -n "$COUNTRY" ]]; then
 fi
 
 # Create the embed fields
+DESCRIPTION="IP **$IP**$LOCATION has been ${ACTION}ned"
 FIELDS='[
     {"name": "IP Address", "value": "'"$IP"'", "inline": true},
     {"name": "Jail", "value": "'"$JAIL"'", "inline": true},
     {"name": "Action", "value": "'"${ACTION^}"'", "inline": true}'
 
+# A surge is about the jail, not a single IP
+if [[ "$ACTION" == "surge" ]]; then
+    DESCRIPTION="**$SURGE_BANS bans** within $((SURGE_WINDOW / 60)) minutes, baseline $SURGE_BASELINE per window"
+    FIELDS='[
+    {"name": "Jail", "value": "'"$JAIL"'", "inline": true},
+    {"name": "Action", "value": "Surge", "inline": true}'
+fi
+
+# So is a bulk unban, when the jail was flushed
+if [[ "$ACTION" == "bulk_unban" ]]; then
+    DESCRIPTION="**$BULK_UNBANS IPs** unbanned within $BULK_WINDOW seconds"
+    FIELDS='[
+    {"name": "Jail", "value": "'"$JAIL"'", "inline": true},
+    {"name": "Action", "value": "Bulk unban", "inline": true}'
+fi
+
 if [[ "$FAILURES" -gt 0 ]]; then
     FIELDS+=',{"name": "Failures", "value": "'"$FAILURES"'", "inline": true}'
 fi
 
+if [[ -n "$HISTORY" ]]; then
+    FIELDS+=',{"name": "History", "value": "'"$HISTORY"'", "inline": false}'
+fi
+
+if [[ -n "$ESCALATION" ]]; then
+    FIELDS+=',{"name": "Escalation", "value": "'"$ESCALATION"'", "inline": false}'
+fi
+
+if [[ "$RISK" -gt 0 ]]; then
+    FIELDS+=',{"name": "Risk", "value": "'"$RISK/100"'", "inline": true}'
+fi
+
+if [[ -n "$CAMPAIGN" ]]; then
+    FIELDS+=',{"name": "Campaign", "value": "'"$CAMPAIGN"'", "inline": false}'
+fi
+
+if [[ -n "$DOMAINS" ]]; then
+    FIELDS+=',{"name": "Recent Domains", "value": "'"${DOMAINS//,/, }"'", "inline": false}'
+fi
+
+if [[ -n "$ANONYMITY" ]]; then
+    FIELDS+=',{"name": "Anonymity", "value": "'"$ANONYMITY"'", "inline": true}'
+fi
+
+if [[ "$HONEYPOT" -gt 0 ]]; then
+    FIELDS+=',{"name": "Honeypot", "value": "'"yes, $HONEYPOT sessions"'", "inline": true}'
+fi
+
+if [[ "$SUPPRESSED" -gt 0 ]]; then
+    FIELDS+=',{"name": "Throttled", "value": "'"$SUPPRESSED further notifications suppressed"'", "inline": false}'
+fi
+
 if [[ -n "$ISP" ]]; then
     FIELDS+=',{"name": "ISP", "value": "'"$ISP"'", "inline": true}'
 fi
@@ -70,7 +143,7 @@ if [[ -n "$HOSTNAME" ]]; then
 fi
 
 if [[ -n "$COUNTRY" ]]; then
-    FIELDS+=',{"name": "Location", "value": "'"${CITY:+$CITY, }$COUNTRY"'", "inline": true}'
+    FIELDS+=',{"name": "Location", "value": "'"${FLAG:+$FLAG }${CITY:+$CITY, }$COUNTRY"'", "inline": true}'
 fi
 
 FIELDS+=']'
@@ -81,8 +154,8 @@ PAYLOAD=$(cat <<EOF
     "username": "$USERNAME",
     "avatar_url": "$AVATAR_URL",
     "embeds": [{
-        "title": "$EMOJI Fail2Ban ${ACTION^}: $JAIL",
-        "description": "IP **$IP**$LOCATION has been ${ACTION}ned",
+        "title": "${F2B_TITLE:-$EMOJI Fail2Ban ${ACTION^}: $JAIL}",
+        "description": "$DESCRIPTION",
         "color": $COLOR,
         "timestamp": "$TIME",
         "fields": $FIELDS,
@@ -5,12 +5,29 @@ Place this file in /etc/fail2ban/connectors/email.py
 """
 
 import os
+import re
 import sys
 import json
+import fcntl
 import smtplib
 from email.mime.text import MIMEText
 from email.mime.multipart import MIMEMultipart
+from email.mime.application import MIMEApplication
+from email.mime.image import MIMEImage
 from datetime import datetime
+from html import escape
+
+# Seconds between digests for each schedule; instant mails every event
+SCHEDULES = {
+    'instant': 0,
+    'hourly': 3600,
+    'daily': 86400,
+    'weekly': 7 * 86400,
+}
+
+# Seconds the recipients of an event are remembered, so a retry of the
+# connector neither mails them again nor queues the event twice
+DELIVERED_TTL = 86400
 
 def get_config():
     """Get configuration from environment variables"""
@@ -23,8 +40,46 @@ def get_config():
         'from_email': os.getenv('EMAIL_FROM', 'fail2ban@localhost'),
         'to_email': os.getenv('EMAIL_TO', 'admin@localhost'),
         'subject_prefix': os.getenv('EMAIL_SUBJECT_PREFIX', '[Fail2Ban]'),
+        'subscribers': os.getenv('EMAIL_SUBSCRIBERS', ''),
+        'digest_dir': os.getenv('EMAIL_DIGEST_DIR', '/var/lib/fail2ban-notify/email-digest'),
+        'heatmap': os.getenv('EMAIL_HEATMAP', ''),
     }
 
+def get_subscribers(config):
+    """Get the recipients with their schedules and jail filters.
+
+    EMAIL_SUBSCRIBERS is a JSON list such as
+    [{"to": "ops@example.com", "schedule": "daily", "jails": ["sshd"]}].
+    Without it, EMAIL_TO receives every event as it happens.
+    """
+    if not config['subscribers']:
+        return [{'to': config['to_email'], 'schedule': 'instant', 'jails': []}]
+
+    try:
+        entries = json.loads(config['subscribers'])
+    except json.JSONDecodeError as e:
+        raise ValueError(f"EMAIL_SUBSCRIBERS is not valid JSON: {e}")
+    if not isinstance(entries, list):
+        raise ValueError("EMAIL_SUBSCRIBERS must be a JSON list")
+
+    subscribers = []
+    for entry in entries:
+        if not isinstance(entry, dict) or not entry.get('to'):
+            raise ValueError("every subscriber needs a 'to' address")
+        schedule = entry.get('schedule', 'instant')
+        if schedule not in SCHEDULES:
+            raise ValueError(f"subscriber {entry['to']}: unknown schedule '{schedule}', "
+                             f"expected one of {', '.join(SCHEDULES)}")
+        jails = entry.get('jails', [])
+        if not isinstance(jails, list):
+            raise ValueError(f"subscriber {entry['to']}: 'jails' must be a list")
+        subscribers.append({'to': entry['to'], 'schedule': schedule, 'jails': jails})
+    return subscribers
+
+def wants(subscriber, data):
+    """Report whether the subscriber's jail filter matches the event"""
+    return not subscriber['jails'] or data['jail'] in subscriber['jails']
+
 def get_notification_data():
     """Get notification data from environment variables and stdin"""
     data = {
@@ -33,11 +88,26 @@ def get_notification_data():
         'action': os.getenv('F2B_ACTION', 'ban'),
         'time': os.getenv('F2B_TIME', datetime.now().isoformat()),
         'country': os.getenv('F2B_COUNTRY', ''),
+        'flag': os.getenv('F2B_FLAG', ''),
         'region': os.getenv('F2B_REGION', ''),
         'city': os.getenv('F2B_CITY', ''),
         'isp': os.getenv('F2B_ISP', ''),
         'hostname': os.getenv('F2B_HOSTNAME', ''),
         'failures': int(os.getenv('F2B_FAILURES', '0')),
+        'event_id': os.getenv('F2B_EVENT_ID', ''),
+        'artifact': os.getenv('F2B_ARTIFACT', ''),
+        'artifact_url': os.getenv('F2B_ARTIFACT_URL', ''),
+        'honeypot_sessions': int(os.getenv('F2B_HONEYPOT_SESSIONS', '0')),
+        'anonymity': os.getenv('F2B_ANONYMITY', ''),
+        'risk_score': int(os.getenv('F2B_RISK_SCORE', '0')),
+        'campaign_summary': os.getenv('F2B_CAMPAIGN', ''),
+        'history_summary': os.getenv('F2B_HISTORY', ''),
+        'escalation': os.getenv('F2B_ESCALATION', ''),
+        'title': os.getenv('F2B_TITLE', ''),
+        'domains': [d for d in os.getenv('F2B_DOMAINS', '').split(',') if d],
+        'surge_bans': int(os.getenv('F2B_SURGE_BANS', '0')),
+        'surge_window': int(os.getenv('F2B_SURGE_WINDOW', '3600')),
+        'surge_baseline': float(os.getenv('F2B_SURGE_BASELINE', '0')),
     }
     
     # Try to read JSON from stdin as well
@@ -50,12 +120,55 @@ def get_notification_data():
     
     return data
 
+def create_surge_content(data, config):
+    """Create email subject and body for an attack surge"""
+    minutes = data['surge_window'] // 60
+    summary = (f"{data['surge_bans']} bans in jail '{data['jail']}' within {minutes} minutes, "
+               f"baseline {data['surge_baseline']:.1f} per window")
+
+    subject = f"{config['subject_prefix']} 📈 Attack surge in {data['jail']}"
+    if data['title']:
+        subject = f"{config['subject_prefix']} {data['title']}"
+
+    html_body = f"""
+    <html>
+    <body style="font-family: Arial, sans-serif; margin: 20px;">
+        <div style="background-color: #fff3e0; padding: 15px; border-radius: 5px; margin-bottom: 20px;">
+            <h2>📈 Fail2Ban Attack Surge</h2>
+            <p>{escape(summary)}</p>
+        </div>
+        <p>Time: {escape(data['time'])}<br>Server: {escape(data['hostname'])}</p>
+        <p style="margin-top: 20px; font-size: 12px; color: #666;">
+            This is an automated security alert from Fail2Ban.
+        </p>
+    </body>
+    </html>
+    """
+
+    text_body = f"""
+Fail2Ban Attack Surge
+
+{summary}
+
+- Time: {data['time']}
+- Server: {data['hostname']}
+
+This is an automated security alert from Fail2Ban.
+"""
+
+    return subject, html_body, text_body
+
 def create_email_content(data, config):
     """Create email subject and body"""
+    if data['action'] == 'surge':
+        return create_surge_content(data, config)
+
     action = data['action'].capitalize()
     emoji = "🚫" if data['action'] == 'ban' else "✅"
     
     subject = f"{config['subject_prefix']} {emoji} {action}: {data['ip']} in {data['jail']}"
+    if data['title']:
+        subject = f"{config['subject_prefix']} {data['title']}"
     
     # Build location string
     location = ""
@@ -63,6 +176,8 @@ def create_email_content(data, config):
         location = f" from {data['country']}"
         if data['city']:
             location = f" from {data['city']}, {data['country']}"
+        if data['flag']:
+            location += f" {data['flag']}"
     
     # Create HTML body
     html_body = f"""
@@ -99,12 +214,36 @@ def create_email_content(data, config):
         location_str = data['city'] + ", " + data['country'] if data['city'] else data['country']
         html_body += f"<tr><td>Location</td><td>{location_str}</td></tr>"
     
+    if data.get('history_summary'):
+        html_body += f"<tr><td>History</td><td>{data['history_summary']}</td></tr>"
+
+    if data.get('escalation'):
+        html_body += f"<tr><td>Escalation</td><td>{data['escalation']}</td></tr>"
+
+    if data.get('risk_score'):
+        html_body += f"<tr><td>Risk</td><td>{data['risk_score']}/100</td></tr>"
+
+    if data.get('campaign_summary'):
+        html_body += f"<tr><td>Campaign</td><td>{data['campaign_summary']}</td></tr>"
+
+    if data.get('domains'):
+        html_body += f"<tr><td>Recent Domains</td><td>{', '.join(data['domains'])}</td></tr>"
+
+    if data.get('anonymity'):
+        html_body += f"<tr><td>Anonymity</td><td>{data['anonymity']}</td></tr>"
+
+    if data.get('honeypot_sessions'):
+        html_body += f"<tr><td>Honeypot</td><td>yes, {data['honeypot_sessions']} sessions</td></tr>"
+
     if data['isp']:
         html_body += f"<tr><td>ISP</td><td>{data['isp']}</td></tr>"
     
     if data['hostname']:
         html_body += f"<tr><td>Hostname</td><td>{data['hostname']}</td></tr>"
     
+    if data.get('artifact_url'):
+        html_body += f"<tr><td>Log Context</td><td><a href=\"{data['artifact_url']}\">{data['artifact_url']}</a></td></tr>"
+    
     html_body += """
         </table>
         
@@ -137,12 +276,36 @@ Details:
         location_str = data['city'] + ", " + data['country'] if data['city'] else data['country']
         text_body += f"- Location: {location_str}\n"
     
+    if data.get('history_summary'):
+        text_body += f"- History: {data['history_summary']}\n"
+
+    if data.get('escalation'):
+        text_body += f"- Escalation: {data['escalation']}\n"
+
+    if data.get('risk_score'):
+        text_body += f"- Risk: {data['risk_score']}/100\n"
+
+    if data.get('campaign_summary'):
+        text_body += f"- Campaign: {data['campaign_summary']}\n"
+
+    if data.get('domains'):
+        text_body += f"- Recent Domains: {', '.join(data['domains'])}\n"
+
+    if data.get('anonymity'):
+        text_body += f"- Anonymity: {data['anonymity']}\n"
+
+    if data.get('honeypot_sessions'):
+        text_body += f"- Honeypot: yes, {data['honeypot_sessions']} sessions\n"
+
     if data['isp']:
         text_body += f"- ISP: {data['isp']}\n"
     
     if data['hostname']:
         text_body += f"- Hostname: {data['hostname']}\n"
     
+    if data.get('artifact_url'):
+        text_body += f"- Log Context: {data['artifact_url']}\n"
+    
     text_body += f"""
 For more information about this IP, visit:
 https://whatismyipaddress.com/ip/{data['ip']}
@@ -152,18 +315,40 @@ This is an automated security alert from Fail2Ban.
     
     return subject, html_body, text_body
 
-def send_email(subject, html_body, text_body, config):
+def send_email(to_email, subject, html_body, text_body, config, artifact='', image=''):
     """Send the email notification"""
     try:
         # Create message
-        msg = MIMEMultipart('alternative')
-        msg['Subject'] = subject
-        msg['From'] = config['from_email']
-        msg['To'] = config['to_email']
+        body = MIMEMultipart('alternative')
         
         # Add both plain text and HTML versions
-        msg.attach(MIMEText(text_body, 'plain'))
-        msg.attach(MIMEText(html_body, 'html'))
+        body.attach(MIMEText(text_body, 'plain'))
+        body.attach(MIMEText(html_body, 'html'))
+        
+        # Embed the image the HTML body refers to as cid:heatmap
+        if image:
+            related = MIMEMultipart('related')
+            related.attach(body)
+            with open(image, 'rb') as f:
+                part = MIMEImage(f.read(), 'png')
+            part.add_header('Content-ID', '<heatmap>')
+            part.add_header('Content-Disposition', 'inline', filename=os.path.basename(image))
+            related.attach(part)
+            body = related
+        
+        # Attach the gzipped log context if one was bundled
+        msg = body
+        if artifact and os.path.isfile(artifact):
+            msg = MIMEMultipart('mixed')
+            msg.attach(body)
+            with open(artifact, 'rb') as f:
+                part = MIMEApplication(f.read(), 'gzip')
+            part.add_header('Content-Disposition', 'attachment', filename=os.path.basename(artifact))
+            msg.attach(part)
+        
+        msg['Subject'] = subject
+        msg['From'] = config['from_email']
+        msg['To'] = to_email
         
         # Connect to SMTP server
         server = smtplib.SMTP(config['smtp_server'], config['smtp_port'])
@@ -178,33 +363,255 @@ def send_email(subject, html_body, text_body, config):
         server.send_message(msg)
         server.quit()
         
-        print(f"Email notification sent successfully to {config['to_email']}")
+        print(f"Email notification sent successfully to {to_email}")
         return True
         
     except Exception as e:
-        print(f"Failed to send email: {e}", file=sys.stderr)
+        print(f"Failed to send email to {to_email}: {e}", file=sys.stderr)
+        return False
+
+def healthcheck(config):
+    """Check that the SMTP server accepts the connection and credentials"""
+    try:
+        server = smtplib.SMTP(config['smtp_server'], config['smtp_port'], timeout=10)
+        if config['smtp_tls']:
+            server.starttls()
+        if config['smtp_user'] and config['smtp_password']:
+            server.login(config['smtp_user'], config['smtp_password'])
+        server.quit()
+        return True
+    except Exception as e:
+        print(f"SMTP check failed: {e}", file=sys.stderr)
         return False
 
+def digest_paths(config, subscriber):
+    """Get the queue and state files of a digest subscriber"""
+    name = re.sub(r'[^A-Za-z0-9@._-]', '_', subscriber['to'])
+    base = os.path.join(config['digest_dir'], f"{name}-{subscriber['schedule']}")
+    return base + '.jsonl', base + '.json'
+
+def queue_event(config, subscriber, data):
+    """Add an event to the subscriber's next digest"""
+    queue, _ = digest_paths(config, subscriber)
+    event = {k: v for k, v in data.items() if k != 'artifact'}
+    with open(queue, 'a') as f:
+        f.write(json.dumps(event) + '\n')
+
+def load_delivered(config):
+    """Get the subscribers each recent event was mailed or queued for"""
+    try:
+        with open(os.path.join(config['digest_dir'], 'delivered.json')) as f:
+            delivered = json.load(f)
+    except (OSError, ValueError):
+        return {}
+    now = datetime.now().timestamp()
+    return {event_id: entry for event_id, entry in delivered.items()
+            if now - entry.get('time', 0) < DELIVERED_TTL}
+
+def save_delivered(config, delivered):
+    """Replace the record of delivered events"""
+    path = os.path.join(config['digest_dir'], 'delivered.json')
+    with open(path + '.tmp', 'w') as f:
+        json.dump(delivered, f)
+    os.replace(path + '.tmp', path)
+
+def subscriber_key(subscriber):
+    """Identify a subscriber in the record of delivered events"""
+    return f"{subscriber['to']}/{subscriber['schedule']}"
+
+def digest_heatmap(config, subscriber):
+    """Get the ban map image to embed in a weekly digest, if one was generated"""
+    if subscriber['schedule'] != 'weekly' or not config['heatmap']:
+        return ''
+    return config['heatmap'] if os.path.isfile(config['heatmap']) else ''
+
+def digest_unacknowledged(events):
+    """Get the number of critical bans nobody acknowledged.
+
+    The count of the running event is the most recent; digests flushed by
+    --flush use the one recorded with the newest queued event.
+    """
+    if os.getenv('F2B_UNACKED_CRITICAL'):
+        return int(os.getenv('F2B_UNACKED_CRITICAL'))
+    return int(events[-1].get('unacknowledged_critical', 0)) if events else 0
+
+def create_digest_content(subscriber, events, config, heatmap=''):
+    """Create the digest subject and bodies for one subscriber"""
+    bans = [e for e in events if e.get('action') == 'ban']
+    unacknowledged = digest_unacknowledged(events)
+    jails = sorted({e.get('jail', '') for e in events})
+    period = subscriber['schedule'].capitalize()
+
+    subject = (f"{config['subject_prefix']} {period} digest: {len(bans)} bans, "
+               f"{len(events) - len(bans)} unbans in {', '.join(jails)}")
+
+    rows = ""
+    lines = ""
+    for e in events:
+        location = ", ".join(x for x in (e.get('city', ''), e.get('country', '')) if x)
+        rows += (f"<tr><td>{escape(str(e.get('time', '')))}</td><td>{escape(e.get('action', ''))}</td>"
+                 f"<td>{escape(e.get('ip', ''))}</td><td>{escape(e.get('jail', ''))}</td>"
+                 f"<td>{escape(location)}</td><td>{e.get('failures', 0) or ''}</td></tr>")
+        lines += f"- {e.get('time', '')} {e.get('action', '')} {e.get('ip', '')} in {e.get('jail', '')}"
+        lines += f" ({location})\n" if location else "\n"
+
+    pending_html = ""
+    pending_text = ""
+    if unacknowledged:
+        pending_html = (f'<p style="color: #d32f2f;"><strong>{unacknowledged} critical bans</strong> '
+                        f'are waiting for a responder to acknowledge them.</p>')
+        pending_text = f"{unacknowledged} critical bans are waiting for a responder to acknowledge them.\n\n"
+
+    picture = ""
+    if heatmap:
+        picture = ('<h3>Where bans came from</h3>'
+                   '<img src="cid:heatmap" alt="Ban heatmap" style="width: 100%; max-width: 1024px;">')
+
+    html_body = f"""
+    <html>
+    <head>
+        <style>
+            body {{ font-family: Arial, sans-serif; margin: 20px; }}
+            .info-table {{ border-collapse: collapse; width: 100%; }}
+            .info-table td {{ border: 1px solid #ddd; padding: 8px; }}
+            .info-table th {{ border: 1px solid #ddd; padding: 8px; background-color: #f2f2f2; }}
+        </style>
+    </head>
+    <body>
+        <h2>Fail2Ban {period} Digest</h2>
+        <p>{len(bans)} bans and {len(events) - len(bans)} unbans in {escape(', '.join(jails))}.</p>
+        {pending_html}
+        {picture}
+        <table class="info-table">
+            <tr><th>Time</th><th>Action</th><th>IP Address</th><th>Jail</th><th>Location</th><th>Failures</th></tr>
+            {rows}
+        </table>
+        <p style="margin-top: 20px; font-size: 12px; color: #666;">
+            This is an automated security digest from Fail2Ban.
+        </p>
+    </body>
+    </html>
+    """
+
+    text_body = f"""
+Fail2Ban {period} Digest
+
+{len(bans)} bans and {len(events) - len(bans)} unbans in {', '.join(jails)}.
+
+{pending_text}{lines}
+This is an automated security digest from Fail2Ban.
+"""
+
+    return subject, html_body, text_body
+
+def flush_digests(config, subscribers, force=False):
+    """Send the digests whose period has passed. Returns False if any failed."""
+    ok = True
+    now = datetime.now().timestamp()
+    for subscriber in subscribers:
+        period = SCHEDULES[subscriber['schedule']]
+        if period == 0:
+            continue
+
+        queue, state_file = digest_paths(config, subscriber)
+        try:
+            with open(state_file) as f:
+                last_sent = json.load(f).get('last_sent', 0)
+        except (OSError, ValueError):
+            # Start the first period now rather than mailing immediately
+            last_sent = now
+            with open(state_file, 'w') as f:
+                json.dump({'last_sent': last_sent}, f)
+
+        if not force and now - last_sent < period:
+            continue
+
+        events = []
+        if os.path.exists(queue):
+            with open(queue) as f:
+                for line in f:
+                    try:
+                        events.append(json.loads(line))
+                    except json.JSONDecodeError:
+                        continue  # Skip a line torn by a crash
+
+        if events:
+            heatmap = digest_heatmap(config, subscriber)
+            subject, html_body, text_body = create_digest_content(subscriber, events, config, heatmap)
+            if not send_email(subscriber['to'], subject, html_body, text_body, config, image=heatmap):
+                ok = False
+                continue  # Keep the queue for the next attempt
+            os.remove(queue)
+
+        with open(state_file, 'w') as f:
+            json.dump({'last_sent': now}, f)
+    return ok
+
 def main():
     """Main function"""
     config = get_config()
-    
+
+    try:
+        subscribers = get_subscribers(config)
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        sys.exit(1)
+
     # Validate required configuration
-    if not config['to_email'] or config['to_email'] == 'admin@localhost':
+    if not config['subscribers'] and (not config['to_email'] or config['to_email'] == 'admin@localhost'):
         print("Error: EMAIL_TO not configured", file=sys.stderr)
         sys.exit(1)
-    
+
+    # --healthcheck verifies the SMTP settings without sending anything
+    if len(sys.argv) > 1 and sys.argv[1] == '--healthcheck':
+        sys.exit(0 if healthcheck(config) else 1)
+
+    lock = None
+    if config['subscribers']:
+        os.makedirs(config['digest_dir'], mode=0o700, exist_ok=True)
+        lock = open(os.path.join(config['digest_dir'], '.lock'), 'w')
+        fcntl.flock(lock, fcntl.LOCK_EX)
+
+    # --flush sends due digests without an event, e.g. from cron, so quiet
+    # periods still produce one; --flush-all sends them regardless of schedule
+    if len(sys.argv) > 1 and sys.argv[1] in ('--flush', '--flush-all'):
+        sys.exit(0 if flush_digests(config, subscribers, sys.argv[1] == '--flush-all') else 1)
+
     # Get notification data
     data = get_notification_data()
-    
-    # Create email content
-    subject, html_body, text_body = create_email_content(data, config)
-    
-    # Send email
-    if send_email(subject, html_body, text_body, config):
-        sys.exit(0)
-    else:
+
+    # fail2ban-notify retries the connector when an instant mail failed. The
+    # subscribers the event already reached are skipped on the retry.
+    event_id = data.get('event_id', '')
+    delivered = load_delivered(config) if lock and event_id else {}
+    done = set(delivered.get(event_id, {}).get('to', []))
+
+    failed = []
+    for subscriber in subscribers:
+        if not wants(subscriber, data) or subscriber_key(subscriber) in done:
+            continue
+        if subscriber['schedule'] == 'instant':
+            subject, html_body, text_body = create_email_content(data, config)
+            if not send_email(subscriber['to'], subject, html_body, text_body, config, data.get('artifact', '')):
+                failed.append(subscriber['to'])
+                continue
+        else:
+            queue_event(config, subscriber, data)
+        done.add(subscriber_key(subscriber))
+
+    if lock and event_id:
+        delivered[event_id] = {'time': datetime.now().timestamp(), 'to': sorted(done)}
+        save_delivered(config, delivered)
+
+    # A failed digest stays queued for the next event or --flush, so it does
+    # not fail the event
+    if lock:
+        flush_digests(config, subscribers)
+
+    if failed:
+        print(f"Failed to email {', '.join(failed)}", file=sys.stderr)
         sys.exit(1)
+    sys.exit(0)
 
 if __name__ == '__main__':
     main()
\ No newline at end of file
@@ -16,6 +16,18 @@ if [[ -z "$WEBHOOK_URL" ]]; then
     exit 1
 fi
 
+# Health probe: an empty payload is rejected with 400 by a valid webhook
+# and with 403, 404 or 410 by a revoked one, without posting anything
+if [[ "${1:-}" == "--healthcheck" ]]; then
+    STATUS=$(curl -sS -o /dev/null -w '%{http_code}' --max-time 10 \
+        -X POST -H "Content-Type: application/json" -d '{}' "$WEBHOOK_URL")
+    if [[ "$STATUS" != 2* && "$STATUS" != "400" ]]; then
+        echo "Error: webhook answered HTTP $STATUS" >&2
+        exit 1
+    fi
+    exit 0
+fi
+
 # Get data from environment variables
 IP="${F2B_IP:-unknown}"
 JAIL="${F2B_JAIL:-unknown}"
@@ -23,16 +35,37 @@ ACTION="${F2B_ACTION:-ban}"
 TIME="${F2B_TIME:-$(date -Iseconds)}"
 TIMESTAMP="${F2B_TIMESTAMP:-$(date +%s)}"
 COUNTRY="${F2B_COUNTRY:-}"
+FLAG="${F2B_FLAG:-}"
 REGION="${F2B_REGION:-}"
 CITY="${F2B_CITY:-}"
 ISP="${F2B_ISP:-}"
 HOSTNAME="${F2B_HOSTNAME:-}"
 FAILURES="${F2B_FAILURES:-0}"
+SUPPRESSED="${F2B_SUPPRESSED:-0}"
+HONEYPOT="${F2B_HONEYPOT_SESSIONS:-0}"
+ANONYMITY="${F2B_ANONYMITY:-}"
+DOMAINS="${F2B_DOMAINS:-}"
+RISK="${F2B_RISK_SCORE:-0}"
+HISTORY="${F2B_HISTORY:-}"
+ESCALATION="${F2B_ESCALATION:-}"
+CAMPAIGN="${F2B_CAMPAIGN:-}"
+SURGE_BANS="${F2B_SURGE_BANS:-0}"
+SURGE_WINDOW="${F2B_SURGE_WINDOW:-3600}"
+SURGE_BASELINE="${F2B_SURGE_BASELINE:-0}"
+BULK_UNBANS="${F2B_BULK_UNBANS:-0}"
+BULK_WINDOW="${F2B_BULK_WINDOW:-0}"
+ARTIFACT_URL="${F2B_ARTIFACT_URL:-}"
+EVENT_ID="${F2B_EVENT_ID:-}"
+ACK_URL="${F2B_ACK_URL:-}"
+UNACKED="${F2B_UNACKED_CRITICAL:-0}"
 
 # Determine color and emoji based on action
-if [[ "$ACTION" == "unban" ]]; then
+if [[ "$ACTION" == "unban" || "$ACTION" == "bulk_unban" ]]; then
     COLOR="good"  # Green
     EMOJI="✅"
+elif [[ "$ACTION" == "surge" ]]; then
+    COLOR="warning"  # Orange
+    EMOJI="📈"
 else
     COLOR="danger"  # Red
     EMOJI="🚫"
@@ -48,16 +81,93 @@ if [[ -n "$COUNTRY" ]]; then
 fi
 
 # Create fields array
+TEXT="IP *$IP*$LOCATION has been ${ACTION}ned in jail '$JAIL'"
+ACTIONS='[{
+            "type": "button",
+            "text": "Check IP",
+            "url": "https://whatismyipaddress.com/ip/'"$IP"'"
+        }]'
 FIELDS='[
     {"title": "IP Address", "value": "'"$IP"'", "short": true},
     {"title": "Jail", "value": "'"$JAIL"'", "short": true},
     {"title": "Action", "value": "'"${ACTION^}"'", "short": true},
     {"title": "Time", "value": "'"$TIME"'", "short": true}'
 
+# A surge is about the jail, not a single IP
+if [[ "$ACTION" == "surge" ]]; then
+    TEXT="*$SURGE_BANS bans* in jail '$JAIL' within $((SURGE_WINDOW / 60)) minutes, baseline $SURGE_BASELINE per window"
+    ACTIONS='[]'
+    FIELDS='[
+    {"title": "Jail", "value": "'"$JAIL"'", "short": true},
+    {"title": "Action", "value": "Surge", "short": true},
+    {"title": "Time", "value": "'"$TIME"'", "short": true}'
+fi
+
+# So is a bulk unban, when the jail was flushed
+if [[ "$ACTION" == "bulk_unban" ]]; then
+    TEXT="*$BULK_UNBANS IPs* unbanned from jail '$JAIL' within $BULK_WINDOW seconds"
+    ACTIONS='[]'
+    FIELDS='[
+    {"title": "Jail", "value": "'"$JAIL"'", "short": true},
+    {"title": "Action", "value": "Bulk unban", "short": true},
+    {"title": "Time", "value": "'"$TIME"'", "short": true}'
+fi
+
+# The Acknowledge button reports back to the daemon when the Slack app sends
+# its interactions to /chatops/slack/actions, and opens the signed ack page
+# otherwise
+if [[ -n "$EVENT_ID" && "$ACTION" == "ban" ]]; then
+    ACK_BUTTON='{"name": "ack", "type": "button", "text": "Acknowledge", "style": "primary", "value": "'"$EVENT_ID"'"'
+    if [[ -n "$ACK_URL" ]]; then
+        ACK_BUTTON+=', "url": "'"$ACK_URL"'"'
+    fi
+    ACTIONS="${ACTIONS%]}, $ACK_BUTTON}]"
+fi
+
 if [[ "$FAILURES" -gt 0 ]]; then
     FIELDS+=',{"title": "Failures", "value": "'"$FAILURES"'", "short": true}'
 fi
 
+if [[ -n "$ARTIFACT_URL" ]]; then
+    FIELDS+=',{"title": "Log Context", "value": "<'"$ARTIFACT_URL"'|Download requests>", "short": false}'
+fi
+
+if [[ -n "$HISTORY" ]]; then
+    FIELDS+=',{"title": "History", "value": "'"$HISTORY"'", "short": false}'
+fi
+
+if [[ -n "$ESCALATION" ]]; then
+    FIELDS+=',{"title": "Escalation", "value": "'"$ESCALATION"'", "short": false}'
+fi
+
+if [[ "$UNACKED" -gt 0 ]]; then
+    FIELDS+=',{"title": "Unacknowledged", "value": "'"$UNACKED critical bans waiting for a responder"'", "short": false}'
+fi
+
+if [[ "$RISK" -gt 0 ]]; then
+    FIELDS+=',{"title": "Risk", "value": "'"$RISK/100"'", "short": true}'
+fi
+
+if [[ -n "$CAMPAIGN" ]]; then
+    FIELDS+=',{"title": "Campaign", "value": "'"$CAMPAIGN"'", "short": false}'
+fi
+
+if [[ -n "$DOMAINS" ]]; then
+    FIELDS+=',{"title": "Recent Domains", "value": "'"${DOMAINS//,/, }"'", "short": false}'
+fi
+
+if [[ -n "$ANONYMITY" ]]; then
+    FIELDS+=',{"title": "Anonymity", "value": "'"$ANONYMITY"'", "short": true}'
+fi
+
+if [[ "$HONEYPOT" -gt 0 ]]; then
+    FIELDS+=',{"title": "Honeypot", "value": "'"yes, $HONEYPOT sessions"'", "short": true}'
+fi
+
+if [[ "$SUPPRESSED" -gt 0 ]]; then
+    FIELDS+=',{"title": "Throttled", "value": "'"$SUPPRESSED further notifications suppressed"'", "short": false}'
+fi
+
 if [[ -n "$ISP" ]]; then
     FIELDS+=',{"title": "ISP", "value": "'"$ISP"'", "short": true}'
 fi
@@ -67,7 +177,7 @@ if [[ -n "$HOSTNAME" ]]; then
 fi
 
 if [[ -n "$COUNTRY" ]]; then
-    FIELDS+=',{"title": "Location", "value": "'"${CITY:+$CITY, }$COUNTRY"'", "short": true}'
+    FIELDS+=',{"title": "Location", "value": "'"${FLAG:+$FLAG }${CITY:+$CITY, }$COUNTRY"'", "short": true}'
 fi
 
 FIELDS+=']'
@@ -80,18 +190,15 @@ PAYLOAD=$(cat <<EOF
     "icon_emoji": "$ICON_EMOJI",
     "attachments": [{
         "color": "$COLOR",
-        "title": "$EMOJI Fail2Ban ${ACTION^} Alert",
-        "text": "IP *$IP*$LOCATION has been ${ACTION}ned in jail '$JAIL'",
+        "title": "${F2B_TITLE:-$EMOJI Fail2Ban ${ACTION^} Alert}",
+        "text": "$TEXT",
         "fields": $FIELDS,
         "ts": $TIMESTAMP,
         "footer": "Fail2Ban Notifier",
         "footer_icon": "https://cdn-icons-png.flaticon.com/512/1828/1828506.png",
         "mrkdwn_in": ["text"],
-        "actions": [{
-            "type": "button",
-            "text": "Check IP",
-            "url": "https://whatismyipaddress.com/ip/$IP"
-        }]
+        "callback_id": "f2b_ack",
+        "actions": $ACTIONS
     }]
 }
 EOF
@@ -13,22 +13,50 @@ if [[ -z "$WEBHOOK_URL" ]]; then
     exit 1
 fi
 
+# Health probe: a GET doesn't post anything; only answers suggesting the
+# webhook was removed or the service is down count as failures
+if [[ "${1:-}" == "--healthcheck" ]]; then
+    STATUS=$(curl -sS -o /dev/null -w '%{http_code}' --max-time 10 "$WEBHOOK_URL")
+    if [[ "$STATUS" == "401" || "$STATUS" == "403" || "$STATUS" == "404" || "$STATUS" == "410" || "$STATUS" == 5* ]]; then
+        echo "Error: webhook answered HTTP $STATUS" >&2
+        exit 1
+    fi
+    exit 0
+fi
+
 # Get data from environment variables
 IP="${F2B_IP:-unknown}"
 JAIL="${F2B_JAIL:-unknown}"
 ACTION="${F2B_ACTION:-ban}"
 TIME="${F2B_TIME:-$(date -Iseconds)}"
 COUNTRY="${F2B_COUNTRY:-}"
+FLAG="${F2B_FLAG:-}"
 REGION="${F2B_REGION:-}"
 CITY="${F2B_CITY:-}"
 ISP="${F2B_ISP:-}"
 HOSTNAME="${F2B_HOSTNAME:-}"
 FAILURES="${F2B_FAILURES:-0}"
+SUPPRESSED="${F2B_SUPPRESSED:-0}"
+HONEYPOT="${F2B_HONEYPOT_SESSIONS:-0}"
+ANONYMITY="${F2B_ANONYMITY:-}"
+DOMAINS="${F2B_DOMAINS:-}"
+RISK="${F2B_RISK_SCORE:-0}"
+HISTORY="${F2B_HISTORY:-}"
+ESCALATION="${F2B_ESCALATION:-}"
+CAMPAIGN="${F2B_CAMPAIGN:-}"
+SURGE_BANS="${F2B_SURGE_BANS:-0}"
+SURGE_WINDOW="${F2B_SURGE_WINDOW:-3600}"
+SURGE_BASELINE="${F2B_SURGE_BASELINE:-0}"
+BULK_UNBANS="${F2B_BULK_UNBANS:-0}"
+BULK_WINDOW="${F2B_BULK_WINDOW:-0}"
 
 # Determine color and emoji based on action
-if [[ "$ACTION" == "unban" ]]; then
+if [[ "$ACTION" == "unban" || "$ACTION" == "bulk_unban" ]]; then
     THEME_COLOR="44FF44"  # Green
     EMOJI="✅"
+elif [[ "$ACTION" == "surge" ]]; then
+    THEME_COLOR="FFA500"  # Orange
+    EMOJI="📈"
 else
     THEME_COLOR="FF4444"  # Red
     EMOJI="🚫"
@@ -44,16 +72,80 @@ if [[ -n "$COUNTRY" ]]; then
 fi
 
 # Create facts array
+SUMMARY="Fail2Ban ${ACTION^}: $IP"
+SUBTITLE="IP $IP$LOCATION has been ${ACTION}ned in jail '$JAIL'"
+POTENTIAL_ACTIONS='[{
+        "@type": "OpenUri",
+        "name": "Check IP Details",
+        "targets": [{
+            "os": "default",
+            "uri": "https://whatismyipaddress.com/ip/'"$IP"'"
+        }]
+    }]'
 FACTS='[
     {"name": "IP Address", "value": "'"$IP"'"},
     {"name": "Jail", "value": "'"$JAIL"'"},
     {"name": "Action", "value": "'"${ACTION^}"'"},
     {"name": "Time", "value": "'"$TIME"'"}'
 
+# A surge is about the jail, not a single IP
+if [[ "$ACTION" == "surge" ]]; then
+    SUMMARY="Fail2Ban Surge: $JAIL"
+    SUBTITLE="$SURGE_BANS bans in jail '$JAIL' within $((SURGE_WINDOW / 60)) minutes, baseline $SURGE_BASELINE per window"
+    POTENTIAL_ACTIONS='[]'
+    FACTS='[
+    {"name": "Jail", "value": "'"$JAIL"'"},
+    {"name": "Action", "value": "Surge"},
+    {"name": "Time", "value": "'"$TIME"'"}'
+fi
+
+# So is a bulk unban, when the jail was flushed
+if [[ "$ACTION" == "bulk_unban" ]]; then
+    SUMMARY="Fail2Ban Bulk Unban: $JAIL"
+    SUBTITLE="$BULK_UNBANS IPs unbanned from jail '$JAIL' within $BULK_WINDOW seconds"
+    POTENTIAL_ACTIONS='[]'
+    FACTS='[
+    {"name": "Jail", "value": "'"$JAIL"'"},
+    {"name": "Action", "value": "Bulk unban"},
+    {"name": "Time", "value": "'"$TIME"'"}'
+fi
+
 if [[ "$FAILURES" -gt 0 ]]; then
     FACTS+=',{"name": "Failures", "value": "'"$FAILURES"'"}'
 fi
 
+if [[ -n "$HISTORY" ]]; then
+    FACTS+=',{"name": "History", "value": "'"$HISTORY"'"}'
+fi
+
+if [[ -n "$ESCALATION" ]]; then
+    FACTS+=',{"name": "Escalation", "value": "'"$ESCALATION"'"}'
+fi
+
+if [[ "$RISK" -gt 0 ]]; then
+    FACTS+=',{"name": "Risk", "value": "'"$RISK/100"'"}'
+fi
+
+if [[ -n "$CAMPAIGN" ]]; then
+    FACTS+=',{"name": "Campaign", "value": "'"$CAMPAIGN"'"}'
+fi
+
+if [[ -n "$DOMAINS" ]]; then
+    FACTS+=',{"name": "Recent Domains", "value": "'"${DOMAINS//,/, }"'"}'
+fi
+
+if [[ -n "$ANONYMITY" ]]; then
+    FACTS+=',{"name": "Anonymity", "value": "'"$ANONYMITY"'"}'
+fi
+
+if [[ "$HONEYPOT" -gt 0 ]]; then
+    FACTS+=',{"name": "Honeypot", "value": "'"yes, $HONEYPOT sessions"'"}'
+fi
+
+if [[ "$SUPPRESSED" -gt 0 ]]; then
+    FACTS+=',{"name": "Throttled", "value": "'"$SUPPRESSED further notifications suppressed"'"}'
+fi
+
 if [[ -n "$ISP" ]]; then
     FACTS+=',{"name": "ISP", "value": "'"$ISP"'"}'
 fi
@@ -63,7 +155,7 @@ if [[ -n "$HOSTNAME" ]]; then
 fi
 
 if [[ -n "$COUNTRY" ]]; then
-    FACTS+=',{"name": "Location", "value": "'"${CITY:+$CITY, }$COUNTRY"'"}'
+    FACTS+=',{"name": "Location", "value": "'"${FLAG:+$FLAG }${CITY:+$CITY, }$COUNTRY"'"}'
 fi
 
 FACTS+=']'
@@ -74,22 +166,15 @@ PAYLOAD=$(cat <<EOF
     "@type": "MessageCard",
     "@context": "http://schema.org/extensions",
     "themeColor": "$THEME_COLOR",
-    "summary": "Fail2Ban ${ACTION^}: $IP",
+    "summary": "$SUMMARY",
     "sections": [{
         "activityTitle": "$EMOJI Fail2Ban ${ACTION^} Alert",
-        "activitySubtitle": "IP $IP$LOCATION has been ${ACTION}ned in jail '$JAIL'",
+        "activitySubtitle": "$SUBTITLE",
         "activityImage": "https://cdn-icons-png.flaticon.com/512/1828/1828506.png",
         "facts": $FACTS,
         "markdown": true
     }],
-    "potentialAction": [{
-        "@type": "OpenUri",
-        "name": "Check IP Details",
-        "targets": [{
-            "os": "default",
-            "uri": "https://whatismyipaddress.com/ip/$IP"
-        }]
-    }]
+    "potentialAction": $POTENTIAL_ACTIONS
 }
 EOF
 )
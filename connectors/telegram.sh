@@ -19,22 +19,45 @@ if [[ -z "$CHAT_ID" ]]; then
     exit 1
 fi
 
+# Health probe: checks the bot token and that the bot can see the chat
+if [[ "${1:-}" == "--healthcheck" ]]; then
+    curl -fsS -o /dev/null --max-time 10 "https://api.telegram.org/bot$BOT_TOKEN/getChat?chat_id=$CHAT_ID"
+    exit 0
+fi
+
 # Get data from environment variables
 IP="${F2B_IP:-unknown}"
 JAIL="${F2B_JAIL:-unknown}"
 ACTION="${F2B_ACTION:-ban}"
 TIME="${F2B_TIME:-$(date -Iseconds)}"
 COUNTRY="${F2B_COUNTRY:-}"
+FLAG="${F2B_FLAG:-}"
 REGION="${F2B_REGION:-}"
 CITY="${F2B_CITY:-}"
 ISP="${F2B_ISP:-}"
 HOSTNAME="${F2B_HOSTNAME:-}"
 FAILURES="${F2B_FAILURES:-0}"
+SUPPRESSED="${F2B_SUPPRESSED:-0}"
+HONEYPOT="${F2B_HONEYPOT_SESSIONS:-0}"
+ANONYMITY="${F2B_ANONYMITY:-}"
+DOMAINS="${F2B_DOMAINS:-}"
+RISK="${F2B_RISK_SCORE:-0}"
+HISTORY="${F2B_HISTORY:-}"
+ESCALATION="${F2B_ESCALATION:-}"
+CAMPAIGN="${F2B_CAMPAIGN:-}"
+SURGE_BANS="${F2B_SURGE_BANS:-0}"
+SURGE_WINDOW="${F2B_SURGE_WINDOW:-3600}"
+SURGE_BASELINE="${F2B_SURGE_BASELINE:-0}"
+BULK_UNBANS="${F2B_BULK_UNBANS:-0}"
+BULK_WINDOW="${F2B_BULK_WINDOW:-0}"
 
 # Determine emoji based on action
-if [[ "$ACTION" == "unban" ]]; then
+if [[ "$ACTION" == "unban" || "$ACTION" == "bulk_unban" ]]; then
     EMOJI="✅"
     ACTION_EMOJI="🔓"
+elif [[ "$ACTION" == "surge" ]]; then
+    EMOJI="📈"
+    ACTION_EMOJI="🔒"
 else
     EMOJI="🚫"
     ACTION_EMOJI="🔒"
@@ -47,6 +70,9 @@ if [[ -n "$COUNTRY" ]]; then
     if [[ -n "$CITY" ]]; then
         LOCATION=" from $CITY, $COUNTRY"
     fi
+    if [[ -n "$FLAG" ]]; then
+        LOCATION="$LOCATION $FLAG"
+    fi
 fi
 
 # Escape special characters for Markdown
@@ -66,6 +92,25 @@ $ACTION_EMOJI *Jail:* $JAIL_ESCAPED
 ⚡ *Action:* ${ACTION^}
 🕐 *Time:* $(date -d "$TIME" '+%Y-%m-%d %H:%M:%S %Z' 2>/dev/null || echo "$TIME")"
 
+# A surge is about the jail, not a single IP
+if [[ "$ACTION" == "surge" ]]; then
+    MESSAGE="$EMOJI *Fail2Ban Attack Surge*
+
+$ACTION_EMOJI *Jail:* $JAIL_ESCAPED
+📊 *Bans:* $SURGE_BANS within $((SURGE_WINDOW / 60)) minutes
+📉 *Baseline:* $SURGE_BASELINE per window
+🕐 *Time:* $(date -d "$TIME" '+%Y-%m-%d %H:%M:%S %Z' 2>/dev/null || echo "$TIME")"
+fi
+
+# So is a bulk unban, when the jail was flushed
+if [[ "$ACTION" == "bulk_unban" ]]; then
+    MESSAGE="$EMOJI *Fail2Ban Bulk Unban*
+
+🔓 *Jail:* $JAIL_ESCAPED
+📊 *Unbans:* $BULK_UNBANS IPs within $BULK_WINDOW seconds
+🕐 *Time:* $(date -d "$TIME" '+%Y-%m-%d %H:%M:%S %Z' 2>/dev/null || echo "$TIME")"
+fi
+
 if [[ "$FAILURES" -gt 0 ]]; then
     MESSAGE="$MESSAGE
 ❌ *Failures:* $FAILURES"
@@ -77,6 +122,49 @@ if [[ -n "$ISP" ]]; then
 🏢 *ISP:* $ISP_ESCAPED"
 fi
 
+if [[ -n "$HISTORY" ]]; then
+    MESSAGE="$MESSAGE
+📜 *History:* $HISTORY"
+fi
+
+if [[ -n "$ESCALATION" ]]; then
+    MESSAGE="$MESSAGE
+🚨 *Escalation:* $ESCALATION"
+fi
+
+if [[ "$RISK" -gt 0 ]]; then
+    MESSAGE="$MESSAGE
+⚠️ *Risk:* $RISK/100"
+fi
+
+if [[ -n "$CAMPAIGN" ]]; then
+    CAMPAIGN_ESCAPED=$(escape_markdown "$CAMPAIGN")
+    MESSAGE="$MESSAGE
+🧩 *Campaign:* $CAMPAIGN_ESCAPED"
+fi
+
+if [[ -n "$DOMAINS" ]]; then
+    DOMAINS_ESCAPED=$(escape_markdown "${DOMAINS//,/, }")
+    MESSAGE="$MESSAGE
+🌐 *Recent domains:* $DOMAINS_ESCAPED"
+fi
+
+if [[ -n "$ANONYMITY" ]]; then
+    ANONYMITY_ESCAPED=$(escape_markdown "$ANONYMITY")
+    MESSAGE="$MESSAGE
+🕵️ *Anonymity:* $ANONYMITY_ESCAPED"
+fi
+
+if [[ "$HONEYPOT" -gt 0 ]]; then
+    MESSAGE="$MESSAGE
+🍯 *Honeypot:* yes, $HONEYPOT sessions"
+fi
+
+if [[ "$SUPPRESSED" -gt 0 ]]; then
+    MESSAGE="$MESSAGE
+🔇 *Throttled:* $SUPPRESSED further notifications suppressed"
+fi
+
 if [[ -n "$HOSTNAME" ]]; then
     HOSTNAME_ESCAPED=$(escape_markdown "$HOSTNAME")
     MESSAGE="$MESSAGE
@@ -0,0 +1,79 @@
+// Package anonymity looks up whether an IP belongs to a VPN, proxy, Tor or
+// bot network through a commercial detection service
+package anonymity
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// cacheFile keeps lookups between invocations, since every lookup is billed
+const cacheFile = "anonymity.json"
+
+// Service is a detection service
+type Service interface {
+	Lookup(ip string) (*types.Anonymity, error)
+}
+
+// Detector looks up IPs with the configured service and caches the results
+type Detector struct {
+	service Service
+	cache   *state.IPCache // Encrypted like the store
+}
+
+// NewDetector creates a detector for the configured service. The cache is
+// keyed by IP, so it is encrypted with the store's encryption.
+func NewDetector(cfg config.AnonymityConfig, stateDir string, storeCfg config.StoreConfig) (*Detector, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var service Service
+	switch cfg.Service {
+	case config.AnonymitySpur:
+		service = &spur{token: cfg.APIKey, client: client}
+	case config.AnonymityIPQS:
+		strictness := cfg.Strictness
+		if strictness == 0 {
+			strictness = 1
+		}
+		service = &ipqs{key: cfg.APIKey, strictness: strictness, client: client}
+	default:
+		return nil, fmt.Errorf("unknown anonymity service: %s", cfg.Service)
+	}
+
+	ttl := time.Duration(cfg.TTL) * time.Second
+	return &Detector{
+		service: service,
+		cache:   state.NewIPCache(store.New(storeCfg), filepath.Join(stateDir, cacheFile), ttl),
+	}, nil
+}
+
+// Lookup returns the anonymity flags of ip, or nil for private addresses
+func (d *Detector) Lookup(ip string, now time.Time) (*types.Anonymity, error) {
+	public, err := state.PublicIP(ip)
+	if err != nil || !public {
+		return nil, err
+	}
+
+	var cached *types.Anonymity
+	if d.cache.Get(ip, now, &cached) {
+		return cached, nil
+	}
+
+	result, err := d.service.Lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.cache.Put(ip, now, result); err != nil {
+		return result, fmt.Errorf("failed to cache anonymity result: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package anonymity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// ipqsURL is the IPQualityScore proxy and VPN detection endpoint
+const ipqsURL = "https://ipqualityscore.com/api/json/ip/"
+
+// ipqs queries the IPQualityScore proxy and VPN detection API
+type ipqs struct {
+	key        string
+	strictness int
+	client     *http.Client
+}
+
+type ipqsResponse struct {
+	Success        bool   `json:"success"`
+	Message        string `json:"message"`
+	FraudScore     int    `json:"fraud_score"`
+	Proxy          bool   `json:"proxy"`
+	VPN            bool   `json:"vpn"`
+	Tor            bool   `json:"tor"`
+	BotStatus      bool   `json:"bot_status"`
+	ConnectionType string `json:"connection_type"`
+}
+
+// Lookup maps the detection result to anonymity flags. Proxies on
+// residential connections are reported as residential proxies.
+func (q *ipqs) Lookup(ip string) (*types.Anonymity, error) {
+	endpoint := fmt.Sprintf("%s%s/%s?strictness=%d", ipqsURL, url.PathEscape(q.key), url.PathEscape(ip), q.strictness)
+
+	resp, err := q.client.Get(endpoint)
+	if err != nil {
+		// Don't leak the key embedded in the URL into logs
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("ipqs request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ipqs returned status %d", resp.StatusCode)
+	}
+
+	var result ipqsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode ipqs response: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("ipqs lookup failed: %s", result.Message)
+	}
+
+	return &types.Anonymity{
+		VPN:              result.VPN,
+		Proxy:            result.Proxy,
+		ResidentialProxy: result.Proxy && !result.VPN && result.ConnectionType == "Residential",
+		Tor:              result.Tor,
+		Bot:              result.BotStatus,
+		FraudScore:       result.FraudScore,
+		Source:           "ipqs",
+	}, nil
+}
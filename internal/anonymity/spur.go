@@ -0,0 +1,100 @@
+package anonymity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// spurURL is the Spur Context API endpoint
+const spurURL = "https://api.spur.us/v2/context/"
+
+// spur queries the Spur Context API
+type spur struct {
+	token  string
+	client *http.Client
+}
+
+type spurContext struct {
+	Infrastructure string `json:"infrastructure"`
+	Client         struct {
+		Proxies []string `json:"proxies"`
+	} `json:"client"`
+	Risks   []string `json:"risks"`
+	Tunnels []struct {
+		Type     string `json:"type"`
+		Operator string `json:"operator"`
+	} `json:"tunnels"`
+}
+
+// Lookup maps the IP context to anonymity flags: tunnels give VPN, Tor and
+// proxy use, the client's proxy services and callback-proxy risk mark
+// residential proxies, and automation risks mark bots
+func (s *spur) Lookup(ip string) (*types.Anonymity, error) {
+	req, err := http.NewRequest(http.MethodGet, spurURL+url.PathEscape(ip), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Token", s.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("spur request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	result := &types.Anonymity{Source: "spur"}
+	if resp.StatusCode == http.StatusNotFound {
+		return result, nil // Nothing known about the IP
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("spur returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var ctx spurContext
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&ctx); err != nil {
+		return nil, fmt.Errorf("failed to decode spur response: %w", err)
+	}
+
+	for _, tunnel := range ctx.Tunnels {
+		switch tunnel.Type {
+		case "VPN":
+			result.VPN = true
+		case "TOR":
+			result.Tor = true
+		case "PROXY":
+			result.Proxy = true
+		}
+		if result.Service == "" {
+			result.Service = tunnel.Operator
+		}
+	}
+
+	for _, risk := range ctx.Risks {
+		switch risk {
+		case "CALLBACK_PROXY":
+			result.Proxy = true
+			result.ResidentialProxy = true
+		case "BOTNET", "WEB_SCRAPING":
+			result.Bot = true
+		}
+	}
+
+	if len(ctx.Client.Proxies) > 0 {
+		result.Proxy = true
+		result.ResidentialProxy = result.ResidentialProxy || ctx.Infrastructure != "DATACENTER"
+		if result.Service == "" {
+			result.Service = ctx.Client.Proxies[0]
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,216 @@
+package artifact
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// File permissions
+const (
+	DirPermission  = 0750
+	FilePermission = 0640
+)
+
+// tailBytes is how much of the end of each log file is searched
+const tailBytes = 4 * 1024 * 1024
+
+// Bundler collects log lines mentioning a banned IP into gzipped artifacts
+type Bundler struct {
+	cfg       config.ArtifactConfig
+	redaction config.RedactionConfig
+}
+
+// NewBundler creates a bundler for the given configuration. The redaction
+// rules are applied to every bundled line.
+func NewBundler(cfg config.ArtifactConfig, redaction config.RedactionConfig) *Bundler {
+	return &Bundler{cfg: cfg, redaction: redaction}
+}
+
+// Bundle writes the log lines mentioning data.IP from the jail's log files to
+// a gzipped artifact and returns its path. It returns an empty path if the
+// jail has no logs configured or no lines matched.
+func (b *Bundler) Bundle(data *types.NotificationData) (string, error) {
+	logs := b.cfg.Logs[data.Jail]
+	if len(logs) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	matched := 0
+	for _, logPath := range logs {
+		lines, err := matchingLines(logPath, data.IP, b.cfg.MaxLines)
+		if err != nil {
+			return "", err
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "==> %s <==\n", logPath)
+		for _, line := range lines {
+			buf.WriteString(b.redaction.Redact(sanitize.Text(line)))
+			buf.WriteByte('\n')
+		}
+		matched += len(lines)
+	}
+
+	if matched == 0 {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(b.cfg.Dir, DirPermission); err != nil {
+		return "", fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.log.gz", data.Time.UTC().Format("20060102T150405Z"),
+		filepath.Base(data.Jail), strings.ReplaceAll(data.IP, ":", "_"))
+	path := filepath.Join(b.cfg.Dir, name)
+
+	if err := writeGzip(path, buf.Bytes()); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// URL returns the link to an artifact served by the daemon, if configured
+func (b *Bundler) URL(path string) string {
+	if b.cfg.BaseURL == "" || path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(b.cfg.BaseURL, "/") + "/" + filepath.Base(path)
+}
+
+// Prune removes artifacts older than the retention period
+func (b *Bundler) Prune(now time.Time) (int, error) {
+	if b.cfg.Retention <= 0 {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(b.cfg.Dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read artifact directory: %w", err)
+	}
+
+	retention := time.Duration(b.cfg.Retention) * time.Second
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || now.Sub(info.ModTime()) <= retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.cfg.Dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// matchingLines returns up to maxLines of the most recent lines in the tail
+// of logPath that contain ip as a whole token
+func matchingLines(logPath, ip string, maxLines int) ([]string, error) {
+	file, err := os.Open(filepath.Clean(logPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log %s: %w", logPath, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat log %s: %w", logPath, err)
+	}
+
+	offset := info.Size() - tailBytes
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek log %s: %w", logPath, err)
+		}
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	first := offset > 0
+	for scanner.Scan() {
+		if first {
+			// Skip the partial line at the seek offset
+			first = false
+			continue
+		}
+
+		line := scanner.Text()
+		if !containsIP(line, ip) {
+			continue
+		}
+
+		lines = append(lines, line)
+		if len(lines) > maxLines {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log %s: %w", logPath, err)
+	}
+
+	return lines, nil
+}
+
+// containsIP reports whether ip occurs in line not directly surrounded by
+// other address characters, so 1.2.3.4 doesn't match 11.2.3.45
+func containsIP(line, ip string) bool {
+	for start := 0; ; {
+		i := strings.Index(line[start:], ip)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(ip)
+		if (i == 0 || !isAddrChar(line[i-1])) && (end == len(line) || !isAddrChar(line[end])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isAddrChar(c byte) bool {
+	return c == '.' || c == ':' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// writeGzip writes data gzip-compressed to path
+func writeGzip(path string, data []byte) error {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+	if _, err := gz.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// TokenPrefix marks issued API tokens so they are easy to recognize in secret scanners
+const TokenPrefix = "f2bn_"
+
+// tokenBytes is the amount of randomness in an issued token
+const tokenBytes = 24
+
+// Issue generates a new random token with the given name and role. The
+// plaintext token is returned once; only its hash is kept in the config.
+func Issue(name, role string, rateLimit int) (string, config.APIToken, error) {
+	if !config.ValidRole(role) {
+		return "", config.APIToken{}, fmt.Errorf("invalid role '%s', must be '%s' or '%s'", role, config.RoleRead, config.RoleOperator)
+	}
+
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", config.APIToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	plain := TokenPrefix + hex.EncodeToString(buf)
+	return plain, config.APIToken{
+		Name:      name,
+		Hash:      HashToken(plain),
+		Role:      role,
+		RateLimit: rateLimit,
+		Created:   time.Now().UTC(),
+	}, nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a plaintext token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator checks bearer tokens against the configured token list and
+// enforces per-token rate limits
+type Authenticator struct {
+	tokens []config.APIToken
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewAuthenticator creates an authenticator for the given tokens
+func NewAuthenticator(tokens []config.APIToken) *Authenticator {
+	return &Authenticator{
+		tokens:  tokens,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Enabled returns true if any tokens are configured
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Authenticate returns the token matching the plaintext value
+func (a *Authenticator) Authenticate(plain string) (*config.APIToken, bool) {
+	hash := HashToken(plain)
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(a.tokens[i].Hash), []byte(hash)) == 1 {
+			return &a.tokens[i], true
+		}
+	}
+	return nil, false
+}
+
+// Allow consumes one request from the token's rate limit bucket. Limits are
+// expressed in requests per minute; zero means unlimited.
+func (a *Authenticator) Allow(token *config.APIToken) bool {
+	if token.RateLimit <= 0 {
+		return true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	b, ok := a.buckets[token.Hash]
+	if !ok {
+		b = &bucket{tokens: float64(token.RateLimit), last: now}
+		a.buckets[token.Hash] = b
+	}
+
+	// Refill proportionally to the elapsed time
+	b.tokens += now.Sub(b.last).Minutes() * float64(token.RateLimit)
+	if b.tokens > float64(token.RateLimit) {
+		b.tokens = float64(token.RateLimit)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Require wraps a handler so it is only reachable with a token holding at
+// least the given role. When no tokens are configured, read routes are open
+// and operator routes are refused, as anyone could otherwise change state.
+func (a *Authenticator) Require(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			if role == config.RoleOperator {
+				http.Error(w, "operator endpoints need an API token, none are configured", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		plain := bearerToken(r)
+		if plain == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fail2ban-notify"`)
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		token, ok := a.Authenticate(plain)
+		if !ok {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		if !config.RoleAllows(token.Role, role) {
+			http.Error(w, "insufficient role", http.StatusForbidden)
+			return
+		}
+
+		if !a.Allow(token) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the token from the Authorization header. Clients that
+// only support Basic authentication, such as feed readers, may send the token
+// as the password with any user name.
+func bearerToken(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
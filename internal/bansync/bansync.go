@@ -0,0 +1,65 @@
+// Package bansync reconciles the active bans of the event store with the
+// bans fail2ban currently holds, which drift apart when fail2ban restarts,
+// bans expire early or events are lost
+package bansync
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/f2bclient" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"          //nolint:depguard
+)
+
+// Sync queries fail2ban-client for the IPs banned in the given jails, or in
+// all running jails if none are given, and reconciles the store's active
+// bans with them. With dryRun the store is not changed.
+func Sync(ctx context.Context, cfg *config.Config, jails []string, dryRun bool) (*store.SyncResult, error) {
+	if !cfg.Store.Enabled {
+		return nil, fmt.Errorf("the event store is disabled")
+	}
+
+	client := f2bclient.New(cfg.ChatOps.Fail2banClient)
+	if len(jails) == 0 {
+		var err error
+		if jails, err = client.Jails(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var hasher *privacy.Hasher
+	if cfg.Privacy.Enabled() {
+		hasher = privacy.NewHasher(cfg.Privacy)
+	}
+
+	now := time.Now()
+	var current []types.NotificationData
+	for _, jail := range jails {
+		banned, err := client.Banned(ctx, jail)
+		if err != nil {
+			return nil, fmt.Errorf("jail %s: %w", jail, err)
+		}
+		for _, ip := range banned {
+			if net.ParseIP(ip) == nil {
+				continue
+			}
+			data := &types.NotificationData{IP: ip, Jail: jail, Action: types.ActionBan, Time: now}
+			if hasher != nil {
+				if data.IPHash, err = hasher.Hash(ip); err != nil {
+					return nil, fmt.Errorf("failed to hash IP: %w", err)
+				}
+			}
+			if cfg.Privacy.Store {
+				data = privacy.Apply(&cfg.Privacy, data)
+			}
+			current = append(current, *data)
+		}
+	}
+
+	return store.New(cfg.Store).Reconcile(jails, current, now, dryRun)
+}
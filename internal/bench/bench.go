@@ -0,0 +1,215 @@
+// Package bench load-tests the pipeline with synthetic events to size the
+// daemon: it submits events at a fixed rate through a queue like the
+// daemon's and measures throughput, queueing and connector latencies
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// Options controls the load
+type Options struct {
+	Rate      int           // Events submitted per second
+	Duration  time.Duration // How long events are submitted
+	QueueSize int           // Events buffered before submission blocks, as daemon.queue_size
+	Jails     []string      // Jails the events are spread over
+}
+
+// Processor runs an event through the pipeline
+type Processor func(ev *pipeline.Event) (*types.BatchResult, error)
+
+// Latency holds percentiles of a set of durations
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// ConnectorResult is the latency and failures of one connector
+type ConnectorResult struct {
+	Name     string  `json:"name"`
+	Runs     int     `json:"runs"`
+	Failures int     `json:"failures"`
+	Latency  Latency `json:"latency"`
+}
+
+// Result is the outcome of a benchmark run
+type Result struct {
+	Rate       int           `json:"rate"`       // Target events per second
+	Submitted  int           `json:"submitted"`  // Events generated
+	Processed  int           `json:"processed"`  // Events through the pipeline
+	Errors     int           `json:"errors"`     // Events completed with errors
+	Elapsed    time.Duration `json:"elapsed"`    // From the first submission until the queue drained
+	Drain      time.Duration `json:"drain"`      // Time the queue took to drain after the last submission
+	Throughput float64       `json:"throughput"` // Events processed per second
+
+	QueueSize     int           `json:"queue_size"`
+	MaxQueueDepth int           `json:"max_queue_depth"`
+	Blocked       int           `json:"blocked"`      // Submissions that waited for a full queue
+	BlockedTime   time.Duration `json:"blocked_time"` // Total time submissions waited
+
+	Pipeline   Latency           `json:"pipeline"`   // Processing time of an event
+	EndToEnd   Latency           `json:"end_to_end"` // From submission until processed, including queueing
+	Connectors []ConnectorResult `json:"connectors"`
+}
+
+// queued is an event waiting in the queue
+type queued struct {
+	ev        *pipeline.Event
+	submitted time.Time
+}
+
+// Run submits synthetic events at opts.Rate for opts.Duration and processes
+// them one at a time, like the daemon. It returns once the queue has drained
+// or ctx is canceled.
+func Run(ctx context.Context, opts Options, process Processor) (*Result, error) {
+	if opts.Rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if opts.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+	if len(opts.Jails) == 0 {
+		opts.Jails = []string{"sshd"}
+	}
+
+	result := &Result{Rate: opts.Rate, QueueSize: opts.QueueSize}
+	queue := make(chan queued, opts.QueueSize)
+
+	var (
+		pipe       []time.Duration
+		endToEnd   []time.Duration
+		connectors = make(map[string][]time.Duration)
+		failures   = make(map[string]int)
+	)
+
+	// Events are processed one at a time, like in the daemon
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for item := range queue {
+			start := time.Now()
+			batch, err := process(item.ev)
+			finished := time.Now()
+
+			result.Processed++
+			if err != nil {
+				result.Errors++
+			}
+			pipe = append(pipe, finished.Sub(start))
+			endToEnd = append(endToEnd, finished.Sub(item.submitted))
+			if batch != nil {
+				for _, r := range batch.Results {
+					connectors[r.ConnectorName] = append(connectors[r.ConnectorName], r.Duration)
+					if !r.Success {
+						failures[r.ConnectorName]++
+					}
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	interval := time.Second / time.Duration(opts.Rate)
+	total := int(opts.Duration / interval)
+submit:
+	for i := 0; i < total; i++ {
+		if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				break submit
+			}
+		}
+
+		item := queued{ev: syntheticEvent(i, opts.Jails), submitted: time.Now()}
+		select {
+		case queue <- item:
+		default:
+			// The queue is full, so the submission blocks like a daemon source
+			result.Blocked++
+			select {
+			case queue <- item:
+			case <-ctx.Done():
+				break submit
+			}
+			result.BlockedTime += time.Since(item.submitted)
+		}
+		result.Submitted++
+		if depth := len(queue); depth > result.MaxQueueDepth {
+			result.MaxQueueDepth = depth
+		}
+	}
+	submitted := time.Now()
+	close(queue)
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	finished := time.Now()
+	result.Elapsed = finished.Sub(start)
+	result.Drain = finished.Sub(submitted)
+	if result.Elapsed > 0 {
+		result.Throughput = float64(result.Processed) / result.Elapsed.Seconds()
+	}
+	result.Pipeline = percentiles(pipe)
+	result.EndToEnd = percentiles(endToEnd)
+
+	result.Connectors = make([]ConnectorResult, 0, len(connectors))
+	for name, durations := range connectors {
+		result.Connectors = append(result.Connectors, ConnectorResult{
+			Name:     name,
+			Runs:     len(durations),
+			Failures: failures[name],
+			Latency:  percentiles(durations),
+		})
+	}
+	sort.Slice(result.Connectors, func(i, j int) bool {
+		return result.Connectors[i].Name < result.Connectors[j].Name
+	})
+	return result, nil
+}
+
+// syntheticEvent returns the i-th benchmark event. IPs are taken from
+// 198.18.0.0/15, which is reserved for benchmarks (RFC 2544).
+func syntheticEvent(i int, jails []string) *pipeline.Event {
+	ip := net.IPv4(198, 18+byte(i>>16&1), byte(i>>8), byte(i))
+	return &pipeline.Event{
+		IP:       ip.String(),
+		Jail:     jails[i%len(jails)],
+		Action:   types.ActionBan,
+		Failures: 3 + rand.Intn(8), //nolint:gosec // Not security sensitive
+		Time:     time.Now(),
+	}
+}
+
+// percentiles computes the latency percentiles of durations, nearest rank
+func percentiles(durations []time.Duration) Latency {
+	if len(durations) == 0 {
+		return Latency{}
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return Latency{P50: rank(0.50), P90: rank(0.90), P99: rank(0.99), Max: sorted[len(sorted)-1]}
+}
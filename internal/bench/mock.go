@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// MockServer is a local HTTP endpoint standing in for notification services.
+// It answers after a random delay around the configured latency and fails a
+// share of the requests.
+type MockServer struct {
+	URL string
+
+	latency     time.Duration
+	failureRate float64
+	server      *http.Server
+}
+
+// StartMock starts a mock endpoint on a random local port
+func StartMock(latency time.Duration, failureRate float64) (*MockServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mock endpoint: %w", err)
+	}
+
+	m := &MockServer{
+		URL:         "http://" + listener.Addr().String() + "/",
+		latency:     latency,
+		failureRate: failureRate,
+	}
+	m.server = &http.Server{Handler: m, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = m.server.Serve(listener)
+	}()
+	return m, nil
+}
+
+// ServeHTTP answers a notification
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+
+	if m.latency > 0 {
+		// Spread the delay over 50-150% of the latency
+		jitter := time.Duration(rand.Int63n(int64(m.latency) + 1)) //nolint:gosec // Not security sensitive
+		time.Sleep(m.latency/2 + jitter)
+	}
+
+	if rand.Float64() < m.failureRate { //nolint:gosec // Not security sensitive
+		http.Error(w, "mock failure", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Connectors returns n HTTP connectors delivering to the mock endpoint
+func (m *MockServer) Connectors(n int) []config.ConnectorConfig {
+	connectors := make([]config.ConnectorConfig, n)
+	for i := range connectors {
+		connectors[i] = config.ConnectorConfig{
+			Name:     fmt.Sprintf("mock%d", i+1),
+			Type:     config.ConnectorTypeHTTP,
+			Enabled:  true,
+			Settings: map[string]string{"url": m.URL},
+			Timeout:  30,
+		}
+	}
+	return connectors
+}
+
+// Close stops the mock endpoint
+func (m *MockServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
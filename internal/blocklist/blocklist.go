@@ -0,0 +1,102 @@
+// Package blocklist renders lists of addresses in the formats firewalls and
+// web servers load, for the blocklist connector, object storage uploads and
+// the top offenders feed
+package blocklist
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Formats
+const (
+	FormatPlain    = "plain"    // One address per line
+	FormatNginx    = "nginx"    // deny <ip>; lines for an nginx include
+	FormatIPSet    = "ipset"    // Input for ipset restore
+	FormatMikroTik = "mikrotik" // RouterOS script replacing an address list
+	FormatEDL      = "edl"      // External dynamic list: bare addresses, no comments
+)
+
+// DefaultListName names the ipset or MikroTik address list
+const DefaultListName = "fail2ban-notify"
+
+// Formats lists the supported formats
+var Formats = []string{FormatPlain, FormatNginx, FormatIPSet, FormatMikroTik, FormatEDL}
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Render formats the addresses, sorted. source names the list in comments,
+// listName the ipset or address list replaced by the ipset and mikrotik
+// formats.
+func Render(format, source, listName string, ips []string) []byte {
+	sorted := append([]string(nil), ips...)
+	sort.Strings(sorted)
+	if listName == "" {
+		listName = DefaultListName
+	}
+
+	var buf bytes.Buffer
+	// ipset restore and the external dynamic lists of Palo Alto and
+	// Fortinet firewalls don't take comments
+	if format != FormatIPSet && format != FormatEDL {
+		fmt.Fprintf(&buf, "# Generated by fail2ban-notify (%s), %d addresses\n", source, len(sorted))
+	}
+
+	switch format {
+	case FormatNginx:
+		for _, ip := range sorted {
+			fmt.Fprintf(&buf, "deny %s;\n", ip)
+		}
+
+	case FormatIPSet:
+		// IPv4 and IPv6 addresses need separate sets
+		set4 := listName
+		set6 := set4 + "6"
+		fmt.Fprintf(&buf, "create %s hash:ip family inet -exist\n", set4)
+		fmt.Fprintf(&buf, "create %s hash:ip family inet6 -exist\n", set6)
+		fmt.Fprintf(&buf, "flush %s\n", set4)
+		fmt.Fprintf(&buf, "flush %s\n", set6)
+		for _, ip := range sorted {
+			set := set4
+			if isIPv6(ip) {
+				set = set6
+			}
+			fmt.Fprintf(&buf, "add %s %s\n", set, ip)
+		}
+
+	case FormatMikroTik:
+		// Imported with /import, replacing the list's previous entries
+		fmt.Fprintf(&buf, "/ip firewall address-list remove [find list=\"%s\"]\n", listName)
+		fmt.Fprintf(&buf, "/ipv6 firewall address-list remove [find list=\"%s\"]\n", listName)
+		for _, ip := range sorted {
+			menu := "/ip"
+			if isIPv6(ip) {
+				menu = "/ipv6"
+			}
+			fmt.Fprintf(&buf, "%s firewall address-list add list=\"%s\" address=%s\n", menu, listName, ip)
+		}
+
+	default:
+		for _, ip := range sorted {
+			fmt.Fprintln(&buf, ip)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// isIPv6 reports whether ip is an IPv6 address
+func isIPv6(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() == nil
+}
@@ -0,0 +1,231 @@
+// Package campaign clusters bans from the same network or autonomous system
+// that are close in time into campaigns, so related IPs are reported together
+package campaign
+
+import (
+	"net"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// File holds the clusters, inside the store directory
+const File = "campaigns.json"
+
+// Cluster kinds
+const (
+	KindSubnet = "subnet" // Same /24 (IPv4) or /48 (IPv6) network
+	KindASN    = "asn"    // Same autonomous system
+)
+
+// Cluster is a group of bans sharing a network or autonomous system. It
+// becomes a campaign, with an ID, once it holds enough distinct IPs.
+type Cluster struct {
+	ID    int       `json:"id,omitempty"`
+	Kind  string    `json:"kind"`
+	Key   string    `json:"key"`
+	IPs   []string  `json:"ips"`
+	Jails []string  `json:"jails"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// Campaign returns the summary of the cluster included in notifications
+func (c *Cluster) Campaign() *types.Campaign {
+	return &types.Campaign{ID: c.ID, Kind: c.Kind, Key: c.Key, IPs: len(c.IPs), First: c.First, Last: c.Last}
+}
+
+// clusters is the state saved in File
+type clusters struct {
+	NextID   int        `json:"next_id"`
+	Clusters []*Cluster `json:"clusters"`
+}
+
+// Tracker assigns bans to campaigns
+type Tracker struct {
+	cfg       config.CampaignConfig
+	path      string
+	retention time.Duration
+	hashIPs   bool         // Keep IP hashes instead of IPs, like the store in privacy mode
+	store     *store.Store // Reads and writes the state, encrypted like the store
+}
+
+// NewTracker creates a tracker keeping its state in the event store
+// directory. Campaigns are kept as long as the events.
+func NewTracker(cfg config.CampaignConfig, storeCfg config.StoreConfig, privacy config.PrivacyConfig) *Tracker {
+	return &Tracker{
+		cfg:       cfg,
+		path:      filepath.Join(storeCfg.Dir, File),
+		retention: time.Duration(storeCfg.Retention) * time.Second,
+		hashIPs:   privacy.Enabled() && privacy.Store,
+		store:     store.New(storeCfg),
+	}
+}
+
+// Assign adds a ban to the clusters of its network and autonomous system and
+// returns the largest campaign it is part of, or nil if there is none yet
+func (t *Tracker) Assign(data *types.NotificationData) (*types.Campaign, error) {
+	if !data.IsBan() {
+		return nil, nil
+	}
+
+	saved := &clusters{}
+	var campaign *types.Campaign
+	err := t.store.UpdateState(t.path, saved, func() error {
+		if cluster := t.add(saved, data); cluster != nil {
+			campaign = cluster.Campaign()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+// Campaigns returns the campaigns last active at or after since, most
+// recently active first
+func (t *Tracker) Campaigns(since time.Time) ([]*Cluster, error) {
+	var saved clusters
+	if err := t.store.LoadState(t.path, &saved); err != nil {
+		return nil, err
+	}
+
+	var campaigns []*Cluster
+	for _, cluster := range saved.Clusters {
+		if cluster.ID > 0 && !cluster.Last.Before(since) {
+			campaigns = append(campaigns, cluster)
+		}
+	}
+
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].Last.After(campaigns[j].Last)
+	})
+	return campaigns, nil
+}
+
+// Rebuild clusters all bans in the event store from scratch, e.g. after
+// changing the window, and returns the number of campaigns found.
+// Campaign IDs are renumbered.
+func (t *Tracker) Rebuild(st *store.Store) (int, error) {
+	rebuilt := &clusters{}
+	err := st.Scan(func(data *types.NotificationData) error {
+		if data.IsBan() {
+			t.add(rebuilt, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := t.store.SaveState(t.path, rebuilt); err != nil {
+		return 0, err
+	}
+	return rebuilt.NextID, nil
+}
+
+// add adds a ban to the matching clusters, starting new ones where none is
+// recent enough, and returns the largest campaign among them
+func (t *Tracker) add(s *clusters, data *types.NotificationData) *Cluster {
+	window := time.Duration(t.cfg.Window) * time.Second
+	s.prune(data.Time, window, t.retention)
+
+	var largest *Cluster
+	for _, key := range keys(data) {
+		cluster := s.find(key[0], key[1], data.Time, window)
+		if cluster == nil {
+			cluster = &Cluster{Kind: key[0], Key: key[1], First: data.Time, Last: data.Time}
+			s.Clusters = append(s.Clusters, cluster)
+		}
+
+		member := data.IP
+		if t.hashIPs {
+			member = data.Subject()
+		}
+		cluster.IPs = appendUnique(cluster.IPs, member)
+		cluster.Jails = appendUnique(cluster.Jails, data.Jail)
+		if data.Time.Before(cluster.First) {
+			cluster.First = data.Time
+		}
+		if data.Time.After(cluster.Last) {
+			cluster.Last = data.Time
+		}
+
+		if cluster.ID == 0 && len(cluster.IPs) >= t.cfg.MinIPs {
+			s.NextID++
+			cluster.ID = s.NextID
+		}
+		if cluster.ID > 0 && (largest == nil || len(cluster.IPs) > len(largest.IPs)) {
+			largest = cluster
+		}
+	}
+
+	return largest
+}
+
+// find returns the most recent cluster with the kind and key whose last ban
+// is within window of t
+func (s *clusters) find(kind, key string, t time.Time, window time.Duration) *Cluster {
+	for i := len(s.Clusters) - 1; i >= 0; i-- {
+		cluster := s.Clusters[i]
+		if cluster.Kind == kind && cluster.Key == key && t.Sub(cluster.Last) <= window {
+			return cluster
+		}
+	}
+	return nil
+}
+
+// prune drops clusters that can no longer become campaigns and campaigns
+// past the retention period
+func (s *clusters) prune(now time.Time, window, retention time.Duration) {
+	kept := s.Clusters[:0]
+	for _, cluster := range s.Clusters {
+		age := now.Sub(cluster.Last)
+		if (cluster.ID == 0 && age > window) || (retention > 0 && age > retention) {
+			continue
+		}
+		kept = append(kept, cluster)
+	}
+	s.Clusters = kept
+}
+
+// keys returns the kind and key of each cluster the ban belongs to
+func keys(data *types.NotificationData) [][2]string {
+	var result [][2]string
+	if subnet := subnetOf(data.IP); subnet != "" {
+		result = append(result, [2]string{KindSubnet, subnet})
+	}
+	if data.ASN != "" {
+		result = append(result, [2]string{KindASN, data.ASN})
+	}
+	return result
+}
+
+// subnetOf returns the /24 network of an IPv4 address or the /48 network of
+// an IPv6 address
+func subnetOf(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// appendUnique appends value unless the list already contains it
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,334 @@
+// Package chain keeps a tamper-evident, append-only copy of the event log.
+// Every record includes the hash of the record before it, so a record that
+// is edited, removed or inserted later breaks the chain from there on.
+// Records may also be signed with an Ed25519 key, so the chain can be
+// checked elsewhere with the public key alone.
+package chain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+)
+
+// maxRecord bounds the length of a record line
+const maxRecord = 1024 * 1024
+
+// tailChunk is how much of the file is read at a time to find the last record
+const tailChunk = 64 * 1024
+
+// Record is one line of the chain
+type Record struct {
+	Seq   uint64          `json:"seq"`           // 1 for the first record
+	Time  time.Time       `json:"time"`          // When the record was appended
+	Event json.RawMessage `json:"event"`         // The event as stored, a string if encrypted
+	Prev  string          `json:"prev"`          // Hash of the previous record, empty for the first
+	Hash  string          `json:"hash"`          // SHA-256 of the fields above
+	Sig   string          `json:"sig,omitempty"` // Ed25519 signature of the hash, base64
+}
+
+// digest returns the hash of the record's content
+func (r *Record) digest() string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(r.Seq, 10) + "\n" + r.Time.UTC().Format(time.RFC3339Nano) + "\n" + r.Prev + "\n"))
+	h.Write(r.Event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Log appends records to the chain. The signing key is read, or generated,
+// on first use.
+type Log struct {
+	cfg    config.ChainConfig
+	sealer *seal.Sealer // nil unless the store is encrypted
+
+	once sync.Once
+	key  ed25519.PrivateKey
+	err  error
+}
+
+// New creates a log for the chain configuration. With a sealer, events are
+// stored encrypted, as the JSON string of the sealed event. The hash covers
+// that string, so the chain can still be verified without the key.
+func New(cfg config.ChainConfig, sealer *seal.Sealer) *Log {
+	return &Log{cfg: cfg, sealer: sealer}
+}
+
+// Append adds the event to the end of the chain
+func (l *Log) Append(event interface{}, now time.Time) error {
+	var key ed25519.PrivateKey
+	if l.cfg.Sign {
+		l.once.Do(func() {
+			l.key, l.err = loadKey(l.cfg.KeyFile)
+		})
+		if l.err != nil {
+			return l.err
+		}
+		key = l.key
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if l.sealer != nil {
+		sealed, err := l.sealer.Seal(raw)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt event: %w", err)
+		}
+		if raw, err = json.Marshal(string(sealed)); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	unlock, err := state.Lock(l.cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(filepath.Clean(l.cfg.Path), os.O_CREATE|os.O_RDWR|os.O_APPEND, state.FilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to open chain: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	last, err := lastRecord(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w; run 'fail2ban-notify verify'", l.cfg.Path, err)
+	}
+
+	record := Record{Seq: 1, Time: now.UTC(), Event: raw}
+	if last != nil {
+		record.Seq = last.Seq + 1
+		record.Prev = last.Hash
+	}
+	record.Hash = record.digest()
+	if key != nil {
+		record.Sig = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(record.Hash)))
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chain record: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write chain record: %w", err)
+	}
+	return file.Sync()
+}
+
+// lastRecord returns the last record of the file, or nil if it is empty. A
+// last line that is not a complete record is an error, since appending
+// after it would hide the damage.
+func lastRecord(file *os.File) (*Record, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chain: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	var buf []byte
+	for offset := size; offset > 0; {
+		n := int64(tailChunk)
+		if n > offset {
+			n = offset
+		}
+		offset -= n
+		part := make([]byte, n)
+		if _, err := file.ReadAt(part, offset); err != nil {
+			return nil, fmt.Errorf("failed to read chain: %w", err)
+		}
+		buf = append(part, buf...)
+
+		// The last line is complete once a newline before it is found
+		if i := bytes.LastIndexByte(buf[:len(buf)-1], '\n'); i >= 0 {
+			buf = buf[i+1:]
+			break
+		}
+		if len(buf) > maxRecord {
+			return nil, errors.New("last record is too long")
+		}
+	}
+
+	if buf[len(buf)-1] != '\n' {
+		return nil, errors.New("last record is incomplete")
+	}
+	var record Record
+	if err := json.Unmarshal(buf, &record); err != nil || record.Hash == "" {
+		return nil, errors.New("last record is damaged")
+	}
+	return &record, nil
+}
+
+// Result summarizes a verified chain
+type Result struct {
+	Records uint64 `json:"records"`
+	Signed  uint64 `json:"signed"` // Records whose signature was checked
+	Head    string `json:"head"`   // Hash of the last record
+	First   string `json:"first,omitempty"`
+	Last    string `json:"last,omitempty"`
+}
+
+// VerifyError locates the first record that breaks the chain
+type VerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Verify checks every record of the chain at path: its sequence number, its
+// link to the previous record, its hash and, with a public key, its
+// signature. The result covers the records up to the first broken one,
+// which is returned as a *VerifyError.
+func Verify(path string, publicKey ed25519.PublicKey) (*Result, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chain: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	result := &Result{}
+	reader := bufio.NewReaderSize(file, tailChunk)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read chain: %w", err)
+		}
+		if len(raw) == 0 {
+			break
+		}
+		if len(raw) > maxRecord {
+			return result, &VerifyError{Line: line, Reason: "record is too long"}
+		}
+		if raw[len(raw)-1] != '\n' {
+			return result, &VerifyError{Line: line, Reason: "record is incomplete"}
+		}
+
+		var record Record
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return result, &VerifyError{Line: line, Reason: "record is not valid JSON"}
+		}
+		if reason := check(&record, result, publicKey); reason != "" {
+			return result, &VerifyError{Line: line, Reason: reason}
+		}
+
+		result.Records++
+		result.Head = record.Hash
+		if result.First == "" {
+			result.First = record.Time.Format(time.RFC3339)
+		}
+		result.Last = record.Time.Format(time.RFC3339)
+		if publicKey != nil {
+			result.Signed++
+		}
+	}
+	return result, nil
+}
+
+// check returns why the record doesn't follow the verified ones, or ""
+func check(record *Record, verified *Result, publicKey ed25519.PublicKey) string {
+	if record.Seq != verified.Records+1 {
+		return fmt.Sprintf("sequence number %d, expected %d: records are missing or were inserted", record.Seq, verified.Records+1)
+	}
+	if record.Prev != verified.Head {
+		return "previous hash doesn't match: a record before it was changed or removed"
+	}
+	if record.digest() != record.Hash {
+		return "hash doesn't match the content: the record was changed"
+	}
+	if publicKey == nil {
+		return ""
+	}
+	sig, err := base64.StdEncoding.DecodeString(record.Sig)
+	if err != nil || record.Sig == "" {
+		return "record is not signed"
+	}
+	if !ed25519.Verify(publicKey, []byte(record.Hash), sig) {
+		return "signature is invalid"
+	}
+	return ""
+}
+
+// loadKey reads the private key file, creating it and the public key file
+// next to it if they don't exist
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	unlock, err := state.Lock(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err == nil {
+		seed, decodeErr := hex.DecodeString(strings.TrimSpace(string(data)))
+		if decodeErr != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid chain signing key in %s, expected %d hex characters", path, 2*ed25519.SeedSize)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read chain signing key: %w", err)
+	}
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate chain signing key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())+"\n"), state.FilePermission); err != nil {
+		return nil, fmt.Errorf("failed to write chain signing key: %w", err)
+	}
+	if err := os.WriteFile(PublicKeyPath(path), []byte(hex.EncodeToString(publicKey)+"\n"), 0644); err != nil { //nolint:gosec // The public key is meant to be shared
+		return nil, fmt.Errorf("failed to write chain public key: %w", err)
+	}
+	return key, nil
+}
+
+// PublicKeyPath returns the path of the public key written next to the
+// private key file
+func PublicKeyPath(keyFile string) string {
+	return keyFile + ".pub"
+}
+
+// LoadPublicKey reads a public key file
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %w", path, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid key in %s, expected %d hex characters", path, 2*ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// minAckSecret is the shortest accepted key for signing acknowledgment links
+const minAckSecret = 16
+
+// AckConfig tracks acknowledgments of ban notifications. Responders
+// acknowledge an event through a signed link, a Slack button or a PagerDuty
+// incident, and the daemon records it in the event store. Critical events
+// (see DeliveryConfig.CriticalJails) nobody acknowledged are counted in
+// -status and the email digests.
+type AckConfig struct {
+	Enabled         bool   `json:"enabled"`
+	BaseURL         string `json:"base_url,omitempty"`         // Public URL of the daemon's /ack endpoint, for links in notifications
+	Secret          string `json:"secret,omitempty"`           // Key signing the links; required with base_url
+	PagerDutySecret string `json:"pagerduty_secret,omitempty"` // Webhook signing secret; enables POST /ack/pagerduty
+	Window          int    `json:"window,omitempty"`           // Seconds a critical event counts as unacknowledged (default: 7 days)
+}
+
+// URL returns the signed acknowledgment link of an event, or "" without a
+// base URL
+func (a *AckConfig) URL(eventID string) string {
+	if a.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(a.BaseURL, "/") + "/" + eventID + "?sig=" + a.Signature(eventID)
+}
+
+// Signature returns the signature of the acknowledgment link of an event
+func (a *AckConfig) Signature(eventID string) string {
+	mac := hmac.New(sha256.New, []byte(a.Secret))
+	mac.Write([]byte("ack:" + eventID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidSignature reports whether sig signs the acknowledgment link of an
+// event
+func (a *AckConfig) ValidSignature(eventID, sig string) bool {
+	return a.Secret != "" && hmac.Equal([]byte(a.Signature(eventID)), []byte(sig))
+}
+
+// validateAckConfig validates the acknowledgment settings and fills in
+// defaults
+func validateAckConfig(config *Config) error {
+	ack := &config.Ack
+
+	if ack.Window <= 0 {
+		ack.Window = 7 * 86400
+	}
+	if !ack.Enabled {
+		return nil
+	}
+
+	if !config.Store.Enabled {
+		return fmt.Errorf("ack: requires the event store")
+	}
+	if ack.BaseURL != "" {
+		if u, err := url.Parse(ack.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("ack: base_url must be an http(s) URL: %s", ack.BaseURL)
+		}
+		if len(ack.Secret) < minAckSecret {
+			return fmt.Errorf("ack: base_url requires a secret of at least %d characters", minAckSecret)
+		}
+	}
+	if (ack.BaseURL != "" || ack.PagerDutySecret != "") && config.Daemon.Listen == "" {
+		return fmt.Errorf("ack: callbacks require daemon.listen")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import "fmt"
+
+// Anonymity detection services
+const (
+	AnonymitySpur = "spur"
+	AnonymityIPQS = "ipqs"
+)
+
+// AnonymityConfig enables VPN, proxy and bot detection through a commercial
+// service. Results are cached on disk since lookups are billed.
+type AnonymityConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Service    string `json:"service"`              // "spur" or "ipqs"
+	APIKey     string `json:"api_key"`              // Spur token or IPQualityScore key
+	TTL        int    `json:"ttl"`                  // Seconds to cache a result (default: 1 day)
+	Strictness int    `json:"strictness,omitempty"` // IPQualityScore strictness 0-3 (default: 1)
+}
+
+// validateAnonymityConfig validates the anonymity detection settings
+func validateAnonymityConfig(anonymity *AnonymityConfig) error {
+	if anonymity.TTL <= 0 {
+		anonymity.TTL = 86400
+	}
+
+	if !anonymity.Enabled {
+		return nil
+	}
+
+	switch anonymity.Service {
+	case AnonymitySpur:
+	case AnonymityIPQS:
+		if anonymity.Strictness < 0 || anonymity.Strictness > 3 {
+			return fmt.Errorf("anonymity: strictness must be between 0 and 3")
+		}
+	default:
+		return fmt.Errorf("anonymity: invalid service '%s', must be '%s' or '%s'", anonymity.Service, AnonymitySpur, AnonymityIPQS)
+	}
+
+	if anonymity.APIKey == "" {
+		return fmt.Errorf("anonymity: api_key is required for %s", anonymity.Service)
+	}
+
+	return nil
+}
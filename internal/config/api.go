@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// API roles
+const (
+	RoleRead     = "read"     // Status and history queries
+	RoleOperator = "operator" // Everything, including unban and connector tests
+)
+
+// APIConfig contains settings for the daemon API
+type APIConfig struct {
+	Tokens []APIToken `json:"tokens,omitempty"`
+}
+
+// APIToken is an issued API token. Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Role      string    `json:"role"`       // "read" or "operator"
+	RateLimit int       `json:"rate_limit"` // Requests per minute, 0 for unlimited
+	Created   time.Time `json:"created"`
+}
+
+// ValidRole returns true if role is a known API role
+func ValidRole(role string) bool {
+	return role == RoleRead || role == RoleOperator
+}
+
+// RoleAllows returns true if a token with role may access an endpoint requiring required
+func RoleAllows(role, required string) bool {
+	if role == RoleOperator {
+		return true
+	}
+	return role == required
+}
+
+// validateAPIConfig validates the API token list
+func validateAPIConfig(api *APIConfig) error {
+	names := make(map[string]bool)
+	for i, token := range api.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("api.tokens[%d]: name cannot be empty", i)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("api.tokens[%d]: duplicate token name '%s'", i, token.Name)
+		}
+		names[token.Name] = true
+
+		if token.Hash == "" {
+			return fmt.Errorf("api.tokens[%d] (%s): hash cannot be empty", i, token.Name)
+		}
+		if !ValidRole(token.Role) {
+			return fmt.Errorf("api.tokens[%d] (%s): invalid role '%s'", i, token.Name, token.Role)
+		}
+		if token.RateLimit < 0 {
+			api.Tokens[i].RateLimit = 0
+		}
+	}
+
+	return nil
+}
+
+// FindToken returns the index of the token with the given name or -1
+func (a *APIConfig) FindToken(name string) int {
+	for i, token := range a.Tokens {
+		if token.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ArtifactConfig controls bundling of log context for banned IPs
+type ArtifactConfig struct {
+	Enabled   bool                `json:"enabled"`
+	Dir       string              `json:"dir,omitempty"`      // Default: <state_dir>/artifacts
+	Logs      map[string][]string `json:"logs"`               // Jail name -> log files to search
+	MaxLines  int                 `json:"max_lines"`          // Maximum lines per log file (default: 50)
+	Retention int                 `json:"retention"`          // Seconds to keep artifacts (default: 7 days)
+	BaseURL   string              `json:"base_url,omitempty"` // Public URL of the daemon's artifact endpoint
+}
+
+// validateArtifactConfig validates the artifact configuration and fills in defaults
+func validateArtifactConfig(config *Config) error {
+	artifacts := &config.Artifacts
+
+	if artifacts.Dir == "" {
+		artifacts.Dir = filepath.Join(config.StateDir, "artifacts")
+	}
+
+	if artifacts.MaxLines <= 0 {
+		artifacts.MaxLines = 50
+	}
+
+	if artifacts.Retention <= 0 {
+		artifacts.Retention = 7 * 86400
+	}
+
+	for jail, logs := range artifacts.Logs {
+		for _, logPath := range logs {
+			if !filepath.IsAbs(logPath) {
+				return fmt.Errorf("artifacts: log path for jail %s must be absolute: %s", jail, logPath)
+			}
+		}
+	}
+
+	return nil
+}
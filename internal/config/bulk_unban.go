@@ -0,0 +1,30 @@
+package config
+
+import "fmt"
+
+// BulkUnbanConfig detects bursts of unbans from one jail, as when fail2ban
+// flushes it with "unban --all" or many bans expire together. Connectors
+// with coalesce_unbans receive one summary of the burst instead of each
+// unban; the others, such as firewalls, still receive every unban.
+type BulkUnbanConfig struct {
+	Enabled   bool `json:"enabled"`
+	Window    int  `json:"window"`    // Seconds the unbans of a jail are held to detect a burst (default: 10)
+	Threshold int  `json:"threshold"` // Unbans of a jail within the window that form a burst (default: 20)
+}
+
+// validateBulkUnbanConfig validates the bulk unban settings and fills in defaults
+func validateBulkUnbanConfig(bulk *BulkUnbanConfig) error {
+	if bulk.Window < 0 || bulk.Threshold < 0 {
+		return fmt.Errorf("bulk_unban: window and threshold must not be negative")
+	}
+	if bulk.Window == 0 {
+		bulk.Window = 10
+	}
+	if bulk.Threshold == 0 {
+		bulk.Threshold = 20
+	}
+	if bulk.Threshold < 2 {
+		return fmt.Errorf("bulk_unban: threshold must be at least 2")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// CampaignConfig controls clustering of bans from the same /24 network or
+// autonomous system into campaigns
+type CampaignConfig struct {
+	Enabled bool `json:"enabled"`
+	Window  int  `json:"window"`  // Seconds between bans that still continue a campaign (default: 2 hours)
+	MinIPs  int  `json:"min_ips"` // Distinct IPs before a cluster becomes a campaign (default: 3)
+}
+
+// validateCampaignConfig validates the campaign settings and fills in defaults
+func validateCampaignConfig(config *Config) error {
+	campaigns := &config.Campaigns
+
+	if campaigns.Window <= 0 {
+		campaigns.Window = 2 * 3600
+	}
+	if campaigns.MinIPs <= 0 {
+		campaigns.MinIPs = 3
+	}
+	if campaigns.MinIPs < 2 {
+		return fmt.Errorf("campaigns: min_ips must be at least 2")
+	}
+
+	if campaigns.Enabled && !config.Store.Enabled {
+		return fmt.Errorf("campaigns: requires the event store")
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+)
+
+// ChatOpsConfig enables chat commands (/f2b status, banned, unban) sent by
+// Slack slash commands and Microsoft Teams outgoing webhooks to the daemon
+type ChatOpsConfig struct {
+	SlackSigningSecret string   `json:"slack_signing_secret,omitempty"` // Enables POST /chatops/slack
+	TeamsSecurityToken string   `json:"teams_security_token,omitempty"` // Base64 token of the outgoing webhook; enables POST /chatops/teams
+	Operators          []string `json:"operators,omitempty"`            // Slack user IDs and Teams AAD object IDs allowed to unban
+	Fail2banClient     string   `json:"fail2ban_client,omitempty"`      // Default: fail2ban-client from PATH
+}
+
+// Enabled reports whether any chat platform is configured
+func (c *ChatOpsConfig) Enabled() bool {
+	return c.SlackSigningSecret != "" || c.TeamsSecurityToken != ""
+}
+
+// validateChatOpsConfig validates the chat command settings
+func validateChatOpsConfig(config *Config) error {
+	chatops := &config.ChatOps
+	if !chatops.Enabled() {
+		return nil
+	}
+
+	if config.Daemon.Listen == "" {
+		return fmt.Errorf("chatops: requires daemon.listen")
+	}
+
+	if chatops.TeamsSecurityToken != "" {
+		if _, err := base64.StdEncoding.DecodeString(chatops.TeamsSecurityToken); err != nil {
+			return fmt.Errorf("chatops: teams_security_token must be base64: %w", err)
+		}
+	}
+
+	if chatops.Fail2banClient != "" && !filepath.IsAbs(chatops.Fail2banClient) {
+		return fmt.Errorf("chatops: fail2ban_client must be an absolute path: %s", chatops.Fail2banClient)
+	}
+
+	return nil
+}
@@ -5,6 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/lsm"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
 )
 
 // Connector types
@@ -14,6 +20,15 @@ const (
 	ConnectorTypeHTTP       = "http"
 )
 
+// CompressionGzip is the compression setting that gzips HTTP request bodies
+const CompressionGzip = "gzip"
+
+// HTTP connector targets
+const (
+	TransportH2C = "h2c"     // Transport setting for plaintext HTTP/2
+	UnixScheme   = "unix://" // URL prefix of Unix socket targets
+)
+
 // GeoIP service types
 const (
 	GeoIPServiceIPAPI         = "ipapi"
@@ -28,25 +43,90 @@ const (
 
 // Config represents the application configuration
 type Config struct {
-	Connectors    []ConnectorConfig `json:"connectors"`
-	ConnectorPath string            `json:"connector_path"`
-	GeoIP         GeoIPConfig       `json:"geoip"`
-	Debug         bool              `json:"debug"`
-	LogLevel      string            `json:"log_level"`
-	Timeout       int               `json:"timeout"`
+	Connectors     []ConnectorConfig            `json:"connectors"`
+	Groups         []ConnectorGroup             `json:"groups,omitempty"` // Connectors tried in order until one succeeds
+	Delivery       DeliveryConfig               `json:"delivery"`
+	ConnectorPath  string                       `json:"connector_path"`
+	GeoIP          GeoIPConfig                  `json:"geoip"`
+	Anonymity      AnonymityConfig              `json:"anonymity"`
+	Proxy          ProxyConfig                  `json:"proxy"`       // Resolves clients behind banned reverse proxies
+	TargetHost     TargetHostConfig             `json:"target_host"` // Finds the attacked site in matched log lines
+	PassiveDNS     PassiveDNSConfig             `json:"passive_dns"`
+	Risk           RiskConfig                   `json:"risk"`
+	Trusted        TrustedCountriesConfig       `json:"trusted_countries"` // Countries whose bans may be credential stuffing
+	Routing        RoutingConfig                `json:"routing"`
+	Transforms     []Transform                  `json:"transforms,omitempty"`  // Applied in order to events before connectors run
+	Templates      map[string]*MessageTemplate  `json:"templates,omitempty"`   // Jail name or glob pattern -> message overrides
+	Labels         map[string]string            `json:"labels,omitempty"`      // Attached to every event, e.g. "env": "prod"
+	JailLabels     map[string]map[string]string `json:"jail_labels,omitempty"` // Jail name or glob pattern -> labels added for it
+	Debug          bool                         `json:"debug"`
+	LogLevel       string                       `json:"log_level"`
+	Timeout        int                          `json:"timeout"`
+	StateDir       string                       `json:"state_dir"`                 // Directory for state shared between invocations
+	SELinuxContext string                       `json:"selinux_context,omitempty"` // Context scripts run in through runcon
+	Profiles       map[string]*Profile          `json:"profiles,omitempty"`
+	ProfileDir     string                       `json:"profile_dir,omitempty"`  // Directory of <name>.json profile files
+	TemplateDir    string                       `json:"template_dir,omitempty"` // Directory of *.tmpl partials and layouts
+	API            APIConfig                    `json:"api"`
+	Spool          SpoolConfig                  `json:"spool"`
+	Observer       ObserverConfig               `json:"observer"` // Receives the BatchResult of every run
+	Artifacts      ArtifactConfig               `json:"artifacts"`
+	Honeypot       HoneypotConfig               `json:"honeypot"`
+	Logins         LoginsConfig                 `json:"logins"` // Successful logins before a ban flag a possible breach
+	Daemon         DaemonConfig                 `json:"daemon"`
+	Store          StoreConfig                  `json:"store"`
+	Surge          SurgeConfig                  `json:"surge"`
+	BulkUnban      BulkUnbanConfig              `json:"bulk_unban"` // Coalesces unbans when a jail is flushed
+	Campaigns      CampaignConfig               `json:"campaigns"`
+	RBL            RBLConfig                    `json:"rbl"`
+	Privacy        PrivacyConfig                `json:"privacy"`
+	Redaction      RedactionConfig              `json:"redaction"`
+	ChatOps        ChatOpsConfig                `json:"chatops"`
+	Heatmap        HeatmapConfig                `json:"heatmap"`
+	Ack            AckConfig                    `json:"ack"`              // Records acknowledgments of ban notifications
+	SLA            SLAConfig                    `json:"sla"`              // Escalates bans not acknowledged in time
+	Secrets        SecretsConfig                `json:"secrets"`          // Resolves vault://, aws-sm:// and systemd-cred:// settings
+	Locale         string                       `json:"locale,omitempty"` // Number and date conventions of templates and CLI output, e.g. "de-DE" (default: en-US)
+
+	// SkipUnbanEnrichment leaves out the GeoIP, anonymity and proxy lookups
+	// of unbans, which reuse the enrichment of their ban from the store
+	SkipUnbanEnrichment bool `json:"skip_unban_enrichment,omitempty"`
+
+	dirProfiles map[string]bool    // Profiles loaded from ProfileDir, not saved back
+	locale      *humanize.Locale   // Resolved Locale
+	partials    *template.Template // Parsed from TemplateDir
 }
 
 // ConnectorConfig defines a notification connector
 type ConnectorConfig struct {
-	Name        string            `json:"name"`
-	Type        string            `json:"type"` // "script", "executable", or "http"
-	Enabled     bool              `json:"enabled"`
-	Path        string            `json:"path"`        // Path to script/executable
-	Settings    map[string]string `json:"settings"`    // Environment variables or config
-	Timeout     int               `json:"timeout"`     // Timeout in seconds (default: 30)
-	RetryCount  int               `json:"retry_count"` // Number of retries on failure
-	RetryDelay  int               `json:"retry_delay"` // Delay between retries in seconds
-	Description string            `json:"description"` // Human-readable description
+	Name           string               `json:"name"`
+	Type           string               `json:"type"` // "script", "executable", or "http"
+	Enabled        bool                 `json:"enabled"`
+	Path           string               `json:"path"`                      // Path to script/executable
+	Args           []string             `json:"args,omitempty"`            // Arguments, Go templates executed with the event
+	Settings       map[string]string    `json:"settings"`                  // Environment variables or config
+	Timeout        int                  `json:"timeout"`                   // Timeout in seconds (default: 30)
+	RetryCount     int                  `json:"retry_count"`               // Number of retries on failure
+	RetryDelay     int                  `json:"retry_delay"`               // Delay between retries in seconds
+	Description    string               `json:"description"`               // Human-readable description
+	Escape         string               `json:"escape,omitempty"`          // Escaping for event fields: "markdown", "markdownv2", "html", "json"
+	Throttle       *ThrottleConfig      `json:"throttle,omitempty"`        // Optional cap on messages per time window
+	Recipients     []Recipient          `json:"recipients,omitempty"`      // Destinations notified instead of the connector's own settings
+	Template       *MessageTemplate     `json:"template,omitempty"`        // Title, body and fields, overriding jail templates
+	Expect         *ResponseExpectation `json:"expect,omitempty"`          // Validation of HTTP responses
+	SELinuxContext string               `json:"selinux_context,omitempty"` // Overrides the global selinux_context
+	RawIP          bool                 `json:"raw_ip,omitempty"`          // Receive real IPs despite the privacy mode
+	BestEffort     bool                 `json:"best_effort,omitempty"`     // Failures don't fail the run, count toward the quorum or escalate
+	Probe          string               `json:"probe,omitempty"`           // Health probe: "head", "options", "healthcheck" or "none"
+	Payload        *PayloadFields       `json:"payload,omitempty"`         // Event fields the connector receives
+	Network        *NetworkPolicy       `json:"network,omitempty"`         // Network sandbox of script and executable connectors
+	CoalesceUnbans bool                 `json:"coalesce_unbans,omitempty"` // Receive one summary instead of each unban of a flushed jail
+}
+
+// ThrottleConfig caps the number of messages a connector delivers per window
+type ThrottleConfig struct {
+	MaxMessages int `json:"max_messages"` // Messages allowed per window
+	Window      int `json:"window"`       // Window length in seconds
 }
 
 // GeoIPConfig contains geolocation API settings
@@ -56,6 +136,9 @@ type GeoIPConfig struct {
 	Service string `json:"service"` // "ipapi" or "ipgeolocation"
 	Cache   bool   `json:"cache"`   // Cache geolocation results
 	TTL     int    `json:"ttl"`     // Cache TTL in seconds
+	// Overrides is a JSON file mapping IPs or CIDRs to fixed labels, consulted
+	// before the service and reloaded when it changes
+	Overrides string `json:"overrides,omitempty"`
 }
 
 // DefaultConfig returns a default configuration
@@ -72,6 +155,9 @@ func DefaultConfig() *Config {
 		Debug:    false,
 		LogLevel: "info",
 		Timeout:  30,
+		StateDir: "/var/lib/fail2ban-notify",
+		Spool:    DefaultSpoolConfig(),
+		Store:    DefaultStoreConfig(),
 	}
 }
 
@@ -89,8 +175,8 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := parseConfig(data, config); err != nil {
+		return nil, err
 	}
 
 	// Validate configuration
@@ -101,6 +187,16 @@ func LoadConfig(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// parseConfig decodes a configuration file over config and loads the
+// profiles kept in separate files
+func parseConfig(data []byte, config *Config) error {
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return loadProfileDir(config)
+}
+
 // SaveConfig saves configuration to file
 func SaveConfig(configPath string, config *Config) error {
 	// Ensure directory exists
@@ -109,7 +205,7 @@ func SaveConfig(configPath string, config *Config) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := json.MarshalIndent(withoutDirProfiles(config), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -122,7 +218,7 @@ func SaveConfig(configPath string, config *Config) error {
 }
 
 // validateConnector validates a single connector configuration
-func validateConnector(_ *Config, i int, connector *ConnectorConfig) error {
+func validateConnector(config *Config, i int, connector *ConnectorConfig) error {
 	if connector.Name == "" {
 		return fmt.Errorf("connector[%d]: name cannot be empty", i)
 	}
@@ -140,48 +236,147 @@ func validateConnector(_ *Config, i int, connector *ConnectorConfig) error {
 		}
 	}
 
-	if !isValidType {
-		return fmt.Errorf("connector[%d] (%s): invalid type '%s', must be '%s', '%s', or '%s'",
-			i, connector.Name, connector.Type, ConnectorTypeScript, ConnectorTypeExecutable, ConnectorTypeHTTP)
+	if validate, ok := nativeConnectorTypes[connector.Type]; ok {
+		// With recipients, the settings only need to be complete per recipient
+		err := validate(connector)
+		if len(connector.Recipients) > 0 {
+			err = validateRecipients(connector, validate)
+		}
+		if err != nil {
+			return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+		}
+	} else if !isValidType {
+		return fmt.Errorf("connector[%d] (%s): invalid type '%s', must be '%s', '%s', '%s' or a built-in type (%s)",
+			i, connector.Name, connector.Type, ConnectorTypeScript, ConnectorTypeExecutable, ConnectorTypeHTTP,
+			strings.Join(NativeConnectorTypes(), ", "))
 	}
 
-	if connector.Type != ConnectorTypeHTTP && connector.Path == "" {
+	if isValidType && connector.Type != ConnectorTypeHTTP && connector.Path == "" {
 		return fmt.Errorf("connector[%d] (%s): path cannot be empty for type '%s'", i, connector.Name, connector.Type)
 	}
 
-	if connector.Type == ConnectorTypeHTTP {
-		if _, ok := connector.Settings["url"]; !ok {
-			return fmt.Errorf("connector[%d] (%s): HTTP connector must have 'url' setting", i, connector.Name)
+	if isValidType {
+		err := validateHTTPSettings(connector)
+		if len(connector.Recipients) > 0 {
+			err = validateRecipients(connector, validateHTTPSettings)
+		}
+		if err != nil {
+			return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+		}
+	}
+
+	if !sanitize.ValidMode(connector.Escape) {
+		return fmt.Errorf("connector[%d] (%s): invalid escape mode '%s'", i, connector.Name, connector.Escape)
+	}
+
+	if connector.Throttle != nil && connector.Throttle.MaxMessages <= 0 {
+		return fmt.Errorf("connector[%d] (%s): throttle max_messages must be positive", i, connector.Name)
+	}
+
+	if connector.Template != nil {
+		if err := connector.Template.parse(config); err != nil {
+			return fmt.Errorf("connector[%d] (%s): template: %w", i, connector.Name, err)
+		}
+	}
+
+	if connector.Expect != nil {
+		if connector.Type != ConnectorTypeHTTP {
+			return fmt.Errorf("connector[%d] (%s): expect is only supported by '%s' connectors", i, connector.Name, ConnectorTypeHTTP)
+		}
+		if err := connector.Expect.parse(); err != nil {
+			return fmt.Errorf("connector[%d] (%s): expect: %w", i, connector.Name, err)
+		}
+	}
+
+	if err := validateArgs(config, connector); err != nil {
+		return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+	}
+
+	if connector.Payload != nil {
+		if err := connector.Payload.validate(); err != nil {
+			return fmt.Errorf("connector[%d] (%s): payload: %w", i, connector.Name, err)
+		}
+	}
+
+	if err := validateProbe(connector); err != nil {
+		return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+	}
+
+	if err := validateNetworkPolicy(connector); err != nil {
+		return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+	}
+
+	if err := validateSecretRefs(config, connector.Settings); err != nil {
+		return fmt.Errorf("connector[%d] (%s): %w", i, connector.Name, err)
+	}
+	for j := range connector.Recipients {
+		if err := validateSecretRefs(config, connector.Recipients[j].Settings); err != nil {
+			return fmt.Errorf("connector[%d] (%s): %s: %w", i, connector.Name, connector.Recipients[j].Label(j), err)
 		}
 	}
 
+	if connector.SELinuxContext != "" && !lsm.ValidContext(connector.SELinuxContext) {
+		return fmt.Errorf("connector[%d] (%s): invalid selinux_context '%s', must be user:role:type[:level]",
+			i, connector.Name, connector.SELinuxContext)
+	}
+
 	return nil
 }
 
-// validateGeoIPConfig validates the GeoIP configuration
-func validateGeoIPConfig(config *Config) {
-	// Validate GeoIP config
-	if config.GeoIP.Service != GeoIPServiceIPAPI && config.GeoIP.Service != GeoIPServiceIPGeolocation {
-		config.GeoIP.Service = GeoIPServiceIPAPI
+// ScriptContext returns the SELinux context a connector's script runs in, or
+// an empty string to run it in the notifier's own context
+func (c *Config) ScriptContext(connector *ConnectorConfig) string {
+	if connector.SELinuxContext != "" {
+		return connector.SELinuxContext
 	}
+	return c.SELinuxContext
+}
 
-	if config.GeoIP.TTL <= 0 {
-		config.GeoIP.TTL = 3600
+// validateHTTPSettings checks that an HTTP connector has a URL
+func validateHTTPSettings(connector *ConnectorConfig) error {
+	if connector.Type != ConnectorTypeHTTP {
+		return nil
 	}
+	target, ok := connector.Settings["url"]
+	if !ok {
+		return fmt.Errorf("HTTP connector must have 'url' setting")
+	}
+	if strings.HasPrefix(target, UnixScheme) {
+		if socket, _ := SplitUnixURL(target); !filepath.IsAbs(socket) {
+			return fmt.Errorf("invalid url '%s', must be unix:///path/to/socket[:/request/path]", target)
+		}
+	}
+	if transport := connector.Settings["transport"]; transport != "" && transport != TransportH2C {
+		return fmt.Errorf("invalid transport '%s', must be '%s'", transport, TransportH2C)
+	}
+	return ValidateCompression(connector.Settings["compression"])
 }
 
-// ValidateConfig validates the configuration
-func ValidateConfig(config *Config) error {
-	if config.ConnectorPath == "" {
-		return fmt.Errorf("connector_path cannot be empty")
+// SplitUnixURL splits unix:///run/hook.sock:/path?query into the socket path
+// and the request path, which defaults to /
+func SplitUnixURL(target string) (socket, path string) {
+	socket = strings.TrimPrefix(target, UnixScheme)
+	path = "/"
+	if i := strings.IndexAny(socket, ":?"); i >= 0 {
+		socket, path = socket[:i], strings.TrimPrefix(socket[i:], ":")
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
 	}
+	return socket, path
+}
 
-	if config.Timeout <= 0 {
-		config.Timeout = 30
+// ValidateCompression checks a compression setting, which is empty or gzip
+func ValidateCompression(value string) error {
+	if value != "" && value != CompressionGzip {
+		return fmt.Errorf("invalid compression '%s', must be '%s'", value, CompressionGzip)
 	}
+	return nil
+}
 
-	// Validate each connector
-	for i, connector := range config.Connectors {
+// validateConnectors validates a list of connectors and fills in default values
+func validateConnectors(config *Config, connectors []ConnectorConfig) error {
+	for i, connector := range connectors {
 		connectorCopy := connector // Create a local copy to avoid memory aliasing
 		if err := validateConnector(config, i, &connectorCopy); err != nil {
 			return err
@@ -189,24 +384,219 @@ func ValidateConfig(config *Config) error {
 
 		// Set default values
 		if connector.Timeout <= 0 {
-			config.Connectors[i].Timeout = config.Timeout
+			connectors[i].Timeout = config.Timeout
 		}
 
 		if connector.RetryCount < 0 {
-			config.Connectors[i].RetryCount = 0
+			connectors[i].RetryCount = 0
 		}
 
 		if connector.RetryDelay <= 0 {
-			config.Connectors[i].RetryDelay = 5
+			connectors[i].RetryDelay = 5
 		}
+
+		if connector.Throttle != nil && connector.Throttle.Window <= 0 {
+			connectors[i].Throttle.Window = 300
+		}
+	}
+
+	return nil
+}
+
+// validateGeoIPConfig validates the GeoIP configuration
+func validateGeoIPConfig(geo *GeoIPConfig) {
+	// Validate GeoIP config
+	if geo.Service != GeoIPServiceIPAPI && geo.Service != GeoIPServiceIPGeolocation {
+		geo.Service = GeoIPServiceIPAPI
+	}
+
+	if geo.TTL <= 0 {
+		geo.TTL = 3600
+	}
+}
+
+// ValidateConfig validates the configuration
+func ValidateConfig(config *Config) error {
+	if config.ConnectorPath == "" {
+		return fmt.Errorf("connector_path cannot be empty")
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 30
+	}
+
+	if config.StateDir == "" {
+		config.StateDir = DefaultConfig().StateDir
+	}
+
+	if config.SELinuxContext != "" && !lsm.ValidContext(config.SELinuxContext) {
+		return fmt.Errorf("invalid selinux_context '%s', must be user:role:type[:level]", config.SELinuxContext)
+	}
+
+	// Resolve the locale before the templates using it are parsed
+	locale, ok := humanize.Lookup(config.Locale)
+	if !ok {
+		return fmt.Errorf("unsupported locale '%s', supported: %s", config.Locale, strings.Join(humanize.Names(), ", "))
+	}
+	config.locale = &locale
+	if err := loadPartials(config); err != nil {
+		return err
+	}
+
+	if err := validateSecretsConfig(config); err != nil {
+		return err
+	}
+
+	// Validate each connector
+	if err := validateConnectors(config, config.Connectors); err != nil {
+		return err
+	}
+
+	// Validate failover groups
+	if err := validateGroups(config); err != nil {
+		return err
+	}
+
+	// Validate the delivery quorum and escalation
+	if err := validateDeliveryConfig(config); err != nil {
+		return err
 	}
 
 	// Validate GeoIP configuration
-	validateGeoIPConfig(config)
+	validateGeoIPConfig(&config.GeoIP)
+
+	// Validate profiles
+	if err := validateProfiles(config); err != nil {
+		return err
+	}
+
+	// Validate API tokens
+	if err := validateAPIConfig(&config.API); err != nil {
+		return err
+	}
+
+	// Validate spool limits
+	if err := validateSpoolConfig(config); err != nil {
+		return err
+	}
+
+	// Validate observer endpoint
+	if err := validateObserverConfig(&config.Observer); err != nil {
+		return err
+	}
+
+	// Validate log artifact bundling and the redaction of log lines
+	if err := validateArtifactConfig(config); err != nil {
+		return err
+	}
+	if err := validateRedactionConfig(&config.Redaction); err != nil {
+		return err
+	}
+
+	// Validate anonymity detection and the routing rules using it
+	if err := validateAnonymityConfig(&config.Anonymity); err != nil {
+		return err
+	}
+	if err := validateProxyConfig(&config.Proxy); err != nil {
+		return err
+	}
+	if err := validateTargetHostConfig(&config.TargetHost); err != nil {
+		return err
+	}
+	if err := validateRoutingConfig(config); err != nil {
+		return err
+	}
+	if err := validateTransforms(config); err != nil {
+		return err
+	}
+
+	// Validate per-jail message templates and labels
+	if err := validateTemplates(config); err != nil {
+		return err
+	}
+	if err := validateLabels(config); err != nil {
+		return err
+	}
+
+	// Validate passive DNS lookups
+	if err := validatePassiveDNSConfig(&config.PassiveDNS); err != nil {
+		return err
+	}
+
+	// Validate risk scoring
+	if err := validateRiskConfig(&config.Risk); err != nil {
+		return err
+	}
+
+	// Validate the countries whose bans raise credential stuffing alerts
+	if err := validateTrustedCountriesConfig(config); err != nil {
+		return err
+	}
+
+	// Validate honeypot correlation
+	if err := validateHoneypotConfig(&config.Honeypot); err != nil {
+		return err
+	}
+
+	// Validate successful login correlation
+	if err := validateLoginsConfig(&config.Logins); err != nil {
+		return err
+	}
+
+	// Validate daemon settings
+	if err := validateDaemonConfig(&config.Daemon); err != nil {
+		return err
+	}
+
+	// Validate event store and what is derived from it
+	validateStoreConfig(config)
+	if err := validateChainConfig(config); err != nil {
+		return err
+	}
+	if err := validateEncryptionConfig(config); err != nil {
+		return err
+	}
+	if err := validateRBLConfig(config); err != nil {
+		return err
+	}
+	if err := validateSurgeConfig(config); err != nil {
+		return err
+	}
+	if err := validateBulkUnbanConfig(&config.BulkUnban); err != nil {
+		return err
+	}
+	if err := validateCampaignConfig(config); err != nil {
+		return err
+	}
+	if err := validateHeatmapConfig(config); err != nil {
+		return err
+	}
+	if err := validateAckConfig(config); err != nil {
+		return err
+	}
+	if err := validateSLAConfig(config); err != nil {
+		return err
+	}
+	if err := validatePrivacyConfig(config); err != nil {
+		return err
+	}
+
+	// Validate chat commands
+	if err := validateChatOpsConfig(config); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// Humanize returns the locale formatting numbers and dates for people
+func (c *Config) Humanize() humanize.Locale {
+	if c.locale == nil {
+		return humanize.Default()
+	}
+	return *c.locale
+}
+
 // GetEnabledConnectors returns only enabled connectors
 func (c *Config) GetEnabledConnectors() []ConnectorConfig {
 	var enabled []ConnectorConfig
@@ -311,16 +701,16 @@ func createSlackConnector() ConnectorConfig {
 	}
 }
 
-// createTelegramConnector creates a sample Telegram connector
+// createTelegramConnector creates a sample Telegram connector, using the
+// built-in telegram type
 func createTelegramConnector() ConnectorConfig {
 	return ConnectorConfig{
 		Name:    "telegram",
-		Type:    ConnectorTypeScript,
+		Type:    "telegram",
 		Enabled: false,
-		Path:    "/etc/fail2ban/connectors/telegram.sh",
 		Settings: map[string]string{
-			"TELEGRAM_BOT_TOKEN": "YOUR_BOT_TOKEN",
-			"TELEGRAM_CHAT_ID":   "YOUR_CHAT_ID",
+			"bot_token": "YOUR_BOT_TOKEN",
+			"chat_id":   "YOUR_CHAT_ID",
 		},
 		Timeout:     30,
 		RetryCount:  2,
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Container mode settings
+const (
+	EnvContainer           = "F2BN_CONTAINER" // Set to true to enable container mode
+	ContainerConfigPath    = "/tmp/fail2ban-notify.json"
+	ContainerDefaultListen = ":8080"
+	ContainerDefaultSocket = DefaultDaemonSocket
+)
+
+// ContainerMode reports whether container mode is requested by the environment
+func ContainerMode() bool {
+	switch os.Getenv(EnvContainer) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// LoadContainerConfig builds the configuration for container mode: the file
+// at configPath is used as a base if it exists, F2BN_* environment variables
+// are applied on top, and the result is written to ContainerConfigPath so
+// other commands run inside the container see the same configuration.
+func LoadContainerConfig(configPath string) (*Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err == nil {
+		if err := parseConfig(data, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ApplyEnv(config, os.Environ()); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	// The health endpoint and event socket are on by default in containers
+	if config.Daemon.Listen == "" {
+		config.Daemon.Listen = ContainerDefaultListen
+	}
+	if config.Daemon.Socket == "" && config.Daemon.TailLog == "" {
+		config.Daemon.Socket = ContainerDefaultSocket
+	}
+
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if err := SaveConfig(ContainerConfigPath, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultDaemonSocket is the conventional path of the daemon's event socket
+const DefaultDaemonSocket = "/run/fail2ban-notify/notify.sock"
+
+// DaemonConfig contains settings for the long-running daemon mode
+type DaemonConfig struct {
+	TailLog       string          `json:"tail_log,omitempty"`       // fail2ban log file to follow for ban/unban lines
+	TailPattern   string          `json:"tail_pattern,omitempty"`   // Custom regex with named groups jail, action and ip
+	PollInterval  int             `json:"poll_interval,omitempty"`  // Log poll interval in milliseconds (default: 1000)
+	QueueSize     int             `json:"queue_size,omitempty"`     // Events buffered before sources block (default: 1000)
+	Socket        string          `json:"socket,omitempty"`         // Unix socket receiving events from fail2ban-notify -socket
+	SocketGroup   string          `json:"socket_group,omitempty"`   // Group allowed to send events on the socket
+	Listen        string          `json:"listen,omitempty"`         // HTTP address for /healthz and the API, e.g. ":8080"
+	User          string          `json:"user,omitempty"`           // Unprivileged user the daemon switches to when started as root
+	ProbeInterval int             `json:"probe_interval,omitempty"` // Seconds between connector health probes, 0 disables them
+	SyncBans      bool            `json:"sync_bans,omitempty"`      // Reconcile the store's active bans with fail2ban-client at start and periodically
+	RestartGrace  int             `json:"restart_grace,omitempty"`  // Seconds after a fail2ban restart whose restored bans are summarized, 0 disables it
+	JournalUnit   string          `json:"journal_unit,omitempty"`   // systemd unit whose journal reveals fail2ban restarts, e.g. "fail2ban.service"
+	TLS           DaemonTLSConfig `json:"tls"`
+}
+
+// DaemonTLSConfig enables mutual TLS on the daemon's HTTP listener
+type DaemonTLSConfig struct {
+	CA   string `json:"ca,omitempty"`   // CA certificate clients must be signed by
+	Cert string `json:"cert,omitempty"` // Server certificate
+	Key  string `json:"key,omitempty"`  // Server key
+}
+
+// Enabled reports whether TLS is configured
+func (t *DaemonTLSConfig) Enabled() bool {
+	return t.Cert != ""
+}
+
+// validateDaemonConfig validates the daemon configuration and fills in defaults
+func validateDaemonConfig(daemon *DaemonConfig) error {
+	if daemon.TailLog != "" && !filepath.IsAbs(daemon.TailLog) {
+		return fmt.Errorf("daemon: tail_log must be an absolute path: %s", daemon.TailLog)
+	}
+
+	if daemon.TailPattern != "" {
+		if _, err := regexp.Compile(daemon.TailPattern); err != nil {
+			return fmt.Errorf("daemon: invalid tail_pattern: %w", err)
+		}
+	}
+
+	if daemon.PollInterval <= 0 {
+		daemon.PollInterval = 1000
+	}
+
+	if daemon.QueueSize <= 0 {
+		daemon.QueueSize = 1000
+	}
+
+	if daemon.ProbeInterval < 0 {
+		return fmt.Errorf("daemon: probe_interval cannot be negative")
+	}
+
+	if daemon.RestartGrace < 0 {
+		return fmt.Errorf("daemon: restart_grace cannot be negative")
+	}
+	if daemon.JournalUnit != "" && daemon.RestartGrace == 0 {
+		return fmt.Errorf("daemon: journal_unit requires restart_grace")
+	}
+
+	if daemon.Socket != "" && !filepath.IsAbs(daemon.Socket) {
+		return fmt.Errorf("daemon: socket must be an absolute path: %s", daemon.Socket)
+	}
+
+	if daemon.Listen != "" {
+		if _, _, err := net.SplitHostPort(daemon.Listen); err != nil {
+			return fmt.Errorf("daemon: invalid listen address: %w", err)
+		}
+	}
+
+	tls := daemon.TLS
+	if tls.CA != "" || tls.Cert != "" || tls.Key != "" {
+		if tls.CA == "" || tls.Cert == "" || tls.Key == "" {
+			return fmt.Errorf("daemon: tls requires ca, cert and key")
+		}
+	}
+
+	return nil
+}
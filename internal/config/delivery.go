@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// DeliveryConfig decides when a run counts as delivered and what happens
+// when it does not
+type DeliveryConfig struct {
+	// Quorum is the number of connectors that must succeed; 0 requires all
+	Quorum int `json:"quorum,omitempty"`
+	// CriticalJails are jail names or glob patterns escalated on a missed quorum
+	CriticalJails []string `json:"critical_jails,omitempty"`
+	// Escalation lists the connectors notified when the quorum of a critical
+	// jail is missed. They are kept out of normal delivery.
+	Escalation []string `json:"escalation,omitempty"`
+	// Sequential runs the connectors one at a time in the order they are
+	// configured instead of all at once
+	Sequential bool `json:"sequential,omitempty"`
+}
+
+// IsCritical reports whether a missed quorum for the jail is escalated
+func (d *DeliveryConfig) IsCritical(jail string) bool {
+	return len(d.Escalation) > 0 && matchesPattern(d.CriticalJails, jail)
+}
+
+// validateDeliveryConfig checks the quorum and escalation settings
+func validateDeliveryConfig(config *Config) error {
+	delivery := &config.Delivery
+
+	if delivery.Quorum < 0 {
+		return fmt.Errorf("delivery: quorum cannot be negative")
+	}
+
+	for _, pattern := range delivery.CriticalJails {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("delivery: invalid jail pattern '%s': %w", pattern, err)
+		}
+	}
+
+	if len(delivery.CriticalJails) > 0 && len(delivery.Escalation) == 0 {
+		return fmt.Errorf("delivery: critical_jails requires escalation connectors")
+	}
+	for _, name := range delivery.Escalation {
+		if _, found := config.GetConnectorByName(name); !found {
+			return fmt.Errorf("delivery: unknown escalation connector '%s'", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of environment variables that override the
+// configuration, e.g. F2BN_CONNECTORS_0_TYPE=http or F2BN_GEOIP_ENABLED=false
+const EnvPrefix = "F2BN_"
+
+// ApplyEnv applies F2BN_* variables from environ (as returned by os.Environ)
+// to config. Variable names are the upper-cased JSON paths of the setting,
+// joined by underscores: list elements are addressed by index and map keys
+// are taken as written, reusing an existing key that differs only in case
+// (F2BN_CONNECTORS_0_SETTINGS_DISCORD_WEBHOOK_URL). Lists take
+// comma-separated values.
+func ApplyEnv(config *Config, environ []string) error {
+	var keys []string
+	values := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || name == EnvContainer {
+			continue
+		}
+		keys = append(keys, name)
+		values[name] = value
+	}
+
+	// Apply in order so list elements are created by ascending index
+	sort.Slice(keys, func(i, j int) bool {
+		return envLess(keys[i], keys[j])
+	})
+
+	for _, name := range keys {
+		path := strings.TrimPrefix(name, EnvPrefix)
+		if err := setEnvPath(reflect.ValueOf(config).Elem(), path, values[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setEnvPath sets the field addressed by path within v
+func setEnvPath(v reflect.Value, path, value string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setEnvPath(v.Elem(), path, value)
+
+	case reflect.Struct:
+		field, rest, ok := envField(v, path)
+		if !ok {
+			return fmt.Errorf("unknown setting %s", path)
+		}
+		if rest == "" {
+			return setEnvValue(field, value)
+		}
+		return setEnvPath(field, rest, value)
+
+	case reflect.Slice:
+		indexStr, rest, _ := strings.Cut(path, "_")
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid list index %s", indexStr)
+		}
+		if index > v.Len() {
+			return fmt.Errorf("list index %d skips elements (next is %d)", index, v.Len())
+		}
+		if index == v.Len() {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		if rest == "" {
+			return setEnvValue(v.Index(index), value)
+		}
+		return setEnvPath(v.Index(index), rest, value)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("setting cannot be set from the environment")
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := path
+		for _, existing := range v.MapKeys() {
+			if strings.EqualFold(existing.String(), path) {
+				key = existing.String()
+				break
+			}
+		}
+		v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown setting %s", path)
+	}
+}
+
+// envField finds the exported struct field whose JSON name is the longest
+// case-insensitive prefix of path, returning the field and the remaining path
+func envField(v reflect.Value, path string) (reflect.Value, string, bool) {
+	var (
+		best     reflect.Value
+		bestRest string
+		bestLen  = -1
+	)
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		var rest string
+		switch {
+		case strings.EqualFold(path, name):
+		case len(path) > len(name) && path[len(name)] == '_' && strings.EqualFold(path[:len(name)], name):
+			rest = path[len(name)+1:]
+		default:
+			continue
+		}
+
+		if len(name) > bestLen {
+			best, bestRest, bestLen = v.Field(i), rest, len(name)
+		}
+	}
+
+	return best, bestRest, bestLen >= 0
+}
+
+// setEnvValue parses value into a scalar or list field
+func setEnvValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("setting requires an index")
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("setting cannot be set from the environment")
+	}
+	return nil
+}
+
+// envLess orders variable names, comparing numeric path segments by value
+func envLess(a, b string) bool {
+	as, bs := strings.Split(a, "_"), strings.Split(b, "_")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		if aErr == nil && bErr == nil {
+			return an < bn
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
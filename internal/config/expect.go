@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ResponseExpectation validates the response of an HTTP connector, for APIs
+// that answer 200 with an error in the body. A response that doesn't meet it
+// is a failure and retried.
+type ResponseExpectation struct {
+	Status   []int  `json:"status,omitempty"`    // Accepted status codes (default: any below 400)
+	Body     string `json:"body,omitempty"`      // Regular expression the body must match
+	JSONPath string `json:"json_path,omitempty"` // Value that must be present and not false or null, e.g. "$.ok"
+	Equals   string `json:"equals,omitempty"`    // Text json_path's value must equal instead, e.g. "sent"
+
+	body *regexp.Regexp
+	path []jsonPathStep
+}
+
+// jsonPathStep is an object key or, if index is not -1, an array index
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+// Check validates a response status and body
+func (e *ResponseExpectation) Check(status int, body []byte) error {
+	// Parsed when the configuration is validated; don't skip checks if not
+	if (e.Body != "" && e.body == nil) || (e.JSONPath != "" && e.path == nil) {
+		if err := e.parse(); err != nil {
+			return err
+		}
+	}
+
+	if len(e.Status) > 0 {
+		if !containsInt(e.Status, status) {
+			return fmt.Errorf("unexpected status %d, expected %s", status, joinInts(e.Status))
+		}
+	} else if status >= 400 {
+		return fmt.Errorf("unexpected status %d", status)
+	}
+
+	if e.body != nil && !e.body.Match(body) {
+		return fmt.Errorf("response body does not match %s", e.Body)
+	}
+
+	if e.path == nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("response body is not JSON: %w", err)
+	}
+
+	value, ok := lookupJSONPath(doc, e.path)
+	if !ok {
+		return fmt.Errorf("response has no %s", e.JSONPath)
+	}
+	if e.Equals != "" {
+		if text := jsonText(value); text != e.Equals {
+			return fmt.Errorf("response %s is %s, expected %s", e.JSONPath, text, e.Equals)
+		}
+		return nil
+	}
+	if value == nil || value == false {
+		return fmt.Errorf("response %s is %s", e.JSONPath, jsonText(value))
+	}
+	return nil
+}
+
+// lookupJSONPath returns the value at path in a decoded JSON document
+func lookupJSONPath(doc interface{}, path []jsonPathStep) (interface{}, bool) {
+	value := doc
+	for _, step := range path {
+		if step.index >= 0 {
+			list, ok := value.([]interface{})
+			if !ok || step.index >= len(list) {
+				return nil, false
+			}
+			value = list[step.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// jsonText formats a JSON value for comparison: strings without quotes,
+// everything else as JSON
+func jsonText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// parseJSONPath parses a path such as "$.result.items[0].status". Keys
+// containing dots or brackets can be quoted: $["message.id"].
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest := strings.TrimPrefix(path, "$")
+	steps := []jsonPathStep{}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key")
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end], index: -1})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if key, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, jsonPathStep{key: key, index: -1})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index [%s]", inner)
+			}
+			steps = append(steps, jsonPathStep{index: index})
+		default:
+			return nil, fmt.Errorf("expected . or [ at %q", rest)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("path selects the whole document")
+	}
+	return steps, nil
+}
+
+// parse compiles the body expression and JSON path
+func (e *ResponseExpectation) parse() error {
+	for _, status := range e.Status {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid status %d", status)
+		}
+	}
+
+	var err error
+	if e.Body != "" {
+		if e.body, err = regexp.Compile(e.Body); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	}
+
+	if e.JSONPath != "" {
+		if e.path, err = parseJSONPath(e.JSONPath); err != nil {
+			return fmt.Errorf("invalid json_path %s: %w", e.JSONPath, err)
+		}
+	} else if e.Equals != "" {
+		return fmt.Errorf("equals requires json_path")
+	}
+	return nil
+}
+
+// containsInt reports whether list contains v
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// joinInts formats a list of numbers as "200, 201 or 202"
+func joinInts(list []int) string {
+	texts := make([]string, len(list))
+	for i, v := range list {
+		texts[i] = strconv.Itoa(v)
+	}
+	if len(texts) == 1 {
+		return texts[0]
+	}
+	return strings.Join(texts[:len(texts)-1], ", ") + " or " + texts[len(texts)-1]
+}
@@ -0,0 +1,61 @@
+package config
+
+import "fmt"
+
+// ConnectorGroup delivers an event through the first of its connectors that
+// succeeds, trying them in order, instead of sending it to all of them
+type ConnectorGroup struct {
+	Name       string   `json:"name"`
+	Connectors []string `json:"connectors"` // Member connector names, in failover order
+}
+
+// GetGroupByName returns a connector group by name
+func (c *Config) GetGroupByName(name string) (*ConnectorGroup, bool) {
+	for i := range c.Groups {
+		if c.Groups[i].Name == name {
+			return &c.Groups[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateGroups checks that groups have unique names and name known
+// connectors, each belonging to at most one group
+func validateGroups(config *Config) error {
+	known := make(map[string]bool)
+	for _, connector := range config.Connectors {
+		known[connector.Name] = true
+	}
+	for _, profile := range config.Profiles {
+		for _, connector := range profile.Connectors {
+			known[connector.Name] = true
+		}
+	}
+
+	names := make(map[string]bool)
+	member := make(map[string]string)
+	for i, group := range config.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("groups[%d]: name cannot be empty", i)
+		}
+		if names[group.Name] || known[group.Name] {
+			return fmt.Errorf("groups[%d]: name '%s' is already used by a group or connector", i, group.Name)
+		}
+		names[group.Name] = true
+
+		if len(group.Connectors) == 0 {
+			return fmt.Errorf("groups[%d] (%s): connectors cannot be empty", i, group.Name)
+		}
+		for _, connector := range group.Connectors {
+			if !known[connector] {
+				return fmt.Errorf("groups[%d] (%s): unknown connector '%s'", i, group.Name, connector)
+			}
+			if other, ok := member[connector]; ok {
+				return fmt.Errorf("groups[%d] (%s): connector '%s' is already in group '%s'", i, group.Name, connector, other)
+			}
+			member[connector] = group.Name
+		}
+	}
+
+	return nil
+}
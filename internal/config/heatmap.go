@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// HeatmapConfig controls the ban map: a heatmap image and a GeoJSON layer
+// of where banned addresses are located, built from the event store
+type HeatmapConfig struct {
+	Enabled  bool    `json:"enabled"`
+	Dir      string  `json:"dir,omitempty"`      // Default: <state_dir>/heatmap
+	Days     int     `json:"days,omitempty"`     // Bans of the last N days (default: 7)
+	Interval int     `json:"interval,omitempty"` // Seconds between regenerations by the daemon (default: 3600)
+	Width    int     `json:"width,omitempty"`    // Image width in pixels, the height is half (default: 1024)
+	Cell     float64 `json:"cell,omitempty"`     // Grid cell size of the GeoJSON layer in degrees (default: 1)
+}
+
+// validateHeatmapConfig validates the ban map settings and fills in defaults
+func validateHeatmapConfig(config *Config) error {
+	heatmap := &config.Heatmap
+
+	if heatmap.Dir == "" {
+		heatmap.Dir = filepath.Join(config.StateDir, "heatmap")
+	}
+	if heatmap.Days <= 0 {
+		heatmap.Days = 7
+	}
+	if heatmap.Interval <= 0 {
+		heatmap.Interval = 3600
+	}
+	if heatmap.Width == 0 {
+		heatmap.Width = 1024
+	}
+	if heatmap.Width < 256 || heatmap.Width > 8192 {
+		return fmt.Errorf("heatmap: width must be between 256 and 8192")
+	}
+	if heatmap.Cell == 0 {
+		heatmap.Cell = 1
+	}
+	if heatmap.Cell < 0.1 || heatmap.Cell > 45 {
+		return fmt.Errorf("heatmap: cell must be between 0.1 and 45 degrees")
+	}
+
+	if heatmap.Enabled && !config.Store.Enabled {
+		return fmt.Errorf("heatmap: requires the event store")
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// HoneypotConfig controls correlation of banned IPs with local honeypot logs
+type HoneypotConfig struct {
+	Enabled bool             `json:"enabled"`
+	Window  int              `json:"window"` // Seconds of honeypot history to search (default: 30 days)
+	Sources []HoneypotSource `json:"sources"`
+}
+
+// HoneypotSource is a honeypot log to search
+type HoneypotSource struct {
+	Name   string `json:"name"`   // Shown in debug logs, defaults to the format
+	Format string `json:"format"` // "cowrie" or "opencanary"
+	Path   string `json:"path"`   // Log file; may be a glob to include rotated (.gz) files
+}
+
+// validateHoneypotConfig validates the honeypot configuration and fills in defaults
+func validateHoneypotConfig(honeypot *HoneypotConfig) error {
+	if honeypot.Window <= 0 {
+		honeypot.Window = 30 * 86400
+	}
+
+	for i := range honeypot.Sources {
+		source := &honeypot.Sources[i]
+		if source.Format == "" {
+			return fmt.Errorf("honeypot: sources[%d]: format cannot be empty", i)
+		}
+		if source.Name == "" {
+			source.Name = source.Format
+		}
+		if !filepath.IsAbs(source.Path) {
+			return fmt.Errorf("honeypot: source %s: path must be absolute: %s", source.Name, source.Path)
+		}
+		if _, err := filepath.Match(source.Path, ""); err != nil {
+			return fmt.Errorf("honeypot: source %s: invalid path pattern: %w", source.Name, err)
+		}
+	}
+
+	if honeypot.Enabled && len(honeypot.Sources) == 0 {
+		return fmt.Errorf("honeypot: enabled but no sources configured")
+	}
+
+	return nil
+}
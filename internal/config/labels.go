@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// labelKey restricts label keys to characters valid in environment variable names
+var labelKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LabelsForJail returns the labels attached to events of a jail: the global
+// labels, overridden by matching jail patterns in alphabetical order, then by
+// the labels of the exact jail name. It returns nil if there are none.
+func (c *Config) LabelsForJail(jail string) map[string]string {
+	if len(c.Labels) == 0 && len(c.JailLabels) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(c.Labels))
+	for key, value := range c.Labels {
+		labels[key] = value
+	}
+
+	patterns := make([]string, 0, len(c.JailLabels))
+	for pattern := range c.JailLabels {
+		if pattern != jail {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range append(patterns, jail) {
+		if matched, _ := path.Match(pattern, jail); !matched {
+			continue
+		}
+		for key, value := range c.JailLabels[pattern] {
+			labels[key] = value
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// validateLabels checks the label keys and jail patterns
+func validateLabels(config *Config) error {
+	for key := range config.Labels {
+		if !labelKey.MatchString(key) {
+			return fmt.Errorf("labels: invalid key '%s': use letters, digits and underscores", key)
+		}
+	}
+
+	for pattern, labels := range config.JailLabels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("jail_labels: invalid jail pattern '%s': %w", pattern, err)
+		}
+		for key := range labels {
+			if !labelKey.MatchString(key) {
+				return fmt.Errorf("jail_labels: %s: invalid key '%s': use letters, digits and underscores", pattern, key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// LoginsConfig controls correlation of bans with successful logins. A login
+// from the banned IP or its network shortly before the ban may mean the
+// attacker got in, so such bans are flagged as a possible breach.
+type LoginsConfig struct {
+	Enabled    bool          `json:"enabled"`
+	Window     int           `json:"window"`      // Seconds before the ban to search (default: 1 hour)
+	IPv4Prefix int           `json:"ipv4_prefix"` // Network of a banned IPv4 address searched (default: 24)
+	IPv6Prefix int           `json:"ipv6_prefix"` // Network of a banned IPv6 address searched (default: 64)
+	Sources    []LoginSource `json:"sources"`
+}
+
+// LoginSource is a record of successful logins to search
+type LoginSource struct {
+	Name    string            `json:"name"`              // Shown in logs and notifications, defaults to the format
+	Format  string            `json:"format"`            // "auth_log", "wtmp" or "webhook"
+	Path    string            `json:"path,omitempty"`    // Log file; may be a glob to include rotated (.gz) files
+	URL     string            `json:"url,omitempty"`     // Endpoint of a webhook source
+	Headers map[string]string `json:"headers,omitempty"` // Sent to the webhook, e.g. an Authorization header
+	Timeout int               `json:"timeout,omitempty"` // Seconds to wait for the webhook (default: 5)
+}
+
+// validateLoginsConfig validates the login correlation settings and fills in
+// defaults
+func validateLoginsConfig(logins *LoginsConfig) error {
+	if logins.Window <= 0 {
+		logins.Window = 3600
+	}
+	if logins.IPv4Prefix == 0 {
+		logins.IPv4Prefix = 24
+	}
+	if logins.IPv4Prefix < 8 || logins.IPv4Prefix > 32 {
+		return fmt.Errorf("logins: ipv4_prefix must be between 8 and 32")
+	}
+	if logins.IPv6Prefix == 0 {
+		logins.IPv6Prefix = 64
+	}
+	if logins.IPv6Prefix < 16 || logins.IPv6Prefix > 128 {
+		return fmt.Errorf("logins: ipv6_prefix must be between 16 and 128")
+	}
+
+	for i := range logins.Sources {
+		source := &logins.Sources[i]
+		if source.Format == "" {
+			return fmt.Errorf("logins: sources[%d]: format cannot be empty", i)
+		}
+		if source.Name == "" {
+			source.Name = source.Format
+		}
+		if source.Timeout <= 0 {
+			source.Timeout = 5
+		}
+
+		if source.URL != "" {
+			parsed, err := url.Parse(source.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("logins: source %s: url must be an http or https URL: %s", source.Name, source.URL)
+			}
+			continue
+		}
+		if !filepath.IsAbs(source.Path) {
+			return fmt.Errorf("logins: source %s: path must be absolute: %s", source.Name, source.Path)
+		}
+		if _, err := filepath.Match(source.Path, ""); err != nil {
+			return fmt.Errorf("logins: source %s: invalid path pattern: %w", source.Name, err)
+		}
+	}
+
+	if logins.Enabled && len(logins.Sources) == 0 {
+		return fmt.Errorf("logins: enabled but no sources configured")
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// MergeConfig merges a partial configuration into the file at configPath.
+// Objects are merged key by key and lists of objects with a "name", such as
+// connectors, item by item; other values replace the existing ones, and null
+// removes a key. The merged configuration must be valid. It reports whether
+// the configuration changed; with dryRun the file is left untouched.
+func MergeConfig(configPath string, partial []byte, dryRun bool) (bool, error) {
+	raw, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		raw, err = json.Marshal(DefaultConfig())
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	base, err := decodeTree(raw)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	patch, err := decodeTree(partial)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse partial config: %w", err)
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return false, fmt.Errorf("partial config must be a JSON object")
+	}
+
+	merged, err := json.Marshal(mergeTree(base, patch))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	// Validate a separate copy: validation fills in defaults, which should
+	// not be written to the file
+	check := DefaultConfig()
+	if err := parseConfig(merged, check); err != nil {
+		return false, err
+	}
+	if err := ValidateConfig(check); err != nil {
+		return false, fmt.Errorf("merged configuration is invalid: %w", err)
+	}
+
+	current, updated := DefaultConfig(), DefaultConfig()
+	if err := parseConfig(raw, current); err != nil {
+		return false, err
+	}
+	if err := parseConfig(merged, updated); err != nil {
+		return false, err
+	}
+
+	before, err := json.Marshal(withoutDirProfiles(current))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	after, err := json.Marshal(withoutDirProfiles(updated))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	if dryRun {
+		return true, nil
+	}
+	return true, SaveConfig(configPath, updated)
+}
+
+// decodeTree decodes JSON into maps, slices and json.Number values
+func decodeTree(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// mergeTree merges patch into base and returns the result
+func mergeTree(base, patch interface{}) interface{} {
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			b = make(map[string]interface{})
+		}
+		for key, value := range p {
+			if value == nil {
+				delete(b, key)
+				continue
+			}
+			b[key] = mergeTree(b[key], value)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || !namedItems(b) || !namedItems(p) {
+			return p
+		}
+		for _, item := range p {
+			name := item.(map[string]interface{})["name"]
+			found := false
+			for i, existing := range b {
+				if existing.(map[string]interface{})["name"] == name {
+					b[i] = mergeTree(existing, item)
+					found = true
+					break
+				}
+			}
+			if !found {
+				b = append(b, item)
+			}
+		}
+		return b
+	default:
+		return patch
+	}
+}
+
+// namedItems reports whether every item of a list is an object with a
+// string "name"
+func namedItems(items []interface{}) bool {
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := object["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package config
+
+import "sort"
+
+// nativeConnectorTypes maps connector types implemented inside the binary to
+// a function validating their settings
+var nativeConnectorTypes = make(map[string]func(*ConnectorConfig) error)
+
+// RegisterConnectorType makes a built-in connector type valid in the
+// configuration. validate checks the connector's settings.
+func RegisterConnectorType(name string, validate func(*ConnectorConfig) error) {
+	nativeConnectorTypes[name] = validate
+}
+
+// NativeConnectorTypes returns the registered built-in connector types
+func NativeConnectorTypes() []string {
+	names := make([]string, 0, len(nativeConnectorTypes))
+	for name := range nativeConnectorTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsNativeConnectorType reports whether name is a built-in connector type
+func IsNativeConnectorType(name string) bool {
+	_, ok := nativeConnectorTypes[name]
+	return ok
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Network sandbox modes of script connectors
+const (
+	NetworkModeNone      = "none"      // No network access at all
+	NetworkModeAllowlist = "allowlist" // Only the allowed endpoints
+)
+
+// NetworkPolicy confines the network access of a script or executable
+// connector, so an untrusted script can only reach its declared endpoints.
+// The script runs in its own network namespace: without any network, or
+// connected through slirp4netns with an nftables egress allowlist.
+type NetworkPolicy struct {
+	Mode  string   `json:"mode"`            // "none" or "allowlist"
+	Allow []string `json:"allow,omitempty"` // Hosts, IPs or CIDRs with optional port, e.g. "hooks.slack.com:443"; default: the hosts of URL settings
+}
+
+// Endpoint is an allowed destination of a sandboxed connector
+type Endpoint struct {
+	Host string // Host name, IP or CIDR
+	Port int    // TCP port, 0 for any port and protocol
+}
+
+// Endpoints returns the destinations the connector may reach. Without an
+// allow list, these are the hosts of the http(s) URLs in its settings.
+func (p *NetworkPolicy) Endpoints(connector *ConnectorConfig) ([]Endpoint, error) {
+	if len(p.Allow) > 0 {
+		endpoints := make([]Endpoint, 0, len(p.Allow))
+		for _, entry := range p.Allow {
+			endpoint, err := parseEndpoint(entry)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+		return endpoints, nil
+	}
+
+	keys := make([]string, 0, len(connector.Settings))
+	for key := range connector.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var endpoints []Endpoint
+	for _, key := range keys {
+		u, err := url.Parse(connector.Settings[key])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			continue
+		}
+		port := 443
+		if u.Scheme == "http" {
+			port = 80
+		}
+		if u.Port() != "" {
+			port, _ = strconv.Atoi(u.Port())
+		}
+		endpoints = append(endpoints, Endpoint{Host: u.Hostname(), Port: port})
+	}
+	return endpoints, nil
+}
+
+// parseEndpoint parses "host", "host:port", "cidr" or "cidr:port"
+func parseEndpoint(entry string) (Endpoint, error) {
+	host, port := entry, 0
+	if strings.Count(entry, ":") == 1 || strings.HasPrefix(entry, "[") {
+		h, p, err := net.SplitHostPort(entry)
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("invalid endpoint '%s': %w", entry, err)
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return Endpoint{}, fmt.Errorf("invalid port in endpoint '%s'", entry)
+		}
+		host, port = h, n
+	}
+
+	// The sandbox network has no IPv6
+	if strings.Contains(host, "/") {
+		ip, _, err := net.ParseCIDR(host)
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("invalid network in endpoint '%s': %w", entry, err)
+		}
+		if ip.To4() == nil {
+			return Endpoint{}, fmt.Errorf("IPv6 endpoint '%s' is not supported", entry)
+		}
+	} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return Endpoint{}, fmt.Errorf("IPv6 endpoint '%s' is not supported", entry)
+	} else if host == "" || strings.ContainsAny(host, " \t\"'{};") {
+		return Endpoint{}, fmt.Errorf("invalid host in endpoint '%s'", entry)
+	}
+	return Endpoint{Host: host, Port: port}, nil
+}
+
+// validateNetworkPolicy checks the sandbox of a connector
+func validateNetworkPolicy(connector *ConnectorConfig) error {
+	policy := connector.Network
+	if policy == nil {
+		return nil
+	}
+	if connector.Type != ConnectorTypeScript && connector.Type != ConnectorTypeExecutable {
+		return fmt.Errorf("network: only script and executable connectors can be sandboxed")
+	}
+
+	switch policy.Mode {
+	case NetworkModeNone:
+		if len(policy.Allow) > 0 {
+			return fmt.Errorf("network: allow requires mode '%s'", NetworkModeAllowlist)
+		}
+	case NetworkModeAllowlist:
+		endpoints, err := policy.Endpoints(connector)
+		if err != nil {
+			return fmt.Errorf("network: %w", err)
+		}
+		if len(endpoints) == 0 {
+			return fmt.Errorf("network: allowlist needs allow entries or URL settings")
+		}
+	default:
+		return fmt.Errorf("network: invalid mode '%s', must be '%s' or '%s'", policy.Mode, NetworkModeNone, NetworkModeAllowlist)
+	}
+	return nil
+}
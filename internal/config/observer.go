@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ObserverConfig configures the endpoint that receives the result of every run
+type ObserverConfig struct {
+	URL          string            `json:"url,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Timeout      int               `json:"timeout,omitempty"`       // Timeout in seconds (default: 10)
+	FailuresOnly bool              `json:"failures_only,omitempty"` // Only report runs with failed connectors
+}
+
+// validateObserverConfig validates the observer endpoint
+func validateObserverConfig(observer *ObserverConfig) error {
+	if observer.URL == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(observer.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("observer: invalid url '%s'", observer.URL)
+	}
+
+	if observer.Timeout <= 0 {
+		observer.Timeout = 10
+	}
+
+	return nil
+}
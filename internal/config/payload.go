@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// PayloadFields selects the event fields a connector receives, e.g. only the
+// jail and country for a public status page. Keys are the JSON field names
+// of the event or "labels.<name>", as in transforms.
+type PayloadFields struct {
+	Include []string `json:"include,omitempty"` // Only these fields, plus action and time
+	Exclude []string `json:"exclude,omitempty"` // All fields but these
+	MaskIP  bool     `json:"mask_ip,omitempty"` // Send only the network part of the IP
+}
+
+// KeepsIP reports whether the IP is passed on, possibly masked
+func (p *PayloadFields) KeepsIP() bool {
+	if len(p.Include) > 0 && !containsString(p.Include, "ip") {
+		return false
+	}
+	return !containsString(p.Exclude, "ip")
+}
+
+// validate checks the field keys
+func (p *PayloadFields) validate() error {
+	if len(p.Include) > 0 && len(p.Exclude) > 0 {
+		return fmt.Errorf("include and exclude cannot be combined")
+	}
+	for _, key := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if !validTransformKey(key) {
+			return fmt.Errorf("invalid field '%s'", key)
+		}
+	}
+	for _, key := range []string{"action", "time"} {
+		if containsString(p.Exclude, key) {
+			return fmt.Errorf("%s cannot be excluded", key)
+		}
+	}
+	return nil
+}
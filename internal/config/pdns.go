@@ -0,0 +1,47 @@
+package config
+
+import "fmt"
+
+// Passive DNS sources
+const (
+	PassiveDNSMnemonic = "mnemonic"
+	PassiveDNSCIRCL    = "circl"
+)
+
+// PassiveDNSConfig enables looking up domains recently resolved to a
+// banned IP in a passive DNS database
+type PassiveDNSConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Service  string `json:"service"`            // "mnemonic" or "circl"
+	APIKey   string `json:"api_key,omitempty"`  // Mnemonic Argus API key (optional, raises rate limits)
+	Username string `json:"username,omitempty"` // CIRCL pDNS credentials
+	Password string `json:"password,omitempty"`
+	Limit    int    `json:"limit"` // Domains included in notifications (default: 3)
+	TTL      int    `json:"ttl"`   // Seconds to cache a result (default: 1 day)
+}
+
+// validatePassiveDNSConfig validates the passive DNS settings
+func validatePassiveDNSConfig(pdns *PassiveDNSConfig) error {
+	if pdns.Limit <= 0 {
+		pdns.Limit = 3
+	}
+	if pdns.TTL <= 0 {
+		pdns.TTL = 86400
+	}
+
+	if !pdns.Enabled {
+		return nil
+	}
+
+	switch pdns.Service {
+	case PassiveDNSMnemonic:
+	case PassiveDNSCIRCL:
+		if pdns.Username == "" || pdns.Password == "" {
+			return fmt.Errorf("passive_dns: username and password are required for circl")
+		}
+	default:
+		return fmt.Errorf("passive_dns: invalid service '%s', must be '%s' or '%s'", pdns.Service, PassiveDNSMnemonic, PassiveDNSCIRCL)
+	}
+
+	return nil
+}
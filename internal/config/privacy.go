@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Privacy modes
+const (
+	PrivacyModeMask = "mask" // Keep the network part: 1.2.3.x
+	PrivacyModeHash = "hash" // Replace the IP with its keyed hash
+)
+
+// PrivacyConfig pseudonymizes IPs in notifications and, optionally, in the
+// event store. Every event keeps a keyed HMAC of its IP as ip_hash, so the
+// bans of one address can still be correlated.
+type PrivacyConfig struct {
+	Mode       string `json:"mode,omitempty"`        // "mask" or "hash", empty to send IPs unchanged
+	KeyFile    string `json:"key_file,omitempty"`    // HMAC key, generated if missing (default: <state_dir>/privacy.key)
+	IPv4Prefix int    `json:"ipv4_prefix,omitempty"` // Bits of IPv4 addresses kept by mask: 8, 16 or 24 (default: 24)
+	IPv6Prefix int    `json:"ipv6_prefix,omitempty"` // Bits of IPv6 addresses kept by mask (default: 48)
+	Store      bool   `json:"store"`                 // Pseudonymize stored events and connector results too
+}
+
+// Enabled reports whether IPs are pseudonymized
+func (p *PrivacyConfig) Enabled() bool {
+	return p.Mode != ""
+}
+
+// validatePrivacyConfig validates the privacy settings and fills in defaults
+func validatePrivacyConfig(config *Config) error {
+	privacy := &config.Privacy
+
+	switch privacy.Mode {
+	case "":
+		return nil
+	case PrivacyModeMask, PrivacyModeHash:
+	default:
+		return fmt.Errorf("privacy: invalid mode '%s', must be '%s' or '%s'", privacy.Mode, PrivacyModeMask, PrivacyModeHash)
+	}
+
+	if privacy.KeyFile == "" {
+		privacy.KeyFile = filepath.Join(config.StateDir, "privacy.key")
+	}
+
+	if privacy.IPv4Prefix == 0 {
+		privacy.IPv4Prefix = 24
+	}
+	if privacy.IPv4Prefix != 8 && privacy.IPv4Prefix != 16 && privacy.IPv4Prefix != 24 {
+		return fmt.Errorf("privacy: ipv4_prefix must be 8, 16 or 24")
+	}
+
+	if privacy.IPv6Prefix == 0 {
+		privacy.IPv6Prefix = 48
+	}
+	if privacy.IPv6Prefix < 16 || privacy.IPv6Prefix > 112 {
+		return fmt.Errorf("privacy: ipv6_prefix must be between 16 and 112")
+	}
+
+	// The zone is built from the stored bans, which would hold pseudonyms
+	if privacy.Store && config.RBL.Enabled {
+		return fmt.Errorf("privacy: store cannot be combined with the RBL zone, which needs the banned IPs")
+	}
+
+	return nil
+}
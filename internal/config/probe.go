@@ -0,0 +1,45 @@
+package config
+
+import "fmt"
+
+// Connector health probes
+const (
+	ProbeHead        = "head"        // HTTP HEAD request to the connector's URL
+	ProbeOptions     = "options"     // HTTP OPTIONS request to the connector's URL
+	ProbeHealthcheck = "healthcheck" // Script run with --healthcheck
+	ProbeNone        = "none"
+)
+
+// ProbeMethod returns the health probe of the connector. HTTP connectors are
+// probed with HEAD by default; scripts only when they opt in, since older
+// scripts would treat --healthcheck as an event and send a notification.
+func (c *ConnectorConfig) ProbeMethod() string {
+	if c.Probe != "" {
+		return c.Probe
+	}
+	if c.Type == ConnectorTypeHTTP {
+		return ProbeHead
+	}
+	return ProbeNone
+}
+
+// validateProbe checks that the probe suits the connector's type
+func validateProbe(connector *ConnectorConfig) error {
+	switch connector.Probe {
+	case "", ProbeNone:
+		return nil
+	case ProbeHead, ProbeOptions:
+		if connector.Type != ConnectorTypeHTTP {
+			return fmt.Errorf("probe '%s' is only supported by '%s' connectors", connector.Probe, ConnectorTypeHTTP)
+		}
+	case ProbeHealthcheck:
+		if connector.Type != ConnectorTypeScript && connector.Type != ConnectorTypeExecutable {
+			return fmt.Errorf("probe '%s' is only supported by '%s' and '%s' connectors",
+				connector.Probe, ConnectorTypeScript, ConnectorTypeExecutable)
+		}
+	default:
+		return fmt.Errorf("invalid probe '%s', must be '%s', '%s', '%s' or '%s'",
+			connector.Probe, ProbeHead, ProbeOptions, ProbeHealthcheck, ProbeNone)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile is a named set of connectors and GeoIP settings that replaces the
+// top-level ones for the jails it is mapped to
+type Profile struct {
+	Jails      []string          `json:"jails,omitempty"` // Jail names or glob patterns routed to this profile
+	Connectors []ConnectorConfig `json:"connectors"`
+	GeoIP      *GeoIPConfig      `json:"geoip,omitempty"` // Optional GeoIP override
+}
+
+// loadProfileDir merges profiles from ProfileDir into the configuration.
+// Each <name>.json file holds a single profile.
+func loadProfileDir(config *Config) error {
+	if config.ProfileDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(config.ProfileDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profile directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		data, err := os.ReadFile(filepath.Join(config.ProfileDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read profile %s: %w", name, err)
+		}
+
+		profile := &Profile{}
+		if err := json.Unmarshal(data, profile); err != nil {
+			return fmt.Errorf("failed to parse profile %s: %w", name, err)
+		}
+
+		if config.Profiles == nil {
+			config.Profiles = make(map[string]*Profile)
+		}
+		if _, exists := config.Profiles[name]; exists {
+			return fmt.Errorf("profile %s is defined both in the config file and in %s", name, config.ProfileDir)
+		}
+		config.Profiles[name] = profile
+
+		if config.dirProfiles == nil {
+			config.dirProfiles = make(map[string]bool)
+		}
+		config.dirProfiles[name] = true
+	}
+
+	return nil
+}
+
+// withoutDirProfiles returns the configuration as it should be written back
+// to the config file, leaving out profiles that live in ProfileDir
+func withoutDirProfiles(config *Config) *Config {
+	if len(config.dirProfiles) == 0 {
+		return config
+	}
+
+	saved := *config
+	saved.Profiles = make(map[string]*Profile)
+	for name, profile := range config.Profiles {
+		if !config.dirProfiles[name] {
+			saved.Profiles[name] = profile
+		}
+	}
+	return &saved
+}
+
+// validateProfiles validates every profile and fills in default values
+func validateProfiles(config *Config) error {
+	for name, profile := range config.Profiles {
+		if profile == nil {
+			return fmt.Errorf("profile %s: definition cannot be empty", name)
+		}
+
+		if err := validateConnectors(config, profile.Connectors); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+
+		if profile.GeoIP != nil {
+			validateGeoIPConfig(profile.GeoIP)
+		}
+
+		for _, pattern := range profile.Jails {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profile %s: invalid jail pattern '%s': %w", name, pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ProfileNames returns the names of all configured profiles in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileForJail returns the name of the profile a jail is mapped to, or an
+// empty string if no profile claims it. Exact names win over glob patterns.
+func (c *Config) ProfileForJail(jail string) string {
+	if jail == "" {
+		return ""
+	}
+
+	names := c.ProfileNames()
+	for _, name := range names {
+		for _, pattern := range c.Profiles[name].Jails {
+			if pattern == jail {
+				return name
+			}
+		}
+	}
+
+	for _, name := range names {
+		for _, pattern := range c.Profiles[name].Jails {
+			if matched, _ := path.Match(pattern, jail); matched {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// WithProfile returns a copy of the configuration with the connectors and
+// GeoIP settings of the named profile applied
+func (c *Config) WithProfile(name string) (*Config, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found", name)
+	}
+
+	derived := *c
+	derived.Connectors = profile.Connectors
+	derived.Profiles = nil // Profiles don't nest
+	// Keep per-connector state of different tenants apart
+	derived.StateDir = filepath.Join(c.StateDir, "profiles", name)
+	derived.Spool.Dir = filepath.Join(c.Spool.Dir, "profiles", name)
+	if profile.GeoIP != nil {
+		derived.GeoIP = *profile.GeoIP
+	}
+
+	return &derived, nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultForwardedHeaders are the headers searched for the client address
+// when ProxyConfig.Headers is not set
+var DefaultForwardedHeaders = []string{"X-Forwarded-For", "X-Real-IP", "Forwarded"}
+
+// ProxyConfig resolves the client behind a reverse proxy. When fail2ban bans
+// the address of a proxy, e.g. because the web app logs the connecting
+// address, the client is taken from the forwarding headers in the matched
+// log lines and reported alongside the banned IP.
+type ProxyConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Jails    []string `json:"jails,omitempty"`    // Jail names or glob patterns; all jails when empty
+	Proxies  []string `json:"proxies,omitempty"`  // Addresses or CIDRs of trusted proxies; any banned IP when empty
+	Headers  []string `json:"headers,omitempty"`  // Headers holding the client (default: X-Forwarded-For, X-Real-IP, Forwarded)
+	Patterns []string `json:"patterns,omitempty"` // Regexes with a "client" group, for log formats without header names
+	GeoIP    bool     `json:"geoip,omitempty"`    // Look up the location and network of the client too
+
+	proxies  []*net.IPNet
+	patterns []*regexp.Regexp
+}
+
+// AppliesTo reports whether the client is resolved for events of the jail
+// banning ip
+func (p *ProxyConfig) AppliesTo(jail, ip string) bool {
+	if !p.Enabled || (len(p.Jails) > 0 && !matchesPattern(p.Jails, jail)) {
+		return false
+	}
+	return len(p.proxies) == 0 || inNetworks(p.proxies, ip)
+}
+
+// Trusted reports whether ip is one of the configured proxies
+func (p *ProxyConfig) Trusted(ip string) bool {
+	return inNetworks(p.proxies, ip)
+}
+
+// Regexps returns the expressions finding forwarded addresses in a log line,
+// headers first. Their "client" group captures the value.
+func (p *ProxyConfig) Regexps() []*regexp.Regexp {
+	return p.patterns
+}
+
+// headerRegexp matches a header in log lines, as "Name: value", "name=value"
+// or the quoted values of JSON logs
+func headerRegexp(header string) (*regexp.Regexp, error) {
+	name := regexp.QuoteMeta(header)
+	// Log formats often write headers in lower case with underscores, e.g.
+	// nginx's $http_x_forwarded_for
+	name = strings.ReplaceAll(name, "-", "[-_]")
+	// The value ends at a quote, except around an address as in Forwarded's
+	// for="[2001:db8::1]:443"
+	return regexp.Compile(`(?i)(?:^|[^A-Za-z0-9_-])(?:http_)?` + name +
+		`"?\s*[:=]\s*"?(?P<client>(?:[^"\r\n]|"\[?[0-9A-Fa-f.:]+\]?(?::[0-9]+)?")+)`)
+}
+
+// validateProxyConfig parses the proxies and patterns of the client
+// resolution
+func validateProxyConfig(proxy *ProxyConfig) error {
+	proxy.proxies = nil
+	for _, entry := range proxy.Proxies {
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("proxy: invalid proxy address '%s'", entry)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("proxy: invalid proxy network '%s': %w", entry, err)
+		}
+		proxy.proxies = append(proxy.proxies, network)
+	}
+
+	for _, pattern := range proxy.Jails {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("proxy: invalid jail pattern '%s': %w", pattern, err)
+		}
+	}
+
+	if len(proxy.Headers) == 0 {
+		proxy.Headers = DefaultForwardedHeaders
+	}
+	proxy.patterns = nil
+	for _, header := range proxy.Headers {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("proxy: header names cannot be empty")
+		}
+		re, err := headerRegexp(header)
+		if err != nil {
+			return fmt.Errorf("proxy: invalid header '%s': %w", header, err)
+		}
+		proxy.patterns = append(proxy.patterns, re)
+	}
+	for _, pattern := range proxy.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("proxy: invalid pattern '%s': %w", pattern, err)
+		}
+		if re.SubexpIndex("client") < 0 {
+			return fmt.Errorf("proxy: pattern '%s' needs a (?P<client>...) group", pattern)
+		}
+		proxy.patterns = append(proxy.patterns, re)
+	}
+	return nil
+}
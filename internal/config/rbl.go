@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// RBL zone formats
+const (
+	RBLFormatRBL = "rbl" // DNSBL: <reversed ip>.<zone> A 127.0.0.2
+	RBLFormatRPZ = "rpz" // Response policy zone: <prefix>.<reversed ip>.rpz-ip CNAME .
+)
+
+// RBLConfig controls generation of a DNS blocklist zone from the banned IPs
+type RBLConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Zone       string `json:"zone"`                  // Zone origin, e.g. "bl.example.com"
+	Format     string `json:"format,omitempty"`      // "rbl" (default) or "rpz"
+	ZoneFile   string `json:"zone_file,omitempty"`   // Default: <state_dir>/rbl/<zone>.zone
+	TTL        int    `json:"ttl,omitempty"`         // Record TTL in seconds (default: 300)
+	Address    string `json:"address,omitempty"`     // A record returned for listed IPs (default: 127.0.0.2)
+	NameServer string `json:"name_server,omitempty"` // Name server in the SOA and NS records (default: localhost)
+	Hostmaster string `json:"hostmaster,omitempty"`  // SOA contact (default: hostmaster.<zone>)
+	Listen     string `json:"listen,omitempty"`      // UDP address of the built-in DNS responder in daemon mode
+}
+
+// validateRBLConfig validates the RBL configuration and fills in defaults
+func validateRBLConfig(config *Config) error {
+	rbl := &config.RBL
+	if !rbl.Enabled {
+		return nil
+	}
+
+	rbl.Zone = strings.TrimSuffix(strings.ToLower(rbl.Zone), ".")
+	if rbl.Zone == "" {
+		return fmt.Errorf("rbl: zone cannot be empty")
+	}
+
+	if !config.Store.Enabled {
+		return fmt.Errorf("rbl: requires the event store to be enabled")
+	}
+
+	switch rbl.Format {
+	case "":
+		rbl.Format = RBLFormatRBL
+	case RBLFormatRBL, RBLFormatRPZ:
+	default:
+		return fmt.Errorf("rbl: invalid format '%s', must be '%s' or '%s'", rbl.Format, RBLFormatRBL, RBLFormatRPZ)
+	}
+
+	if rbl.ZoneFile == "" {
+		rbl.ZoneFile = filepath.Join(config.StateDir, "rbl", rbl.Zone+".zone")
+	}
+
+	if rbl.TTL <= 0 {
+		rbl.TTL = 300
+	}
+
+	if rbl.Address == "" {
+		rbl.Address = "127.0.0.2"
+	}
+	if ip := net.ParseIP(rbl.Address); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("rbl: address must be an IPv4 address: %s", rbl.Address)
+	}
+
+	if rbl.NameServer == "" {
+		rbl.NameServer = "localhost"
+	}
+
+	if rbl.Hostmaster == "" {
+		rbl.Hostmaster = "hostmaster." + rbl.Zone
+	}
+
+	if rbl.Listen != "" {
+		if _, _, err := net.SplitHostPort(rbl.Listen); err != nil {
+			return fmt.Errorf("rbl: invalid listen address: %w", err)
+		}
+	}
+
+	return nil
+}
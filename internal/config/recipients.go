@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// Recipient is one destination of a connector, such as a chat, channel or
+// mailbox. Its settings override those of the connector, so a single entry
+// can notify several destinations.
+type Recipient struct {
+	Name     string            `json:"name,omitempty"`
+	Jails    []string          `json:"jails,omitempty"` // Jail names or glob patterns; all jails when empty
+	Settings map[string]string `json:"settings"`
+}
+
+// Label returns the recipient name, or its position when it has none
+func (r *Recipient) Label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("recipients[%d]", i)
+}
+
+// Matches reports whether the recipient receives events of the jail
+func (r *Recipient) Matches(jail string) bool {
+	return len(r.Jails) == 0 || matchesPattern(r.Jails, jail)
+}
+
+// ForRecipient returns a copy of the connector with the recipient's settings
+// merged over its own
+func (c *ConnectorConfig) ForRecipient(r *Recipient) *ConnectorConfig {
+	merged := *c
+	merged.Recipients = nil
+	merged.Settings = make(map[string]string, len(c.Settings)+len(r.Settings))
+	for key, value := range c.Settings {
+		merged.Settings[key] = value
+	}
+	for key, value := range r.Settings {
+		merged.Settings[key] = value
+	}
+	return &merged
+}
+
+// validateRecipients checks the recipients of a connector. The settings each
+// recipient ends up with are checked by check, the connector type's validator.
+func validateRecipients(connector *ConnectorConfig, check func(*ConnectorConfig) error) error {
+	for i := range connector.Recipients {
+		recipient := &connector.Recipients[i]
+		if len(recipient.Settings) == 0 {
+			return fmt.Errorf("%s: settings cannot be empty", recipient.Label(i))
+		}
+		for _, pattern := range recipient.Jails {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid jail pattern '%s': %w", recipient.Label(i), pattern, err)
+			}
+		}
+		if err := check(connector.ForRecipient(recipient)); err != nil {
+			return fmt.Errorf("%s: %w", recipient.Label(i), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedaction replaces redacted text unless a rule sets its own
+const DefaultRedaction = "[REDACTED]"
+
+// Redaction presets for data commonly found in auth and web logs
+const (
+	RedactEmails    = "emails"
+	RedactUsers     = "users"
+	RedactPasswords = "passwords"
+)
+
+// redactionPresets are the rules of each preset. Users are only redacted
+// when they don't look like an address, so the banned IP stays visible.
+var redactionPresets = map[string][]RedactionRule{
+	RedactEmails: {
+		{Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+`},
+	},
+	RedactUsers: {
+		{Pattern: `(?i)\b((?:for )?invalid user|(?:for )?illegal user|for user|username|ruser|user|login|for)([= ])[^\s\d.:\[][^\s;,]*`, Replacement: "${1}${2}" + DefaultRedaction},
+	},
+	RedactPasswords: {
+		{Pattern: `(?i)\b(pass|passwd|password|pwd|secret|token|api_?key)([=:]\s*)[^\s&;,]+`, Replacement: "${1}${2}" + DefaultRedaction},
+	},
+}
+
+// RedactionConfig removes sensitive data such as usernames, emails and
+// passwords from log lines before they are sent: fail2ban's matches and the
+// lines bundled as artifacts
+type RedactionConfig struct {
+	Presets []string        `json:"presets,omitempty"` // "emails", "users" and/or "passwords"
+	Rules   []RedactionRule `json:"rules,omitempty"`   // Applied after the presets
+
+	rules []*regexpRule
+}
+
+// RedactionRule replaces every match of a regular expression
+type RedactionRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // May refer to groups as ${1} (default: [REDACTED])
+}
+
+// regexpRule is a compiled redaction rule
+type regexpRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Enabled reports whether any redaction is configured
+func (r *RedactionConfig) Enabled() bool {
+	return len(r.Presets) > 0 || len(r.Rules) > 0
+}
+
+// Redact applies the presets and rules to s
+func (r *RedactionConfig) Redact(s string) string {
+	if !r.Enabled() {
+		return s
+	}
+	// Compiled when the configuration is validated; don't skip redaction if not
+	if r.rules == nil {
+		if err := r.parse(); err != nil {
+			return DefaultRedaction
+		}
+	}
+	for _, rule := range r.rules {
+		s = rule.re.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// parse compiles the presets and rules
+func (r *RedactionConfig) parse() error {
+	var all []RedactionRule
+	for _, preset := range r.Presets {
+		rules, ok := redactionPresets[preset]
+		if !ok {
+			return fmt.Errorf("unknown preset '%s', must be one of %s", preset, strings.Join([]string{RedactEmails, RedactUsers, RedactPasswords}, ", "))
+		}
+		all = append(all, rules...)
+	}
+	all = append(all, r.Rules...)
+
+	compiled := make([]*regexpRule, 0, len(all))
+	for _, rule := range all {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %w", rule.Pattern, err)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = DefaultRedaction
+		}
+		compiled = append(compiled, &regexpRule{re: re, replacement: replacement})
+	}
+	r.rules = compiled
+	return nil
+}
+
+// validateRedactionConfig compiles the redaction rules
+func validateRedactionConfig(redaction *RedactionConfig) error {
+	if !redaction.Enabled() {
+		return nil
+	}
+	if err := redaction.parse(); err != nil {
+		return fmt.Errorf("redaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// RiskConfig combines the enrichments into a 0-100 risk score per ban
+type RiskConfig struct {
+	Enabled            bool           `json:"enabled"`
+	Weights            RiskWeights    `json:"weights"`
+	FailuresCap        int            `json:"failures_cap"`        // Failures that score the maximum (default: 20)
+	HistoryCap         int            `json:"history_cap"`         // Earlier bans of the IP that score the maximum (default: 5)
+	JailCriticality    map[string]int `json:"jail_criticality"`    // Jail name or glob pattern -> criticality 0-100
+	DefaultCriticality int            `json:"default_criticality"` // Criticality of other jails (default: 50)
+}
+
+// RiskWeights sets how much each factor contributes to the score. Factors
+// without data, such as history when the event store is disabled, are left
+// out instead of counting as zero.
+type RiskWeights struct {
+	Failures  int `json:"failures"`  // Failures before the ban
+	History   int `json:"history"`   // Earlier bans of the IP in the event store
+	Abuse     int `json:"abuse"`     // IPQualityScore fraud score
+	Anonymity int `json:"anonymity"` // Tor, VPN or proxy use
+	Jail      int `json:"jail"`      // Criticality of the jail
+}
+
+// DefaultRiskWeights returns the weights used when none are configured
+func DefaultRiskWeights() RiskWeights {
+	return RiskWeights{Failures: 20, History: 25, Abuse: 20, Anonymity: 15, Jail: 20}
+}
+
+// Criticality returns the configured criticality of a jail. Exact names win
+// over glob patterns.
+func (r *RiskConfig) Criticality(jail string) int {
+	if value, ok := r.JailCriticality[jail]; ok {
+		return value
+	}
+	for pattern, value := range r.JailCriticality {
+		if matched, _ := path.Match(pattern, jail); matched {
+			return value
+		}
+	}
+	return r.DefaultCriticality
+}
+
+// validateRiskConfig validates the risk scoring settings and fills in defaults
+func validateRiskConfig(risk *RiskConfig) error {
+	if risk.Weights == (RiskWeights{}) {
+		risk.Weights = DefaultRiskWeights()
+	}
+	w := risk.Weights
+	if w.Failures < 0 || w.History < 0 || w.Abuse < 0 || w.Anonymity < 0 || w.Jail < 0 {
+		return fmt.Errorf("risk: weights cannot be negative")
+	}
+
+	if risk.FailuresCap <= 0 {
+		risk.FailuresCap = 20
+	}
+	if risk.HistoryCap <= 0 {
+		risk.HistoryCap = 5
+	}
+	if risk.DefaultCriticality <= 0 {
+		risk.DefaultCriticality = 50
+	}
+	if risk.DefaultCriticality > 100 {
+		return fmt.Errorf("risk: default_criticality must be between 0 and 100")
+	}
+
+	for pattern, value := range risk.JailCriticality {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("risk: criticality of jail %s must be between 0 and 100", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("risk: invalid jail pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
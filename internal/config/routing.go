@@ -0,0 +1,292 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/countries" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"          //nolint:depguard
+)
+
+// RoutingConfig restricts connectors to the events matching their rules.
+// Connectors no rule names keep receiving every event.
+type RoutingConfig struct {
+	Rules []RouteRule `json:"rules,omitempty"`
+}
+
+// RouteRule sends the events it matches to the listed connectors
+type RouteRule struct {
+	Name       string     `json:"name,omitempty"`
+	Match      RouteMatch `json:"match"`
+	Connectors []string   `json:"connectors"`
+}
+
+// RouteMatch lists the conditions of a rule; all set conditions must hold.
+// Anonymity conditions never match events without a detection result.
+type RouteMatch struct {
+	Jails            []string `json:"jails,omitempty"`      // Jail names or glob patterns
+	Actions          []string `json:"actions,omitempty"`    // "ban", "unban", "surge" or "restore"
+	Countries        []string `json:"countries,omitempty"`  // ISO country codes or names, normalized to codes
+	Continents       []string `json:"continents,omitempty"` // Continent codes, e.g. "EU"
+	ASNs             []string `json:"asns,omitempty"`       // Autonomous systems, e.g. "AS3320" or "3320"
+	Org              string   `json:"org,omitempty"`        // Regular expression matched against the ISP or organization
+	Networks         []string `json:"networks,omitempty"`   // CIDR ranges the IP is in
+	Hosts            []string `json:"hosts,omitempty"`      // Attacked sites or glob patterns, e.g. "*.example.com"
+	MinRisk          int      `json:"min_risk,omitempty"`   // Risk score at least this high
+	MinAbuse         int      `json:"min_abuse,omitempty"`  // Fraud score of the anonymity lookup at least this high
+	Anonymous        *bool    `json:"anonymous,omitempty"`  // VPN, proxy or Tor
+	VPN              *bool    `json:"vpn,omitempty"`
+	Proxy            *bool    `json:"proxy,omitempty"`
+	ResidentialProxy *bool    `json:"residential_proxy,omitempty"`
+	Tor              *bool    `json:"tor,omitempty"`
+	Bot              *bool    `json:"bot,omitempty"`
+	TrustedRegion    *bool    `json:"trusted_region,omitempty"`  // Ban from one of the trusted countries
+	PossibleBreach   *bool    `json:"possible_breach,omitempty"` // Ban after a successful login from the IP's network
+
+	org      *regexp.Regexp
+	networks []*net.IPNet
+}
+
+// Routes reports whether any rule names the connector, and if so whether
+// one of those rules matches the event
+func (r *RoutingConfig) Routes(connector string, data *types.NotificationData) (routed, matched bool) {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if !containsString(rule.Connectors, connector) {
+			continue
+		}
+		routed = true
+		if rule.Match.Matches(data) {
+			return true, true
+		}
+	}
+	return routed, false
+}
+
+// Matches reports whether the event satisfies every set condition
+func (m *RouteMatch) Matches(data *types.NotificationData) bool {
+	if len(m.Jails) > 0 && !matchesPattern(m.Jails, data.Jail) {
+		return false
+	}
+	if len(m.Actions) > 0 && !containsString(m.Actions, data.Action) {
+		return false
+	}
+	if len(m.Countries) > 0 && !containsFold(m.Countries, countryCode(data)) {
+		return false
+	}
+	if len(m.Continents) > 0 && !containsFold(m.Continents, data.Continent) {
+		return false
+	}
+
+	if len(m.ASNs) > 0 && !containsFold(m.ASNs, normalizeASN(data.ASN)) {
+		return false
+	}
+	if m.org != nil && !m.org.MatchString(data.ISP) {
+		return false
+	}
+	if len(m.networks) > 0 && !inNetworks(m.networks, data.IP) {
+		return false
+	}
+	if len(m.Hosts) > 0 && (data.TargetHost == "" || !matchesPattern(m.Hosts, data.TargetHost)) {
+		return false
+	}
+
+	if m.MinRisk > 0 && data.RiskScore < m.MinRisk {
+		return false
+	}
+	if m.TrustedRegion != nil && data.TrustedRegion != *m.TrustedRegion {
+		return false
+	}
+	if m.PossibleBreach != nil && data.PossibleBreach() != *m.PossibleBreach {
+		return false
+	}
+
+	a := data.Anonymity
+	if m.MinAbuse > 0 && (a == nil || a.FraudScore < m.MinAbuse) {
+		return false
+	}
+	flags := []struct {
+		want *bool
+		have func() bool
+	}{
+		{m.Anonymous, func() bool { return a.Anonymous() }},
+		{m.VPN, func() bool { return a.VPN }},
+		{m.Proxy, func() bool { return a.Proxy }},
+		{m.ResidentialProxy, func() bool { return a.ResidentialProxy }},
+		{m.Tor, func() bool { return a.Tor }},
+		{m.Bot, func() bool { return a.Bot }},
+	}
+	for _, flag := range flags {
+		if flag.want == nil {
+			continue
+		}
+		if a == nil || flag.have() != *flag.want {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parse compiles the org pattern and the networks and checks the host
+// patterns
+func (m *RouteMatch) parse() error {
+	if m.Org != "" {
+		var err error
+		if m.org, err = regexp.Compile(m.Org); err != nil {
+			return fmt.Errorf("invalid org pattern: %w", err)
+		}
+	}
+
+	m.networks = nil
+	for _, cidr := range m.Networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid network '%s': %w", cidr, err)
+		}
+		m.networks = append(m.networks, network)
+	}
+
+	for i, host := range m.Hosts {
+		m.Hosts[i] = strings.ToLower(host)
+		if _, err := path.Match(m.Hosts[i], ""); err != nil {
+			return fmt.Errorf("invalid host pattern '%s': %w", host, err)
+		}
+	}
+
+	// Events carry the ISO code whatever the provider called the country
+	for i, country := range m.Countries {
+		code := countries.Code(country)
+		if code == "" {
+			return fmt.Errorf("unknown country '%s', use its ISO 3166-1 code such as 'DE'", country)
+		}
+		m.Countries[i] = code
+	}
+
+	for i, asn := range m.ASNs {
+		if normalized := normalizeASN(asn); normalized != "" {
+			m.ASNs[i] = normalized
+			continue
+		}
+		return fmt.Errorf("invalid ASN '%s'", asn)
+	}
+	return nil
+}
+
+// normalizeASN returns an AS number as "AS<number>", or "" if it isn't one
+func normalizeASN(asn string) string {
+	number := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+	if number == "" || strings.Trim(number, "0123456789") != "" {
+		return ""
+	}
+	return "AS" + number
+}
+
+// inNetworks reports whether ip is in one of the networks
+func inNetworks(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// countryCode returns the ISO code of the event's country. Events recorded
+// before countries were normalized carry the provider's name only.
+func countryCode(data *types.NotificationData) string {
+	if data.CountryCode != "" {
+		return data.CountryCode
+	}
+	return countries.Code(data.Country)
+}
+
+// continents are the continent codes GeoIP results are normalized to
+var continents = []string{"AF", "AN", "AS", "EU", "NA", "OC", "SA"}
+
+// validateRoutingConfig checks that rules name known connectors
+func validateRoutingConfig(config *Config) error {
+	known := make(map[string]bool)
+	for _, connector := range config.Connectors {
+		known[connector.Name] = true
+	}
+	for _, profile := range config.Profiles {
+		for _, connector := range profile.Connectors {
+			known[connector.Name] = true
+		}
+	}
+
+	for i := range config.Routing.Rules {
+		rule := &config.Routing.Rules[i]
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rules[%d]", i)
+		}
+
+		if len(rule.Connectors) == 0 {
+			return fmt.Errorf("routing: %s: connectors cannot be empty", name)
+		}
+		for _, connector := range rule.Connectors {
+			if !known[connector] {
+				return fmt.Errorf("routing: %s: unknown connector '%s'", name, connector)
+			}
+		}
+
+		for _, pattern := range rule.Match.Jails {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("routing: %s: invalid jail pattern '%s': %w", name, pattern, err)
+			}
+		}
+		for _, action := range rule.Match.Actions {
+			switch action {
+			case types.ActionBan, types.ActionUnban, types.ActionSurge, types.ActionRestore, types.ActionBulkUnban:
+			default:
+				return fmt.Errorf("routing: %s: invalid action '%s'", name, action)
+			}
+		}
+		for _, continent := range rule.Match.Continents {
+			if !containsFold(continents, continent) {
+				return fmt.Errorf("routing: %s: invalid continent '%s'", name, continent)
+			}
+		}
+		if err := rule.Match.parse(); err != nil {
+			return fmt.Errorf("routing: %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func matchesPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Secret stores connector settings can refer to instead of holding tokens
+const (
+	SecretSchemeVault   = "vault"        // vault://<path>[#key], a HashiCorp Vault KV secret
+	SecretSchemeAWS     = "aws-sm"       // aws-sm://<name or ARN>[#key], an AWS Secrets Manager secret
+	SecretSchemeSystemd = "systemd-cred" // systemd-cred://<name>, a credential passed by systemd's LoadCredential
+)
+
+// SecretsConfig tells how secret references in connector settings are
+// resolved
+type SecretsConfig struct {
+	Vault    VaultConfig      `json:"vault,omitempty"`
+	AWS      AWSSecretsConfig `json:"aws,omitempty"`
+	CacheTTL int              `json:"cache_ttl,omitempty"` // Seconds a resolved secret is reused (default: 300)
+}
+
+// VaultConfig locates the Vault server. The token is read from a file, so it
+// isn't kept in the configuration either.
+type VaultConfig struct {
+	Address   string `json:"address,omitempty"`    // e.g. "https://vault.example.com:8200" (default: $VAULT_ADDR)
+	TokenFile string `json:"token_file,omitempty"` // File holding the token (default: $VAULT_TOKEN, then ~/.vault-token)
+	Namespace string `json:"namespace,omitempty"`  // Vault Enterprise namespace
+}
+
+// AWSSecretsConfig locates AWS Secrets Manager. Credentials come from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+type AWSSecretsConfig struct {
+	Region   string `json:"region,omitempty"`   // Default: the region of an ARN, then $AWS_REGION or $AWS_DEFAULT_REGION
+	Endpoint string `json:"endpoint,omitempty"` // e.g. a VPC endpoint (default: https://secretsmanager.<region>.amazonaws.com)
+}
+
+// SecretRef is a reference to a secret in a connector setting
+type SecretRef struct {
+	Scheme string
+	Path   string // Vault path, Secrets Manager secret ID or credential name
+	Key    string // Field of a secret holding several values, empty for the whole secret
+}
+
+// String returns the reference as written in the setting
+func (r *SecretRef) String() string {
+	ref := r.Scheme + "://" + r.Path
+	if r.Key != "" {
+		ref += "#" + r.Key
+	}
+	return ref
+}
+
+// ParseSecretRef parses a setting value that refers to a secret. It returns
+// nil for values that are no secret references.
+func ParseSecretRef(value string) (*SecretRef, error) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found || (scheme != SecretSchemeVault && scheme != SecretSchemeAWS && scheme != SecretSchemeSystemd) {
+		return nil, nil
+	}
+
+	ref := &SecretRef{Scheme: scheme, Path: rest}
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		ref.Path, ref.Key = rest[:i], rest[i+1:]
+		if ref.Key == "" {
+			return nil, fmt.Errorf("secret reference '%s' has an empty key", value)
+		}
+	}
+	if ref.Path == "" {
+		return nil, fmt.Errorf("secret reference '%s' has no path", value)
+	}
+
+	if scheme == SecretSchemeSystemd {
+		if ref.Key != "" {
+			return nil, fmt.Errorf("systemd credential '%s' cannot have a key", value)
+		}
+		if strings.Contains(ref.Path, "/") || ref.Path == "." || ref.Path == ".." {
+			return nil, fmt.Errorf("invalid systemd credential name '%s'", ref.Path)
+		}
+	}
+	return ref, nil
+}
+
+// HasSecretRefs reports whether any of the settings refers to a secret
+func HasSecretRefs(settings map[string]string) bool {
+	for _, value := range settings {
+		if ref, err := ParseSecretRef(value); ref != nil || err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SystemdCredentials returns the names of the systemd credentials the
+// connectors and the store encryption refer to, sorted
+func (c *Config) SystemdCredentials() []string {
+	seen := make(map[string]bool)
+	add := func(settings map[string]string) {
+		for _, value := range settings {
+			if ref, err := ParseSecretRef(value); err == nil && ref != nil && ref.Scheme == SecretSchemeSystemd {
+				seen[ref.Path] = true
+			}
+		}
+	}
+	for i := range c.Connectors {
+		add(c.Connectors[i].Settings)
+		for j := range c.Connectors[i].Recipients {
+			add(c.Connectors[i].Recipients[j].Settings)
+		}
+	}
+	if c.Store.Encryption.Enabled {
+		add(map[string]string{"key": c.Store.Encryption.Key})
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadSystemdCredential reads a credential systemd passed to the service
+// with LoadCredential= or SetCredential=
+func ReadSystemdCredential(name string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", fmt.Errorf("CREDENTIALS_DIRECTORY is not set, add LoadCredential=%s:<file> to the service", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// validateSecretRefs checks the secret references in settings
+func validateSecretRefs(config *Config, settings map[string]string) error {
+	for key, value := range settings {
+		ref, err := ParseSecretRef(value)
+		if err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+		if ref == nil {
+			continue
+		}
+		if ref.Scheme == SecretSchemeVault && config.Secrets.Vault.Address == "" && os.Getenv("VAULT_ADDR") == "" {
+			return fmt.Errorf("setting %s: %s needs secrets.vault.address or VAULT_ADDR", key, ref)
+		}
+	}
+	return nil
+}
+
+// validateSecretsConfig fills in the defaults of secret resolution
+func validateSecretsConfig(config *Config) error {
+	secrets := &config.Secrets
+	if secrets.CacheTTL <= 0 {
+		secrets.CacheTTL = 300
+	}
+	if secrets.Vault.Address != "" && !strings.HasPrefix(secrets.Vault.Address, "http://") && !strings.HasPrefix(secrets.Vault.Address, "https://") {
+		return fmt.Errorf("secrets: vault address must be an http(s) URL")
+	}
+	if secrets.AWS.Endpoint != "" && !strings.HasPrefix(secrets.AWS.Endpoint, "https://") {
+		return fmt.Errorf("secrets: aws endpoint must be an https URL")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// SLAConfig sets how quickly bans of critical jails must be acknowledged.
+// When a ban is still unacknowledged at its deadline, the daemon notifies
+// the SLA escalation connectors, e.g. an on-call pager.
+type SLAConfig struct {
+	Jails      map[string]int `json:"jails,omitempty"`      // Jail name or glob pattern -> seconds to acknowledge a ban
+	Escalation []string       `json:"escalation,omitempty"` // Connectors or failover groups notified when the time is up
+	Interval   int            `json:"interval,omitempty"`   // Seconds between checks by the daemon (default: 60)
+}
+
+// Enabled reports whether any jail has an SLA
+func (s *SLAConfig) Enabled() bool {
+	return len(s.Jails) > 0
+}
+
+// Deadline returns the time allowed to acknowledge a ban of the jail. When
+// several patterns match, the shortest time applies.
+func (s *SLAConfig) Deadline(jail string) (time.Duration, bool) {
+	shortest := 0
+	for pattern, seconds := range s.Jails {
+		if matchesPattern([]string{pattern}, jail) && (shortest == 0 || seconds < shortest) {
+			shortest = seconds
+		}
+	}
+	return time.Duration(shortest) * time.Second, shortest > 0
+}
+
+// AckRequired reports whether bans of the jail wait for an acknowledgment:
+// those of critical jails and jails with an SLA
+func (c *Config) AckRequired(jail string) bool {
+	if c.Delivery.IsCritical(jail) {
+		return true
+	}
+	_, ok := c.SLA.Deadline(jail)
+	return ok
+}
+
+// validateSLAConfig validates the SLA timers and fills in defaults
+func validateSLAConfig(config *Config) error {
+	sla := &config.SLA
+
+	if sla.Interval <= 0 {
+		sla.Interval = 60
+	}
+	if !sla.Enabled() {
+		return nil
+	}
+
+	for pattern, seconds := range sla.Jails {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("sla: invalid jail pattern '%s': %w", pattern, err)
+		}
+		if seconds <= 0 {
+			return fmt.Errorf("sla: time for jail '%s' must be positive", pattern)
+		}
+	}
+
+	if !config.Ack.Enabled {
+		return fmt.Errorf("sla: requires ack to be enabled")
+	}
+	if len(sla.Escalation) == 0 {
+		return fmt.Errorf("sla: requires escalation connectors")
+	}
+	for _, name := range sla.Escalation {
+		_, isConnector := config.GetConnectorByName(name)
+		_, isGroup := config.GetGroupByName(name)
+		if !isConnector && !isGroup {
+			return fmt.Errorf("sla: unknown escalation connector or group '%s'", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Spool overflow policies
+const (
+	SpoolOverflowDropOldest = "drop-oldest"
+	SpoolOverflowDropNew    = "drop-new"
+	SpoolOverflowDigest     = "digest"
+)
+
+// SpoolConfig controls queuing of notifications that failed delivery
+type SpoolConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Dir       string `json:"dir,omitempty"` // Default: <state_dir>/spool
+	MaxEvents int    `json:"max_events"`    // Maximum queued events, 0 for unlimited
+	MaxDiskMB int    `json:"max_disk_mb"`   // Maximum disk usage in MB, 0 for unlimited
+	MaxAge    int    `json:"max_age"`       // Maximum age of queued events in seconds, 0 for unlimited
+	Overflow  string `json:"overflow"`      // "drop-oldest", "drop-new" or "digest"
+}
+
+// DefaultSpoolConfig returns the default spool limits
+func DefaultSpoolConfig() SpoolConfig {
+	return SpoolConfig{
+		Enabled:   false,
+		MaxEvents: 1000,
+		MaxDiskMB: 50,
+		MaxAge:    86400, // 1 day
+		Overflow:  SpoolOverflowDropOldest,
+	}
+}
+
+// validateSpoolConfig validates the spool configuration and fills in defaults
+func validateSpoolConfig(config *Config) error {
+	spool := &config.Spool
+
+	if spool.Dir == "" {
+		spool.Dir = filepath.Join(config.StateDir, "spool")
+	}
+
+	if spool.Overflow == "" {
+		spool.Overflow = SpoolOverflowDropOldest
+	}
+
+	switch spool.Overflow {
+	case SpoolOverflowDropOldest, SpoolOverflowDropNew, SpoolOverflowDigest:
+	default:
+		return fmt.Errorf("spool: invalid overflow policy '%s', must be '%s', '%s', or '%s'",
+			spool.Overflow, SpoolOverflowDropOldest, SpoolOverflowDropNew, SpoolOverflowDigest)
+	}
+
+	if spool.MaxEvents < 0 || spool.MaxDiskMB < 0 || spool.MaxAge < 0 {
+		return fmt.Errorf("spool: limits cannot be negative")
+	}
+
+	return nil
+}
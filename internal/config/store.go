@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal" //nolint:depguard
+)
+
+// DefaultOutputLimit is the connector output kept per result in bytes
+const DefaultOutputLimit = 4096
+
+// DefaultHourlyRetention is how long hourly rollups are kept in seconds
+const DefaultHourlyRetention = 90 * 86400
+
+// StoreConfig controls the event store: a log of every ban and unban plus
+// the set of currently banned IPs, shared by all profiles
+type StoreConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Dir       string `json:"dir,omitempty"` // Default: <state_dir>/store
+	Retention int    `json:"retention"`     // Seconds to keep events (default: 90 days)
+	// OutputLimit caps the connector output kept per result in bytes
+	// (default: 4096, -1 to keep none)
+	OutputLimit int `json:"output_limit,omitempty"`
+	// RetentionHook is run with the pruned events on stdin, to delete copies
+	// kept elsewhere on the same schedule
+	RetentionHook string `json:"retention_hook,omitempty"`
+	// Chain keeps a tamper-evident copy of the events
+	Chain ChainConfig `json:"chain"`
+	// Rollups keep hourly and daily counts beyond the retention period
+	Rollups RollupConfig `json:"rollups"`
+	// Encryption encrypts the stored events and the spool at rest
+	Encryption EncryptionConfig `json:"encryption"`
+}
+
+// DefaultEncryptionKey is where the store encryption key is read from by
+// default
+const DefaultEncryptionKey = "env://F2B_STORE_KEY"
+
+// EncryptionConfig controls encryption at rest of the files holding
+// personal data: the events, the connector results, the acknowledgments,
+// the active bans and the campaigns of the store, the events of the chain,
+// the spooled events, the anonymity and passive DNS caches, and the state
+// of the blocklist and BGP connectors. It is meant for hosts without disk
+// encryption. The key is 32 bytes, hex or base64 encoded, and is read when
+// the store is opened, so only the processes reading the store need it.
+type EncryptionConfig struct {
+	Enabled bool `json:"enabled"`
+	// Key is where the key is read from: env://<variable> or
+	// systemd-cred://<name> (default: env://F2B_STORE_KEY)
+	Key string `json:"key,omitempty"`
+}
+
+// Sealer returns the sealer of the configured key, nil with encryption
+// disabled
+func (e *EncryptionConfig) Sealer() (*seal.Sealer, error) {
+	if !e.Enabled {
+		return nil, nil
+	}
+
+	var text string
+	scheme, name, _ := strings.Cut(e.Key, "://")
+	switch scheme {
+	case "env":
+		if text = os.Getenv(name); text == "" {
+			return nil, fmt.Errorf("encryption key %s is not set", name)
+		}
+	case SecretSchemeSystemd:
+		var err error
+		if text, err = ReadSystemdCredential(name); err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid encryption key source %s", e.Key)
+	}
+
+	key, err := seal.ParseKey(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return seal.New(key)
+}
+
+// RollupConfig controls the hourly and daily counts of bans and unbans per
+// jail, country and network, which stats and the query API read instead of
+// every raw event. Retention doesn't remove them.
+type RollupConfig struct {
+	Enabled         bool `json:"enabled"`
+	HourlyRetention int  `json:"hourly_retention,omitempty"` // Seconds to keep hourly counts (default: 90 days)
+	DailyRetention  int  `json:"daily_retention,omitempty"`  // Seconds to keep daily counts (default: 0, forever)
+}
+
+// ChainConfig controls the hash-chained event log: an append-only copy of
+// the stored events in which every record includes the hash of the one
+// before it, so removing or editing a record breaks the chain. Retention
+// doesn't apply to it.
+type ChainConfig struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path,omitempty"`     // Default: <store dir>/chain.jsonl
+	Sign    bool   `json:"sign"`               // Sign every record with an Ed25519 key
+	KeyFile string `json:"key_file,omitempty"` // Private key, generated if missing (default: <store dir>/chain.key)
+}
+
+// DefaultStoreConfig returns the default event store configuration. The
+// store keeps a log of banned IPs, so it is only written when enabled.
+func DefaultStoreConfig() StoreConfig {
+	return StoreConfig{
+		Retention: 90 * 86400,
+	}
+}
+
+// validateStoreConfig fills in store defaults
+func validateStoreConfig(config *Config) {
+	store := &config.Store
+
+	if store.Dir == "" {
+		store.Dir = filepath.Join(config.StateDir, "store")
+	}
+
+	if store.Retention <= 0 {
+		store.Retention = DefaultStoreConfig().Retention
+	}
+
+	if store.OutputLimit == 0 {
+		store.OutputLimit = DefaultOutputLimit
+	}
+
+	if store.Rollups.HourlyRetention <= 0 {
+		store.Rollups.HourlyRetention = DefaultHourlyRetention
+	}
+	if store.Rollups.DailyRetention < 0 {
+		store.Rollups.DailyRetention = 0
+	}
+}
+
+// validateChainConfig validates the hash-chained event log and fills in
+// defaults
+func validateChainConfig(config *Config) error {
+	chain := &config.Store.Chain
+
+	if chain.Path == "" {
+		chain.Path = filepath.Join(config.Store.Dir, "chain.jsonl")
+	}
+	if chain.KeyFile == "" {
+		chain.KeyFile = filepath.Join(config.Store.Dir, "chain.key")
+	}
+
+	if chain.Enabled && !config.Store.Enabled {
+		return fmt.Errorf("store: chain requires the event store")
+	}
+	return nil
+}
+
+// validateEncryptionConfig checks where the encryption key is read from.
+// The key itself may only be available to the service, so it is read when
+// the store is opened.
+func validateEncryptionConfig(config *Config) error {
+	encryption := &config.Store.Encryption
+	if !encryption.Enabled {
+		return nil
+	}
+
+	if encryption.Key == "" {
+		encryption.Key = DefaultEncryptionKey
+	}
+	scheme, name, found := strings.Cut(encryption.Key, "://")
+	switch {
+	case !found || name == "":
+		return fmt.Errorf("store: encryption key must be env://<variable> or systemd-cred://<name>")
+	case scheme == "env":
+	case scheme == SecretSchemeSystemd:
+		if _, err := ParseSecretRef(encryption.Key); err != nil {
+			return fmt.Errorf("store: encryption key: %w", err)
+		}
+	default:
+		return fmt.Errorf("store: encryption key must be env://<variable> or systemd-cred://<name>")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import "fmt"
+
+// SurgeConfig controls detection of attack surges: a jail whose ban rate in
+// the current window exceeds its rolling baseline raises a "surge" event
+type SurgeConfig struct {
+	Enabled  bool    `json:"enabled"`
+	Window   int     `json:"window"`   // Seconds per rate window (default: 1 hour)
+	Baseline int     `json:"baseline"` // Previous windows averaged into the baseline (default: 24)
+	Factor   float64 `json:"factor"`   // Multiple of the baseline that counts as a surge (default: 3)
+	MinBans  int     `json:"min_bans"` // Bans in the window required before a surge is raised (default: 10)
+}
+
+// validateSurgeConfig validates the surge detection settings and fills in defaults
+func validateSurgeConfig(config *Config) error {
+	surge := &config.Surge
+
+	if surge.Window <= 0 {
+		surge.Window = 3600
+	}
+	if surge.Baseline <= 0 {
+		surge.Baseline = 24
+	}
+	if surge.Factor == 0 {
+		surge.Factor = 3
+	}
+	if surge.Factor < 1 {
+		return fmt.Errorf("surge: factor must be at least 1")
+	}
+	if surge.MinBans <= 0 {
+		surge.MinBans = 10
+	}
+
+	if surge.Enabled && !config.Store.Enabled {
+		return fmt.Errorf("surge: requires the event store")
+	}
+
+	return nil
+}
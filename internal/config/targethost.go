@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// builtinHostPatterns find the attacked site in common log formats: Host
+// headers, nginx error logs ("server: example.com") and Apache's
+// vhost_combined format ("example.com:443 1.2.3.4 - - [...]")
+var builtinHostPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9_-])(?:http_)?host"?\s*[:=]\s*"?(?P<host>[A-Za-z0-9.:\[\]-]+)`),
+	regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9_-])server(?:_name)?"?\s*[:=]\s*"?(?P<host>[A-Za-z0-9.-]+)`),
+	regexp.MustCompile(`^(?P<host>[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+):[0-9]+ \S+ \S+ \S+ \[`),
+}
+
+// TargetHostConfig finds which site an attack targeted, for servers hosting
+// many domains. The host is taken from the matched log lines, trying the
+// configured patterns before the built-in ones.
+type TargetHostConfig struct {
+	Patterns []string `json:"patterns,omitempty"` // Regexes with a "host" group, for custom log formats
+
+	patterns []*regexp.Regexp
+}
+
+// Regexps returns the expressions finding the host, configured patterns
+// first. Their "host" group captures it.
+func (t *TargetHostConfig) Regexps() []*regexp.Regexp {
+	if t.patterns == nil {
+		return builtinHostPatterns
+	}
+	return t.patterns
+}
+
+// validateTargetHostConfig compiles the host patterns
+func validateTargetHostConfig(targetHost *TargetHostConfig) error {
+	targetHost.patterns = make([]*regexp.Regexp, 0, len(targetHost.Patterns)+len(builtinHostPatterns))
+	for _, pattern := range targetHost.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("target_host: invalid pattern '%s': %w", pattern, err)
+		}
+		if re.SubexpIndex("host") < 0 {
+			return fmt.Errorf("target_host: pattern '%s' needs a (?P<host>...) group", pattern)
+		}
+		targetHost.patterns = append(targetHost.patterns, re)
+	}
+	targetHost.patterns = append(targetHost.patterns, builtinHostPatterns...)
+	return nil
+}
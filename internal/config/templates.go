@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/humanize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// MessageFields are the keys of the fields native connectors show, in their
+// default order
+var MessageFields = []string{
+	"ip", "ip_hash", "client", "jail", "action", "time", "site", "failures", "history", "risk", "logins",
+	"trusted_region", "location", "campaign", "domains", "anonymity", "honeypot", "isp", "server", "labels",
+	"throttled", "escalation", "unacknowledged", "ack", "matches",
+}
+
+// TemplateExt is the extension of the partials and layouts in TemplateDir
+const TemplateExt = ".tmpl"
+
+// MessageTemplate overrides how native connectors word the notifications of
+// a jail or connector. Title and Body are Go templates executed with the
+// event, e.g. "{{.IP}} probed {{.Hostname}} for {{.Jail}}".
+type MessageTemplate struct {
+	Title   string   `json:"title,omitempty"`   // Replaces the one-line title
+	Body    string   `json:"body,omitempty"`    // Replaces the field list
+	Fields  []string `json:"fields,omitempty"`  // Fields to show, by key, in this order
+	Extends string   `json:"extends,omitempty"` // Layout in the template directory; Body then only redefines its blocks
+
+	title *template.Template
+	body  *template.Template
+}
+
+// RenderTitle executes the title template. It returns false if there is no
+// title template or it fails, so the default title is used.
+func (t *MessageTemplate) RenderTitle(data *types.NotificationData) (string, bool) {
+	return render(t.title, data)
+}
+
+// RenderBody executes the body template. It returns false if there is no
+// body template or it fails, so the default field list is used.
+func (t *MessageTemplate) RenderBody(data *types.NotificationData) (string, bool) {
+	return render(t.body, data)
+}
+
+// render executes a parsed template with the event
+func render(tmpl *template.Template, data *types.NotificationData) (string, bool) {
+	if tmpl == nil {
+		return "", false
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// RenderArgs executes the argument templates of a script or executable
+// connector with the event. Unlike titles, a failing argument template is an
+// error: the connector must not run with wrong arguments.
+func (c *ConnectorConfig) RenderArgs(data *types.NotificationData, locale humanize.Locale) ([]string, error) {
+	args := make([]string, 0, len(c.Args))
+	for i, arg := range c.Args {
+		tmpl, err := parseArg(i, arg, locale.Funcs())
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("args[%d]: %w", i, err)
+		}
+		args = append(args, b.String())
+	}
+	return args, nil
+}
+
+// parseArg parses one argument template
+func parseArg(i int, arg string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(fmt.Sprintf("args[%d]", i)).Funcs(funcs).Option("missingkey=error").Parse(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid args[%d]: %w", i, err)
+	}
+	return tmpl, nil
+}
+
+// validateArgs checks the argument templates of a connector
+func validateArgs(config *Config, connector *ConnectorConfig) error {
+	if len(connector.Args) > 0 && connector.Type != ConnectorTypeScript && connector.Type != ConnectorTypeExecutable {
+		return fmt.Errorf("args are only supported by '%s' and '%s' connectors", ConnectorTypeScript, ConnectorTypeExecutable)
+	}
+	for i, arg := range connector.Args {
+		if _, err := parseArg(i, arg, config.Humanize().Funcs()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TemplateForJail returns the message template of a jail, or nil if none is
+// configured. Exact names win over glob patterns, which are tried in
+// alphabetical order.
+func (c *Config) TemplateForJail(jail string) *MessageTemplate {
+	if tmpl, ok := c.Templates[jail]; ok {
+		return tmpl
+	}
+
+	patterns := make([]string, 0, len(c.Templates))
+	for pattern := range c.Templates {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, jail); matched {
+			return c.Templates[pattern]
+		}
+	}
+	return nil
+}
+
+// TemplateFor returns the message template used by a connector for events
+// of a jail: the connector's template, falling back to the jail's template
+// for the parts it leaves unset. It returns nil if neither exists.
+func (c *Config) TemplateFor(connector *ConnectorConfig, jail string) *MessageTemplate {
+	jailTmpl := c.TemplateForJail(jail)
+	if connector == nil || connector.Template == nil {
+		return jailTmpl
+	}
+	if jailTmpl == nil {
+		return connector.Template
+	}
+
+	merged := *connector.Template
+	if merged.title == nil {
+		merged.Title, merged.title = jailTmpl.Title, jailTmpl.title
+	}
+	if merged.body == nil {
+		merged.Body, merged.body = jailTmpl.Body, jailTmpl.body
+	}
+	if len(merged.Fields) == 0 {
+		merged.Fields = jailTmpl.Fields
+	}
+	return &merged
+}
+
+// parse parses the title and body templates with the functions and the
+// partials of the configuration and checks the field keys
+func (t *MessageTemplate) parse(config *Config) error {
+	var err error
+	if t.Title != "" {
+		if t.title, err = config.newTemplate("title", t.Title); err != nil {
+			return fmt.Errorf("invalid title: %w", err)
+		}
+	}
+	if t.Extends != "" {
+		if t.body, err = config.extendTemplate(t.Extends, t.Body); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	} else if t.Body != "" {
+		if t.body, err = config.newTemplate("body", t.Body); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	}
+
+	for _, field := range t.Fields {
+		if !containsString(MessageFields, field) {
+			return fmt.Errorf("unknown field '%s'", field)
+		}
+	}
+	return nil
+}
+
+// validateTemplates parses the message templates of the jails
+func validateTemplates(config *Config) error {
+	for pattern, tmpl := range config.Templates {
+		if tmpl == nil {
+			return fmt.Errorf("templates: %s: template cannot be empty", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("templates: invalid jail pattern '%s': %w", pattern, err)
+		}
+		if err := tmpl.parse(config); err != nil {
+			return fmt.Errorf("templates: %s: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// loadPartials parses the *.tmpl files of TemplateDir. Each file is a
+// template named after the file without its extension, and may define
+// further templates; all of them can be included in titles and bodies with
+// {{template "name" .}}, and serve as layouts to extend.
+func loadPartials(config *Config) error {
+	config.partials = nil
+	if config.TemplateDir == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(config.TemplateDir); err != nil {
+		return fmt.Errorf("template_dir: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(config.TemplateDir, "*"+TemplateExt))
+	if err != nil {
+		return fmt.Errorf("template_dir: %w", err)
+	}
+	sort.Strings(files)
+
+	partials := template.New("").Funcs(config.Humanize().Funcs())
+	for _, file := range files {
+		source, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return fmt.Errorf("template_dir: %w", err)
+		}
+		// The newline ending the file is not part of the template, so
+		// partials can be included in one-line titles
+		name := strings.TrimSuffix(filepath.Base(file), TemplateExt)
+		source = bytes.TrimSuffix(bytes.TrimSuffix(source, []byte("\n")), []byte("\r"))
+		if _, err := partials.New(name).Parse(string(source)); err != nil {
+			return fmt.Errorf("template_dir: %s: %w", filepath.Base(file), err)
+		}
+	}
+	for _, tmpl := range partials.Templates() {
+		switch tmpl.Name() {
+		case "title", "body", "overrides":
+			return fmt.Errorf("template_dir: %s: the name is reserved for message templates", tmpl.Name())
+		}
+		if err := checkIncludes(tmpl, tmpl.Tree); err != nil {
+			return fmt.Errorf("template_dir: %s: %w", tmpl.Name(), err)
+		}
+	}
+
+	config.partials = partials
+	return nil
+}
+
+// newTemplate parses a title or body with access to the partials
+func (c *Config) newTemplate(name, source string) (*template.Template, error) {
+	root := template.New(name).Funcs(c.Humanize().Funcs())
+	if c.partials != nil {
+		var err error
+		if root, err = c.partials.Clone(); err != nil {
+			return nil, err
+		}
+		root = root.New(name)
+	}
+
+	tmpl, err := root.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIncludes(tmpl, tmpl.Tree); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// extendTemplate returns a body rendering the layout, with the blocks the
+// overrides define replacing those of the layout
+func (c *Config) extendTemplate(layout, overrides string) (*template.Template, error) {
+	if c.partials == nil {
+		return nil, fmt.Errorf("extends requires template_dir")
+	}
+	if c.partials.Lookup(layout) == nil {
+		return nil, fmt.Errorf("unknown layout '%s' in %s", layout, c.TemplateDir)
+	}
+
+	tmpl, err := c.newTemplate("body", fmt.Sprintf("{{template %q .}}", layout))
+	if err != nil {
+		return nil, err
+	}
+	defined, err := tmpl.New("overrides").Parse(overrides)
+	if err != nil {
+		return nil, err
+	}
+	if defined.Tree != nil && !blank(defined.Tree.Root) {
+		return nil, fmt.Errorf("a body extending a layout may only contain {{define}} blocks")
+	}
+	for _, block := range tmpl.Templates() {
+		if err := checkIncludes(tmpl, block.Tree); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// checkIncludes reports templates included by tree that are not defined,
+// which would otherwise only fail when an event is rendered
+func checkIncludes(tmpl *template.Template, tree *parse.Tree) error {
+	if tree == nil {
+		return nil
+	}
+	var missing string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			if missing == "" && tmpl.Lookup(n.Name) == nil {
+				missing = n.Name
+			}
+		}
+	}
+	walk(tree.Root)
+
+	if missing != "" {
+		return fmt.Errorf("template %q is not defined", missing)
+	}
+	return nil
+}
+
+// blank reports whether a parsed template only holds white space
+func blank(list *parse.ListNode) bool {
+	for _, node := range list.Nodes {
+		text, ok := node.(*parse.TextNode)
+		if !ok || len(bytes.TrimSpace(text.Text)) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// DefaultTransformTimeout is the time a mutator script may take in seconds
+const DefaultTransformTimeout = 5
+
+// Transform modifies events before connectors run, for site-specific tweaks
+// such as normalizing jail names or dropping fields. Transforms apply in
+// order to the events their match selects. Set values are Go templates
+// executed with the event, and keys are the JSON field names of the event;
+// "labels.<name>" addresses a label.
+type Transform struct {
+	Name    string            `json:"name,omitempty"`
+	Match   *RouteMatch       `json:"match,omitempty"`   // Conditions as in routing rules; all events if unset
+	Set     map[string]string `json:"set,omitempty"`     // Field -> template of its new value
+	Drop    []string          `json:"drop,omitempty"`    // Fields removed from the event
+	Script  string            `json:"script,omitempty"`  // Mutator reading the event as JSON on stdin and writing the new event to stdout
+	Timeout int               `json:"timeout,omitempty"` // Seconds the script may take (default: 5)
+
+	set map[string]*template.Template
+}
+
+// Label names the transform in errors
+func (t *Transform) Label(i int) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("transforms[%d]", i)
+}
+
+// SetTemplates returns the parsed templates of Set
+func (t *Transform) SetTemplates() map[string]*template.Template {
+	return t.set
+}
+
+// TransformFuncs are the functions available in transform templates, in
+// addition to the formatting functions of the locale
+var TransformFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"regexReplace": func(pattern, replacement, s string) (string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(s, replacement), nil
+	},
+}
+
+// validTransformKey reports whether key names a field of the event, or a
+// label as "labels.<name>"
+func validTransformKey(key string) bool {
+	field, label, nested := strings.Cut(key, ".")
+	if nested {
+		return field == "labels" && label != ""
+	}
+
+	fields := reflect.TypeOf(types.NotificationData{})
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ",")
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTransforms parses the templates and checks the scripts of the
+// transforms
+func validateTransforms(config *Config) error {
+	for i := range config.Transforms {
+		t := &config.Transforms[i]
+		name := t.Label(i)
+
+		if len(t.Set) == 0 && len(t.Drop) == 0 && t.Script == "" {
+			return fmt.Errorf("transforms: %s: needs set, drop or script", name)
+		}
+
+		if t.Match != nil {
+			if err := t.Match.parse(); err != nil {
+				return fmt.Errorf("transforms: %s: %w", name, err)
+			}
+		}
+
+		t.set = make(map[string]*template.Template, len(t.Set))
+		for key, value := range t.Set {
+			if !validTransformKey(key) {
+				return fmt.Errorf("transforms: %s: invalid field '%s'", name, key)
+			}
+			tmpl, err := template.New(key).Funcs(config.Humanize().Funcs()).Funcs(TransformFuncs).Option("missingkey=error").Parse(value)
+			if err != nil {
+				return fmt.Errorf("transforms: %s: invalid template for '%s': %w", name, key, err)
+			}
+			t.set[key] = tmpl
+		}
+
+		for _, key := range t.Drop {
+			if !validTransformKey(key) {
+				return fmt.Errorf("transforms: %s: invalid field '%s'", name, key)
+			}
+		}
+
+		if t.Script != "" && !filepath.IsAbs(t.Script) {
+			return fmt.Errorf("transforms: %s: script must be an absolute path: %s", name, t.Script)
+		}
+		if t.Timeout < 0 {
+			return fmt.Errorf("transforms: %s: timeout cannot be negative", name)
+		}
+		if t.Timeout == 0 {
+			t.Timeout = DefaultTransformTimeout
+		}
+	}
+	return nil
+}
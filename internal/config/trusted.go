@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// TrustedCountriesConfig lists the countries the users come from. Bans from
+// other countries are routine; a ban from a trusted country may be
+// credential stuffing against real accounts and is flagged as such.
+type TrustedCountriesConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Countries []string `json:"countries"`       // ISO country codes or names
+	Jails     []string `json:"jails,omitempty"` // Jail names or glob patterns; all jails when empty
+}
+
+// Trusts reports whether the ban comes from a trusted country. The client
+// behind a banned reverse proxy counts instead of the proxy when its
+// location is known.
+func (t *TrustedCountriesConfig) Trusts(data *types.NotificationData) bool {
+	if !t.Enabled || !data.IsBan() || (len(t.Jails) > 0 && !matchesPattern(t.Jails, data.Jail)) {
+		return false
+	}
+	code, country := data.CountryCode, data.Country
+	if c := data.Client; c != nil && (c.CountryCode != "" || c.Country != "") {
+		code, country = c.CountryCode, c.Country
+	}
+	return (code != "" && containsFold(t.Countries, code)) || (country != "" && containsFold(t.Countries, country))
+}
+
+// validateTrustedCountriesConfig checks the trusted countries, which rely on
+// the GeoIP lookup
+func validateTrustedCountriesConfig(config *Config) error {
+	trusted := &config.Trusted
+	if !trusted.Enabled {
+		return nil
+	}
+
+	if len(trusted.Countries) == 0 {
+		return fmt.Errorf("trusted_countries: countries cannot be empty")
+	}
+	for _, country := range trusted.Countries {
+		if strings.TrimSpace(country) == "" {
+			return fmt.Errorf("trusted_countries: country names cannot be empty")
+		}
+	}
+	for _, pattern := range trusted.Jails {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("trusted_countries: invalid jail pattern '%s': %w", pattern, err)
+		}
+	}
+	if !config.GeoIP.Enabled {
+		return fmt.Errorf("trusted_countries: requires geoip")
+	}
+	return nil
+}
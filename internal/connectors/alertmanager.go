@@ -0,0 +1,189 @@
+package connectors
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeAlertmanager posts alerts to the Prometheus Alertmanager API
+const ConnectorTypeAlertmanager = "alertmanager"
+
+// Alertmanager defaults
+const (
+	defaultAlertName      = "Fail2BanBan"
+	defaultSurgeAlertName = "Fail2BanSurge"
+	defaultSeverity       = "warning"
+	defaultSurgeSeverity  = "critical"
+	defaultBreachSeverity = "critical"
+)
+
+// alertmanagerAlert is an alert in the format of POST /api/v2/alerts
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+func init() {
+	registerNative(ConnectorTypeAlertmanager, nativeConnector{validate: validateAlertmanager, execute: executeAlertmanager})
+}
+
+// validateAlertmanager checks the Alertmanager connector settings
+func validateAlertmanager(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "url"); err != nil {
+		return err
+	}
+
+	if _, err := url.ParseRequestURI(connector.Settings["url"]); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	if _, err := parseRiskSeverity(connector.Settings["risk_severity"]); err != nil {
+		return fmt.Errorf("invalid risk_severity: %w", err)
+	}
+
+	for _, key := range []string{"jail_severity", "labels"} {
+		if _, err := parseKeyValueList(connector.Settings[key]); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// executeAlertmanager fires an alert on ban that ends when the ban expires,
+// and resolves it on unban. Surges fire a separate alert for their window.
+func executeAlertmanager(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if data.IsRestore() || data.IsBulkUnban() {
+		return nil // Summaries have nothing to fire or resolve
+	}
+
+	severity := settingOr(connector, "severity", defaultSeverity)
+	riskSeverity, _ := parseRiskSeverity(connector.Settings["risk_severity"])
+	for _, level := range riskSeverity {
+		if data.RiskScore >= level.threshold {
+			severity = level.severity
+			break
+		}
+	}
+	jailSeverity, _ := parseKeyValueList(connector.Settings["jail_severity"])
+	if value, ok := jailSeverity[data.Jail]; ok {
+		severity = value
+	}
+	if data.PossibleBreach() {
+		severity = settingOr(connector, "breach_severity", defaultBreachSeverity)
+	}
+
+	labels, _ := parseKeyValueList(connector.Settings["labels"])
+	for key, value := range data.Labels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+	labels["alertname"] = settingOr(connector, "alertname", defaultAlertName)
+	labels["ip"] = data.IP
+	labels["jail"] = data.Jail
+	labels["severity"] = severity
+	if data.IsSurge() {
+		labels["alertname"] = settingOr(connector, "surge_alertname", defaultSurgeAlertName)
+		labels["severity"] = settingOr(connector, "surge_severity", defaultSurgeSeverity)
+		delete(labels, "ip")
+	}
+	if data.Country != "" {
+		labels["country"] = data.Country
+	}
+	if data.CountryCode != "" {
+		labels["country_code"] = data.CountryCode
+	}
+	if data.Hostname != "" {
+		labels["instance"] = data.Hostname
+	}
+
+	alert := alertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     m.messageTitle(connector, data),
+			"description": m.messageText(connector, data),
+		},
+		StartsAt:     data.Time,
+		GeneratorURL: connector.Settings["generator_url"],
+	}
+
+	if data.IsUnban() {
+		// An alert ending now resolves the firing alert with the same labels
+		alert.EndsAt = &data.Time
+	} else if data.IsSurge() && data.Surge != nil {
+		// A surge lasts at least until its window is over
+		endsAt := data.Time.Add(time.Duration(data.Surge.Window) * time.Second)
+		alert.EndsAt = &endsAt
+	} else if data.BanTime > 0 {
+		endsAt := data.Time.Add(time.Duration(data.BanTime) * time.Second)
+		alert.EndsAt = &endsAt
+	}
+
+	headers := map[string]string{}
+	if token := connector.Settings["bearer_token"]; token != "" {
+		headers["Authorization"] = "Bearer " + token
+	} else if user := connector.Settings["username"]; user != "" {
+		credentials := user + ":" + connector.Settings["password"]
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	endpoint := strings.TrimRight(connector.Settings["url"], "/") + "/api/v2/alerts"
+	return m.doJSON(ctx, http.MethodPost, endpoint, headers, []alertmanagerAlert{alert}, nil)
+}
+
+// parseKeyValueList parses a comma-separated list of key=value pairs
+func parseKeyValueList(value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("expected key=value, got '%s'", item)
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return pairs, nil
+}
+
+// riskLevel maps a minimum risk score to a severity
+type riskLevel struct {
+	severity  string
+	threshold int
+}
+
+// parseRiskSeverity parses "critical=80, warning=50" into levels ordered
+// from the highest threshold down
+func parseRiskSeverity(value string) ([]riskLevel, error) {
+	pairs, err := parseKeyValueList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := make([]riskLevel, 0, len(pairs))
+	for severity, threshold := range pairs {
+		n, err := strconv.Atoi(threshold)
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("threshold of %s must be a risk score between 1 and 100", severity)
+		}
+		levels = append(levels, riskLevel{severity: severity, threshold: n})
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].threshold > levels[j].threshold })
+	return levels, nil
+}
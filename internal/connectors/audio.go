@@ -0,0 +1,190 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeAudio plays a sound or speaks an alert on the local machine
+const ConnectorTypeAudio = "audio"
+
+// defaultAudioCooldown is the minimum number of seconds between two alerts
+const defaultAudioCooldown = 30
+
+// Players and speech synthesizers tried in order when none is configured
+var (
+	audioPlayers = map[string][]string{
+		"darwin": {"afplay"},
+		"linux":  {"paplay", "pw-play", "aplay"},
+	}
+	speechSynthesizers = map[string][]string{
+		"darwin": {"say"},
+		"linux":  {"espeak-ng", "espeak"},
+	}
+)
+
+func init() {
+	registerNative(ConnectorTypeAudio, nativeConnector{validate: validateAudio, execute: executeAudio})
+}
+
+// validateAudio checks the audio connector settings
+func validateAudio(connector *config.ConnectorConfig) error {
+	sounds, err := parseKeyValueList(connector.Settings["jail_sounds"])
+	if err != nil {
+		return fmt.Errorf("invalid jail_sounds: %w", err)
+	}
+	if sound := connector.Settings["sound"]; sound != "" {
+		sounds[""] = sound
+	}
+	for _, sound := range sounds {
+		if _, err := os.Stat(sound); err != nil {
+			return fmt.Errorf("sound file not found: %w", err)
+		}
+	}
+
+	if value := connector.Settings["cooldown"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("cooldown must be a non-negative number of seconds: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// executeAudio plays the jail's sound, or speaks the alert when the jail has
+// none. Alerts are skipped for jails not listed in 'jails', during the
+// cooldown after the previous alert and while another alert is playing.
+func executeAudio(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if data.IsUnban() && connector.Settings["unban"] != "true" {
+		return nil
+	}
+	if jails := connector.Settings["jails"]; jails != "" && !containsItem(jails, data.Jail) {
+		return nil
+	}
+
+	base := filepath.Join(m.config.StateDir, "audio", filepath.Base(connector.Name))
+
+	// Never play two alerts at once
+	unlock, err := state.TryLock(base + ".lock")
+	if errors.Is(err, state.ErrLocked) {
+		if m.config.Debug {
+			m.logger.Printf("Audio connector %s: skipping alert, another one is playing", connector.Name)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cooldown := defaultAudioCooldown
+	if value := connector.Settings["cooldown"]; value != "" {
+		cooldown, _ = strconv.Atoi(value)
+	}
+
+	var last time.Time
+	if err := state.Load(base+".json", &last); err != nil {
+		return err
+	}
+	if data.Time.Sub(last) < time.Duration(cooldown)*time.Second {
+		if m.config.Debug {
+			m.logger.Printf("Audio connector %s: skipping alert within %ds cooldown", connector.Name, cooldown)
+		}
+		return nil
+	}
+	if err := state.Save(base+".json", data.Time); err != nil {
+		return err
+	}
+
+	name, args, err := audioCommand(connector, data)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	if err := desktopSession(cmd, connector.Settings["user"]); err != nil {
+		return err
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w, stderr: %s", filepath.Base(name), err, stderr.String())
+	}
+	return nil
+}
+
+// audioCommand returns the player and its arguments for the jail's sound
+// file, or the speech synthesizer and the spoken text
+func audioCommand(connector *config.ConnectorConfig, data *types.NotificationData) (string, []string, error) {
+	sounds, _ := parseKeyValueList(connector.Settings["jail_sounds"])
+	sound, ok := sounds[data.Jail]
+	if !ok {
+		sound = connector.Settings["sound"]
+	}
+
+	if sound != "" {
+		player, err := findProgram(connector.Settings["player"], audioPlayers[runtime.GOOS])
+		if err != nil {
+			return "", nil, fmt.Errorf("no audio player found: %w", err)
+		}
+		return player, []string{sound}, nil
+	}
+
+	synthesizer, err := findProgram(connector.Settings["speech"], speechSynthesizers[runtime.GOOS])
+	if err != nil {
+		return "", nil, fmt.Errorf("no speech synthesizer found: %w", err)
+	}
+
+	text := "Fail2ban alert: " + data.String()
+	if custom := connector.Settings["text"]; custom != "" {
+		text = strings.NewReplacer(
+			"{ip}", data.IP,
+			"{jail}", data.Jail,
+			"{action}", data.Action,
+			"{country}", data.Country,
+			"{hostname}", data.Hostname,
+		).Replace(custom)
+	}
+
+	// "--" keeps text starting with a dash from being read as an option
+	return synthesizer, []string{"--", text}, nil
+}
+
+// findProgram returns the path of the configured program, or of the first
+// candidate found in PATH
+func findProgram(configured string, candidates []string) (string, error) {
+	if configured != "" {
+		return exec.LookPath(configured)
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s in PATH", strings.Join(candidates, ", "))
+}
+
+// containsItem reports whether the comma-separated list contains item
+func containsItem(list, item string) bool {
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeAzureMonitor posts events to the Azure Monitor HTTP Data
+// Collector API of a Log Analytics workspace
+const ConnectorTypeAzureMonitor = "azure_monitor"
+
+// Azure Monitor defaults
+const (
+	defaultAzureDomain  = "ods.opinsights.azure.com"
+	defaultAzureLogType = "Fail2Ban" // Stored as the Fail2Ban_CL table
+	azureAPIVersion     = "2016-04-01"
+)
+
+// azureLogType matches valid custom log type names
+var azureLogType = regexp.MustCompile(`^[A-Za-z0-9_]{1,100}$`)
+
+func init() {
+	registerNative(ConnectorTypeAzureMonitor, nativeConnector{validate: validateAzureMonitor, execute: executeAzureMonitor})
+}
+
+// validateAzureMonitor checks the Azure Monitor connector settings
+func validateAzureMonitor(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "workspace_id", "shared_key"); err != nil {
+		return err
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(connector.Settings["shared_key"]); err != nil {
+		return fmt.Errorf("shared_key must be base64 encoded: %w", err)
+	}
+
+	if logType := settingOr(connector, "log_type", defaultAzureLogType); !azureLogType.MatchString(logType) {
+		return fmt.Errorf("log_type may only contain letters, digits and underscores: %s", logType)
+	}
+
+	return nil
+}
+
+// executeAzureMonitor posts the event as a record of the custom log type
+func executeAzureMonitor(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	body, err := jsonBytes([]*types.NotificationData{data})
+	if err != nil {
+		return err
+	}
+
+	workspaceID := connector.Settings["workspace_id"]
+	date := m.clock.Now().UTC().Format(http.TimeFormat)
+
+	signature, err := azureSignature(connector.Settings["shared_key"], len(body), date)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Authorization":        "SharedKey " + workspaceID + ":" + signature,
+		"Log-Type":             settingOr(connector, "log_type", defaultAzureLogType),
+		"x-ms-date":            date,
+		"time-generated-field": "time",
+	}
+
+	url := fmt.Sprintf("https://%s.%s/api/logs?api-version=%s",
+		workspaceID, settingOr(connector, "domain", defaultAzureDomain), azureAPIVersion)
+	return m.doJSON(ctx, http.MethodPost, url, headers, body, nil)
+}
+
+// azureSignature signs a Data Collector API request with the workspace key
+func azureSignature(sharedKey string, contentLength int, date string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(sharedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode shared_key: %w", err)
+	}
+
+	stringToSign := "POST\n" + strconv.Itoa(contentLength) + "\n" + ContentTypeJSON + "\nx-ms-date:" + date + "\n/api/logs"
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,124 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// batchBacklogFactor limits the rows kept for a failing batch connector to
+// this many batches; older rows are dropped
+const batchBacklogFactor = 10
+
+// batchFlushFunc delivers a batch of rows
+type batchFlushFunc func(ctx context.Context, connector *config.ConnectorConfig, rows [][]byte) error
+
+// pendingBatch holds the rows queued for one connector
+type pendingBatch struct {
+	connector config.ConnectorConfig
+	flush     batchFlushFunc
+	rows      [][]byte
+}
+
+// batches collects rows of batching connectors. Batching is only enabled in
+// a long-running process, which flushes the queued rows periodically and on
+// shutdown; a single CLI invocation delivers its event directly.
+var batches = struct {
+	sync.Mutex
+	enabled bool
+	pending map[string]*pendingBatch
+}{
+	pending: make(map[string]*pendingBatch),
+}
+
+// EnableBatching makes batching connectors queue rows until FlushBatches is
+// called or a batch is full
+func EnableBatching() {
+	batches.Lock()
+	defer batches.Unlock()
+	batches.enabled = true
+}
+
+// batchingEnabled reports whether EnableBatching was called
+func batchingEnabled() bool {
+	batches.Lock()
+	defer batches.Unlock()
+	return batches.enabled
+}
+
+// enqueueBatch queues row for the connector and flushes the batch once it
+// holds size rows. A failed flush keeps the rows for the next attempt.
+func (m *Manager) enqueueBatch(ctx context.Context, connector *config.ConnectorConfig, size int, flush batchFlushFunc, row []byte) {
+	batches.Lock()
+	batch, ok := batches.pending[connector.Name]
+	if !ok {
+		batch = &pendingBatch{}
+		batches.pending[connector.Name] = batch
+	}
+	// Keep the latest settings for reloaded configurations
+	batch.connector = *connector
+	batch.flush = flush
+	batch.rows = append(batch.rows, row)
+
+	if limit := size * batchBacklogFactor; len(batch.rows) > limit {
+		dropped := len(batch.rows) - limit
+		batch.rows = batch.rows[dropped:]
+		m.logger.Printf("Connector %s: dropped %d queued rows, backlog limit reached", connector.Name, dropped)
+	}
+
+	full := len(batch.rows) >= size
+	batches.Unlock()
+
+	if full {
+		if err := flushBatch(ctx, connector.Name); err != nil {
+			m.logger.Printf("Connector %s: %v", connector.Name, err)
+		}
+	}
+}
+
+// FlushBatches delivers the rows queued by all batching connectors
+func FlushBatches(ctx context.Context) error {
+	batches.Lock()
+	names := make([]string, 0, len(batches.pending))
+	for name := range batches.pending {
+		names = append(names, name)
+	}
+	batches.Unlock()
+
+	var firstErr error
+	for _, name := range names {
+		if err := flushBatch(ctx, name); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("connector %s: %w", name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// flushBatch delivers the rows queued for one connector, putting them back
+// in front of newer rows when delivery fails
+func flushBatch(ctx context.Context, name string) error {
+	batches.Lock()
+	batch, ok := batches.pending[name]
+	if !ok || len(batch.rows) == 0 {
+		batches.Unlock()
+		return nil
+	}
+	rows := batch.rows
+	batch.rows = nil
+	connector := batch.connector
+	flush := batch.flush
+	batches.Unlock()
+
+	if err := flush(ctx, &connector, rows); err != nil {
+		batches.Lock()
+		batch.rows = append(rows, batch.rows...)
+		batches.Unlock()
+		return fmt.Errorf("failed to flush %d rows: %w", len(rows), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeBGP announces banned IPs through a GoBGP daemon
+const ConnectorTypeBGP = "bgp"
+
+// BGP announcement modes
+const (
+	BGPModeRTBH     = "rtbh"     // Remotely triggered blackhole: host route with blackhole community
+	BGPModeFlowSpec = "flowspec" // FlowSpec rule discarding traffic from the address
+)
+
+// BGP defaults
+const (
+	defaultBGPNextHop     = "192.0.2.1" // RFC 5737 address commonly routed to null
+	defaultBGPNextHop6    = "100::1"    // RFC 6666 discard prefix
+	defaultBGPCommunity   = "65535:666" // RFC 7999 BLACKHOLE
+	defaultBGPMaxPrefixes = 1000
+)
+
+func init() {
+	registerNative(ConnectorTypeBGP, nativeConnector{validate: validateBGP, execute: executeBGP})
+}
+
+// validateBGP checks the BGP connector settings
+func validateBGP(connector *config.ConnectorConfig) error {
+	switch mode := settingOr(connector, "mode", BGPModeRTBH); mode {
+	case BGPModeRTBH, BGPModeFlowSpec:
+	default:
+		return fmt.Errorf("invalid mode '%s', must be '%s' or '%s'", mode, BGPModeRTBH, BGPModeFlowSpec)
+	}
+
+	if value := connector.Settings["max_prefixes"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("max_prefixes must be a positive number: %s", value)
+		}
+	}
+
+	if _, err := parseCIDRList(connector.Settings["allowed_ranges"]); err != nil {
+		return fmt.Errorf("invalid allowed_ranges: %w", err)
+	}
+
+	return nil
+}
+
+// executeBGP adds a route on ban and withdraws it on unban, refusing
+// addresses outside the allowed ranges and announcements beyond the limit
+func executeBGP(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	ip := net.ParseIP(data.IP)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address: %s", data.IP)
+	}
+
+	if data.IsBan() {
+		if reason := bgpRefusal(connector, ip); reason != "" {
+			m.logger.Printf("BGP connector %s: not announcing %s: %s", connector.Name, data.IP, reason)
+			return nil
+		}
+	}
+
+	maxPrefixes := defaultBGPMaxPrefixes
+	if value := connector.Settings["max_prefixes"]; value != "" {
+		maxPrefixes, _ = strconv.Atoi(value)
+	}
+
+	// Announced address -> time of announcement, encrypted like the store
+	announced := make(map[string]time.Time)
+	statePath := filepath.Join(m.config.StateDir, "bgp", filepath.Base(connector.Name)+".json")
+
+	return store.New(m.config.Store).UpdateState(statePath, &announced, func() error {
+		if data.IsUnban() {
+			if _, ok := announced[data.IP]; !ok {
+				return nil // Never announced, e.g. refused or added before the connector
+			}
+			if err := runGoBGP(ctx, connector, bgpArgs(connector, ip, "del")); err != nil {
+				return err
+			}
+			delete(announced, data.IP)
+			return nil
+		}
+
+		if _, ok := announced[data.IP]; !ok && len(announced) >= maxPrefixes {
+			return fmt.Errorf("prefix limit of %d announcements reached, not announcing %s", maxPrefixes, data.IP)
+		}
+
+		if err := runGoBGP(ctx, connector, bgpArgs(connector, ip, "add")); err != nil {
+			return err
+		}
+		announced[data.IP] = data.Time
+		return nil
+	})
+}
+
+// bgpRefusal returns why ip must not be announced, or an empty string
+func bgpRefusal(connector *config.ConnectorConfig, ip net.IP) string {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return "not a public unicast address"
+	}
+
+	allowed, _ := parseCIDRList(connector.Settings["allowed_ranges"])
+	if len(allowed) == 0 {
+		return ""
+	}
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return ""
+		}
+	}
+	return "outside allowed_ranges"
+}
+
+// bgpArgs returns the gobgp command line adding or deleting the route for ip
+func bgpArgs(connector *config.ConnectorConfig, ip net.IP, op string) []string {
+	var args []string
+	if host := connector.Settings["api_host"]; host != "" {
+		args = append(args, "-u", host)
+	}
+	if port := connector.Settings["api_port"]; port != "" {
+		args = append(args, "-p", port)
+	}
+
+	family, prefix, nextHop := "ipv4", ip.String()+"/32", settingOr(connector, "next_hop", defaultBGPNextHop)
+	if ip.To4() == nil {
+		family, prefix, nextHop = "ipv6", ip.String()+"/128", settingOr(connector, "next_hop6", defaultBGPNextHop6)
+	}
+
+	if settingOr(connector, "mode", BGPModeRTBH) == BGPModeFlowSpec {
+		args = append(args, "global", "rib", "-a", family+"-flowspec", op, "match", "source", prefix)
+		if op == "add" {
+			args = append(args, "then", "discard")
+		}
+		return args
+	}
+
+	args = append(args, "global", "rib", "-a", family, op, prefix)
+	if op == "add" {
+		args = append(args, "nexthop", nextHop, "community", settingOr(connector, "community", defaultBGPCommunity))
+	}
+	return args
+}
+
+// runGoBGP runs the gobgp client, which talks to the daemon's gRPC API
+func runGoBGP(ctx context.Context, connector *config.ConnectorConfig, args []string) error {
+	path, err := exec.LookPath(settingOr(connector, "gobgp_path", "gobgp"))
+	if err != nil {
+		return fmt.Errorf("gobgp client not found: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gobgp %s failed: %w, stderr: %s", strings.Join(args, " "), err, stderr.String())
+	}
+	return nil
+}
+
+// parseCIDRList parses a comma-separated list of networks
+func parseCIDRList(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
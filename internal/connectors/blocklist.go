@@ -0,0 +1,113 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/blocklist" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"          //nolint:depguard
+)
+
+// ConnectorTypeBlocklist maintains a blocklist file from bans and unbans
+const ConnectorTypeBlocklist = "blocklist"
+
+// Blocklist file formats
+const (
+	BlocklistFormatPlain    = blocklist.FormatPlain
+	BlocklistFormatNginx    = blocklist.FormatNginx
+	BlocklistFormatIPSet    = blocklist.FormatIPSet
+	BlocklistFormatMikroTik = blocklist.FormatMikroTik
+	BlocklistFormatEDL      = blocklist.FormatEDL
+	blocklistFilePerm       = 0644
+)
+
+func init() {
+	registerNative(ConnectorTypeBlocklist, nativeConnector{validate: validateBlocklist, execute: executeBlocklist})
+}
+
+// validateBlocklist checks the blocklist connector settings
+func validateBlocklist(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "file"); err != nil {
+		return err
+	}
+
+	if !filepath.IsAbs(connector.Settings["file"]) {
+		return fmt.Errorf("blocklist file must be an absolute path: %s", connector.Settings["file"])
+	}
+
+	return validateBlocklistFormat(connector)
+}
+
+// validateBlocklistFormat checks the 'format' setting
+func validateBlocklistFormat(connector *config.ConnectorConfig) error {
+	if format := settingOr(connector, "format", BlocklistFormatPlain); !blocklist.ValidFormat(format) {
+		return fmt.Errorf("invalid blocklist format '%s', must be one of: %s", format, strings.Join(blocklist.Formats, ", "))
+	}
+
+	return nil
+}
+
+// executeBlocklist updates the listed addresses, atomically rewrites the
+// blocklist file and runs the post-update hook
+func executeBlocklist(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	// Address -> expiry (zero if permanent), encrypted like the store
+	listed := make(map[string]time.Time)
+	statePath := filepath.Join(m.config.StateDir, "blocklist", filepath.Base(connector.Name)+".json")
+
+	err := store.New(m.config.Store).UpdateState(statePath, &listed, func() error {
+		if data.IsBan() {
+			var expires time.Time
+			if data.BanTime > 0 {
+				expires = data.Time.Add(time.Duration(data.BanTime) * time.Second)
+			}
+			listed[data.IP] = expires
+		} else if data.IsUnban() {
+			delete(listed, data.IP)
+		}
+
+		for ip, expires := range listed {
+			if !expires.IsZero() && data.Time.After(expires) {
+				delete(listed, ip)
+			}
+		}
+
+		return state.WriteFileAtomic(connector.Settings["file"], renderBlocklist(connector, listed), blocklistFilePerm)
+	})
+	if err != nil {
+		return err
+	}
+
+	hook := connector.Settings["hook"]
+	if hook == "" {
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hook)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("blocklist hook failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+// renderBlocklist formats the listed addresses. The ipset and MikroTik
+// formats replace the list named by 'list_name', or the older 'ipset_name'.
+func renderBlocklist(connector *config.ConnectorConfig, listed map[string]time.Time) []byte {
+	ips := make([]string, 0, len(listed))
+	for ip := range listed {
+		ips = append(ips, ip)
+	}
+
+	listName := settingOr(connector, "list_name", settingOr(connector, "ipset_name", blocklist.DefaultListName))
+	return blocklist.Render(settingOr(connector, "format", BlocklistFormatPlain), connector.Name, listName, ips)
+}
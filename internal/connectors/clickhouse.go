@@ -0,0 +1,135 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeClickHouse inserts events into a ClickHouse table over HTTP
+const ConnectorTypeClickHouse = "clickhouse"
+
+// ClickHouse defaults
+const (
+	defaultClickHouseURL       = "http://127.0.0.1:8123"
+	defaultClickHouseTable     = "fail2ban_events"
+	defaultClickHouseBatchSize = 500
+	clickHouseTimeFormat       = "2006-01-02 15:04:05" // DateTime input format
+)
+
+func init() {
+	registerNative(ConnectorTypeClickHouse, nativeConnector{validate: validateClickHouse, execute: executeClickHouse})
+}
+
+// validateClickHouse checks the ClickHouse connector settings
+func validateClickHouse(connector *config.ConnectorConfig) error {
+	if _, err := url.ParseRequestURI(settingOr(connector, "url", defaultClickHouseURL)); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	for _, key := range []string{"database", "table"} {
+		if value := connector.Settings[key]; value != "" && !sqlIdentifier.MatchString(value) {
+			return fmt.Errorf("invalid %s name '%s'", key, value)
+		}
+	}
+
+	if value := connector.Settings["batch_size"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("batch_size must be a positive number: %s", value)
+		}
+	}
+
+	if err := config.ValidateCompression(connector.Settings["compression"]); err != nil {
+		return err
+	}
+
+	if value := connector.Settings["async_insert"]; value != "" {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("async_insert must be true or false: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// executeClickHouse inserts the event, or queues it for the next batch when
+// batching is enabled
+func executeClickHouse(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	row, err := clickHouseRow(data)
+	if err != nil {
+		return err
+	}
+
+	if !batchingEnabled() {
+		return m.insertClickHouse(ctx, connector, [][]byte{row})
+	}
+
+	size := defaultClickHouseBatchSize
+	if value := connector.Settings["batch_size"]; value != "" {
+		size, _ = strconv.Atoi(value)
+	}
+	m.enqueueBatch(ctx, connector, size, m.insertClickHouse, row)
+	return nil
+}
+
+// clickHouseRow returns the event as a JSONEachRow line
+func clickHouseRow(data *types.NotificationData) ([]byte, error) {
+	row := make(map[string]interface{}, len(eventFields))
+	for name, value := range eventFields {
+		row[name] = value(data)
+	}
+	row["time"] = data.Time.UTC().Format(clickHouseTimeFormat)
+
+	return jsonBytes(row)
+}
+
+// insertClickHouse sends the rows in one INSERT ... FORMAT JSONEachRow
+func (m *Manager) insertClickHouse(ctx context.Context, connector *config.ConnectorConfig, rows [][]byte) error {
+	table := settingOr(connector, "table", defaultClickHouseTable)
+	if database := connector.Settings["database"]; database != "" {
+		table = database + "." + table
+	}
+
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	// Unknown fields are skipped so the table may store a subset of them
+	query.Set("input_format_skip_unknown_fields", "1")
+	if async, _ := strconv.ParseBool(settingOr(connector, "async_insert", "true")); async {
+		query.Set("async_insert", "1")
+		query.Set("wait_for_async_insert", "1")
+	}
+
+	endpoint := strings.TrimRight(settingOr(connector, "url", defaultClickHouseURL), "/") + "/?" + query.Encode()
+	req, err := newPayloadRequest(ctx, connector, http.MethodPost, endpoint, append(bytes.Join(rows, []byte("\n")), '\n'))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", UserAgent)
+	if user := connector.Settings["user"]; user != "" {
+		req.Header.Set("X-ClickHouse-User", user)
+		req.Header.Set("X-ClickHouse-Key", connector.Settings["password"])
+	}
+
+	resp, err := m.httpDoer(http.DefaultClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 400 {
+		body, _ := readResponse(resp, maxResponseBody)
+		return fmt.Errorf("insert failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
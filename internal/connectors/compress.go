@@ -0,0 +1,58 @@
+package connectors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// newPayloadRequest creates a request sending payload, gzipped when the
+// connector's compression setting is gzip
+func newPayloadRequest(ctx context.Context, connector *config.ConnectorConfig, method, url string, payload []byte) (*http.Request, error) {
+	compress := connector.Settings["compression"] == config.CompressionGzip
+	if compress {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(payload); err != nil {
+			return nil, fmt.Errorf("failed to compress payload: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress payload: %w", err)
+		}
+		payload = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if compress {
+		req.Header.Set("Content-Encoding", config.CompressionGzip)
+	}
+	return req, nil
+}
+
+// readResponse reads up to limit bytes of a response body, decompressing it
+// when the server gzipped it. Go only decompresses responses on its own when
+// it asked for gzip itself, not when a header_Accept-Encoding setting did.
+func readResponse(resp *http.Response, limit int64) ([]byte, error) {
+	var body io.Reader = resp.Body
+	if !resp.Uncompressed && strings.EqualFold(resp.Header.Get("Content-Encoding"), config.CompressionGzip) {
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		body = reader
+	}
+	// The limit applies after decompression
+	return io.ReadAll(io.LimitReader(body, limit))
+}
@@ -0,0 +1,90 @@
+package connectors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// withoutEscalation leaves out the escalation connectors, which are only
+// notified when a quorum is missed or an SLA expires
+func (m *Manager) withoutEscalation(connectors []config.ConnectorConfig) []config.ConnectorConfig {
+	escalation := m.slaConnectors()
+	if len(m.config.Delivery.Escalation) == 0 && len(escalation) == 0 {
+		return connectors
+	}
+
+	var kept []config.ConnectorConfig
+	for _, connector := range connectors {
+		if !containsName(m.config.Delivery.Escalation, connector.Name) && !containsName(escalation, connector.Name) {
+			kept = append(kept, connector)
+		}
+	}
+	return kept
+}
+
+// coalesceUnbans leaves out the connectors coalescing unbans for an unban
+// summarized by a bulk unban, and all other connectors for the bulk unban
+// itself
+func (m *Manager) coalesceUnbans(connectors []config.ConnectorConfig, data *types.NotificationData) []config.ConnectorConfig {
+	if !data.Coalesced && !data.IsBulkUnban() {
+		return connectors
+	}
+
+	var kept []config.ConnectorConfig
+	for _, connector := range connectors {
+		if connector.CoalesceUnbans == data.IsBulkUnban() {
+			kept = append(kept, connector)
+		}
+	}
+	return kept
+}
+
+// applyQuorum sets the quorum of the batch. A quorum larger than the number
+// of critical connectors that ran requires all of them.
+func (m *Manager) applyQuorum(batch *types.BatchResult) {
+	succeeded, failed := batch.CriticalCounts()
+	batch.Quorum = m.config.Delivery.Quorum
+	if batch.Quorum > succeeded+failed {
+		batch.Quorum = succeeded + failed
+	}
+}
+
+// escalate notifies the escalation connectors that an event of a critical
+// jail was not delivered by enough connectors
+func (m *Manager) escalate(batch *types.BatchResult, data *types.NotificationData) {
+	succeeded, failed := batch.CriticalCounts()
+	required := batch.Quorum
+	if required == 0 {
+		required = succeeded + failed
+	}
+
+	escalated := *data
+	escalated.Escalation = fmt.Sprintf("Only %d of %d required connectors delivered this event (failed: %s)",
+		succeeded, required, strings.Join(batch.GetFailedConnectors(), ", "))
+
+	for _, name := range m.config.Delivery.Escalation {
+		connector, found := m.config.GetConnectorByName(name)
+		if !found || !connector.Enabled {
+			continue
+		}
+
+		result, err := m.runConnector(connector, &escalated)
+		if err != nil {
+			m.logger.Printf("Error: escalation connector %s failed: %v", name, err)
+		}
+		batch.Escalation = append(batch.Escalation, result)
+	}
+}
+
+// containsName reports whether names contains name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
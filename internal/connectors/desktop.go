@@ -0,0 +1,174 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeDesktop raises a notification on the local desktop
+const ConnectorTypeDesktop = "desktop"
+
+// Desktop notification urgencies of the freedesktop specification
+var desktopUrgencies = map[string]int{"low": 0, "normal": 1, "critical": 2}
+
+func init() {
+	registerNative(ConnectorTypeDesktop, nativeConnector{validate: validateDesktop, execute: executeDesktop})
+}
+
+// validateDesktop checks the desktop connector settings
+func validateDesktop(connector *config.ConnectorConfig) error {
+	if urgency := connector.Settings["urgency"]; urgency != "" {
+		if _, ok := desktopUrgencies[urgency]; !ok {
+			return fmt.Errorf("invalid urgency '%s', must be 'low', 'normal' or 'critical'", urgency)
+		}
+	}
+
+	if value := connector.Settings["expire_ms"]; value != "" {
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("expire_ms must be a number: %s", value)
+		}
+	}
+
+	if name := connector.Settings["user"]; name != "" {
+		if _, err := user.Lookup(name); err != nil {
+			return fmt.Errorf("unknown user '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// executeDesktop shows the event through org.freedesktop.Notifications on
+// Linux or terminal-notifier on macOS
+func executeDesktop(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	title, body := m.messageTitle(connector, data), m.desktopBody(connector, data)
+
+	var name string
+	var args []string
+	if runtime.GOOS == "darwin" {
+		name = settingOr(connector, "notifier_path", "terminal-notifier")
+		args = []string{
+			"-title", "fail2ban-notify",
+			"-subtitle", title,
+			"-message", body,
+			"-group", "fail2ban-notify-" + data.IP,
+		}
+	} else {
+		name = settingOr(connector, "notifier_path", "gdbus")
+		args = desktopNotifyArgs(connector, data, title, body)
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("notifier not found: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	if err := desktopSession(cmd, connector.Settings["user"]); err != nil {
+		return err
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("desktop notification failed: %w, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// desktopBody returns the notification text: the body template, or the
+// fields other than IP and jail, which are in the title
+func (m *Manager) desktopBody(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if body, ok := tmpl.RenderBody(data); ok {
+			return strings.TrimSpace(body)
+		}
+	}
+
+	var body strings.Builder
+	for _, field := range m.messageFields(connector, data) {
+		if field.Key != "ip" && field.Key != "jail" {
+			fmt.Fprintf(&body, "%s: %s\n", field.Label, field.Value)
+		}
+	}
+	return strings.TrimSpace(body.String())
+}
+
+// desktopNotifyArgs returns the gdbus arguments calling the Notify method
+func desktopNotifyArgs(connector *config.ConnectorConfig, data *types.NotificationData, title, body string) []string {
+	urgency := desktopUrgencies["normal"]
+	if data.IsBan() {
+		urgency = desktopUrgencies["critical"]
+	}
+	if value, ok := desktopUrgencies[connector.Settings["urgency"]]; ok {
+		urgency = value
+	}
+
+	icon := "security-high"
+	if data.IsUnban() {
+		icon = "security-low"
+	}
+
+	return []string{
+		"call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		// app_name, replaces_id, app_icon, summary, body, actions, hints and
+		// expire_timeout, where -1 leaves the timeout to the server
+		gvariantString("fail2ban-notify"),
+		"0",
+		gvariantString(settingOr(connector, "icon", icon)),
+		gvariantString(title),
+		gvariantString(body),
+		"[]",
+		fmt.Sprintf("{'urgency': <byte %d>}", urgency),
+		settingOr(connector, "expire_ms", "-1"),
+	}
+}
+
+// desktopSession runs cmd as the desktop user and connects it to that
+// user's session bus and runtime directory (e.g. for the sound server),
+// since fail2ban runs as root
+func desktopSession(cmd *exec.Cmd, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	account, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("unknown user '%s': %w", name, err)
+	}
+	uid, _ := strconv.ParseUint(account.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(account.Gid, 10, 32)
+
+	cmd.Env = append(os.Environ(),
+		"HOME="+account.HomeDir,
+		"USER="+account.Username,
+		fmt.Sprintf("XDG_RUNTIME_DIR=/run/user/%d", uid),
+		fmt.Sprintf("DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/%d/bus", uid),
+	)
+	if uint64(os.Getuid()) != uid {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+		}
+	}
+
+	return nil
+}
+
+// gvariantString quotes s as a GVariant text format string
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`).Replace(s) + "'"
+}
@@ -0,0 +1,74 @@
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeDingTalk posts events to a DingTalk robot webhook
+const ConnectorTypeDingTalk = "dingtalk"
+
+// botResponse is the reply of DingTalk and WeCom bot webhooks, which report
+// errors with a non-zero errcode and HTTP status 200
+type botResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func init() {
+	registerNative(ConnectorTypeDingTalk, nativeConnector{validate: validateWebhookURL, execute: executeDingTalk})
+}
+
+// executeDingTalk posts the event as a Markdown message, signed when the
+// robot uses the "additional signature" security setting
+func executeDingTalk(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	webhookURL := connector.Settings["webhook_url"]
+	if secret := connector.Settings["secret"]; secret != "" {
+		timestamp := strconv.FormatInt(m.clock.Now().UnixMilli(), 10)
+		separator := "?"
+		if strings.Contains(webhookURL, "?") {
+			separator = "&"
+		}
+		webhookURL += separator + url.Values{
+			"timestamp": {timestamp},
+			"sign":      {dingTalkSignature(secret, timestamp)},
+		}.Encode()
+	}
+
+	// DingTalk needs blank lines to break lines in Markdown
+	body := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": m.messageTitle(connector, data),
+			"text":  strings.ReplaceAll(m.markdownMessage(connector, data), "\n", "\n\n"),
+		},
+	}
+
+	var resp botResponse
+	if err := m.doJSON(ctx, http.MethodPost, webhookURL, nil, body, &resp); err != nil {
+		return err
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("dingtalk robot rejected the message: %d %s", resp.ErrCode, resp.ErrMsg)
+	}
+
+	return nil
+}
+
+// dingTalkSignature signs "<timestamp>\n<secret>" with HMAC-SHA256 keyed
+// with the secret
+func dingTalkSignature(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,185 @@
+package connectors
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Connector types maintaining a firewall alias through the firewall's REST API
+const (
+	ConnectorTypeOPNsense = "opnsense"
+	ConnectorTypePfSense  = "pfsense"
+)
+
+// opnsenseStatus is the response of the OPNsense alias_util endpoints
+type opnsenseStatus struct {
+	Status string `json:"status"`
+}
+
+// pfsenseAlias is a firewall alias of the pfSense REST API package
+type pfsenseAlias struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	Address []string `json:"address"`
+	Detail  []string `json:"detail"`
+}
+
+// pfsenseAliases is the response of GET /api/v2/firewall/aliases
+type pfsenseAliases struct {
+	Data []pfsenseAlias `json:"data"`
+}
+
+func init() {
+	registerNative(ConnectorTypeOPNsense, nativeConnector{validate: validateFirewallAlias, execute: executeOPNsense})
+	registerNative(ConnectorTypePfSense, nativeConnector{validate: validateFirewallAlias, execute: executePfSense})
+}
+
+// validateFirewallAlias checks the OPNsense and pfSense connector settings
+func validateFirewallAlias(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "url", "alias", "api_key"); err != nil {
+		return err
+	}
+	if connector.Type == ConnectorTypeOPNsense {
+		if err := requireSettings(connector, "api_secret"); err != nil {
+			return err
+		}
+	}
+
+	if u, err := url.ParseRequestURI(connector.Settings["url"]); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("url must be an http(s) URL: %s", connector.Settings["url"])
+	}
+	if strings.ContainsAny(connector.Settings["alias"], "/?#") {
+		return fmt.Errorf("invalid alias name: %s", connector.Settings["alias"])
+	}
+
+	return validateTLSSettings(connector)
+}
+
+// executeOPNsense adds the banned address to the alias's table and deletes
+// it on unban. The alias must be of type External, whose table is only
+// changed through the API.
+func executeOPNsense(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !data.IsBan() && !data.IsUnban() {
+		return nil
+	}
+	ip, err := firewallAliasIP(data)
+	if err != nil {
+		return err
+	}
+	client, err := connectorHTTPClient(connector, connector.Settings["url"])
+	if err != nil {
+		return err
+	}
+
+	action := "add"
+	if data.IsUnban() {
+		action = "delete"
+	}
+	endpoint := strings.TrimRight(connector.Settings["url"], "/") + "/api/firewall/alias_util/" + action + "/" + url.PathEscape(connector.Settings["alias"])
+	credentials := connector.Settings["api_key"] + ":" + connector.Settings["api_secret"]
+	headers := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}
+
+	var status opnsenseStatus
+	if err := m.doJSONWith(ctx, client, http.MethodPost, endpoint, headers, map[string]string{"address": ip}, &status); err != nil {
+		return err
+	}
+	if status.Status != "done" {
+		return fmt.Errorf("failed to %s %s: alias %s returned status '%s'", action, ip, connector.Settings["alias"], status.Status)
+	}
+	return nil
+}
+
+// executePfSense adds the banned address to the alias and removes it on
+// unban through the pfSense REST API package, then applies the change. The
+// read-modify-write of the alias holds a lock so concurrent fail2ban actions
+// don't lose each other's updates.
+func executePfSense(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !data.IsBan() && !data.IsUnban() {
+		return nil
+	}
+	ip, err := firewallAliasIP(data)
+	if err != nil {
+		return err
+	}
+	client, err := connectorHTTPClient(connector, connector.Settings["url"])
+	if err != nil {
+		return err
+	}
+
+	unlock, err := state.Lock(filepath.Join(m.config.StateDir, "pfsense", filepath.Base(connector.Name)))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	base := strings.TrimRight(connector.Settings["url"], "/") + "/api/v2/firewall"
+	headers := map[string]string{"X-API-Key": connector.Settings["api_key"]}
+	name := connector.Settings["alias"]
+
+	var aliases pfsenseAliases
+	if err := m.doJSONWith(ctx, client, http.MethodGet, base+"/aliases?name="+url.QueryEscape(name), headers, nil, &aliases); err != nil {
+		return err
+	}
+	var alias *pfsenseAlias
+	for i := range aliases.Data {
+		if aliases.Data[i].Name == name {
+			alias = &aliases.Data[i]
+			break
+		}
+	}
+	if alias == nil {
+		return fmt.Errorf("alias %s not found", name)
+	}
+
+	// The details are the descriptions of the addresses, index by index
+	update := pfsenseAlias{ID: alias.ID, Address: []string{}, Detail: []string{}}
+	listed := false
+	for i, address := range alias.Address {
+		detail := ""
+		if i < len(alias.Detail) {
+			detail = alias.Detail[i]
+		}
+		if address == ip {
+			listed = true
+			if data.IsUnban() {
+				continue
+			}
+		}
+		update.Address = append(update.Address, address)
+		update.Detail = append(update.Detail, detail)
+	}
+	if listed == data.IsBan() {
+		return nil
+	}
+	if data.IsBan() {
+		update.Address = append(update.Address, ip)
+		update.Detail = append(update.Detail, fmt.Sprintf("fail2ban-notify: %s %s", data.Jail, data.Time.UTC().Format("2006-01-02 15:04")))
+	}
+
+	if err := m.doJSONWith(ctx, client, http.MethodPatch, base+"/alias", headers, update, nil); err != nil {
+		return fmt.Errorf("failed to update alias %s: %w", name, err)
+	}
+	if err := m.doJSONWith(ctx, client, http.MethodPost, base+"/apply", headers, map[string]interface{}{}, nil); err != nil {
+		return fmt.Errorf("failed to apply alias %s: %w", name, err)
+	}
+	return nil
+}
+
+// firewallAliasIP returns the event's address, which must be a real one
+func firewallAliasIP(data *types.NotificationData) (string, error) {
+	ip := net.ParseIP(data.IP)
+	if ip == nil {
+		return "", fmt.Errorf("%s is not an IP address, set raw_ip on the connector", data.IP)
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,273 @@
+package connectors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/msgpack" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// ConnectorTypeFluent forwards events to Fluentd or Fluent Bit with the
+// forward protocol
+const ConnectorTypeFluent = "fluent"
+
+// Fluent defaults
+const (
+	defaultFluentPort = "24224"
+	defaultFluentTag  = "fail2ban"
+)
+
+func init() {
+	registerNative(ConnectorTypeFluent, nativeConnector{validate: validateFluent, execute: executeFluent})
+}
+
+// validateFluent checks the Fluent connector settings
+func validateFluent(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "address"); err != nil {
+		return err
+	}
+
+	if _, _, err := net.SplitHostPort(fluentAddress(connector)); err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+
+	if connector.Settings["username"] != "" && connector.Settings["shared_key"] == "" {
+		return fmt.Errorf("username requires a shared_key")
+	}
+
+	for _, key := range []string{"tls", "require_ack"} {
+		if value := connector.Settings[key]; value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("%s must be 'true' or 'false': %s", key, value)
+		}
+	}
+
+	return validateTLSSettings(connector)
+}
+
+// executeFluent sends the event as a record tagged with tag, authenticating
+// with the shared key when one is set, and waits for the server's ack
+// unless require_ack is false
+func executeFluent(ctx context.Context, _ *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	record, err := fluentRecord(data)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialFluent(ctx, connector)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+
+	if connector.Settings["shared_key"] != "" {
+		if err := fluentHandshake(conn, reader, connector, data); err != nil {
+			return err
+		}
+	}
+
+	// Message mode: [tag, time, record, option]
+	option := map[string]interface{}{}
+	chunk := ""
+	if connector.Settings["require_ack"] != "false" {
+		chunk, err = fluentChunkID()
+		if err != nil {
+			return err
+		}
+		option["chunk"] = chunk
+	}
+	tag := settingOr(connector, "tag", defaultFluentTag)
+	if err := fluentWrite(conn, []interface{}{tag, msgpack.EventTime(data.Time), record, option}); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	response, err := fluentRead(reader)
+	if err != nil {
+		return fmt.Errorf("no ack from fluent server: %w", err)
+	}
+	if m, ok := response.(map[string]interface{}); !ok || m["ack"] != chunk {
+		return fmt.Errorf("fluent server acknowledged a different chunk: %v", response)
+	}
+	return nil
+}
+
+// fluentRecord converts the event to a record with the JSON field names
+func fluentRecord(data *types.NotificationData) (map[string]interface{}, error) {
+	raw, err := data.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to convert event: %w", err)
+	}
+	return record, nil
+}
+
+// fluentAddress returns the server address with the default port added
+func fluentAddress(connector *config.ConnectorConfig) string {
+	address := connector.Settings["address"]
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, defaultFluentPort)
+}
+
+// dialFluent connects to the server, with TLS when tls is true. The
+// context's deadline bounds the whole exchange.
+func dialFluent(ctx context.Context, connector *config.ConnectorConfig) (net.Conn, error) {
+	address := fluentAddress(connector)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if connector.Settings["tls"] != "true" {
+		return conn, nil
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	tlsConfig, err := connectorTLSConfig(connector, host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+	}
+	return tlsConn, nil
+}
+
+// fluentHandshake answers the server's HELO with a PING proving knowledge
+// of the shared key, and the user's password when the server asks for one,
+// then checks the PONG, which proves the server knows the key too
+func fluentHandshake(conn net.Conn, reader *bufio.Reader, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	helo, err := fluentRead(reader)
+	if err != nil {
+		return fmt.Errorf("no HELO from fluent server: %w", err)
+	}
+	heloArgs, ok := helo.([]interface{})
+	if !ok || len(heloArgs) < 2 || heloArgs[0] != "HELO" {
+		return fmt.Errorf("unexpected HELO from fluent server: %v", helo)
+	}
+	heloOptions, _ := heloArgs[1].(map[string]interface{})
+	nonce := fluentBytes(heloOptions["nonce"])
+	authSalt := fluentBytes(heloOptions["auth"])
+
+	hostname := connector.Settings["self_hostname"]
+	if hostname == "" {
+		hostname = data.Hostname
+	}
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	salt, err := fluentChunkID()
+	if err != nil {
+		return err
+	}
+	sharedKey := connector.Settings["shared_key"]
+
+	username, password := connector.Settings["username"], ""
+	if len(authSalt) > 0 {
+		password = fluentDigest(string(authSalt), username, connector.Settings["password"])
+	} else {
+		username = ""
+	}
+	ping := []interface{}{"PING", hostname, salt, fluentDigest(salt, hostname, string(nonce), sharedKey), username, password}
+	if err := fluentWrite(conn, ping); err != nil {
+		return err
+	}
+
+	// PONG: ["PONG", auth_result, reason, server_hostname, shared_key_hexdigest]
+	pong, err := fluentRead(reader)
+	if err != nil {
+		return fmt.Errorf("no PONG from fluent server: %w", err)
+	}
+	pongArgs, ok := pong.([]interface{})
+	if !ok || len(pongArgs) < 5 || pongArgs[0] != "PONG" {
+		return fmt.Errorf("unexpected PONG from fluent server: %v", pong)
+	}
+	if pongArgs[1] != true {
+		return fmt.Errorf("fluent server rejected authentication: %v", pongArgs[2])
+	}
+	serverHostname, _ := pongArgs[3].(string)
+	if pongArgs[4] != fluentDigest(salt, serverHostname, string(nonce), sharedKey) {
+		return fmt.Errorf("fluent server failed to prove the shared key")
+	}
+	return nil
+}
+
+// fluentDigest is the hex SHA-512 of the concatenated parts
+func fluentDigest(parts ...string) string {
+	h := sha512.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fluentBytes returns a binary or string handshake value as bytes
+func fluentBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+// fluentChunkID returns a random ID for a chunk or shared key salt
+func fluentChunkID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate chunk ID: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// fluentWrite sends one MessagePack value
+func fluentWrite(conn net.Conn, v interface{}) error {
+	var encoder msgpack.Encoder
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	if _, err := conn.Write(encoder.Bytes()); err != nil {
+		return fmt.Errorf("failed to send to fluent server: %w", err)
+	}
+	return nil
+}
+
+// fluentRead reads one MessagePack value
+func fluentRead(reader *bufio.Reader) (interface{}, error) {
+	v, err := msgpack.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from fluent server: %w", err)
+	}
+	return v, nil
+}
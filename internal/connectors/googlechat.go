@@ -0,0 +1,339 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// ConnectorTypeGoogleChat posts events to a Google Chat space webhook
+const ConnectorTypeGoogleChat = "googlechat"
+
+// Google Chat defaults
+const (
+	defaultGoogleChatMapURL = "https://www.openstreetmap.org/?mlat={lat}&mlon={lon}&zoom=8"
+	googleChatDefaultIcon   = "info"
+)
+
+// googleChatIcons are the Material icons shown next to the message fields
+var googleChatIcons = map[string]string{
+	"ip":             "lan",
+	"ip_hash":        "fingerprint",
+	"client":         "person",
+	"jail":           "gavel",
+	"time":           "schedule",
+	"site":           "language",
+	"failures":       "error",
+	"history":        "history",
+	"risk":           "warning",
+	"logins":         "login",
+	"trusted_region": "shield",
+	"location":       "location_on",
+	"country":        "flag",
+	"timezone":       "public",
+	"coordinates":    "my_location",
+	"campaign":       "hub",
+	"domains":        "dns",
+	"anonymity":      "vpn_lock",
+	"honeypot":       "bug_report",
+	"isp":            "router",
+	"server":         "computer",
+	"labels":         "label",
+	"throttled":      "speed",
+	"escalation":     "priority_high",
+	"unacknowledged": "notifications_active",
+	"ack":            "task_alt",
+	"event_id":       "tag",
+	"matches":        "description",
+	"bans":           "block",
+	"unbans":         "check_circle",
+	"baseline":       "trending_up",
+	"jails":          "gavel",
+}
+
+// googleChatMessage is a Chat message with one card
+type googleChatMessage struct {
+	CardsV2 []googleChatCardV2 `json:"cardsV2"`
+}
+
+type googleChatCardV2 struct {
+	CardID string         `json:"cardId"`
+	Card   googleChatCard `json:"card"`
+}
+
+type googleChatCard struct {
+	Header   googleChatHeader    `json:"header"`
+	Sections []googleChatSection `json:"sections"`
+}
+
+type googleChatHeader struct {
+	Title     string `json:"title"`
+	Subtitle  string `json:"subtitle,omitempty"`
+	ImageURL  string `json:"imageUrl,omitempty"`
+	ImageType string `json:"imageType,omitempty"`
+}
+
+type googleChatSection struct {
+	Header  string             `json:"header,omitempty"`
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *googleChatText          `json:"textParagraph,omitempty"`
+	ButtonList    *googleChatButtonList    `json:"buttonList,omitempty"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel  string          `json:"topLabel"`
+	Text      string          `json:"text"`
+	WrapText  bool            `json:"wrapText"`
+	StartIcon *googleChatIcon `json:"startIcon,omitempty"`
+}
+
+type googleChatIcon struct {
+	MaterialIcon struct {
+		Name string `json:"name"`
+	} `json:"materialIcon"`
+}
+
+type googleChatText struct {
+	Text string `json:"text"`
+}
+
+type googleChatButtonList struct {
+	Buttons []googleChatButton `json:"buttons"`
+}
+
+type googleChatButton struct {
+	Text    string `json:"text"`
+	OnClick struct {
+		OpenLink struct {
+			URL string `json:"url"`
+		} `json:"openLink"`
+	} `json:"onClick"`
+}
+
+func init() {
+	registerNative(ConnectorTypeGoogleChat, nativeConnector{validate: validateGoogleChat, execute: executeGoogleChat})
+}
+
+// validateGoogleChat checks the Google Chat connector settings
+func validateGoogleChat(connector *config.ConnectorConfig) error {
+	if err := validateWebhookURL(connector); err != nil {
+		return err
+	}
+
+	switch connector.Settings["thread"] {
+	case "", "none", "ip", "jail":
+	default:
+		return fmt.Errorf("thread must be none, ip or jail: %s", connector.Settings["thread"])
+	}
+
+	for _, key := range []string{"ban_image_url", "unban_image_url"} {
+		if value := connector.Settings[key]; value != "" {
+			if u, err := url.ParseRequestURI(value); err != nil || u.Scheme != "https" {
+				return fmt.Errorf("%s must be an https URL: %s", key, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// executeGoogleChat posts the event as a card: a header with the title and
+// the ban or unban image, the fields with icons and the location of the IP
+// with a map link
+func executeGoogleChat(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	header := googleChatHeader{Title: m.messageTitle(connector, data), Subtitle: data.Hostname}
+	imageKey := "ban_image_url"
+	if data.IsUnban() || data.IsBulkUnban() {
+		imageKey = "unban_image_url"
+	}
+	if image := connector.Settings[imageKey]; image != "" {
+		header.ImageURL = image
+		header.ImageType = "CIRCLE"
+	}
+
+	cardID := data.EventID
+	if cardID == "" {
+		cardID = "fail2ban"
+	}
+	message := googleChatMessage{CardsV2: []googleChatCardV2{{
+		CardID: cardID,
+		Card:   googleChatCard{Header: header, Sections: m.googleChatSections(connector, data)},
+	}}}
+
+	webhookURL := connector.Settings["webhook_url"]
+	if key := googleChatThreadKey(connector, data); key != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil {
+			return fmt.Errorf("invalid webhook_url: %w", err)
+		}
+		query := u.Query()
+		query.Set("threadKey", key)
+		query.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+		u.RawQuery = query.Encode()
+		webhookURL = u.String()
+	}
+
+	return m.doJSON(ctx, http.MethodPost, webhookURL, nil, message, nil)
+}
+
+// googleChatSections returns the sections of the card: the body template or
+// the fields, the location and the links. Values are HTML-escaped, since
+// Chat formats card text as HTML.
+func (m *Manager) googleChatSections(connector *config.ConnectorConfig, data *types.NotificationData) []googleChatSection {
+	var sections []googleChatSection
+
+	fields := m.messageFields(connector, data)
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if body, ok := tmpl.RenderBody(sanitize.Escaped(data, sanitize.EscapeHTML)); ok {
+			text := strings.ReplaceAll(strings.TrimSpace(body), "\n", "<br>")
+			sections = append(sections, googleChatSection{Widgets: []googleChatWidget{{TextParagraph: &googleChatText{Text: text}}}})
+			fields = nil
+		}
+	}
+
+	// The location and network get a section of their own, the location
+	// split into its parts
+	details := googleChatSection{Header: "Details"}
+	location := googleChatSection{Header: "Location"}
+	for _, field := range fields {
+		switch field.Key {
+		case "location":
+			location.Widgets = append(location.Widgets, googleChatGeoWidgets(connector, data)...)
+		case "isp":
+			location.Widgets = append(location.Widgets, googleChatField(field, data))
+		case "ack":
+			// Linked by a button
+		default:
+			details.Widgets = append(details.Widgets, googleChatField(field, data))
+		}
+	}
+	if len(details.Widgets) > 0 {
+		sections = append(sections, details)
+	}
+	if len(location.Widgets) > 0 {
+		sections = append(sections, location)
+	}
+
+	var links []googleChatButton
+	if data.ArtifactURL != "" {
+		links = append(links, googleChatLink("Log context", data.ArtifactURL))
+	}
+	if data.AckURL != "" {
+		links = append(links, googleChatLink("Acknowledge", data.AckURL))
+	}
+	if len(links) > 0 {
+		sections = append(sections, googleChatSection{Widgets: []googleChatWidget{googleChatButtons(links...)}})
+	}
+
+	return sections
+}
+
+// googleChatField returns a message field as a labeled text with its icon
+func googleChatField(field messageField, data *types.NotificationData) googleChatWidget {
+	icon := googleChatIcons[field.Key]
+	switch {
+	case field.Key == "action" && (data.IsUnban() || data.IsBulkUnban()):
+		icon = "check_circle"
+	case field.Key == "action" && data.IsSurge():
+		icon = "trending_up"
+	case field.Key == "action":
+		icon = "block"
+	case icon == "":
+		icon = googleChatDefaultIcon
+	}
+
+	text := &googleChatDecoratedText{
+		TopLabel:  field.Label,
+		Text:      strings.ReplaceAll(sanitize.Escape(field.Value, sanitize.EscapeHTML), "\n", "<br>"),
+		WrapText:  true,
+		StartIcon: &googleChatIcon{},
+	}
+	text.StartIcon.MaterialIcon.Name = icon
+	return googleChatWidget{DecoratedText: text}
+}
+
+// googleChatGeoWidgets returns the country, the region and city, and the
+// coordinates of the IP with a map link
+func googleChatGeoWidgets(connector *config.ConnectorConfig, data *types.NotificationData) []googleChatWidget {
+	var widgets []googleChatWidget
+	if data.Country != "" {
+		country := data.Country
+		if data.CountryCode != "" {
+			country += " (" + data.CountryCode + ")"
+		}
+		if flag := data.Flag(); flag != "" {
+			country = flag + " " + country
+		}
+		widgets = append(widgets, googleChatField(messageField{"country", "Country", country}, data))
+	}
+
+	var place []string
+	for _, part := range []string{data.City, data.Region} {
+		if part != "" && (len(place) == 0 || place[0] != part) {
+			place = append(place, part)
+		}
+	}
+	if len(place) > 0 {
+		widgets = append(widgets, googleChatField(messageField{"location", "City", strings.Join(place, ", ")}, data))
+	}
+	if data.Timezone != "" {
+		widgets = append(widgets, googleChatField(messageField{"timezone", "Time Zone", data.Timezone}, data))
+	}
+
+	if data.Latitude != 0 || data.Longitude != 0 {
+		coordinates := fmt.Sprintf("%.4f, %.4f", data.Latitude, data.Longitude)
+		widgets = append(widgets, googleChatField(messageField{"coordinates", "Coordinates", coordinates}, data))
+		if link := googleChatMapURL(connector, data); link != "" {
+			widgets = append(widgets, googleChatButtons(googleChatLink("Show on map", link)))
+		}
+	}
+	return widgets
+}
+
+// googleChatLink returns a button opening link
+func googleChatLink(text, link string) googleChatButton {
+	button := googleChatButton{Text: text}
+	button.OnClick.OpenLink.URL = link
+	return button
+}
+
+func googleChatButtons(buttons ...googleChatButton) googleChatWidget {
+	return googleChatWidget{ButtonList: &googleChatButtonList{Buttons: buttons}}
+}
+
+// googleChatMapURL returns the map link of the event's coordinates, or ""
+// with map_url set to none
+func googleChatMapURL(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	template := settingOr(connector, "map_url", defaultGoogleChatMapURL)
+	if template == "none" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{lat}", fmt.Sprintf("%.4f", data.Latitude),
+		"{lon}", fmt.Sprintf("%.4f", data.Longitude),
+	).Replace(template)
+}
+
+// googleChatThreadKey returns the thread the event is posted in, so that an
+// unban replies to its ban, or "" to start a new thread per event
+func googleChatThreadKey(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	switch connector.Settings["thread"] {
+	case "ip":
+		if data.IsBan() || data.IsUnban() {
+			return "fail2ban-" + data.Subject()
+		}
+	case "jail":
+		return "fail2ban-" + data.Jail
+	}
+	return ""
+}
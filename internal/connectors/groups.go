@@ -0,0 +1,119 @@
+package connectors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// failoverGroup is a connector group with its members that receive the event
+type failoverGroup struct {
+	name    string
+	members []config.ConnectorConfig
+}
+
+// splitGroups separates the connectors that belong to a failover group from
+// those that are sent every event. Groups keep the order of their members.
+func (m *Manager) splitGroups(connectors []config.ConnectorConfig) ([]config.ConnectorConfig, []failoverGroup) {
+	if len(m.config.Groups) == 0 {
+		return connectors, nil
+	}
+
+	byName := make(map[string]config.ConnectorConfig, len(connectors))
+	for _, connector := range connectors {
+		byName[connector.Name] = connector
+	}
+
+	grouped := make(map[string]bool)
+	var groups []failoverGroup
+	for _, group := range m.config.Groups {
+		fg := failoverGroup{name: group.Name}
+		for _, name := range group.Connectors {
+			grouped[name] = true
+			if connector, ok := byName[name]; ok {
+				fg.members = append(fg.members, connector)
+			}
+		}
+		if len(fg.members) > 0 {
+			groups = append(groups, fg)
+		}
+	}
+
+	var standalone []config.ConnectorConfig
+	for _, connector := range connectors {
+		if !grouped[connector.Name] {
+			standalone = append(standalone, connector)
+		}
+	}
+	return standalone, groups
+}
+
+// runGroup tries the members of a group in order until one delivers the
+// event. Throttled members are skipped. It returns nil if every member was
+// throttled.
+func (m *Manager) runGroup(group failoverGroup, data *types.NotificationData) (*types.ExecutionResult, error) {
+	start := m.clock.Now()
+	result := &types.ExecutionResult{ConnectorName: group.name, Timestamp: start, BestEffort: true}
+	for i := range group.members {
+		// The group is critical if any of its members is
+		result.BestEffort = result.BestEffort && group.members[i].BestEffort
+	}
+
+	var failed, outputs []string
+	for i := range group.members {
+		member := &group.members[i]
+		memberData, allowed := m.applyThrottle(member, data)
+		if !allowed {
+			continue
+		}
+
+		memberResult, err := m.runConnector(member, memberData)
+		result.Attempts += memberResult.Attempts
+		if memberResult.Output != "" {
+			outputs = append(outputs, fmt.Sprintf("[%s]\n%s", member.Name, memberResult.Output))
+			result.Output = strings.Join(outputs, "\n")
+		}
+		if err == nil {
+			result.Success = true
+			result.DeliveredBy = member.Name
+			result.Duration = m.clock.Now().Sub(start)
+			if m.config.Debug && len(failed) > 0 {
+				m.logger.Printf("Group %s delivered by %s after %d failed members", group.name, member.Name, len(failed))
+			}
+			return result, nil
+		}
+		failed = append(failed, err.Error())
+	}
+
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	result.Duration = m.clock.Now().Sub(start)
+	result.Error = fmt.Sprintf("all members failed: %s", strings.Join(failed, "; "))
+	return result, fmt.Errorf("%s", result.Error)
+}
+
+// executeGroup delivers an event through the first enabled member of a group
+// that succeeds, without throttling. It is used to replay spooled events.
+func (m *Manager) executeGroup(group *config.ConnectorGroup, data *types.NotificationData) error {
+	var failed []string
+	for _, name := range group.Connectors {
+		connector, found := m.config.GetConnectorByName(name)
+		if !found || !connector.Enabled {
+			continue
+		}
+		err := m.executeConnector(connector, data)
+		if err == nil {
+			return nil
+		}
+		failed = append(failed, err.Error())
+	}
+
+	if len(failed) == 0 {
+		return fmt.Errorf("group %s has no enabled connectors", group.Name)
+	}
+	return fmt.Errorf("all members failed: %s", strings.Join(failed, "; "))
+}
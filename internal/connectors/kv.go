@@ -0,0 +1,313 @@
+package connectors
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Key-value store connector types
+const (
+	ConnectorTypeConsul = "consul"
+	ConnectorTypeEtcd   = "etcd"
+)
+
+// Consul limits session TTLs to this range, in seconds
+const (
+	consulMinTTL = 10
+	consulMaxTTL = 86400
+)
+
+// DefaultKVPrefix is the key prefix blocklist entries are written under
+const DefaultKVPrefix = "fail2ban/blocklist"
+
+func init() {
+	registerNative(ConnectorTypeConsul, nativeConnector{validate: validateKV, execute: executeConsul})
+	registerNative(ConnectorTypeEtcd, nativeConnector{validate: validateKV, execute: executeEtcd})
+}
+
+// kvEntry is the value stored for a banned IP
+type kvEntry struct {
+	IP       string    `json:"ip"`
+	Jail     string    `json:"jail"`
+	Country  string    `json:"country,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+	BannedAt time.Time `json:"banned_at"`
+	Expires  time.Time `json:"expires_at,omitempty"`
+	EventID  string    `json:"event_id,omitempty"`
+}
+
+// validateKV checks the settings shared by the key-value connectors
+func validateKV(connector *config.ConnectorConfig) error {
+	if ttl := connector.Settings["default_ttl"]; ttl != "" {
+		if _, err := strconv.Atoi(ttl); err != nil {
+			return fmt.Errorf("invalid default_ttl '%s': %w", ttl, err)
+		}
+	}
+	return nil
+}
+
+// kvTTL returns how long the entry should live in seconds, 0 meaning forever.
+// It is the ban time reported by fail2ban, or default_ttl when unknown.
+func kvTTL(connector *config.ConnectorConfig, data *types.NotificationData) int {
+	if data.BanTime < 0 {
+		return 0
+	}
+	if data.BanTime > 0 {
+		return data.BanTime
+	}
+	ttl, _ := strconv.Atoi(connector.Settings["default_ttl"])
+	return ttl
+}
+
+// kvKey returns the key for a banned IP: <prefix>/<jail>/<ip>
+func kvKey(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	prefix := strings.Trim(settingOr(connector, "prefix", DefaultKVPrefix), "/")
+	return prefix + "/" + data.Jail + "/" + data.IP
+}
+
+// newKVEntry builds the stored value for a ban
+func newKVEntry(data *types.NotificationData, ttl int) kvEntry {
+	entry := kvEntry{
+		IP:       data.IP,
+		Jail:     data.Jail,
+		Country:  data.Country,
+		Hostname: data.Hostname,
+		Failures: data.Failures,
+		BannedAt: data.Time.UTC(),
+		EventID:  data.EventID,
+	}
+	if ttl > 0 {
+		entry.Expires = entry.BannedAt.Add(time.Duration(ttl) * time.Second)
+	}
+	return entry
+}
+
+// executeConsul writes bans to Consul KV and removes them on unban. Entries
+// with a TTL are bound to a session that deletes them when it expires.
+// Consul caps sessions at a day, so longer bans are written without one and
+// removed by sweepConsul once their expiry has passed.
+func executeConsul(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if data.IsSurge() || data.IsRestore() || data.IsBulkUnban() {
+		return nil // Meta-events name no IP to store
+	}
+
+	base := strings.TrimSuffix(settingOr(connector, "address", "http://127.0.0.1:8500"), "/")
+	headers := map[string]string{}
+	if token := connector.Settings["token"]; token != "" {
+		headers["X-Consul-Token"] = token
+	}
+
+	query := url.Values{}
+	if dc := connector.Settings["datacenter"]; dc != "" {
+		query.Set("dc", dc)
+	}
+
+	key := kvKey(connector, data)
+	keyURL := func(extra url.Values) string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		for k, v := range extra {
+			q[k] = v
+		}
+		u := base + "/v1/kv/" + key
+		if len(q) > 0 {
+			u += "?" + q.Encode()
+		}
+		return u
+	}
+
+	// Expired bans whose unban was missed are removed with the next event
+	if err := m.sweepConsul(ctx, connector, base, headers, query); err != nil {
+		m.logger.Printf("Consul connector %s: failed to remove expired entries: %v", connector.Name, err)
+	}
+
+	if data.IsUnban() {
+		return m.doJSON(ctx, http.MethodDelete, keyURL(nil), headers, nil, nil)
+	}
+
+	ttl := kvTTL(connector, data)
+	value := newKVEntry(data, ttl)
+
+	if ttl == 0 || ttl > consulMaxTTL {
+		// Release the key from the session of an earlier, shorter ban,
+		// which would otherwise delete it
+		if err := m.doJSON(ctx, http.MethodDelete, keyURL(nil), headers, nil, nil); err != nil {
+			return err
+		}
+		return m.doJSON(ctx, http.MethodPut, keyURL(nil), headers, value, nil)
+	}
+
+	// Consul invalidates a session between TTL and twice the TTL
+	sessionTTL := ttl
+	if sessionTTL < consulMinTTL {
+		sessionTTL = consulMinTTL
+	}
+
+	var session struct {
+		ID string `json:"ID"`
+	}
+	sessionReq := map[string]interface{}{
+		"Name":      "fail2ban-notify " + data.Jail + " " + data.IP,
+		"TTL":       fmt.Sprintf("%ds", sessionTTL),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	}
+	sessionURL := base + "/v1/session/create"
+	if len(query) > 0 {
+		sessionURL += "?" + query.Encode()
+	}
+	if err := m.doJSON(ctx, http.MethodPut, sessionURL, headers, sessionReq, &session); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// A repeated ban finds the key held by the previous session
+	for attempt := 0; attempt < 2; attempt++ {
+		var acquired bool
+		err := m.doJSON(ctx, http.MethodPut, keyURL(url.Values{"acquire": {session.ID}}), headers, value, &acquired)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		if err := m.doJSON(ctx, http.MethodDelete, keyURL(nil), headers, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to acquire key %s", key)
+}
+
+// sweepConsul deletes the entries under the prefix that are not bound to a
+// session and whose expiry has passed
+func (m *Manager) sweepConsul(ctx context.Context, connector *config.ConnectorConfig, base string, headers map[string]string, query url.Values) error {
+	prefix := strings.Trim(settingOr(connector, "prefix", DefaultKVPrefix), "/")
+	q := url.Values{"recurse": {"true"}}
+	for k, v := range query {
+		q[k] = v
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/v1/kv/"+prefix+"/?"+q.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := m.httpDoer(http.DefaultClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// Consul answers 404 when no key has the prefix
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	body, err := readResponse(resp, maxResponseBody)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP request failed with status %s: %s", resp.Status, string(body))
+	}
+
+	var pairs []struct {
+		Key         string `json:"Key"`
+		Value       []byte `json:"Value"`
+		Session     string `json:"Session"`
+		ModifyIndex uint64 `json:"ModifyIndex"`
+	}
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	now := m.clock.Now()
+	for _, pair := range pairs {
+		var entry kvEntry
+		if pair.Session != "" || json.Unmarshal(pair.Value, &entry) != nil {
+			continue
+		}
+		if entry.Expires.IsZero() || now.Before(entry.Expires) {
+			continue
+		}
+
+		// cas leaves the key alone if a new ban rewrote it meanwhile
+		q := url.Values{"cas": {strconv.FormatUint(pair.ModifyIndex, 10)}}
+		for k, v := range query {
+			q[k] = v
+		}
+		if err := m.doJSON(ctx, http.MethodDelete, base+"/v1/kv/"+pair.Key+"?"+q.Encode(), headers, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeEtcd writes bans to etcd through its v3 JSON gateway and removes
+// them on unban. Entries with a TTL are attached to a lease.
+func executeEtcd(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if data.IsSurge() || data.IsRestore() || data.IsBulkUnban() {
+		return nil // Meta-events name no IP to store
+	}
+
+	base := strings.TrimSuffix(settingOr(connector, "endpoint", "http://127.0.0.1:2379"), "/")
+	headers := map[string]string{}
+
+	if user := connector.Settings["username"]; user != "" {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		authReq := map[string]string{"name": user, "password": connector.Settings["password"]}
+		if err := m.doJSON(ctx, http.MethodPost, base+"/v3/auth/authenticate", nil, authReq, &auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+		headers["Authorization"] = auth.Token
+	}
+
+	key := base64.StdEncoding.EncodeToString([]byte(kvKey(connector, data)))
+
+	if data.IsUnban() {
+		return m.doJSON(ctx, http.MethodPost, base+"/v3/kv/deleterange", headers, map[string]string{"key": key}, nil)
+	}
+
+	ttl := kvTTL(connector, data)
+	value, err := jsonBytes(newKVEntry(data, ttl))
+	if err != nil {
+		return err
+	}
+
+	put := map[string]string{
+		"key":   key,
+		"value": base64.StdEncoding.EncodeToString(value),
+	}
+
+	if ttl > 0 {
+		var lease struct {
+			ID string `json:"ID"`
+		}
+		if err := m.doJSON(ctx, http.MethodPost, base+"/v3/lease/grant", headers, map[string]int{"TTL": ttl}, &lease); err != nil {
+			return fmt.Errorf("failed to grant lease: %w", err)
+		}
+		put["lease"] = lease.ID
+	}
+
+	return m.doJSON(ctx, http.MethodPost, base+"/v3/kv/put", headers, put, nil)
+}
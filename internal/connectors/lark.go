@@ -0,0 +1,78 @@
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeLark posts events to a Lark / Feishu custom bot
+const ConnectorTypeLark = "lark"
+
+func init() {
+	registerNative(ConnectorTypeLark, nativeConnector{validate: validateWebhookURL, execute: executeLark})
+}
+
+// larkResponse is the reply of a Lark bot webhook, which reports errors
+// with a non-zero code and HTTP status 200
+type larkResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// executeLark posts the event as an interactive card, signed when the bot
+// has signature verification enabled
+func executeLark(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	template := "red"
+	if data.IsUnban() {
+		template = "green"
+	}
+
+	var content strings.Builder
+	m.markdownFields(&content, connector, data)
+
+	body := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    map[string]string{"tag": "plain_text", "content": m.messageTitle(connector, data)},
+				"template": template,
+			},
+			"elements": []map[string]interface{}{{
+				"tag":  "div",
+				"text": map[string]string{"tag": "lark_md", "content": content.String()},
+			}},
+		},
+	}
+
+	if secret := connector.Settings["secret"]; secret != "" {
+		timestamp := strconv.FormatInt(m.clock.Now().Unix(), 10)
+		body["timestamp"] = timestamp
+		body["sign"] = larkSignature(secret, timestamp)
+	}
+
+	var resp larkResponse
+	if err := m.doJSON(ctx, http.MethodPost, connector.Settings["webhook_url"], nil, body, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("lark bot rejected the message: %d %s", resp.Code, resp.Msg)
+	}
+
+	return nil
+}
+
+// larkSignature signs a bot request: the HMAC-SHA256 of an empty message
+// keyed with "<timestamp>\n<secret>"
+func larkSignature(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
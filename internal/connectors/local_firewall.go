@@ -0,0 +1,180 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Connector types mirroring bans into the local host's firewall, for hosts
+// where fail2ban watches logs shipped from other machines
+const (
+	ConnectorTypeNftables = "nftables"
+	ConnectorTypeUFW      = "ufw"
+)
+
+// Local firewall defaults
+const (
+	defaultNftablesTable = "fail2ban_notify"
+	defaultUFWComment    = "fail2ban-notify"
+)
+
+// nftablesName matches table names the connector may own
+var nftablesName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,31}$`)
+
+// ufwComment matches comments that survive ufw's rule files unquoted
+var ufwComment = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+func init() {
+	registerNative(ConnectorTypeNftables, nativeConnector{validate: validateNftables, execute: executeNftables})
+	registerNative(ConnectorTypeUFW, nativeConnector{validate: validateUFW, execute: executeUFW})
+}
+
+// validateNftables checks the nftables connector settings
+func validateNftables(connector *config.ConnectorConfig) error {
+	if table := settingOr(connector, "table", defaultNftablesTable); !nftablesName.MatchString(table) {
+		return fmt.Errorf("invalid table name '%s', must be a letter followed by up to 31 letters, digits or underscores", table)
+	}
+
+	switch verdict := settingOr(connector, "verdict", "drop"); verdict {
+	case "drop", "reject":
+	default:
+		return fmt.Errorf("invalid verdict '%s', must be 'drop' or 'reject'", verdict)
+	}
+
+	return nil
+}
+
+// executeNftables adds the banned address to a set of the connector's own
+// table, which drops its traffic, and deletes it on unban. Elements carry
+// the ban time as timeout, so the kernel expires them by itself.
+func executeNftables(ctx context.Context, _ *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !data.IsBan() && !data.IsUnban() {
+		return nil
+	}
+	ip := net.ParseIP(data.IP)
+	if ip == nil {
+		return fmt.Errorf("nftables connector needs a real IP address, set raw_ip: %s", data.IP)
+	}
+
+	table := settingOr(connector, "table", defaultNftablesTable)
+	set := "banned4"
+	if ip.To4() == nil {
+		set = "banned6"
+	}
+	element := fmt.Sprintf("inet %s %s { %s }", table, set, ip)
+
+	// Creating the table only when it is missing keeps its chain from
+	// collecting duplicate rules
+	if err := runNft(ctx, connector, "", "list", "table", "inet", table); err != nil {
+		if err := runNft(ctx, connector, nftablesTable(table, settingOr(connector, "verdict", "drop")), "-f", "-"); err != nil {
+			return err
+		}
+	}
+
+	// Adding before deleting makes the transaction succeed whether or not
+	// the element exists, and a repeated ban restarts its timeout
+	script := fmt.Sprintf("add element %s\ndelete element %s\n", element, element)
+	if data.IsBan() {
+		timeout := ""
+		if data.BanTime > 0 {
+			timeout = fmt.Sprintf(" timeout %ds", data.BanTime)
+		}
+		script += fmt.Sprintf("add element inet %s %s { %s%s }\n", table, set, ip, timeout)
+	}
+	return runNft(ctx, connector, script, "-f", "-")
+}
+
+// nftablesTable is the script creating the connector's table, with a set per
+// address family and an input chain ahead of the distribution's filters
+func nftablesTable(table, verdict string) string {
+	return fmt.Sprintf(`table inet %[1]s {
+	set banned4 {
+		type ipv4_addr
+		flags timeout
+	}
+	set banned6 {
+		type ipv6_addr
+		flags timeout
+	}
+	chain input {
+		type filter hook input priority filter - 10; policy accept;
+		ip saddr @banned4 %[2]s
+		ip6 saddr @banned6 %[2]s
+	}
+}
+`, table, verdict)
+}
+
+// runNft runs nft with script on stdin
+func runNft(ctx context.Context, connector *config.ConnectorConfig, script string, args ...string) error {
+	path, err := exec.LookPath(settingOr(connector, "nft_path", "nft"))
+	if err != nil {
+		return fmt.Errorf("nft not found: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("nft %s failed: %w, stderr: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// validateUFW checks the ufw connector settings
+func validateUFW(connector *config.ConnectorConfig) error {
+	switch rule := settingOr(connector, "rule", "deny"); rule {
+	case "deny", "reject":
+	default:
+		return fmt.Errorf("invalid rule '%s', must be 'deny' or 'reject'", rule)
+	}
+
+	if comment := settingOr(connector, "comment", defaultUFWComment); !ufwComment.MatchString(comment) {
+		return fmt.Errorf("invalid comment '%s', must be up to 64 letters, digits or _.:-", comment)
+	}
+
+	return nil
+}
+
+// executeUFW prepends a rule blocking the banned address, tagged with the
+// connector's comment, and deletes it on unban. ufw skips rules that exist
+// and ignores deleting missing ones, so repeated events are harmless.
+func executeUFW(ctx context.Context, _ *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !data.IsBan() && !data.IsUnban() {
+		return nil
+	}
+	ip := net.ParseIP(data.IP)
+	if ip == nil {
+		return fmt.Errorf("ufw connector needs a real IP address, set raw_ip: %s", data.IP)
+	}
+
+	path, err := exec.LookPath(settingOr(connector, "ufw_path", "ufw"))
+	if err != nil {
+		return fmt.Errorf("ufw not found: %w", err)
+	}
+
+	// prepend, unlike insert 1, also works for IPv6 rules behind IPv4 ones
+	rule := settingOr(connector, "rule", "deny")
+	args := []string{"prepend", rule, "from", ip.String(), "comment", settingOr(connector, "comment", defaultUFWComment)}
+	if data.IsUnban() {
+		args = []string{"delete", rule, "from", ip.String()}
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ufw %s failed: %w, output: %s", strings.Join(args, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
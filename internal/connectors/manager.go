@@ -12,12 +12,20 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
-	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/lsm"       //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/secrets"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/spool"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/throttle"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/transform" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"          //nolint:depguard
 )
 
 // Script file extensions
@@ -39,8 +47,16 @@ const (
 
 // Manager manages and executes connectors
 type Manager struct {
-	config *config.Config
-	logger *log.Logger
+	config  *config.Config
+	logger  *log.Logger
+	limiter *throttle.Limiter
+	spool   *spool.Spool
+	clock   types.Clock
+	doer    types.HTTPDoer // Nil to use the client of each connector's target
+	secrets *secrets.Resolver
+
+	metricsMu sync.Mutex
+	metrics   types.Metrics
 }
 
 // NewManager creates a new connector manager
@@ -50,56 +66,278 @@ func NewManager(cfg *config.Config, logger *log.Logger) *Manager {
 	}
 
 	return &Manager{
-		config: cfg,
-		logger: logger,
+		config:  cfg,
+		logger:  logger,
+		limiter: throttle.NewLimiter(cfg.StateDir),
+		spool:   spool.New(cfg.Spool, cfg.Store.Encryption),
+		clock:   types.SystemClock,
+		secrets: secrets.New(&cfg.Secrets),
 	}
 }
 
-// ExecuteAll executes all enabled connectors concurrently
-func (m *Manager) ExecuteAll(data *types.NotificationData) error {
+// SetClock replaces the clock that times connectors and waits between
+// retries
+func (m *Manager) SetClock(clock types.Clock) {
+	m.clock = clock
+	m.secrets.SetClock(clock)
+}
+
+// SetHTTPDoer sends all HTTP requests of connectors and the observer through
+// doer instead of their own clients
+func (m *Manager) SetHTTPDoer(doer types.HTTPDoer) {
+	m.doer = doer
+	m.secrets.SetHTTPDoer(doer)
+}
+
+// httpDoer returns the injected doer, or client without one
+func (m *Manager) httpDoer(client *http.Client) types.HTTPDoer {
+	if m.doer != nil {
+		return m.doer
+	}
+	return client
+}
+
+// ExecuteAll executes all enabled connectors, concurrently unless delivery is
+// sequential, and returns the per-connector results. Connectors held back
+// by throttling are not included.
+func (m *Manager) ExecuteAll(data *types.NotificationData) (*types.BatchResult, error) {
 	enabledConnectors := m.config.GetEnabledConnectors()
 
 	if len(enabledConnectors) == 0 {
-		return fmt.Errorf("no enabled connectors found")
+		return nil, fmt.Errorf("no enabled connectors found")
 	}
 
+	// Leave out escalation connectors and those whose routing rules don't
+	// match the event
+	enabledConnectors = m.withoutEscalation(enabledConnectors)
+	enabledConnectors = m.routeConnectors(enabledConnectors, data)
+	enabledConnectors = m.coalesceUnbans(enabledConnectors, data)
+
+	// Members of failover groups are tried in turn rather than all at once
+	standalone, groups := m.splitGroups(enabledConnectors)
+
 	if m.config.Debug {
-		m.logger.Printf("Executing %d connectors for IP %s", len(enabledConnectors), data.IP)
+		m.logger.Printf("Executing %d connectors for IP %s (event %s)", len(enabledConnectors), data.IP, data.EventID)
+	}
+
+	start := m.clock.Now()
+
+	deliveries := m.orderDeliveries(enabledConnectors, standalone, groups, data)
+	var results []types.ExecutionResult
+	if m.config.Delivery.Sequential {
+		// One at a time in configuration order, so earlier connectors such as
+		// a SIEM have the event before later ones
+		for _, deliver := range deliveries {
+			if result := deliver(); result != nil {
+				results = append(results, *result)
+			}
+		}
+	} else {
+		// Execute connectors concurrently
+		var wg sync.WaitGroup
+		resultChan := make(chan types.ExecutionResult, len(deliveries))
+		for _, deliver := range deliveries {
+			wg.Add(1)
+			go func(deliver delivery) {
+				defer wg.Done()
+				if result := deliver(); result != nil {
+					resultChan <- *result
+				}
+			}(deliver)
+		}
+
+		// Wait for all connectors to complete
+		wg.Wait()
+		close(resultChan)
+		for result := range resultChan {
+			results = append(results, result)
+		}
 	}
 
-	// Execute connectors concurrently
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(enabledConnectors))
+	batch := &types.BatchResult{
+		EventID:          data.EventID,
+		NotificationData: *data,
+		Timestamp:        start,
+	}
 
-	for _, connector := range enabledConnectors {
-		wg.Add(1)
-		go func(conn config.ConnectorConfig) {
-			defer wg.Done()
+	// Collect results and any errors
+	var collectedErrors []string
+	for _, result := range results {
+		batch.Results = append(batch.Results, result)
+		batch.TotalConnectors++
+		if result.Success {
+			batch.SuccessfulCount++
+			continue
+		}
 
-			if err := m.executeConnector(&conn, data); err != nil {
-				errChan <- fmt.Errorf("connector %s failed: %w", conn.Name, err)
+		batch.FailedCount++
+		err := fmt.Errorf("connector %s failed: %s", result.ConnectorName, result.Error)
+		if result.BestEffort {
+			// Optional connectors don't fail the run or fill the logs
+			if m.config.Debug {
+				m.logger.Printf("Best-effort %v", err)
+			}
+			continue
+		}
+		collectedErrors = append(collectedErrors, err.Error())
+		m.logger.Printf("Error: %v (event %s)", err, data.EventID)
+	}
+
+	// With a quorum, enough successes make the run a success despite failures
+	m.applyQuorum(batch)
+	var err error
+	if !batch.IsSuccess() {
+		err = fmt.Errorf("connector failures: %s", strings.Join(collectedErrors, "; "))
+		if batch.Quorum > 0 {
+			succeeded, _ := batch.CriticalCounts()
+			err = fmt.Errorf("quorum not met, %d of %d connectors delivered: %s",
+				succeeded, batch.Quorum, strings.Join(collectedErrors, "; "))
+		}
+		if m.config.Delivery.IsCritical(data.Jail) {
+			m.escalate(batch, data)
+		}
+	}
+	batch.TotalDuration = m.clock.Now().Sub(start)
+
+	// Report the run to the observer endpoint
+	m.notifyObserver(batch)
+
+	return batch, err
+}
+
+// delivery runs a connector or failover group. It returns nil if nothing
+// was delivered because of throttling.
+type delivery func() *types.ExecutionResult
+
+// orderDeliveries returns a delivery for every standalone connector and
+// group, in the order of the connectors in the configuration. A group takes
+// the place of its first member.
+func (m *Manager) orderDeliveries(ordered, standalone []config.ConnectorConfig, groups []failoverGroup, data *types.NotificationData) []delivery {
+	position := make(map[string]int, len(ordered))
+	for i, connector := range ordered {
+		position[connector.Name] = i
+	}
+
+	type placed struct {
+		position int
+		deliver  delivery
+	}
+	var deliveries []placed
+
+	for _, connector := range standalone {
+		conn := connector
+		deliveries = append(deliveries, placed{position[conn.Name], func() *types.ExecutionResult {
+			connData, allowed := m.applyThrottle(&conn, data)
+			if !allowed {
+				return nil
+			}
+
+			result, err := m.runConnector(&conn, connData)
+			if err != nil {
+				m.spoolFailure(conn.Name, connData, err)
 			} else if m.config.Debug {
 				m.logger.Printf("Connector %s executed successfully", conn.Name)
 			}
-		}(connector)
+			return &result
+		}})
 	}
 
-	// Wait for all connectors to complete
-	wg.Wait()
-	close(errChan)
+	for _, group := range groups {
+		group := group
+		deliveries = append(deliveries, placed{position[group.members[0].Name], func() *types.ExecutionResult {
+			result, err := m.runGroup(group, data)
+			if result == nil {
+				return nil
+			}
+			if err != nil {
+				m.spoolFailure(group.name, data, err)
+			} else if m.config.Debug {
+				m.logger.Printf("Group %s delivered by %s", group.name, result.DeliveredBy)
+			}
+			return result
+		}})
+	}
 
-	// Collect any collectedErrors
-	var collectedErrors []string
-	for err := range errChan {
-		collectedErrors = append(collectedErrors, err.Error())
-		m.logger.Printf("Error: %v", err)
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].position < deliveries[j].position
+	})
+	runs := make([]delivery, len(deliveries))
+	for i := range deliveries {
+		runs[i] = deliveries[i].deliver
 	}
+	return runs
+}
 
-	if len(collectedErrors) > 0 {
-		return fmt.Errorf("connector failures: %s", strings.Join(collectedErrors, "; "))
+// routeConnectors returns the connectors the event is routed to
+func (m *Manager) routeConnectors(connectors []config.ConnectorConfig, data *types.NotificationData) []config.ConnectorConfig {
+	if len(m.config.Routing.Rules) == 0 {
+		return connectors
 	}
 
-	return nil
+	var routed []config.ConnectorConfig
+	for _, connector := range connectors {
+		if ruled, matched := m.config.Routing.Routes(connector.Name, data); ruled && !matched {
+			if m.config.Debug {
+				m.logger.Printf("Connector %s skipped: no routing rule matches", connector.Name)
+			}
+			continue
+		}
+		routed = append(routed, connector)
+	}
+	return routed
+}
+
+// runConnector executes a connector and records the outcome
+func (m *Manager) runConnector(connector *config.ConnectorConfig, data *types.NotificationData) (types.ExecutionResult, error) {
+	start := m.clock.Now()
+	attempts, output, err := m.executeRecipients(connector, data)
+
+	result := types.ExecutionResult{
+		ConnectorName: connector.Name,
+		Success:       err == nil,
+		Duration:      m.clock.Now().Sub(start),
+		Timestamp:     start,
+		Attempts:      attempts,
+		Output:        output,
+		BestEffort:    connector.BestEffort,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, err
+}
+
+// applyThrottle checks the connector's message cap. It returns the data to
+// deliver, annotated with the number of suppressed messages, and whether
+// delivery is allowed at all.
+func (m *Manager) applyThrottle(connector *config.ConnectorConfig, data *types.NotificationData) (*types.NotificationData, bool) {
+	if connector.Throttle == nil {
+		return data, true
+	}
+
+	decision, err := m.limiter.Allow(connector.Name, connector.Throttle, data.Time)
+	if err != nil {
+		// Fail open: losing a notification is worse than exceeding the cap
+		m.logger.Printf("Warning: throttle check for connector %s failed: %v", connector.Name, err)
+		return data, true
+	}
+
+	if !decision.Allowed {
+		if m.config.Debug {
+			m.logger.Printf("Connector %s throttled (%d messages per %ds)", connector.Name,
+				connector.Throttle.MaxMessages, connector.Throttle.Window)
+		}
+		return nil, false
+	}
+
+	if decision.Suppressed == 0 {
+		return data, true
+	}
+
+	throttled := *data
+	throttled.Suppressed = decision.Suppressed
+	return &throttled, true
 }
 
 // Execute executes a specific connector by name
@@ -118,29 +356,93 @@ func (m *Manager) Execute(connectorName string, data *types.NotificationData) er
 
 // executeConnector executes a single connector with retry logic
 func (m *Manager) executeConnector(connector *config.ConnectorConfig, data *types.NotificationData) error {
+	_, _, err := m.executeRecipients(connector, data)
+	return err
+}
+
+// executeRecipients delivers the event to each recipient of the connector
+// whose jails match, or to the connector itself when it has no recipients.
+// It returns the total number of attempts made and the captured output.
+func (m *Manager) executeRecipients(connector *config.ConnectorConfig, data *types.NotificationData) (int, string, error) {
+	// Connectors such as firewalls and blocklists need the real IP
+	if !connector.RawIP {
+		data = privacy.Apply(&m.config.Privacy, data)
+	}
+
+	// Pass on only the fields the connector is meant to see
+	if connector.Payload != nil {
+		selected, err := transform.Select(connector.Payload, &m.config.Privacy, data)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to select payload fields: %w", err)
+		}
+		data = selected
+	}
+
+	if len(connector.Recipients) == 0 {
+		return m.executeWithRetry(connector, data)
+	}
+
+	attempts := 0
+	var failed, outputs []string
+	for i := range connector.Recipients {
+		recipient := &connector.Recipients[i]
+		if !recipient.Matches(data.Jail) {
+			continue
+		}
+
+		n, output, err := m.executeWithRetry(connector.ForRecipient(recipient), data)
+		attempts += n
+		if output != "" {
+			outputs = append(outputs, fmt.Sprintf("[%s]\n%s", recipient.Label(i), output))
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", recipient.Label(i), err))
+		}
+	}
+
+	output := strings.Join(outputs, "\n")
+	if len(failed) > 0 {
+		return attempts, output, fmt.Errorf("recipients failed: %s", strings.Join(failed, "; "))
+	}
+	return attempts, output, nil
+}
+
+// executeWithRetry executes a single connector with retry logic and returns
+// the number of attempts made and the output of the last one
+func (m *Manager) executeWithRetry(connector *config.ConnectorConfig, data *types.NotificationData) (int, string, error) {
 	var lastErr error
+	var output string
 
 	for attempt := 0; attempt <= connector.RetryCount; attempt++ {
 		if attempt > 0 {
 			// Wait before retry
-			time.Sleep(time.Duration(connector.RetryDelay) * time.Second)
+			m.clock.Sleep(time.Duration(connector.RetryDelay) * time.Second)
 			if m.config.Debug {
 				m.logger.Printf("Retrying connector %s (attempt %d/%d)", connector.Name, attempt+1, connector.RetryCount+1)
 			}
 		}
 
-		var err error
-		switch connector.Type {
-		case config.ConnectorTypeScript, config.ConnectorTypeExecutable:
-			err = m.executeScript(connector, data)
-		case config.ConnectorTypeHTTP:
-			err = m.executeHTTP(connector, data)
+		// Secrets are resolved per attempt, so a retry can outlast an outage
+		// of the secret store
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(connector.Timeout)*time.Second)
+		resolved, err := m.withSecrets(ctx, connector)
+		cancel()
+		switch {
+		case err != nil:
+		case connector.Type == config.ConnectorTypeScript, connector.Type == config.ConnectorTypeExecutable:
+			output, err = m.executeScript(resolved, data)
+		case connector.Type == config.ConnectorTypeHTTP:
+			output, err = m.executeHTTP(resolved, data)
 		default:
-			return fmt.Errorf("unknown connector type: %s", connector.Type)
+			native, ok := nativeConnectors[connector.Type]
+			if !ok {
+				return attempt + 1, "", fmt.Errorf("unknown connector type: %s", connector.Type)
+			}
+			err = m.executeNative(native, resolved, data)
 		}
 
 		if err == nil {
-			return nil // Success
+			return attempt + 1, output, nil // Success
 		}
 
 		lastErr = err
@@ -149,7 +451,64 @@ func (m *Manager) executeConnector(connector *config.ConnectorConfig, data *type
 		}
 	}
 
-	return fmt.Errorf("connector %s failed after %d attempts: %w", connector.Name, connector.RetryCount+1, lastErr)
+	return connector.RetryCount + 1, output, fmt.Errorf("connector %s failed after %d attempts: %w", connector.Name, connector.RetryCount+1, lastErr)
+}
+
+// withSecrets returns the connector with the secret references in its
+// settings replaced by their values
+func (m *Manager) withSecrets(ctx context.Context, connector *config.ConnectorConfig) (*config.ConnectorConfig, error) {
+	if !config.HasSecretRefs(connector.Settings) {
+		return connector, nil
+	}
+
+	settings, err := m.secrets.Settings(ctx, connector.Settings)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *connector
+	resolved.Settings = settings
+	return &resolved, nil
+}
+
+// scriptOutput returns the captured stdout and stderr of a script, each
+// truncated to the store's output limit
+func (m *Manager) scriptOutput(stdout, stderr []byte) string {
+	if m.config.Store.OutputLimit < 0 {
+		return ""
+	}
+
+	var parts []string
+	if len(stdout) > 0 {
+		parts = append(parts, "stdout:\n"+m.truncateOutput(stdout))
+	}
+	if len(stderr) > 0 {
+		parts = append(parts, "stderr:\n"+m.truncateOutput(stderr))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// httpOutput returns the status and truncated body of an HTTP response
+func (m *Manager) httpOutput(status string, body []byte) string {
+	if m.config.Store.OutputLimit < 0 {
+		return ""
+	}
+	if len(body) == 0 {
+		return status
+	}
+	return status + "\n" + m.truncateOutput(body)
+}
+
+// truncateOutput cuts output to the store's output limit
+func (m *Manager) truncateOutput(output []byte) string {
+	limit := m.config.Store.OutputLimit
+	if limit <= 0 {
+		limit = config.DefaultOutputLimit
+	}
+	text := strings.TrimRight(string(output), "\n")
+	if len(text) <= limit {
+		return text
+	}
+	return strings.ToValidUTF8(text[:limit], "") + fmt.Sprintf("\n... (%d bytes truncated)", len(text)-limit)
 }
 
 // getInterpreter returns the appropriate interpreter for a script based on its extension
@@ -175,30 +534,25 @@ func getInterpreter(scriptPath string) (interpreter string, args []string) {
 // executeScript executes a script or executable connector
 //
 //nolint:funlen
-func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.NotificationData) error {
+func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.NotificationData) (string, error) {
 	// Validate and clean path
 	cleanPath := filepath.Clean(connector.Path)
 	if !filepath.IsAbs(cleanPath) {
-		return fmt.Errorf("connector path must be absolute: %s", connector.Path)
+		return "", fmt.Errorf("connector path must be absolute: %s", connector.Path)
 	}
 
 	// Check if file exists and is executable
 	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
-		return fmt.Errorf("connector script not found: %s", cleanPath)
+		return "", fmt.Errorf("connector script not found: %s", cleanPath)
 	}
 
-	// Prepare the command
-	var cmd *exec.Cmd
-	var interpreter string
-	var args []string
+	// Escape free-text fields for the markup the connector produces
+	escaped := sanitize.Escaped(data, connector.Escape)
 
-	if connector.Type == config.ConnectorTypeScript {
-		// Determine interpreter based on file extension
-		interpreter, args = getInterpreter(cleanPath)
-	} else {
-		// Execute as binary
-		interpreter = cleanPath
-		args = []string{}
+	// Arguments from the connector's templates follow the script
+	extraArgs, err := connector.RenderArgs(escaped, m.config.Humanize())
+	if err != nil {
+		return "", fmt.Errorf("failed to render args: %w", err)
 	}
 
 	// Set up context with timeout
@@ -206,21 +560,9 @@ func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.N
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Create command with context
-	if len(args) > 0 {
-		// Use full path for interpreter to avoid path traversal
-		fullPath, err := exec.LookPath(interpreter)
-		if err != nil {
-			return fmt.Errorf("interpreter not found: %s, error: %w", interpreter, err)
-		}
-		cmd = exec.CommandContext(ctx, fullPath, args...)
-	} else {
-		// Use full path for interpreter to avoid path traversal
-		fullPath, err := exec.LookPath(interpreter)
-		if err != nil {
-			return fmt.Errorf("interpreter not found: %s, error: %w", interpreter, err)
-		}
-		cmd = exec.CommandContext(ctx, fullPath)
+	cmd, sb, err := m.scriptCommand(ctx, connector, cleanPath, extraArgs)
+	if err != nil {
+		return "", err
 	}
 
 	// Prepare environment variables
@@ -228,17 +570,116 @@ func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.N
 
 	// Create a slice for environment variables
 	envVars := []string{
-		fmt.Sprintf("F2B_IP=%s", data.IP),
-		fmt.Sprintf("F2B_JAIL=%s", data.Jail),
-		fmt.Sprintf("F2B_ACTION=%s", data.Action),
+		fmt.Sprintf("F2B_IP=%s", escaped.IP),
+		fmt.Sprintf("F2B_IP_HASH=%s", data.IPHash),
+		fmt.Sprintf("F2B_JAIL=%s", escaped.Jail),
+		fmt.Sprintf("F2B_ACTION=%s", escaped.Action),
 		fmt.Sprintf("F2B_TIME=%s", data.Time.Format(time.RFC3339)),
 		fmt.Sprintf("F2B_TIMESTAMP=%d", data.Time.Unix()),
-		fmt.Sprintf("F2B_COUNTRY=%s", data.Country),
-		fmt.Sprintf("F2B_REGION=%s", data.Region),
-		fmt.Sprintf("F2B_CITY=%s", data.City),
-		fmt.Sprintf("F2B_ISP=%s", data.ISP),
-		fmt.Sprintf("F2B_HOSTNAME=%s", data.Hostname),
+		fmt.Sprintf("F2B_COUNTRY=%s", escaped.Country),
+		fmt.Sprintf("F2B_COUNTRY_CODE=%s", data.CountryCode),
+		fmt.Sprintf("F2B_CONTINENT=%s", data.Continent),
+		fmt.Sprintf("F2B_FLAG=%s", data.Flag()),
+		fmt.Sprintf("F2B_REGION=%s", escaped.Region),
+		fmt.Sprintf("F2B_CITY=%s", escaped.City),
+		fmt.Sprintf("F2B_ISP=%s", escaped.ISP),
+		fmt.Sprintf("F2B_ASN=%s", data.ASN),
+		fmt.Sprintf("F2B_HOSTNAME=%s", escaped.Hostname),
+		fmt.Sprintf("F2B_TARGET_HOST=%s", escaped.TargetHost),
 		fmt.Sprintf("F2B_FAILURES=%d", data.Failures),
+		fmt.Sprintf("F2B_BANTIME=%d", data.BanTime),
+		fmt.Sprintf("F2B_SUPPRESSED=%d", data.Suppressed),
+		fmt.Sprintf("F2B_ARTIFACT=%s", data.Artifact),
+		fmt.Sprintf("F2B_ARTIFACT_URL=%s", data.ArtifactURL),
+		fmt.Sprintf("F2B_HONEYPOT_SESSIONS=%d", data.HoneypotSessions),
+		fmt.Sprintf("F2B_DOMAINS=%s", strings.Join(escaped.Domains, ",")),
+		fmt.Sprintf("F2B_RISK_SCORE=%d", data.RiskScore),
+		fmt.Sprintf("F2B_TRUSTED_REGION=%t", data.TrustedRegion),
+		fmt.Sprintf("F2B_POSSIBLE_BREACH=%t", data.PossibleBreach()),
+		fmt.Sprintf("F2B_ESCALATION=%s", escaped.Escalation),
+		fmt.Sprintf("F2B_EVENT_ID=%s", data.EventID),
+		fmt.Sprintf("F2B_ACK_URL=%s", data.AckURL),
+		fmt.Sprintf("F2B_UNACKED_CRITICAL=%d", data.Unacknowledged),
+		fmt.Sprintf("F2B_MATCHES=%s", strings.Join(escaped.Matches, "\n")),
+	}
+	if c := data.Client; c != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_CLIENT_IP=%s", c.IP),
+			fmt.Sprintf("F2B_CLIENT_HEADER=%s", c.Header),
+			fmt.Sprintf("F2B_CLIENT_COUNTRY=%s", c.Country),
+			fmt.Sprintf("F2B_CLIENT_ASN=%s", c.ASN),
+		)
+	}
+	if a := escaped.Anonymity; a != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_ANONYMITY=%s", strings.Join(a.Labels(), ", ")),
+			fmt.Sprintf("F2B_VPN=%t", a.VPN),
+			fmt.Sprintf("F2B_PROXY=%t", a.Proxy),
+			fmt.Sprintf("F2B_RESIDENTIAL_PROXY=%t", a.ResidentialProxy),
+			fmt.Sprintf("F2B_TOR=%t", a.Tor),
+			fmt.Sprintf("F2B_BOT=%t", a.Bot),
+		)
+	}
+	if h := data.History; h != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_HISTORY=%s", h.Summary()),
+			fmt.Sprintf("F2B_BAN_COUNT=%d", h.BanCount),
+		)
+		if h.BanCount > 0 {
+			envVars = append(envVars,
+				fmt.Sprintf("F2B_FIRST_SEEN=%s", h.FirstSeen.Format(time.RFC3339)),
+				fmt.Sprintf("F2B_LAST_SEEN=%s", h.LastSeen.Format(time.RFC3339)),
+			)
+		}
+	}
+	if len(escaped.Logins) > 0 {
+		logins := make([]string, len(escaped.Logins))
+		for i := range escaped.Logins {
+			logins[i] = escaped.Logins[i].Summary()
+		}
+		envVars = append(envVars, fmt.Sprintf("F2B_LOGINS=%s", strings.Join(logins, "\n")))
+	}
+	if len(data.Labels) > 0 {
+		envVars = append(envVars, fmt.Sprintf("F2B_LABELS=%s", data.LabelString()))
+		for key, value := range data.Labels {
+			envVars = append(envVars, fmt.Sprintf("F2B_LABEL_%s=%s", strings.ToUpper(key), value))
+		}
+	}
+	if c := data.Campaign; c != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_CAMPAIGN_ID=%d", c.ID),
+			fmt.Sprintf("F2B_CAMPAIGN=%s", c.Summary()),
+		)
+	}
+	if s := data.Surge; s != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_SURGE_BANS=%d", s.Bans),
+			fmt.Sprintf("F2B_SURGE_WINDOW=%d", s.Window),
+			fmt.Sprintf("F2B_SURGE_BASELINE=%.1f", s.Baseline),
+		)
+	}
+	if r := data.Restore; r != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_RESTORE_BANS=%d", r.Bans),
+			fmt.Sprintf("F2B_RESTORE_JAILS=%s", r.JailSummary()),
+			fmt.Sprintf("F2B_RESTORE_WINDOW=%d", r.Window),
+		)
+	}
+	if b := data.BulkUnban; b != nil {
+		envVars = append(envVars,
+			fmt.Sprintf("F2B_BULK_UNBANS=%d", b.Unbans),
+			fmt.Sprintf("F2B_BULK_WINDOW=%d", b.Window),
+		)
+	}
+
+	// Rendered templates, so scripts can use the configured wording
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if title, ok := tmpl.RenderTitle(escaped); ok {
+			envVars = append(envVars, fmt.Sprintf("F2B_TITLE=%s", title))
+		}
+		if body, ok := tmpl.RenderBody(escaped); ok {
+			envVars = append(envVars, fmt.Sprintf("F2B_BODY=%s", strings.TrimSpace(body)))
+		}
 	}
 
 	// Add all environment variables at once
@@ -254,7 +695,7 @@ func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.N
 	// Pass JSON data via stdin
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal notification data: %w", err)
+		return "", fmt.Errorf("failed to marshal notification data: %w", err)
 	}
 	cmd.Stdin = bytes.NewReader(jsonData)
 
@@ -264,7 +705,8 @@ func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.N
 	cmd.Stderr = &stderr
 
 	// Execute the command
-	err = cmd.Run()
+	err = sb.run(cmd)
+	output := m.scriptOutput(stdout.Bytes(), stderr.Bytes())
 
 	if m.config.Debug {
 		if stdout.Len() > 0 {
@@ -277,25 +719,78 @@ func (m *Manager) executeScript(connector *config.ConnectorConfig, data *types.N
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			return fmt.Errorf("connector timed out after %v", timeout)
+			return output, fmt.Errorf("connector timed out after %v", timeout)
+		}
+		// SELinux and AppArmor denials are otherwise easy to miss
+		if lsm.PermissionError(err, stderr.String()) {
+			if hint := lsm.Diagnose(cleanPath); hint != "" {
+				return output, fmt.Errorf("execution failed: %w, stderr: %s, %s", err, stderr.String(), hint)
+			}
 		}
-		return fmt.Errorf("execution failed: %w, stderr: %s", err, stderr.String())
+		return output, fmt.Errorf("execution failed: %w, stderr: %s", err, stderr.String())
 	}
 
-	return nil
+	return output, nil
+}
+
+// scriptCommand prepares the command running a script or executable
+// connector with args, in the connector's SELinux domain if one is set. The
+// command is run by the returned sandbox, which is nil without a network
+// policy.
+func (m *Manager) scriptCommand(ctx context.Context, connector *config.ConnectorConfig, cleanPath string, args []string) (*exec.Cmd, *sandbox, error) {
+	var interpreter string
+	var interpreterArgs []string
+
+	if connector.Type == config.ConnectorTypeScript {
+		// Determine interpreter based on file extension
+		interpreter, interpreterArgs = getInterpreter(cleanPath)
+	} else {
+		// Execute as binary
+		interpreter = cleanPath
+	}
+	args = append(interpreterArgs, args...)
+
+	// Use full path for interpreter to avoid path traversal
+	fullPath, err := exec.LookPath(interpreter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("interpreter not found: %s, error: %w", interpreter, err)
+	}
+	cmd := exec.CommandContext(ctx, fullPath, args...)
+
+	// Run the script in a designated SELinux domain
+	if selinuxContext := m.config.ScriptContext(connector); selinuxContext != "" {
+		runcon, err := exec.LookPath("runcon")
+		if err != nil {
+			return nil, nil, fmt.Errorf("runcon not found for selinux_context %s: %w", selinuxContext, err)
+		}
+		cmd = exec.CommandContext(ctx, runcon, append([]string{selinuxContext}, cmd.Args...)...)
+	}
+
+	sb, err := newSandbox(ctx, connector)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cmd, sb, nil
 }
 
 // executeHTTP executes an HTTP connector
-func (m *Manager) executeHTTP(connector *config.ConnectorConfig, data *types.NotificationData) error {
-	url, ok := connector.Settings["url"]
-	if !ok {
-		return fmt.Errorf("HTTP connector missing 'url' setting")
+func (m *Manager) executeHTTP(connector *config.ConnectorConfig, data *types.NotificationData) (string, error) {
+	if _, ok := connector.Settings["url"]; !ok {
+		return "", fmt.Errorf("HTTP connector missing 'url' setting")
+	}
+	url, client, err := httpTarget(connector)
+	if err != nil {
+		return "", err
+	}
+	if client.Transport != nil {
+		// Unix socket and h2c transports are not shared between requests
+		defer client.CloseIdleConnections()
 	}
 
 	// Prepare JSON payload
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return "", fmt.Errorf("failed to marshal data: %w", err)
 	}
 
 	// Set up context with timeout
@@ -303,10 +798,10 @@ func (m *Manager) executeHTTP(connector *config.ConnectorConfig, data *types.Not
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, HTTPMethodPost, url, bytes.NewReader(jsonData))
+	// Create request with context, gzipped if the connector asks for it
+	req, err := newPayloadRequest(ctx, connector, HTTPMethodPost, url, jsonData)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
 
 	// Set default headers
@@ -321,13 +816,10 @@ func (m *Manager) executeHTTP(connector *config.ConnectorConfig, data *types.Not
 		}
 	}
 
-	// Set up HTTP client
-	client := &http.Client{}
-
 	// Execute request
-	resp, err := client.Do(req)
+	resp, err := m.httpDoer(client).Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP request failed: %w", err)
+		return "", fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -337,18 +829,30 @@ func (m *Manager) executeHTTP(connector *config.ConnectorConfig, data *types.Not
 	}(resp.Body)
 
 	// Read response body for debugging
-	body, _ := io.ReadAll(resp.Body)
+	body, err := readResponse(resp, maxResponseBody)
+	if err != nil {
+		return "", err
+	}
+	output := m.httpOutput(resp.Status, body)
 
 	if m.config.Debug {
 		m.logger.Printf("HTTP connector %s response: %s %s", connector.Name, resp.Status, string(body))
 	}
 
+	// Check the response against the connector's expectation
+	if connector.Expect != nil {
+		if err := connector.Expect.Check(resp.StatusCode, body); err != nil {
+			return output, fmt.Errorf("HTTP response rejected: %w: %s", err, string(body))
+		}
+		return output, nil
+	}
+
 	// Check for HTTP errors
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP request failed with status %s: %s", resp.Status, string(body))
+		return output, fmt.Errorf("HTTP request failed with status %s: %s", resp.Status, string(body))
 	}
 
-	return nil
+	return output, nil
 }
 
 // DiscoverConnectors scans the connector directory for available connectors
@@ -433,10 +937,11 @@ func (m *Manager) TestConnector(connectorName string, testData *types.Notificati
 		}
 
 		testData = &types.NotificationData{
+			EventID:  types.NewEventID(),
 			IP:       "192.168.1.100",
 			Jail:     "test",
 			Action:   "ban",
-			Time:     time.Now(),
+			Time:     m.clock.Now(),
 			Country:  "Test Country",
 			Region:   "Test Region",
 			City:     "Test City",
@@ -490,7 +995,11 @@ func (m *Manager) ValidateConnector(connector *config.ConnectorConfig) error {
 		}
 
 	default:
-		return fmt.Errorf("unknown connector type: %s", connector.Type)
+		native, ok := nativeConnectors[connector.Type]
+		if !ok {
+			return fmt.Errorf("unknown connector type: %s", connector.Type)
+		}
+		return native.validate(connector)
 	}
 
 	return nil
@@ -500,6 +1009,12 @@ func (m *Manager) ValidateConnector(connector *config.ConnectorConfig) error {
 func (m *Manager) GetConnectorStatus() map[string]ConnectorStatus {
 	status := make(map[string]ConnectorStatus)
 
+	var queued map[string]int
+	if m.config.Spool.Enabled {
+		queued, _ = m.spool.CountByConnector()
+	}
+	health, _ := m.LoadHealth()
+
 	for i := range m.config.Connectors {
 		// Get a pointer to the connector
 		connector := &m.config.Connectors[i]
@@ -510,6 +1025,7 @@ func (m *Manager) GetConnectorStatus() map[string]ConnectorStatus {
 			Enabled:     connector.Enabled,
 			Path:        connector.Path,
 			Description: connector.Description,
+			BestEffort:  connector.BestEffort,
 		}
 
 		// Validate connector
@@ -522,6 +1038,17 @@ func (m *Manager) GetConnectorStatus() map[string]ConnectorStatus {
 			connStatus.Status = "disabled"
 		}
 
+		connStatus.Queued = queued[connector.Name]
+		if result, ok := health[connector.Name]; ok && connector.ProbeMethod() != config.ProbeNone {
+			connStatus.Health = &result
+		}
+
+		if connector.Throttle != nil {
+			if pending, err := m.limiter.Pending(connector.Name); err == nil {
+				connStatus.Suppressed = pending
+			}
+		}
+
 		status[connector.Name] = connStatus
 	}
 
@@ -530,11 +1057,15 @@ func (m *Manager) GetConnectorStatus() map[string]ConnectorStatus {
 
 // ConnectorStatus represents the status of a connector
 type ConnectorStatus struct {
-	Name        string `json:"name"`
-	Type        string `json:"type"`
-	Enabled     bool   `json:"enabled"`
-	Path        string `json:"path"`
-	Description string `json:"description"`
-	Status      string `json:"status"` // "ready", "disabled", "invalid"
-	Error       string `json:"error,omitempty"`
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Enabled     bool         `json:"enabled"`
+	Path        string       `json:"path"`
+	Description string       `json:"description"`
+	Status      string       `json:"status"` // "ready", "disabled", "invalid"
+	Error       string       `json:"error,omitempty"`
+	Suppressed  int          `json:"suppressed,omitempty"` // Messages currently held back by throttling
+	Queued      int          `json:"queued,omitempty"`     // Notifications waiting in the spool
+	BestEffort  bool         `json:"best_effort,omitempty"`
+	Health      *ProbeResult `json:"health,omitempty"` // Latest health probe, if the daemon probes the connector
 }
@@ -0,0 +1,286 @@
+package connectors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// messageField is a labeled value shown in chat messages. Key names the
+// field in message templates (see config.MessageFields).
+type messageField struct {
+	Key   string
+	Label string
+	Value string
+}
+
+// messageTitle returns a one-line summary of the event for chat connectors,
+// using the title template of the connector or jail if there is one
+func (m *Manager) messageTitle(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if title, ok := tmpl.RenderTitle(data); ok {
+			return title
+		}
+	}
+
+	if data.IsUnban() {
+		return fmt.Sprintf("✅ %s unbanned from %s", data.IP, data.Jail)
+	}
+	if data.IsSurge() {
+		return fmt.Sprintf("📈 Attack surge in %s", data.Jail)
+	}
+	if data.IsRestore() && data.Restore != nil {
+		return fmt.Sprintf("♻️ Restored %d bans after fail2ban restart", data.Restore.Bans)
+	}
+	if data.IsBulkUnban() && data.BulkUnban != nil {
+		return fmt.Sprintf("✅ %d IPs unbanned from %s", data.BulkUnban.Unbans, data.Jail)
+	}
+	title := fmt.Sprintf("🚫 %s banned in %s", data.IP, data.Jail)
+	if data.PossibleBreach() {
+		title = fmt.Sprintf("🚨 Possible breach: %s banned in %s after a successful login", data.IP, data.Jail)
+	} else if data.TrustedRegion {
+		title = fmt.Sprintf("⚠️ Possible credential stuffing from trusted region: %s banned in %s", data.IP, data.Jail)
+	}
+	if data.TargetHost != "" {
+		title += " on " + data.TargetHost
+	}
+	if data.Client != nil {
+		title += fmt.Sprintf(" (proxy for %s)", data.Client.IP)
+	}
+	return title
+}
+
+// messageFields returns the event fields that are set, in display order,
+// limited to and ordered by the fields of the template if it has any
+func (m *Manager) messageFields(connector *config.ConnectorConfig, data *types.NotificationData) []messageField {
+	fields := defaultMessageFields(data)
+
+	tmpl := m.config.TemplateFor(connector, data.Jail)
+	if tmpl == nil || len(tmpl.Fields) == 0 || data.IsSurge() || data.IsRestore() || data.IsBulkUnban() {
+		return fields
+	}
+
+	byKey := make(map[string]messageField, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	selected := make([]messageField, 0, len(tmpl.Fields))
+	for _, key := range tmpl.Fields {
+		if field, ok := byKey[key]; ok {
+			selected = append(selected, field)
+		}
+	}
+	return selected
+}
+
+// defaultMessageFields returns all event fields that are set
+func defaultMessageFields(data *types.NotificationData) []messageField {
+	if data.IsSurge() && data.Surge != nil {
+		return surgeFields(data)
+	}
+	if data.IsRestore() && data.Restore != nil {
+		return restoreFields(data)
+	}
+	if data.IsBulkUnban() && data.BulkUnban != nil {
+		return bulkUnbanFields(data)
+	}
+
+	fields := []messageField{
+		{"ip", "IP Address", data.IP},
+		{"jail", "Jail", data.Jail},
+		{"action", "Action", data.Action},
+		{"time", "Time", data.Time.Format(time.RFC3339)},
+	}
+
+	// A masked IP is shared by its network, the hash tells addresses apart
+	if data.IPHash != "" && data.IPHash != data.IP {
+		fields = append(fields, messageField{"ip_hash", "IP Hash", data.IPHash})
+	}
+	if data.Client != nil {
+		fields = append(fields, messageField{"client", "Client", data.Client.Summary() + " via proxy " + data.IP})
+	}
+
+	if data.TargetHost != "" {
+		fields = append(fields, messageField{"site", "Site", data.TargetHost})
+	}
+	if data.Failures > 0 {
+		fields = append(fields, messageField{"failures", "Failures", strconv.Itoa(data.Failures)})
+	}
+	if data.History != nil {
+		fields = append(fields, messageField{"history", "History", data.History.Summary()})
+	}
+	if data.RiskScore > 0 {
+		fields = append(fields, messageField{"risk", "Risk", fmt.Sprintf("%d/100", data.RiskScore)})
+	}
+	if len(data.Logins) > 0 {
+		logins := make([]string, len(data.Logins))
+		for i := range data.Logins {
+			logins[i] = data.Logins[i].Summary()
+		}
+		fields = append(fields, messageField{"logins", "Successful Logins", strings.Join(logins, "; ")})
+	}
+	if data.TrustedRegion {
+		fields = append(fields, messageField{"trusted_region", "Alert", "Ban from a trusted country, possibly credential stuffing against real accounts"})
+	}
+	if location := data.GetLocationString(); location != "" {
+		if flag := data.Flag(); flag != "" {
+			location = flag + " " + location
+		}
+		fields = append(fields, messageField{"location", "Location", location})
+	}
+	if data.Campaign != nil {
+		fields = append(fields, messageField{"campaign", "Campaign", data.Campaign.Summary()})
+	}
+	if len(data.Domains) > 0 {
+		fields = append(fields, messageField{"domains", "Recent Domains", strings.Join(data.Domains, ", ")})
+	}
+	if data.Anonymity != nil {
+		if labels := data.Anonymity.Labels(); len(labels) > 0 {
+			fields = append(fields, messageField{"anonymity", "Anonymity", strings.Join(labels, ", ")})
+		}
+	}
+	if data.HoneypotSessions > 0 {
+		fields = append(fields, messageField{"honeypot", "Honeypot", fmt.Sprintf("yes, %d sessions", data.HoneypotSessions)})
+	}
+	if data.ISP != "" {
+		isp := data.ISP
+		if data.ASN != "" {
+			isp += " (" + data.ASN + ")"
+		}
+		fields = append(fields, messageField{"isp", "ISP", isp})
+	}
+	if data.Hostname != "" {
+		fields = append(fields, messageField{"server", "Server", data.Hostname})
+	}
+	if len(data.Labels) > 0 {
+		fields = append(fields, messageField{"labels", "Labels", data.LabelString()})
+	}
+	if data.Suppressed > 0 {
+		fields = append(fields, messageField{"throttled", "Throttled", fmt.Sprintf("%d further notifications suppressed", data.Suppressed)})
+	}
+	if data.Escalation != "" {
+		fields = append(fields, messageField{"escalation", "Escalation", data.Escalation})
+	}
+	if data.Unacknowledged > 0 {
+		fields = append(fields, messageField{"unacknowledged", "Unacknowledged", fmt.Sprintf("%d critical bans waiting for a responder", data.Unacknowledged)})
+	}
+	if data.AckURL != "" {
+		fields = append(fields, messageField{"ack", "Acknowledge", data.AckURL})
+	}
+	if data.EventID != "" {
+		fields = append(fields, messageField{"event_id", "Event ID", data.EventID})
+	}
+	if len(data.Matches) > 0 {
+		fields = append(fields, messageField{"matches", "Log Lines", strings.Join(data.Matches, "\n")})
+	}
+
+	return fields
+}
+
+// surgeFields returns the fields of a surge event
+func surgeFields(data *types.NotificationData) []messageField {
+	surge := data.Surge
+	fields := []messageField{
+		{"jail", "Jail", data.Jail},
+		{"action", "Action", data.Action},
+		{"time", "Time", data.Time.Format(time.RFC3339)},
+		{"bans", "Bans", fmt.Sprintf("%d in the last %s", surge.Bans, time.Duration(surge.Window)*time.Second)},
+		{"baseline", "Baseline", fmt.Sprintf("%.1f per window (%.1fx)", surge.Baseline, surge.Factor())},
+	}
+	if data.Hostname != "" {
+		fields = append(fields, messageField{"server", "Server", data.Hostname})
+	}
+	if data.EventID != "" {
+		fields = append(fields, messageField{"event_id", "Event ID", data.EventID})
+	}
+	return fields
+}
+
+// restoreFields returns the fields of a restart summary
+func restoreFields(data *types.NotificationData) []messageField {
+	restore := data.Restore
+	fields := []messageField{
+		{"action", "Action", data.Action},
+		{"time", "Time", data.Time.Format(time.RFC3339)},
+		{"bans", "Bans", fmt.Sprintf("%d restored within %s of the restart", restore.Bans, time.Duration(restore.Window)*time.Second)},
+		{"jails", "Jails", restore.JailSummary()},
+	}
+	if data.Hostname != "" {
+		fields = append(fields, messageField{"server", "Server", data.Hostname})
+	}
+	if data.EventID != "" {
+		fields = append(fields, messageField{"event_id", "Event ID", data.EventID})
+	}
+	return fields
+}
+
+// bulkUnbanFields returns the fields of a bulk unban event
+func bulkUnbanFields(data *types.NotificationData) []messageField {
+	bulk := data.BulkUnban
+	fields := []messageField{
+		{"jail", "Jail", data.Jail},
+		{"action", "Action", data.Action},
+		{"time", "Time", data.Time.Format(time.RFC3339)},
+		{"unbans", "Unbans", fmt.Sprintf("%d IPs within %s", bulk.Unbans, time.Duration(bulk.Window)*time.Second)},
+	}
+	if data.Hostname != "" {
+		fields = append(fields, messageField{"server", "Server", data.Hostname})
+	}
+	if data.EventID != "" {
+		fields = append(fields, messageField{"event_id", "Event ID", data.EventID})
+	}
+	return fields
+}
+
+// messageText returns the body of the event as plain text: the body template
+// if there is one, otherwise one "Label: value" line per field
+func (m *Manager) messageText(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if body, ok := tmpl.RenderBody(data); ok {
+			return strings.TrimSpace(body)
+		}
+	}
+
+	var b strings.Builder
+	for _, field := range m.messageFields(connector, data) {
+		fmt.Fprintf(&b, "%s: %s\n", field.Label, field.Value)
+	}
+	if data.ArtifactURL != "" {
+		fmt.Fprintf(&b, "Log context: %s\n", data.ArtifactURL)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// markdownMessage formats the event as Markdown: the title in bold and one
+// line per field, with values escaped so log-derived text cannot inject markup
+func (m *Manager) markdownMessage(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n", sanitize.Escape(m.messageTitle(connector, data), sanitize.EscapeMarkdown))
+	m.markdownFields(&b, connector, data)
+	return b.String()
+}
+
+// markdownFields writes one "**Label:** value" line per field, followed by
+// a link to the log artifact when there is one. A body template replaces the
+// fields; it is executed with Markdown-escaped event fields.
+func (m *Manager) markdownFields(b *strings.Builder, connector *config.ConnectorConfig, data *types.NotificationData) {
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if body, ok := tmpl.RenderBody(sanitize.Escaped(data, sanitize.EscapeMarkdown)); ok {
+			b.WriteString(strings.TrimSpace(body) + "\n")
+			return
+		}
+	}
+
+	for _, field := range m.messageFields(connector, data) {
+		fmt.Fprintf(b, "**%s:** %s\n", field.Label, sanitize.Escape(field.Value, sanitize.EscapeMarkdown))
+	}
+	if data.ArtifactURL != "" {
+		fmt.Fprintf(b, "[Log context](%s)\n", data.ArtifactURL)
+	}
+}
@@ -0,0 +1,329 @@
+package connectors
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5" //nolint:gosec // RouterOS before 6.43 requires the MD5 challenge login
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/blocklist" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"          //nolint:depguard
+)
+
+// ConnectorTypeMikroTik adds banned IPs to a RouterOS firewall address list
+// through the RouterOS API
+const ConnectorTypeMikroTik = "mikrotik"
+
+// RouterOS API
+const (
+	defaultRouterOSPort    = "8728"
+	defaultRouterOSTLSPort = "8729"
+	routerOSMaxWord        = 64 * 1024
+)
+
+// errRouterOSExists is the trap of adding an address that is already listed
+var errRouterOSExists = errors.New("already have such entry")
+
+func init() {
+	registerNative(ConnectorTypeMikroTik, nativeConnector{validate: validateMikroTik, execute: executeMikroTik})
+}
+
+// validateMikroTik checks the MikroTik connector settings
+func validateMikroTik(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "address", "username"); err != nil {
+		return err
+	}
+
+	if _, _, err := net.SplitHostPort(routerOSAddress(connector)); err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+
+	if value := connector.Settings["tls"]; value != "" && value != "true" && value != "false" {
+		return fmt.Errorf("tls must be 'true' or 'false': %s", value)
+	}
+
+	return validateTLSSettings(connector)
+}
+
+// executeMikroTik adds the banned address to the address list, with the
+// ban time as timeout so RouterOS drops it by itself, and removes it on unban
+func executeMikroTik(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !data.IsBan() && !data.IsUnban() {
+		return nil
+	}
+
+	ip := net.ParseIP(data.IP)
+	if ip == nil {
+		return fmt.Errorf("mikrotik connector needs a real IP address, set raw_ip: %s", data.IP)
+	}
+
+	// IPv6 address lists are a separate menu and store host addresses
+	// with their prefix length
+	menu, address := "/ip/firewall/address-list", ip.String()
+	if ip.To4() == nil {
+		menu, address = "/ipv6/firewall/address-list", ip.String()+"/128"
+	}
+	list := settingOr(connector, "list", blocklist.DefaultListName)
+
+	conn, err := dialRouterOS(ctx, connector)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	entries, _, err := conn.run(menu+"/print", "=.proplist=.id", "?list="+list, "?address="+address)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s in address list %s: %w", data.IP, list, err)
+	}
+
+	if data.IsUnban() {
+		for _, entry := range entries {
+			if _, _, err := conn.run(menu+"/remove", "=.id="+entry[".id"]); err != nil {
+				return fmt.Errorf("failed to remove %s from address list %s: %w", data.IP, list, err)
+			}
+		}
+		return nil
+	}
+
+	timeout := ""
+	if data.BanTime > 0 {
+		timeout = fmt.Sprintf("=timeout=%ds", data.BanTime)
+	}
+
+	// A repeated ban renews the timeout of the listed address
+	if len(entries) > 0 {
+		if timeout == "" {
+			return nil
+		}
+		if _, _, err := conn.run(menu+"/set", "=.id="+entries[0][".id"], timeout); err != nil {
+			return fmt.Errorf("failed to renew %s in address list %s: %w", data.IP, list, err)
+		}
+		return nil
+	}
+
+	words := []string{menu + "/add", "=list=" + list, "=address=" + address,
+		"=comment=" + settingOr(connector, "comment", "fail2ban-notify: "+data.Jail)}
+	if timeout != "" {
+		words = append(words, timeout)
+	}
+	if _, _, err := conn.run(words...); err != nil && !errors.Is(err, errRouterOSExists) {
+		return fmt.Errorf("failed to add %s to address list %s: %w", data.IP, list, err)
+	}
+	return nil
+}
+
+// routerOSAddress returns the router address with the API port added
+func routerOSAddress(connector *config.ConnectorConfig) string {
+	address := connector.Settings["address"]
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	if connector.Settings["tls"] == "true" {
+		return net.JoinHostPort(address, defaultRouterOSTLSPort)
+	}
+	return net.JoinHostPort(address, defaultRouterOSPort)
+}
+
+// routerOSConn is a logged-in RouterOS API session
+type routerOSConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialRouterOS connects to the API, or the API-SSL service with tls, and
+// logs in. The context's deadline bounds the whole session.
+func dialRouterOS(ctx context.Context, connector *config.ConnectorConfig) (*routerOSConn, error) {
+	address := routerOSAddress(connector)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if connector.Settings["tls"] == "true" {
+		host, _, _ := net.SplitHostPort(address)
+		tlsConfig, err := connectorTLSConfig(connector, host)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+		}
+		conn = tlsConn
+	}
+
+	c := &routerOSConn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.login(connector.Settings["username"], connector.Settings["password"]); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// login authenticates with the plain login of RouterOS 6.43 and later,
+// falling back to the MD5 challenge of older versions
+func (c *routerOSConn) login(username, password string) error {
+	_, done, err := c.run("/login", "=name="+username, "=password="+password)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	challenge := done["ret"]
+	if challenge == "" {
+		return nil
+	}
+
+	raw, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("login failed: invalid challenge")
+	}
+	sum := md5.Sum(append(append([]byte{0}, password...), raw...)) //nolint:gosec // Required by the legacy login
+	if _, _, err := c.run("/login", "=name="+username, "=response=00"+hex.EncodeToString(sum[:])); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	return nil
+}
+
+// Close ends the session
+func (c *routerOSConn) Close() error {
+	return c.conn.Close()
+}
+
+// run sends a command and returns the attributes of its !re replies and of
+// the final !done. A !trap is returned as error.
+func (c *routerOSConn) run(words ...string) ([]map[string]string, map[string]string, error) {
+	// The sentence goes out in one write, so it is one TLS record
+	var sentence []byte
+	for _, word := range words {
+		sentence = appendRouterOSWord(sentence, word)
+	}
+	if _, err := c.conn.Write(appendRouterOSWord(sentence, "")); err != nil {
+		return nil, nil, fmt.Errorf("failed to send to router: %w", err)
+	}
+
+	var replies []map[string]string
+	var trap error
+	for {
+		sentence, err := c.readSentence()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(sentence) == 0 {
+			continue
+		}
+
+		attrs := make(map[string]string)
+		for _, word := range sentence[1:] {
+			if key, value, ok := strings.Cut(strings.TrimPrefix(word, "="), "="); ok {
+				attrs[key] = value
+			}
+		}
+
+		switch sentence[0] {
+		case "!re":
+			replies = append(replies, attrs)
+		case "!trap":
+			// The command still ends with !done
+			if strings.Contains(attrs["message"], errRouterOSExists.Error()) {
+				trap = errRouterOSExists
+			} else {
+				trap = fmt.Errorf("%s", attrs["message"])
+			}
+		case "!fatal":
+			return nil, nil, fmt.Errorf("router closed the session: %s", strings.Join(sentence[1:], " "))
+		case "!done":
+			if trap != nil {
+				return nil, nil, trap
+			}
+			return replies, attrs, nil
+		}
+	}
+}
+
+// appendRouterOSWord appends a word with its variable-length size prefix
+func appendRouterOSWord(b []byte, word string) []byte {
+	n := len(word)
+	switch {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x4000:
+		b = append(b, byte(n>>8)|0x80, byte(n))
+	case n < 0x200000:
+		b = append(b, byte(n>>16)|0xC0, byte(n>>8), byte(n))
+	case n < 0x10000000:
+		b = append(b, byte(n>>24)|0xE0, byte(n>>16), byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xF0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, word...)
+}
+
+// readSentence reads words up to the empty word ending a sentence
+func (c *routerOSConn) readSentence() ([]string, error) {
+	var sentence []string
+	for {
+		word, err := c.readWord()
+		if err != nil {
+			return nil, err
+		}
+		if word == "" {
+			return sentence, nil
+		}
+		sentence = append(sentence, word)
+	}
+}
+
+// readWord reads one size-prefixed word
+func (c *routerOSConn) readWord() (string, error) {
+	first, err := c.reader.ReadByte()
+	if err != nil {
+		return "", fmt.Errorf("failed to read from router: %w", err)
+	}
+
+	// The high bits of the first byte tell how many bytes follow
+	var n, extra int
+	switch {
+	case first&0x80 == 0:
+		n = int(first)
+	case first&0xC0 == 0x80:
+		n, extra = int(first&0x3F), 1
+	case first&0xE0 == 0xC0:
+		n, extra = int(first&0x1F), 2
+	case first&0xF0 == 0xE0:
+		n, extra = int(first&0x0F), 3
+	default:
+		n, extra = 0, 4
+	}
+	for i := 0; i < extra; i++ {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("failed to read from router: %w", err)
+		}
+		n = n<<8 | int(b)
+	}
+
+	if n > routerOSMaxWord {
+		return "", fmt.Errorf("router sent a %d byte word", n)
+	}
+	word := make([]byte, n)
+	if _, err := io.ReadFull(c.reader, word); err != nil {
+		return "", fmt.Errorf("failed to read from router: %w", err)
+	}
+	return string(word), nil
+}
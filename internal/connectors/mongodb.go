@@ -0,0 +1,59 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeMongoDB writes events as documents to a MongoDB collection
+const ConnectorTypeMongoDB = "mongodb"
+
+// mongoInsert inserts the document into the collection, creating the TTL
+// index on 'time' first when ttl is positive. It is set by the file linking
+// the MongoDB driver, which is only built with the 'mongodb' build tag.
+var mongoInsert func(ctx context.Context, uri, database, collection string, ttl int, document map[string]interface{}) error
+
+func init() {
+	registerNative(ConnectorTypeMongoDB, nativeConnector{validate: validateMongoDB, execute: executeMongoDB})
+}
+
+// validateMongoDB checks the MongoDB connector settings
+func validateMongoDB(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "uri", "database"); err != nil {
+		return err
+	}
+
+	if uri := connector.Settings["uri"]; !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		return fmt.Errorf("uri must start with mongodb:// or mongodb+srv://")
+	}
+
+	if value := connector.Settings["ttl"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("ttl must be a non-negative number of seconds: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// executeMongoDB inserts the event as a document
+func executeMongoDB(ctx context.Context, _ *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if mongoInsert == nil {
+		return fmt.Errorf("built without MongoDB support, rebuild with '-tags mongodb'")
+	}
+
+	ttl, _ := strconv.Atoi(connector.Settings["ttl"])
+
+	document := make(map[string]interface{}, len(eventFields))
+	for name, value := range eventFields {
+		document[name] = value(data)
+	}
+
+	return mongoInsert(ctx, connector.Settings["uri"], connector.Settings["database"],
+		settingOr(connector, "collection", "events"), ttl, document)
+}
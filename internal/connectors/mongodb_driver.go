@@ -0,0 +1,78 @@
+//go:build mongodb
+
+package connectors
+
+// Link the MongoDB driver for the mongodb connector:
+//
+//	go get go.mongodb.org/mongo-driver && go build -tags mongodb ./cmd/fail2ban-notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoClients shares one client, and with it a connection pool, per URI.
+// indexed records collections whose TTL index has been created.
+var mongoClients = struct {
+	sync.Mutex
+	clients map[string]*mongo.Client
+	indexed map[string]bool
+}{
+	clients: make(map[string]*mongo.Client),
+	indexed: make(map[string]bool),
+}
+
+func init() {
+	mongoInsert = mongoDriverInsert
+}
+
+// mongoDriverInsert implements mongoInsert with the official driver
+func mongoDriverInsert(ctx context.Context, uri, database, collection string, ttl int, document map[string]interface{}) error {
+	coll, err := mongoCollection(ctx, uri, database, collection, ttl)
+	if err != nil {
+		return err
+	}
+
+	if _, err := coll.InsertOne(ctx, bson.M(document)); err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+	return nil
+}
+
+// mongoCollection returns the collection, connecting and creating the TTL
+// index on first use
+func mongoCollection(ctx context.Context, uri, database, collection string, ttl int) (*mongo.Collection, error) {
+	mongoClients.Lock()
+	defer mongoClients.Unlock()
+
+	client, ok := mongoClients.clients[uri]
+	if !ok {
+		var err error
+		client, err = mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+		mongoClients.clients[uri] = client
+	}
+
+	coll := client.Database(database).Collection(collection)
+
+	key := fmt.Sprintf("%s\x00%s\x00%s", uri, database, collection)
+	if ttl > 0 && !mongoClients.indexed[key] {
+		index := mongo.IndexModel{
+			Keys:    bson.D{{Key: "time", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(ttl)),
+		}
+		if _, err := coll.Indexes().CreateOne(ctx, index); err != nil {
+			return nil, fmt.Errorf("failed to create TTL index: %w", err)
+		}
+		mongoClients.indexed[key] = true
+	}
+
+	return coll, nil
+}
@@ -0,0 +1,202 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// maxResponseBody limits how much of a response native connectors read
+const maxResponseBody = 1024 * 1024
+
+// nativeConnector is a connector type implemented inside the binary
+type nativeConnector struct {
+	// validate checks the connector settings when the configuration is loaded
+	validate func(connector *config.ConnectorConfig) error
+	// execute delivers the notification
+	execute func(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error
+}
+
+// nativeConnectors holds the registered built-in connector types
+var nativeConnectors = make(map[string]nativeConnector)
+
+// registerNative registers a built-in connector type. It is called from the
+// init function of the file implementing the connector.
+func registerNative(name string, connector nativeConnector) {
+	nativeConnectors[name] = connector
+	config.RegisterConnectorType(name, connector.validate)
+}
+
+// executeNative runs a built-in connector with the connector timeout
+func (m *Manager) executeNative(native nativeConnector, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	timeout := time.Duration(connector.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return native.execute(ctx, m, connector, data)
+}
+
+// requireSettings returns an error naming the first missing setting
+func requireSettings(connector *config.ConnectorConfig, keys ...string) error {
+	for _, key := range keys {
+		if connector.Settings[key] == "" {
+			return fmt.Errorf("%s connector must have '%s' setting", connector.Type, key)
+		}
+	}
+	return nil
+}
+
+// settingOr returns a connector setting or def when it is unset
+func settingOr(connector *config.ConnectorConfig, key, def string) string {
+	if value := connector.Settings[key]; value != "" {
+		return value
+	}
+	return def
+}
+
+// validateWebhookURL checks that the connector has an HTTP(S) webhook_url
+func validateWebhookURL(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "webhook_url"); err != nil {
+		return err
+	}
+
+	if u, err := url.ParseRequestURI(connector.Settings["webhook_url"]); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("webhook_url must be an http(s) URL: %s", connector.Settings["webhook_url"])
+	}
+
+	return nil
+}
+
+// validateTLSSettings checks the ca_file and tls_skip_verify settings of
+// connectors talking TLS to appliances
+func validateTLSSettings(connector *config.ConnectorConfig) error {
+	if value := connector.Settings["tls_skip_verify"]; value != "" && value != "true" && value != "false" {
+		return fmt.Errorf("tls_skip_verify must be 'true' or 'false': %s", value)
+	}
+	if ca := connector.Settings["ca_file"]; ca != "" && !filepath.IsAbs(ca) {
+		return fmt.Errorf("ca_file must be an absolute path: %s", ca)
+	}
+	return nil
+}
+
+// connectorTLSConfig trusts the system roots, the ca_file, or with
+// tls_skip_verify any certificate, as routers and firewalls often come with
+// self-signed ones
+func connectorTLSConfig(connector *config.ConnectorConfig, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+		//nolint:gosec // Opt-in for appliances with self-signed certificates
+		InsecureSkipVerify: connector.Settings["tls_skip_verify"] == "true",
+	}
+
+	if ca := connector.Settings["ca_file"]; ca != "" {
+		pem, err := os.ReadFile(filepath.Clean(ca))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// connectorHTTPClient returns a client for rawURL trusting the connector's
+// ca_file, or any certificate with tls_skip_verify
+func connectorHTTPClient(connector *config.ConnectorConfig, rawURL string) (*http.Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	tlsConfig, err := connectorTLSConfig(connector, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// doJSON sends body (marshaled to JSON unless it is already []byte) and
+// decodes a JSON response into out when out is not nil
+func (m *Manager) doJSON(ctx context.Context, method, url string, headers map[string]string, body, out interface{}) error {
+	return m.doJSONWith(ctx, http.DefaultClient, method, url, headers, body, out)
+}
+
+// doJSONWith is doJSON sending the request with client
+func (m *Manager) doJSONWith(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, ok := body.([]byte)
+		if !ok {
+			var err error
+			payload, err = json.Marshal(body)
+			if err != nil {
+				return fmt.Errorf("failed to marshal request: %w", err)
+			}
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", ContentTypeJSON)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := m.httpDoer(client).Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := readResponse(resp, maxResponseBody)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP request failed with status %s: %s", resp.Status, string(respBody))
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// jsonBytes marshals v to JSON
+func jsonBytes(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,79 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// notifyObserver posts the result of a run to the configured observer URL.
+// Failures are logged but never affect the run itself.
+func (m *Manager) notifyObserver(batch *types.BatchResult) {
+	observer := m.config.Observer
+	if observer.URL == "" {
+		return
+	}
+
+	if observer.FailuresOnly && batch.IsSuccess() {
+		return
+	}
+
+	// The observer is an external endpoint like the connectors
+	if m.config.Privacy.Enabled() {
+		private := *batch
+		private.NotificationData = *privacy.Apply(&m.config.Privacy, &batch.NotificationData)
+		batch = &private
+	}
+
+	if err := m.postObserver(batch); err != nil {
+		m.logger.Printf("Warning: failed to report results to observer: %v", err)
+	} else if m.config.Debug {
+		m.logger.Printf("Reported results to observer %s", observer.URL)
+	}
+}
+
+// postObserver sends the batch result as JSON to the observer URL
+func (m *Manager) postObserver(batch *types.BatchResult) error {
+	observer := m.config.Observer
+
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch result: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(observer.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, HTTPMethodPost, observer.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	req.Header.Set("User-Agent", UserAgent)
+	for name, value := range observer.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := m.httpDoer(http.DefaultClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP request failed with status %s: %s", resp.Status, string(body))
+	}
+
+	return nil
+}
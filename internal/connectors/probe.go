@@ -0,0 +1,227 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+)
+
+// HealthcheckFlag is passed to script connectors probed with "healthcheck".
+// The script checks its settings and credentials without notifying anyone and
+// exits 0 when it could deliver.
+const HealthcheckFlag = "--healthcheck"
+
+// ProbeResult is the outcome of the latest health probe of a connector
+type ProbeResult struct {
+	Time     time.Time     `json:"time"`
+	Healthy  bool          `json:"healthy"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// healthPath is the state file holding the latest probe result of each
+// connector
+func (m *Manager) healthPath() string {
+	return filepath.Join(m.config.StateDir, "health.json")
+}
+
+// ProbeAll probes every enabled connector that has a health probe,
+// concurrently, and records the results for -status
+func (m *Manager) ProbeAll(ctx context.Context) (map[string]ProbeResult, error) {
+	results := make(map[string]ProbeResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, connector := range m.config.GetEnabledConnectors() {
+		if connector.ProbeMethod() == config.ProbeNone {
+			continue
+		}
+		wg.Add(1)
+		go func(connector config.ConnectorConfig) {
+			defer wg.Done()
+			result := m.Probe(ctx, &connector)
+			mu.Lock()
+			results[connector.Name] = result
+			mu.Unlock()
+		}(connector)
+	}
+	wg.Wait()
+
+	// Probes cut short by shutdown say nothing about the connectors
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	var health map[string]ProbeResult
+	err := state.Update(m.healthPath(), &health, func() error {
+		if health == nil {
+			health = make(map[string]ProbeResult)
+		}
+		for name, result := range results {
+			health[name] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return results, fmt.Errorf("failed to save probe results: %w", err)
+	}
+	return results, nil
+}
+
+// Probe checks whether a connector could deliver, without sending an event.
+// Connectors with recipients are healthy only if every recipient is.
+func (m *Manager) Probe(ctx context.Context, connector *config.ConnectorConfig) ProbeResult {
+	start := m.clock.Now()
+	result := ProbeResult{Time: start}
+
+	targets := []*config.ConnectorConfig{connector}
+	labels := []string{connector.Name}
+	if len(connector.Recipients) > 0 {
+		targets, labels = nil, nil
+		for i := range connector.Recipients {
+			targets = append(targets, connector.ForRecipient(&connector.Recipients[i]))
+			labels = append(labels, connector.Recipients[i].Label(i))
+		}
+	}
+
+	var failed []string
+	for i, target := range targets {
+		if err := m.probe(ctx, target); err != nil {
+			if len(targets) == 1 {
+				failed = append(failed, err.Error())
+			} else {
+				failed = append(failed, fmt.Sprintf("%s: %v", labels[i], err))
+			}
+		}
+	}
+
+	result.Duration = m.clock.Now().Sub(start)
+	result.Healthy = len(failed) == 0
+	result.Error = strings.Join(failed, "; ")
+	return result
+}
+
+// probe runs the connector's health probe once
+func (m *Manager) probe(ctx context.Context, connector *config.ConnectorConfig) error {
+	timeout := time.Duration(connector.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	connector, err := m.withSecrets(ctx, connector)
+	if err != nil {
+		return err
+	}
+
+	switch method := connector.ProbeMethod(); method {
+	case config.ProbeHead:
+		return m.probeHTTP(ctx, connector, http.MethodHead)
+	case config.ProbeOptions:
+		return m.probeHTTP(ctx, connector, http.MethodOptions)
+	case config.ProbeHealthcheck:
+		return m.probeScript(ctx, connector)
+	default:
+		return fmt.Errorf("unsupported probe '%s'", method)
+	}
+}
+
+// probeHTTP sends a request without body to the connector's URL. Endpoints
+// that reject the method still prove they are reachable, so only server
+// errors and responses hinting at bad credentials or a removed endpoint count
+// as failures.
+func (m *Manager) probeHTTP(ctx context.Context, connector *config.ConnectorConfig, method string) error {
+	if _, ok := connector.Settings["url"]; !ok {
+		return fmt.Errorf("HTTP connector missing 'url' setting")
+	}
+	url, client, err := httpTarget(connector)
+	if err != nil {
+		return err
+	}
+	if client.Transport != nil {
+		defer client.CloseIdleConnections()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for key, value := range connector.Settings {
+		if strings.HasPrefix(key, "header_") {
+			req.Header.Set(strings.TrimPrefix(key, "header_"), value)
+		}
+	}
+
+	resp, err := m.httpDoer(client).Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError,
+		resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusNotFound,
+		resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("HTTP probe failed with status %s", resp.Status)
+	}
+	return nil
+}
+
+// probeScript runs the script with HealthcheckFlag and its settings in the
+// environment. No event is passed.
+func (m *Manager) probeScript(ctx context.Context, connector *config.ConnectorConfig) error {
+	cleanPath := filepath.Clean(connector.Path)
+	if !filepath.IsAbs(cleanPath) {
+		return fmt.Errorf("connector path must be absolute: %s", connector.Path)
+	}
+	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
+		return fmt.Errorf("connector script not found: %s", cleanPath)
+	}
+
+	cmd, sb, err := m.scriptCommand(ctx, connector, cleanPath, []string{HealthcheckFlag})
+	if err != nil {
+		return err
+	}
+	env := os.Environ()
+	for key, value := range connector.Settings {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	cmd.Env = env
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := sb.run(cmd); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("healthcheck timed out")
+		}
+		if out := strings.TrimSpace(m.truncateOutput(output.Bytes())); out != "" {
+			return fmt.Errorf("healthcheck failed: %w: %s", err, out)
+		}
+		return fmt.Errorf("healthcheck failed: %w", err)
+	}
+	return nil
+}
+
+// LoadHealth returns the latest probe result of each connector
+func (m *Manager) LoadHealth() (map[string]ProbeResult, error) {
+	var health map[string]ProbeResult
+	if err := state.Load(m.healthPath(), &health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
@@ -0,0 +1,317 @@
+package connectors
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypePubSub publishes events to a Google Cloud Pub/Sub topic
+const ConnectorTypePubSub = "pubsub"
+
+// Google Cloud endpoints
+const (
+	defaultPubSubEndpoint = "https://pubsub.googleapis.com"
+	pubSubScope           = "https://www.googleapis.com/auth/pubsub"
+	gceMetadataTokenURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	googleJWTGrantType    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	googleTokenLifetime   = time.Hour
+	googleTokenMargin     = time.Minute // Refresh tokens this long before they expire
+)
+
+// googleServiceAccount holds the fields of a service account key file
+type googleServiceAccount struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleToken is an OAuth2 access token response
+type googleToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	expires     time.Time
+}
+
+// googleTokens caches access tokens by credentials file ("" for the
+// metadata server) so they are reused until shortly before they expire
+var googleTokens = struct {
+	sync.Mutex
+	tokens map[string]*googleToken
+}{
+	tokens: make(map[string]*googleToken),
+}
+
+func init() {
+	registerNative(ConnectorTypePubSub, nativeConnector{validate: validatePubSub, execute: executePubSub})
+}
+
+// validatePubSub checks the Pub/Sub connector settings
+func validatePubSub(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "topic"); err != nil {
+		return err
+	}
+
+	if file := connector.Settings["credentials_file"]; file != "" {
+		if _, err := loadServiceAccount(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// executePubSub publishes the event as a JSON message with the jail,
+// action and hostname as attributes for subscription filters
+func executePubSub(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	payload, err := data.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	credentials := connector.Settings["credentials_file"]
+	if credentials == "" {
+		credentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	topic, err := pubSubTopic(connector, credentials)
+	if err != nil {
+		return err
+	}
+
+	endpoint := defaultPubSubEndpoint
+	headers := map[string]string{}
+	if emulator := os.Getenv("PUBSUB_EMULATOR_HOST"); emulator != "" {
+		endpoint = "http://" + emulator // The emulator takes no credentials
+	} else {
+		token, err := m.googleAccessToken(ctx, credentials)
+		if err != nil {
+			return err
+		}
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	attributes := map[string]string{"jail": data.Jail, "action": data.Action}
+	if data.Hostname != "" {
+		attributes["hostname"] = data.Hostname
+	}
+
+	body := map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"data":       base64.StdEncoding.EncodeToString(payload),
+			"attributes": attributes,
+		}},
+	}
+
+	return m.doJSON(ctx, http.MethodPost, endpoint+"/v1/"+topic+":publish", headers, body, nil)
+}
+
+// pubSubTopic returns the full topic name. The project defaults to the one
+// of the service account.
+func pubSubTopic(connector *config.ConnectorConfig, credentials string) (string, error) {
+	topic := connector.Settings["topic"]
+	if strings.HasPrefix(topic, "projects/") {
+		return topic, nil
+	}
+
+	project := connector.Settings["project"]
+	if project == "" && credentials != "" {
+		account, err := loadServiceAccount(credentials)
+		if err != nil {
+			return "", err
+		}
+		project = account.ProjectID
+	}
+	if project == "" {
+		return "", fmt.Errorf("pubsub connector needs 'project' or a full topic name such as projects/<project>/topics/<topic>")
+	}
+
+	return "projects/" + project + "/topics/" + topic, nil
+}
+
+// googleAccessToken returns an access token for the service account in the
+// credentials file, or from the metadata server (workload identity) when
+// credentials is empty
+func (m *Manager) googleAccessToken(ctx context.Context, credentials string) (string, error) {
+	googleTokens.Lock()
+	defer googleTokens.Unlock()
+
+	if token, ok := googleTokens.tokens[credentials]; ok && m.clock.Now().Before(token.expires) {
+		return token.AccessToken, nil
+	}
+
+	var token *googleToken
+	var err error
+	if credentials != "" {
+		token, err = m.serviceAccountToken(ctx, credentials)
+	} else {
+		token, err = m.metadataToken(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token.expires = m.clock.Now().Add(time.Duration(token.ExpiresIn)*time.Second - googleTokenMargin)
+	googleTokens.tokens[credentials] = token
+	return token.AccessToken, nil
+}
+
+// serviceAccountToken exchanges a signed JWT for an access token
+func (m *Manager) serviceAccountToken(ctx context.Context, credentials string) (*googleToken, error) {
+	account, err := loadServiceAccount(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseRSAPrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private_key in %s: %w", credentials, err)
+	}
+
+	now := m.clock.Now()
+	claims := map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": pubSubScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(googleTokenLifetime).Unix(),
+	}
+	assertion, err := signJWT(key, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{"grant_type": {googleJWTGrantType}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return m.fetchGoogleToken(req)
+}
+
+// metadataToken gets an access token for the attached service account from
+// the metadata server
+func (m *Manager) metadataToken(ctx context.Context) (*googleToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gceMetadataTokenURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	return m.fetchGoogleToken(req)
+}
+
+// fetchGoogleToken sends a token request and parses the response
+func (m *Manager) fetchGoogleToken(req *http.Request) (*googleToken, error) {
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := m.httpDoer(http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("token request failed with status %s: %s", resp.Status, string(body))
+	}
+
+	var token googleToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+
+	return &token, nil
+}
+
+// loadServiceAccount reads a service account key file
+func loadServiceAccount(path string) (*googleServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+
+	if account.Type != "service_account" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials file %s is not a service account key", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &account, nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#8 or PKCS#1 RSA key
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return key, nil
+}
+
+// signJWT returns an RS256 signed JWT with the given claims
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	header, err := jsonBytes(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := jsonBytes(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,169 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypePushover sends push notifications to phones through Pushover
+const ConnectorTypePushover = "pushover"
+
+// Pushover defaults
+const (
+	defaultPushoverAPI             = "https://api.pushover.net/1/messages.json"
+	defaultPushoverReputationURL   = "https://www.abuseipdb.com/check/{ip}"
+	defaultPushoverReputationTitle = "Check IP reputation"
+	defaultPushoverPriority        = "0"
+	defaultPushoverUnbanPriority   = "-1"
+	defaultPushoverRetry           = "60"
+	defaultPushoverExpire          = "3600"
+)
+
+// Pushover message limits, in characters
+const (
+	pushoverMaxTitle   = 250
+	pushoverMaxMessage = 1024
+	pushoverMaxURL     = 512
+)
+
+// pushoverEmergency is the priority that repeats until acknowledged
+const pushoverEmergency = 2
+
+// pushoverMessage is the request of the messages API
+type pushoverMessage struct {
+	Token     string `json:"token"`
+	User      string `json:"user"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Priority  int    `json:"priority"`
+	Retry     int    `json:"retry,omitempty"`  // Seconds between repeats of an emergency message
+	Expire    int    `json:"expire,omitempty"` // Seconds an emergency message repeats for
+	URL       string `json:"url,omitempty"`
+	URLTitle  string `json:"url_title,omitempty"`
+	Device    string `json:"device,omitempty"`
+	Sound     string `json:"sound,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// pushoverResponse is the reply of the messages API, which sets status to 0
+// with errors when the message is rejected
+type pushoverResponse struct {
+	Status  int      `json:"status"`
+	Request string   `json:"request"`
+	Errors  []string `json:"errors"`
+}
+
+func init() {
+	registerNative(ConnectorTypePushover, nativeConnector{validate: validatePushover, execute: executePushover})
+}
+
+// validatePushover checks the Pushover connector settings
+func validatePushover(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "app_token", "user_key"); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"priority", "unban_priority"} {
+		if value := connector.Settings[key]; value != "" {
+			if n, err := strconv.Atoi(value); err != nil || n < -2 || n > pushoverEmergency {
+				return fmt.Errorf("%s must be a number from -2 to 2: %s", key, value)
+			}
+		}
+	}
+	if value := connector.Settings["retry"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 30 {
+			return fmt.Errorf("retry must be at least 30 seconds: %s", value)
+		}
+	}
+	if value := connector.Settings["expire"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 || n > 10800 {
+			return fmt.Errorf("expire must be from 1 to 10800 seconds: %s", value)
+		}
+	}
+
+	if value := connector.Settings["reputation_url"]; value != "" && value != "none" {
+		if u, err := url.ParseRequestURI(strings.ReplaceAll(value, "{ip}", "192.0.2.1")); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("reputation_url must be an http(s) URL or 'none': %s", value)
+		}
+	}
+
+	if value := connector.Settings["api_url"]; value != "" {
+		if u, err := url.ParseRequestURI(value); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("api_url must be an http(s) URL: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// executePushover pushes the event to the user's devices. Bans and surges
+// are sent with priority, unbans and summaries with unban_priority, and
+// events naming an IP link to its reputation.
+func executePushover(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	priorityKey, priorityDefault := "priority", defaultPushoverPriority
+	if data.IsUnban() || data.IsRestore() || data.IsBulkUnban() {
+		priorityKey, priorityDefault = "unban_priority", defaultPushoverUnbanPriority
+	}
+	priority, _ := strconv.Atoi(settingOr(connector, priorityKey, priorityDefault))
+
+	message := pushoverMessage{
+		Token:     connector.Settings["app_token"],
+		User:      connector.Settings["user_key"],
+		Title:     truncateChars(m.messageTitle(connector, data), pushoverMaxTitle),
+		Message:   truncateChars(m.messageText(connector, data), pushoverMaxMessage),
+		Priority:  priority,
+		Device:    connector.Settings["device"],
+		Sound:     connector.Settings["sound"],
+		Timestamp: data.Time.Unix(),
+	}
+	if priority == pushoverEmergency {
+		message.Retry, _ = strconv.Atoi(settingOr(connector, "retry", defaultPushoverRetry))
+		message.Expire, _ = strconv.Atoi(settingOr(connector, "expire", defaultPushoverExpire))
+	}
+	if link := pushoverReputationURL(connector, data); link != "" {
+		message.URL = link
+		message.URLTitle = settingOr(connector, "reputation_title", defaultPushoverReputationTitle)
+	}
+
+	var resp pushoverResponse
+	if err := m.doJSON(ctx, http.MethodPost, settingOr(connector, "api_url", defaultPushoverAPI), nil, message, &resp); err != nil {
+		return err
+	}
+	if resp.Status != 1 {
+		return fmt.Errorf("pushover rejected the message: %s", strings.Join(resp.Errors, "; "))
+	}
+	return nil
+}
+
+// pushoverReputationURL returns the reputation link of the event's IP, or
+// "" if it has none: for meta-events, IPs pseudonymized by the privacy mode
+// and with reputation_url set to none
+func pushoverReputationURL(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	template := settingOr(connector, "reputation_url", defaultPushoverReputationURL)
+	if template == "none" || net.ParseIP(data.IP) == nil {
+		return ""
+	}
+	link := strings.ReplaceAll(template, "{ip}", url.PathEscape(data.IP))
+	if len(link) > pushoverMaxURL {
+		return ""
+	}
+	return link
+}
+
+// truncateChars shortens s to at most maxChars characters, marking the cut
+// with an ellipsis
+func truncateChars(s string, maxChars int) string {
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	return string(runes[:maxChars-1]) + "…"
+}
@@ -0,0 +1,281 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sigv4"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeS3 uploads blocklists and reports to S3-compatible storage
+const ConnectorTypeS3 = "s3"
+
+// Object storage providers
+const (
+	S3ProviderAWS   = "aws"
+	S3ProviderGCS   = "gcs"
+	S3ProviderMinIO = "minio"
+)
+
+// Uploaded content
+const (
+	S3ContentBlocklist = "blocklist" // Active bans in a blocklist format
+	S3ContentCSV       = "csv"       // Events of the day as CSV
+	S3ContentHTML      = "html"      // Events of the day as an HTML table
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage
+const gcsEndpoint = "https://storage.googleapis.com"
+
+func init() {
+	registerNative(ConnectorTypeS3, nativeConnector{validate: validateS3, execute: executeS3})
+}
+
+// validateS3 checks the object storage connector settings
+func validateS3(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "bucket"); err != nil {
+		return err
+	}
+
+	provider := settingOr(connector, "provider", S3ProviderAWS)
+	switch provider {
+	case S3ProviderAWS, S3ProviderGCS:
+	case S3ProviderMinIO:
+		if err := requireSettings(connector, "endpoint"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid provider '%s', must be '%s', '%s' or '%s'", provider, S3ProviderAWS, S3ProviderGCS, S3ProviderMinIO)
+	}
+
+	switch content := settingOr(connector, "content", S3ContentBlocklist); content {
+	case S3ContentBlocklist:
+		return validateBlocklistFormat(connector)
+	case S3ContentCSV, S3ContentHTML:
+	default:
+		return fmt.Errorf("invalid content '%s', must be '%s', '%s' or '%s'", content, S3ContentBlocklist, S3ContentCSV, S3ContentHTML)
+	}
+
+	return nil
+}
+
+// executeS3 renders the configured content from the event store and uploads
+// it to the object path
+func executeS3(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	if !m.config.Store.Enabled {
+		return fmt.Errorf("s3 connector requires the event store to be enabled")
+	}
+	st := store.New(m.config.Store)
+
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch settingOr(connector, "content", S3ContentBlocklist) {
+	case S3ContentCSV:
+		body, err = dailyReportCSV(st, data.Time)
+		contentType = "text/csv; charset=utf-8"
+	case S3ContentHTML:
+		body, err = dailyReportHTML(st, data.Time)
+		contentType = "text/html; charset=utf-8"
+	default:
+		body, err = storeBlocklist(connector, st, data.Time)
+		contentType = "text/plain; charset=utf-8"
+	}
+	if err != nil {
+		return err
+	}
+
+	key := objectPath(connector, data)
+	target, err := objectURL(connector, key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL.RawPath = sigv4.URIEncode(req.URL.Path) // Send the path exactly as signed
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", UserAgent)
+
+	creds := sigv4.Credentials{
+		AccessKey: settingOr(connector, "access_key", os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey: settingOr(connector, "secret_key", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return fmt.Errorf("s3 connector needs 'access_key' and 'secret_key' (or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	}
+	sigv4.Sign(req, body, creds, s3Region(connector), "s3", m.clock.Now().UTC())
+
+	resp, err := m.httpDoer(http.DefaultClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("upload of %s failed with status %s: %s", key, resp.Status, string(respBody))
+	}
+
+	if m.config.Debug {
+		m.logger.Printf("S3 connector %s uploaded %d bytes to %s", connector.Name, len(body), target.Redacted())
+	}
+
+	return nil
+}
+
+// objectPath expands the path template. Supported placeholders are {date}
+// (UTC, YYYY-MM-DD), {hostname}, {jail} and {content}.
+func objectPath(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	content := settingOr(connector, "content", S3ContentBlocklist)
+	def := "fail2ban/{hostname}/blocklist.txt"
+	if content != S3ContentBlocklist {
+		def = "fail2ban/{hostname}/reports/{date}." + content
+	}
+
+	replacer := strings.NewReplacer(
+		"{date}", data.Time.UTC().Format("2006-01-02"),
+		"{hostname}", data.Hostname,
+		"{jail}", data.Jail,
+		"{content}", content,
+	)
+	return strings.TrimPrefix(replacer.Replace(settingOr(connector, "path", def)), "/")
+}
+
+// objectURL returns the URL of an object. AWS uses virtual-hosted style,
+// custom endpoints use path style.
+func objectURL(connector *config.ConnectorConfig, key string) (*url.URL, error) {
+	bucket := connector.Settings["bucket"]
+	endpoint := connector.Settings["endpoint"]
+
+	var raw string
+	switch {
+	case endpoint != "":
+		raw = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + key
+	case settingOr(connector, "provider", S3ProviderAWS) == S3ProviderGCS:
+		raw = gcsEndpoint + "/" + bucket + "/" + key
+	default:
+		raw = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, s3Region(connector), key)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object URL: %w", err)
+	}
+	return u, nil
+}
+
+// s3Region returns the signing region
+func s3Region(connector *config.ConnectorConfig) string {
+	def := "us-east-1"
+	if settingOr(connector, "provider", S3ProviderAWS) == S3ProviderGCS {
+		def = "auto"
+	}
+	return settingOr(connector, "region", def)
+}
+
+// storeBlocklist renders the active bans in the connector's blocklist format
+func storeBlocklist(connector *config.ConnectorConfig, st *store.Store, now time.Time) ([]byte, error) {
+	bans, err := st.ActiveBans(now)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]time.Time, len(bans))
+	for _, ban := range bans {
+		listed[ban.IP] = ban.Expires
+	}
+	return renderBlocklist(connector, listed), nil
+}
+
+// dayEvents returns the stored events of the UTC day containing now
+func dayEvents(st *store.Store, now time.Time) ([]types.NotificationData, error) {
+	day := now.UTC().Format("2006-01-02")
+
+	var events []types.NotificationData
+	err := st.Scan(func(data *types.NotificationData) error {
+		if data.Time.UTC().Format("2006-01-02") == day {
+			events = append(events, *data)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// reportColumns are the columns of the daily reports
+var reportColumns = []string{"time", "action", "ip", "jail", "country", "city", "isp", "failures", "hostname", "event_id"}
+
+// reportRow returns the report columns of an event
+func reportRow(data *types.NotificationData) []string {
+	return []string{
+		data.Time.UTC().Format(time.RFC3339), data.Action, data.IP, data.Jail,
+		data.Country, data.City, data.ISP, strconv.Itoa(data.Failures), data.Hostname, data.EventID,
+	}
+}
+
+// dailyReportCSV renders the day's events as CSV
+func dailyReportCSV(st *store.Store, now time.Time) ([]byte, error) {
+	events, err := dayEvents(st, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(reportColumns)
+	for i := range events {
+		_ = w.Write(reportRow(&events[i]))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to render CSV report: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// dailyReportHTML renders the day's events as a standalone HTML table
+func dailyReportHTML(st *store.Store, now time.Time) ([]byte, error) {
+	events, err := dayEvents(st, now)
+	if err != nil {
+		return nil, err
+	}
+
+	day := now.UTC().Format("2006-01-02")
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>fail2ban report %s</title></head><body>\n", day)
+	fmt.Fprintf(&buf, "<h1>fail2ban report %s</h1>\n<p>%d events</p>\n<table border=\"1\">\n<tr>", day, len(events))
+	for _, column := range reportColumns {
+		fmt.Fprintf(&buf, "<th>%s</th>", column)
+	}
+	buf.WriteString("</tr>\n")
+	for i := range events {
+		buf.WriteString("<tr>")
+		for _, cell := range reportRow(&events[i]) {
+			fmt.Fprintf(&buf, "<td>%s</td>", html.EscapeString(cell))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n</body></html>\n")
+
+	return buf.Bytes(), nil
+}
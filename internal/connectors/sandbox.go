@@ -0,0 +1,259 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+)
+
+// sandboxSetup runs inside the namespaces of an allowlist sandbox. It tells
+// the notifier the namespaces exist, waits until slirp4netns connected them,
+// pins the allowed hosts, loads the egress rules and drops the capabilities
+// before running the script. Exit status 125 means the setup failed.
+const sandboxSetup = `printf x >&4; exec 4>&-
+read -r _ <&3 || exit 125; exec 3<&-
+"$1" --bind "$2/hosts" /etc/hosts || exit 125
+"$1" --bind "$2/resolv.conf" /etc/resolv.conf || exit 125
+"$3" -f "$2/rules.nft" || exit 125
+shift 3; exec "$@"`
+
+// sandbox confines the network of a script connector to its policy
+type sandbox struct {
+	ctx       context.Context
+	connector *config.ConnectorConfig
+	policy    *config.NetworkPolicy
+	unshare   string
+	setpriv   string
+	shell     string
+	mount     string
+	nft       string
+	slirp     string
+}
+
+// newSandbox looks up the tools the connector's network policy needs. It
+// returns nil for connectors without a policy.
+func newSandbox(ctx context.Context, connector *config.ConnectorConfig) (*sandbox, error) {
+	policy := connector.Network
+	if policy == nil {
+		return nil, nil
+	}
+
+	sb := &sandbox{ctx: ctx, connector: connector, policy: policy}
+	tools := []string{"unshare", "setpriv"}
+	paths := []*string{&sb.unshare, &sb.setpriv}
+	if policy.Mode == config.NetworkModeAllowlist {
+		tools = append(tools, "sh", "mount", "nft", "slirp4netns")
+		paths = append(paths, &sb.shell, &sb.mount, &sb.nft, &sb.slirp)
+	}
+	for i, tool := range tools {
+		fullPath, err := exec.LookPath(tool)
+		if err != nil {
+			return nil, fmt.Errorf("%s not found for network mode %s: %w", tool, policy.Mode, err)
+		}
+		*paths[i] = fullPath
+	}
+	return sb, nil
+}
+
+// run runs cmd, in the sandbox unless sb is nil
+func (sb *sandbox) run(cmd *exec.Cmd) error {
+	if sb == nil {
+		return cmd.Run()
+	}
+
+	// The script keeps no capabilities in its user namespace, so it can't
+	// change the sandbox's network or rules
+	args := append([]string{"--no-new-privs", "--inh-caps=-all", "--bounding-set=-all", "--"}, cmd.Args...)
+	args = append([]string{sb.setpriv}, args...)
+
+	if sb.policy.Mode == config.NetworkModeNone {
+		sb.wrap(cmd, append([]string{"--user", "--map-root-user", "--net", "--"}, args...))
+		return cmd.Run()
+	}
+	return sb.runAllowlist(cmd, args)
+}
+
+// wrap makes cmd run args through unshare
+func (sb *sandbox) wrap(cmd *exec.Cmd, args []string) {
+	cmd.Path = sb.unshare
+	cmd.Args = append([]string{sb.unshare}, args...)
+}
+
+// runAllowlist runs cmd in a network namespace connected through
+// slirp4netns, where nftables only lets connections to the allowed
+// endpoints out
+//
+//nolint:funlen
+func (sb *sandbox) runAllowlist(cmd *exec.Cmd, args []string) error {
+	dir, err := os.MkdirTemp("", "f2bn-sandbox-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	if err := sb.writeFiles(dir); err != nil {
+		return err
+	}
+
+	// fd 3 releases the script once the network is up, fd 4 reports that
+	// the namespaces exist
+	releaseR, releaseW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer func() {
+		_ = releaseW.Close()
+	}()
+	unsharedR, unsharedW, err := os.Pipe()
+	if err != nil {
+		_ = releaseR.Close()
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer func() {
+		_ = unsharedR.Close()
+	}()
+
+	sb.wrap(cmd, append([]string{"--user", "--map-root-user", "--net", "--mount", "--", sb.shell, "-c", sandboxSetup,
+		"sandbox", sb.mount, dir, sb.nft}, args...))
+	cmd.ExtraFiles = []*os.File{releaseR, unsharedW}
+
+	err = cmd.Start()
+	_ = releaseR.Close()
+	_ = unsharedW.Close()
+	if err != nil {
+		return err
+	}
+
+	// Until the script runs, a failure makes the setup exit 125 when the
+	// release pipe closes
+	abort := func(err error) error {
+		_ = releaseW.Close()
+		_ = cmd.Wait()
+		return err
+	}
+
+	if err := waitReady(sb.ctx, unsharedR); err != nil {
+		return abort(fmt.Errorf("sandbox namespaces not created: %w", err))
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return abort(fmt.Errorf("failed to create sandbox: %w", err))
+	}
+	defer func() {
+		_ = readyR.Close()
+	}()
+
+	// slirp4netns outlives the script's namespace only until it's killed
+	//nolint:gosec // The tool path comes from LookPath and the PID from the started command
+	slirp := exec.Command(sb.slirp, "--configure", "--mtu=65520", "--disable-host-loopback", "--ready-fd=3",
+		strconv.Itoa(cmd.Process.Pid), "tap0")
+	slirp.ExtraFiles = []*os.File{readyW}
+	err = slirp.Start()
+	_ = readyW.Close()
+	if err != nil {
+		return abort(fmt.Errorf("failed to start slirp4netns: %w", err))
+	}
+	defer func() {
+		_ = slirp.Process.Kill()
+		_ = slirp.Wait()
+	}()
+
+	if err := waitReady(sb.ctx, readyR); err != nil {
+		return abort(fmt.Errorf("slirp4netns not ready: %w", err))
+	}
+
+	if _, err := releaseW.Write([]byte("\n")); err != nil {
+		return abort(fmt.Errorf("failed to start sandboxed script: %w", err))
+	}
+	_ = releaseW.Close()
+	return cmd.Wait()
+}
+
+// waitReady waits until a byte can be read from f or ctx ends
+func waitReady(ctx context.Context, f *os.File) error {
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := f.Read(buf)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = f.Close()
+		return ctx.Err()
+	}
+}
+
+// writeFiles writes the hosts file pinning the allowed hosts to the
+// addresses the rules allow, an empty resolv.conf and the nftables rules.
+// The script can't resolve other names, so DNS can't leak data.
+func (sb *sandbox) writeFiles(dir string) error {
+	endpoints, err := sb.policy.Endpoints(sb.connector)
+	if err != nil {
+		return err
+	}
+
+	hosts := []string{"127.0.0.1 localhost"}
+	rules := []string{
+		"table inet f2bn_sandbox {",
+		"\tchain output {",
+		"\t\ttype filter hook output priority 0; policy drop;",
+		"\t\toif \"lo\" accept",
+	}
+	for _, endpoint := range endpoints {
+		daddr, err := sb.resolve(endpoint.Host, &hosts)
+		if err != nil {
+			return err
+		}
+		rule := "\t\tip daddr " + daddr
+		if endpoint.Port > 0 {
+			rule += fmt.Sprintf(" tcp dport %d", endpoint.Port)
+		}
+		rules = append(rules, rule+" accept")
+	}
+	rules = append(rules, "\t}", "}")
+
+	files := map[string]string{
+		"hosts":       strings.Join(hosts, "\n") + "\n",
+		"resolv.conf": "# DNS is disabled in the connector sandbox\n",
+		"rules.nft":   strings.Join(rules, "\n") + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), state.FilePermission); err != nil {
+			return fmt.Errorf("failed to write sandbox %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// resolve returns the nftables address set of an endpoint host. Host names
+// are resolved now and added to hosts.
+func (sb *sandbox) resolve(host string, hosts *[]string) (string, error) {
+	if strings.Contains(host, "/") || net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(sb.ctx, "ip4", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve sandbox endpoint %s: %w", host, err)
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+		*hosts = append(*hosts, ip.String()+" "+host)
+	}
+	return "{ " + strings.Join(addrs, ", ") + " }", nil
+}
@@ -0,0 +1,74 @@
+package connectors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// slaConnectors returns the names of the SLA escalation connectors,
+// including the members of escalation groups
+func (m *Manager) slaConnectors() []string {
+	var names []string
+	for _, name := range m.config.SLA.Escalation {
+		if group, found := m.config.GetGroupByName(name); found {
+			names = append(names, group.Connectors...)
+		} else {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// EscalateSLA notifies the SLA escalation connectors and groups of a ban
+// nobody acknowledged in time. Their results are the Escalation of the
+// returned batch.
+func (m *Manager) EscalateSLA(data *types.NotificationData) (*types.BatchResult, error) {
+	start := m.clock.Now()
+	batch := &types.BatchResult{EventID: data.EventID, NotificationData: *data, Timestamp: start}
+
+	var failed []string
+	for _, name := range m.config.SLA.Escalation {
+		var result *types.ExecutionResult
+		var err error
+		if group, found := m.config.GetGroupByName(name); found {
+			fg := failoverGroup{name: group.Name}
+			for _, member := range group.Connectors {
+				if connector, ok := m.config.GetConnectorByName(member); ok && connector.Enabled {
+					fg.members = append(fg.members, *connector)
+				}
+			}
+			if len(fg.members) == 0 {
+				continue
+			}
+			if result, err = m.runGroup(fg, data); result == nil {
+				continue // Every member was throttled
+			}
+		} else {
+			connector, ok := m.config.GetConnectorByName(name)
+			if !ok || !connector.Enabled {
+				continue
+			}
+			r, runErr := m.runConnector(connector, data)
+			result, err = &r, runErr
+		}
+
+		if err != nil {
+			m.logger.Printf("Error: SLA escalation through %s failed: %v", name, err)
+			failed = append(failed, name)
+		}
+		batch.Escalation = append(batch.Escalation, *result)
+	}
+	batch.TotalDuration = m.clock.Now().Sub(start)
+
+	m.notifyObserver(batch)
+
+	if len(batch.Escalation) == 0 {
+		return batch, fmt.Errorf("no SLA escalation connectors enabled")
+	}
+	if len(failed) == len(batch.Escalation) {
+		return batch, fmt.Errorf("SLA escalation failed: %s", strings.Join(failed, ", "))
+	}
+	return batch, nil
+}
@@ -0,0 +1,146 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeSplunkOnCall pages through the REST endpoint of Splunk
+// On-Call, formerly VictorOps
+const ConnectorTypeSplunkOnCall = "splunk_oncall"
+
+// Splunk On-Call defaults
+const (
+	defaultSplunkOnCallAPI   = "https://alert.victorops.com/integrations/generic/20131114/alert"
+	defaultSplunkOnCallBan   = "CRITICAL"
+	defaultSplunkOnCallUnban = "RECOVERY"
+	splunkOnCallTool         = "fail2ban-notify"
+)
+
+// splunkOnCallMessageTypes are the message types the REST endpoint accepts
+var splunkOnCallMessageTypes = []string{"CRITICAL", "WARNING", "INFO", "ACKNOWLEDGEMENT", "RECOVERY"}
+
+// splunkOnCallAlert is the body of a REST endpoint alert. Alerts with the
+// same entity_id form one incident, which RECOVERY resolves.
+type splunkOnCallAlert struct {
+	MessageType       string `json:"message_type"`
+	EntityID          string `json:"entity_id"`
+	EntityDisplayName string `json:"entity_display_name"`
+	StateMessage      string `json:"state_message"`
+	StateStartTime    int64  `json:"state_start_time"`
+	MonitoringTool    string `json:"monitoring_tool"`
+	Host              string `json:"host_name,omitempty"`
+	IP                string `json:"ip,omitempty"`
+	Jail              string `json:"jail,omitempty"`
+	Country           string `json:"country,omitempty"`
+	EventID           string `json:"event_id,omitempty"`
+}
+
+// splunkOnCallResponse is the reply of the REST endpoint
+type splunkOnCallResponse struct {
+	Result  string `json:"result"`
+	Message string `json:"message"`
+}
+
+func init() {
+	registerNative(ConnectorTypeSplunkOnCall, nativeConnector{validate: validateSplunkOnCall, execute: executeSplunkOnCall})
+}
+
+// validateSplunkOnCall checks the Splunk On-Call connector settings
+func validateSplunkOnCall(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "api_key", "routing_key"); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"ban_message_type", "unban_message_type"} {
+		if value := connector.Settings[key]; value != "" && !validSplunkOnCallMessageType(value) {
+			return fmt.Errorf("invalid %s '%s', must be one of %s", key, value, strings.Join(splunkOnCallMessageTypes, ", "))
+		}
+	}
+
+	if _, err := parseKeyValueList(connector.Settings["jail_routing_keys"]); err != nil {
+		return fmt.Errorf("invalid jail_routing_keys: %w", err)
+	}
+
+	if value := connector.Settings["api_url"]; value != "" {
+		if u, err := url.ParseRequestURI(value); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("api_url must be an http(s) URL: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// validSplunkOnCallMessageType reports whether the REST endpoint accepts value
+func validSplunkOnCallMessageType(value string) bool {
+	for _, messageType := range splunkOnCallMessageTypes {
+		if messageType == value {
+			return true
+		}
+	}
+	return false
+}
+
+// executeSplunkOnCall opens an incident for a ban with ban_message_type and
+// resolves it on unban with unban_message_type. Surges page like bans, and
+// restart and bulk unban summaries are sent as INFO.
+func executeSplunkOnCall(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	alert := splunkOnCallAlert{
+		MessageType:       settingOr(connector, "ban_message_type", defaultSplunkOnCallBan),
+		EntityID:          splunkOnCallEntity(data),
+		EntityDisplayName: m.messageTitle(connector, data),
+		StateMessage:      m.messageText(connector, data),
+		StateStartTime:    data.Time.Unix(),
+		MonitoringTool:    splunkOnCallTool,
+		Host:              data.Hostname,
+		IP:                data.IP,
+		Jail:              data.Jail,
+		Country:           data.Country,
+		EventID:           data.EventID,
+	}
+	switch {
+	case data.IsUnban():
+		alert.MessageType = settingOr(connector, "unban_message_type", defaultSplunkOnCallUnban)
+	case data.IsRestore(), data.IsBulkUnban():
+		alert.MessageType = "INFO"
+	}
+
+	routingKey := connector.Settings["routing_key"]
+	jailKeys, _ := parseKeyValueList(connector.Settings["jail_routing_keys"])
+	if key, ok := jailKeys[data.Jail]; ok {
+		routingKey = key
+	}
+
+	endpoint := strings.TrimRight(settingOr(connector, "api_url", defaultSplunkOnCallAPI), "/") +
+		"/" + url.PathEscape(connector.Settings["api_key"]) + "/" + url.PathEscape(routingKey)
+
+	var resp splunkOnCallResponse
+	if err := m.doJSON(ctx, http.MethodPost, endpoint, nil, alert, &resp); err != nil {
+		// The request URL contains the API key
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), url.PathEscape(connector.Settings["api_key"]), "<api_key>"))
+	}
+	if resp.Result != "success" {
+		return fmt.Errorf("splunk on-call rejected the alert: %s", resp.Message)
+	}
+	return nil
+}
+
+// splunkOnCallEntity identifies the incident of an event, the same for a
+// ban and its unban
+func splunkOnCallEntity(data *types.NotificationData) string {
+	switch {
+	case data.IsSurge():
+		return fmt.Sprintf("fail2ban/%s/surge/%s", data.Hostname, data.Jail)
+	case data.IsRestore():
+		return fmt.Sprintf("fail2ban/%s/restore/%s", data.Hostname, data.EventID)
+	case data.IsBulkUnban():
+		return fmt.Sprintf("fail2ban/%s/bulk_unban/%s", data.Hostname, data.EventID)
+	}
+	return fmt.Sprintf("fail2ban/%s/%s/%s", data.Hostname, data.Jail, data.Subject())
+}
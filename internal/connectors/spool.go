@@ -0,0 +1,136 @@
+package connectors
+
+import (
+	"errors"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/spool" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// spoolFailure queues a notification that a connector failed to deliver
+func (m *Manager) spoolFailure(connectorName string, data *types.NotificationData, deliveryErr error) {
+	if !m.config.Spool.Enabled {
+		return
+	}
+
+	dropped, err := m.spool.Enqueue(connectorName, data, deliveryErr, m.clock.Now())
+	if err != nil {
+		m.logger.Printf("Warning: failed to spool notification for connector %s: %v", connectorName, err)
+		return
+	}
+
+	if dropped > 0 {
+		m.logger.Printf("Warning: spool limits reached, dropped %d queued notifications (policy %s)",
+			dropped, m.config.Spool.Overflow)
+	}
+
+	if m.config.Debug {
+		m.logger.Printf("Spooled notification for connector %s", connectorName)
+	}
+}
+
+// FlushSpool retries delivery of queued notifications, oldest first. Entries
+// for connectors that no longer exist or are disabled are discarded. Entries
+// for failover groups are retried through the group.
+func (m *Manager) FlushSpool() (delivered, failed int, err error) {
+	if !m.config.Spool.Enabled {
+		return 0, 0, nil
+	}
+
+	unlock, err := m.spool.TryLockFlush()
+	if errors.Is(err, state.ErrLocked) {
+		// Another invocation is already flushing
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+	// Runs before unlock, so the metrics match the spool this flush left
+	defer m.recordSpoolMetrics()
+
+	if _, err := m.spool.Prune(m.clock.Now()); err != nil {
+		return 0, 0, err
+	}
+
+	entries, err := m.spool.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+
+		var execErr error
+		if group, isGroup := m.config.GetGroupByName(entry.Connector); isGroup {
+			execErr = m.executeGroup(group, &entry.Data)
+		} else {
+			connector, found := m.config.GetConnectorByName(entry.Connector)
+			if !found || !connector.Enabled {
+				if err := m.spool.Remove(entry.ID); err != nil {
+					return delivered, failed, err
+				}
+				continue
+			}
+			execErr = m.executeConnector(connector, &entry.Data)
+		}
+
+		if execErr != nil {
+			entry.Attempts++
+			entry.LastError = execErr.Error()
+			if err := m.spool.Update(entry); err != nil {
+				return delivered, failed, err
+			}
+			failed++
+			continue
+		}
+
+		if err := m.spool.Remove(entry.ID); err != nil {
+			return delivered, failed, err
+		}
+		delivered++
+	}
+
+	return delivered, failed, nil
+}
+
+// SpoolStats returns the current spool usage
+func (m *Manager) SpoolStats() (spool.Stats, error) {
+	return m.spool.Stats()
+}
+
+// Metrics returns the metrics of the manager, with the spool depth and size
+// read from the spool
+func (m *Manager) Metrics() (types.Metrics, error) {
+	if m.config.Spool.Enabled {
+		stats, err := m.spool.Stats()
+		if err != nil {
+			return types.Metrics{}, err
+		}
+		m.setSpoolMetrics(stats)
+	}
+
+	m.metricsMu.Lock()
+	defer m.metricsMu.Unlock()
+	return m.metrics, nil
+}
+
+// recordSpoolMetrics refreshes the spool depth and size in the metrics. It
+// logs instead of failing, as the flush itself already succeeded or failed.
+func (m *Manager) recordSpoolMetrics() {
+	stats, err := m.spool.Stats()
+	if err != nil {
+		m.logger.Printf("Warning: failed to read spool for metrics: %v", err)
+		return
+	}
+	m.setSpoolMetrics(stats)
+}
+
+// setSpoolMetrics stores the spool usage in the metrics
+func (m *Manager) setSpoolMetrics(stats spool.Stats) {
+	m.metricsMu.Lock()
+	defer m.metricsMu.Unlock()
+	m.metrics.SpoolDepth = int64(stats.Events)
+	m.metrics.SpoolBytes = stats.Bytes
+}
@@ -0,0 +1,239 @@
+package connectors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeSQL inserts events into a relational database table
+const ConnectorTypeSQL = "sql"
+
+// SQL drivers with known placeholder styles
+const (
+	SQLDriverPostgres = "postgres"
+	SQLDriverMySQL    = "mysql"
+)
+
+// Connection pool limits per database
+const (
+	sqlMaxOpenConns = 4
+	sqlMaxIdleConns = 2
+)
+
+// sqlIdentifier matches table and column names that are safe to interpolate
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// eventFields returns the value of each event field that sinks can store
+var eventFields = map[string]func(data *types.NotificationData) interface{}{
+	"ip":                func(d *types.NotificationData) interface{} { return d.IP },
+	"ip_hash":           func(d *types.NotificationData) interface{} { return d.IPHash },
+	"jail":              func(d *types.NotificationData) interface{} { return d.Jail },
+	"action":            func(d *types.NotificationData) interface{} { return d.Action },
+	"time":              func(d *types.NotificationData) interface{} { return d.Time.UTC() },
+	"country":           func(d *types.NotificationData) interface{} { return d.Country },
+	"country_code":      func(d *types.NotificationData) interface{} { return d.CountryCode },
+	"continent":         func(d *types.NotificationData) interface{} { return d.Continent },
+	"region":            func(d *types.NotificationData) interface{} { return d.Region },
+	"city":              func(d *types.NotificationData) interface{} { return d.City },
+	"isp":               func(d *types.NotificationData) interface{} { return d.ISP },
+	"asn":               func(d *types.NotificationData) interface{} { return d.ASN },
+	"hostname":          func(d *types.NotificationData) interface{} { return d.Hostname },
+	"target_host":       func(d *types.NotificationData) interface{} { return d.TargetHost },
+	"failures":          func(d *types.NotificationData) interface{} { return d.Failures },
+	"bantime":           func(d *types.NotificationData) interface{} { return d.BanTime },
+	"latitude":          func(d *types.NotificationData) interface{} { return d.Latitude },
+	"longitude":         func(d *types.NotificationData) interface{} { return d.Longitude },
+	"honeypot_sessions": func(d *types.NotificationData) interface{} { return d.HoneypotSessions },
+	"risk_score":        func(d *types.NotificationData) interface{} { return d.RiskScore },
+	"event_id":          func(d *types.NotificationData) interface{} { return d.EventID },
+	"ban_count": func(d *types.NotificationData) interface{} {
+		if d.History == nil {
+			return 0
+		}
+		return d.History.BanCount
+	},
+	"campaign_id": func(d *types.NotificationData) interface{} {
+		if d.Campaign == nil {
+			return 0
+		}
+		return d.Campaign.ID
+	},
+	"client_ip": func(d *types.NotificationData) interface{} {
+		if d.Client == nil {
+			return ""
+		}
+		return d.Client.IP
+	},
+}
+
+// defaultSQLColumns is the mapping used when 'columns' is not set
+const defaultSQLColumns = "time,action,ip,jail,country,city,isp,hostname,failures"
+
+// sqlPool shares connections and prepared statements between events, so a
+// daemon keeps its pool open instead of reconnecting per event
+var sqlPool = struct {
+	sync.Mutex
+	dbs   map[string]*sql.DB
+	stmts map[string]*sql.Stmt
+}{
+	dbs:   make(map[string]*sql.DB),
+	stmts: make(map[string]*sql.Stmt),
+}
+
+func init() {
+	registerNative(ConnectorTypeSQL, nativeConnector{validate: validateSQL, execute: executeSQL})
+}
+
+// validateSQL checks the SQL connector settings
+func validateSQL(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "driver", "dsn"); err != nil {
+		return err
+	}
+
+	switch driver := connector.Settings["driver"]; driver {
+	case SQLDriverPostgres, SQLDriverMySQL:
+		// Drivers are linked only with the build tag of the same name
+		if !sqlDriverLinked(driver) {
+			return fmt.Errorf("built without the %s driver, rebuild with '-tags %s'", driver, driver)
+		}
+	default:
+		return fmt.Errorf("invalid driver '%s', must be '%s' or '%s'", driver, SQLDriverPostgres, SQLDriverMySQL)
+	}
+
+	if table := settingOr(connector, "table", "fail2ban_events"); !sqlIdentifier.MatchString(table) {
+		return fmt.Errorf("invalid table name '%s'", table)
+	}
+
+	_, _, err := sqlColumns(connector)
+	return err
+}
+
+// sqlDriverLinked returns true if the binary registered the driver
+func sqlDriverLinked(driver string) bool {
+	for _, name := range sql.Drivers() {
+		if name == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// executeSQL inserts the event with a prepared statement
+func executeSQL(ctx context.Context, _ *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	stmt, fields, err := sqlStatement(ctx, connector)
+	if err != nil {
+		return err
+	}
+
+	args := make([]interface{}, len(fields))
+	for i, field := range fields {
+		args[i] = eventFields[field](data)
+	}
+
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+	return nil
+}
+
+// sqlStatement returns the prepared insert statement for the connector and
+// the event fields bound to its parameters
+func sqlStatement(ctx context.Context, connector *config.ConnectorConfig) (*sql.Stmt, []string, error) {
+	fields, columns, err := sqlColumns(connector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	driver := connector.Settings["driver"]
+	dsn := connector.Settings["dsn"]
+	table := settingOr(connector, "table", "fail2ban_events")
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		if driver == SQLDriverPostgres {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	sqlPool.Lock()
+	defer sqlPool.Unlock()
+
+	poolKey := driver + "\x00" + dsn
+	if stmt, ok := sqlPool.stmts[poolKey+"\x00"+query]; ok {
+		return stmt, fields, nil
+	}
+
+	db, ok := sqlPool.dbs[poolKey]
+	if !ok {
+		db, err = sql.Open(driver, dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database (is the %s driver compiled in?): %w", driver, err)
+		}
+		db.SetMaxOpenConns(sqlMaxOpenConns)
+		db.SetMaxIdleConns(sqlMaxIdleConns)
+		sqlPool.dbs[poolKey] = db
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	sqlPool.stmts[poolKey+"\x00"+query] = stmt
+
+	return stmt, fields, nil
+}
+
+// sqlColumns parses the 'columns' setting: a comma-separated list of event
+// fields, each optionally mapped to a column name as field=column
+func sqlColumns(connector *config.ConnectorConfig) (fields, columns []string, err error) {
+	for _, item := range strings.Split(settingOr(connector, "columns", defaultSQLColumns), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		field, column, mapped := strings.Cut(item, "=")
+		field = strings.TrimSpace(field)
+		if !mapped {
+			column = field
+		}
+		column = strings.TrimSpace(column)
+
+		if _, ok := eventFields[field]; !ok {
+			return nil, nil, fmt.Errorf("unknown event field '%s' in columns, must be one of %s", field, strings.Join(eventFieldNames(), ", "))
+		}
+		if !sqlIdentifier.MatchString(column) {
+			return nil, nil, fmt.Errorf("invalid column name '%s'", column)
+		}
+
+		fields = append(fields, field)
+		columns = append(columns, column)
+	}
+
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("columns cannot be empty")
+	}
+	return fields, columns, nil
+}
+
+// eventFieldNames returns the names of the storable event fields
+func eventFieldNames() []string {
+	names := make([]string, 0, len(eventFields))
+	for name := range eventFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
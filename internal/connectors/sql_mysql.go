@@ -0,0 +1,8 @@
+//go:build mysql
+
+package connectors
+
+// Link the MySQL driver for the sql connector:
+//
+//	go get github.com/go-sql-driver/mysql && go build -tags mysql ./cmd/fail2ban-notify
+import _ "github.com/go-sql-driver/mysql"
@@ -0,0 +1,8 @@
+//go:build postgres
+
+package connectors
+
+// Link the PostgreSQL driver for the sql connector:
+//
+//	go get github.com/lib/pq && go build -tags postgres ./cmd/fail2ban-notify
+import _ "github.com/lib/pq"
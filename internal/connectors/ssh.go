@@ -0,0 +1,143 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeSSH runs a command on a remote host over SSH
+const ConnectorTypeSSH = "ssh"
+
+func init() {
+	registerNative(ConnectorTypeSSH, nativeConnector{validate: validateSSH, execute: executeSSH})
+}
+
+// validateSSH checks the SSH connector settings. The host key must be pinned.
+func validateSSH(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "host", "host_key"); err != nil {
+		return err
+	}
+
+	if connector.Settings["command"] == "" && connector.Settings["ban_command"] == "" {
+		return fmt.Errorf("ssh connector must have 'command' or 'ban_command' setting")
+	}
+
+	if len(strings.Fields(connector.Settings["host_key"])) < 2 {
+		return fmt.Errorf("host_key must be a public key line such as 'ssh-ed25519 AAAA...'")
+	}
+
+	if port := connector.Settings["port"]; port != "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			return fmt.Errorf("invalid port '%s'", port)
+		}
+	}
+
+	if key := connector.Settings["key"]; key != "" && !filepath.IsAbs(key) {
+		return fmt.Errorf("key must be an absolute path: %s", key)
+	}
+
+	return nil
+}
+
+// executeSSH runs the ban or unban command on the remote host with the event
+// fields substituted
+func executeSSH(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	command := connector.Settings["command"]
+	if data.IsBan() && connector.Settings["ban_command"] != "" {
+		command = connector.Settings["ban_command"]
+	}
+	if data.IsUnban() && connector.Settings["unban_command"] != "" {
+		command = connector.Settings["unban_command"]
+	}
+	if command == "" {
+		return nil // Only a ban command is configured
+	}
+
+	host := connector.Settings["host"]
+	port := settingOr(connector, "port", "22")
+
+	// Pin the host key through a known_hosts file holding only that key
+	knownHosts, err := os.CreateTemp("", "fail2ban-notify-known_hosts-*")
+	if err != nil {
+		return fmt.Errorf("failed to create known_hosts file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(knownHosts.Name())
+	}()
+
+	hostPattern := host
+	if port != "22" {
+		hostPattern = "[" + host + "]:" + port
+	}
+	if _, err := fmt.Fprintf(knownHosts, "%s %s\n", hostPattern, strings.TrimSpace(connector.Settings["host_key"])); err != nil {
+		_ = knownHosts.Close()
+		return fmt.Errorf("failed to write known_hosts file: %w", err)
+	}
+	if err := knownHosts.Close(); err != nil {
+		return fmt.Errorf("failed to write known_hosts file: %w", err)
+	}
+
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UserKnownHostsFile=" + knownHosts.Name(),
+		"-o", "GlobalKnownHostsFile=/dev/null",
+		"-o", fmt.Sprintf("ConnectTimeout=%d", connector.Timeout),
+		"-p", port,
+	}
+	if key := connector.Settings["key"]; key != "" {
+		args = append(args, "-i", key, "-o", "IdentitiesOnly=yes")
+	}
+	if user := connector.Settings["user"]; user != "" {
+		args = append(args, "-l", user)
+	}
+	args = append(args, "--", host, expandShellCommand(command, data))
+
+	sshPath, err := exec.LookPath(settingOr(connector, "ssh_path", "ssh"))
+	if err != nil {
+		return fmt.Errorf("ssh client not found: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, sshPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("remote command on %s failed: %w, stderr: %s", host, err, stderr.String())
+	}
+
+	if m.config.Debug && stdout.Len() > 0 {
+		m.logger.Printf("SSH connector %s output: %s", connector.Name, stdout.String())
+	}
+
+	return nil
+}
+
+// expandShellCommand substitutes {ip}, {jail}, {action}, {failures},
+// {bantime}, {country} and {hostname} in command. Values are single-quoted
+// so they are passed to the remote shell as one literal word each.
+func expandShellCommand(command string, data *types.NotificationData) string {
+	return strings.NewReplacer(
+		"{ip}", shellQuote(data.IP),
+		"{jail}", shellQuote(data.Jail),
+		"{action}", shellQuote(data.Action),
+		"{failures}", shellQuote(strconv.Itoa(data.Failures)),
+		"{bantime}", shellQuote(strconv.Itoa(data.BanTime)),
+		"{country}", shellQuote(data.Country),
+		"{hostname}", shellQuote(data.Hostname),
+	).Replace(command)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
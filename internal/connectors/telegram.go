@@ -0,0 +1,123 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// ConnectorTypeTelegram sends events to a Telegram chat through the Bot API
+const ConnectorTypeTelegram = "telegram"
+
+// defaultTelegramAPI is the Bot API server; self-hosted ones can be set
+// with api_url
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// telegramMessage is the request of sendMessage
+type telegramMessage struct {
+	ChatID              string `json:"chat_id"`
+	MessageThreadID     int    `json:"message_thread_id,omitempty"`
+	Text                string `json:"text"`
+	ParseMode           string `json:"parse_mode"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+	DisablePreview      bool   `json:"disable_web_page_preview"`
+}
+
+// telegramResponse is the reply of the Bot API, which sets ok to false with
+// a description on errors
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func init() {
+	registerNative(ConnectorTypeTelegram, nativeConnector{validate: validateTelegram, execute: executeTelegram})
+}
+
+// validateTelegram checks the Telegram connector settings
+func validateTelegram(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "bot_token", "chat_id"); err != nil {
+		return err
+	}
+
+	if value := connector.Settings["thread_id"]; value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("thread_id must be a positive number: %s", value)
+		}
+	}
+
+	switch silent := settingOr(connector, "silent", "false"); silent {
+	case "true", "false", "unban":
+	default:
+		return fmt.Errorf("invalid silent '%s', must be 'true', 'false' or 'unban'", silent)
+	}
+
+	if value := connector.Settings["api_url"]; value != "" {
+		if u, err := url.ParseRequestURI(value); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("api_url must be an http(s) URL: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// executeTelegram sends the event as a MarkdownV2 message, to a forum topic
+// when thread_id is set
+func executeTelegram(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	silent := settingOr(connector, "silent", "false")
+	message := telegramMessage{
+		ChatID:              connector.Settings["chat_id"],
+		Text:                m.telegramText(connector, data),
+		ParseMode:           "MarkdownV2",
+		DisableNotification: silent == "true" || (silent == "unban" && data.IsUnban()),
+		DisablePreview:      true,
+	}
+	if value := connector.Settings["thread_id"]; value != "" {
+		message.MessageThreadID, _ = strconv.Atoi(value)
+	}
+
+	endpoint := strings.TrimRight(settingOr(connector, "api_url", defaultTelegramAPI), "/") +
+		"/bot" + connector.Settings["bot_token"] + "/sendMessage"
+
+	var resp telegramResponse
+	if err := m.doJSON(ctx, http.MethodPost, endpoint, nil, message, &resp); err != nil {
+		// The request URL contains the bot token
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), connector.Settings["bot_token"], "<bot_token>"))
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram rejected the message: %s", resp.Description)
+	}
+	return nil
+}
+
+// telegramText formats the event in Telegram's MarkdownV2, which requires
+// escaping every reserved character outside of markup
+func (m *Manager) telegramText(connector *config.ConnectorConfig, data *types.NotificationData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n\n", sanitize.Escape(m.messageTitle(connector, data), sanitize.EscapeMarkdownV2))
+
+	if tmpl := m.config.TemplateFor(connector, data.Jail); tmpl != nil {
+		if body, ok := tmpl.RenderBody(sanitize.Escaped(data, sanitize.EscapeMarkdownV2)); ok {
+			b.WriteString(strings.TrimSpace(body))
+			return b.String()
+		}
+	}
+
+	for _, field := range m.messageFields(connector, data) {
+		fmt.Fprintf(&b, "*%s:* %s\n", sanitize.Escape(field.Label, sanitize.EscapeMarkdownV2),
+			sanitize.Escape(field.Value, sanitize.EscapeMarkdownV2))
+	}
+	if data.ArtifactURL != "" {
+		// Inside the link target only ) and \ are reserved
+		target := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(data.ArtifactURL)
+		fmt.Fprintf(&b, "[Log context](%s)\n", target)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
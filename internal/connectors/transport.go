@@ -0,0 +1,56 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// unixHost is the host of request URLs sent over a Unix socket
+const unixHost = "localhost"
+
+// dialFunc opens the connection for a request
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// h2cTransport returns a transport speaking HTTP/2 without TLS over dial. It
+// is set by the file linking golang.org/x/net/http2, which is only built
+// with the 'h2c' build tag.
+var h2cTransport func(dial dialFunc) http.RoundTripper
+
+// httpTarget returns the request URL and client for an HTTP connector's url
+// and transport settings. A unix:// URL is sent over the socket, with the
+// request path after a colon: unix:///run/hook.sock:/hooks/ban.
+func httpTarget(connector *config.ConnectorConfig) (string, *http.Client, error) {
+	target := connector.Settings["url"]
+	dialer := &net.Dialer{}
+	dial := dialer.DialContext
+
+	if strings.HasPrefix(target, config.UnixScheme) {
+		socket, path := config.SplitUnixURL(target)
+		target = "http://" + unixHost + path
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+
+	if connector.Settings["transport"] == config.TransportH2C {
+		if h2cTransport == nil {
+			return "", nil, fmt.Errorf("built without h2c support, rebuild with '-tags h2c'")
+		}
+		if !strings.HasPrefix(target, "http://") {
+			return "", nil, fmt.Errorf("h2c requires an http:// or unix:// url")
+		}
+		return target, &http.Client{Transport: h2cTransport(dial)}, nil
+	}
+
+	if target == connector.Settings["url"] {
+		return target, &http.Client{}, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	return target, &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,31 @@
+//go:build h2c
+
+package connectors
+
+// Link HTTP/2 support for the h2c transport of HTTP connectors:
+//
+//	go get golang.org/x/net/http2 && go build -tags h2c ./cmd/fail2ban-notify
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+func init() {
+	h2cTransport = newH2CTransport
+}
+
+// newH2CTransport returns an HTTP/2 transport with prior knowledge, which
+// sends plaintext requests over the connections opened by dial
+func newH2CTransport(dial dialFunc) http.RoundTripper {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package connectors
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeWazuh hands events to Wazuh or OSSEC for correlation
+const ConnectorTypeWazuh = "wazuh"
+
+// Wazuh delivery modes
+const (
+	WazuhModeFile   = "file"   // JSON lines read by the agent's localfile
+	WazuhModeSocket = "socket" // The agent's or manager's analysis queue
+	WazuhModeAPI    = "api"    // POST /events of the manager API (Wazuh 4.6+)
+)
+
+// Wazuh defaults
+const (
+	defaultWazuhFile    = "/var/log/fail2ban-notify/wazuh.json"
+	defaultWazuhSocket  = "/var/ossec/queue/sockets/queue"
+	wazuhIntegration    = "fail2ban-notify"
+	wazuhLocalfileQueue = '1' // Queue of log collector messages
+	wazuhFilePerm       = 0640
+	wazuhDirPerm        = 0750
+)
+
+// wazuhEvent is the JSON event. Wazuh's JSON decoder maps srcip to the
+// static field that rules and active responses use.
+type wazuhEvent struct {
+	Integration string          `json:"integration"`
+	SrcIP       string          `json:"srcip,omitempty"`
+	Fail2Ban    json.RawMessage `json:"fail2ban"`
+}
+
+// wazuhToken is the response of POST /security/user/authenticate
+type wazuhToken struct {
+	Data struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+func init() {
+	registerNative(ConnectorTypeWazuh, nativeConnector{validate: validateWazuh, execute: executeWazuh})
+}
+
+// validateWazuh checks the Wazuh connector settings
+func validateWazuh(connector *config.ConnectorConfig) error {
+	switch mode := settingOr(connector, "mode", WazuhModeFile); mode {
+	case WazuhModeFile, WazuhModeSocket:
+		for _, key := range []string{"file", "socket"} {
+			if path := connector.Settings[key]; path != "" && !filepath.IsAbs(path) {
+				return fmt.Errorf("%s must be an absolute path: %s", key, path)
+			}
+		}
+	case WazuhModeAPI:
+		if err := requireSettings(connector, "url", "username", "password"); err != nil {
+			return err
+		}
+		if u, err := url.ParseRequestURI(connector.Settings["url"]); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("url must be an http(s) URL: %s", connector.Settings["url"])
+		}
+		return validateTLSSettings(connector)
+	default:
+		return fmt.Errorf("invalid mode '%s', must be '%s', '%s' or '%s'", mode, WazuhModeFile, WazuhModeSocket, WazuhModeAPI)
+	}
+	return nil
+}
+
+// executeWazuh delivers the event as a JSON alert to the Wazuh analysis
+// engine, where rules correlate it with the agents' own events
+func executeWazuh(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	raw, err := data.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	event := wazuhEvent{Integration: wazuhIntegration, Fail2Ban: raw}
+	if net.ParseIP(data.IP) != nil {
+		event.SrcIP = data.IP
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	switch settingOr(connector, "mode", WazuhModeFile) {
+	case WazuhModeSocket:
+		return wazuhQueue(ctx, settingOr(connector, "socket", defaultWazuhSocket), line)
+	case WazuhModeAPI:
+		return wazuhAPI(ctx, m, connector, line)
+	default:
+		return wazuhFile(settingOr(connector, "file", defaultWazuhFile), line)
+	}
+}
+
+// wazuhFile appends the event to the JSON lines file the agent monitors
+func wazuhFile(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), wazuhDirPerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, wazuhFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	// One write per line keeps concurrent appends from interleaving
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// wazuhQueue sends the event to the analysis queue as a log collector
+// message, "1:<location>:<message>"
+func wazuhQueue(ctx context.Context, socket string, line []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unixgram", socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", socket, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	message := append([]byte{wazuhLocalfileQueue, ':'}, wazuhIntegration+":"...)
+	if _, err := conn.Write(append(message, line...)); err != nil {
+		return fmt.Errorf("failed to send to %s: %w", socket, err)
+	}
+	return nil
+}
+
+// wazuhAPI authenticates with the manager API and posts the event to its
+// /events endpoint
+func wazuhAPI(ctx context.Context, m *Manager, connector *config.ConnectorConfig, line []byte) error {
+	base := strings.TrimRight(connector.Settings["url"], "/")
+	client, err := connectorHTTPClient(connector, base)
+	if err != nil {
+		return err
+	}
+
+	credentials := connector.Settings["username"] + ":" + connector.Settings["password"]
+	basic := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}
+	var token wazuhToken
+	if err := m.doJSONWith(ctx, client, http.MethodPost, base+"/security/user/authenticate", basic, nil, &token); err != nil {
+		return fmt.Errorf("wazuh authentication failed: %w", err)
+	}
+	if token.Data.Token == "" {
+		return fmt.Errorf("wazuh authentication returned no token")
+	}
+
+	bearer := map[string]string{"Authorization": "Bearer " + token.Data.Token}
+	body := map[string][]string{"events": {string(line)}}
+	return m.doJSONWith(ctx, client, http.MethodPost, base+"/events", bearer, body, nil)
+}
@@ -0,0 +1,22 @@
+package connectors
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeWebex posts events to a Cisco Webex incoming webhook
+const ConnectorTypeWebex = "webex"
+
+func init() {
+	registerNative(ConnectorTypeWebex, nativeConnector{validate: validateWebhookURL, execute: executeWebex})
+}
+
+// executeWebex posts the event as a Markdown message
+func executeWebex(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	body := map[string]string{"markdown": m.markdownMessage(connector, data)}
+	return m.doJSON(ctx, http.MethodPost, connector.Settings["webhook_url"], nil, body, nil)
+}
@@ -0,0 +1,47 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// ConnectorTypeWeCom posts events to a WeCom (WeChat Work) group bot
+const ConnectorTypeWeCom = "wecom"
+
+func init() {
+	registerNative(ConnectorTypeWeCom, nativeConnector{validate: validateWebhookURL, execute: executeWeCom})
+}
+
+// executeWeCom posts the event as a Markdown message. The title is colored
+// with WeCom's font tags: orange for bans, green for unbans.
+func executeWeCom(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	color := "warning"
+	if data.IsUnban() {
+		color = "info"
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "<font color=\"%s\">**%s**</font>\n", color, sanitize.Escape(m.messageTitle(connector, data), sanitize.EscapeMarkdown))
+	m.markdownFields(&content, connector, data)
+
+	body := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": content.String()},
+	}
+
+	var resp botResponse
+	if err := m.doJSON(ctx, http.MethodPost, connector.Settings["webhook_url"], nil, body, &resp); err != nil {
+		return err
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("wecom bot rejected the message: %d %s", resp.ErrCode, resp.ErrMsg)
+	}
+
+	return nil
+}
@@ -0,0 +1,646 @@
+package connectors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // SCRAM-SHA-1 is the SASL mechanism every XMPP server offers
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeXMPP sends events as XMPP messages to contacts and
+// multi-user chat rooms
+const ConnectorTypeXMPP = "xmpp"
+
+// XMPP defaults and namespaces
+const (
+	defaultXMPPPort      = "5222"
+	defaultXMPPTLSPort   = "5223"
+	defaultXMPPNick      = "fail2ban"
+	defaultXMPPResource  = "fail2ban-notify"
+	xmppNamespaceStream  = "http://etherx.jabber.org/streams"
+	xmppNamespaceTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	xmppNamespaceSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	xmppNamespaceBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	xmppNamespaceSession = "urn:ietf:params:xml:ns:xmpp-session"
+	xmppNamespaceMUC     = "http://jabber.org/protocol/muc"
+)
+
+// xmppMechanisms are the SASL mechanisms used, strongest first. PLAIN is
+// only ever sent over TLS.
+var xmppMechanisms = []string{"SCRAM-SHA-256", "SCRAM-SHA-1", "PLAIN"}
+
+func init() {
+	registerNative(ConnectorTypeXMPP, nativeConnector{validate: validateXMPP, execute: executeXMPP})
+}
+
+// validateXMPP checks the XMPP connector settings
+func validateXMPP(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "jid", "password"); err != nil {
+		return err
+	}
+	if _, _, _, err := parseJID(connector.Settings["jid"]); err != nil {
+		return fmt.Errorf("invalid jid: %w", err)
+	}
+
+	contacts, rooms := xmppList(connector, "to"), xmppList(connector, "room")
+	if len(contacts) == 0 && len(rooms) == 0 {
+		return fmt.Errorf("xmpp connector needs a 'to' contact or a 'room'")
+	}
+	for _, jid := range append(contacts, rooms...) {
+		if _, _, _, err := parseJID(jid); err != nil {
+			return fmt.Errorf("invalid recipient %s: %w", jid, err)
+		}
+	}
+
+	if server := connector.Settings["server"]; server != "" {
+		if _, _, err := net.SplitHostPort(xmppAddress(connector, server)); err != nil {
+			return fmt.Errorf("invalid server address: %w", err)
+		}
+	}
+	if value := connector.Settings["direct_tls"]; value != "" && value != "true" && value != "false" {
+		return fmt.Errorf("direct_tls must be 'true' or 'false': %s", value)
+	}
+
+	return validateTLSSettings(connector)
+}
+
+// executeXMPP logs in, sends the event to every contact and joins every
+// room to post it there. A ping answered by the server confirms that the
+// messages were processed before the session ends.
+func executeXMPP(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	c, err := dialXMPP(ctx, connector)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	body := m.messageTitle(connector, data)
+	if text := m.messageText(connector, data); text != "" {
+		body += "\n\n" + text
+	}
+
+	for _, contact := range xmppList(connector, "to") {
+		if err := c.send(`<message to='%s' type='chat'><body>%s</body></message>`, contact, body); err != nil {
+			return err
+		}
+	}
+	for _, room := range xmppList(connector, "room") {
+		if err := c.join(room, settingOr(connector, "nick", defaultXMPPNick), connector.Settings["room_password"]); err != nil {
+			return fmt.Errorf("failed to join %s: %w", room, err)
+		}
+		if err := c.send(`<message to='%s' type='groupchat'><body>%s</body></message>`, room, body); err != nil {
+			return err
+		}
+	}
+
+	return c.ping()
+}
+
+// xmppList returns the comma-separated JIDs of a setting
+func xmppList(connector *config.ConnectorConfig, key string) []string {
+	var jids []string
+	for _, jid := range strings.Split(connector.Settings[key], ",") {
+		if jid = strings.TrimSpace(jid); jid != "" {
+			jids = append(jids, jid)
+		}
+	}
+	return jids
+}
+
+// parseJID splits local@domain/resource; the resource is optional
+func parseJID(jid string) (local, domain, resource string, err error) {
+	bare, resource, _ := strings.Cut(jid, "/")
+	local, domain, found := strings.Cut(bare, "@")
+	if !found || local == "" || domain == "" || strings.ContainsAny(bare, " <>'\"&") {
+		return "", "", "", fmt.Errorf("must be user@domain: %s", jid)
+	}
+	return local, domain, resource, nil
+}
+
+// xmppAddress returns server with the client port added
+func xmppAddress(connector *config.ConnectorConfig, server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	if connector.Settings["direct_tls"] == "true" {
+		return net.JoinHostPort(server, defaultXMPPTLSPort)
+	}
+	return net.JoinHostPort(server, defaultXMPPPort)
+}
+
+// xmppServer returns the address to connect to: the server setting, else
+// the SRV record of the domain, else the domain itself
+func xmppServer(ctx context.Context, connector *config.ConnectorConfig, domain string) string {
+	if server := connector.Settings["server"]; server != "" {
+		return xmppAddress(connector, server)
+	}
+
+	service := "xmpp-client"
+	if connector.Settings["direct_tls"] == "true" {
+		service = "xmpps-client"
+	}
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", domain)
+	if err == nil && len(records) > 0 && records[0].Target != "." {
+		return net.JoinHostPort(strings.TrimSuffix(records[0].Target, "."), strconv.Itoa(int(records[0].Port)))
+	}
+	return xmppAddress(connector, domain)
+}
+
+// xmppElement is a top-level element of the stream
+type xmppElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr    `xml:",any,attr"`
+	Children []xmppElement `xml:",any"`
+	Text     string        `xml:",chardata"`
+}
+
+// attr returns the value of an attribute, "" if it is missing
+func (e *xmppElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the local name, nil if there
+// is none
+func (e *xmppElement) child(name string) *xmppElement {
+	for i := range e.Children {
+		if e.Children[i].XMLName.Local == name {
+			return &e.Children[i]
+		}
+	}
+	return nil
+}
+
+// condition describes a stream, SASL or stanza error: the defined
+// condition and the text the server added
+func (e *xmppElement) condition() string {
+	if inner := e.child("error"); inner != nil && e.XMLName.Local != "error" {
+		e = inner
+	}
+	var condition, text string
+	for i := range e.Children {
+		switch name := e.Children[i].XMLName.Local; name {
+		case "text":
+			text = strings.TrimSpace(e.Children[i].Text)
+		default:
+			if condition == "" {
+				condition = name
+			}
+		}
+	}
+	if text != "" {
+		return condition + ": " + text
+	}
+	return condition
+}
+
+// xmppConn is a logged-in XMPP session
+type xmppConn struct {
+	conn    net.Conn
+	decoder *xml.Decoder
+	domain  string
+	bounced error // First message the server returned as undeliverable
+}
+
+// dialXMPP connects to the server of the JID's domain, secures the
+// connection with STARTTLS or direct TLS, logs in and binds a resource.
+// Unencrypted connections are refused. The context's deadline bounds the
+// whole session.
+func dialXMPP(ctx context.Context, connector *config.ConnectorConfig) (*xmppConn, error) {
+	local, domain, resource, err := parseJID(connector.Settings["jid"])
+	if err != nil {
+		return nil, err
+	}
+	if resource == "" {
+		// Unique, so that concurrent events don't end each other's sessions
+		buf := make([]byte, 4)
+		_, _ = rand.Read(buf)
+		resource = defaultXMPPResource + "-" + hex.EncodeToString(buf)
+	}
+
+	address := xmppServer(ctx, connector, domain)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c := &xmppConn{conn: conn, domain: domain}
+	if err := c.login(ctx, connector, local, resource); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// login runs the stream negotiation: TLS, SASL, then resource binding
+func (c *xmppConn) login(ctx context.Context, connector *config.ConnectorConfig, local, resource string) error {
+	secure := connector.Settings["direct_tls"] == "true"
+	if secure {
+		if err := c.startTLS(ctx, connector); err != nil {
+			return err
+		}
+	}
+
+	features, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	if !secure {
+		if features.child("starttls") == nil {
+			return fmt.Errorf("server %s does not offer STARTTLS", c.domain)
+		}
+		if err := c.send(`<starttls xmlns='%s'/>`, xmppNamespaceTLS); err != nil {
+			return err
+		}
+		reply, err := c.next()
+		if err != nil {
+			return err
+		}
+		if reply.XMLName.Local != "proceed" {
+			return fmt.Errorf("server %s refused STARTTLS", c.domain)
+		}
+		if err := c.startTLS(ctx, connector); err != nil {
+			return err
+		}
+		if features, err = c.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.authenticate(features, local, connector.Settings["password"]); err != nil {
+		return err
+	}
+	if features, err = c.open(); err != nil {
+		return err
+	}
+	return c.bind(features, resource)
+}
+
+// startTLS wraps the connection in TLS for the JID's domain, which the
+// certificate must be issued for
+func (c *xmppConn) startTLS(ctx context.Context, connector *config.ConnectorConfig) error {
+	tlsConfig, err := connectorTLSConfig(connector, c.domain)
+	if err != nil {
+		return err
+	}
+	tlsConn := tls.Client(c.conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("TLS handshake with %s failed: %w", c.domain, err)
+	}
+	c.conn = tlsConn
+	return nil
+}
+
+// open starts a new stream and returns its features
+func (c *xmppConn) open() (*xmppElement, error) {
+	header := fmt.Sprintf(`<?xml version='1.0'?><stream:stream to='%s' version='1.0' xmlns='jabber:client' xmlns:stream='%s'>`,
+		xmppEscape(c.domain), xmppNamespaceStream)
+	if _, err := c.conn.Write([]byte(header)); err != nil {
+		return nil, fmt.Errorf("failed to send to %s: %w", c.domain, err)
+	}
+
+	// The server sends nothing past the end of the previous stream's
+	// negotiation, so nothing buffered is lost with the old decoder
+	c.decoder = xml.NewDecoder(bufio.NewReader(c.conn))
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from %s: %w", c.domain, err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Space != xmppNamespaceStream || start.Name.Local != "stream" {
+				return nil, fmt.Errorf("%s is not an XMPP server", c.domain)
+			}
+			break
+		}
+	}
+
+	features, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	if features.XMLName.Local != "features" {
+		return nil, fmt.Errorf("expected stream features from %s, got %s", c.domain, features.XMLName.Local)
+	}
+	return features, nil
+}
+
+// next reads the next top-level element, returning stream errors as errors
+func (c *xmppConn) next() (*xmppElement, error) {
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from %s: %w", c.domain, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			var element xmppElement
+			if err := c.decoder.DecodeElement(&element, &t); err != nil {
+				return nil, fmt.Errorf("failed to read from %s: %w", c.domain, err)
+			}
+			if element.XMLName.Space == xmppNamespaceStream && element.XMLName.Local == "error" {
+				return nil, fmt.Errorf("stream error from %s: %s", c.domain, element.condition())
+			}
+			if element.XMLName.Local == "message" && element.attr("type") == "error" && c.bounced == nil {
+				c.bounced = fmt.Errorf("message to %s failed: %s", element.attr("from"), element.condition())
+			}
+			return &element, nil
+		case xml.EndElement:
+			return nil, fmt.Errorf("%s closed the stream", c.domain)
+		}
+	}
+}
+
+// send writes a stanza. The arguments are escaped, so they can hold any
+// text.
+func (c *xmppConn) send(format string, args ...interface{}) error {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = xmppEscape(fmt.Sprint(arg))
+	}
+	if _, err := fmt.Fprintf(c.conn, format, escaped...); err != nil {
+		return fmt.Errorf("failed to send to %s: %w", c.domain, err)
+	}
+	return nil
+}
+
+// xmppEscape escapes text for XML content and attributes. Characters XML
+// doesn't allow, such as most control characters, are replaced.
+func xmppEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// authenticate logs in with the strongest mechanism both sides support
+func (c *xmppConn) authenticate(features *xmppElement, username, password string) error {
+	offered := make(map[string]bool)
+	if mechanisms := features.child("mechanisms"); mechanisms != nil {
+		for _, mechanism := range mechanisms.Children {
+			offered[strings.TrimSpace(mechanism.Text)] = true
+		}
+	}
+
+	var mechanism string
+	for _, name := range xmppMechanisms {
+		if offered[name] {
+			mechanism = name
+			break
+		}
+	}
+
+	switch mechanism {
+	case "SCRAM-SHA-256":
+		return c.scram(mechanism, sha256.New, username, password)
+	case "SCRAM-SHA-1":
+		return c.scram(mechanism, sha1.New, username, password)
+	case "PLAIN":
+		response := base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+		if err := c.send(`<auth xmlns='%s' mechanism='PLAIN'>%s</auth>`, xmppNamespaceSASL, response); err != nil {
+			return err
+		}
+		_, err := c.saslReply("success")
+		return err
+	}
+	return fmt.Errorf("server %s offers no supported login mechanism (%s)", c.domain, strings.Join(xmppMechanisms, ", "))
+}
+
+// saslReply reads the server's next SASL step, which must be want, and
+// returns its decoded payload
+func (c *xmppConn) saslReply(want string) ([]byte, error) {
+	reply, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	if reply.XMLName.Local == "failure" {
+		return nil, fmt.Errorf("login to %s failed: %s", c.domain, reply.condition())
+	}
+	if reply.XMLName.Local != want {
+		return nil, fmt.Errorf("login to %s failed: unexpected %s", c.domain, reply.XMLName.Local)
+	}
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimSpace(reply.Text))
+	if err != nil {
+		return nil, fmt.Errorf("login to %s failed: invalid %s", c.domain, want)
+	}
+	return payload, nil
+}
+
+// scram logs in with SCRAM (RFC 5802), which keeps the password from the
+// server and verifies that the server knows it too
+func (c *xmppConn) scram(mechanism string, newHash func() hash.Hash, username, password string) error {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(buf)
+	user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	clientFirstBare := "n=" + user + ",r=" + clientNonce
+
+	encode := base64.StdEncoding.EncodeToString
+	if err := c.send(`<auth xmlns='%s' mechanism='%s'>%s</auth>`, xmppNamespaceSASL, mechanism, encode([]byte("n,,"+clientFirstBare))); err != nil {
+		return err
+	}
+
+	serverFirst, err := c.saslReply("challenge")
+	if err != nil {
+		return err
+	}
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(string(serverFirst), ",") {
+		if key, value, found := strings.Cut(part, "="); found {
+			attrs[key] = value
+		}
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	iterations, iterErr := strconv.Atoi(attrs["i"])
+	if err != nil || iterErr != nil || iterations < 1 || !strings.HasPrefix(attrs["r"], clientNonce) {
+		return fmt.Errorf("login to %s failed: invalid SCRAM challenge", c.domain)
+	}
+
+	mac := func(key []byte, message string) []byte {
+		h := hmac.New(newHash, key)
+		h.Write([]byte(message))
+		return h.Sum(nil)
+	}
+
+	// Hi() of RFC 5802, PBKDF2 with a single block
+	u := mac([]byte(password), string(salt)+"\x00\x00\x00\x01")
+	salted := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		u = mac([]byte(password), string(u))
+		for j := range salted {
+			salted[j] ^= u[j]
+		}
+	}
+
+	clientFinal := "c=biws,r=" + attrs["r"]
+	authMessage := clientFirstBare + "," + string(serverFirst) + "," + clientFinal
+	clientKey := mac(salted, "Client Key")
+	storedKey := newHash()
+	storedKey.Write(clientKey)
+	proof := mac(storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	serverSignature := encode(mac(mac(salted, "Server Key"), authMessage))
+
+	if err := c.send(`<response xmlns='%s'>%s</response>`, xmppNamespaceSASL, encode([]byte(clientFinal+",p="+encode(proof)))); err != nil {
+		return err
+	}
+
+	// The server signature comes with the success, or with a last
+	// challenge answered by an empty response
+	reply, err := c.next()
+	if err != nil {
+		return err
+	}
+	switch reply.XMLName.Local {
+	case "success", "challenge":
+	case "failure":
+		return fmt.Errorf("login to %s failed: %s", c.domain, reply.condition())
+	default:
+		return fmt.Errorf("login to %s failed: unexpected %s", c.domain, reply.XMLName.Local)
+	}
+	final, err := base64.StdEncoding.DecodeString(strings.TrimSpace(reply.Text))
+	if err != nil || !hmac.Equal(final, []byte("v="+serverSignature)) {
+		return fmt.Errorf("login to %s failed: the server could not prove it knows the password", c.domain)
+	}
+	if reply.XMLName.Local == "challenge" {
+		if err := c.send(`<response xmlns='%s'/>`, xmppNamespaceSASL); err != nil {
+			return err
+		}
+		_, err = c.saslReply("success")
+		return err
+	}
+	return nil
+}
+
+// bind binds the resource and, on servers still requiring it, starts a
+// session
+func (c *xmppConn) bind(features *xmppElement, resource string) error {
+	if features.child("bind") == nil {
+		return fmt.Errorf("server %s does not offer resource binding", c.domain)
+	}
+	if err := c.send(`<iq type='set' id='bind'><bind xmlns='%s'><resource>%s</resource></bind></iq>`, xmppNamespaceBind, resource); err != nil {
+		return err
+	}
+	reply, err := c.result("bind")
+	if err != nil {
+		return err
+	}
+	if reply.attr("type") == "error" {
+		return fmt.Errorf("failed to bind resource: %s", reply.condition())
+	}
+
+	if session := features.child("session"); session != nil && session.child("optional") == nil {
+		if err := c.send(`<iq type='set' id='session'><session xmlns='%s'/></iq>`, xmppNamespaceSession); err != nil {
+			return err
+		}
+		reply, err := c.result("session")
+		if err != nil {
+			return err
+		}
+		if reply.attr("type") == "error" {
+			return fmt.Errorf("failed to start session: %s", reply.condition())
+		}
+	}
+	return nil
+}
+
+// result waits for the answer to the iq with the given ID, which may be an
+// error, skipping presence, room subjects and other chatter
+func (c *xmppConn) result(id string) (*xmppElement, error) {
+	for {
+		stanza, err := c.next()
+		if err != nil {
+			return nil, err
+		}
+		if stanza.XMLName.Local == "iq" && stanza.attr("id") == id {
+			return stanza, nil
+		}
+	}
+}
+
+// join enters a room with the nickname, or with a suffixed one if another
+// occupant has it, and waits for the room to confirm
+func (c *xmppConn) join(room, nick, password string) error {
+	nicks := []string{nick, nick + "-" + strconv.Itoa(int(randomUint16()))}
+	for i, candidate := range nicks {
+		occupant := room + "/" + candidate
+		if err := c.send(`<presence to='%s'><x xmlns='%s'><history maxstanzas='0'/><password>%s</password></x></presence>`,
+			occupant, xmppNamespaceMUC, password); err != nil {
+			return err
+		}
+
+		for {
+			stanza, err := c.next()
+			if err != nil {
+				return err
+			}
+			if stanza.XMLName.Local != "presence" || !strings.EqualFold(stanza.attr("from"), occupant) {
+				continue
+			}
+			if stanza.attr("type") != "error" {
+				return nil
+			}
+			condition := stanza.condition()
+			if strings.HasPrefix(condition, "conflict") && i < len(nicks)-1 {
+				break
+			}
+			return errors.New(condition)
+		}
+	}
+	return nil
+}
+
+// ping asks the server for a reply, which it sends only after processing
+// everything before it, and returns the first message it bounced
+func (c *xmppConn) ping() error {
+	if err := c.send(`<iq type='get' id='ping' to='%s'><ping xmlns='urn:xmpp:ping'/></iq>`, c.domain); err != nil {
+		return err
+	}
+	// An error answer, such as from a server without ping support, still
+	// confirms the messages went through
+	if _, err := c.result("ping"); err != nil {
+		return err
+	}
+	return c.bounced
+}
+
+// close ends the stream and the connection
+func (c *xmppConn) close() {
+	_, _ = c.conn.Write([]byte("</stream:stream>"))
+	_ = c.conn.Close()
+}
+
+// randomUint16 returns a random number for nickname suffixes
+func randomUint16() uint16 {
+	buf := make([]byte, 2)
+	_, _ = rand.Read(buf)
+	return uint16(buf[0])<<8 | uint16(buf[1])
+}
@@ -0,0 +1,172 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// ConnectorTypeZabbix pushes events to Zabbix trapper items with the
+// sender protocol
+const ConnectorTypeZabbix = "zabbix"
+
+// Zabbix sender protocol
+const (
+	defaultZabbixPort = "10051"
+	defaultZabbixKey  = "fail2ban.event"
+	zabbixHeader      = "ZBXD\x01" // Protocol signature and flags
+	zabbixMaxResponse = 64 * 1024
+)
+
+// zabbixProcessed extracts the failed count from a sender response
+var zabbixProcessed = regexp.MustCompile(`failed: (\d+)`)
+
+// zabbixItem is one value sent to a trapper item
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// zabbixRequest is a sender data request
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+}
+
+// zabbixResponse is the server's reply to a sender data request
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+func init() {
+	registerNative(ConnectorTypeZabbix, nativeConnector{validate: validateZabbix, execute: executeZabbix})
+}
+
+// validateZabbix checks the Zabbix connector settings
+func validateZabbix(connector *config.ConnectorConfig) error {
+	if err := requireSettings(connector, "server"); err != nil {
+		return err
+	}
+
+	if _, _, err := net.SplitHostPort(zabbixAddress(connector)); err != nil {
+		return fmt.Errorf("invalid server address: %w", err)
+	}
+
+	return nil
+}
+
+// executeZabbix sends the event as JSON to the trapper item of the host
+func executeZabbix(ctx context.Context, m *Manager, connector *config.ConnectorConfig, data *types.NotificationData) error {
+	value, err := data.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	host := settingOr(connector, "host", data.Hostname)
+	if host == "" {
+		return fmt.Errorf("zabbix connector needs a 'host' setting when the hostname is unknown")
+	}
+
+	request := zabbixRequest{
+		Request: "sender data",
+		Data: []zabbixItem{{
+			Host:  host,
+			Key:   settingOr(connector, "key", defaultZabbixKey),
+			Value: string(value),
+			Clock: data.Time.Unix(),
+		}},
+		Clock: m.clock.Now().Unix(),
+	}
+
+	response, err := zabbixSend(ctx, zabbixAddress(connector), request)
+	if err != nil {
+		return err
+	}
+
+	if response.Response != "success" {
+		return fmt.Errorf("zabbix server rejected the data: %s", response.Info)
+	}
+	if match := zabbixProcessed.FindStringSubmatch(response.Info); match != nil {
+		if failed, _ := strconv.Atoi(match[1]); failed > 0 {
+			return fmt.Errorf("zabbix server did not accept the value (%s), check that host '%s' has a trapper item '%s'",
+				response.Info, host, request.Data[0].Key)
+		}
+	}
+
+	return nil
+}
+
+// zabbixAddress returns the server address with the default port added
+func zabbixAddress(connector *config.ConnectorConfig) string {
+	server := connector.Settings["server"]
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(server, defaultZabbixPort)
+	}
+	return server
+}
+
+// zabbixSend sends a request and reads the response, both framed with the
+// protocol header and a little-endian 64-bit length
+func zabbixSend(ctx context.Context, address string, request zabbixRequest) (*zabbixResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to zabbix server: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var packet bytes.Buffer
+	packet.WriteString(zabbixHeader)
+	_ = binary.Write(&packet, binary.LittleEndian, uint64(len(payload)))
+	packet.Write(payload)
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send data: %w", err)
+	}
+
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if string(header[:4]) != zabbixHeader[:4] {
+		return nil, fmt.Errorf("invalid response from zabbix server")
+	}
+
+	length := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if length > zabbixMaxResponse {
+		return nil, fmt.Errorf("zabbix response too large: %d bytes", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var response zabbixResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,327 @@
+// Package countries maps the country codes and names GeoIP providers
+// return to ISO 3166-1 codes, common English names and continents
+package countries
+
+import "strings"
+
+// Country is an entry of the ISO 3166-1 country table
+type Country struct {
+	Name      string // Common English name, the same whatever the provider
+	Continent string // Continent code: AF, AN, AS, EU, NA, OC or SA
+}
+
+// countries maps ISO 3166-1 alpha-2 codes to country names and continents
+var countries = map[string]Country{
+	"AD": {"Andorra", "EU"},
+	"AE": {"United Arab Emirates", "AS"},
+	"AF": {"Afghanistan", "AS"},
+	"AG": {"Antigua and Barbuda", "NA"},
+	"AI": {"Anguilla", "NA"},
+	"AL": {"Albania", "EU"},
+	"AM": {"Armenia", "AS"},
+	"AO": {"Angola", "AF"},
+	"AQ": {"Antarctica", "AN"},
+	"AR": {"Argentina", "SA"},
+	"AS": {"American Samoa", "OC"},
+	"AT": {"Austria", "EU"},
+	"AU": {"Australia", "OC"},
+	"AW": {"Aruba", "NA"},
+	"AX": {"Åland Islands", "EU"},
+	"AZ": {"Azerbaijan", "AS"},
+	"BA": {"Bosnia and Herzegovina", "EU"},
+	"BB": {"Barbados", "NA"},
+	"BD": {"Bangladesh", "AS"},
+	"BE": {"Belgium", "EU"},
+	"BF": {"Burkina Faso", "AF"},
+	"BG": {"Bulgaria", "EU"},
+	"BH": {"Bahrain", "AS"},
+	"BI": {"Burundi", "AF"},
+	"BJ": {"Benin", "AF"},
+	"BL": {"Saint Barthélemy", "NA"},
+	"BM": {"Bermuda", "NA"},
+	"BN": {"Brunei", "AS"},
+	"BO": {"Bolivia", "SA"},
+	"BQ": {"Caribbean Netherlands", "NA"},
+	"BR": {"Brazil", "SA"},
+	"BS": {"Bahamas", "NA"},
+	"BT": {"Bhutan", "AS"},
+	"BV": {"Bouvet Island", "AN"},
+	"BW": {"Botswana", "AF"},
+	"BY": {"Belarus", "EU"},
+	"BZ": {"Belize", "NA"},
+	"CA": {"Canada", "NA"},
+	"CC": {"Cocos (Keeling) Islands", "OC"},
+	"CD": {"DR Congo", "AF"},
+	"CF": {"Central African Republic", "AF"},
+	"CG": {"Republic of the Congo", "AF"},
+	"CH": {"Switzerland", "EU"},
+	"CI": {"Ivory Coast", "AF"},
+	"CK": {"Cook Islands", "OC"},
+	"CL": {"Chile", "SA"},
+	"CM": {"Cameroon", "AF"},
+	"CN": {"China", "AS"},
+	"CO": {"Colombia", "SA"},
+	"CR": {"Costa Rica", "NA"},
+	"CU": {"Cuba", "NA"},
+	"CV": {"Cabo Verde", "AF"},
+	"CW": {"Curaçao", "NA"},
+	"CX": {"Christmas Island", "OC"},
+	"CY": {"Cyprus", "AS"},
+	"CZ": {"Czechia", "EU"},
+	"DE": {"Germany", "EU"},
+	"DJ": {"Djibouti", "AF"},
+	"DK": {"Denmark", "EU"},
+	"DM": {"Dominica", "NA"},
+	"DO": {"Dominican Republic", "NA"},
+	"DZ": {"Algeria", "AF"},
+	"EC": {"Ecuador", "SA"},
+	"EE": {"Estonia", "EU"},
+	"EG": {"Egypt", "AF"},
+	"EH": {"Western Sahara", "AF"},
+	"ER": {"Eritrea", "AF"},
+	"ES": {"Spain", "EU"},
+	"ET": {"Ethiopia", "AF"},
+	"FI": {"Finland", "EU"},
+	"FJ": {"Fiji", "OC"},
+	"FK": {"Falkland Islands", "SA"},
+	"FM": {"Micronesia", "OC"},
+	"FO": {"Faroe Islands", "EU"},
+	"FR": {"France", "EU"},
+	"GA": {"Gabon", "AF"},
+	"GB": {"United Kingdom", "EU"},
+	"GD": {"Grenada", "NA"},
+	"GE": {"Georgia", "AS"},
+	"GF": {"French Guiana", "SA"},
+	"GG": {"Guernsey", "EU"},
+	"GH": {"Ghana", "AF"},
+	"GI": {"Gibraltar", "EU"},
+	"GL": {"Greenland", "NA"},
+	"GM": {"Gambia", "AF"},
+	"GN": {"Guinea", "AF"},
+	"GP": {"Guadeloupe", "NA"},
+	"GQ": {"Equatorial Guinea", "AF"},
+	"GR": {"Greece", "EU"},
+	"GS": {"South Georgia and the South Sandwich Islands", "AN"},
+	"GT": {"Guatemala", "NA"},
+	"GU": {"Guam", "OC"},
+	"GW": {"Guinea-Bissau", "AF"},
+	"GY": {"Guyana", "SA"},
+	"HK": {"Hong Kong", "AS"},
+	"HM": {"Heard Island and McDonald Islands", "AN"},
+	"HN": {"Honduras", "NA"},
+	"HR": {"Croatia", "EU"},
+	"HT": {"Haiti", "NA"},
+	"HU": {"Hungary", "EU"},
+	"ID": {"Indonesia", "AS"},
+	"IE": {"Ireland", "EU"},
+	"IL": {"Israel", "AS"},
+	"IM": {"Isle of Man", "EU"},
+	"IN": {"India", "AS"},
+	"IO": {"British Indian Ocean Territory", "AF"},
+	"IQ": {"Iraq", "AS"},
+	"IR": {"Iran", "AS"},
+	"IS": {"Iceland", "EU"},
+	"IT": {"Italy", "EU"},
+	"JE": {"Jersey", "EU"},
+	"JM": {"Jamaica", "NA"},
+	"JO": {"Jordan", "AS"},
+	"JP": {"Japan", "AS"},
+	"KE": {"Kenya", "AF"},
+	"KG": {"Kyrgyzstan", "AS"},
+	"KH": {"Cambodia", "AS"},
+	"KI": {"Kiribati", "OC"},
+	"KM": {"Comoros", "AF"},
+	"KN": {"Saint Kitts and Nevis", "NA"},
+	"KP": {"North Korea", "AS"},
+	"KR": {"South Korea", "AS"},
+	"KW": {"Kuwait", "AS"},
+	"KY": {"Cayman Islands", "NA"},
+	"KZ": {"Kazakhstan", "AS"},
+	"LA": {"Laos", "AS"},
+	"LB": {"Lebanon", "AS"},
+	"LC": {"Saint Lucia", "NA"},
+	"LI": {"Liechtenstein", "EU"},
+	"LK": {"Sri Lanka", "AS"},
+	"LR": {"Liberia", "AF"},
+	"LS": {"Lesotho", "AF"},
+	"LT": {"Lithuania", "EU"},
+	"LU": {"Luxembourg", "EU"},
+	"LV": {"Latvia", "EU"},
+	"LY": {"Libya", "AF"},
+	"MA": {"Morocco", "AF"},
+	"MC": {"Monaco", "EU"},
+	"MD": {"Moldova", "EU"},
+	"ME": {"Montenegro", "EU"},
+	"MF": {"Saint Martin", "NA"},
+	"MG": {"Madagascar", "AF"},
+	"MH": {"Marshall Islands", "OC"},
+	"MK": {"North Macedonia", "EU"},
+	"ML": {"Mali", "AF"},
+	"MM": {"Myanmar", "AS"},
+	"MN": {"Mongolia", "AS"},
+	"MO": {"Macao", "AS"},
+	"MP": {"Northern Mariana Islands", "OC"},
+	"MQ": {"Martinique", "NA"},
+	"MR": {"Mauritania", "AF"},
+	"MS": {"Montserrat", "NA"},
+	"MT": {"Malta", "EU"},
+	"MU": {"Mauritius", "AF"},
+	"MV": {"Maldives", "AS"},
+	"MW": {"Malawi", "AF"},
+	"MX": {"Mexico", "NA"},
+	"MY": {"Malaysia", "AS"},
+	"MZ": {"Mozambique", "AF"},
+	"NA": {"Namibia", "AF"},
+	"NC": {"New Caledonia", "OC"},
+	"NE": {"Niger", "AF"},
+	"NF": {"Norfolk Island", "OC"},
+	"NG": {"Nigeria", "AF"},
+	"NI": {"Nicaragua", "NA"},
+	"NL": {"Netherlands", "EU"},
+	"NO": {"Norway", "EU"},
+	"NP": {"Nepal", "AS"},
+	"NR": {"Nauru", "OC"},
+	"NU": {"Niue", "OC"},
+	"NZ": {"New Zealand", "OC"},
+	"OM": {"Oman", "AS"},
+	"PA": {"Panama", "NA"},
+	"PE": {"Peru", "SA"},
+	"PF": {"French Polynesia", "OC"},
+	"PG": {"Papua New Guinea", "OC"},
+	"PH": {"Philippines", "AS"},
+	"PK": {"Pakistan", "AS"},
+	"PL": {"Poland", "EU"},
+	"PM": {"Saint Pierre and Miquelon", "NA"},
+	"PN": {"Pitcairn Islands", "OC"},
+	"PR": {"Puerto Rico", "NA"},
+	"PS": {"Palestine", "AS"},
+	"PT": {"Portugal", "EU"},
+	"PW": {"Palau", "OC"},
+	"PY": {"Paraguay", "SA"},
+	"QA": {"Qatar", "AS"},
+	"RE": {"Réunion", "AF"},
+	"RO": {"Romania", "EU"},
+	"RS": {"Serbia", "EU"},
+	"RU": {"Russia", "EU"},
+	"RW": {"Rwanda", "AF"},
+	"SA": {"Saudi Arabia", "AS"},
+	"SB": {"Solomon Islands", "OC"},
+	"SC": {"Seychelles", "AF"},
+	"SD": {"Sudan", "AF"},
+	"SE": {"Sweden", "EU"},
+	"SG": {"Singapore", "AS"},
+	"SH": {"Saint Helena", "AF"},
+	"SI": {"Slovenia", "EU"},
+	"SJ": {"Svalbard and Jan Mayen", "EU"},
+	"SK": {"Slovakia", "EU"},
+	"SL": {"Sierra Leone", "AF"},
+	"SM": {"San Marino", "EU"},
+	"SN": {"Senegal", "AF"},
+	"SO": {"Somalia", "AF"},
+	"SR": {"Suriname", "SA"},
+	"SS": {"South Sudan", "AF"},
+	"ST": {"São Tomé and Príncipe", "AF"},
+	"SV": {"El Salvador", "NA"},
+	"SX": {"Sint Maarten", "NA"},
+	"SY": {"Syria", "AS"},
+	"SZ": {"Eswatini", "AF"},
+	"TC": {"Turks and Caicos Islands", "NA"},
+	"TD": {"Chad", "AF"},
+	"TF": {"French Southern Territories", "AN"},
+	"TG": {"Togo", "AF"},
+	"TH": {"Thailand", "AS"},
+	"TJ": {"Tajikistan", "AS"},
+	"TK": {"Tokelau", "OC"},
+	"TL": {"Timor-Leste", "AS"},
+	"TM": {"Turkmenistan", "AS"},
+	"TN": {"Tunisia", "AF"},
+	"TO": {"Tonga", "OC"},
+	"TR": {"Türkiye", "EU"},
+	"TT": {"Trinidad and Tobago", "SA"},
+	"TV": {"Tuvalu", "OC"},
+	"TW": {"Taiwan", "AS"},
+	"TZ": {"Tanzania", "AF"},
+	"UA": {"Ukraine", "EU"},
+	"UG": {"Uganda", "AF"},
+	"UM": {"U.S. Minor Outlying Islands", "OC"},
+	"US": {"United States", "NA"},
+	"UY": {"Uruguay", "SA"},
+	"UZ": {"Uzbekistan", "AS"},
+	"VA": {"Vatican City", "EU"},
+	"VC": {"Saint Vincent and the Grenadines", "NA"},
+	"VE": {"Venezuela", "SA"},
+	"VG": {"British Virgin Islands", "NA"},
+	"VI": {"U.S. Virgin Islands", "NA"},
+	"VN": {"Vietnam", "AS"},
+	"VU": {"Vanuatu", "OC"},
+	"WF": {"Wallis and Futuna", "OC"},
+	"WS": {"Samoa", "OC"},
+	"YE": {"Yemen", "AS"},
+	"YT": {"Mayotte", "AF"},
+	"ZA": {"South Africa", "AF"},
+	"ZM": {"Zambia", "AF"},
+	"ZW": {"Zimbabwe", "AF"},
+}
+
+// countryAliases maps names used by GeoIP providers that differ from the
+// table, lower-cased, to country codes
+var countryAliases = map[string]string{
+	"bosnia & herzegovina":             "BA",
+	"britain":                          "GB",
+	"brunei darussalam":                "BN",
+	"burma":                            "MM",
+	"cape verde":                       "CV",
+	"congo":                            "CG",
+	"côte d'ivoire":                    "CI",
+	"cote d'ivoire":                    "CI",
+	"czech republic":                   "CZ",
+	"democratic republic of the congo": "CD",
+	"great britain":                    "GB",
+	"hong kong sar":                    "HK",
+	"iran, islamic republic of":        "IR",
+	"korea, republic of":               "KR",
+	"republic of korea":                "KR",
+	"korea":                            "KR",
+	"lao people's democratic republic": "LA",
+	"macau":                            "MO",
+	"moldova, republic of":             "MD",
+	"macedonia":                        "MK",
+	"palestinian territory":            "PS",
+	"russian federation":               "RU",
+	"swaziland":                        "SZ",
+	"syrian arab republic":             "SY",
+	"the netherlands":                  "NL",
+	"turkey":                           "TR",
+	"uk":                               "GB",
+	"united states of america":         "US",
+	"usa":                              "US",
+	"viet nam":                         "VN",
+}
+
+// byName maps lower-cased country names to codes
+var byName = func() map[string]string {
+	names := make(map[string]string, len(countries)+len(countryAliases))
+	for code, c := range countries {
+		names[strings.ToLower(c.Name)] = code
+	}
+	for alias, code := range countryAliases {
+		names[alias] = code
+	}
+	return names
+}()
+
+// Lookup returns the country of an ISO 3166-1 alpha-2 code
+func Lookup(code string) (Country, bool) {
+	c, ok := countries[strings.ToUpper(strings.TrimSpace(code))]
+	return c, ok
+}
+
+// Code returns the ISO 3166-1 alpha-2 code of a country code or name,
+// including the spellings of GeoIP providers, or "" if it is unknown
+func Code(country string) string {
+	code := strings.ToUpper(strings.TrimSpace(country))
+	if _, ok := countries[code]; ok {
+		return code
+	}
+	return byName[strings.ToLower(strings.TrimSpace(country))]
+}
@@ -0,0 +1,313 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/store" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// Acknowledgment limits
+const (
+	maxAckNote          = 500
+	slackResponseWindow = 10 * time.Second
+)
+
+// ackPage asks for confirmation before a signed link acknowledges an event,
+// so link previews and mail scanners opening it don't
+var ackPage = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Acknowledge event {{.ID}}</title></head>
+<body style="font-family: Arial, sans-serif; margin: 20px;">
+{{if .Ack}}<p>✅ Event {{.ID}} was acknowledged{{with .Ack.By}} by {{.}}{{end}} at {{.Ack.Time.Format "2006-01-02 15:04:05 MST"}}.</p>
+{{else}}<h2>{{.Event.Action}} of {{.Event.IP}} in {{.Event.Jail}}</h2>
+<p>{{.Event.Time.Format "2006-01-02 15:04:05 MST"}}{{with .Event.Hostname}} on {{.}}{{end}}</p>
+<form method="post">
+<p><label>Name <input name="by" maxlength="100"></label></p>
+<p><label>Note <input name="note" maxlength="500" size="60"></label></p>
+<p><button type="submit">Acknowledge</button></p>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// ackRequest is the body of POST /ack
+type ackRequest struct {
+	Event string `json:"event"`
+	By    string `json:"by,omitempty"`
+	Note  string `json:"note,omitempty"`
+}
+
+// acknowledge records an acknowledgment and logs who made it
+func (d *Daemon) acknowledge(id, by, source, note string) (*store.Ack, error) {
+	if len(note) > maxAckNote {
+		note = note[:maxAckNote]
+	}
+	ack, err := store.New(d.config.Store).Acknowledge(id, by, source, note, time.Now())
+	if err == nil {
+		d.logger.Printf("Event %s acknowledged by %s via %s", ack.EventID, ackSender(ack.By), source)
+	}
+	return ack, err
+}
+
+// ackSender names who acknowledged an event in the log
+func ackSender(by string) string {
+	if by == "" {
+		return "unknown"
+	}
+	return by
+}
+
+// handleAck acknowledges an event for API clients holding an operator token
+func (d *Daemon) handleAck(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ackRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Event == "" {
+		http.Error(w, "expected {\"event\": \"<event-id>\"}", http.StatusBadRequest)
+		return
+	}
+
+	ack, err := d.acknowledge(req.Event, req.By, store.AckSourceAPI, req.Note)
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, store.ErrAlreadyAcknowledged):
+		status = http.StatusConflict
+	case errors.Is(err, store.ErrEventNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ack)
+}
+
+// handleAckLink serves the signed links of notifications: GET shows the
+// event with a confirmation form, POST acknowledges it
+func (d *Daemon) handleAckLink(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/ack/")
+	if !d.config.Ack.ValidSignature(id, r.URL.Query().Get("sig")) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	st := store.New(d.config.Store)
+	event, err := st.Event(id)
+	if err != nil || event.ID() != id {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+
+	page := struct {
+		ID    string
+		Event *types.NotificationData
+		Ack   *store.Ack
+	}{ID: id, Event: event}
+
+	switch r.Method {
+	case http.MethodGet:
+		acks, err := st.Acks()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ack, found := acks[id]; found {
+			page.Ack = &ack
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxChatRequest)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		page.Ack, err = d.acknowledge(id, strings.TrimSpace(r.PostForm.Get("by")), store.AckSourceLink, r.PostForm.Get("note"))
+		if err != nil && !errors.Is(err, store.ErrAlreadyAcknowledged) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = ackPage.Execute(w, page)
+}
+
+// handlePagerDutyAck records PagerDuty v3 webhook incident.acknowledged
+// events. The incident key must be the event ID, i.e. the dedup_key sent to
+// the Events API was F2B_EVENT_ID.
+func (d *Daemon) handlePagerDutyAck(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !validPagerDutySignature(d.config.Ack.PagerDutySecret, r.Header.Get("X-PagerDuty-Signature"), body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var webhook struct {
+		Event struct {
+			EventType string `json:"event_type"`
+			Agent     struct {
+				Summary string `json:"summary"`
+			} `json:"agent"`
+			Data struct {
+				IncidentKey string `json:"incident_key"`
+			} `json:"data"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// PagerDuty retries failed deliveries, so events that aren't about our
+	// incidents are accepted and ignored
+	event := webhook.Event
+	if event.EventType == "incident.acknowledged" && event.Data.IncidentKey != "" {
+		_, err := d.acknowledge(event.Data.IncidentKey, event.Agent.Summary, store.AckSourcePagerDuty, "")
+		if err != nil && !errors.Is(err, store.ErrAlreadyAcknowledged) && !errors.Is(err, store.ErrEventNotFound) {
+			d.logger.Printf("Failed to record PagerDuty acknowledgment of %s: %v", event.Data.IncidentKey, err)
+			http.Error(w, "failed to record acknowledgment", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validPagerDutySignature checks the v1 signatures of a PagerDuty webhook.
+// The header lists one per active secret, e.g. "v1=abc,v1=def".
+func validPagerDutySignature(secret, header string, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "v1=" + hex.EncodeToString(mac.Sum(nil))
+	for _, signature := range strings.Split(header, ",") {
+		if hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSlackActions serves the interactions of Slack messages: the
+// Acknowledge button of ban notifications. Requests are signed like slash
+// commands.
+func (d *Daemon) handleSlackActions(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if !validSlackSignature(d.config.ChatOps.SlackSigningSecret, timestamp, r.Header.Get("X-Slack-Signature"), body, time.Now()) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Legacy attachment buttons have a name, Block Kit buttons an action_id
+	var payload struct {
+		User struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Actions []struct {
+			Name     string `json:"name"`
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+		ResponseURL string `json:"response_url"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	user := payload.User.Username
+	if user == "" {
+		user = payload.User.Name
+	}
+	sender := chatUser{id: payload.User.ID, name: user}
+
+	for _, action := range payload.Actions {
+		if (action.Name != "ack" && action.ActionID != "f2b_ack") || action.Value == "" {
+			continue
+		}
+
+		var reply string
+		switch {
+		case len(d.config.ChatOps.Operators) > 0 && !d.isChatOperator(sender):
+			d.logger.Printf("Refused Slack acknowledgment of %s by %s: not an operator", action.Value, sender)
+			reply = "You are not allowed to acknowledge events."
+		default:
+			ack, err := d.acknowledge(action.Value, user, store.AckSourceSlack, "")
+			switch {
+			case errors.Is(err, store.ErrAlreadyAcknowledged):
+				reply = "Already acknowledged by " + ackSender(ack.By) + "."
+			case err != nil:
+				d.logger.Printf("Failed to record Slack acknowledgment of %s: %v", action.Value, err)
+				reply = "Failed to acknowledge: " + err.Error()
+			default:
+				reply = "✅ Acknowledged by <@" + payload.User.ID + ">."
+			}
+		}
+		go d.slackRespond(payload.ResponseURL, reply)
+	}
+
+	// Slack expects an answer within 3 seconds; the reply follows through
+	// the response URL
+	w.WriteHeader(http.StatusOK)
+}
+
+// slackRespond posts a reply to the response URL of a Slack interaction
+func (d *Daemon) slackRespond(responseURL, text string) {
+	u, err := url.Parse(responseURL)
+	if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".slack.com") {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), slackResponseWindow)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]interface{}{"response_type": "in_channel", "replace_original": false, "text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		d.logger.Printf("Failed to reply to Slack: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+}
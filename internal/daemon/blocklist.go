@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/blocklist" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+)
+
+// handleBlocklist serves the top repeat offenders from the event store as a
+// blocklist, so firewalls, proxies and routers can subscribe to it. The query
+// parameters format, top, min_bans, days and list work like the flags of the
+// blocklist command.
+func (d *Daemon) handleBlocklist(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = blocklist.FormatPlain
+	}
+	if !blocklist.ValidFormat(format) {
+		http.Error(w, fmt.Sprintf("unknown format '%s'", format), http.StatusBadRequest)
+		return
+	}
+
+	top, err := queryInt(query, "top", store.DefaultOffenderLimit, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minBans, err := queryInt(query, "min_bans", store.DefaultOffenderMinBans, 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := queryInt(query, "days", store.DefaultOffenderDays, 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offenders, err := store.New(d.config.Store).TopOffenders(store.OffenderQuery{
+		Since:   time.Now().AddDate(0, 0, -days),
+		MinBans: minBans,
+		Limit:   top,
+	})
+	if err != nil {
+		d.logger.Printf("Failed to read event store for blocklist: %v", err)
+		http.Error(w, "failed to read event store", http.StatusInternalServerError)
+		return
+	}
+
+	ips := make([]string, len(offenders))
+	for i := range offenders {
+		ips[i] = offenders[i].IP
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(blocklist.Render(format, "top offenders", query.Get("list"), ips))
+}
+
+// queryInt returns an integer query parameter of at least minimum, or def
+// when it is missing
+func queryInt(query url.Values, name string, def, minimum int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minimum {
+		return 0, fmt.Errorf("%s must be an integer of at least %d", name, minimum)
+	}
+	return n, nil
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// unbanBurst is the unbans of a jail held during its window
+type unbanBurst struct {
+	jail   string
+	until  time.Time
+	events []*pipeline.Event
+}
+
+// unbanCoalescer holds the unbans of each jail for a short window, telling a
+// flush of the jail, which unbans many IPs at once, from unbans trickling in
+// as bans expire. Only the process loop uses it.
+type unbanCoalescer struct {
+	window    time.Duration
+	threshold int
+	bursts    map[string]*unbanBurst
+}
+
+func newUnbanCoalescer(cfg *config.BulkUnbanConfig) *unbanCoalescer {
+	return &unbanCoalescer{
+		window:    time.Duration(cfg.Window) * time.Second,
+		threshold: cfg.Threshold,
+		bursts:    make(map[string]*unbanBurst),
+	}
+}
+
+// hold adds the unban to its jail's burst, opening a window at now if the
+// jail has none
+func (c *unbanCoalescer) hold(ev *pipeline.Event, now time.Time) {
+	burst, ok := c.bursts[ev.Jail]
+	if !ok {
+		burst = &unbanBurst{jail: ev.Jail, until: now.Add(c.window)}
+		c.bursts[ev.Jail] = burst
+	}
+	burst.events = append(burst.events, ev)
+}
+
+// due removes and returns the bursts whose window is over at now, in jail
+// order
+func (c *unbanCoalescer) due(now time.Time) []*unbanBurst {
+	var due []*unbanBurst
+	for jail, burst := range c.bursts {
+		if !now.Before(burst.until) {
+			due = append(due, burst)
+			delete(c.bursts, jail)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].jail < due[j].jail })
+	return due
+}
+
+// holdUnban reports whether ev is an unban held back to detect a flush of
+// its jail
+func (d *Daemon) holdUnban(ev *pipeline.Event) bool {
+	if d.unbans == nil || ev.Action != types.ActionUnban {
+		return false
+	}
+	now := time.Now()
+	if ev.Time.IsZero() {
+		ev.Time = now // When it was unbanned, not when it is released
+	}
+	d.unbans.hold(ev, now)
+	return true
+}
+
+// releaseUnbans delivers the unbans whose window is over at now. The
+// unbans of a burst reach only the connectors that don't coalesce unbans,
+// and the others receive one summary of the burst instead.
+func (d *Daemon) releaseUnbans(now time.Time) {
+	for _, burst := range d.unbans.due(now) {
+		bulk := len(burst.events) >= d.unbans.threshold
+		for _, ev := range burst.events {
+			ev.Coalesced = bulk
+			d.deliver(ev)
+		}
+		if !bulk {
+			continue
+		}
+
+		summary := &types.BulkUnban{Unbans: len(burst.events), Window: int(d.unbans.window / time.Second)}
+		if _, err := d.pipeline.DeliverBulkUnban(burst.jail, summary, now); err != nil {
+			d.logger.Printf("Delivering the bulk unban of %s completed with errors: %v", burst.jail, err)
+		}
+	}
+}
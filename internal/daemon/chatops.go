@@ -0,0 +1,354 @@
+package daemon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/f2bclient" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+)
+
+// Chat command limits
+const (
+	maxChatRequest    = 64 * 1024
+	slackMaxClockSkew = 5 * time.Minute
+	maxListedBans     = 50
+)
+
+// chatUsage lists the supported chat commands
+const chatUsage = "Usage: `/f2b status`, `/f2b banned <jail>`, `/f2b unban <ip> [jail]`"
+
+var (
+	jailName    = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+	teamsMarkup = regexp.MustCompile(`<at>[^<]*</at>|<[^>]+>`)
+)
+
+// chatCommand is a verified command from a chat platform
+type chatCommand struct {
+	text string   // Command text after /f2b, e.g. "banned sshd"
+	user chatUser // Sender of the command
+}
+
+// chatUser identifies the sender of a chat command. Only the ID is checked
+// against the operators, as users can change their display names.
+type chatUser struct {
+	id   string // Slack user ID or Teams AAD object ID
+	name string // Display name, for the log
+}
+
+// handleSlack serves Slack slash commands. Requests are signed with the
+// app's signing secret over the timestamp and raw body.
+func (d *Daemon) handleSlack(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if !validSlackSignature(d.config.ChatOps.SlackSigningSecret, timestamp, r.Header.Get("X-Slack-Signature"), body, time.Now()) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reply := d.runChatCommand(r.Context(), "slack", chatCommand{
+		text: form.Get("text"),
+		user: chatUser{id: form.Get("user_id"), name: form.Get("user_name")},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": reply})
+}
+
+// handleTeams serves Microsoft Teams outgoing webhooks. Requests carry an
+// HMAC of the body keyed with the webhook's security token.
+func (d *Daemon) handleTeams(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !validTeamsSignature(d.config.ChatOps.TeamsSecurityToken, r.Header.Get("Authorization"), body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var activity struct {
+		Text string `json:"text"`
+		From struct {
+			Name        string `json:"name"`
+			AADObjectID string `json:"aadObjectId"`
+		} `json:"from"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The message starts with the mention of the webhook, e.g. "<at>f2b</at> status"
+	text := strings.ReplaceAll(teamsMarkup.ReplaceAllString(activity.Text, " "), "&nbsp;", " ")
+	reply := d.runChatCommand(r.Context(), "teams", chatCommand{
+		text: text,
+		user: chatUser{id: activity.From.AADObjectID, name: activity.From.Name},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"type": "message", "text": strings.ReplaceAll(reply, "\n", "<br>")})
+}
+
+// readChatRequest reads the body of a POST from a chat platform
+func readChatRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatRequest))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// validSlackSignature checks the v0 signature of a Slack request and rejects
+// replays outside the allowed clock skew
+func validSlackSignature(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > slackMaxClockSkew || skew < -slackMaxClockSkew {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// validTeamsSignature checks the "HMAC <base64>" authorization of a Teams
+// outgoing webhook request
+func validTeamsSignature(token, authorization string, body []byte) bool {
+	key, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return false
+	}
+	given, ok := strings.CutPrefix(authorization, "HMAC ")
+	if !ok {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.TrimSpace(given)))
+}
+
+// runChatCommand executes a command and returns the reply text
+func (d *Daemon) runChatCommand(ctx context.Context, platform string, cmd chatCommand) string {
+	args := strings.Fields(cmd.text)
+	if len(args) == 0 {
+		return chatUsage
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "status":
+		return d.chatStatus(ctx)
+
+	case "banned":
+		if len(args) != 2 || !jailName.MatchString(args[1]) {
+			return "Usage: `/f2b banned <jail>`"
+		}
+		return d.chatBanned(ctx, args[1])
+
+	case "unban":
+		if len(args) < 2 || len(args) > 3 || net.ParseIP(args[1]) == nil {
+			return "Usage: `/f2b unban <ip> [jail]`"
+		}
+		if !d.isChatOperator(cmd.user) {
+			d.logger.Printf("Refused %s unban of %s by %s: not an operator", platform, args[1], cmd.user)
+			return "You are not allowed to unban IPs."
+		}
+
+		jail := ""
+		if len(args) == 3 {
+			if !jailName.MatchString(args[2]) {
+				return "Usage: `/f2b unban <ip> [jail]`"
+			}
+			jail = args[2]
+		}
+		return d.chatUnban(ctx, platform, cmd.user, args[1], jail)
+
+	default:
+		return chatUsage
+	}
+}
+
+// chatStatus summarizes the daemon and the active bans per jail
+func (d *Daemon) chatStatus(ctx context.Context) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "fail2ban-notify up %s, %d events queued\n",
+		time.Since(d.started).Truncate(time.Second), len(d.events))
+
+	counts, err := d.activeBanCounts(ctx)
+	if err != nil {
+		d.logger.Printf("Failed to get active bans for chat status: %v", err)
+		b.WriteString("Active bans unavailable: " + err.Error())
+		return b.String()
+	}
+
+	jails := make([]string, 0, len(counts))
+	total := 0
+	for jail, n := range counts {
+		jails = append(jails, jail)
+		total += n
+	}
+	sort.Strings(jails)
+
+	fmt.Fprintf(&b, "Active bans: %d", total)
+	for _, jail := range jails {
+		fmt.Fprintf(&b, "\n• %s: %d", jail, counts[jail])
+	}
+	return b.String()
+}
+
+// activeBanCounts counts active bans per jail from the event store, or asks
+// fail2ban when the store is disabled
+func (d *Daemon) activeBanCounts(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	if d.config.Store.Enabled {
+		bans, err := store.New(d.config.Store).ActiveBans(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		for _, ban := range bans {
+			counts[ban.Jail]++
+		}
+		return counts, nil
+	}
+
+	jails, err := d.fail2ban().Jails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, jail := range jails {
+		jailStatus, err := d.fail2ban().Status(ctx, jail)
+		if err != nil {
+			return nil, err
+		}
+		counts[jail], _ = strconv.Atoi(jailStatus["Currently banned"])
+	}
+	return counts, nil
+}
+
+// chatBanned lists the active bans of a jail
+func (d *Daemon) chatBanned(ctx context.Context, jail string) string {
+	var ips []string
+	if d.config.Store.Enabled {
+		bans, err := store.New(d.config.Store).ActiveBans(time.Now())
+		if err != nil {
+			d.logger.Printf("Failed to get active bans for chat: %v", err)
+			return "Active bans unavailable: " + err.Error()
+		}
+		sort.Slice(bans, func(i, j int) bool { return bans[i].Since.After(bans[j].Since) })
+		for _, ban := range bans {
+			if ban.Jail != jail {
+				continue
+			}
+			line := ban.IP
+			if ban.Country != "" {
+				line += " (" + ban.Country + ")"
+			}
+			ips = append(ips, line+" since "+ban.Since.UTC().Format(time.RFC3339))
+		}
+	} else {
+		banned, err := d.fail2ban().Banned(ctx, jail)
+		if err != nil {
+			return "Active bans unavailable: " + err.Error()
+		}
+		ips = banned
+	}
+
+	if len(ips) == 0 {
+		return fmt.Sprintf("No active bans in %s.", jail)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d active bans in %s:", len(ips), jail)
+	for i, ip := range ips {
+		if i == maxListedBans {
+			fmt.Fprintf(&b, "\n… and %d more", len(ips)-maxListedBans)
+			break
+		}
+		b.WriteString("\n• " + ip)
+	}
+	return b.String()
+}
+
+// chatUnban unbans an IP from one jail, or from all jails
+func (d *Daemon) chatUnban(ctx context.Context, platform string, user chatUser, ip, jail string) string {
+	args := []string{"unban", ip}
+	where := "all jails"
+	if jail != "" {
+		args = []string{"set", jail, "unbanip", ip}
+		where = jail
+	}
+
+	if _, err := d.fail2ban().Run(ctx, args...); err != nil {
+		d.logger.Printf("Failed %s unban of %s from %s by %s: %v", platform, ip, where, user, err)
+		return fmt.Sprintf("Failed to unban %s: %v", ip, err)
+	}
+
+	d.logger.Printf("Unbanned %s from %s via %s by %s", ip, where, platform, user)
+	return fmt.Sprintf("Unbanned %s from %s.", ip, where)
+}
+
+// isChatOperator reports whether the sender's ID is one of the operators
+func (d *Daemon) isChatOperator(user chatUser) bool {
+	if user.id == "" {
+		return false
+	}
+	for _, operator := range d.config.ChatOps.Operators {
+		if user.id == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the sender for the log
+func (u chatUser) String() string {
+	if u.name == "" || u.name == u.id {
+		return u.id
+	}
+	return u.name + "/" + u.id
+}
+
+// fail2ban returns the client used to query and unban
+func (d *Daemon) fail2ban() *f2bclient.Client {
+	return f2bclient.New(d.config.ChatOps.Fail2banClient)
+}
@@ -0,0 +1,383 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/bansync"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/f2blog"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/heatmap"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/rbl"        //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/tail"       //nolint:depguard
+)
+
+// Background intervals
+const (
+	housekeepingInterval = time.Minute      // How often time-based state is refreshed
+	batchFlushInterval   = 5 * time.Second  // How often queued connector batches are delivered
+	shutdownFlushTimeout = 10 * time.Second // Time allowed for the final batch flush
+	banSyncInterval      = 10 * time.Minute // How often active bans are reconciled with fail2ban
+	rollupInterval       = 10 * time.Minute // How often new events are counted into the store's rollups
+)
+
+// Daemon receives events from its sources and runs them through the
+// pipeline one at a time
+type Daemon struct {
+	config   *config.Config
+	logger   *log.Logger
+	pipeline *pipeline.Pipeline
+	events   chan *pipeline.Event
+	grace    *restartGrace   // nil unless restored bans are summarized
+	unbans   *unbanCoalescer // nil unless bulk unbans are coalesced
+	started  time.Time
+}
+
+// New creates a daemon for the given configuration
+func New(cfg *config.Config, logger *log.Logger) *Daemon {
+	if logger == nil {
+		logger = log.New(os.Stderr, "[daemon] ", log.LstdFlags)
+	}
+
+	// Events keep arriving, so connectors may deliver them in batches
+	connectors.EnableBatching()
+
+	d := &Daemon{
+		config:   cfg,
+		logger:   logger,
+		pipeline: pipeline.New(cfg, logger),
+		events:   make(chan *pipeline.Event, cfg.Daemon.QueueSize),
+	}
+	if cfg.Daemon.RestartGrace > 0 {
+		d.grace = newRestartGrace(cfg.Daemon.RestartGrace)
+	}
+	if cfg.BulkUnban.Enabled {
+		d.unbans = newUnbanCoalescer(&cfg.BulkUnban)
+	}
+	return d
+}
+
+// Run starts all configured event sources and processes events until ctx
+// is canceled
+func (d *Daemon) Run(ctx context.Context) error {
+	d.started = time.Now()
+
+	sources := d.sources()
+	if len(sources) == 0 {
+		return fmt.Errorf("no event sources configured (set daemon.tail_log or daemon.socket)")
+	}
+	sources = append(sources, d.services()...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(sources))
+	for _, source := range sources {
+		wg.Add(1)
+		go func(run func(context.Context) error) {
+			defer wg.Done()
+			if err := run(ctx); err != nil {
+				errChan <- err
+				cancel()
+			}
+		}(source)
+	}
+
+	d.logger.Printf("Daemon started")
+	d.process(ctx)
+
+	wg.Wait()
+	close(errChan)
+	for err := range errChan {
+		return err
+	}
+
+	d.logger.Printf("Daemon stopped")
+	return nil
+}
+
+// sources returns the event sources enabled in the configuration
+func (d *Daemon) sources() []func(context.Context) error {
+	var sources []func(context.Context) error
+	if d.config.Daemon.TailLog != "" {
+		sources = append(sources, d.tailLog)
+	}
+	if d.config.Daemon.Socket != "" {
+		sources = append(sources, d.serveSocket)
+	}
+	return sources
+}
+
+// services returns the background services enabled in the configuration
+func (d *Daemon) services() []func(context.Context) error {
+	services := []func(context.Context) error{d.housekeeping, d.flushBatches}
+	if d.config.Daemon.Listen != "" {
+		services = append(services, d.serveHTTP)
+	}
+	if d.config.Daemon.SyncBans && d.config.Store.Enabled {
+		services = append(services, d.syncBans)
+	}
+	if d.config.Store.Enabled && d.config.Store.Rollups.Enabled {
+		services = append(services, d.rollUp)
+	}
+	if d.config.Daemon.JournalUnit != "" {
+		services = append(services, d.followJournal)
+	}
+	if d.config.Daemon.ProbeInterval > 0 {
+		services = append(services, d.probeConnectors)
+	}
+	if d.config.Heatmap.Enabled {
+		services = append(services, d.renderHeatmap)
+	}
+	if d.config.SLA.Enabled() {
+		services = append(services, d.enforceSLA)
+	}
+	if d.config.RBL.Enabled && d.config.RBL.Listen != "" {
+		responder := rbl.NewResponder(&d.config.RBL, store.New(d.config.Store), d.logger)
+		services = append(services, responder.Serve)
+	}
+	return services
+}
+
+// housekeeping periodically refreshes state derived from the passage of
+// time, such as bans expiring without an unban event
+func (d *Daemon) housekeeping(ctx context.Context) error {
+	ticker := time.NewTicker(housekeepingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if d.config.RBL.Enabled {
+				if err := rbl.WriteZone(&d.config.RBL, store.New(d.config.Store), now); err != nil {
+					d.logger.Printf("Failed to refresh RBL zone: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// syncBans reconciles the store's active bans with fail2ban at start and
+// every banSyncInterval, so they stay right across restarts of either
+func (d *Daemon) syncBans(ctx context.Context) error {
+	ticker := time.NewTicker(banSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := bansync.Sync(ctx, d.config, nil, false)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Printf("Failed to sync active bans with fail2ban: %v", err)
+		} else if err == nil && (len(result.Added) > 0 || len(result.Removed) > 0) {
+			d.logger.Printf("Synced active bans with fail2ban: %d added, %d removed", len(result.Added), len(result.Removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollUp counts new events into the store's rollups at start and every
+// rollupInterval, so stats and queries read few raw events
+func (d *Daemon) rollUp(ctx context.Context) error {
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+
+	st := store.New(d.config.Store)
+	for {
+		if _, err := st.RollUp(time.Now()); err != nil {
+			d.logger.Printf("Failed to roll up stored events: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeConnectors runs the health probes of the connectors at start and
+// every probe_interval, logging connectors that become unhealthy or recover
+func (d *Daemon) probeConnectors(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(d.config.Daemon.ProbeInterval) * time.Second)
+	defer ticker.Stop()
+
+	manager := connectors.NewManager(d.config, d.logger)
+	healthy := make(map[string]bool)
+	for {
+		results, err := manager.ProbeAll(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Printf("Failed to probe connectors: %v", err)
+		}
+		for name, result := range results {
+			previous, seen := healthy[name]
+			if !result.Healthy && (!seen || previous) {
+				d.logger.Printf("Connector %s failed its health probe: %s", name, result.Error)
+			} else if result.Healthy && seen && !previous {
+				d.logger.Printf("Connector %s is healthy again", name)
+			}
+			healthy[name] = result.Healthy
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderHeatmap regenerates the ban map at start and every heatmap
+// interval
+func (d *Daemon) renderHeatmap(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(d.config.Heatmap.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, err := heatmap.Generate(d.config, time.Now()); err != nil {
+			d.logger.Printf("Failed to generate ban heatmap: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushBatches periodically delivers the rows queued by batching connectors
+// and flushes them one last time on shutdown
+func (d *Daemon) flushBatches(ctx context.Context) error {
+	ticker := time.NewTicker(batchFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+			defer cancel()
+			if err := connectors.FlushBatches(flushCtx); err != nil {
+				d.logger.Printf("Failed to flush connector batches on shutdown: %v", err)
+			}
+			return nil
+		case <-ticker.C:
+			if err := connectors.FlushBatches(ctx); err != nil {
+				d.logger.Printf("Failed to flush connector batches: %v", err)
+			}
+		}
+	}
+}
+
+// Submit queues an event for processing. It blocks while the queue is full.
+func (d *Daemon) Submit(ctx context.Context, ev *pipeline.Event) error {
+	if err := ev.Validate(); err != nil {
+		return err
+	}
+
+	select {
+	case d.events <- ev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// process runs queued events through the pipeline until ctx is canceled,
+// holding back the bans fail2ban restores after a restart for the summary
+// and unbans until a flush of their jail can be told apart
+func (d *Daemon) process(ctx context.Context) {
+	var summaries <-chan time.Time
+	if d.grace != nil || d.unbans != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		summaries = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if d.unbans != nil {
+				// Deliver the held unbans rather than dropping them
+				d.releaseUnbans(time.Now().Add(d.unbans.window))
+			}
+			return
+		case now := <-summaries:
+			if d.grace != nil {
+				d.summarizeRestart(now)
+			}
+			if d.unbans != nil {
+				d.releaseUnbans(now)
+			}
+		case ev := <-d.events:
+			if d.absorbRestored(ev) || d.holdUnban(ev) {
+				continue
+			}
+			d.deliver(ev)
+		}
+	}
+}
+
+// deliver runs an event through the pipeline
+func (d *Daemon) deliver(ev *pipeline.Event) {
+	if _, err := d.pipeline.Process(ev); err != nil {
+		d.logger.Printf("Processing %s of %s in %s completed with errors: %v", ev.Action, ev.IP, ev.Jail, err)
+	}
+}
+
+// tailLog follows the fail2ban log and submits its ban and unban lines,
+// watching for the line fail2ban logs when it starts
+func (d *Daemon) tailLog(ctx context.Context) error {
+	parser, err := f2blog.NewParser(d.config.Daemon.TailPattern)
+	if err != nil {
+		return err
+	}
+
+	lines := make(chan string)
+	errChan := make(chan error, 1)
+	go func() {
+		poll := time.Duration(d.config.Daemon.PollInterval) * time.Millisecond
+		errChan <- tail.Follow(ctx, d.config.Daemon.TailLog, poll, lines)
+		close(lines)
+	}()
+
+	d.logger.Printf("Following %s", d.config.Daemon.TailLog)
+
+	for line := range lines {
+		if f2blog.IsStart(line) {
+			d.restarted(time.Now(), "log")
+			continue
+		}
+
+		entry, ok := parser.Parse(line)
+		if !ok {
+			continue
+		}
+
+		ev := &pipeline.Event{
+			IP:       entry.IP,
+			Jail:     entry.Jail,
+			Action:   entry.Action,
+			Time:     entry.Time,
+			Restored: entry.Restore,
+		}
+		if err := d.Submit(ctx, ev); err != nil && ctx.Err() == nil {
+			d.logger.Printf("Ignoring log line %q: %v", line, err)
+		}
+	}
+
+	return <-errChan
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/blocklist" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"     //nolint:depguard
+)
+
+// handleEDL serves the active bans as an external dynamic list, the plain
+// address list Palo Alto and Fortinet firewalls poll: all jails at /edl and
+// a single jail at /edl/<jail>. The query parameter family=ipv4 or ipv6
+// serves one address family, for lists of a single type.
+func (d *Daemon) handleEDL(w http.ResponseWriter, r *http.Request) {
+	jail := ""
+	if r.URL.Path != "/edl" {
+		jail = strings.TrimPrefix(r.URL.Path, "/edl/")
+		if jail == "" || strings.Contains(jail, "/") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	family := r.URL.Query().Get("family")
+	if family != "" && family != "ipv4" && family != "ipv6" {
+		http.Error(w, "family must be ipv4 or ipv6", http.StatusBadRequest)
+		return
+	}
+
+	bans, err := store.New(d.config.Store).ActiveBans(time.Now())
+	if err != nil {
+		d.logger.Printf("Failed to read active bans for EDL: %v", err)
+		http.Error(w, "failed to read active bans", http.StatusInternalServerError)
+		return
+	}
+
+	// Pseudonymized addresses can't be blocked and would be rejected by
+	// the firewall, and an address banned in several jails is listed once
+	seen := make(map[string]bool)
+	ips := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		ip := net.ParseIP(ban.IP)
+		if ip == nil || seen[ban.IP] || (jail != "" && ban.Jail != jail) {
+			continue
+		}
+		if (family == "ipv4" && ip.To4() == nil) || (family == "ipv6" && ip.To4() != nil) {
+			continue
+		}
+		seen[ban.IP] = true
+		ips = append(ips, ban.IP)
+	}
+
+	// Bans expire without the store changing, so the ETag is derived from
+	// the list itself and lets pollers skip unchanged lists
+	body := blocklist.Render(blocklist.FormatEDL, "", "", ips)
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/store" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// feedEntries is the number of most recent bans in a feed
+const feedEntries = 50
+
+// atomFeed is an Atom (RFC 4287) feed document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Content  atomContent  `xml:"content"`
+	Link     *atomLink    `xml:"link,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// handleFeed serves the most recent bans as an Atom feed: all jails at
+// /feed.xml and a single jail at /feed/<jail>.xml
+func (d *Daemon) handleFeed(w http.ResponseWriter, r *http.Request) {
+	jail := ""
+	if r.URL.Path != "/feed.xml" {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/feed/"), ".xml")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		jail = name
+	}
+
+	// Keep the last feedEntries bans, compacting as the scan goes
+	var recent []types.NotificationData
+	err := store.New(d.config.Store).Scan(func(data *types.NotificationData) error {
+		if !data.IsBan() || (jail != "" && data.Jail != jail) {
+			return nil
+		}
+		recent = append(recent, *data)
+		if len(recent) >= 2*feedEntries {
+			recent = append(recent[:0], recent[len(recent)-feedEntries:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		d.logger.Printf("Failed to read event store for feed: %v", err)
+		http.Error(w, "failed to read event store", http.StatusInternalServerError)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	title := "fail2ban bans on " + hostname
+	if jail != "" {
+		title = fmt.Sprintf("fail2ban bans in %s on %s", jail, hostname)
+	}
+
+	self := feedURL(r)
+	feed := atomFeed{
+		ID:      self,
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: self, Rel: "self"},
+		Author:  atomAuthor{Name: "fail2ban-notify"},
+	}
+
+	if len(recent) > feedEntries {
+		recent = recent[len(recent)-feedEntries:]
+	}
+	for i := len(recent) - 1; i >= 0; i-- { // Newest first
+		feed.Entries = append(feed.Entries, feedEntry(hostname, &recent[i]))
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		d.logger.Printf("Failed to write feed: %v", err)
+	}
+}
+
+// feedEntry converts a ban into a feed entry
+func feedEntry(hostname string, data *types.NotificationData) atomEntry {
+	var content strings.Builder
+	fmt.Fprintf(&content, "IP: %s\nJail: %s\n", data.IP, data.Jail)
+	if location := data.GetLocationString(); location != "" {
+		fmt.Fprintf(&content, "Location: %s\n", location)
+	}
+	if data.ISP != "" {
+		fmt.Fprintf(&content, "ISP: %s\n", data.ISP)
+	}
+	if data.Failures > 0 {
+		fmt.Fprintf(&content, "Failures: %d\n", data.Failures)
+	}
+
+	entry := atomEntry{
+		ID:       fmt.Sprintf("urn:fail2ban-notify:%s:%s:%s:%d", hostname, data.Jail, data.IP, data.Time.UnixNano()),
+		Title:    data.String(),
+		Updated:  data.Time.UTC().Format(time.RFC3339),
+		Category: atomCategory{Term: data.Jail},
+		Content:  atomContent{Type: "text", Body: content.String()},
+	}
+	if data.ArtifactURL != "" {
+		entry.Link = &atomLink{Href: data.ArtifactURL}
+	}
+	return entry
+}
+
+// feedURL returns the absolute URL the feed was requested with
+func feedURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
@@ -0,0 +1,408 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/graphql" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// GraphQL limits
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 10000
+	maxGraphQLRequest = 64 * 1024
+)
+
+// GraphQLSchema describes the query API in schema definition language
+const GraphQLSchema = `type Query {
+  events(jail: String, ip: String, action: String, country: String, since: String, until: String, limit: Int = 100): [Event!]!
+  activeBans(jail: String): [Ban!]!
+  aggregate(by: GroupBy!, jail: String, action: String = "ban", since: String, until: String, limit: Int = 100): [Group!]!
+  connectorResults(connector: String, ip: String, jail: String, success: Boolean, since: String, until: String, limit: Int = 100): [ConnectorResult!]!
+}
+
+enum GroupBy { JAIL COUNTRY IP HOSTNAME HOUR DAY }
+
+type Event { id: String! ip: String! jail: String! action: String! time: String! country: String countryCode: String continent: String region: String city: String isp: String hostname: String failures: Int bantime: Int latitude: Float longitude: Float }
+type Ban { ip: String! jail: String! since: String! expires: String country: String failures: Int }
+type Group { key: String! count: Int! }
+type ConnectorResult { eventId: String! time: String! ip: String! jail: String! action: String! connector: String! success: Boolean! error: String output: String durationMs: Int! attempts: Int! }
+`
+
+// graphqlSchema builds the query API over the event store. since and until
+// take RFC 3339 times or ages such as 24h or 7d.
+func (d *Daemon) graphqlSchema() *graphql.Schema {
+	st := store.New(d.config.Store)
+
+	event := &graphql.Object{Name: "Event", Fields: scalarFields(
+		"id", "ip", "jail", "action", "time", "country", "countryCode", "continent", "region", "city", "isp", "hostname", "failures", "bantime", "latitude", "longitude")}
+	ban := &graphql.Object{Name: "Ban", Fields: scalarFields("ip", "jail", "since", "expires", "country", "failures")}
+	group := &graphql.Object{Name: "Group", Fields: scalarFields("key", "count")}
+	result := &graphql.Object{Name: "ConnectorResult", Fields: scalarFields(
+		"eventId", "time", "ip", "jail", "action", "connector", "success", "error", "output", "durationMs", "attempts")}
+
+	str := graphql.Arg{Type: graphql.String}
+	limit := graphql.Arg{Type: graphql.Int, Default: defaultQueryLimit}
+
+	return &graphql.Schema{Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"events": {
+			Type: event,
+			Args: map[string]graphql.Arg{"jail": str, "ip": str, "action": str, "country": str, "since": str, "until": str, "limit": limit},
+			Resolve: func(args map[string]interface{}) (interface{}, error) {
+				filter, err := newEventFilter(args)
+				if err != nil {
+					return nil, err
+				}
+				var events []map[string]interface{}
+				err = st.Scan(func(data *types.NotificationData) error {
+					if filter.match(data) {
+						events = append(events, eventObject(data))
+					}
+					return nil
+				})
+				return newestFirst(events, filter.limit), err
+			},
+		},
+		"activeBans": {
+			Type: ban,
+			Args: map[string]graphql.Arg{"jail": str},
+			Resolve: func(args map[string]interface{}) (interface{}, error) {
+				bans, err := st.ActiveBans(time.Now())
+				if err != nil {
+					return nil, err
+				}
+				objects := []map[string]interface{}{}
+				for _, b := range bans {
+					if jail, ok := args["jail"]; ok && b.Jail != jail {
+						continue
+					}
+					objects = append(objects, map[string]interface{}{
+						"ip": b.IP, "jail": b.Jail, "since": formatTime(b.Since), "expires": formatTime(b.Expires),
+						"country": b.Country, "failures": b.Failures,
+					})
+				}
+				return objects, nil
+			},
+		},
+		"aggregate": {
+			Type: group,
+			Args: map[string]graphql.Arg{
+				"by":     {Enum: []string{"JAIL", "COUNTRY", "IP", "HOSTNAME", "HOUR", "DAY"}},
+				"jail":   str,
+				"action": {Type: graphql.String, Default: types.ActionBan},
+				"since":  str,
+				"until":  str,
+				"limit":  limit,
+			},
+			Resolve: func(args map[string]interface{}) (interface{}, error) {
+				by, ok := args["by"].(string)
+				if !ok {
+					return nil, fmt.Errorf("argument \"by\" is required")
+				}
+				filter, err := newEventFilter(args)
+				if err != nil {
+					return nil, err
+				}
+				if d.config.Store.Rollups.Enabled && rollupGroupable(by, filter, st.HourlyFrom(time.Now())) {
+					counts, err := rollupCounts(st, by, filter)
+					return groups(counts, by, filter.limit), err
+				}
+				counts := make(map[string]int)
+				err = st.Scan(func(data *types.NotificationData) error {
+					if filter.match(data) {
+						counts[groupKey(by, data)]++
+					}
+					return nil
+				})
+				return groups(counts, by, filter.limit), err
+			},
+		},
+		"connectorResults": {
+			Type: result,
+			Args: map[string]graphql.Arg{
+				"connector": str, "ip": str, "jail": str, "success": {Type: graphql.Boolean},
+				"since": str, "until": str, "limit": limit,
+			},
+			Resolve: func(args map[string]interface{}) (interface{}, error) {
+				filter, err := newEventFilter(args)
+				if err != nil {
+					return nil, err
+				}
+				var results []map[string]interface{}
+				err = st.ScanResults(func(r *store.Result) error {
+					if !filter.matchTime(r.Time) || (filter.ip != "" && r.IP != filter.ip) || (filter.jail != "" && r.Jail != filter.jail) {
+						return nil
+					}
+					if connector, ok := args["connector"]; ok && r.ConnectorName != connector {
+						return nil
+					}
+					if success, ok := args["success"]; ok && r.Success != success {
+						return nil
+					}
+					results = append(results, map[string]interface{}{
+						"eventId": r.EventID(), "time": formatTime(r.Time), "ip": r.IP, "jail": r.Jail, "action": r.Action,
+						"connector": r.ConnectorName, "success": r.Success, "error": r.Error, "output": r.Output,
+						"durationMs": r.Duration.Milliseconds(), "attempts": r.Attempts,
+					})
+					return nil
+				})
+				return newestFirst(results, filter.limit), err
+			},
+		},
+	}}}
+}
+
+// graphqlHandler serves queries sent as GET ?query= or POSTed as JSON
+func graphqlHandler(schema *graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveGraphQL(schema, w, r)
+	}
+}
+
+func serveGraphQL(schema *graphql.Schema, w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Query == "" {
+			// Without a query, describe the API
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(w, GraphQLSchema)
+			return
+		}
+
+	case http.MethodPost:
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxGraphQLRequest)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := schema.Execute(req)
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Data == nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// eventFilter selects events by the common query arguments
+type eventFilter struct {
+	jail, ip, action, country string
+	since, until              time.Time
+	limit                     int
+}
+
+func newEventFilter(args map[string]interface{}) (*eventFilter, error) {
+	f := &eventFilter{limit: defaultQueryLimit}
+	f.jail, _ = args["jail"].(string)
+	f.ip, _ = args["ip"].(string)
+	f.action, _ = args["action"].(string)
+	f.country, _ = args["country"].(string)
+	if limit, ok := args["limit"].(int); ok {
+		f.limit = limit
+	}
+	if f.limit <= 0 || f.limit > maxQueryLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+	}
+
+	now := time.Now()
+	var err error
+	if value, ok := args["since"].(string); ok {
+		if f.since, err = parseQueryTime(value, now); err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if value, ok := args["until"].(string); ok {
+		if f.until, err = parseQueryTime(value, now); err != nil {
+			return nil, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *eventFilter) match(data *types.NotificationData) bool {
+	return f.matchTime(data.Time) &&
+		(f.jail == "" || data.Jail == f.jail) &&
+		(f.ip == "" || data.IP == f.ip) &&
+		(f.action == "" || data.Action == f.action) &&
+		(f.country == "" || strings.EqualFold(data.Country, f.country) || strings.EqualFold(data.CountryCode, f.country))
+}
+
+func (f *eventFilter) matchTime(t time.Time) bool {
+	return (f.since.IsZero() || !t.Before(f.since)) && (f.until.IsZero() || t.Before(f.until))
+}
+
+// parseQueryTime parses an RFC 3339 time or an age such as 90m, 24h or 7d
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("expected RFC 3339 time or age such as 24h or 7d: %s", value)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time or age such as 24h or 7d: %s", value)
+	}
+	return now.Add(-age), nil
+}
+
+// eventObject converts an event to a GraphQL Event
+func eventObject(data *types.NotificationData) map[string]interface{} {
+	return map[string]interface{}{
+		"id": data.ID(), "ip": data.IP, "jail": data.Jail, "action": data.Action, "time": formatTime(data.Time),
+		"country": data.Country, "countryCode": data.CountryCode, "continent": data.Continent, "region": data.Region, "city": data.City, "isp": data.ISP,
+		"hostname": data.Hostname, "failures": data.Failures, "bantime": data.BanTime,
+		"latitude": data.Latitude, "longitude": data.Longitude,
+	}
+}
+
+// groupKey returns the aggregation key of an event
+func groupKey(by string, data *types.NotificationData) string {
+	switch by {
+	case "JAIL":
+		return data.Jail
+	case "COUNTRY":
+		return data.Country
+	case "IP":
+		return data.IP
+	case "HOSTNAME":
+		return data.Hostname
+	case "HOUR":
+		return data.Time.UTC().Truncate(time.Hour).Format(time.RFC3339)
+	default:
+		return data.Time.UTC().Format("2006-01-02")
+	}
+}
+
+// rollupGroupable reports whether the aggregation can be counted from the
+// store's rollups: bans or unbans per jail, country, day or hour, the latter
+// only where hourly rollups are kept
+func rollupGroupable(by string, filter *eventFilter, hourlyFrom time.Time) bool {
+	if filter.action != types.ActionBan && filter.action != types.ActionUnban {
+		return false
+	}
+	switch by {
+	case "JAIL", "COUNTRY", "DAY":
+		return true
+	case "HOUR":
+		return !filter.since.IsZero() && !filter.since.Before(hourlyFrom)
+	}
+	return false
+}
+
+// rollupCounts aggregates the store's rollups like groupKey does events
+func rollupCounts(st *store.Store, by string, filter *eventFilter) (map[string]int, error) {
+	rollups, err := st.Rollups(filter.since, filter.until, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, r := range rollups {
+		if filter.jail != "" && r.Jail != filter.jail {
+			continue
+		}
+		n := r.Bans
+		if filter.action == types.ActionUnban {
+			n = r.Unbans
+		}
+		if n == 0 {
+			continue
+		}
+		switch by {
+		case "JAIL":
+			counts[r.Jail] += n
+		case "COUNTRY":
+			counts[r.Country] += n
+		case "HOUR":
+			counts[r.Start.Format(time.RFC3339)] += n
+		default:
+			counts[r.Start.Format("2006-01-02")] += n
+		}
+	}
+	return counts, nil
+}
+
+// groups sorts the counts: time buckets chronologically, everything else by
+// descending count, and keeps the first limit groups
+func groups(counts map[string]int, by string, limit int) []map[string]interface{} {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	if by == "HOUR" || by == "DAY" {
+		sort.Strings(keys)
+	} else {
+		sort.Slice(keys, func(i, j int) bool {
+			if counts[keys[i]] != counts[keys[j]] {
+				return counts[keys[i]] > counts[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+	}
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	result := make([]map[string]interface{}, len(keys))
+	for i, key := range keys {
+		result[i] = map[string]interface{}{"key": key, "count": counts[key]}
+	}
+	return result
+}
+
+// newestFirst reverses items stored oldest first and keeps the first limit
+func newestFirst(items []map[string]interface{}, limit int) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, limit)
+	for i := len(items) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, items[i])
+	}
+	return result
+}
+
+// scalarFields declares scalar fields read from the object maps
+func scalarFields(names ...string) map[string]*graphql.Field {
+	fields := make(map[string]*graphql.Field, len(names))
+	for _, name := range names {
+		fields[name] = &graphql.Field{}
+	}
+	return fields
+}
+
+// formatTime formats t as RFC 3339, or returns nil for the zero time
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/auth"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/heatmap" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pki"     //nolint:depguard
+)
+
+// shutdownTimeout bounds how long in-flight HTTP requests may take on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Health is the response of the /healthz endpoint
+type Health struct {
+	Status     string `json:"status"`
+	Uptime     int64  `json:"uptime_seconds"`
+	QueueDepth int    `json:"queue_depth"`
+}
+
+// handler builds the HTTP routes served by the daemon
+func (d *Daemon) handler() http.Handler {
+	authenticator := auth.NewAuthenticator(d.config.API.Tokens)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.handleHealth)
+
+	if d.config.Artifacts.Enabled {
+		files := http.StripPrefix("/artifacts/", http.FileServer(http.Dir(d.config.Artifacts.Dir)))
+		mux.Handle("/artifacts/", authenticator.Require(config.RoleRead, files))
+	}
+
+	if d.config.Heatmap.Enabled {
+		for _, name := range []string{heatmap.ImageFile, heatmap.GeoJSONFile} {
+			mux.Handle("/"+name, authenticator.Require(config.RoleRead, d.heatmapFile(name)))
+		}
+	}
+
+	if d.config.Store.Enabled {
+		feed := authenticator.Require(config.RoleRead, http.HandlerFunc(d.handleFeed))
+		mux.Handle("/feed.xml", feed)
+		mux.Handle("/feed/", feed)
+		mux.Handle("/graphql", authenticator.Require(config.RoleRead, graphqlHandler(d.graphqlSchema())))
+		mux.Handle("/blocklist", authenticator.Require(config.RoleRead, http.HandlerFunc(d.handleBlocklist)))
+		edl := authenticator.Require(config.RoleRead, http.HandlerFunc(d.handleEDL))
+		mux.Handle("/edl", edl)
+		mux.Handle("/edl/", edl)
+	}
+
+	// Chat platforms and PagerDuty sign their requests, and links in
+	// notifications are signed, instead of sending API tokens
+	if d.config.Ack.Enabled {
+		mux.Handle("/ack", authenticator.Require(config.RoleOperator, http.HandlerFunc(d.handleAck)))
+		if d.config.Ack.BaseURL != "" {
+			mux.HandleFunc("/ack/", d.handleAckLink)
+		}
+		if d.config.Ack.PagerDutySecret != "" {
+			mux.HandleFunc("/ack/pagerduty", d.handlePagerDutyAck)
+		}
+		if d.config.ChatOps.SlackSigningSecret != "" {
+			mux.HandleFunc("/chatops/slack/actions", d.handleSlackActions)
+		}
+	}
+	if d.config.ChatOps.SlackSigningSecret != "" {
+		mux.HandleFunc("/chatops/slack", d.handleSlack)
+	}
+	if d.config.ChatOps.TeamsSecurityToken != "" {
+		mux.HandleFunc("/chatops/teams", d.handleTeams)
+	}
+
+	return mux
+}
+
+// handleHealth reports that the daemon is running. It is unauthenticated so
+// container orchestrators can probe it.
+func (d *Daemon) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	health := Health{
+		Status:     "ok",
+		Uptime:     int64(time.Since(d.started).Seconds()),
+		QueueDepth: len(d.events),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(health)
+}
+
+// heatmapFile serves a file of the ban map, for dashboards to embed
+func (d *Daemon) heatmapFile(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := filepath.Join(d.config.Heatmap.Dir, name)
+		if _, err := os.Stat(path); err != nil {
+			http.Error(w, "heatmap not generated yet", http.StatusServiceUnavailable)
+			return
+		}
+		if name == heatmap.GeoJSONFile {
+			w.Header().Set("Content-Type", "application/geo+json")
+		}
+		http.ServeFile(w, r, path)
+	})
+}
+
+// serveHTTP runs the HTTP listener until ctx is canceled
+func (d *Daemon) serveHTTP(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              d.config.Daemon.Listen,
+		Handler:           d.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	tlsCfg := d.config.Daemon.TLS
+	if tlsCfg.Enabled() {
+		var err error
+		server.TLSConfig, err = pki.ServerConfig(tlsCfg.CA, tlsCfg.Cert, tlsCfg.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		d.logger.Printf("Serving HTTP on %s", d.config.Daemon.Listen)
+		if tlsCfg.Enabled() {
+			errChan <- server.ListenAndServeTLS("", "")
+		} else {
+			errChan <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/auth"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// TestOperatorRoutesNeedTokens checks that without API tokens the operator
+// endpoints are refused while the unauthenticated ones stay reachable, and
+// that an operator token gets through once tokens are configured
+func TestOperatorRoutesNeedTokens(t *testing.T) {
+	cfg := &config.Config{Ack: config.AckConfig{Enabled: true}}
+	d := &Daemon{config: cfg}
+
+	ack := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/ack", strings.NewReader(`{}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := ack(""); code != http.StatusForbidden {
+		t.Errorf("POST /ack without tokens = %d, want %d", code, http.StatusForbidden)
+	}
+	rec := httptest.NewRecorder()
+	d.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz without tokens = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	plain, token, err := auth.Issue("ops", config.RoleOperator, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.API.Tokens = []config.APIToken{token}
+	if code := ack(""); code != http.StatusUnauthorized {
+		t.Errorf("POST /ack without a token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	// The empty body is rejected by the handler, past authentication
+	if code := ack(plain); code != http.StatusBadRequest {
+		t.Errorf("POST /ack with an operator token = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// TestChatOperatorsMatchIDs checks that chat unbans are only allowed for
+// operators' IDs, not for senders who took an operator's display name
+func TestChatOperatorsMatchIDs(t *testing.T) {
+	fail2banClient, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("true not found")
+	}
+	teamsToken := base64.StdEncoding.EncodeToString([]byte("teams-secret"))
+	cfg := &config.Config{ChatOps: config.ChatOpsConfig{
+		SlackSigningSecret: "slack-secret",
+		TeamsSecurityToken: teamsToken,
+		Operators:          []string{"U024BE7LH", "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "Jane Doe", "jane"},
+		Fail2banClient:     fail2banClient,
+	}}
+	d := &Daemon{config: cfg, logger: log.New(io.Discard, "", 0)}
+
+	slack := func(id, name string) string {
+		body := url.Values{"text": {"unban 192.0.2.1"}, "user_id": {id}, "user_name": {name}}.Encode()
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte("slack-secret"))
+		fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+
+		req := httptest.NewRequest(http.MethodPost, "/chatops/slack", strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		return chatReply(t, d, req)
+	}
+	teams := func(id, name string) string {
+		body, _ := json.Marshal(map[string]interface{}{
+			"text": "<at>f2b</at> unban 192.0.2.1",
+			"from": map[string]string{"name": name, "aadObjectId": id},
+		})
+		mac := hmac.New(sha256.New, []byte("teams-secret"))
+		mac.Write(body)
+
+		req := httptest.NewRequest(http.MethodPost, "/chatops/teams", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "HMAC "+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		return chatReply(t, d, req)
+	}
+
+	tests := []struct {
+		name    string
+		reply   string
+		allowed bool
+	}{
+		{"slack operator ID", slack("U024BE7LH", "someone"), true},
+		{"slack operator user name", slack("U0IMPOSTER", "jane"), false},
+		{"slack user name as ID", slack("", "U024BE7LH"), false},
+		{"teams operator ID", teams("1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "Someone"), true},
+		{"teams operator display name", teams("9f8e7d6c-0000-4000-8000-000000000000", "Jane Doe"), false},
+	}
+	for _, tt := range tests {
+		if allowed := strings.HasPrefix(tt.reply, "Unbanned"); allowed != tt.allowed {
+			t.Errorf("%s: reply %q, allowed = %v, want %v", tt.name, tt.reply, allowed, tt.allowed)
+		}
+	}
+}
+
+// chatReply serves a chat request and returns the text of the reply
+func chatReply(t *testing.T, d *Daemon, req *http.Request) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	d.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s = %d: %s", req.URL.Path, rec.Code, rec.Body)
+	}
+	var reply struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply.Text
+}
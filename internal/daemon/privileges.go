@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// DropPrivileges switches a daemon started as root to the named user and its
+// groups, which also drops all capabilities. The socket directory is created
+// for the user first, since it usually lives in the root-owned /run.
+func DropPrivileges(cfg *config.Config, name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %s: %w", u.Uid, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %s: %w", u.Gid, err)
+	}
+
+	if os.Geteuid() != 0 {
+		if os.Geteuid() == uid {
+			return nil
+		}
+		return fmt.Errorf("must be started as root to switch to user %s", name)
+	}
+
+	groups, err := groupIDs(u)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Daemon.Socket != "" {
+		// Members of the socket group must be able to enter the directory
+		dirGID := gid
+		if cfg.Daemon.SocketGroup != "" {
+			if dirGID, err = lookupGroup(cfg.Daemon.SocketGroup); err != nil {
+				return err
+			}
+		}
+		dir := filepath.Dir(cfg.Daemon.Socket)
+		if err := os.MkdirAll(dir, SocketDirPermission); err != nil {
+			return fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		if err := os.Chown(dir, uid, dirGID); err != nil {
+			return fmt.Errorf("failed to change owner of socket directory: %w", err)
+		}
+	}
+
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("failed to set groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("failed to set gid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("failed to set uid: %w", err)
+	}
+
+	// Connector scripts inherit the environment
+	_ = os.Setenv("USER", u.Username)
+	_ = os.Setenv("LOGNAME", u.Username)
+	_ = os.Setenv("HOME", u.HomeDir)
+
+	return nil
+}
+
+// groupIDs returns the numeric IDs of a user's groups, so membership in
+// groups such as adm still grants access to fail2ban.log
+func groupIDs(u *user.User) ([]int, error) {
+	names, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up groups of %s: %w", u.Username, err)
+	}
+
+	groups := make([]int, 0, len(names))
+	for _, name := range names {
+		gid, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %s: %w", name, err)
+		}
+		groups = append(groups, gid)
+	}
+	return groups, nil
+}
+
+// lookupGroup returns the numeric ID of a group
+func lookupGroup(name string) (int, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %s: %w", name, err)
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid gid %s: %w", g.Gid, err)
+	}
+	return gid, nil
+}
@@ -0,0 +1,263 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// Journal messages systemd logs when a unit starts
+const (
+	journalUnitStarting = "7d4958e842da4a758f6c1cdc7b36dcc5"
+	journalUnitStarted  = "39f53479d3a045ac8e11786248231fbf"
+)
+
+// journalCursorInterval is how often the journal cursor is saved while
+// entries keep arriving
+const journalCursorInterval = 10 * time.Second
+
+// restartGrace collects the bans fail2ban re-applies from its database
+// after a restart, so they are summarized instead of notified one by one
+type restartGrace struct {
+	mu     sync.Mutex
+	window time.Duration
+	until  time.Time      // End of the open window, zero when closed
+	jails  map[string]int // Bans absorbed per jail
+	bans   int
+}
+
+func newRestartGrace(seconds int) *restartGrace {
+	return &restartGrace{window: time.Duration(seconds) * time.Second, jails: make(map[string]int)}
+}
+
+// open starts a window at the time fail2ban started, or extends the open
+// one. It returns true if no window was open and the new one isn't over at
+// now yet.
+func (g *restartGrace) open(started, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if started.After(now) {
+		started = now // Clock skew between the log and the daemon
+	}
+	until := started.Add(g.window)
+	if !until.After(now) {
+		return false
+	}
+	opened := !now.Before(g.until)
+	if until.After(g.until) {
+		g.until = until
+	}
+	return opened
+}
+
+// active reports whether a window is open at now
+func (g *restartGrace) active(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return now.Before(g.until)
+}
+
+// absorb counts a restored ban toward the summary
+func (g *restartGrace) absorb(jail string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.jails[jail]++
+	g.bans++
+}
+
+// close ends the window once it is over at now, returning the summary of
+// the bans it absorbed or nil if there were none
+func (g *restartGrace) close(now time.Time) *types.Restore {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.until.IsZero() || now.Before(g.until) {
+		return nil
+	}
+	g.until = time.Time{}
+	if g.bans == 0 {
+		return nil
+	}
+
+	restore := &types.Restore{Bans: g.bans, Jails: g.jails, Window: int(g.window / time.Second)}
+	g.jails = make(map[string]int)
+	g.bans = 0
+	return restore
+}
+
+// restarted opens the grace window after fail2ban started at started
+func (d *Daemon) restarted(started time.Time, source string) {
+	if d.grace == nil {
+		return
+	}
+	if d.grace.open(started, time.Now()) {
+		d.logger.Printf("Fail2ban restarted (%s), summarizing restored bans for %ds", source, d.config.Daemon.RestartGrace)
+	}
+}
+
+// absorbRestored reports whether ev is a ban fail2ban re-applied after a
+// restart, which was notified when it was first banned. Inside the grace
+// window such bans, and bans the store already holds, count toward the
+// restart summary instead of being notified.
+func (d *Daemon) absorbRestored(ev *pipeline.Event) bool {
+	if ev.Action != types.ActionBan {
+		return false
+	}
+
+	if d.grace == nil {
+		if ev.Restored && d.config.Debug {
+			d.logger.Printf("Skipping restored ban of %s in %s", ev.IP, ev.Jail)
+		}
+		return ev.Restored
+	}
+
+	// A restored ban proves the restart even if its announcement was missed
+	if ev.Restored {
+		d.restarted(time.Now(), "restored ban")
+	}
+	if !d.grace.active(time.Now()) {
+		return ev.Restored
+	}
+	if !ev.Restored && !d.pipeline.Active(ev) {
+		return false
+	}
+
+	d.grace.absorb(ev.Jail)
+	return true
+}
+
+// summarizeRestart delivers the restart summary once the grace window is
+// over
+func (d *Daemon) summarizeRestart(now time.Time) {
+	restore := d.grace.close(now)
+	if restore == nil {
+		return
+	}
+	if _, err := d.pipeline.DeliverRestore(restore, now); err != nil {
+		d.logger.Printf("Delivering the restart summary completed with errors: %v", err)
+	}
+}
+
+// journalEntry holds the fields of a journalctl JSON line the restart
+// detection needs. MESSAGE is raw since the journal stores binary messages
+// as arrays of bytes.
+type journalEntry struct {
+	Cursor    string          `json:"__CURSOR"`
+	Realtime  string          `json:"__REALTIME_TIMESTAMP"` // Microseconds since the epoch
+	MessageID string          `json:"MESSAGE_ID"`
+	Message   json.RawMessage `json:"MESSAGE"`
+}
+
+// journalCursor is the state file remembering how far the journal was read
+type journalCursor struct {
+	Cursor string `json:"cursor"`
+}
+
+// followJournal watches the journal of the fail2ban unit for starts. It
+// resumes after the cursor saved by its last run, so a restart while the
+// daemon was down, such as at boot, still opens a grace window if it is
+// recent enough.
+func (d *Daemon) followJournal(ctx context.Context) error {
+	path, err := exec.LookPath("journalctl")
+	if err != nil {
+		return fmt.Errorf("journalctl not found: %w", err)
+	}
+
+	cursorPath := filepath.Join(d.config.StateDir, "daemon", "journal.json")
+	var saved journalCursor
+	if err := state.Load(cursorPath, &saved); err != nil {
+		return err
+	}
+
+	args := []string{"--unit", d.config.Daemon.JournalUnit, "--follow", "--output", "json"}
+	if saved.Cursor != "" {
+		args = append(args, "--after-cursor", saved.Cursor)
+	} else {
+		args = append(args, "--lines", "0")
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run journalctl: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run journalctl: %w", err)
+	}
+
+	d.logger.Printf("Following the journal of %s", d.config.Daemon.JournalUnit)
+
+	cursor, lastSave := saved.Cursor, time.Now()
+	save := func() {
+		if cursor == saved.Cursor {
+			return
+		}
+		if err := state.Save(cursorPath, journalCursor{Cursor: cursor}); err != nil {
+			d.logger.Printf("Failed to save journal cursor: %v", err)
+			return
+		}
+		saved.Cursor, lastSave = cursor, time.Now()
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		cursor = entry.Cursor
+
+		if journalStart(&entry) {
+			d.restarted(entry.loggedAt(), "journal")
+			save()
+		} else if time.Since(lastSave) >= journalCursorInterval {
+			save()
+		}
+	}
+	save()
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("journalctl exited: %w", err)
+	}
+	return fmt.Errorf("journalctl exited unexpectedly")
+}
+
+// journalStart reports whether the entry announces a start of the unit,
+// either systemd's start job or fail2ban's own log line when it logs to the
+// journal
+func journalStart(entry *journalEntry) bool {
+	if entry.MessageID == journalUnitStarting || entry.MessageID == journalUnitStarted {
+		return true
+	}
+	var message string
+	if err := json.Unmarshal(entry.Message, &message); err != nil {
+		return false
+	}
+	return strings.Contains(message, "Starting Fail2ban")
+}
+
+// loggedAt returns when the entry was logged, or now if it has no timestamp
+func (e *journalEntry) loggedAt() time.Time {
+	usec, err := strconv.ParseInt(e.Realtime, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.UnixMicro(usec)
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"      //nolint:depguard
+)
+
+// slaFile records the bans already escalated for a missed SLA, keyed by
+// event ID, so each is escalated once
+const slaFile = "sla.json"
+
+// enforceSLA escalates bans whose acknowledgment deadline has passed, at
+// start and every sla interval
+func (d *Daemon) enforceSLA(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(d.config.SLA.Interval) * time.Second)
+	defer ticker.Stop()
+
+	manager := connectors.NewManager(d.config, d.logger)
+	for {
+		if err := d.checkSLA(manager, time.Now()); err != nil {
+			d.logger.Printf("Failed to check acknowledgment SLAs: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSLA escalates every unacknowledged ban past its deadline that was
+// not escalated yet. A ban stays due until at least one escalation
+// connector delivers it.
+func (d *Daemon) checkSLA(manager *connectors.Manager, now time.Time) error {
+	st := store.New(d.config.Store)
+	since := now.Add(-time.Duration(d.config.Ack.Window) * time.Second)
+	pending, err := st.Unacknowledged(func(jail string) bool {
+		_, ok := d.config.SLA.Deadline(jail)
+		return ok
+	}, since)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	escalated := make(map[string]time.Time)
+	return state.Update(filepath.Join(d.config.Store.Dir, slaFile), &escalated, func() error {
+		for id, at := range escalated {
+			if at.Before(since) {
+				delete(escalated, id)
+			}
+		}
+
+		for i := range pending {
+			event := &pending[i]
+			id := event.ID()
+			deadline, _ := d.config.SLA.Deadline(event.Jail)
+			if _, done := escalated[id]; done || now.Before(event.Time.Add(deadline)) {
+				continue
+			}
+
+			event.EventID = id
+			event.AckURL = d.config.Ack.URL(id)
+			event.Escalation = fmt.Sprintf("Not acknowledged within %s, the SLA of jail %s",
+				d.config.Humanize().Duration(deadline), event.Jail)
+			batch, err := manager.EscalateSLA(event)
+			if batch != nil {
+				if err := st.AppendResults(batch); err != nil {
+					d.logger.Printf("Failed to record SLA escalation results: %v", err)
+				}
+			}
+			if err != nil {
+				d.logger.Printf("Failed to escalate event %s past its SLA: %v", id, err)
+				continue
+			}
+			d.logger.Printf("Escalated event %s (%s): not acknowledged within %s", id, event, deadline)
+			escalated[id] = now
+		}
+		return nil
+	})
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/pipeline" //nolint:depguard
+)
+
+// Socket settings
+const (
+	SocketDirPermission  = 0750
+	SocketFilePermission = 0660
+	socketTimeout        = 10 * time.Second
+	systemdFirstFD       = 3 // First file descriptor passed by socket activation
+)
+
+// socketReply is written back for every event received on the socket
+type socketReply struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// SendEvent delivers an event to a daemon listening on the unix socket at
+// path. It returns once the daemon has queued the event.
+func SendEvent(path string, ev *pipeline.Event) error {
+	conn, err := net.DialTimeout("unix", path, socketTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon socket: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Now().Add(socketTimeout))
+
+	if err := json.NewEncoder(conn).Encode(ev); err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+
+	var reply socketReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return fmt.Errorf("failed to read daemon reply: %w", err)
+	}
+	if !reply.OK {
+		return fmt.Errorf("daemon rejected event: %s", reply.Error)
+	}
+
+	return nil
+}
+
+// serveSocket accepts newline-delimited JSON events on the configured unix
+// socket until ctx is canceled. A socket passed by systemd socket activation
+// is used instead of creating one.
+func (d *Daemon) serveSocket(ctx context.Context) error {
+	path := d.config.Daemon.Socket
+
+	listener, err := systemdListener()
+	if err != nil {
+		return err
+	}
+	if listener == nil {
+		if listener, err = listenSocket(path, d.config.Daemon.SocketGroup); err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.Remove(path)
+		}()
+	} else {
+		path = listener.Addr().String()
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	d.logger.Printf("Listening for events on %s", path)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			d.logger.Printf("Socket accept failed: %v", err)
+			continue
+		}
+		go d.handleSocketConn(ctx, conn)
+	}
+}
+
+// listenSocket creates the unix socket at path, writable by group if given
+func listenSocket(path, group string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), SocketDirPermission); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	// Remove a stale socket left by an unclean shutdown
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket: %w", err)
+	}
+
+	if err := os.Chmod(path, SocketFilePermission); err != nil {
+		_ = listener.Close()
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err == nil {
+			err = os.Chown(path, -1, gid)
+		}
+		if err != nil {
+			_ = listener.Close()
+			_ = os.Remove(path)
+			return nil, fmt.Errorf("failed to set socket group: %w", err)
+		}
+	}
+
+	return listener, nil
+}
+
+// systemdListener returns the socket passed by systemd socket activation
+// (LISTEN_PID and LISTEN_FDS), or nil if the daemon was started without one
+func systemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+
+	// Connector scripts must not see the variables
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer func() {
+		_ = file.Close()
+	}()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use socket from systemd: %w", err)
+	}
+	return listener, nil
+}
+
+// handleSocketConn reads events from one client connection
+func (d *Daemon) handleSocketConn(ctx context.Context, conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(socketTimeout))
+		if !scanner.Scan() {
+			return
+		}
+
+		var reply socketReply
+		var ev pipeline.Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			reply.Error = fmt.Sprintf("invalid event: %v", err)
+		} else if err := d.Submit(ctx, &ev); err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.OK = true
+		}
+
+		if err := encoder.Encode(reply); err != nil {
+			return
+		}
+	}
+}
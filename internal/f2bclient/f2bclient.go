@@ -0,0 +1,93 @@
+// Package f2bclient queries and controls fail2ban through fail2ban-client
+package f2bclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// timeout bounds each fail2ban-client call
+const timeout = 10 * time.Second
+
+// Client runs fail2ban-client
+type Client struct {
+	path string // Empty to look fail2ban-client up in PATH
+}
+
+// New returns a client running the fail2ban-client at path, or the one in
+// PATH if path is empty
+func New(path string) *Client {
+	return &Client{path: path}
+}
+
+// Run runs fail2ban-client with args and returns its output
+func (c *Client) Run(ctx context.Context, args ...string) (string, error) {
+	path := c.path
+	if path == "" {
+		var err error
+		if path, err = exec.LookPath("fail2ban-client"); err != nil {
+			return "", fmt.Errorf("fail2ban-client not found: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("fail2ban-client failed: %s", msg)
+		}
+		return "", fmt.Errorf("fail2ban-client failed: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+// Status parses the "key: value" lines of fail2ban-client status, of the
+// server if jail is empty
+func (c *Client) Status(ctx context.Context, jail string) (map[string]string, error) {
+	args := []string{"status"}
+	if jail != "" {
+		args = append(args, jail)
+	}
+
+	out, err := c.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		// Lines are drawn as a tree, e.g. "   |- Currently banned:\t3"
+		key, value, ok := strings.Cut(strings.TrimLeft(line, " |`-"), ":")
+		if ok {
+			status[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return status, nil
+}
+
+// Jails returns the names of the running jails
+func (c *Client) Jails(ctx context.Context) ([]string, error) {
+	status, err := c.Status(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(status["Jail list"], func(r rune) bool { return r == ',' || r == ' ' }), nil
+}
+
+// Banned returns the IPs currently banned in a jail
+func (c *Client) Banned(ctx context.Context, jail string) ([]string, error) {
+	status, err := c.Status(ctx, jail)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(status["Banned IP list"]), nil
+}
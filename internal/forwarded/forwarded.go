@@ -0,0 +1,84 @@
+// Package forwarded finds the client behind a reverse proxy in the log lines
+// fail2ban matched, for jails that ban the proxy instead of the client
+package forwarded
+
+import (
+	"net"
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Resolve returns the client a proxy forwarded for, or nil if the lines
+// don't name one. Lines are searched newest first. In a chain such as
+// "client, proxy1, proxy2" the rightmost address that is neither the banned
+// IP nor a trusted proxy is the client, since addresses further left are
+// set by the client and can be forged.
+func Resolve(cfg *config.ProxyConfig, bannedIP string, lines []string) *types.Client {
+	banned := net.ParseIP(bannedIP)
+	for i := len(lines) - 1; i >= 0; i-- {
+		for j, re := range cfg.Regexps() {
+			for _, match := range re.FindAllStringSubmatch(lines[i], -1) {
+				addresses := chain(match[re.SubexpIndex("client")])
+				for k := len(addresses) - 1; k >= 0; k-- {
+					ip := addresses[k]
+					if ip.Equal(banned) || cfg.Trusted(ip.String()) {
+						continue
+					}
+					return &types.Client{IP: ip.String(), Header: source(cfg, j)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// source names the header or pattern of the jth expression
+func source(cfg *config.ProxyConfig, j int) string {
+	if j < len(cfg.Headers) {
+		return cfg.Headers[j]
+	}
+	return "pattern"
+}
+
+// chain parses the addresses of a forwarding header value, in order. It
+// reads X-Forwarded-For lists as well as the for= parameters of Forwarded
+// (RFC 7239), and skips obfuscated identifiers such as "unknown".
+func chain(value string) []net.IP {
+	var addresses []net.IP
+	for _, hop := range strings.Split(value, ",") {
+		for _, param := range strings.Split(hop, ";") {
+			param = strings.TrimSpace(param)
+			if key, v, ok := strings.Cut(param, "="); ok {
+				if !strings.EqualFold(strings.TrimSpace(key), "for") {
+					continue
+				}
+				param = strings.TrimSpace(v)
+			}
+			fields := strings.Fields(param)
+			if len(fields) == 0 {
+				continue
+			}
+			if ip := parseHost(strings.Trim(fields[0], `"'`)); ip != nil {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	return addresses
+}
+
+// parseHost parses an address that may carry a port, e.g. "1.2.3.4:5678"
+// or "[2001:db8::1]:443"
+func parseHost(host string) net.IP {
+	if strings.HasPrefix(host, "[") {
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return nil
+		}
+		host = host[1:end]
+	} else if strings.Count(host, ":") == 1 {
+		host, _, _ = strings.Cut(host, ":")
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,20 @@
+package geoip
+
+import "github.com/eyeskiller/fail2ban-notifier/internal/countries" //nolint:depguard
+
+// normalizeCountry fills in the country code, canonical name and continent
+// of info from whichever of them the provider returned
+func normalizeCountry(info *Info) {
+	code := countries.Code(info.CountryCode)
+	if code == "" {
+		code = countries.Code(info.Country)
+	}
+
+	c, ok := countries.Lookup(code)
+	if !ok {
+		return
+	}
+	info.CountryCode = code
+	info.Country = c.Name
+	info.Continent = c.Continent
+}
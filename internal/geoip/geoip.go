@@ -9,22 +9,27 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
 )
 
 // Info represents geolocation information for an IP address
 type Info struct {
-	IP       string  `json:"ip"`
-	Country  string  `json:"country"`
-	Region   string  `json:"region"`
-	City     string  `json:"city"`
-	ISP      string  `json:"isp"`
-	Timezone string  `json:"timezone"`
-	Lat      float64 `json:"lat"`
-	Lon      float64 `json:"lon"`
+	IP          string  `json:"ip"`
+	Country     string  `json:"country"`      // Common English name, see countries.go
+	CountryCode string  `json:"country_code"` // ISO 3166-1 alpha-2 code, e.g. "DE"
+	Continent   string  `json:"continent"`    // Continent code, e.g. "EU"
+	Region      string  `json:"region"`
+	City        string  `json:"city"`
+	ISP         string  `json:"isp"`
+	ASN         string  `json:"asn"` // Autonomous system number, e.g. "AS4134"
+	Timezone    string  `json:"timezone"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
 }
 
 // Service represents a GeoIP service provider
@@ -35,11 +40,13 @@ type Service interface {
 
 // Manager manages GeoIP lookups with caching
 type Manager struct {
-	config   config.GeoIPConfig
-	cache    map[string]*cacheEntry
-	cacheMu  sync.RWMutex
-	logger   *log.Logger
-	services map[string]Service
+	config    config.GeoIPConfig
+	cache     map[string]*cacheEntry
+	cacheMu   sync.RWMutex
+	logger    *log.Logger
+	services  map[string]Service
+	clock     types.Clock
+	overrides *overrides // Nil without an overrides file
 }
 
 type cacheEntry struct {
@@ -58,6 +65,11 @@ func NewManager(cfg config.GeoIPConfig, logger *log.Logger) *Manager {
 		cache:    make(map[string]*cacheEntry),
 		logger:   logger,
 		services: make(map[string]Service),
+		clock:    types.SystemClock,
+	}
+
+	if cfg.Overrides != "" {
+		manager.overrides = &overrides{path: cfg.Overrides}
 	}
 
 	// Register available services
@@ -72,6 +84,23 @@ func NewManager(cfg config.GeoIPConfig, logger *log.Logger) *Manager {
 	return manager
 }
 
+// SetClock replaces the clock that ages cache entries
+func (m *Manager) SetClock(clock types.Clock) {
+	m.clock = clock
+}
+
+// SetHTTPDoer sends the lookups of the built-in services through doer
+func (m *Manager) SetHTTPDoer(doer types.HTTPDoer) {
+	for _, service := range m.services {
+		switch s := service.(type) {
+		case *IPAPIService:
+			s.client = doer
+		case *IPGeolocationService:
+			s.client = doer
+		}
+	}
+}
+
 // Lookup performs a GeoIP lookup for the given IP address
 func (m *Manager) Lookup(ip string) (*Info, error) {
 	if !m.config.Enabled {
@@ -79,10 +108,19 @@ func (m *Manager) Lookup(ip string) (*Info, error) {
 	}
 
 	// Validate IP address
-	if net.ParseIP(ip) == nil {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
 		return nil, fmt.Errorf("invalid IP address: %s", ip)
 	}
 
+	// Local overrides take precedence over everything, even private ranges
+	if m.overrides != nil {
+		if info, ok := m.overrides.lookup(parsed, m.clock.Now(), m.logger.Printf); ok {
+			info.IP = ip
+			return info, nil
+		}
+	}
+
 	// Skip private/local IP addresses
 	if isPrivateIP(ip) {
 		return &Info{
@@ -114,6 +152,9 @@ func (m *Manager) Lookup(ip string) (*Info, error) {
 		return &Info{IP: ip}, nil // Return empty info instead of error
 	}
 
+	// Providers spell countries differently
+	normalizeCountry(info)
+
 	// Cache the result
 	if m.config.Cache {
 		m.setCached(ip, info)
@@ -133,7 +174,7 @@ func (m *Manager) getCached(ip string) *Info {
 	}
 
 	// Check if cache entry is still valid
-	if time.Since(entry.timestamp) > time.Duration(m.config.TTL)*time.Second {
+	if m.clock.Now().Sub(entry.timestamp) > time.Duration(m.config.TTL)*time.Second {
 		return nil
 	}
 
@@ -147,7 +188,7 @@ func (m *Manager) setCached(ip string, info *Info) {
 
 	m.cache[ip] = &cacheEntry{
 		info:      info,
-		timestamp: time.Now(),
+		timestamp: m.clock.Now(),
 	}
 }
 
@@ -217,7 +258,7 @@ func isPrivateIP(ip string) bool {
 
 // IPAPIService implements the ip-api.com service
 type IPAPIService struct {
-	client *http.Client
+	client types.HTTPDoer
 }
 
 func (s *IPAPIService) GetName() string {
@@ -225,7 +266,7 @@ func (s *IPAPIService) GetName() string {
 }
 
 func (s *IPAPIService) Lookup(ip string) (*Info, error) {
-	url := fmt.Sprintf("https://ip-api.com/json/%s?fields=status,country,regionName,city,isp,timezone,lat,lon", ip)
+	url := fmt.Sprintf("https://ip-api.com/json/%s?fields=status,country,countryCode,regionName,city,isp,as,timezone,lat,lon", ip)
 
 	// Create a new request with context
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -256,14 +297,16 @@ func (s *IPAPIService) Lookup(ip string) (*Info, error) {
 	}
 
 	var result struct {
-		Status     string  `json:"status"`
-		Country    string  `json:"country"`
-		RegionName string  `json:"regionName"`
-		City       string  `json:"city"`
-		ISP        string  `json:"isp"`
-		Timezone   string  `json:"timezone"`
-		Lat        float64 `json:"lat"`
-		Lon        float64 `json:"lon"`
+		Status      string  `json:"status"`
+		Country     string  `json:"country"`
+		CountryCode string  `json:"countryCode"`
+		RegionName  string  `json:"regionName"`
+		City        string  `json:"city"`
+		ISP         string  `json:"isp"`
+		AS          string  `json:"as"` // "AS4134 CHINANET-BACKBONE"
+		Timezone    string  `json:"timezone"`
+		Lat         float64 `json:"lat"`
+		Lon         float64 `json:"lon"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
@@ -275,21 +318,23 @@ func (s *IPAPIService) Lookup(ip string) (*Info, error) {
 	}
 
 	return &Info{
-		IP:       ip,
-		Country:  result.Country,
-		Region:   result.RegionName,
-		City:     result.City,
-		ISP:      result.ISP,
-		Timezone: result.Timezone,
-		Lat:      result.Lat,
-		Lon:      result.Lon,
+		IP:          ip,
+		Country:     result.Country,
+		CountryCode: result.CountryCode,
+		Region:      result.RegionName,
+		City:        result.City,
+		ISP:         result.ISP,
+		ASN:         strings.SplitN(result.AS, " ", 2)[0],
+		Timezone:    result.Timezone,
+		Lat:         result.Lat,
+		Lon:         result.Lon,
 	}, nil
 }
 
 // IPGeolocationService implements the ipgeolocation.io service
 type IPGeolocationService struct {
 	apiKey string
-	client *http.Client
+	client types.HTTPDoer
 }
 
 func (s *IPGeolocationService) GetName() string {
@@ -330,9 +375,11 @@ func (s *IPGeolocationService) Lookup(ip string) (*Info, error) {
 	var result struct {
 		IP          string  `json:"ip"`
 		CountryName string  `json:"country_name"`
+		CountryCode string  `json:"country_code2"`
 		StateProv   string  `json:"state_prov"`
 		City        string  `json:"city"`
 		ISP         string  `json:"isp"`
+		ASN         string  `json:"asn"`
 		TimeZone    string  `json:"time_zone"`
 		Latitude    float64 `json:"latitude"`
 		Longitude   float64 `json:"longitude"`
@@ -349,14 +396,16 @@ func (s *IPGeolocationService) Lookup(ip string) (*Info, error) {
 	}
 
 	return &Info{
-		IP:       ip,
-		Country:  result.CountryName,
-		Region:   result.StateProv,
-		City:     result.City,
-		ISP:      result.ISP,
-		Timezone: result.TimeZone,
-		Lat:      result.Latitude,
-		Lon:      result.Longitude,
+		IP:          ip,
+		Country:     result.CountryName,
+		CountryCode: result.CountryCode,
+		Region:      result.StateProv,
+		City:        result.City,
+		ISP:         result.ISP,
+		ASN:         result.ASN,
+		Timezone:    result.TimeZone,
+		Lat:         result.Latitude,
+		Lon:         result.Longitude,
 	}, nil
 }
 
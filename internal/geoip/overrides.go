@@ -0,0 +1,128 @@
+package geoip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// overrideCheckInterval is how often the overrides file is checked for
+// changes
+const overrideCheckInterval = time.Second
+
+// override is a network with fixed labels
+type override struct {
+	network *net.IPNet
+	info    Info
+}
+
+// overrides holds the overrides file and reloads it when it changes
+type overrides struct {
+	path string
+
+	mu      sync.Mutex
+	entries []override // Most specific network first
+	loaded  bool
+	missing bool
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// loadOverrides parses an overrides file. It maps IPs or CIDRs to the labels
+// of an Info, e.g. {"203.0.113.0/24": {"isp": "Partner VPN", "country_code": "DE"}}.
+func loadOverrides(path string) ([]override, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]Info
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	entries := make([]override, 0, len(raw))
+	for key, info := range raw {
+		cidr := key
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("%s: invalid IP or CIDR '%s'", path, key)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid IP or CIDR '%s'", path, key)
+		}
+		normalizeCountry(&info)
+		entries = append(entries, override{network: network, info: info})
+	}
+
+	// The longest prefix wins
+	sort.Slice(entries, func(i, j int) bool {
+		a, _ := entries[i].network.Mask.Size()
+		b, _ := entries[j].network.Mask.Size()
+		return a > b
+	})
+	return entries, nil
+}
+
+// lookup returns the labels of the most specific network containing ip. The
+// file is reloaded first when it changed, so edits apply without a restart;
+// a file that fails to load keeps the previous overrides.
+func (o *overrides) lookup(ip net.IP, now time.Time, logf func(string, ...interface{})) (*Info, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if now.Sub(o.checked) >= overrideCheckInterval {
+		o.checked = now
+		o.reload(logf)
+	}
+
+	for _, entry := range o.entries {
+		if entry.network.Contains(ip) {
+			info := entry.info
+			return &info, true
+		}
+	}
+	return nil, false
+}
+
+// reload loads the file again when its modification time or size changed
+func (o *overrides) reload(logf func(string, ...interface{})) {
+	stat, err := os.Stat(o.path)
+	if err != nil {
+		if !o.missing {
+			logf("GeoIP overrides unavailable: %v", err)
+		}
+		o.entries, o.modTime, o.size, o.missing = nil, time.Time{}, 0, true
+		return
+	}
+	o.missing = false
+	if stat.ModTime().Equal(o.modTime) && stat.Size() == o.size {
+		return
+	}
+	o.modTime, o.size = stat.ModTime(), stat.Size()
+
+	entries, err := loadOverrides(o.path)
+	if err != nil {
+		logf("Keeping previous GeoIP overrides: %v", err)
+		return
+	}
+	if o.loaded {
+		logf("Reloaded %d GeoIP overrides from %s", len(entries), o.path)
+	}
+	o.entries, o.loaded = entries, true
+}
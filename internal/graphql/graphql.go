@@ -0,0 +1,346 @@
+// Package graphql implements the subset of GraphQL needed for read-only
+// query APIs: queries with aliases, arguments and variables over a schema of
+// object types. Mutations, fragments, directives and introspection are not
+// supported.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Argument types
+const (
+	String  = "String"
+	Int     = "Int"
+	Float   = "Float"
+	Boolean = "Boolean"
+)
+
+// Object is an object type and its fields
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type
+type Field struct {
+	// Type is the object type of the field's value, or nil for scalars. A
+	// resolved slice is returned as a list of that type.
+	Type *Object
+	// Args declares the accepted arguments
+	Args map[string]Arg
+	// Resolve returns the value of the field. When nil, the value is read
+	// from the parent object's map under the field name.
+	Resolve func(args map[string]interface{}) (interface{}, error)
+}
+
+// Arg declares an argument: its type, the values of an enum and the default
+type Arg struct {
+	Type    string   // String, Int, Float or Boolean; ignored for enums
+	Enum    []string // Allowed values of an enum argument
+	Default interface{}
+}
+
+// Schema is the entry point of queries
+type Schema struct {
+	Query *Object
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request
+type Response struct {
+	Data   *OrderedMap `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is a request or field error
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// OrderedMap is a JSON object that keeps the order of the selected fields
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+func (m *OrderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON encodes the fields in selection order
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Execute runs a query against the schema
+func (s *Schema) Execute(req Request) *Response {
+	operations, err := parse(req.Query)
+	if err != nil {
+		return requestError(err)
+	}
+
+	op, err := selectOperation(operations, req.OperationName)
+	if err != nil {
+		return requestError(err)
+	}
+
+	variables := make(map[string]interface{})
+	for _, def := range op.variables {
+		if value, ok := req.Variables[def.name]; ok {
+			variables[def.name] = value
+		} else if def.hasValue {
+			variables[def.name] = def.def
+		}
+	}
+
+	if err := validate(s.Query, op.selection, op.variables); err != nil {
+		return requestError(err)
+	}
+
+	resp := &Response{Data: newOrderedMap()}
+	for _, f := range op.selection {
+		if f.name == "__typename" {
+			resp.Data.set(f.alias, s.Query.Name)
+			continue
+		}
+
+		def := s.Query.Fields[f.name]
+		args, err := coerceArgs(def, f, variables)
+		if err != nil {
+			return requestError(err)
+		}
+
+		value, err := def.Resolve(args)
+		if err != nil {
+			resp.Data.set(f.alias, nil)
+			resp.Errors = append(resp.Errors, Error{Message: err.Error(), Path: []interface{}{f.alias}})
+			continue
+		}
+		resp.Data.set(f.alias, complete(def.Type, f.selection, value))
+	}
+
+	return resp
+}
+
+// requestError is the response to a request that cannot be executed
+func requestError(err error) *Response {
+	return &Response{Errors: []Error{{Message: err.Error()}}}
+}
+
+// selectOperation picks the operation to run
+func selectOperation(operations []*operation, name string) (*operation, error) {
+	if name == "" {
+		if len(operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		return operations[0], nil
+	}
+
+	for _, op := range operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// validate checks the selection against the object type
+func validate(obj *Object, selection []*field, defs []variableDef) error {
+	for _, f := range selection {
+		if f.name == "__typename" {
+			if f.selection != nil || f.arguments != nil {
+				return fmt.Errorf("field \"__typename\" takes no arguments or selection")
+			}
+			continue
+		}
+
+		def, ok := obj.Fields[f.name]
+		if !ok {
+			return fmt.Errorf("cannot query field %q on type %q, available fields: %s", f.name, obj.Name, fieldNames(obj))
+		}
+
+		for name, value := range f.arguments {
+			if _, ok := def.Args[name]; !ok {
+				return fmt.Errorf("unknown argument %q on field %q", name, f.name)
+			}
+			if ref, ok := value.(variable); ok && !hasVariable(defs, string(ref)) {
+				return fmt.Errorf("variable \"$%s\" is not defined", ref)
+			}
+		}
+
+		switch {
+		case def.Type == nil && f.selection != nil:
+			return fmt.Errorf("field %q of type %q must not have a selection", f.name, obj.Name)
+		case def.Type != nil && f.selection == nil:
+			return fmt.Errorf("field %q of type %q must have a selection of subfields", f.name, obj.Name)
+		case def.Type != nil:
+			if err := validate(def.Type, f.selection, defs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasVariable(defs []variableDef, name string) bool {
+	for _, def := range defs {
+		if def.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldNames(obj *Object) string {
+	names := make([]string, 0, len(obj.Fields))
+	for name := range obj.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// coerceArgs resolves variables and converts the arguments of a field to
+// the declared types: string, int, float64 or bool. Missing arguments take
+// their default and are left out when there is none.
+func coerceArgs(def *Field, f *field, variables map[string]interface{}) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for name, arg := range def.Args {
+		value, ok := f.arguments[name]
+		if ref, isVar := value.(variable); isVar {
+			value, ok = variables[string(ref)]
+		}
+		if !ok || value == nil {
+			if arg.Default != nil {
+				args[name] = arg.Default
+			}
+			continue
+		}
+
+		coerced, err := coerce(arg, value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q of field %q: %w", name, f.name, err)
+		}
+		args[name] = coerced
+	}
+	return args, nil
+}
+
+// coerce converts a literal or variable value to the argument type
+func coerce(arg Arg, value interface{}) (interface{}, error) {
+	if len(arg.Enum) > 0 {
+		var name string
+		switch v := value.(type) {
+		case enumValue:
+			name = string(v)
+		case string:
+			name = v // Variables carry enum values as strings
+		}
+		for _, allowed := range arg.Enum {
+			if name == allowed {
+				return name, nil
+			}
+		}
+		return nil, fmt.Errorf("expected one of %s", strings.Join(arg.Enum, ", "))
+	}
+
+	switch arg.Type {
+	case String:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case Int:
+		switch v := value.(type) {
+		case int64:
+			return int(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), nil
+			}
+		}
+	case Float:
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case Boolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s", arg.Type)
+}
+
+// complete projects a resolved value onto the selection
+func complete(obj *Object, selection []*field, value interface{}) interface{} {
+	if obj == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := newOrderedMap()
+		for _, f := range selection {
+			if f.name == "__typename" {
+				out.set(f.alias, obj.Name)
+				continue
+			}
+			out.set(f.alias, complete(obj.Fields[f.name].Type, f.selection, v[f.name]))
+		}
+		return out
+
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = complete(obj, selection, item)
+		}
+		return list
+
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = complete(obj, selection, item)
+		}
+		return list
+	}
+
+	return nil
+}
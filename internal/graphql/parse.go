@@ -0,0 +1,497 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind classifies lexical tokens
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// operation is a parsed query operation
+type operation struct {
+	name      string
+	variables []variableDef
+	selection []*field
+}
+
+type variableDef struct {
+	name     string
+	def      interface{}
+	hasValue bool
+}
+
+// field is a selected field with its alias, arguments and sub-selection
+type field struct {
+	alias     string
+	name      string
+	arguments map[string]interface{}
+	selection []*field
+}
+
+// variable is a reference to a query variable inside an argument value
+type variable string
+
+// enumValue is an unquoted enum literal
+type enumValue string
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+// parse parses a query document into its operations
+func parse(src string) ([]*operation, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens}
+	var operations []*operation
+	for p.peek().kind != tokenEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("document contains no operation")
+	}
+	return operations, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(value string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.value == value
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if t.kind != tokenPunct || t.value != value {
+		return p.errorf(t, "expected %q", value)
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokenName {
+		return "", p.errorf(t, "expected name")
+	}
+	return t.value, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:t.pos], "\n")
+	found := t.value
+	if t.kind == tokenEOF {
+		found = "end of document"
+	}
+	return fmt.Errorf("syntax error at line %d: %s, found %q", line, fmt.Sprintf(format, args...), found)
+}
+
+// operation parses "query Name($var: Type = default) { ... }" or the
+// shorthand "{ ... }"
+func (p *parser) operation() (*operation, error) {
+	op := &operation{}
+
+	if t := p.peek(); t.kind == tokenName {
+		switch t.value {
+		case "query":
+			p.next()
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("%s operations are not supported", t.value)
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, p.errorf(t, "expected operation")
+		}
+
+		if p.peek().kind == tokenName {
+			op.name = p.next().value
+		}
+		if p.isPunct("(") {
+			vars, err := p.variableDefs()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = vars
+		}
+	}
+
+	selection, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selection = selection
+	return op, nil
+}
+
+func (p *parser) variableDefs() ([]variableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var defs []variableDef
+	for !p.isPunct(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+
+		def := variableDef{name: name}
+		if p.isPunct("=") {
+			p.next()
+			value, err := p.value(true)
+			if err != nil {
+				return nil, err
+			}
+			def.def, def.hasValue = value, true
+		}
+		defs = append(defs, def)
+	}
+	p.next()
+
+	return defs, nil
+}
+
+// skipType consumes a type reference such as [String!]!. Types of
+// variables are not checked; argument coercion validates the values.
+func (p *parser) skipType() error {
+	if p.isPunct("[") {
+		p.next()
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+
+	if p.isPunct("!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *parser) selectionSet() ([]*field, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var fields []*field
+	for !p.isPunct("}") {
+		if p.isPunct("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		if p.isPunct("@") {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.next()
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("selection set cannot be empty")
+	}
+	return fields, nil
+}
+
+func (p *parser) field() (*field, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &field{alias: name, name: name}
+	if p.isPunct(":") {
+		p.next()
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.isPunct("(") {
+		p.next()
+		f.arguments = make(map[string]interface{})
+		for !p.isPunct(")") {
+			argName, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.value(false)
+			if err != nil {
+				return nil, err
+			}
+			f.arguments[argName] = value
+		}
+		p.next()
+	}
+
+	if p.isPunct("@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+
+	if p.isPunct("{") {
+		if f.selection, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// value parses an input value. Default values of variables must be constant.
+func (p *parser) value(constant bool) (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid integer")
+		}
+		return n, nil
+
+	case tokenFloat:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid float")
+		}
+		return f, nil
+
+	case tokenString:
+		return t.value, nil
+
+	case tokenName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(t.value), nil
+
+	case tokenPunct:
+		switch t.value {
+		case "$":
+			if constant {
+				return nil, p.errorf(t, "variables are not allowed here")
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return variable(name), nil
+
+		case "[":
+			list := []interface{}{}
+			for !p.isPunct("]") {
+				if p.peek().kind == tokenEOF {
+					return nil, p.errorf(p.peek(), "expected \"]\"")
+				}
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			p.next()
+			return list, nil
+
+		case "{":
+			object := make(map[string]interface{})
+			for !p.isPunct("}") {
+				key, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if object[key], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			return object, nil
+		}
+	}
+
+	return nil, p.errorf(t, "expected value")
+}
+
+// lex splits src into tokens. Commas and comments are insignificant.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokenPunct, "...", i})
+			i += 3
+
+		case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+			tokens = append(tokens, token{tokenPunct, string(c), i})
+			i++
+
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], start})
+
+		case c == '-' || isDigit(c):
+			start := i
+			i++
+			kind := tokenInt
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				if !isDigit(src[i]) {
+					kind = tokenFloat
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind, src[start:i], start})
+
+		case c == '"':
+			value, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i = end
+
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, fmt.Errorf("syntax error at line %d: unexpected character %q", 1+strings.Count(src[:i], "\n"), r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// lexString reads the string literal starting at src[start] and returns
+// its value and the offset after the closing quote
+func lexString(src string, start int) (string, int, error) {
+	if strings.HasPrefix(src[start:], `"""`) {
+		end := strings.Index(src[start+3:], `"""`)
+		if end < 0 {
+			return "", 0, fmt.Errorf("syntax error: unterminated block string")
+		}
+		return src[start+3 : start+3+end], start + 3 + end + 3, nil
+	}
+
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("syntax error: unterminated string")
+		case '\\':
+			i++
+			if i >= len(src) {
+				return "", 0, fmt.Errorf("syntax error: unterminated string")
+			}
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, fmt.Errorf("syntax error: invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("syntax error: invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				b.WriteByte(src[i])
+			}
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("syntax error: unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
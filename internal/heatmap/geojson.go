@@ -0,0 +1,73 @@
+package heatmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// feature is a GeoJSON point feature of a cell
+type feature struct {
+	Type       string            `json:"type"`
+	Geometry   geometry          `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+type geometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // Longitude, latitude
+}
+
+type featureProperties struct {
+	Bans      int      `json:"bans"`
+	Weight    float64  `json:"weight"` // Bans relative to the busiest cell, 0 to 1, for heatmap layers
+	Countries []string `json:"countries,omitempty"`
+}
+
+// featureCollection is the GeoJSON document
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// WriteGeoJSON writes the cells as a GeoJSON FeatureCollection of points,
+// which mapping libraries such as Leaflet and MapLibre can show as a
+// heatmap layer
+func (m *Map) WriteGeoJSON(w io.Writer) error {
+	collection := featureCollection{Type: "FeatureCollection", Features: []feature{}}
+	peak := m.peak()
+	for _, cell := range m.Cells {
+		collection.Features = append(collection.Features, feature{
+			Type:     "Feature",
+			Geometry: geometry{Type: "Point", Coordinates: [2]float64{round(cell.Lon), round(cell.Lat)}},
+			Properties: featureProperties{
+				Bans:      cell.Bans,
+				Weight:    round(float64(cell.Bans) / float64(peak)),
+				Countries: cell.Countries,
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(collection); err != nil {
+		return fmt.Errorf("failed to write GeoJSON: %w", err)
+	}
+	return nil
+}
+
+// peak returns the bans of the busiest cell, at least 1
+func (m *Map) peak() int {
+	peak := 1
+	for _, cell := range m.Cells {
+		if cell.Bans > peak {
+			peak = cell.Bans
+		}
+	}
+	return peak
+}
+
+// round keeps four decimals, about 10 m, to keep the layer small
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
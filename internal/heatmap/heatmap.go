@@ -0,0 +1,129 @@
+// Package heatmap builds the ban map: it aggregates the locations of banned
+// addresses from the event store into grid cells and renders them as a
+// heatmap image and a GeoJSON layer
+package heatmap
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Files written to the heatmap directory
+const (
+	ImageFile   = "heatmap.png"
+	GeoJSONFile = "heatmap.geojson"
+)
+
+// Cell is a grid cell with the bans located in it
+type Cell struct {
+	Lat       float64  `json:"lat"` // Center of the cell
+	Lon       float64  `json:"lon"`
+	Bans      int      `json:"bans"`
+	Countries []string `json:"countries,omitempty"`
+}
+
+// Map is the aggregated ban locations of a period
+type Map struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	CellSize float64   `json:"cell_size"` // Degrees
+	Bans     int       `json:"bans"`      // Bans with a location
+	Unplaced int       `json:"unplaced"`  // Bans without one, e.g. private addresses
+	Cells    []Cell    `json:"cells"`     // Most bans first
+}
+
+// Build aggregates the bans stored between since and until into cells of
+// cellSize degrees
+func Build(st *store.Store, since, until time.Time, cellSize float64) (*Map, error) {
+	m := &Map{Since: since, Until: until, CellSize: cellSize, Cells: []Cell{}}
+
+	type cellKey struct{ row, col int }
+	cells := make(map[cellKey]*Cell)
+	countries := make(map[cellKey]map[string]bool)
+
+	err := st.Scan(func(data *types.NotificationData) error {
+		if !data.IsBan() || data.Time.Before(since) || data.Time.After(until) {
+			return nil
+		}
+		if data.Latitude == 0 && data.Longitude == 0 {
+			m.Unplaced++
+			return nil
+		}
+
+		m.Bans++
+		key := cellKey{
+			row: int(math.Floor((data.Latitude + 90) / cellSize)),
+			col: int(math.Floor((data.Longitude + 180) / cellSize)),
+		}
+		cell, ok := cells[key]
+		if !ok {
+			cell = &Cell{
+				Lat: -90 + (float64(key.row)+0.5)*cellSize,
+				Lon: -180 + (float64(key.col)+0.5)*cellSize,
+			}
+			cells[key] = cell
+			countries[key] = make(map[string]bool)
+		}
+		cell.Bans++
+		if data.Country != "" {
+			countries[key][data.Country] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, cell := range cells {
+		for country := range countries[key] {
+			cell.Countries = append(cell.Countries, country)
+		}
+		sort.Strings(cell.Countries)
+		m.Cells = append(m.Cells, *cell)
+	}
+	sort.Slice(m.Cells, func(i, j int) bool {
+		if m.Cells[i].Bans != m.Cells[j].Bans {
+			return m.Cells[i].Bans > m.Cells[j].Bans
+		}
+		if m.Cells[i].Lat != m.Cells[j].Lat {
+			return m.Cells[i].Lat > m.Cells[j].Lat
+		}
+		return m.Cells[i].Lon < m.Cells[j].Lon
+	})
+	return m, nil
+}
+
+// Generate builds the map of the configured period and writes the image
+// and GeoJSON layer to the heatmap directory
+func Generate(cfg *config.Config, now time.Time) (*Map, error) {
+	since := now.Add(-time.Duration(cfg.Heatmap.Days) * 24 * time.Hour)
+	m, err := Build(store.New(cfg.Store), since, now, cfg.Heatmap.Cell)
+	if err != nil {
+		return nil, err
+	}
+
+	var image, layer bytes.Buffer
+	if err := m.WritePNG(&image, cfg.Heatmap.Width); err != nil {
+		return nil, err
+	}
+	if err := m.WriteGeoJSON(&layer); err != nil {
+		return nil, err
+	}
+
+	// Replaced atomically, so the daemon never serves a partial image
+	if err := state.WriteFileAtomic(filepath.Join(cfg.Heatmap.Dir, ImageFile), image.Bytes(), state.FilePermission); err != nil {
+		return nil, err
+	}
+	if err := state.WriteFileAtomic(filepath.Join(cfg.Heatmap.Dir, GeoJSONFile), layer.Bytes(), state.FilePermission); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
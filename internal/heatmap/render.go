@@ -0,0 +1,130 @@
+package heatmap
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Colors of the image
+var (
+	background = color.RGBA{R: 0x11, G: 0x18, B: 0x27, A: 0xff}
+	graticule  = color.RGBA{R: 0x26, G: 0x30, B: 0x45, A: 0xff}
+	equator    = color.RGBA{R: 0x37, G: 0x41, B: 0x5a, A: 0xff}
+
+	// ramp maps heat from cold to hot
+	ramp = []color.RGBA{
+		{R: 0x1e, G: 0x40, B: 0xaf, A: 0xff},
+		{R: 0x06, G: 0xb6, B: 0xd4, A: 0xff},
+		{R: 0x22, G: 0xc5, B: 0x5e, A: 0xff},
+		{R: 0xea, G: 0xb3, B: 0x08, A: 0xff},
+		{R: 0xdc, G: 0x26, B: 0x26, A: 0xff},
+	}
+)
+
+// graticuleStep is the spacing of the grid lines in degrees
+const graticuleStep = 30
+
+// WritePNG renders the cells as a heatmap over an equirectangular
+// projection of the world, width pixels wide and half as high
+func (m *Map) WritePNG(w io.Writer, width int) error {
+	height := width / 2
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	scale := float64(width) / 360
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+	for deg := -180 + graticuleStep; deg < 180; deg += graticuleStep {
+		x := int(float64(deg+180) * scale)
+		for y := 0; y < height; y++ {
+			img.SetRGBA(x, y, graticule)
+		}
+	}
+	for deg := -90 + graticuleStep; deg < 90; deg += graticuleStep {
+		c := graticule
+		if deg == 0 {
+			c = equator
+		}
+		y := int(float64(90-deg) * scale)
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	heat := m.heat(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if v := heat[y*width+x]; v > 0.01 {
+				img.SetRGBA(x, y, blend(img.RGBAAt(x, y), heatColor(v), math.Min(1, v*1.5)))
+			}
+		}
+	}
+
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("failed to write heatmap image: %w", err)
+	}
+	return nil
+}
+
+// heat spreads the bans of each cell over a Gaussian spot and returns the
+// heat of every pixel, 0 to 1. The square root keeps a few busy cells from
+// washing out the rest.
+func (m *Map) heat(width, height int) []float64 {
+	heat := make([]float64, width*height)
+	scale := float64(width) / 360
+	sigma := math.Max(2, float64(width)/200)
+	radius := int(math.Ceil(sigma * 3))
+
+	for _, cell := range m.Cells {
+		cx := int((cell.Lon + 180) * scale)
+		cy := int((90 - cell.Lat) * scale)
+		for dy := -radius; dy <= radius; dy++ {
+			y := cy + dy
+			if y < 0 || y >= height {
+				continue
+			}
+			for dx := -radius; dx <= radius; dx++ {
+				// Wrap around the antimeridian
+				x := ((cx+dx)%width + width) % width
+				d := float64(dx*dx + dy*dy)
+				heat[y*width+x] += float64(cell.Bans) * math.Exp(-d/(2*sigma*sigma))
+			}
+		}
+	}
+
+	peak := 0.0
+	for _, v := range heat {
+		peak = math.Max(peak, v)
+	}
+	if peak == 0 {
+		return heat
+	}
+	for i, v := range heat {
+		heat[i] = math.Sqrt(v / peak)
+	}
+	return heat
+}
+
+// heatColor interpolates the ramp at v, 0 to 1
+func heatColor(v float64) color.RGBA {
+	pos := math.Min(1, math.Max(0, v)) * float64(len(ramp)-1)
+	i := int(pos)
+	if i >= len(ramp)-1 {
+		return ramp[len(ramp)-1]
+	}
+	return blend(ramp[i], ramp[i+1], pos-float64(i))
+}
+
+// blend mixes b over a with opacity alpha
+func blend(a, b color.RGBA, alpha float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-alpha) + float64(y)*alpha))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
@@ -0,0 +1,41 @@
+package honeypot
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+func init() {
+	Register("cowrie", newCowrie)
+}
+
+// cowrie reads the JSON log of the Cowrie SSH/Telnet honeypot, where every
+// event carries the session ID it belongs to
+type cowrie struct {
+	path string
+}
+
+type cowrieEvent struct {
+	SrcIP     string    `json:"src_ip"`
+	Session   string    `json:"session"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func newCowrie(source config.HoneypotSource) (Source, error) {
+	return &cowrie{path: source.Path}, nil
+}
+
+// Sessions counts the distinct sessions opened by ip
+func (c *cowrie) Sessions(ip string, since time.Time) (int, error) {
+	sessions := make(map[string]bool)
+	err := scanLines(c.path, ip, func(line []byte) {
+		var event cowrieEvent
+		if json.Unmarshal(line, &event) != nil || event.SrcIP != ip || event.Timestamp.Before(since) {
+			return
+		}
+		sessions[event.Session] = true
+	})
+	return len(sessions), err
+}
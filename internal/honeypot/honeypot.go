@@ -0,0 +1,162 @@
+// Package honeypot cross-references banned IPs with local honeypot logs.
+// Each log format is a Source registered under its name, so new honeypots
+// can be supported without touching the correlation logic.
+package honeypot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// maxLineSize bounds a single honeypot log line
+const maxLineSize = 1024 * 1024
+
+// Source searches one honeypot log for an IP
+type Source interface {
+	// Sessions returns the number of honeypot sessions from ip since the cutoff
+	Sessions(ip string, since time.Time) (int, error)
+}
+
+// Factory creates a Source for a configured log
+type Factory func(source config.HoneypotSource) (Source, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a log format available to the honeypot configuration
+func Register(format string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[format] = factory
+}
+
+// Formats returns the names of the registered log formats
+func Formats() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Correlator searches all configured honeypot logs
+type Correlator struct {
+	window  time.Duration
+	names   []string
+	sources []Source
+}
+
+// NewCorrelator creates the sources of the honeypot configuration
+func NewCorrelator(cfg config.HoneypotConfig) (*Correlator, error) {
+	c := &Correlator{window: time.Duration(cfg.Window) * time.Second}
+
+	for _, sourceCfg := range cfg.Sources {
+		factoriesMu.RLock()
+		factory, ok := factories[sourceCfg.Format]
+		factoriesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("honeypot source %s: unknown format %q, available: %s",
+				sourceCfg.Name, sourceCfg.Format, strings.Join(Formats(), ", "))
+		}
+
+		source, err := factory(sourceCfg)
+		if err != nil {
+			return nil, fmt.Errorf("honeypot source %s: %w", sourceCfg.Name, err)
+		}
+		c.names = append(c.names, sourceCfg.Name)
+		c.sources = append(c.sources, source)
+	}
+
+	return c, nil
+}
+
+// Sessions returns the total number of honeypot sessions from ip within the
+// window before now. Sources that fail are skipped and reported in the error.
+func (c *Correlator) Sessions(ip string, now time.Time) (int, error) {
+	since := now.Add(-c.window)
+
+	total := 0
+	var failed []string
+	for i, source := range c.sources {
+		n, err := source.Sessions(ip, since)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.names[i], err))
+			continue
+		}
+		total += n
+	}
+
+	if len(failed) > 0 {
+		return total, fmt.Errorf("failed to search honeypot logs: %s", strings.Join(failed, "; "))
+	}
+	return total, nil
+}
+
+// scanLines calls fn for every line mentioning ip in the files matching the
+// pattern, decompressing rotated .gz files. Lines without the IP are skipped
+// before fn so that sources only parse candidate lines.
+func scanLines(pattern, ip string, fn func(line []byte)) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid path pattern: %w", err)
+	}
+
+	needle := []byte(ip)
+	for _, path := range paths {
+		if err := scanFile(path, needle, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, needle []byte, fn func(line []byte)) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open honeypot log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to decompress honeypot log %s: %w", path, err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); bytes.Contains(line, needle) {
+			fn(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read honeypot log %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package honeypot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// openCanaryTime is the layout of OpenCanary's utc_time field
+const openCanaryTime = "2006-01-02 15:04:05.999999"
+
+func init() {
+	Register("opencanary", newOpenCanary)
+}
+
+// openCanary reads the JSON log of the OpenCanary honeypot. It has no
+// session IDs, so each source port connecting to a service counts as one
+// session.
+type openCanary struct {
+	path string
+}
+
+type openCanaryEvent struct {
+	SrcHost string `json:"src_host"`
+	SrcPort int    `json:"src_port"`
+	DstPort int    `json:"dst_port"`
+	UTCTime string `json:"utc_time"`
+}
+
+func newOpenCanary(source config.HoneypotSource) (Source, error) {
+	return &openCanary{path: source.Path}, nil
+}
+
+// Sessions counts the distinct connections made by ip
+func (o *openCanary) Sessions(ip string, since time.Time) (int, error) {
+	sessions := make(map[string]bool)
+	err := scanLines(o.path, ip, func(line []byte) {
+		var event openCanaryEvent
+		if json.Unmarshal(line, &event) != nil || event.SrcHost != ip {
+			return
+		}
+		t, err := time.Parse(openCanaryTime, event.UTCTime)
+		if err != nil || t.Before(since) {
+			return
+		}
+		sessions[fmt.Sprintf("%d:%d", event.DstPort, event.SrcPort)] = true
+	})
+	return len(sessions), err
+}
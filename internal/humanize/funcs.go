@@ -0,0 +1,71 @@
+package humanize
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Funcs returns the template functions of the locale:
+//
+//	{{count 2345}}                     2.3k
+//	{{number 12345}}                   12,345
+//	{{plural .Failures "try" "tries"}} 5 tries
+//	{{duration .BanTime}}              1 hour (seconds or a time.Duration; "permanent" if negative)
+//	{{ago .Time}}                      3 hours ago
+//	{{date .Time}}                     Jan 2, 2006
+//	{{datetime .Time}}                 Jan 2, 2006 3:04 PM
+func (l Locale) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"count": func(n interface{}) (string, error) {
+			i, err := toInt(n)
+			return l.Count(i), err
+		},
+		"number": func(n interface{}) (string, error) {
+			if f, ok := n.(float64); ok {
+				return l.Float(f, 1), nil
+			}
+			i, err := toInt(n)
+			return l.Number(i), err
+		},
+		"plural": func(n interface{}, singular, plural string) (string, error) {
+			i, err := toInt(n)
+			return l.Plural(i, singular, plural), err
+		},
+		"duration": func(d interface{}) (string, error) {
+			if duration, ok := d.(time.Duration); ok {
+				return l.Duration(duration), nil
+			}
+			seconds, err := toInt(d)
+			if seconds < 0 {
+				return "permanent", err
+			}
+			return l.Duration(time.Duration(seconds) * time.Second), err
+		},
+		"ago":      func(t time.Time) string { return l.Ago(t, time.Now()) },
+		"date":     l.FormatDate,
+		"datetime": l.FormatDateTime,
+	}
+}
+
+// toInt converts the numbers templates pass
+func toInt(n interface{}) (int64, error) {
+	switch v := n.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("not a number: %v", n)
+	}
+}
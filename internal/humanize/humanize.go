@@ -0,0 +1,213 @@
+// Package humanize formats numbers, durations and dates for people: "2.3k"
+// bans, banned "3 hours ago", and dates in the order and with the
+// separators of a locale. The same functions are available to message and
+// transform templates.
+package humanize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the conventions of a language and region
+type Locale struct {
+	Name      string // BCP 47 tag, e.g. "de-DE"
+	Decimal   string // Decimal separator
+	Thousands string // Digit group separator
+	Date      string // Go time layout of dates
+	DateTime  string // Go time layout of dates with a time
+}
+
+// locales are the supported locales. The first locale of a language is used
+// when only the language is given.
+var locales = []Locale{
+	{Name: "en-US", Decimal: ".", Thousands: ",", Date: "Jan 2, 2006", DateTime: "Jan 2, 2006 3:04 PM"},
+	{Name: "en-GB", Decimal: ".", Thousands: ",", Date: "2 Jan 2006", DateTime: "2 Jan 2006 15:04"},
+	{Name: "cs-CZ", Decimal: ",", Thousands: " ", Date: "2. 1. 2006", DateTime: "2. 1. 2006 15:04"},
+	{Name: "de-DE", Decimal: ",", Thousands: ".", Date: "02.01.2006", DateTime: "02.01.2006 15:04"},
+	{Name: "es-ES", Decimal: ",", Thousands: ".", Date: "02/01/2006", DateTime: "02/01/2006 15:04"},
+	{Name: "fr-FR", Decimal: ",", Thousands: " ", Date: "02/01/2006", DateTime: "02/01/2006 15:04"},
+	{Name: "it-IT", Decimal: ",", Thousands: ".", Date: "02/01/2006", DateTime: "02/01/2006 15:04"},
+	{Name: "ja-JP", Decimal: ".", Thousands: ",", Date: "2006/01/02", DateTime: "2006/01/02 15:04"},
+	{Name: "nl-NL", Decimal: ",", Thousands: ".", Date: "02-01-2006", DateTime: "02-01-2006 15:04"},
+	{Name: "pl-PL", Decimal: ",", Thousands: " ", Date: "02.01.2006", DateTime: "02.01.2006 15:04"},
+	{Name: "pt-BR", Decimal: ",", Thousands: ".", Date: "02/01/2006", DateTime: "02/01/2006 15:04"},
+	{Name: "ru-RU", Decimal: ",", Thousands: " ", Date: "02.01.2006", DateTime: "02.01.2006 15:04"},
+	{Name: "sk-SK", Decimal: ",", Thousands: " ", Date: "2. 1. 2006", DateTime: "2. 1. 2006 15:04"},
+	{Name: "sv-SE", Decimal: ",", Thousands: " ", Date: "2006-01-02", DateTime: "2006-01-02 15:04"},
+}
+
+// Default returns the locale used when none is configured, en-US
+func Default() Locale {
+	return locales[0]
+}
+
+// Lookup finds a locale by tag, e.g. "de-DE", "de_DE.UTF-8" or "de". The C
+// and POSIX locales map to the default.
+func Lookup(name string) (Locale, bool) {
+	name, _, _ = strings.Cut(name, ".")
+	name = strings.ReplaceAll(name, "_", "-")
+	if name == "" || name == "C" || name == "POSIX" {
+		return Default(), true
+	}
+
+	for _, l := range locales {
+		if strings.EqualFold(l.Name, name) {
+			return l, true
+		}
+	}
+	language, _, _ := strings.Cut(name, "-")
+	for _, l := range locales {
+		if prefix, _, _ := strings.Cut(l.Name, "-"); strings.EqualFold(prefix, language) {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+// Names returns the tags of the supported locales
+func Names() []string {
+	names := make([]string, 0, len(locales))
+	for _, l := range locales {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// Number formats an integer with digit groups, e.g. 12,345
+func (l Locale) Number(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String()
+}
+
+// Float formats a number with digit groups and the given decimals
+func (l Locale) Float(f float64, decimals int) string {
+	text := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(text, ".")
+	n, _ := strconv.ParseInt(whole, 10, 64)
+
+	formatted := l.Number(n)
+	if fraction != "" {
+		formatted += l.Decimal + fraction
+	}
+	if f < 0 && strings.Trim(text, "0.") != "" {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+// countUnits are the suffixes of Count, largest first
+var countUnits = []struct {
+	size   float64
+	suffix string
+}{
+	{1e9, "B"},
+	{1e6, "M"},
+	{1e3, "k"},
+}
+
+// Count abbreviates large numbers to one decimal, e.g. 2.3k or 1.5M. Numbers
+// below a thousand are written out.
+func (l Locale) Count(n int64) string {
+	abs := math.Abs(float64(n))
+	for i, unit := range countUnits {
+		if abs < unit.size {
+			continue
+		}
+		scaled := math.Round(abs/unit.size*10) / 10
+		// 999,950 rounds to 1000.0k, which reads better as 1M
+		if i > 0 && scaled >= 1000 {
+			unit = countUnits[i-1]
+			scaled = math.Round(abs/unit.size*10) / 10
+		}
+		text := l.Float(scaled, 1)
+		text = strings.TrimSuffix(text, l.Decimal+"0")
+		if n < 0 {
+			text = "-" + text
+		}
+		return text + unit.suffix
+	}
+	return l.Number(n)
+}
+
+// durationUnits are the units of Duration, largest first
+var durationUnits = []struct {
+	size time.Duration
+	name string
+}{
+	{365 * 24 * time.Hour, "year"},
+	{30 * 24 * time.Hour, "month"},
+	{7 * 24 * time.Hour, "week"},
+	{24 * time.Hour, "day"},
+	{time.Hour, "hour"},
+	{time.Minute, "minute"},
+	{time.Second, "second"},
+}
+
+// Duration writes a duration in its largest whole unit, e.g. "3 hours" or
+// "1 week"
+func (l Locale) Duration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	for _, unit := range durationUnits {
+		if d >= unit.size {
+			n := int64(d / unit.size)
+			return l.Plural(n, unit.name, unit.name+"s")
+		}
+	}
+	return l.Plural(0, "second", "seconds")
+}
+
+// justNow is how close a time must be to now to be written as "just now"
+const justNow = 10 * time.Second
+
+// Ago writes a time relative to now, e.g. "3 hours ago", "in 5 minutes" or
+// "just now"
+func (l Locale) Ago(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case t.IsZero():
+		return "never"
+	case d > -justNow && d < justNow:
+		return "just now"
+	case d > 0:
+		return l.Duration(d) + " ago"
+	default:
+		return "in " + l.Duration(d)
+	}
+}
+
+// Plural writes a count with the singular or plural form of a word, e.g.
+// "1 ban" or "2,300 bans"
+func (l Locale) Plural(n int64, singular, plural string) string {
+	if n == 1 || n == -1 {
+		return fmt.Sprintf("%s %s", l.Number(n), singular)
+	}
+	return fmt.Sprintf("%s %s", l.Number(n), plural)
+}
+
+// FormatDate writes the date of t in the local time zone
+func (l Locale) FormatDate(t time.Time) string {
+	return t.Local().Format(l.Date)
+}
+
+// FormatDateTime writes the date and time of t in the local time zone
+func (l Locale) FormatDateTime(t time.Time) string {
+	return t.Local().Format(l.DateTime)
+}
@@ -0,0 +1,97 @@
+package logins
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+func init() {
+	Register("auth_log", newAuthLog)
+}
+
+// authLogin is a successful login message of a service and the groups
+// naming the user and the address
+type authLogin struct {
+	service string
+	re      *regexp.Regexp
+}
+
+var authLogins = []authLogin{
+	// Accepted publickey for root from 203.0.113.9 port 52144 ssh2: ...
+	{"sshd", regexp.MustCompile(`sshd(?:-session)?\[\d+\]: Accepted \S+ for (?P<user>\S+) from (?P<ip>\S+) port \d+`)},
+	// imap-login: Login: user=<alice>, method=PLAIN, rip=203.0.113.9, ...
+	{"dovecot", regexp.MustCompile(`dovecot(?:\[\d+\])?: (?:\S+-login): Login: user=<(?P<user>[^>]*)>.*? rip=(?P<ip>[^,\s]+)`)},
+}
+
+// authLog reads the syslog authentication log, e.g. /var/log/auth.log or
+// /var/log/secure
+type authLog struct {
+	path string
+}
+
+func newAuthLog(source config.LoginSource) (Source, error) {
+	return &authLog{path: source.Path}, nil
+}
+
+// Logins returns the accepted sshd and dovecot logins from the network
+func (a *authLog) Logins(network *net.IPNet, since, until time.Time) ([]types.Login, error) {
+	var found []types.Login
+	err := scanLines(a.path, func(line string) {
+		if !strings.Contains(line, "Accepted") && !strings.Contains(line, "Login:") {
+			return
+		}
+		for _, login := range authLogins {
+			match := login.re.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			ip := match[login.re.SubexpIndex("ip")]
+			t, ok := syslogTime(line, until)
+			if !ok || !within(network, ip, t, since, until) {
+				return
+			}
+			found = append(found, types.Login{
+				User:    match[login.re.SubexpIndex("user")],
+				IP:      ip,
+				Time:    t,
+				Service: login.service,
+			})
+			return
+		}
+	})
+	return found, err
+}
+
+// syslogTime parses the timestamp starting a syslog line: RFC 3339 as
+// written by rsyslog's high-precision format and journalctl -o short-iso,
+// or the traditional "Oct 16 09:08:32" in local time. The traditional
+// format has no year; it is the year of ref, or the one before for
+// timestamps after ref.
+func syslogTime(line string, ref time.Time) (time.Time, bool) {
+	if field, _, found := strings.Cut(line, " "); found {
+		if t, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse("2006-01-02T15:04:05-0700", field); err == nil {
+			return t, true
+		}
+	}
+
+	if len(line) < len(time.Stamp) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = time.Date(ref.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	if t.After(ref.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, true
+}
@@ -0,0 +1,216 @@
+// Package logins looks for successful logins from a banned IP or its network
+// shortly before the ban. Each record of logins is a Source registered under
+// its format, like the honeypot logs, so further ones can be added without
+// touching the correlation logic.
+package logins
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// maxLogins bounds the logins attached to an event
+const maxLogins = 10
+
+// maxLineSize bounds a single log line
+const maxLineSize = 1024 * 1024
+
+// Source searches one record of logins
+type Source interface {
+	// Logins returns the successful logins from the network between since
+	// and until
+	Logins(network *net.IPNet, since, until time.Time) ([]types.Login, error)
+}
+
+// Factory creates a Source for a configured record
+type Factory func(source config.LoginSource) (Source, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a format available to the logins configuration
+func Register(format string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[format] = factory
+}
+
+// Formats returns the names of the registered formats
+func Formats() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Correlator searches all configured records of logins
+type Correlator struct {
+	window     time.Duration
+	ipv4Prefix int
+	ipv6Prefix int
+	names      []string
+	sources    []Source
+}
+
+// NewCorrelator creates the sources of the logins configuration
+func NewCorrelator(cfg config.LoginsConfig) (*Correlator, error) {
+	c := &Correlator{
+		window:     time.Duration(cfg.Window) * time.Second,
+		ipv4Prefix: cfg.IPv4Prefix,
+		ipv6Prefix: cfg.IPv6Prefix,
+	}
+
+	for _, sourceCfg := range cfg.Sources {
+		factoriesMu.RLock()
+		factory, ok := factories[sourceCfg.Format]
+		factoriesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("logins source %s: unknown format %q, available: %s",
+				sourceCfg.Name, sourceCfg.Format, strings.Join(Formats(), ", "))
+		}
+
+		source, err := factory(sourceCfg)
+		if err != nil {
+			return nil, fmt.Errorf("logins source %s: %w", sourceCfg.Name, err)
+		}
+		c.names = append(c.names, sourceCfg.Name)
+		c.sources = append(c.sources, source)
+	}
+
+	return c, nil
+}
+
+// Logins returns the successful logins from ip or its network within the
+// window before the ban, most recent first. Sources that fail are skipped
+// and reported in the error.
+func (c *Correlator) Logins(ip string, banTime time.Time) ([]types.Login, error) {
+	network := c.network(ip)
+	if network == nil {
+		return nil, nil
+	}
+	since := banTime.Add(-c.window)
+
+	var found []types.Login
+	var failed []string
+	for i, source := range c.sources {
+		logins, err := source.Logins(network, since, banTime)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.names[i], err))
+		}
+		for _, login := range logins {
+			// User names come from logs and remote services
+			login.User = sanitize.Line(login.User)
+			login.Service = sanitize.Line(login.Service)
+			login.Source = c.names[i]
+			found = append(found, login)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Time.After(found[j].Time)
+	})
+	if len(found) > maxLogins {
+		found = found[:maxLogins]
+	}
+
+	if len(failed) > 0 {
+		return found, fmt.Errorf("failed to search logins: %s", strings.Join(failed, "; "))
+	}
+	return found, nil
+}
+
+// network returns the network of ip searched for logins, nil if ip is not
+// an address
+func (c *Correlator) network(ip string) *net.IPNet {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		mask := net.CIDRMask(c.ipv4Prefix, 32)
+		return &net.IPNet{IP: v4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(c.ipv6Prefix, 128)
+	return &net.IPNet{IP: parsed.Mask(mask), Mask: mask}
+}
+
+// within reports whether a login at t from ip falls in the network and
+// between since and until
+func within(network *net.IPNet, ip string, t, since, until time.Time) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && network.Contains(parsed) && !t.Before(since) && !t.After(until)
+}
+
+// openFiles calls fn with a reader for every file matching the pattern,
+// decompressing rotated .gz files
+func openFiles(pattern string, fn func(path string, reader io.Reader) error) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid path pattern: %w", err)
+	}
+
+	for _, path := range paths {
+		if err := openFile(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openFile(path string, fn func(path string, reader io.Reader) error) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		reader = gz
+	}
+	return fn(path, reader)
+}
+
+// scanLines calls fn for every line of the files matching the pattern
+func scanLines(pattern string, fn func(line string)) error {
+	return openFiles(pattern, func(path string, reader io.Reader) error {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			fn(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return nil
+	})
+}
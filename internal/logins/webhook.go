@@ -0,0 +1,96 @@
+package logins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+func init() {
+	Register("webhook", newWebhook)
+}
+
+// webhook asks an external service, such as an identity provider or a SIEM,
+// for the logins. It is sent
+//
+//	GET <url>?ip=203.0.113.9&network=203.0.113.0/24&since=<RFC 3339>&until=<RFC 3339>
+//
+// and answers {"logins": [{"user": "alice", "ip": "203.0.113.9", "time": "<RFC 3339>", "service": "vpn"}]}.
+type webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+type webhookResponse struct {
+	Logins []types.Login `json:"logins"`
+}
+
+func newWebhook(source config.LoginSource) (Source, error) {
+	if source.URL == "" {
+		return nil, fmt.Errorf("url cannot be empty")
+	}
+	return &webhook{
+		url:     source.URL,
+		headers: source.Headers,
+		client:  &http.Client{Timeout: time.Duration(source.Timeout) * time.Second},
+	}, nil
+}
+
+// Logins returns the logins the service reports for the network
+func (w *webhook) Logins(network *net.IPNet, since, until time.Time) ([]types.Login, error) {
+	target, err := url.Parse(w.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	query := target.Query()
+	query.Set("ip", network.IP.String())
+	query.Set("network", network.String())
+	query.Set("since", since.UTC().Format(time.RFC3339))
+	query.Set("until", until.UTC().Format(time.RFC3339))
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var response webhookResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	// Services may answer more broadly than asked
+	var found []types.Login
+	for _, login := range response.Logins {
+		if within(network, login.IP, login.Time, since, until) {
+			found = append(found, login)
+		}
+	}
+	return found, nil
+}
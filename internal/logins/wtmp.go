@@ -0,0 +1,98 @@
+package logins
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+func init() {
+	Register("wtmp", newWtmp)
+}
+
+// Layout of a glibc utmp record on Linux, the same on 32 and 64 bit
+const (
+	utmpSize       = 384
+	utmpUserOffset = 44
+	utmpLineOffset = 8
+	utmpHostOffset = 76
+	utmpTimeOffset = 340
+	utmpAddrOffset = 348
+	utmpUserSize   = 32
+	utmpLineSize   = 32
+	utmpHostSize   = 256
+
+	// userProcess is the ut_type of a login
+	userProcess = 7
+)
+
+// wtmp reads the binary login records of /var/log/wtmp, which include
+// logins of any service going through PAM sessions
+type wtmp struct {
+	path string
+}
+
+func newWtmp(source config.LoginSource) (Source, error) {
+	return &wtmp{path: source.Path}, nil
+}
+
+// Logins returns the recorded logins from the network
+func (w *wtmp) Logins(network *net.IPNet, since, until time.Time) ([]types.Login, error) {
+	var found []types.Login
+	err := openFiles(w.path, func(path string, reader io.Reader) error {
+		record := make([]byte, utmpSize)
+		for {
+			if _, err := io.ReadFull(reader, record); err != nil {
+				if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					return nil
+				}
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if binary.LittleEndian.Uint16(record) != userProcess {
+				continue
+			}
+
+			ip := utmpAddr(record)
+			t := time.Unix(int64(binary.LittleEndian.Uint32(record[utmpTimeOffset:])), 0)
+			if !within(network, ip, t, since, until) {
+				continue
+			}
+			found = append(found, types.Login{
+				User:    cString(record[utmpUserOffset : utmpUserOffset+utmpUserSize]),
+				IP:      ip,
+				Time:    t,
+				Service: cString(record[utmpLineOffset : utmpLineOffset+utmpLineSize]),
+			})
+		}
+	})
+	return found, err
+}
+
+// utmpAddr returns the remote address of a record. The address field holds
+// an IPv4 address in its first word only; records without it fall back to
+// the host name field, which sshd fills with the address.
+func utmpAddr(record []byte) string {
+	addr := record[utmpAddrOffset : utmpAddrOffset+net.IPv6len]
+	switch {
+	case bytes.Equal(addr[4:], make([]byte, 12)) && !bytes.Equal(addr[:4], make([]byte, 4)):
+		return net.IP(addr[:4]).String()
+	case !bytes.Equal(addr, make([]byte, net.IPv6len)):
+		return net.IP(addr).String()
+	}
+	return cString(record[utmpHostOffset : utmpHostOffset+utmpHostSize])
+}
+
+// cString returns the NUL-terminated string in b
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,278 @@
+package lsm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Logs searched for denials, in order
+var DenialLogs = []string{
+	"/var/log/audit/audit.log",
+	"/var/log/kern.log",
+	"/var/log/syslog",
+	"/var/log/messages",
+}
+
+// Denial search limits
+const (
+	denialLogTail = 2 << 20 // Bytes read from the end of each log
+	recentWindow  = 10 * time.Minute
+)
+
+// Denial is an access denied by SELinux or AppArmor
+type Denial struct {
+	Module      string    `json:"module"`
+	Time        time.Time `json:"time"`
+	Permissions string    `json:"permissions"`      // e.g. "execute" or "x"
+	Comm        string    `json:"comm,omitempty"`   // Command that was denied
+	Name        string    `json:"name,omitempty"`   // File or object name
+	Source      string    `json:"source,omitempty"` // SELinux scontext or AppArmor profile
+	Target      string    `json:"target,omitempty"` // SELinux tcontext
+	Class       string    `json:"class,omitempty"`  // SELinux tclass or AppArmor operation
+	Permissive  bool      `json:"permissive"`       // Logged but not enforced
+}
+
+var (
+	// auditFields matches key=value and key="value" pairs of audit records
+	auditFields = regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`)
+	// avcPermissions matches the "{ execute read }" list of SELinux denials
+	avcPermissions = regexp.MustCompile(`avc:\s+denied\s+\{([^}]*)\}`)
+	// auditTime matches the audit(1697040000.123:456) timestamp
+	auditTime = regexp.MustCompile(`audit\((\d+)\.(\d+):\d+\)`)
+)
+
+// ParseDenial parses an audit or kernel log line. It returns nil if the
+// line is not an SELinux or AppArmor denial.
+func ParseDenial(line string) *Denial {
+	var d *Denial
+	switch {
+	case strings.Contains(line, "avc:") && strings.Contains(line, "denied"):
+		m := avcPermissions.FindStringSubmatch(line)
+		if m == nil {
+			return nil
+		}
+		d = &Denial{Module: SELinux, Permissions: strings.TrimSpace(m[1])}
+	case strings.Contains(line, `apparmor="DENIED"`):
+		d = &Denial{Module: AppArmor}
+	default:
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, m := range auditFields.FindAllStringSubmatch(line, -1) {
+		fields[m[1]] = strings.Trim(m[2], `"`)
+	}
+
+	d.Comm = fields["comm"]
+	d.Name = fields["name"]
+	if d.Module == SELinux {
+		d.Source = fields["scontext"]
+		d.Target = fields["tcontext"]
+		d.Class = fields["tclass"]
+		d.Permissive = fields["permissive"] == "1"
+	} else {
+		d.Source = fields["profile"]
+		d.Class = fields["operation"]
+		d.Permissions = fields["denied_mask"]
+	}
+
+	if m := auditTime.FindStringSubmatch(line); m != nil {
+		sec, _ := strconv.ParseInt(m[1], 10, 64)
+		msec, _ := strconv.ParseInt(m[2], 10, 64)
+		d.Time = time.Unix(sec, msec*int64(time.Millisecond))
+	}
+
+	return d
+}
+
+// Matches reports whether the denial concerns the file at path
+func (d *Denial) Matches(path string) bool {
+	base := filepath.Base(path)
+	switch d.Name {
+	case path, base:
+		return true
+	}
+	// comm is truncated to 15 characters by the kernel
+	if len(base) > 15 {
+		base = base[:15]
+	}
+	return d.Comm == base
+}
+
+// String describes the denial
+func (d *Denial) String() string {
+	var b strings.Builder
+	if d.Module == SELinux {
+		fmt.Fprintf(&b, "SELinux denied { %s } on %s", d.Permissions, d.object())
+		if d.Source != "" {
+			fmt.Fprintf(&b, " (scontext=%s tcontext=%s tclass=%s)", d.Source, d.Target, d.Class)
+		}
+	} else {
+		fmt.Fprintf(&b, "AppArmor denied %s (%s) on %s", d.Class, d.Permissions, d.object())
+		if d.Source != "" {
+			fmt.Fprintf(&b, " for profile %s", d.Source)
+		}
+	}
+	if d.Permissive {
+		b.WriteString(", permissive")
+	}
+	return b.String()
+}
+
+// object names what was denied
+func (d *Denial) object() string {
+	switch {
+	case d.Name != "" && d.Comm != "":
+		return fmt.Sprintf("%s by %s", d.Name, d.Comm)
+	case d.Name != "":
+		return d.Name
+	case d.Comm != "":
+		return d.Comm
+	}
+	return "an unknown object"
+}
+
+// Suggestion returns how to allow the denied access for a script at path
+func (d *Denial) Suggestion(path string) string {
+	if d.Module == AppArmor {
+		profile := d.Source
+		if profile == "" {
+			profile = "the profile"
+		}
+		return fmt.Sprintf("allow it in %s, e.g. add \"%s rix,\" and reload with apparmor_parser -r, "+
+			"or run aa-logprof to update the profile from the log", profile, path)
+	}
+
+	var hints []string
+	if strings.Contains(d.Permissions, "execute") && d.Class == "file" && contextType(d.Target) != "bin_t" {
+		dir := filepath.Dir(path)
+		hints = append(hints, fmt.Sprintf("label the connectors as executables: semanage fcontext -a -t bin_t '%s(/.*)?' && restorecon -Rv %s", dir, dir))
+	}
+	hints = append(hints,
+		"build a local policy module: ausearch -m avc -ts recent | audit2allow -M fail2ban-notify && semodule -i fail2ban-notify.pp",
+		"or run scripts in a permitted domain with selinux_context")
+	return strings.Join(hints, "; ")
+}
+
+// contextType returns the type of an SELinux context
+func contextType(context string) string {
+	parts := strings.Split(context, ":")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// RecentDenials returns the denials logged since the given time, oldest
+// first. Logs that don't exist or can't be read are skipped.
+func RecentDenials(since time.Time) []*Denial {
+	var denials []*Denial
+	seen := make(map[string]bool)
+
+	for _, path := range DenialLogs {
+		lines, err := tailLines(path, denialLogTail)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			d := ParseDenial(line)
+			if d == nil || (!d.Time.IsZero() && d.Time.Before(since)) {
+				continue
+			}
+			// The same record often reaches both audit.log and syslog
+			key := d.String() + d.Time.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			denials = append(denials, d)
+		}
+	}
+
+	return denials
+}
+
+// tailLines returns the lines in the last size bytes of a file
+func tailLines(path string, size int64) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	skipFirst := false
+	if info.Size() > size {
+		if _, err := file.Seek(-size, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		skipFirst = true // Partial line
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if skipFirst {
+			skipFirst = false
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// PermissionError reports whether a failed script execution looks like a
+// permission problem: exec failing with EACCES or EPERM, the shell's exit
+// status 126, or "Permission denied" in the output
+func PermissionError(err error, stderr string) bool {
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		return true
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 126 {
+		return true
+	}
+	return strings.Contains(stderr, "Permission denied") || strings.Contains(stderr, "Operation not permitted")
+}
+
+// Diagnose explains why executing the script at path may have been denied.
+// It returns an empty string if no security module is enforcing.
+func Diagnose(path string) string {
+	status := Detect()
+	if !status.Active() || !status.Enforcing {
+		return ""
+	}
+
+	var found *Denial
+	for _, d := range RecentDenials(time.Now().Add(-recentWindow)) {
+		if !d.Permissive && d.Matches(path) {
+			found = d
+		}
+	}
+
+	if found != nil {
+		return fmt.Sprintf("%s; to fix: %s", found, found.Suggestion(path))
+	}
+
+	if status.Module == SELinux {
+		return fmt.Sprintf("SELinux is enforcing (context %s, script label %s); if this is a denial, "+
+			"check ausearch -m avc -ts recent and see fail2ban-notify diagnose", status.Context, FileLabel(path))
+	}
+	return fmt.Sprintf("AppArmor is enforcing (profile %s); if this is a denial, check the kernel log "+
+		"for apparmor=\"DENIED\" and see fail2ban-notify diagnose", status.Context)
+}
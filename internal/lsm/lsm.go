@@ -0,0 +1,97 @@
+// Package lsm detects SELinux and AppArmor and explains the denials they log
+// when connector scripts cannot be executed
+package lsm
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Security modules
+const (
+	SELinux  = "selinux"
+	AppArmor = "apparmor"
+)
+
+// Paths read to detect the security modules
+const (
+	selinuxEnforce  = "/sys/fs/selinux/enforce"
+	apparmorEnabled = "/sys/module/apparmor/parameters/enabled"
+	currentAttr     = "/proc/self/attr/current"
+)
+
+// Status describes the active security module
+type Status struct {
+	Module    string `json:"module,omitempty"`  // SELinux, AppArmor or empty if none is active
+	Enforcing bool   `json:"enforcing"`         // SELinux enforcing; AppArmor always enforces loaded profiles
+	Context   string `json:"context,omitempty"` // SELinux context or AppArmor profile of this process
+	Confined  bool   `json:"confined"`          // Whether this process is restricted by a policy
+}
+
+// Active reports whether a security module is active
+func (s *Status) Active() bool {
+	return s.Module != ""
+}
+
+// Detect returns the status of SELinux or AppArmor on this system
+func Detect() *Status {
+	status := &Status{}
+
+	if data, err := os.ReadFile(selinuxEnforce); err == nil {
+		status.Module = SELinux
+		status.Enforcing = strings.TrimSpace(string(data)) == "1"
+		status.Context = currentContext()
+		// unconfined_t and initrc contexts are not restricted by policy
+		status.Confined = status.Context != "" && !strings.Contains(status.Context, ":unconfined_t:")
+		return status
+	}
+
+	if data, err := os.ReadFile(apparmorEnabled); err == nil && strings.TrimSpace(string(data)) == "Y" {
+		status.Module = AppArmor
+		status.Enforcing = true
+		status.Context = currentContext()
+		status.Confined = status.Context != "" && status.Context != "unconfined"
+		if strings.HasSuffix(status.Context, "(complain)") {
+			status.Enforcing = false
+		}
+	}
+
+	return status
+}
+
+// currentContext returns the SELinux context or AppArmor profile of this
+// process
+func currentContext() string {
+	data, err := os.ReadFile(currentAttr)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+}
+
+// FileLabel returns the SELinux label of a file, or an empty string if it
+// has none
+func FileLabel(path string) string {
+	buf := make([]byte, 256)
+	n, err := syscall.Getxattr(path, "security.selinux", buf)
+	if err != nil || n <= 0 {
+		return ""
+	}
+	return strings.TrimRight(string(buf[:n]), "\x00")
+}
+
+// ValidContext reports whether context looks like an SELinux context
+// (user:role:type with an optional level)
+func ValidContext(context string) bool {
+	parts := strings.SplitN(context, ":", 4)
+	if len(parts) < 3 {
+		return false
+	}
+	for _, part := range parts[:3] {
+		if part == "" || strings.ContainsAny(part, " \t") {
+			return false
+		}
+	}
+	return true
+}
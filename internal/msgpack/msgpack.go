@@ -0,0 +1,338 @@
+// Package msgpack encodes and decodes the subset of MessagePack the Fluent
+// forward protocol uses: nil, booleans, integers, floats, strings, binary,
+// arrays, maps and the EventTime extension
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxLength bounds strings, binaries and containers read from the network
+const maxLength = 1 << 20
+
+// EventTime is a timestamp with nanoseconds, Fluentd's extension type 0
+type EventTime time.Time
+
+// Encoder writes MessagePack values
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded values
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Encode appends v, which may be nil, a bool, an integer, float64, string,
+// []byte, json.Number, EventTime, []interface{}, []string or a map with
+// string keys. Map keys are written in sorted order.
+func (e *Encoder) Encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.encodeInt(int64(v))
+	case int64:
+		e.encodeInt(v)
+	case uint64:
+		e.encodeUint(v)
+	case float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			e.encodeInt(n)
+		} else if f, err := v.Float64(); err == nil {
+			return e.Encode(f)
+		} else {
+			return fmt.Errorf("invalid number %s", v)
+		}
+	case string:
+		e.encodeHeader(len(v), 0xa0, 0x1f, 0xd9, 0xda, 0xdb)
+		e.buf = append(e.buf, v...)
+	case []byte:
+		e.encodeHeader(len(v), 0, 0, 0xc4, 0xc5, 0xc6)
+		e.buf = append(e.buf, v...)
+	case EventTime:
+		t := time.Time(v)
+		e.buf = append(e.buf, 0xd7, 0x00)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Unix()))
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+	case []string:
+		e.encodeHeader(len(v), 0x90, 0x0f, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			_ = e.Encode(item)
+		}
+	case []interface{}:
+		e.encodeHeader(len(v), 0x90, 0x0f, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := e.Encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.encodeHeader(len(v), 0x80, 0x0f, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			_ = e.Encode(key)
+			if err := e.Encode(v[key]); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		return e.Encode(m)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+// encodeHeader writes the type and length of a string, binary or container:
+// the fix type when n fits in fixMax, else the 8, 16 or 32 bit variant.
+// Zero type bytes mark variants the type doesn't have.
+func (e *Encoder) encodeHeader(n int, fix, fixMax, t8, t16, t32 byte) {
+	switch {
+	case fix != 0 && n <= int(fixMax):
+		e.buf = append(e.buf, fix|byte(n))
+	case t8 != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, t8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, t16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, t32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// encodeInt writes n in the smallest integer format
+func (e *Encoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+// encodeUint writes n in the smallest unsigned integer format
+func (e *Encoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+// Decode reads one value. Strings decode to string, binaries to []byte,
+// integers to int64, floats to float64, arrays to []interface{}, maps to
+// map[string]interface{} (with keys formatted when they aren't strings) and
+// extension types to their raw data.
+func Decode(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return decodeMap(r, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return decodeArray(r, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return decodeString(r, int(b&0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readLength(r, b-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readBytes(r, n)
+	case 0xca:
+		v, err := readUint(r, 4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := readUint(r, 8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := readUint(r, 1<<(b-0xcc))
+		return int64(v), err
+	case 0xd0:
+		v, err := readUint(r, 1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := readUint(r, 2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := readUint(r, 4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := readUint(r, 8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext: type byte and 1 to 16 bytes of data
+		if _, err := r.ReadByte(); err != nil {
+			return nil, err
+		}
+		return readBytes(r, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readLength(r, b-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.ReadByte(); err != nil {
+			return nil, err
+		}
+		return readBytes(r, n)
+	case 0xd9, 0xda, 0xdb:
+		n, err := readLength(r, b-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return decodeString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readLength(r, b-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readLength(r, b-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMap(r, n)
+	}
+	return nil, fmt.Errorf("msgpack: unknown type byte 0x%02x", b)
+}
+
+// readLength reads a 1, 2 or 4 byte length, for size 0, 1 or 2
+func readLength(r *bufio.Reader, size byte) (int, error) {
+	n, err := readUint(r, 1<<size)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxLength {
+		return 0, fmt.Errorf("msgpack: length %d too large", n)
+	}
+	return int(n), nil
+}
+
+// readUint reads a big-endian unsigned integer of n bytes
+func readUint(r *bufio.Reader, n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func readBytes(r *bufio.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func decodeString(r *bufio.Reader, n int) (string, error) {
+	buf, err := readBytes(r, n)
+	return string(buf), err
+}
+
+func decodeArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: array of %d items too large", n)
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func decodeMap(r *bufio.Reader, n int) (map[string]interface{}, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: map of %d entries too large", n)
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			m[s] = value
+		} else {
+			m[fmt.Sprint(key)] = value
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,68 @@
+package pdns
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// circlURL is the CIRCL passive DNS query endpoint
+const circlURL = "https://www.circl.lu/pdns/query/"
+
+// circl queries CIRCL passive DNS, which answers in the passive DNS common
+// output format: one JSON record per line
+type circl struct {
+	username string
+	password string
+	client   *http.Client
+}
+
+type circlRecord struct {
+	RRName   string `json:"rrname"`
+	RRType   string `json:"rrtype"`
+	TimeLast int64  `json:"time_last"` // Seconds
+}
+
+// Records returns the A/AAAA records answering with ip
+func (c *circl) Records(ip string) ([]Record, error) {
+	req, err := http.NewRequest(http.MethodGet, circlURL+url.PathEscape(ip), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("circl request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("circl returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var records []Record
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 4*1024*1024))
+	for scanner.Scan() {
+		var record circlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.RRType != "A" && record.RRType != "AAAA" {
+			continue
+		}
+		records = append(records, Record{Domain: record.RRName, LastSeen: time.Unix(record.TimeLast, 0)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read circl response: %w", err)
+	}
+	return records, nil
+}
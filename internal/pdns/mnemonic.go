@@ -0,0 +1,76 @@
+package pdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mnemonicURL is the Mnemonic passive DNS API endpoint
+const mnemonicURL = "https://api.mnemonic.no/pdns/v3/"
+
+// mnemonicLimit is the number of records requested per lookup
+const mnemonicLimit = 100
+
+// mnemonic queries the Mnemonic passive DNS API. It works without a key at
+// a low rate limit; an Argus API key raises it.
+type mnemonic struct {
+	apiKey string
+	client *http.Client
+}
+
+type mnemonicResponse struct {
+	Data []struct {
+		Query             string `json:"query"`
+		RRType            string `json:"rrtype"`
+		LastSeenTimestamp int64  `json:"lastSeenTimestamp"` // Milliseconds
+	} `json:"data"`
+	Messages []struct {
+		Message string `json:"message"`
+	} `json:"messages"`
+}
+
+// Records returns the A/AAAA records answering with ip
+func (m *mnemonic) Records(ip string) ([]Record, error) {
+	endpoint := fmt.Sprintf("%s%s?limit=%d", mnemonicURL, url.PathEscape(ip), mnemonicLimit)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if m.apiKey != "" {
+		req.Header.Set("Argus-API-Key", m.apiKey)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mnemonic request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result mnemonicResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4*1024*1024)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode mnemonic response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var messages []string
+		for _, msg := range result.Messages {
+			messages = append(messages, msg.Message)
+		}
+		return nil, fmt.Errorf("mnemonic returned status %d: %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+
+	var records []Record
+	for _, record := range result.Data {
+		if rrtype := strings.ToLower(record.RRType); rrtype != "a" && rrtype != "aaaa" {
+			continue
+		}
+		records = append(records, Record{Domain: record.Query, LastSeen: time.UnixMilli(record.LastSeenTimestamp)})
+	}
+	return records, nil
+}
@@ -0,0 +1,116 @@
+// Package pdns looks up the domains a banned IP recently served in a passive
+// DNS database, for context such as "this IP hosted phishing-site.example"
+package pdns
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+)
+
+// cacheFile keeps lookups between invocations to respect rate limits
+const cacheFile = "pdns.json"
+
+// Record is a domain seen resolving to the IP
+type Record struct {
+	Domain   string
+	LastSeen time.Time
+}
+
+// Source is a passive DNS database
+type Source interface {
+	// Records returns the A/AAAA records pointing at ip
+	Records(ip string) ([]Record, error)
+}
+
+// Resolver looks up domains with the configured source and caches them
+type Resolver struct {
+	config config.PassiveDNSConfig
+	source Source
+	cache  *state.IPCache // Encrypted like the store
+}
+
+// NewResolver creates a resolver for the configured source. The cache is
+// keyed by IP, so it is encrypted with the store's encryption.
+func NewResolver(cfg config.PassiveDNSConfig, stateDir string, storeCfg config.StoreConfig) (*Resolver, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var source Source
+	switch cfg.Service {
+	case config.PassiveDNSMnemonic:
+		source = &mnemonic{apiKey: cfg.APIKey, client: client}
+	case config.PassiveDNSCIRCL:
+		source = &circl{username: cfg.Username, password: cfg.Password, client: client}
+	default:
+		return nil, fmt.Errorf("unknown passive DNS service: %s", cfg.Service)
+	}
+
+	ttl := time.Duration(cfg.TTL) * time.Second
+	return &Resolver{
+		config: cfg,
+		source: source,
+		cache:  state.NewIPCache(store.New(storeCfg), filepath.Join(stateDir, cacheFile), ttl),
+	}, nil
+}
+
+// Domains returns the most recently seen domains of ip, up to the limit
+func (r *Resolver) Domains(ip string, now time.Time) ([]string, error) {
+	public, err := state.PublicIP(ip)
+	if err != nil || !public {
+		return nil, err
+	}
+
+	var cached []string
+	if r.cache.Get(ip, now, &cached) {
+		return cached, nil
+	}
+
+	records, err := r.source.Records(ip)
+	if err != nil {
+		return nil, err
+	}
+	domains := topDomains(records, r.config.Limit)
+
+	if err := r.cache.Put(ip, now, domains); err != nil {
+		return domains, fmt.Errorf("failed to cache passive DNS result: %w", err)
+	}
+
+	return domains, nil
+}
+
+// topDomains returns up to limit distinct domains, most recently seen first
+func topDomains(records []Record, limit int) []string {
+	latest := make(map[string]time.Time)
+	for _, record := range records {
+		domain := strings.TrimSuffix(strings.ToLower(record.Domain), ".")
+		if domain == "" {
+			continue
+		}
+		if seen, ok := latest[domain]; !ok || record.LastSeen.After(seen) {
+			latest[domain] = record.LastSeen
+		}
+	}
+
+	domains := make([]string, 0, len(latest))
+	for domain := range latest {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if !latest[domains[i]].Equal(latest[domains[j]]) {
+			return latest[domains[i]].After(latest[domains[j]])
+		}
+		return domains[i] < domains[j]
+	})
+
+	if len(domains) > limit {
+		domains = domains[:limit]
+	}
+	return domains
+}
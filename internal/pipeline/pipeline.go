@@ -0,0 +1,678 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/anonymity"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/artifact"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/campaign"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/connectors" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/forwarded"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/geoip"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/honeypot"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/logins"     //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/pdns"       //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/rbl"        //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/risk"       //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sanitize"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/transform"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/vhost"      //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"           //nolint:depguard
+)
+
+// Event is a ban or unban reported by fail2ban
+type Event struct {
+	IP       string    `json:"ip"`
+	Jail     string    `json:"jail"`
+	Action   string    `json:"action"`
+	Failures int       `json:"failures"`
+	BanTime  int       `json:"bantime,omitempty"`
+	Time     time.Time `json:"time"`
+	Matches  []string  `json:"matches,omitempty"` // Log lines fail2ban matched
+	// Restored marks a ban fail2ban re-applied from its database after a
+	// restart
+	Restored bool `json:"restored,omitempty"`
+	// Coalesced marks an unban summarized by a bulk unban, which is
+	// delivered only to the connectors that don't coalesce unbans
+	Coalesced bool `json:"-"`
+}
+
+// maxMatches is the number of matched log lines kept, the most recent ones
+const maxMatches = 10
+
+// Validate checks that the event has the required fields
+func (e *Event) Validate() error {
+	if e.IP == "" || e.Jail == "" {
+		return fmt.Errorf("ip and jail are required")
+	}
+
+	if net.ParseIP(e.IP) == nil {
+		return fmt.Errorf("invalid IP address: %s", e.IP)
+	}
+
+	if e.Action != types.ActionBan && e.Action != types.ActionUnban {
+		return fmt.Errorf("invalid action: %s (must be '%s' or '%s')", e.Action, types.ActionBan, types.ActionUnban)
+	}
+
+	return nil
+}
+
+// Pipeline turns events into notifications: it enriches them, runs the
+// enabled connectors and keeps per-profile state between events, so the
+// same instance serves a single CLI invocation or a long-running daemon
+type Pipeline struct {
+	cfg    *config.Config
+	logger *log.Logger
+
+	store     *store.Store
+	campaigns *campaign.Tracker // Nil unless campaign clustering is enabled
+	hasher    *privacy.Hasher   // Nil unless privacy mode is enabled
+
+	mu     sync.Mutex
+	stages map[string]*stage
+}
+
+// stage holds the managers for one configuration profile
+type stage struct {
+	cfg        *config.Config
+	geo        *geoip.Manager
+	anonymity  *anonymity.Detector  // Nil unless anonymity detection is enabled
+	pdns       *pdns.Resolver       // Nil unless passive DNS is enabled
+	honeypot   *honeypot.Correlator // Nil unless honeypot correlation is enabled
+	logins     *logins.Correlator   // Nil unless login correlation is enabled
+	connectors *connectors.Manager
+}
+
+// New creates a pipeline for the given configuration
+func New(cfg *config.Config, logger *log.Logger) *Pipeline {
+	if logger == nil {
+		logger = log.New(os.Stderr, "[pipeline] ", log.LstdFlags)
+	}
+
+	p := &Pipeline{
+		cfg:    cfg,
+		logger: logger,
+		stages: make(map[string]*stage),
+	}
+	if cfg.Store.Enabled {
+		p.store = store.New(cfg.Store)
+	}
+	if cfg.Campaigns.Enabled {
+		p.campaigns = campaign.NewTracker(cfg.Campaigns, cfg.Store, cfg.Privacy)
+	}
+	if cfg.Privacy.Enabled() {
+		p.hasher = privacy.NewHasher(cfg.Privacy)
+	}
+	return p
+}
+
+// stageFor returns the managers for the profile the jail is mapped to
+func (p *Pipeline) stageFor(jail string) (*stage, error) {
+	profile := p.cfg.ProfileForJail(jail)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if st, ok := p.stages[profile]; ok {
+		return st, nil
+	}
+
+	cfg := p.cfg
+	if profile != "" {
+		var err error
+		cfg, err = p.cfg.WithProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Debug {
+			p.logger.Printf("Using profile %s for jail %s", profile, jail)
+		}
+	}
+
+	st := &stage{
+		cfg:        cfg,
+		geo:        geoip.NewManager(cfg.GeoIP, p.logger),
+		connectors: connectors.NewManager(cfg, p.logger),
+	}
+	if cfg.Anonymity.Enabled {
+		detector, err := anonymity.NewDetector(cfg.Anonymity, cfg.StateDir, cfg.Store)
+		if err != nil {
+			return nil, err
+		}
+		st.anonymity = detector
+	}
+	if cfg.PassiveDNS.Enabled {
+		resolver, err := pdns.NewResolver(cfg.PassiveDNS, cfg.StateDir, cfg.Store)
+		if err != nil {
+			return nil, err
+		}
+		st.pdns = resolver
+	}
+	if cfg.Honeypot.Enabled {
+		correlator, err := honeypot.NewCorrelator(cfg.Honeypot)
+		if err != nil {
+			return nil, err
+		}
+		st.honeypot = correlator
+	}
+	if cfg.Logins.Enabled {
+		correlator, err := logins.NewCorrelator(cfg.Logins)
+		if err != nil {
+			return nil, err
+		}
+		st.logins = correlator
+	}
+	p.stages[profile] = st
+	return st, nil
+}
+
+// Process enriches the event and delivers it through all enabled connectors.
+// A nil result without error means there was nothing to deliver to.
+func (p *Pipeline) Process(ev *Event) (*types.BatchResult, error) {
+	if err := ev.Validate(); err != nil {
+		return nil, err
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	st, err := p.stageFor(ev.Jail)
+	if err != nil {
+		return nil, err
+	}
+	cfg := st.cfg
+
+	notificationData := p.buildData(st, ev)
+	if cfg.Debug {
+		p.logger.Printf("Processing %s action for IP %s in jail %s (event %s)", ev.Action, ev.IP, ev.Jail, notificationData.EventID)
+	}
+
+	// Apply site-specific tweaks before the event is stored and delivered
+	if len(cfg.Transforms) > 0 {
+		transformed, transformErr := transform.Apply(cfg.Transforms, notificationData)
+		if transformErr != nil {
+			p.logger.Printf("Warning: %v", transformErr)
+		}
+		notificationData = transformed
+	}
+
+	if cfg.Debug {
+		p.logger.Printf("Notification data: %+v", notificationData)
+	}
+
+	// Let responders acknowledge the ban by its event ID
+	if cfg.Ack.Enabled && p.store != nil && notificationData.IsBan() {
+		p.trackAck(cfg, notificationData)
+	}
+
+	// Record the event whether or not any connector delivers it
+	surge := p.record(notificationData)
+
+	// Get enabled connectors
+	enabledConnectors := cfg.GetEnabledConnectors()
+	if len(enabledConnectors) == 0 {
+		p.logger.Printf("Warning: No connectors enabled. Edit the configuration to enable notification services.")
+		return nil, nil
+	}
+
+	if cfg.Debug {
+		p.logger.Printf("Found %d enabled connectors", len(enabledConnectors))
+	}
+
+	// Redeliver notifications queued by earlier failed runs first
+	delivered, failed, flushErr := st.connectors.FlushSpool()
+	if flushErr != nil {
+		p.logger.Printf("Warning: failed to flush spool: %v", flushErr)
+	} else if cfg.Debug && delivered+failed > 0 {
+		p.logger.Printf("Spool flush: %d delivered, %d still failing", delivered, failed)
+	}
+
+	// Execute all enabled connectors. Set after the transforms, which may
+	// replace the data.
+	notificationData.Coalesced = ev.Coalesced
+	batch, err := st.connectors.ExecuteAll(notificationData)
+	p.recordResults(batch)
+
+	// Flag the surge separately from the ban that triggered it
+	if surge != nil {
+		p.deliverSurge(st, surge)
+	}
+	return batch, err
+}
+
+// record assigns the event to a campaign, appends it to the store and
+// refreshes what is derived from the active bans. It returns a surge event if the ban pushed its jail's
+// ban rate over the surge threshold.
+func (p *Pipeline) record(data *types.NotificationData) *types.NotificationData {
+	if p.store == nil {
+		return nil
+	}
+
+	// Assign the campaign first so the stored event includes it
+	if p.campaigns != nil {
+		assigned, err := p.campaigns.Assign(data)
+		if err != nil {
+			p.logger.Printf("Warning: failed to assign campaign: %v", err)
+		}
+		data.Campaign = assigned
+	}
+
+	if err := p.store.Append(p.stored(data)); err != nil {
+		p.logger.Printf("Warning: failed to record event: %v", err)
+		return nil
+	}
+
+	if p.cfg.RBL.Enabled {
+		if err := rbl.WriteZone(&p.cfg.RBL, p.store, data.Time); err != nil {
+			p.logger.Printf("Warning: failed to write RBL zone: %v", err)
+		}
+	}
+
+	if !p.cfg.Surge.Enabled {
+		return nil
+	}
+	surge, err := p.store.RecordRate(&p.cfg.Surge, data)
+	if err != nil {
+		p.logger.Printf("Warning: failed to record ban rate: %v", err)
+		return nil
+	}
+	if surge == nil {
+		return nil
+	}
+
+	event := &types.NotificationData{
+		EventID:  types.NewEventID(),
+		Jail:     data.Jail,
+		Action:   types.ActionSurge,
+		Time:     data.Time,
+		Hostname: data.Hostname,
+		Surge:    surge,
+		Labels:   data.Labels,
+	}
+	p.logger.Printf("Attack surge in jail %s: %d bans in %ds, baseline %.1f (event %s)",
+		event.Jail, surge.Bans, surge.Window, surge.Baseline, event.EventID)
+	if err := p.store.Append(event); err != nil {
+		p.logger.Printf("Warning: failed to record surge: %v", err)
+	}
+	return event
+}
+
+// trackAck sets the link acknowledging a ban and counts the earlier
+// critical bans still waiting for an acknowledgment
+func (p *Pipeline) trackAck(cfg *config.Config, data *types.NotificationData) {
+	data.AckURL = cfg.Ack.URL(data.EventID)
+
+	since := data.Time.Add(-time.Duration(cfg.Ack.Window) * time.Second)
+	pending, err := p.store.Unacknowledged(cfg.AckRequired, since)
+	if err != nil {
+		p.logger.Printf("Warning: failed to count unacknowledged events: %v", err)
+		return
+	}
+	data.Unacknowledged = len(pending)
+}
+
+// recordResults stores the per-connector results of a delivery
+func (p *Pipeline) recordResults(batch *types.BatchResult) {
+	if batch == nil || p.store == nil {
+		return
+	}
+	if p.cfg.Privacy.Store {
+		private := *batch
+		private.NotificationData = *p.stored(&batch.NotificationData)
+		batch = &private
+	}
+	if err := p.store.AppendResults(batch); err != nil {
+		p.logger.Printf("Warning: failed to record connector results: %v", err)
+	}
+}
+
+// stored returns the event as it is kept in the store, with the IP
+// pseudonymized when privacy mode covers the store
+func (p *Pipeline) stored(data *types.NotificationData) *types.NotificationData {
+	if !p.cfg.Privacy.Store {
+		return data
+	}
+	return privacy.Apply(&p.cfg.Privacy, data)
+}
+
+// deliverSurge sends a surge event through the connectors. Failures are
+// logged rather than returned so they don't mask the result of the ban that
+// triggered the surge.
+func (p *Pipeline) deliverSurge(st *stage, surge *types.NotificationData) {
+	batch, err := st.connectors.ExecuteAll(surge)
+	p.recordResults(batch)
+	if err != nil {
+		p.logger.Printf("Warning: failed to deliver surge: %v", err)
+	}
+}
+
+// Active reports whether the store already has the event's IP banned in
+// its jail, as it does for the bans fail2ban re-applies after a restart
+func (p *Pipeline) Active(ev *Event) bool {
+	if p.store == nil {
+		return false
+	}
+	subject := p.stored(&types.NotificationData{IP: ev.IP, Jail: ev.Jail, Action: ev.Action}).Subject()
+	banned, err := p.store.IsBanned(ev.Jail, subject, ev.Time)
+	if err != nil {
+		p.logger.Printf("Warning: failed to check active bans: %v", err)
+		return false
+	}
+	return banned
+}
+
+// DeliverRestore sends the summary of the bans fail2ban re-applied after a
+// restart through the connectors of the default profile
+func (p *Pipeline) DeliverRestore(restore *types.Restore, at time.Time) (*types.BatchResult, error) {
+	st, err := p.stageFor("")
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	event := &types.NotificationData{
+		EventID:  types.NewEventID(),
+		Action:   types.ActionRestore,
+		Time:     at,
+		Hostname: hostname,
+		Restore:  restore,
+		Labels:   st.cfg.LabelsForJail(""),
+	}
+	p.logger.Printf("Restored %d bans after fail2ban restart: %s (event %s)", restore.Bans, restore.JailSummary(), event.EventID)
+	if p.store != nil {
+		if err := p.store.Append(event); err != nil {
+			p.logger.Printf("Warning: failed to record restart summary: %v", err)
+		}
+	}
+
+	batch, err := st.connectors.ExecuteAll(event)
+	p.recordResults(batch)
+	return batch, err
+}
+
+// DeliverBulkUnban sends the summary of a burst of unbans from the jail
+// through the connectors that coalesce unbans
+func (p *Pipeline) DeliverBulkUnban(jail string, bulk *types.BulkUnban, at time.Time) (*types.BatchResult, error) {
+	st, err := p.stageFor(jail)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	event := &types.NotificationData{
+		EventID:   types.NewEventID(),
+		Jail:      jail,
+		Action:    types.ActionBulkUnban,
+		Time:      at,
+		Hostname:  hostname,
+		BulkUnban: bulk,
+		Labels:    st.cfg.LabelsForJail(jail),
+	}
+	p.logger.Printf("%d IPs unbanned from jail %s within %ds (event %s)", bulk.Unbans, jail, bulk.Window, event.EventID)
+	if p.store != nil {
+		if err := p.store.Append(event); err != nil {
+			p.logger.Printf("Warning: failed to record bulk unban: %v", err)
+		}
+	}
+
+	batch, err := st.connectors.ExecuteAll(event)
+	p.recordResults(batch)
+	return batch, err
+}
+
+// buildData creates the notification data for an event
+func (p *Pipeline) buildData(st *stage, ev *Event) *types.NotificationData {
+	cfg := st.cfg
+
+	// Unbans may reuse the enrichment of their ban instead of looking it up
+	enrich := ev.Action != types.ActionUnban || !cfg.SkipUnbanEnrichment
+
+	// Perform GeoIP lookup
+	geoInfo := &geoip.Info{IP: ev.IP}
+	if cfg.GeoIP.Enabled && enrich {
+		info, lookupErr := st.geo.Lookup(ev.IP)
+		if lookupErr != nil {
+			if cfg.Debug {
+				p.logger.Printf("GeoIP lookup failed: %v", lookupErr)
+			}
+			// Continue with empty geo info
+		} else {
+			geoInfo = info
+			if cfg.Debug {
+				p.logger.Printf("GeoIP lookup successful: %s -> %s", ev.IP, geoInfo.Country)
+			}
+		}
+	}
+
+	// Get local hostname
+	hostname, err := os.Hostname()
+	if err != nil {
+		if cfg.Debug {
+			p.logger.Printf("Failed to get hostname: %v", err)
+		}
+		hostname = "unknown"
+	}
+
+	data := &types.NotificationData{
+		EventID:     types.NewEventID(),
+		IP:          ev.IP,
+		Jail:        ev.Jail,
+		Action:      ev.Action,
+		Time:        ev.Time,
+		Country:     geoInfo.Country,
+		CountryCode: geoInfo.CountryCode,
+		Continent:   geoInfo.Continent,
+		Region:      geoInfo.Region,
+		City:        geoInfo.City,
+		ISP:         geoInfo.ISP,
+		ASN:         geoInfo.ASN,
+		Hostname:    hostname, // Local hostname of the server that was attacked
+		Failures:    ev.Failures,
+		BanTime:     ev.BanTime,
+		Timezone:    geoInfo.Timezone,
+		Latitude:    geoInfo.Lat,
+		Longitude:   geoInfo.Lon,
+		Labels:      cfg.LabelsForJail(ev.Jail),
+		Matches:     recentMatches(ev.Matches),
+	}
+
+	// Strip control characters and escape sequences from log-derived fields
+	sanitize.Data(data)
+
+	// Name the attacked site, for servers hosting many domains
+	data.TargetHost = vhost.Find(cfg.TargetHost.Regexps(), data.Matches)
+
+	// Find the client when the banned IP is a reverse proxy
+	if cfg.Proxy.AppliesTo(ev.Jail, ev.IP) && enrich {
+		data.Client = p.resolveClient(st, ev.IP, data.Matches)
+	}
+
+	// Remove usernames, passwords and the like before the lines are sent
+	for i, line := range data.Matches {
+		data.Matches[i] = cfg.Redaction.Redact(line)
+	}
+
+	// Keep a keyed hash of the IP to correlate pseudonymized events
+	if p.hasher != nil {
+		hash, hashErr := p.hasher.Hash(ev.IP)
+		if hashErr != nil {
+			p.logger.Printf("Warning: failed to hash IP: %v", hashErr)
+		}
+		data.IPHash = hash
+	}
+
+	if !enrich && p.store != nil {
+		p.reuseBanEnrichment(data)
+	}
+
+	// Flag VPN, proxy and bot networks for routing rules
+	if st.anonymity != nil && enrich {
+		result, lookupErr := st.anonymity.Lookup(ev.IP, ev.Time)
+		if lookupErr != nil {
+			p.logger.Printf("Warning: anonymity lookup failed: %v", lookupErr)
+		}
+		data.Anonymity = result
+	}
+
+	// Add the domains the IP recently served for context
+	if st.pdns != nil && ev.Action == types.ActionBan {
+		domains, lookupErr := st.pdns.Domains(ev.IP, ev.Time)
+		if lookupErr != nil {
+			p.logger.Printf("Warning: passive DNS lookup failed: %v", lookupErr)
+		}
+		data.Domains = domains
+	}
+
+	// Check whether the IP also probed a local honeypot
+	if st.honeypot != nil && ev.Action == types.ActionBan {
+		sessions, hpErr := st.honeypot.Sessions(ev.IP, ev.Time)
+		if hpErr != nil {
+			p.logger.Printf("Warning: %v", hpErr)
+		}
+		data.HoneypotSessions = sessions
+		if cfg.Debug && sessions > 0 {
+			p.logger.Printf("Honeypot correlation: %s had %d sessions", ev.IP, sessions)
+		}
+	}
+
+	// A successful login shortly before the ban may mean the attacker got in
+	if st.logins != nil && ev.Action == types.ActionBan {
+		found, loginErr := st.logins.Logins(ev.IP, ev.Time)
+		if loginErr != nil {
+			p.logger.Printf("Warning: %v", loginErr)
+		}
+		data.Logins = found
+		if len(found) > 0 {
+			p.logger.Printf("Possible breach: %d successful logins from the network of %s before its ban in %s", len(found), ev.IP, ev.Jail)
+		}
+	}
+
+	// Tell responders whether the IP was banned before
+	if p.store != nil {
+		history, historyErr := p.store.History(data.Subject(), ev.Time)
+		if historyErr != nil {
+			p.logger.Printf("Warning: failed to look up earlier bans: %v", historyErr)
+		}
+		data.History = history
+	}
+
+	// Bans from where the users are may be credential stuffing
+	if cfg.Trusted.Trusts(data) {
+		data.TrustedRegion = true
+		if cfg.Debug {
+			p.logger.Printf("Ban of %s comes from trusted country %s", ev.IP, data.CountryCode)
+		}
+	}
+
+	// Score the ban from the enrichments gathered so far
+	if cfg.Risk.Enabled && ev.Action == types.ActionBan {
+		priorBans := -1
+		if data.History != nil {
+			priorBans = data.History.BanCount
+		}
+		data.RiskScore = risk.Score(&cfg.Risk, data, priorBans)
+		if cfg.Debug {
+			p.logger.Printf("Risk score for %s: %d", ev.IP, data.RiskScore)
+		}
+	}
+
+	// Bundle the log lines that led to the ban
+	if cfg.Artifacts.Enabled && ev.Action == types.ActionBan {
+		bundler := artifact.NewBundler(cfg.Artifacts, cfg.Redaction)
+		artifactPath, bundleErr := bundler.Bundle(data)
+		if bundleErr != nil {
+			p.logger.Printf("Warning: failed to bundle log context: %v", bundleErr)
+		} else {
+			data.Artifact = artifactPath
+			data.ArtifactURL = bundler.URL(artifactPath)
+		}
+		if _, pruneErr := bundler.Prune(time.Now()); pruneErr != nil && cfg.Debug {
+			p.logger.Printf("Failed to prune artifacts: %v", pruneErr)
+		}
+	}
+
+	return data
+}
+
+// reuseBanEnrichment copies the location, network and anonymity of the
+// address from its latest ban in the store
+func (p *Pipeline) reuseBanEnrichment(data *types.NotificationData) {
+	ban, err := p.store.LastBan(data.Subject(), data.Time)
+	if err != nil {
+		p.logger.Printf("Warning: failed to look up the ban of %s: %v", data.IP, err)
+		return
+	}
+	if ban == nil {
+		return
+	}
+	if p.cfg.Debug {
+		p.logger.Printf("Reusing the enrichment of ban %s for the unban of %s", ban.ID(), data.IP)
+	}
+
+	data.Country = ban.Country
+	data.CountryCode = ban.CountryCode
+	data.Continent = ban.Continent
+	data.Region = ban.Region
+	data.City = ban.City
+	data.ISP = ban.ISP
+	data.ASN = ban.ASN
+	data.Timezone = ban.Timezone
+	data.Latitude = ban.Latitude
+	data.Longitude = ban.Longitude
+	data.Anonymity = ban.Anonymity
+}
+
+// resolveClient finds the client a banned proxy forwarded for in the
+// matched lines, with its location if configured
+func (p *Pipeline) resolveClient(st *stage, ip string, matches []string) *types.Client {
+	cfg := st.cfg
+	client := forwarded.Resolve(&cfg.Proxy, ip, matches)
+	if client == nil {
+		return nil
+	}
+	if cfg.Debug {
+		p.logger.Printf("Resolved client %s behind proxy %s from %s", client.IP, ip, client.Header)
+	}
+
+	if cfg.Proxy.GeoIP && cfg.GeoIP.Enabled {
+		info, lookupErr := st.geo.Lookup(client.IP)
+		if lookupErr != nil {
+			if cfg.Debug {
+				p.logger.Printf("GeoIP lookup of client failed: %v", lookupErr)
+			}
+			return client
+		}
+		client.Country = info.Country
+		client.CountryCode = info.CountryCode
+		client.City = info.City
+		client.ISP = info.ISP
+		client.ASN = info.ASN
+	}
+	return client
+}
+
+// recentMatches returns the last maxMatches non-empty lines of the matches
+func recentMatches(matches []string) []string {
+	var lines []string
+	for _, match := range matches {
+		for _, line := range strings.Split(match, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) > maxMatches {
+		lines = lines[len(lines)-maxMatches:]
+	}
+	return lines
+}
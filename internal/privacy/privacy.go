@@ -0,0 +1,165 @@
+// Package privacy pseudonymizes IP addresses for installations that must
+// minimize the personal data sent to chat tools and kept in the event store
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Key and hash sizes in bytes
+const (
+	keyBytes  = 32
+	hashBytes = 8
+)
+
+// Hasher computes the keyed hash of IPs. The key is read, or generated, on
+// first use.
+type Hasher struct {
+	keyFile string
+
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// NewHasher creates a hasher for the key file of the privacy configuration
+func NewHasher(cfg config.PrivacyConfig) *Hasher {
+	return &Hasher{keyFile: cfg.KeyFile}
+}
+
+// Hash returns the keyed HMAC of ip, truncated to 16 hex characters. The
+// same IP always has the same hash, but it can't be reversed without the key.
+func (h *Hasher) Hash(ip string) (string, error) {
+	h.once.Do(func() {
+		h.key, h.err = loadKey(h.keyFile)
+	})
+	if h.err != nil {
+		return "", h.err
+	}
+
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:hashBytes]), nil
+}
+
+// loadKey reads the key file, creating it with a random key if it doesn't
+// exist
+func loadKey(path string) ([]byte, error) {
+	unlock, err := state.Lock(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err == nil {
+		key, decodeErr := hex.DecodeString(strings.TrimSpace(string(data)))
+		if decodeErr != nil || len(key) < 16 {
+			return nil, fmt.Errorf("invalid privacy key in %s, expected at least 32 hex characters", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read privacy key: %w", err)
+	}
+
+	key := make([]byte, keyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate privacy key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), state.FilePermission); err != nil {
+		return nil, fmt.Errorf("failed to write privacy key: %w", err)
+	}
+	return key, nil
+}
+
+// Mask keeps the network part of ip: 1.2.3.x for a /24, or the network such
+// as 2001:db8:1::/48 for IPv6. Values that are not IPs are returned as is.
+func Mask(ip string, ipv4Prefix, ipv6Prefix int) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		octets := strings.Split(v4.String(), ".")
+		for i := ipv4Prefix / 8; i < len(octets); i++ {
+			octets[i] = "x"
+		}
+		return strings.Join(octets, ".")
+	}
+
+	mask := net.CIDRMask(ipv6Prefix, 128)
+	return (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String()
+}
+
+// Apply returns a copy of data with the IP pseudonymized according to the
+// privacy mode, or data itself when privacy is disabled or there is no IP.
+// The hash mode uses data.IPHash and falls back to masking without it, so
+// the real IP is never passed on. Fields that identify the IP by other
+// means, such as its host name, are left out.
+func Apply(cfg *config.PrivacyConfig, data *types.NotificationData) *types.NotificationData {
+	if !cfg.Enabled() || data.IP == "" {
+		return data
+	}
+
+	private := *data
+	if cfg.Mode == config.PrivacyModeHash && data.IPHash != "" {
+		private.IP = data.IPHash
+	} else {
+		private.IP = Mask(data.IP, cfg.IPv4Prefix, cfg.IPv6Prefix)
+	}
+
+	// Reverse DNS names often spell out the IP, passive DNS domains point
+	// back to it, the artifact holds the raw log lines, and the ban history
+	// follows the address across events
+	private.Hostname = ""
+	private.Domains = nil
+	private.Artifact = ""
+	private.ArtifactURL = ""
+	private.History = nil
+
+	// A client behind a proxy has no hash of its own and is always masked
+	var client string
+	if data.Client != nil {
+		masked := *data.Client
+		masked.IP = Mask(data.Client.IP, cfg.IPv4Prefix, cfg.IPv6Prefix)
+		private.Client = &masked
+		client = data.Client.IP
+	}
+
+	// Logins from the network are masked like the client
+	if len(data.Logins) > 0 {
+		private.Logins = make([]types.Login, len(data.Logins))
+		for i, login := range data.Logins {
+			login.IP = Mask(login.IP, cfg.IPv4Prefix, cfg.IPv6Prefix)
+			private.Logins[i] = login
+		}
+	}
+
+	// The matched log lines mention the IPs too
+	if len(data.Matches) > 0 {
+		private.Matches = make([]string, len(data.Matches))
+		for i, line := range data.Matches {
+			line = strings.ReplaceAll(line, data.IP, private.IP)
+			if client != "" {
+				line = strings.ReplaceAll(line, client, private.Client.IP)
+			}
+			private.Matches[i] = line
+		}
+	}
+	return &private
+}
@@ -0,0 +1,157 @@
+package privacy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		ip         string
+		ipv4Prefix int
+		ipv6Prefix int
+		want       string
+	}{
+		{"203.0.113.77", 24, 48, "203.0.113.x"},
+		{"203.0.113.77", 16, 48, "203.0.x.x"},
+		{"203.0.113.77", 8, 48, "203.x.x.x"},
+		{"::ffff:203.0.113.77", 24, 48, "203.0.113.x"},
+		{"2001:db8:1:2:3:4:5:6", 24, 48, "2001:db8:1::/48"},
+		{"2001:db8:1:2:3:4:5:6", 24, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:3:4:5:6", 24, 32, "2001:db8::/32"},
+		{"not-an-ip", 24, 48, "not-an-ip"},
+		{"", 24, 48, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Mask(tt.ip, tt.ipv4Prefix, tt.ipv6Prefix); got != tt.want {
+			t.Errorf("Mask(%q, %d, %d) = %q, want %q", tt.ip, tt.ipv4Prefix, tt.ipv6Prefix, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	const ip = "203.0.113.77"
+	event := func() *types.NotificationData {
+		return &types.NotificationData{
+			IP:          ip,
+			IPHash:      "0123456789abcdef",
+			Jail:        "sshd",
+			Hostname:    "host-203-0-113-77.isp.example",
+			Domains:     []string{"mail.example.org"},
+			Artifact:    "/var/lib/fail2ban-notify/artifacts/" + ip + ".gz",
+			ArtifactURL: "https://f2b.example.com/artifacts/" + ip + ".gz",
+			History:     &types.History{BanCount: 3},
+			Client:      &types.Client{IP: "198.51.100.9"},
+			Logins:      []types.Login{{User: "jane", IP: "2001:db8:1:2::10"}},
+			Matches:     []string{"Failed password for root from " + ip + " via 198.51.100.9"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.PrivacyConfig
+		mutate  func(*types.NotificationData)
+		wantIP  string
+		private bool
+	}{
+		{"disabled", config.PrivacyConfig{}, nil, ip, false},
+		{"mask", config.PrivacyConfig{Mode: config.PrivacyModeMask, IPv4Prefix: 24, IPv6Prefix: 48}, nil, "203.0.113.x", true},
+		{"mask /16", config.PrivacyConfig{Mode: config.PrivacyModeMask, IPv4Prefix: 16, IPv6Prefix: 48}, nil, "203.0.x.x", true},
+		{"hash", config.PrivacyConfig{Mode: config.PrivacyModeHash, IPv4Prefix: 24, IPv6Prefix: 48}, nil, "0123456789abcdef", true},
+		{"hash without a hash falls back to mask", config.PrivacyConfig{Mode: config.PrivacyModeHash, IPv4Prefix: 24, IPv6Prefix: 48},
+			func(d *types.NotificationData) { d.IPHash = "" }, "203.0.113.x", true},
+		{"mask IPv6", config.PrivacyConfig{Mode: config.PrivacyModeMask, IPv4Prefix: 24, IPv6Prefix: 48},
+			func(d *types.NotificationData) {
+				d.IP = "2001:db8:1:2::77"
+				d.Matches = []string{"Failed password from 2001:db8:1:2::77"}
+			}, "2001:db8:1::/48", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := event()
+			if tt.mutate != nil {
+				tt.mutate(data)
+			}
+			original := *data
+			got := Apply(&tt.cfg, data)
+
+			if !reflect.DeepEqual(*data, original) {
+				t.Error("Apply modified its argument")
+			}
+			if got.IP != tt.wantIP {
+				t.Errorf("IP = %q, want %q", got.IP, tt.wantIP)
+			}
+			if !tt.private {
+				if got != data {
+					t.Error("disabled privacy should return the event itself")
+				}
+				return
+			}
+
+			if got.Hostname != "" || got.Domains != nil || got.Artifact != "" || got.ArtifactURL != "" || got.History != nil {
+				t.Errorf("identifying fields kept: hostname %q, domains %v, artifact %q, artifact URL %q, history %v",
+					got.Hostname, got.Domains, got.Artifact, got.ArtifactURL, got.History)
+			}
+			// The client and logins are always masked, even in hash mode
+			if want := Mask("198.51.100.9", tt.cfg.IPv4Prefix, tt.cfg.IPv6Prefix); got.Client.IP != want {
+				t.Errorf("Client.IP = %q, want %q", got.Client.IP, want)
+			}
+			if got.Logins[0].IP != "2001:db8:1::/48" {
+				t.Errorf("Logins[0].IP = %q, want 2001:db8:1::/48", got.Logins[0].IP)
+			}
+			for _, line := range got.Matches {
+				if strings.Contains(line, data.IP) || strings.Contains(line, "198.51.100.9") {
+					t.Errorf("match %q still holds an IP", line)
+				}
+			}
+		})
+	}
+}
+
+// TestHashFailure checks that an unreadable key fails the hash, which leaves
+// IPHash empty so Apply masks the IP
+func TestHashFailure(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "privacy.key")
+	if err := os.WriteFile(keyFile, []byte("not hex\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := NewHasher(config.PrivacyConfig{KeyFile: keyFile})
+	if hash, err := hasher.Hash("203.0.113.77"); err == nil {
+		t.Fatalf("Hash = %q with an invalid key, want an error", hash)
+	}
+
+	cfg := config.PrivacyConfig{Mode: config.PrivacyModeHash, IPv4Prefix: 24, IPv6Prefix: 48}
+	if got := Apply(&cfg, &types.NotificationData{IP: "203.0.113.77"}); got.IP != "203.0.113.x" {
+		t.Errorf("IP = %q, want 203.0.113.x", got.IP)
+	}
+}
+
+func TestHashIsStable(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "privacy.key")
+
+	first, err := NewHasher(config.PrivacyConfig{KeyFile: keyFile}).Hash("203.0.113.77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewHasher(config.PrivacyConfig{KeyFile: keyFile}).Hash("203.0.113.77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewHasher(config.PrivacyConfig{KeyFile: keyFile}).Hash("203.0.113.78")
+
+	if first != second || len(first) != 2*hashBytes {
+		t.Errorf("hashes %q and %q, want the same %d hex characters", first, second, 2*hashBytes)
+	}
+	if first == other {
+		t.Error("different IPs have the same hash")
+	}
+}
@@ -0,0 +1,304 @@
+package rbl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+)
+
+// DNS protocol constants used by the responder
+const (
+	dnsHeaderLen = 12
+	maxUDPSize   = 512
+
+	typeA    = 1
+	typeNS   = 2
+	typeSOA  = 6
+	typeTXT  = 16
+	typeANY  = 255
+	classIN  = 1
+	classANY = 255
+	flagQR   = 0x8000
+	flagAA   = 0x0400
+	flagRD   = 0x0100
+	rcodeOK  = 0
+	rcodeFmt = 1
+	rcodeNX  = 3
+	rcodeNI  = 4
+	rcodeRef = 5
+)
+
+// cacheTTL is how long the responder reuses the active bans it loaded
+const cacheTTL = 5 * time.Second
+
+// Responder is a minimal authoritative DNS server for the RBL zone. It
+// answers A and TXT queries for listed addresses and SOA/NS at the apex.
+type Responder struct {
+	cfg    *config.RBLConfig
+	store  *store.Store
+	logger *log.Logger
+
+	mu       sync.Mutex
+	listed   map[string]*store.Ban
+	loaded   time.Time
+	modified time.Time
+}
+
+// NewResponder creates a responder answering from the active bans in st
+func NewResponder(cfg *config.RBLConfig, st *store.Store, logger *log.Logger) *Responder {
+	return &Responder{cfg: cfg, store: st, logger: logger}
+}
+
+// Serve answers queries on the configured UDP address until ctx is canceled
+func (r *Responder) Serve(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", r.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for DNS: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	r.logger.Printf("Serving RBL zone %s on %s/udp", r.cfg.Zone, r.cfg.Listen)
+
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			r.logger.Printf("DNS read failed: %v", err)
+			continue
+		}
+
+		resp := r.handle(buf[:n], time.Now())
+		if resp != nil {
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// handle builds the response to one query, or nil if it must be dropped
+func (r *Responder) handle(query []byte, now time.Time) []byte {
+	if len(query) < dnsHeaderLen {
+		return nil
+	}
+
+	flags := binary.BigEndian.Uint16(query[2:])
+	if flags&flagQR != 0 {
+		return nil // Not a query
+	}
+
+	respFlags := flagQR | flagAA | (flags & (0x7800 | flagRD)) // Keep opcode and RD
+
+	if opcode := (flags >> 11) & 0x0f; opcode != 0 {
+		return header(query, respFlags|rcodeNI, 0, 0)
+	}
+
+	if binary.BigEndian.Uint16(query[4:]) != 1 {
+		return header(query, respFlags|rcodeFmt, 0, 0)
+	}
+
+	name, end, ok := readName(query, dnsHeaderLen)
+	if !ok || end+4 > len(query) {
+		return header(query, respFlags|rcodeFmt, 0, 0)
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+	qclass := binary.BigEndian.Uint16(query[end+2:])
+	question := query[dnsHeaderLen : end+4]
+
+	zone := r.cfg.Zone
+	lname := strings.ToLower(name)
+	if lname != zone && !strings.HasSuffix(lname, "."+zone) {
+		return withQuestion(header(query, respFlags|rcodeRef, 1, 0), question)
+	}
+
+	var answers [][]byte
+	rcode := uint16(rcodeOK)
+
+	if lname == zone {
+		if qtype == typeSOA || qtype == typeANY {
+			answers = append(answers, r.soaRecord(now))
+		}
+		if qtype == typeNS || qtype == typeANY {
+			answers = append(answers, record(typeNS, r.cfg.TTL, encodeName(r.cfg.NameServer)))
+		}
+	} else {
+		ban := r.lookup(strings.TrimSuffix(lname, "."+zone), now)
+		switch {
+		case ban == nil:
+			rcode = rcodeNX
+		case qclass != classIN && qclass != classANY:
+		default:
+			if qtype == typeA || qtype == typeANY {
+				answers = append(answers, record(typeA, r.cfg.TTL, net.ParseIP(r.cfg.Address).To4()))
+			}
+			if qtype == typeTXT || qtype == typeANY {
+				answers = append(answers, record(typeTXT, r.cfg.TTL, txtData(Reason(ban))))
+			}
+		}
+	}
+
+	resp := withQuestion(header(query, respFlags|rcode, 1, len(answers)), question)
+	for _, answer := range answers {
+		resp = append(resp, answer...)
+	}
+
+	if rcode == rcodeNX || len(answers) == 0 {
+		// Negative answers carry the SOA for caching
+		soa := r.soaRecord(now)
+		resp = append(resp, soa...)
+		binary.BigEndian.PutUint16(resp[8:], 1)
+	}
+
+	return resp
+}
+
+// lookup returns the ban listed under the reversed-IP labels, if any
+func (r *Responder) lookup(labels string, now time.Time) *store.Ban {
+	ip := ParseReverseName(labels)
+	if ip == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modified := r.store.Modified()
+	if r.listed == nil || now.Sub(r.loaded) > cacheTTL || !modified.Equal(r.modified) {
+		bans, err := r.store.ActiveBans(now)
+		if err != nil {
+			r.logger.Printf("Failed to load active bans: %v", err)
+			return nil
+		}
+		r.listed = make(map[string]*store.Ban, len(bans))
+		for i := range bans {
+			if parsed := net.ParseIP(bans[i].IP); parsed != nil {
+				r.listed[parsed.String()] = &bans[i]
+			}
+		}
+		r.loaded = now
+		r.modified = modified
+	}
+
+	ban, ok := r.listed[ip.String()]
+	if !ok || ban.Expired(now) {
+		return nil
+	}
+	return ban
+}
+
+// soaRecord builds the apex SOA record
+func (r *Responder) soaRecord(now time.Time) []byte {
+	serial := Serial(r.store.Modified())
+	if serial == 0 {
+		serial = Serial(now)
+	}
+
+	rdata := append(encodeName(r.cfg.NameServer), encodeName(r.cfg.Hostmaster)...)
+	timers := make([]byte, 20)
+	binary.BigEndian.PutUint32(timers[0:], serial)
+	binary.BigEndian.PutUint32(timers[4:], soaRefresh)
+	binary.BigEndian.PutUint32(timers[8:], soaRetry)
+	binary.BigEndian.PutUint32(timers[12:], soaExpire)
+	binary.BigEndian.PutUint32(timers[16:], uint32(r.cfg.TTL))
+	rdata = append(rdata, timers...)
+
+	// SOA owner is the zone apex, which is not necessarily the query name
+	rr := encodeName(r.cfg.Zone)
+	return append(rr, recordTail(typeSOA, r.cfg.TTL, rdata)...)
+}
+
+// header returns a response header for query with the given counts
+func header(query []byte, flags uint16, qdcount, ancount int) []byte {
+	h := make([]byte, dnsHeaderLen)
+	copy(h, query[:2]) // ID
+	binary.BigEndian.PutUint16(h[2:], flags)
+	binary.BigEndian.PutUint16(h[4:], uint16(qdcount))
+	binary.BigEndian.PutUint16(h[6:], uint16(ancount))
+	return h
+}
+
+// withQuestion appends the question section
+func withQuestion(h, question []byte) []byte {
+	return append(h, question...)
+}
+
+// record builds a resource record owned by the query name, referenced
+// through a compression pointer to the question
+func record(rtype uint16, ttl int, rdata []byte) []byte {
+	rr := []byte{0xc0, dnsHeaderLen}
+	return append(rr, recordTail(rtype, ttl, rdata)...)
+}
+
+// recordTail encodes the type, class, TTL and data of a resource record
+func recordTail(rtype uint16, ttl int, rdata []byte) []byte {
+	tail := make([]byte, 10, 10+len(rdata))
+	binary.BigEndian.PutUint16(tail[0:], rtype)
+	binary.BigEndian.PutUint16(tail[2:], classIN)
+	binary.BigEndian.PutUint32(tail[4:], uint32(ttl))
+	binary.BigEndian.PutUint16(tail[8:], uint16(len(rdata)))
+	return append(tail, rdata...)
+}
+
+// txtData encodes text as TXT character strings of at most 255 bytes
+func txtData(text string) []byte {
+	var data []byte
+	for len(text) > 0 {
+		chunk := text
+		if len(chunk) > 255 {
+			chunk = chunk[:255]
+		}
+		data = append(data, byte(len(chunk)))
+		data = append(data, chunk...)
+		text = text[len(chunk):]
+	}
+	return data
+}
+
+// encodeName encodes a domain name in uncompressed wire format
+func encodeName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
+
+// readName decodes an uncompressed name starting at off and returns it with
+// the offset following it
+func readName(msg []byte, off int) (string, int, bool) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, false
+		}
+		length := int(msg[off])
+		off++
+		if length == 0 {
+			break
+		}
+		if length&0xc0 != 0 || off+length > len(msg) {
+			return "", 0, false // Compression is not used in questions
+		}
+		labels = append(labels, string(msg[off:off+length]))
+		off += length
+	}
+	return strings.Join(labels, "."), off, true
+}
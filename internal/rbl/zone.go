@@ -0,0 +1,125 @@
+package rbl
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"  //nolint:depguard
+)
+
+// FilePermission lets the name server read the zone file
+const FilePermission = 0644
+
+// SOA timers in seconds
+const (
+	soaRefresh = 3600
+	soaRetry   = 600
+	soaExpire  = 604800
+)
+
+// ReverseName returns the DNSBL label sequence of an IP: the octets of an
+// IPv4 address or the nibbles of an IPv6 address in reverse order
+func ReverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	v6 := ip.To16()
+	labels := make([]string, 0, 32)
+	for i := len(v6) - 1; i >= 0; i-- {
+		labels = append(labels, strconv.FormatUint(uint64(v6[i]&0x0f), 16), strconv.FormatUint(uint64(v6[i]>>4), 16))
+	}
+	return strings.Join(labels, ".")
+}
+
+// ParseReverseName is the inverse of ReverseName
+func ParseReverseName(name string) net.IP {
+	labels := strings.Split(name, ".")
+	switch len(labels) {
+	case 4:
+		return net.ParseIP(labels[3] + "." + labels[2] + "." + labels[1] + "." + labels[0])
+	case 32:
+		var b strings.Builder
+		for i := 31; i >= 0; i-- {
+			b.WriteString(labels[i])
+			if i%4 == 0 && i > 0 {
+				b.WriteByte(':')
+			}
+		}
+		return net.ParseIP(b.String())
+	}
+	return nil
+}
+
+// rpzName returns the RPZ rpz-ip trigger owner name for a single address
+func rpzName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("32.%d.%d.%d.%d.rpz-ip", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	v6 := ip.To16()
+	groups := make([]string, 0, 8)
+	for i := 14; i >= 0; i -= 2 {
+		groups = append(groups, strconv.FormatUint(uint64(v6[i])<<8|uint64(v6[i+1]), 16))
+	}
+	return "128." + strings.Join(groups, ".") + ".rpz-ip"
+}
+
+// Zone renders the zone file for the given bans. The serial is derived from
+// now so every regeneration is picked up by secondaries.
+func Zone(cfg *config.RBLConfig, bans []store.Ban, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "; Generated by fail2ban-notify at %s\n", now.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "$ORIGIN %s.\n", cfg.Zone)
+	fmt.Fprintf(&buf, "$TTL %d\n", cfg.TTL)
+	fmt.Fprintf(&buf, "@ IN SOA %s. %s. %d %d %d %d %d\n",
+		cfg.NameServer, cfg.Hostmaster, Serial(now), soaRefresh, soaRetry, soaExpire, cfg.TTL)
+	fmt.Fprintf(&buf, "@ IN NS %s.\n", cfg.NameServer)
+
+	seen := make(map[string]bool)
+	for i := range bans {
+		ip := net.ParseIP(bans[i].IP)
+		if ip == nil || seen[ip.String()] {
+			continue // Listed once even if banned in several jails
+		}
+		seen[ip.String()] = true
+
+		if cfg.Format == config.RBLFormatRPZ {
+			fmt.Fprintf(&buf, "%s IN CNAME .\n", rpzName(ip))
+			continue
+		}
+
+		name := ReverseName(ip)
+		fmt.Fprintf(&buf, "%s IN A %s\n", name, cfg.Address)
+		fmt.Fprintf(&buf, "%s IN TXT \"%s\"\n", name, Reason(&bans[i]))
+	}
+
+	return buf.Bytes()
+}
+
+// Reason is the TXT record text explaining a listing
+func Reason(ban *store.Ban) string {
+	return fmt.Sprintf("Banned by fail2ban (%s) since %s", ban.Jail, ban.Since.UTC().Format(time.RFC3339))
+}
+
+// Serial returns the SOA serial for a zone generated at t
+func Serial(t time.Time) uint32 {
+	return uint32(t.Unix())
+}
+
+// WriteZone regenerates the zone file from the active bans in st
+func WriteZone(cfg *config.RBLConfig, st *store.Store, now time.Time) error {
+	bans, err := st.ActiveBans(now)
+	if err != nil {
+		return err
+	}
+
+	return state.WriteFileAtomic(cfg.ZoneFile, Zone(cfg, bans, now), FilePermission)
+}
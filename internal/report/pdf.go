@@ -0,0 +1,146 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page layout in points
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	pageMargin   = 50
+	bodySize     = 9
+	titleSize    = 15
+	headingSize  = 12
+	lineHeight   = 12
+	lineChars    = 90 // Courier is 0.6 em wide, so 90 characters fit the text width at 9pt
+	wrapIndent   = "      "
+	linesPerPage = (pageHeight - 2*pageMargin) / lineHeight
+)
+
+// pdfLine is a line of a PDF page in one of the fonts
+type pdfLine struct {
+	text string
+	font string // F1 Courier, F2 Helvetica-Bold
+	size int
+}
+
+// WritePDF writes the report as a PDF document. It uses the standard PDF
+// fonts and needs no external tools; characters outside Latin-1 are
+// replaced by "?".
+func WritePDF(w io.Writer, inc *Incident) error {
+	lines := []pdfLine{
+		{text: "Incident report for " + inc.IP, font: "F2", size: titleSize},
+		{text: fmt.Sprintf("Generated %s on %s", formatTime(inc.Generated), inc.Host), font: "F1", size: bodySize},
+	}
+	for _, section := range inc.Sections() {
+		lines = append(lines, pdfLine{}, pdfLine{text: section.Title, font: "F2", size: headingSize})
+		for _, line := range section.Lines {
+			for _, wrapped := range wrapLine(line, lineChars) {
+				lines = append(lines, pdfLine{text: wrapped, font: "F1", size: bodySize})
+			}
+		}
+	}
+
+	var pages [][]pdfLine
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	_, err := w.Write(buildPDF(pages))
+	return err
+}
+
+// buildPDF lays out the pages as a PDF file. Objects 1 to 4 are the catalog,
+// the page tree and the two fonts; each page adds a page and a content object.
+func buildPDF(pages [][]pdfLine) []byte {
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, page := range pages {
+		var content bytes.Buffer
+		content.WriteString("BT\n")
+		fmt.Fprintf(&content, "%d TL\n%d %d Td\n", lineHeight, pageMargin, pageHeight-pageMargin)
+		for _, line := range page {
+			if line.font != "" {
+				fmt.Fprintf(&content, "/%s %d Tf\n", line.font, line.size)
+			}
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfString(line.text))
+		}
+		content.WriteString("ET\n")
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d %d Td\n(Page %d of %d) Tj\nET\n", bodySize, pageMargin, pageMargin/2, i+1, len(pages))
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// pdfString encodes text as the body of a PDF string in WinAnsiEncoding
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrapLine splits a line longer than width characters, indenting the
+// continuation lines
+func wrapLine(line string, width int) []string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return []string{line}
+	}
+
+	wrapped := []string{string(runes[:width])}
+	runes = runes[width:]
+	rest := width - len(wrapIndent)
+	for len(runes) > rest {
+		wrapped = append(wrapped, wrapIndent+string(runes[:rest]))
+		runes = runes[rest:]
+	}
+	return append(wrapped, wrapIndent+string(runes))
+}
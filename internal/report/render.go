@@ -0,0 +1,273 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// Section is a titled block of the plain text report
+type Section struct {
+	Title string
+	Lines []string
+}
+
+// Sections lays out the report as plain text, shared by the text and PDF
+// formats
+func (inc *Incident) Sections() []Section {
+	summary := Section{Title: "Summary", Lines: []string{
+		"IP:           " + inc.IP,
+		"First seen:   " + formatTime(inc.FirstSeen),
+		"Last seen:    " + formatTime(inc.LastSeen),
+		fmt.Sprintf("Bans:         %d", inc.Bans),
+		fmt.Sprintf("Unbans:       %d", inc.Unbans),
+		fmt.Sprintf("Active bans:  %d", len(inc.ActiveBans)),
+	}}
+	if len(inc.Servers) > 0 {
+		summary.Lines = append(summary.Lines, "Reported by:  "+strings.Join(inc.Servers, ", "))
+	}
+	sections := []Section{summary}
+
+	if len(inc.Jails) > 0 {
+		jails := Section{Title: "Jails"}
+		for _, jail := range inc.Jails {
+			jails.Lines = append(jails.Lines, fmt.Sprintf("%-20s %4d bans, %s to %s",
+				jail.Name, jail.Bans, formatTime(jail.First), formatTime(jail.Last)))
+		}
+		sections = append(sections, jails)
+	}
+
+	if len(inc.ActiveBans) > 0 {
+		active := Section{Title: "Active Bans"}
+		for _, ban := range inc.ActiveBans {
+			expires := "permanent"
+			if !ban.Expires.IsZero() {
+				expires = "until " + formatTime(ban.Expires)
+			}
+			active.Lines = append(active.Lines, fmt.Sprintf("%-20s since %s, %s", ban.Jail, formatTime(ban.Since), expires))
+		}
+		sections = append(sections, active)
+	}
+
+	if lines := LocationLines(inc.Location); len(lines) > 0 {
+		sections = append(sections, Section{Title: "Location", Lines: lines})
+	}
+	if lines := EnrichmentLines(inc.Enrichment); len(lines) > 0 {
+		sections = append(sections, Section{Title: "Enrichment", Lines: lines})
+	}
+
+	timeline := Section{Title: "Timeline"}
+	for i := range inc.Timeline {
+		entry := &inc.Timeline[i]
+		event := entry.Event
+		line := fmt.Sprintf("%s  %-6s %-16s %s", formatTime(event.Time), event.Action, event.Jail, entry.ID)
+		if event.Hostname != "" {
+			line += " on " + event.Hostname
+		}
+		if len(entry.Deliveries) > 0 {
+			line += fmt.Sprintf(" (%d/%d notifications delivered)", entry.Delivered(), len(entry.Deliveries))
+		}
+		timeline.Lines = append(timeline.Lines, line)
+		if event.Failures > 0 {
+			timeline.Lines = append(timeline.Lines, fmt.Sprintf("    %d failures", event.Failures))
+		}
+		for _, match := range event.Matches {
+			timeline.Lines = append(timeline.Lines, "    | "+match)
+		}
+		for _, r := range entry.Deliveries {
+			state := "delivered"
+			if !r.Success {
+				state = "failed"
+			}
+			line := fmt.Sprintf("    %s: %s after %d attempts", r.ConnectorName, state, r.Attempts)
+			if r.Error != "" {
+				line += ": " + r.Error
+			}
+			timeline.Lines = append(timeline.Lines, line)
+		}
+	}
+	sections = append(sections, timeline)
+
+	if len(inc.Artifacts) > 0 {
+		sections = append(sections, Section{Title: "Log Artifacts", Lines: inc.Artifacts})
+	}
+
+	return sections
+}
+
+// LocationLines describes the location and network of an event
+func LocationLines(data *types.NotificationData) []string {
+	if data == nil {
+		return nil
+	}
+
+	var lines []string
+	if location := data.GetLocationString(); location != "" {
+		lines = append(lines, "Location:     "+location)
+	}
+	if data.ISP != "" {
+		lines = append(lines, "ISP:          "+data.ISP)
+	}
+	if data.ASN != "" {
+		lines = append(lines, "ASN:          "+data.ASN)
+	}
+	if data.Latitude != 0 || data.Longitude != 0 {
+		lines = append(lines, fmt.Sprintf("Coordinates:  %.4f, %.4f", data.Latitude, data.Longitude))
+	}
+	return lines
+}
+
+// EnrichmentLines describes the enrichment of a ban
+func EnrichmentLines(data *types.NotificationData) []string {
+	if data == nil {
+		return nil
+	}
+
+	var lines []string
+	if data.RiskScore > 0 {
+		lines = append(lines, fmt.Sprintf("Risk score:   %d/100", data.RiskScore))
+	}
+	if data.Anonymity != nil {
+		labels := data.Anonymity.Labels()
+		if len(labels) == 0 {
+			labels = []string{"none detected"}
+		}
+		lines = append(lines, fmt.Sprintf("Anonymity:    %s (%s)", strings.Join(labels, ", "), data.Anonymity.Source))
+	}
+	if len(data.Domains) > 0 {
+		lines = append(lines, "Domains:      "+strings.Join(data.Domains, ", "))
+	}
+	if data.HoneypotSessions > 0 {
+		lines = append(lines, fmt.Sprintf("Honeypot:     %d sessions", data.HoneypotSessions))
+	}
+	if data.Campaign != nil {
+		lines = append(lines, "Campaign:     "+data.Campaign.Summary())
+	}
+	if data.History != nil {
+		lines = append(lines, "History:      "+data.History.Summary())
+	}
+	return lines
+}
+
+// WriteText writes the report as plain text
+func WriteText(w io.Writer, inc *Incident) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Incident report for %s\n", inc.IP)
+	fmt.Fprintf(&b, "Generated %s on %s\n", formatTime(inc.Generated), inc.Host)
+	for _, section := range inc.Sections() {
+		fmt.Fprintf(&b, "\n%s:\n", section.Title)
+		for _, line := range section.Lines {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes the report as a self-contained HTML page
+func WriteHTML(w io.Writer, inc *Incident) error {
+	return htmlTemplate.Execute(w, inc)
+}
+
+// formatTime formats report times, or "-" for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+var htmlTemplate = template.Must(template.New("incident").Funcs(template.FuncMap{
+	"time":       formatTime,
+	"location":   LocationLines,
+	"enrichment": EnrichmentLines,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Incident report for {{.IP}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 2em; color: #222; }
+h1 { font-size: 22px; }
+h2 { font-size: 17px; border-bottom: 1px solid #ccc; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: 4px 8px; border-bottom: 1px solid #eee; }
+th { background: #f5f5f5; }
+pre { margin: 2px 0; font-size: 12px; white-space: pre-wrap; }
+.failed { color: #b00; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>Incident report for {{.IP}}</h1>
+<p class="meta">Generated {{time .Generated}} on {{.Host}}</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>IP</th><td>{{.IP}}</td></tr>
+<tr><th>First seen</th><td>{{time .FirstSeen}}</td></tr>
+<tr><th>Last seen</th><td>{{time .LastSeen}}</td></tr>
+<tr><th>Bans</th><td>{{.Bans}}</td></tr>
+<tr><th>Unbans</th><td>{{.Unbans}}</td></tr>
+<tr><th>Active bans</th><td>{{len .ActiveBans}}</td></tr>
+{{- if .Servers}}
+<tr><th>Reported by</th><td>{{range $i, $s := .Servers}}{{if $i}}, {{end}}{{$s}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- if .Jails}}
+
+<h2>Jails</h2>
+<table>
+<tr><th>Jail</th><th>Bans</th><th>First</th><th>Last</th></tr>
+{{- range .Jails}}
+<tr><td>{{.Name}}</td><td>{{.Bans}}</td><td>{{time .First}}</td><td>{{time .Last}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .ActiveBans}}
+
+<h2>Active Bans</h2>
+<table>
+<tr><th>Jail</th><th>Since</th><th>Expires</th></tr>
+{{- range .ActiveBans}}
+<tr><td>{{.Jail}}</td><td>{{time .Since}}</td><td>{{if .Expires.IsZero}}permanent{{else}}{{time .Expires}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with location .Location}}
+
+<h2>Location</h2>
+{{range .}}<pre>{{.}}</pre>
+{{end}}
+{{- end}}
+{{- with enrichment .Enrichment}}
+
+<h2>Enrichment</h2>
+{{range .}}<pre>{{.}}</pre>
+{{end}}
+{{- end}}
+
+<h2>Timeline</h2>
+<table>
+<tr><th>Time</th><th>Action</th><th>Jail</th><th>Server</th><th>Event</th><th>Notifications</th></tr>
+{{- range .Timeline}}
+<tr>
+<td>{{time .Event.Time}}</td><td>{{.Event.Action}}</td><td>{{.Event.Jail}}</td><td>{{.Event.Hostname}}</td>
+<td>{{.ID}}{{if .Event.Failures}}<br>{{.Event.Failures}} failures{{end}}{{range .Event.Matches}}<pre>{{.}}</pre>{{end}}</td>
+<td>{{range .Deliveries}}<div{{if not .Success}} class="failed"{{end}}>{{.ConnectorName}}: {{if .Success}}delivered{{else}}failed{{end}} after {{.Attempts}} attempts{{if .Error}}: {{.Error}}{{end}}</div>{{else}}-{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- if .Artifacts}}
+
+<h2>Log Artifacts</h2>
+{{range .Artifacts}}<pre>{{.}}</pre>
+{{end}}
+{{- end}}
+</body>
+</html>
+`))
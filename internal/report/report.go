@@ -0,0 +1,174 @@
+// Package report compiles everything the event store knows about an IP into
+// an incident report for handover to legal or abuse teams
+package report
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/store" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// Incident is the report on one IP
+type Incident struct {
+	IP        string    `json:"ip"`
+	Generated time.Time `json:"generated"`
+	Host      string    `json:"host"` // Server the report was generated on
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Bans      int       `json:"bans"`
+	Unbans    int       `json:"unbans"`
+	Jails     []Jail    `json:"jails"`
+	Servers   []string  `json:"servers"` // Hostnames that reported the IP
+
+	// Location and enrichment are taken from the most recent event that has them
+	Location   *types.NotificationData `json:"location"`
+	Enrichment *types.NotificationData `json:"enrichment"`
+
+	ActiveBans []store.Ban `json:"active_bans"`
+	Timeline   []Entry     `json:"timeline"`
+	Artifacts  []string    `json:"artifacts"` // Log context bundles still on disk
+}
+
+// Jail summarizes the bans of the IP in one jail
+type Jail struct {
+	Name  string    `json:"name"`
+	Bans  int       `json:"bans"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// Entry is an event with the notifications delivered for it
+type Entry struct {
+	ID         string                  `json:"id"`
+	Event      *types.NotificationData `json:"event"`
+	Deliveries []store.Result          `json:"deliveries"`
+}
+
+// Delivered returns the number of connectors that delivered the event
+func (e *Entry) Delivered() int {
+	delivered := 0
+	for i := range e.Deliveries {
+		if e.Deliveries[i].Success {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Matcher reports whether a stored event concerns the IP of the report
+type Matcher func(data *types.NotificationData) bool
+
+// Collect compiles the report on ip from the store. It returns nil if the
+// store has no events matching it.
+func Collect(st *store.Store, ip string, matches Matcher, now time.Time) (*Incident, error) {
+	incident := &Incident{
+		IP:         ip,
+		Generated:  now,
+		Jails:      []Jail{},
+		Servers:    []string{},
+		ActiveBans: []store.Ban{},
+		Timeline:   []Entry{},
+		Artifacts:  []string{},
+	}
+	incident.Host, _ = os.Hostname()
+
+	jails := make(map[string]*Jail)
+	servers := make(map[string]bool)
+	byID := make(map[string]int)
+
+	err := st.Scan(func(data *types.NotificationData) error {
+		if !matches(data) {
+			return nil
+		}
+
+		event := *data
+		if incident.FirstSeen.IsZero() || event.Time.Before(incident.FirstSeen) {
+			incident.FirstSeen = event.Time
+		}
+		if event.Time.After(incident.LastSeen) {
+			incident.LastSeen = event.Time
+		}
+		if event.Hostname != "" && !servers[event.Hostname] {
+			servers[event.Hostname] = true
+			incident.Servers = append(incident.Servers, event.Hostname)
+		}
+		if event.Country != "" || event.ISP != "" {
+			incident.Location = &event
+		}
+		if event.Artifact != "" {
+			if _, err := os.Stat(event.Artifact); err == nil {
+				incident.Artifacts = append(incident.Artifacts, event.Artifact)
+			}
+		}
+
+		switch {
+		case event.IsBan():
+			incident.Bans++
+			jail, ok := jails[event.Jail]
+			if !ok {
+				jail = &Jail{Name: event.Jail, First: event.Time}
+				jails[event.Jail] = jail
+			}
+			jail.Bans++
+			jail.Last = event.Time
+			if hasEnrichment(&event) {
+				incident.Enrichment = &event
+			}
+		case event.IsUnban():
+			incident.Unbans++
+		}
+
+		id := event.ID()
+		byID[id] = len(incident.Timeline)
+		incident.Timeline = append(incident.Timeline, Entry{ID: id, Event: &event, Deliveries: []store.Result{}})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(incident.Timeline) == 0 {
+		return nil, nil
+	}
+
+	err = st.ScanResults(func(result *store.Result) error {
+		if i, ok := byID[result.EventID()]; ok {
+			incident.Timeline[i].Deliveries = append(incident.Timeline[i].Deliveries, *result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, jail := range jails {
+		incident.Jails = append(incident.Jails, *jail)
+	}
+	sort.Slice(incident.Jails, func(i, j int) bool {
+		if incident.Jails[i].Bans != incident.Jails[j].Bans {
+			return incident.Jails[i].Bans > incident.Jails[j].Bans
+		}
+		return incident.Jails[i].Name < incident.Jails[j].Name
+	})
+
+	bans, err := st.ActiveBans(now)
+	if err != nil {
+		return nil, err
+	}
+	for _, ban := range bans {
+		if matches(&types.NotificationData{IP: ban.IP}) {
+			incident.ActiveBans = append(incident.ActiveBans, ban)
+		}
+	}
+
+	return incident, nil
+}
+
+// hasEnrichment reports whether a ban carries more than the basic fields
+func hasEnrichment(data *types.NotificationData) bool {
+	return data.RiskScore > 0 || data.Anonymity != nil || len(data.Domains) > 0 ||
+		data.HoneypotSessions > 0 || data.Campaign != nil || data.History != nil
+}
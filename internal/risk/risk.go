@@ -0,0 +1,44 @@
+// Package risk combines the enrichments of a ban into a single 0-100 score
+package risk
+
+import (
+	"math"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Score returns the weighted average of the factors that have data, scaled
+// to 0-100. priorBans is the number of earlier bans of the IP, or -1 when
+// the history is unknown.
+func Score(cfg *config.RiskConfig, data *types.NotificationData, priorBans int) int {
+	var sum, weights float64
+	add := func(weight int, value float64) {
+		sum += float64(weight) * math.Min(math.Max(value, 0), 1)
+		weights += float64(weight)
+	}
+
+	add(cfg.Weights.Failures, float64(data.Failures)/float64(cfg.FailuresCap))
+	add(cfg.Weights.Jail, float64(cfg.Criticality(data.Jail))/100)
+
+	if priorBans >= 0 {
+		add(cfg.Weights.History, float64(priorBans)/float64(cfg.HistoryCap))
+	}
+
+	if a := data.Anonymity; a != nil {
+		anonymity := 0.0
+		if a.Anonymous() {
+			anonymity = 1
+		}
+		add(cfg.Weights.Anonymity, anonymity)
+
+		if a.Source == config.AnonymityIPQS {
+			add(cfg.Weights.Abuse, float64(a.FraudScore)/100)
+		}
+	}
+
+	if weights == 0 {
+		return 0
+	}
+	return int(math.Round(sum / weights * 100))
+}
@@ -0,0 +1,213 @@
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// Escape modes
+const (
+	EscapeNone       = ""
+	EscapeMarkdown   = "markdown"
+	EscapeMarkdownV2 = "markdownv2"
+	EscapeHTML       = "html"
+	EscapeJSON       = "json"
+)
+
+// MaxFieldLength is the maximum length of a single sanitized field
+const MaxFieldLength = 1024
+
+// ansiPattern matches CSI and OSC terminal escape sequences
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// ValidMode returns true if mode is a known escape mode
+func ValidMode(mode string) bool {
+	switch mode {
+	case EscapeNone, EscapeMarkdown, EscapeMarkdownV2, EscapeHTML, EscapeJSON:
+		return true
+	}
+	return false
+}
+
+// Text removes terminal escape sequences, control and bidi override
+// characters and invalid UTF-8 from s. Newlines and tabs are preserved.
+func Text(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = ansiPattern.ReplaceAllString(s, "")
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case r == '\r':
+			continue
+		case unicode.IsControl(r), isBidiControl(r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return truncate(b.String(), MaxFieldLength)
+}
+
+// Line sanitizes s like Text and additionally collapses it to a single line
+func Line(s string) string {
+	s = Text(s)
+	s = strings.Join(strings.Fields(s), " ")
+	return s
+}
+
+// Escape escapes s for safe embedding in the given markup
+func Escape(s, mode string) string {
+	switch mode {
+	case EscapeMarkdown:
+		return escapeChars(s, "\\`*_[")
+	case EscapeMarkdownV2:
+		return escapeChars(s, "\\_*[]()~`>#+-=|{}.!")
+	case EscapeHTML:
+		return htmlReplacer.Replace(s)
+	case EscapeJSON:
+		return escapeJSON(s)
+	default:
+		return s
+	}
+}
+
+// Data sanitizes all free-text fields of the notification data in place
+func Data(nd *types.NotificationData) {
+	nd.IP = Line(nd.IP)
+	nd.Jail = Line(nd.Jail)
+	nd.Action = Line(nd.Action)
+	nd.Country = Line(nd.Country)
+	nd.Region = Line(nd.Region)
+	nd.City = Line(nd.City)
+	nd.ISP = Line(nd.ISP)
+	nd.Hostname = Line(nd.Hostname)
+	nd.TargetHost = Line(nd.TargetHost)
+	nd.Timezone = Line(nd.Timezone)
+	for i, line := range nd.Matches {
+		nd.Matches[i] = Line(line)
+	}
+}
+
+// Escaped returns a copy of the notification data with all free-text
+// fields escaped for the given mode
+func Escaped(nd *types.NotificationData, mode string) *types.NotificationData {
+	escaped := *nd
+	if mode == EscapeNone {
+		return &escaped
+	}
+
+	escaped.IP = Escape(nd.IP, mode)
+	escaped.Jail = Escape(nd.Jail, mode)
+	escaped.Action = Escape(nd.Action, mode)
+	escaped.Country = Escape(nd.Country, mode)
+	escaped.Region = Escape(nd.Region, mode)
+	escaped.City = Escape(nd.City, mode)
+	escaped.ISP = Escape(nd.ISP, mode)
+	escaped.Hostname = Escape(nd.Hostname, mode)
+	escaped.TargetHost = Escape(nd.TargetHost, mode)
+	escaped.Timezone = Escape(nd.Timezone, mode)
+	escaped.Escalation = Escape(nd.Escalation, mode)
+	if len(nd.Matches) > 0 {
+		escaped.Matches = make([]string, len(nd.Matches))
+		for i, line := range nd.Matches {
+			escaped.Matches[i] = Escape(line, mode)
+		}
+	}
+	if len(nd.Domains) > 0 {
+		escaped.Domains = make([]string, len(nd.Domains))
+		for i, domain := range nd.Domains {
+			escaped.Domains[i] = Escape(domain, mode)
+		}
+	}
+	if nd.Anonymity != nil {
+		anonymity := *nd.Anonymity
+		anonymity.Service = Escape(anonymity.Service, mode)
+		escaped.Anonymity = &anonymity
+	}
+	if len(nd.Logins) > 0 {
+		escaped.Logins = make([]types.Login, len(nd.Logins))
+		for i, login := range nd.Logins {
+			login.User = Escape(login.User, mode)
+			login.Service = Escape(login.Service, mode)
+			escaped.Logins[i] = login
+		}
+	}
+	return &escaped
+}
+
+var htmlReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// escapeChars prefixes every character in chars with a backslash
+func escapeChars(s, chars string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeJSON escapes s for use inside a JSON string literal
+func escapeJSON(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '<', '>', '&':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			if r < 0x20 {
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isBidiControl reports whether r is a Unicode bidirectional control character
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069') ||
+		r == '\u200e' || r == '\u200f' || r == '\u061c'
+}
+
+// truncate shortens s to at most maxLen bytes without splitting a rune
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !isRuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,107 @@
+package scaffold
+
+// bashSource is the skeleton of a Bash connector
+const bashSource = `#!/bin/bash
+# {{.Name}} connector for fail2ban-notify
+# Generated by "fail2ban-notify connector scaffold {{.Lang}}"; install it as
+# {{.Path}} and make it executable.
+#
+# Contract:
+#   - The event arrives as F2B_* environment variables and as JSON on stdin.
+#   - The connector's settings arrive as environment variables named after
+#     their keys, here {{.Prefix}}_URL and {{.Prefix}}_TOKEN.
+#   - Values rendered from the connector's "args" follow the options.
+#   - --healthcheck checks the settings without notifying anyone.
+#   - Exit 0 when delivered or deliberately skipped, 1 when delivery failed
+#     and may be retried, 2 for invalid arguments or settings. Errors go to
+#     stderr; stdout is kept with the event in the history.
+
+set -euo pipefail
+
+usage() {
+    echo "usage: $0 [--healthcheck] [--dry-run] [--timeout seconds] [args...]" >&2
+    exit 2
+}
+
+# Arguments; ARGS collects the values rendered from the connector's "args"
+HEALTHCHECK=false
+DRY_RUN=false
+TIMEOUT=10
+ARGS=()
+while [[ $# -gt 0 ]]; do
+    case "$1" in
+        --healthcheck) HEALTHCHECK=true ;;
+        --dry-run) DRY_RUN=true ;;
+        --timeout)
+            [[ $# -ge 2 && "$2" =~ ^[0-9]+$ ]] || usage
+            TIMEOUT="$2"
+            shift
+            ;;
+        -h|--help) usage ;;
+        --) shift; ARGS+=("$@"); break ;;
+        -*) echo "Error: unknown option $1" >&2; usage ;;
+        *) ARGS+=("$1") ;;
+    esac
+    shift
+done
+
+# Settings
+ENDPOINT="{{printf "${%s_URL:-}" .Prefix}}"
+TOKEN="{{printf "${%s_TOKEN:-}" .Prefix}}"
+if [[ -z "$ENDPOINT" ]]; then
+    echo "Error: {{.Prefix}}_URL not set" >&2
+    exit 2
+fi
+
+if $HEALTHCHECK; then
+    # Replace with a request that checks the credentials without notifying
+    # anyone, exiting 1 if it fails
+    exit 0
+fi
+
+# The event's fields are read from the environment, and the JSON on stdin is
+# forwarded as is. Run by hand without stdin, a minimal event is built.
+EVENT=""
+if [[ ! -t 0 ]]; then
+    EVENT=$(cat)
+fi
+ACTION="${F2B_ACTION:-ban}"
+IP="${F2B_IP:-}"
+JAIL="${F2B_JAIL:-}"
+if [[ -z "$EVENT" ]]; then
+    EVENT=$(printf '{"ip":"%s","jail":"%s","action":"%s","time":"%s"}' \
+        "$IP" "$JAIL" "$ACTION" "${F2B_TIME:-$(date -Iseconds)}")
+fi
+
+# Bans and unbans name an IP; surge, restore and bulk_unban summarize many
+# bans. Actions added in later versions are skipped, not failed.
+case "$ACTION" in
+    ban|unban) ;;
+    *)
+        echo "Skipping $ACTION event"
+        exit 0
+        ;;
+esac
+
+if $DRY_RUN; then
+    echo "$EVENT"
+    exit 0
+fi
+
+# Delivery: replace with the request your service expects
+CURL_ARGS=(-sS -o /dev/null -w '%{http_code}' --max-time "$TIMEOUT"
+    -X POST -H "Content-Type: application/json" --data-binary "$EVENT")
+if [[ -n "$TOKEN" ]]; then
+    CURL_ARGS+=(-H "Authorization: Bearer $TOKEN")
+fi
+if ! STATUS=$(curl "${CURL_ARGS[@]}" "$ENDPOINT"); then
+    echo "Error: request to $ENDPOINT failed" >&2
+    exit 1
+fi
+if [[ "$STATUS" != 2* ]]; then
+    echo "Error: $ENDPOINT answered HTTP $STATUS" >&2
+    exit 1
+fi
+
+echo "Delivered $ACTION of $IP in $JAIL (HTTP $STATUS)"
+`
@@ -0,0 +1,171 @@
+package scaffold
+
+// goSource is the skeleton of a Go connector, built into an executable
+// connector
+const goSource = `// {{.Name}} connector for fail2ban-notify
+// Generated by "fail2ban-notify connector scaffold {{.Lang}}"; build it with
+//
+//	go build -o {{.Path}} {{.Name}}{{.Ext}}
+//
+// and configure it as an executable connector.
+//
+// Contract:
+//   - The event arrives as F2B_* environment variables and as JSON on stdin.
+//   - The connector's settings arrive as environment variables named after
+//     their keys, here {{.Prefix}}_URL and {{.Prefix}}_TOKEN.
+//   - Values rendered from the connector's "args" follow the options.
+//   - --healthcheck checks the settings without notifying anyone.
+//   - Exit 0 when delivered or deliberately skipped, 1 when delivery failed
+//     and may be retried, 2 for invalid arguments or settings. Errors go to
+//     stderr; stdout is kept with the event in the history.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exitDelivered = 0
+	exitFailed    = 1
+	exitUsage     = 2
+)
+
+// event holds the fields the connector uses. The whole event is forwarded
+// as received; see "Available Environment Variables" in the fail2ban-notify
+// README for the others. Field names match the JSON keys case-insensitively.
+type event struct {
+	IP     string
+	Jail   string
+	Action string
+}
+
+// config holds the connector's settings
+type config struct {
+	url   string
+	token string
+}
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	// flag exits with status 2 on invalid options. flag.Args() are the
+	// values rendered from the connector's "args".
+	healthcheck := flag.Bool("healthcheck", false, "Check the settings without notifying anyone")
+	dryRun := flag.Bool("dry-run", false, "Print the event instead of delivering it")
+	timeout := flag.Int("timeout", 10, "Seconds to wait for the service")
+	flag.Parse()
+	if *timeout <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -timeout must be positive")
+		return exitUsage
+	}
+
+	cfg := config{
+		url:   os.Getenv("{{.Prefix}}_URL"),
+		token: os.Getenv("{{.Prefix}}_TOKEN"),
+	}
+	if cfg.url == "" {
+		fmt.Fprintln(os.Stderr, "Error: {{.Prefix}}_URL not set")
+		return exitUsage
+	}
+
+	if *healthcheck {
+		// Replace with a request that checks the credentials without
+		// notifying anyone, returning exitFailed if it fails
+		return exitDelivered
+	}
+
+	raw, err := readEvent()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+	var ev event
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid event on stdin: %v\n", err)
+		return exitFailed
+	}
+
+	// Bans and unbans name an IP; surge, restore and bulk_unban summarize
+	// many bans. Actions added in later versions are skipped, not failed.
+	if ev.Action != "ban" && ev.Action != "unban" {
+		fmt.Printf("Skipping %s event\n", ev.Action)
+		return exitDelivered
+	}
+
+	if *dryRun {
+		fmt.Println(string(raw))
+		return exitDelivered
+	}
+
+	status, err := deliver(cfg, raw, time.Duration(*timeout)*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+
+	fmt.Printf("Delivered %s of %s in %s (HTTP %d)\n", ev.Action, ev.IP, ev.Jail, status)
+	return exitDelivered
+}
+
+// readEvent reads the event from stdin. Run by hand without stdin, a
+// minimal one is built from the environment.
+func readEvent() ([]byte, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			return raw, nil
+		}
+	}
+
+	action := os.Getenv("F2B_ACTION")
+	if action == "" {
+		action = "ban"
+	}
+	return json.Marshal(map[string]string{
+		"ip":       os.Getenv("F2B_IP"),
+		"jail":     os.Getenv("F2B_JAIL"),
+		"action":   action,
+		"time":     os.Getenv("F2B_TIME"),
+		"event_id": os.Getenv("F2B_EVENT_ID"),
+	})
+}
+
+// deliver sends the event to the service and returns the HTTP status.
+// Replace with the request your service expects.
+func deliver(cfg config, raw []byte, timeout time.Duration) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, cfg.url, bytes.NewReader(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid {{.Prefix}}_URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.token)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request to %s failed: %w", cfg.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s answered HTTP %d: %s", cfg.url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+`
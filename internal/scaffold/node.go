@@ -0,0 +1,164 @@
+package scaffold
+
+// nodeSource is the skeleton of a Node.js connector
+const nodeSource = `#!/usr/bin/env node
+// {{.Name}} connector for fail2ban-notify
+// Generated by "fail2ban-notify connector scaffold {{.Lang}}"; install it as
+// {{.Path}} and make it executable.
+//
+// Contract:
+//   - The event arrives as F2B_* environment variables and as JSON on stdin.
+//   - The connector's settings arrive as environment variables named after
+//     their keys, here {{.Prefix}}_URL and {{.Prefix}}_TOKEN.
+//   - Values rendered from the connector's "args" follow the options.
+//   - --healthcheck checks the settings without notifying anyone.
+//   - Exit 0 when delivered or deliberately skipped, 1 when delivery failed
+//     and may be retried, 2 for invalid arguments or settings. Errors go to
+//     stderr; stdout is kept with the event in the history.
+'use strict';
+
+const { parseArgs } = require('node:util');
+
+const EXIT_DELIVERED = 0;
+const EXIT_FAILED = 1;
+const EXIT_USAGE = 2;
+
+const USAGE = 'usage: {{.Name}}{{.Ext}} [--healthcheck] [--dry-run] [--timeout seconds] [args...]';
+
+// usage prints the error and the usage, exiting with EXIT_USAGE
+function usage(message) {
+  if (message) {
+    console.error('Error: ' + message);
+  }
+  console.error(USAGE);
+  process.exit(EXIT_USAGE);
+}
+
+// parseOptions parses the options. positionals are the values rendered from
+// the connector's "args".
+function parseOptions() {
+  let parsed;
+  try {
+    parsed = parseArgs({
+      options: {
+        healthcheck: { type: 'boolean', default: false },
+        'dry-run': { type: 'boolean', default: false },
+        timeout: { type: 'string', default: '10' },
+        help: { type: 'boolean', short: 'h', default: false },
+      },
+      allowPositionals: true,
+    });
+  } catch (err) {
+    usage(err.message);
+  }
+  const { values, positionals } = parsed;
+  if (values.help) {
+    usage();
+  }
+  const timeout = Number(values.timeout);
+  if (!(timeout > 0)) {
+    usage('invalid --timeout ' + values.timeout);
+  }
+  return { ...values, timeout, args: positionals };
+}
+
+// getConfig gets the connector's settings from environment variables
+function getConfig() {
+  return {
+    url: process.env.{{.Prefix}}_URL || '',
+    token: process.env.{{.Prefix}}_TOKEN || '',
+  };
+}
+
+// readEvent reads the event from stdin. Run by hand without stdin, a minimal
+// one is built from the environment.
+async function readEvent() {
+  if (!process.stdin.isTTY) {
+    let data = '';
+    for await (const chunk of process.stdin) {
+      data += chunk;
+    }
+    if (data.trim()) {
+      return JSON.parse(data);
+    }
+  }
+  return {
+    ip: process.env.F2B_IP || '',
+    jail: process.env.F2B_JAIL || '',
+    action: process.env.F2B_ACTION || 'ban',
+    time: process.env.F2B_TIME || '',
+    event_id: process.env.F2B_EVENT_ID || '',
+  };
+}
+
+// deliver sends the event to the service and returns the HTTP status.
+// Replace with the request your service expects.
+async function deliver(config, event, timeout) {
+  const headers = { 'Content-Type': 'application/json' };
+  if (config.token) {
+    headers.Authorization = 'Bearer ' + config.token;
+  }
+  const response = await fetch(config.url, {
+    method: 'POST',
+    headers,
+    body: JSON.stringify(event),
+    signal: AbortSignal.timeout(timeout * 1000),
+  });
+  if (!response.ok) {
+    throw new Error(config.url + ' answered HTTP ' + response.status);
+  }
+  return response.status;
+}
+
+async function main() {
+  const options = parseOptions();
+
+  const config = getConfig();
+  if (!config.url) {
+    console.error('Error: {{.Prefix}}_URL not set');
+    return EXIT_USAGE;
+  }
+
+  if (options.healthcheck) {
+    // Replace with a request that checks the credentials without notifying
+    // anyone, returning EXIT_FAILED if it fails
+    return EXIT_DELIVERED;
+  }
+
+  let event;
+  try {
+    event = await readEvent();
+  } catch (err) {
+    console.error('Error: invalid event on stdin: ' + err.message);
+    return EXIT_FAILED;
+  }
+
+  // Bans and unbans name an IP; surge, restore and bulk_unban summarize many
+  // bans. Actions added in later versions are skipped, not failed.
+  const action = event.action || 'ban';
+  if (action !== 'ban' && action !== 'unban') {
+    console.log('Skipping ' + action + ' event');
+    return EXIT_DELIVERED;
+  }
+
+  if (options['dry-run']) {
+    console.log(JSON.stringify(event, null, 2));
+    return EXIT_DELIVERED;
+  }
+
+  let status;
+  try {
+    status = await deliver(config, event, options.timeout);
+  } catch (err) {
+    console.error('Error: ' + err.message);
+    return EXIT_FAILED;
+  }
+
+  console.log('Delivered ' + action + ' of ' + (event.ip || '') + ' in ' + (event.jail || '') + ' (HTTP ' + status + ')');
+  return EXIT_DELIVERED;
+}
+
+main().then((code) => {
+  process.exitCode = code;
+});
+`
@@ -0,0 +1,131 @@
+package scaffold
+
+// pythonSource is the skeleton of a Python connector
+const pythonSource = `#!/usr/bin/env python3
+"""
+{{.Name}} connector for fail2ban-notify
+Generated by "fail2ban-notify connector scaffold {{.Lang}}"; install it as
+{{.Path}} and make it executable.
+
+Contract:
+  - The event arrives as F2B_* environment variables and as JSON on stdin.
+  - The connector's settings arrive as environment variables named after
+    their keys, here {{.Prefix}}_URL and {{.Prefix}}_TOKEN.
+  - Values rendered from the connector's "args" follow the options.
+  - --healthcheck checks the settings without notifying anyone.
+  - Exit 0 when delivered or deliberately skipped, 1 when delivery failed
+    and may be retried, 2 for invalid arguments or settings. Errors go to
+    stderr; stdout is kept with the event in the history.
+"""
+
+import argparse
+import json
+import os
+import sys
+import urllib.error
+import urllib.request
+
+EXIT_DELIVERED = 0
+EXIT_FAILED = 1
+EXIT_USAGE = 2
+
+
+def parse_args():
+    """Parse the options; argparse exits with EXIT_USAGE on errors"""
+    parser = argparse.ArgumentParser(description='{{.Name}} connector for fail2ban-notify')
+    parser.add_argument('--healthcheck', action='store_true',
+                        help='check the settings without notifying anyone')
+    parser.add_argument('--dry-run', action='store_true',
+                        help='print the event instead of delivering it')
+    parser.add_argument('--timeout', type=float, default=10,
+                        help='seconds to wait for the service (default 10)')
+    parser.add_argument('args', nargs='*',
+                        help="values rendered from the connector's args")
+    return parser.parse_args()
+
+
+def get_config():
+    """Get the connector's settings from environment variables"""
+    return {
+        'url': os.getenv('{{.Prefix}}_URL', ''),
+        'token': os.getenv('{{.Prefix}}_TOKEN', ''),
+    }
+
+
+def read_event():
+    """Read the event from stdin. Run by hand without stdin, a minimal one
+    is built from the environment."""
+    if not sys.stdin.isatty():
+        data = sys.stdin.read().strip()
+        if data:
+            return json.loads(data)
+    return {
+        'ip': os.getenv('F2B_IP', ''),
+        'jail': os.getenv('F2B_JAIL', ''),
+        'action': os.getenv('F2B_ACTION', 'ban'),
+        'time': os.getenv('F2B_TIME', ''),
+        'event_id': os.getenv('F2B_EVENT_ID', ''),
+    }
+
+
+def deliver(config, event, timeout):
+    """Send the event to the service and return the HTTP status. Replace
+    with the request your service expects."""
+    request = urllib.request.Request(
+        config['url'],
+        data=json.dumps(event).encode('utf-8'),
+        headers={'Content-Type': 'application/json'},
+        method='POST',
+    )
+    if config['token']:
+        request.add_header('Authorization', 'Bearer ' + config['token'])
+    with urllib.request.urlopen(request, timeout=timeout) as response:
+        return response.status
+
+
+def main():
+    args = parse_args()
+
+    config = get_config()
+    if not config['url']:
+        print('Error: {{.Prefix}}_URL not set', file=sys.stderr)
+        return EXIT_USAGE
+
+    if args.healthcheck:
+        # Replace with a request that checks the credentials without
+        # notifying anyone, returning EXIT_FAILED if it fails
+        return EXIT_DELIVERED
+
+    try:
+        event = read_event()
+    except json.JSONDecodeError as e:
+        print(f'Error: invalid event on stdin: {e}', file=sys.stderr)
+        return EXIT_FAILED
+
+    # Bans and unbans name an IP; surge, restore and bulk_unban summarize
+    # many bans. Actions added in later versions are skipped, not failed.
+    action = event.get('action', 'ban')
+    if action not in ('ban', 'unban'):
+        print(f'Skipping {action} event')
+        return EXIT_DELIVERED
+
+    if args.dry_run:
+        print(json.dumps(event, indent=2))
+        return EXIT_DELIVERED
+
+    try:
+        status = deliver(config, event, args.timeout)
+    except urllib.error.HTTPError as e:
+        print(f"Error: {config['url']} answered HTTP {e.code}", file=sys.stderr)
+        return EXIT_FAILED
+    except (urllib.error.URLError, OSError) as e:
+        print(f"Error: request to {config['url']} failed: {e}", file=sys.stderr)
+        return EXIT_FAILED
+
+    print(f"Delivered {action} of {event.get('ip', '')} in {event.get('jail', '')} (HTTP {status})")
+    return EXIT_DELIVERED
+
+
+if __name__ == '__main__':
+    sys.exit(main())
+`
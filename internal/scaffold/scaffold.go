@@ -0,0 +1,111 @@
+// Package scaffold generates skeletons of custom connectors. Each skeleton
+// follows the contract script and executable connectors run under: event
+// fields in F2B_* environment variables, the whole event as JSON on stdin,
+// connector settings as environment variables, --healthcheck for probes and
+// the exit status telling delivery from failure.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+)
+
+// Language is a language a connector can be scaffolded in
+type Language struct {
+	Name    string   // As passed to "connector scaffold"
+	Aliases []string // Other accepted names
+	Ext     string   // Extension of the generated file
+	Type    string   // Connector type, executable once built
+	Runtime string   // What runs or builds the connector
+	source  string
+}
+
+// languages are the supported languages, in the order they are listed
+var languages = []Language{
+	{Name: "bash", Aliases: []string{"sh", "shell"}, Ext: ".sh", Type: config.ConnectorTypeScript, Runtime: "bash and curl", source: bashSource},
+	{Name: "python", Aliases: []string{"py", "python3"}, Ext: ".py", Type: config.ConnectorTypeScript, Runtime: "Python 3.6+, standard library only", source: pythonSource},
+	{Name: "node", Aliases: []string{"js", "javascript", "nodejs"}, Ext: ".js", Type: config.ConnectorTypeScript, Runtime: "Node.js 18+, no packages", source: nodeSource},
+	{Name: "go", Aliases: []string{"golang"}, Ext: ".go", Type: config.ConnectorTypeExecutable, Runtime: "Go 1.16+ to build, standard library only", source: goSource},
+}
+
+// Languages returns the supported languages
+func Languages() []Language {
+	return languages
+}
+
+// Lookup returns the language called name or one of its aliases
+func Lookup(name string) (Language, error) {
+	name = strings.ToLower(name)
+	for _, lang := range languages {
+		if lang.Name == name {
+			return lang, nil
+		}
+		for _, alias := range lang.Aliases {
+			if alias == name {
+				return lang, nil
+			}
+		}
+	}
+	names := make([]string, len(languages))
+	for i, lang := range languages {
+		names[i] = lang.Name
+	}
+	return Language{}, fmt.Errorf("unsupported language '%s', must be one of %s", name, strings.Join(names, ", "))
+}
+
+// validName matches connector names usable as file names and in
+// environment variable names
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// Options name the generated connector
+type Options struct {
+	Name string // Connector name, e.g. "myservice"
+	Path string // Where the connector will be installed, mentioned in its header
+}
+
+// templateData is what the skeletons are rendered with
+type templateData struct {
+	Name   string
+	Prefix string // Prefix of the connector's settings, e.g. "MYSERVICE"
+	Path   string
+	Lang   string
+	Ext    string
+}
+
+// Prefix returns the prefix of the settings of the connector called name
+func Prefix(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Settings returns example settings of the connector called name, the
+// environment variables its skeleton reads
+func Settings(name string) map[string]string {
+	prefix := Prefix(name)
+	return map[string]string{
+		prefix + "_URL":   "https://example.com/fail2ban",
+		prefix + "_TOKEN": "",
+	}
+}
+
+// Render returns the skeleton of a connector in lang
+func Render(lang Language, opts Options) ([]byte, error) {
+	if !validName.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid connector name '%s': use letters, digits, '-' and '_', starting with a letter", opts.Name)
+	}
+
+	tmpl, err := template.New(lang.Name).Parse(lang.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s skeleton: %w", lang.Name, err)
+	}
+	var b bytes.Buffer
+	data := templateData{Name: opts.Name, Prefix: Prefix(opts.Name), Path: opts.Path, Lang: lang.Name, Ext: lang.Ext}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s skeleton: %w", lang.Name, err)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,134 @@
+// Package seal encrypts files at rest with AES-256-GCM. Sealed data is a
+// single line of text, so a sealed JSON document can stand in for a line of
+// a JSON Lines file as well as for a whole state file, and data written
+// before encryption was enabled is told apart and read as it is.
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of a key in bytes
+const KeySize = 32
+
+// prefix starts sealed data, followed by the key ID, a colon and the
+// base64-encoded nonce and ciphertext
+const prefix = "f2b-sealed1:"
+
+// keyIDSize is the length of a key ID in bytes
+const keyIDSize = 4
+
+var (
+	// ErrKey is returned by Open for data sealed with another key, or
+	// sealed data opened without a key
+	ErrKey = errors.New("cannot decrypt")
+
+	// ErrCorrupt is returned by Open for sealed data that is truncated or
+	// was modified
+	ErrCorrupt = errors.New("corrupt sealed data")
+)
+
+// Sealer encrypts and decrypts data with one key. A nil Sealer leaves data
+// unencrypted.
+type Sealer struct {
+	aead cipher.AEAD
+	id   string
+}
+
+// New creates a sealer for a KeySize-byte key
+func New(key []byte) (*Sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &Sealer{aead: aead, id: hex.EncodeToString(sum[:keyIDSize])}, nil
+}
+
+// ParseKey decodes a hex or base64 key, such as one generated with
+// "openssl rand -hex 32"
+func ParseKey(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes, hex or base64 encoded", KeySize)
+}
+
+// KeyID identifies the key in sealed data, without revealing it
+func (s *Sealer) KeyID() string {
+	return s.id
+}
+
+// IsSealed reports whether data was sealed
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix))
+}
+
+// Seal encrypts data. With a nil Sealer, data is returned as it is.
+func (s *Sealer) Seal(data []byte) ([]byte, error) {
+	if s == nil {
+		return data, nil
+	}
+
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(data)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, data, nil)
+
+	head := prefix + s.id + ":"
+	out := make([]byte, len(head)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, head)
+	base64.StdEncoding.Encode(out[len(head):], sealed)
+	return out, nil
+}
+
+// Open decrypts sealed data. Data that was not sealed is returned as it
+// is, so files written before encryption was enabled stay readable.
+func (s *Sealer) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	id, encoded, found := bytes.Cut(bytes.TrimSpace(data[len(prefix):]), []byte(":"))
+	if !found {
+		return nil, ErrCorrupt
+	}
+	if s == nil {
+		return nil, fmt.Errorf("%w: data is encrypted, but no key is configured", ErrKey)
+	}
+	if string(id) != s.id {
+		return nil, fmt.Errorf("%w: data is encrypted with key %s, not the configured key %s", ErrKey, id, s.id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plain, nil
+}
@@ -0,0 +1,299 @@
+// Package secrets resolves the secret references in connector settings, so
+// tokens can stay in HashiCorp Vault, AWS Secrets Manager or systemd
+// credentials instead of the configuration file
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/sigv4"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// maxSecretResponse caps the responses of secret stores
+const maxSecretResponse = 1 << 20
+
+// Resolver resolves secret references and caches the values for the
+// configured time. A failed refresh keeps using the previous value, so an
+// outage of the secret store doesn't stop notifications.
+type Resolver struct {
+	cfg   *config.SecretsConfig
+	doer  types.HTTPDoer
+	clock types.Clock
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// cached is a resolved secret
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+// New creates a resolver
+func New(cfg *config.SecretsConfig) *Resolver {
+	return &Resolver{
+		cfg:   cfg,
+		doer:  http.DefaultClient,
+		clock: types.SystemClock,
+		cache: make(map[string]cached),
+	}
+}
+
+// SetHTTPDoer sends the requests to Vault and AWS through doer
+func (r *Resolver) SetHTTPDoer(doer types.HTTPDoer) {
+	r.doer = doer
+}
+
+// SetClock replaces the clock that expires cached secrets
+func (r *Resolver) SetClock(clock types.Clock) {
+	r.clock = clock
+}
+
+// Settings returns settings with the secret references replaced by their
+// values. Settings without references are returned as they are.
+func (r *Resolver) Settings(ctx context.Context, settings map[string]string) (map[string]string, error) {
+	if !config.HasSecretRefs(settings) {
+		return settings, nil
+	}
+
+	resolved := make(map[string]string, len(settings))
+	for key, value := range settings {
+		ref, err := config.ParseSecretRef(value)
+		if err != nil {
+			return nil, fmt.Errorf("setting %s: %w", key, err)
+		}
+		if ref != nil {
+			if value, err = r.Resolve(ctx, ref); err != nil {
+				return nil, fmt.Errorf("setting %s: %w", key, err)
+			}
+		}
+		resolved[key] = value
+	}
+	return resolved, nil
+}
+
+// Resolve returns the value of a secret reference
+func (r *Resolver) Resolve(ctx context.Context, ref *config.SecretRef) (string, error) {
+	key := ref.String()
+	now := r.clock.Now()
+
+	r.mu.Lock()
+	entry, found := r.cache[key]
+	r.mu.Unlock()
+	if found && now.Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	var value string
+	var err error
+	switch ref.Scheme {
+	case config.SecretSchemeVault:
+		value, err = r.vault(ctx, ref)
+	case config.SecretSchemeAWS:
+		value, err = r.aws(ctx, ref)
+	case config.SecretSchemeSystemd:
+		value, err = config.ReadSystemdCredential(ref.Path)
+	default:
+		err = fmt.Errorf("unknown secret scheme %s", ref.Scheme)
+	}
+	if err != nil {
+		if found {
+			return entry.value, nil
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", key, err)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = cached{value: value, expires: now.Add(time.Duration(r.cfg.CacheTTL) * time.Second)}
+	r.mu.Unlock()
+	return value, nil
+}
+
+// vault reads a secret from Vault. KV version 2 nests the fields under
+// data.data, version 1 under data; the path must include "data/" for
+// version 2, e.g. vault://secret/data/fail2ban#slack_webhook. Without a
+// key, the field "value" is read.
+func (r *Resolver) vault(ctx context.Context, ref *config.SecretRef) (string, error) {
+	address := r.cfg.Vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token, err := r.vaultToken()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(address, "/")+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if r.cfg.Vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.cfg.Vault.Namespace)
+	}
+
+	body, err := r.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid Vault response: %w", err)
+	}
+	fields := secret.Data
+	if nested, ok := fields["data"]; ok {
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return "", fmt.Errorf("invalid Vault KV v2 response: %w", err)
+		}
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = "value"
+	}
+	return field(fields, key)
+}
+
+// vaultToken reads the Vault token from the token file, $VAULT_TOKEN or
+// the file the vault CLI writes at login
+func (r *Resolver) vaultToken() (string, error) {
+	path := r.cfg.Vault.TokenFile
+	if path == "" {
+		if token := os.Getenv("VAULT_TOKEN"); token != "" {
+			return token, nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("no Vault token: set secrets.vault.token_file or VAULT_TOKEN")
+		}
+		path = filepath.Join(home, ".vault-token")
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Vault token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// aws reads a secret from AWS Secrets Manager. With a key, the secret
+// string is a JSON object and the key names one of its fields.
+func (r *Resolver) aws(ctx context.Context, ref *config.SecretRef) (string, error) {
+	creds := sigv4.EnvCredentials()
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return "", fmt.Errorf("AWS credentials missing, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	region := awsRegion(r.cfg.AWS.Region, ref.Path)
+	if region == "" {
+		return "", fmt.Errorf("AWS region unknown, set secrets.aws.region or AWS_REGION")
+	}
+	endpoint := r.cfg.AWS.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sigv4.Sign(req, body, creds, region, "secretsmanager", r.clock.Now().UTC())
+
+	resp, err := r.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(resp, &secret); err != nil {
+		return "", fmt.Errorf("invalid Secrets Manager response: %w", err)
+	}
+	if secret.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", ref.Path)
+	}
+	if ref.Key == "" {
+		return *secret.SecretString, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", ref.Path, err)
+	}
+	return field(fields, ref.Key)
+}
+
+// awsRegion returns the configured region, the region of a secret ARN or
+// the region of the environment
+func awsRegion(configured, secretID string) string {
+	if configured != "" {
+		return configured
+	}
+	// arn:aws:secretsmanager:<region>:<account>:secret:<name>
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// do sends a request to a secret store and returns the body of a
+// successful response
+func (r *Resolver) do(req *http.Request) ([]byte, error) {
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretResponse))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Error bodies name the problem, never the secret
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// field returns a string field of a secret; other JSON values are returned
+// as JSON
+func field(fields map[string]json.RawMessage, key string) (string, error) {
+	raw, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %s", key)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw), nil
+	}
+	return value, nil
+}
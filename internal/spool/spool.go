@@ -0,0 +1,311 @@
+package spool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// entryExt is the file extension of spooled entries
+const entryExt = ".json"
+
+// Entry is a notification waiting to be redelivered to a connector
+type Entry struct {
+	ID        string                 `json:"id"`
+	Connector string                 `json:"connector"`
+	Data      types.NotificationData `json:"data"`
+	Queued    time.Time              `json:"queued"`
+	Attempts  int                    `json:"attempts"`
+	LastError string                 `json:"last_error,omitempty"`
+
+	size int64
+}
+
+// Stats describes the current spool usage
+type Stats struct {
+	Events int       `json:"events"`
+	Bytes  int64     `json:"bytes"`
+	Oldest time.Time `json:"oldest,omitempty"`
+}
+
+// Spool is a directory of notifications that failed delivery
+type Spool struct {
+	cfg config.SpoolConfig
+
+	sealer  *seal.Sealer // nil unless encryption is enabled
+	sealErr error        // Failure to read the encryption key, returned by every operation
+}
+
+// New creates a spool for the given configuration. The entries hold the
+// events, so they are encrypted like the event store.
+func New(cfg config.SpoolConfig, encryption config.EncryptionConfig) *Spool {
+	s := &Spool{cfg: cfg}
+	if s.sealer, s.sealErr = encryption.Sealer(); s.sealErr != nil {
+		s.sealErr = fmt.Errorf("spool: %w", s.sealErr)
+	}
+	return s
+}
+
+// lockPath is the lock guarding modifications of the spool directory
+func (s *Spool) lockPath() string {
+	return filepath.Join(s.cfg.Dir, ".spool")
+}
+
+// Enqueue stores a failed notification for later redelivery, applying the
+// configured limits. It returns the number of entries dropped to make room.
+func (s *Spool) Enqueue(connector string, data *types.NotificationData, lastErr error, now time.Time) (int, error) {
+	unlock, err := state.Lock(s.lockPath())
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	entries, expired, err := s.dropExpired(entries, now)
+	if err != nil {
+		return 0, err
+	}
+	dropped += expired
+
+	entry := Entry{
+		ID:        newID(now, connector),
+		Connector: connector,
+		Data:      *data,
+		Queued:    now,
+	}
+	if lastErr != nil {
+		entry.LastError = lastErr.Error()
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err == nil {
+		encoded, err = s.sealer.Seal(encoded)
+	}
+	if err != nil {
+		return dropped, fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+
+	if s.fits(entries, int64(len(encoded))) {
+		return dropped, s.write(&entry)
+	}
+
+	switch s.cfg.Overflow {
+	case config.SpoolOverflowDropNew:
+		return dropped + 1, nil
+
+	case config.SpoolOverflowDigest:
+		// Fold the event into the newest entry for this connector
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Connector != connector {
+				continue
+			}
+			digest := entries[i]
+			entry.Data.Suppressed += digest.Data.Suppressed + 1
+			entry.ID = digest.ID
+			entry.Queued = digest.Queued
+			return dropped, s.write(&entry)
+		}
+		fallthrough
+
+	default: // drop-oldest
+		for len(entries) > 0 && !s.fits(entries, int64(len(encoded))) {
+			if err := s.remove(entries[0].ID); err != nil {
+				return dropped, err
+			}
+			entries = entries[1:]
+			dropped++
+		}
+		return dropped, s.write(&entry)
+	}
+}
+
+// Entries returns all spooled entries, oldest first
+func (s *Spool) Entries() ([]Entry, error) {
+	return s.entries()
+}
+
+// Remove deletes a delivered or abandoned entry
+func (s *Spool) Remove(id string) error {
+	return s.remove(id)
+}
+
+// Update rewrites an entry after a failed redelivery attempt
+func (s *Spool) Update(entry *Entry) error {
+	return s.write(entry)
+}
+
+// Prune removes entries older than the configured maximum age
+func (s *Spool) Prune(now time.Time) (int, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+	_, dropped, err := s.dropExpired(entries, now)
+	return dropped, err
+}
+
+// TryLockFlush takes the flush lock so only one process redelivers entries
+// at a time. It returns state.ErrLocked if another flush is running.
+func (s *Spool) TryLockFlush() (func(), error) {
+	return state.TryLock(filepath.Join(s.cfg.Dir, ".flush"))
+}
+
+// Stats returns the current spool usage
+func (s *Spool) Stats() (Stats, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	for i := range entries {
+		stats.Events++
+		stats.Bytes += entries[i].size
+		if stats.Oldest.IsZero() || entries[i].Queued.Before(stats.Oldest) {
+			stats.Oldest = entries[i].Queued
+		}
+	}
+	return stats, nil
+}
+
+// CountByConnector returns the number of queued entries per connector
+func (s *Spool) CountByConnector() (map[string]int, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for i := range entries {
+		counts[entries[i].Connector]++
+	}
+	return counts, nil
+}
+
+// fits reports whether an entry of the given size can be added without
+// exceeding the event and disk limits
+func (s *Spool) fits(entries []Entry, size int64) bool {
+	if s.cfg.MaxEvents > 0 && len(entries)+1 > s.cfg.MaxEvents {
+		return false
+	}
+
+	if s.cfg.MaxDiskMB > 0 {
+		total := size
+		for i := range entries {
+			total += entries[i].size
+		}
+		if total > int64(s.cfg.MaxDiskMB)*1024*1024 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dropExpired removes entries older than MaxAge
+func (s *Spool) dropExpired(entries []Entry, now time.Time) ([]Entry, int, error) {
+	if s.cfg.MaxAge <= 0 {
+		return entries, 0, nil
+	}
+
+	maxAge := time.Duration(s.cfg.MaxAge) * time.Second
+	kept := entries[:0]
+	dropped := 0
+	for _, entry := range entries {
+		if now.Sub(entry.Queued) > maxAge {
+			if err := s.remove(entry.ID); err != nil {
+				return nil, dropped, err
+			}
+			dropped++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept, dropped, nil
+}
+
+// entries reads all entries from the spool directory, oldest first
+func (s *Spool) entries() ([]Entry, error) {
+	if s.sealErr != nil {
+		return nil, s.sealErr
+	}
+
+	files, err := os.ReadDir(s.cfg.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != entryExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.cfg.Dir, name))
+		if err != nil {
+			continue // Removed by a concurrent flush
+		}
+
+		plain, err := s.sealer.Open(data)
+		if errors.Is(err, seal.ErrKey) {
+			return nil, fmt.Errorf("failed to read spool entry %s: %w", name, err)
+		}
+
+		var entry Entry
+		if err != nil || json.Unmarshal(plain, &entry) != nil {
+			continue
+		}
+		entry.size = int64(len(data))
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Queued.Before(entries[j].Queued)
+	})
+	return entries, nil
+}
+
+// write stores an entry under its ID
+func (s *Spool) write(entry *Entry) error {
+	if s.sealErr != nil {
+		return s.sealErr
+	}
+	return state.SaveSealed(filepath.Join(s.cfg.Dir, entry.ID+entryExt), entry, s.sealer)
+}
+
+// remove deletes the entry with the given ID
+func (s *Spool) remove(id string) error {
+	err := os.Remove(filepath.Join(s.cfg.Dir, filepath.Base(id)+entryExt))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool entry: %w", err)
+	}
+	return nil
+}
+
+// newID returns a unique, time-ordered entry ID
+func newID(now time.Time, connector string) string {
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%d-%s-%s", now.UnixNano(), filepath.Base(connector), hex.EncodeToString(buf))
+}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Files reads and writes state files, such as a store that encrypts the
+// files holding personal data
+type Files interface {
+	LoadState(path string, v interface{}) error
+	UpdateState(path string, v interface{}, fn func() error) error
+}
+
+// IPCache keeps the results of an external lookup per IP for a TTL, so
+// billed or rate-limited services are asked at most once per IP and TTL
+type IPCache struct {
+	files Files
+	path  string
+	ttl   time.Duration
+}
+
+type ipCacheEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Fetched time.Time       `json:"fetched"`
+}
+
+// NewIPCache creates a cache in the state file at path, read and written
+// through files
+func NewIPCache(files Files, path string, ttl time.Duration) *IPCache {
+	return &IPCache{files: files, path: path, ttl: ttl}
+}
+
+// PublicIP reports whether ip is worth looking up. Private, loopback and
+// link-local addresses are unknown to external services.
+func PublicIP(ip string) (bool, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	return !parsed.IsPrivate() && !parsed.IsLoopback() && !parsed.IsLinkLocalUnicast(), nil
+}
+
+// Get reads the cached result of ip into v. It returns false if there is
+// none younger than the TTL or the cache can't be read.
+func (c *IPCache) Get(ip string, now time.Time, v interface{}) bool {
+	cache := make(map[string]ipCacheEntry)
+	if err := c.files.LoadState(c.path, &cache); err != nil {
+		return false
+	}
+
+	entry, ok := cache[ip]
+	if !ok || len(entry.Value) == 0 || now.Sub(entry.Fetched) >= c.ttl {
+		return false
+	}
+	return json.Unmarshal(entry.Value, v) == nil
+}
+
+// Put caches the result v of ip and drops expired entries
+func (c *IPCache) Put(ip string, now time.Time, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string]ipCacheEntry)
+	return c.files.UpdateState(c.path, &cache, func() error {
+		for key, entry := range cache {
+			if now.Sub(entry.Fetched) >= c.ttl {
+				delete(cache, key)
+			}
+		}
+		cache[ip] = ipCacheEntry{Value: value, Fetched: now}
+		return nil
+	})
+}
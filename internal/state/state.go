@@ -0,0 +1,167 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal" //nolint:depguard
+)
+
+// File permissions
+const (
+	DirPermission  = 0750
+	FilePermission = 0600
+)
+
+// Load reads the JSON state file at path into v. A missing file leaves v untouched.
+func Load(path string, v interface{}) error {
+	return LoadSealed(path, v, nil)
+}
+
+// LoadSealed is Load for a state file encrypted with sealer. A file written
+// before encryption was enabled is read as it is.
+func LoadSealed(path string, v interface{}, sealer *seal.Sealer) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if data, err = sealer.Open(data); err != nil {
+		return fmt.Errorf("failed to decrypt state file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Save atomically writes v as JSON to the state file at path
+func Save(path string, v interface{}) error {
+	return SaveSealed(path, v, nil)
+}
+
+// SaveSealed is Save encrypting the state file with sealer
+func SaveSealed(path string, v interface{}, sealer *seal.Sealer) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if data, err = sealer.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt state: %w", err)
+	}
+
+	return WriteFileAtomic(path, data, FilePermission)
+}
+
+// WriteFileAtomic replaces path with data through a temporary file in the
+// same directory and a rename, so readers never see a partial file. The
+// directory is created if needed.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, DirPermission); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Update loads the state file at path into v, calls fn and saves v again,
+// holding an exclusive lock so concurrent fail2ban actions don't race
+func Update(path string, v interface{}, fn func() error) error {
+	return UpdateSealed(path, v, nil, fn)
+}
+
+// UpdateSealed is Update for a state file encrypted with sealer
+func UpdateSealed(path string, v interface{}, sealer *seal.Sealer, fn func() error) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := LoadSealed(path, v, sealer); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return SaveSealed(path, v, sealer)
+}
+
+// ErrLocked is returned by TryLock when another process holds the lock
+var ErrLocked = errors.New("state file is locked by another process")
+
+// Lock takes an exclusive lock on path and returns a function releasing it
+func Lock(path string) (func(), error) {
+	return lock(path, syscall.LOCK_EX)
+}
+
+// TryLock is like Lock but returns ErrLocked instead of waiting
+func TryLock(path string) (func(), error) {
+	return lock(path, syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func lock(path string, how int) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), DirPermission); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lockFile, err := os.OpenFile(filepath.Clean(path+".lock"), os.O_CREATE|os.O_RDWR, FilePermission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(lockFile.Fd()), how); err != nil {
+		_ = lockFile.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock state file: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		_ = lockFile.Close()
+	}, nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// AcksFile holds one Ack per line, oldest first
+const AcksFile = "acks.jsonl"
+
+// Sources of acknowledgments
+const (
+	AckSourceAPI       = "api"
+	AckSourceLink      = "link"
+	AckSourceSlack     = "slack"
+	AckSourcePagerDuty = "pagerduty"
+	AckSourceCLI       = "cli"
+)
+
+// ErrAlreadyAcknowledged is returned by Acknowledge for an event that was
+// acknowledged before
+var ErrAlreadyAcknowledged = errors.New("event already acknowledged")
+
+// Ack records that a responder acknowledged an event
+type Ack struct {
+	EventID   string    `json:"event_id"`
+	EventTime time.Time `json:"event_time"` // Lets acks be pruned with their event
+	Time      time.Time `json:"time"`
+	By        string    `json:"by,omitempty"`
+	Source    string    `json:"source"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// Acknowledge records an acknowledgment of the event whose ID is id or
+// starts with it. Only the first acknowledgment of an event is kept; later
+// ones return it with ErrAlreadyAcknowledged.
+func (s *Store) Acknowledge(id, by, source, note string, now time.Time) (*Ack, error) {
+	event, err := s.Event(id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	acks, err := s.Acks()
+	if err != nil {
+		return nil, err
+	}
+	if ack, found := acks[event.ID()]; found {
+		return &ack, ErrAlreadyAcknowledged
+	}
+
+	ack := Ack{EventID: event.ID(), EventTime: event.Time, Time: now.UTC(), By: by, Source: source, Note: note}
+	line, err := s.encodeLine(&ack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal acknowledgment: %w", err)
+	}
+
+	path := filepath.Join(s.cfg.Dir, AcksFile)
+	file, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, state.FilePermission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open acknowledgment store: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to write acknowledgment: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close acknowledgment store: %w", err)
+	}
+	return &ack, nil
+}
+
+// Acks returns the first acknowledgment of every acknowledged event, keyed
+// by event ID
+func (s *Store) Acks() (map[string]Ack, error) {
+	acks := make(map[string]Ack)
+
+	file, err := os.Open(filepath.Join(s.cfg.Dir, AcksFile))
+	if os.IsNotExist(err) {
+		return acks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open acknowledgment store: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ack Ack
+		ok, err := s.decodeLine(scanner.Bytes(), &ack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acknowledgment store: %w", err)
+		}
+		if !ok {
+			continue // Skip a line torn by a crash
+		}
+		if _, found := acks[ack.EventID]; !found {
+			acks[ack.EventID] = ack
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read acknowledgment store: %w", err)
+	}
+	return acks, nil
+}
+
+// Unacknowledged returns the bans since the given time in the jails for
+// which critical returns true that nobody acknowledged, newest first
+func (s *Store) Unacknowledged(critical func(jail string) bool, since time.Time) ([]types.NotificationData, error) {
+	acks, err := s.Acks()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []types.NotificationData
+	err = s.Scan(func(data *types.NotificationData) error {
+		if !data.IsBan() || data.Time.Before(since) || !critical(data.Jail) {
+			return nil
+		}
+		if _, found := acks[data.ID()]; !found {
+			pending = append(pending, *data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Time.After(pending[j].Time)
+	})
+	return pending, nil
+}
+
+// pruneAcks removes acknowledgments of events before cutoff. The caller
+// holds the store lock.
+func (s *Store) pruneAcks(cutoff time.Time) error {
+	acks, err := s.Acks()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Ack, 0, len(acks))
+	for _, ack := range acks {
+		if !ack.EventTime.Before(cutoff) {
+			kept = append(kept, ack)
+		}
+	}
+	if len(kept) == len(acks) {
+		return nil
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Time.Before(kept[j].Time)
+	})
+
+	var buf bytes.Buffer
+	for i := range kept {
+		line, err := s.encodeLine(&kept[i])
+		if err != nil {
+			return fmt.Errorf("failed to write acknowledgment store: %w", err)
+		}
+		buf.Write(line)
+	}
+
+	return state.WriteFileAtomic(filepath.Join(s.cfg.Dir, AcksFile), buf.Bytes(), state.FilePermission)
+}
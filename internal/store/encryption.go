@@ -0,0 +1,76 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+)
+
+// The events, results, acknowledgments and active bans hold addresses and
+// user names, so they are encrypted with encryption enabled, as are the
+// events of the chain and the state files other packages keep through
+// LoadState and UpdateState. Each line of the JSON Lines files is encrypted
+// on its own, which keeps them append-only. The rollups and rates only hold
+// counts and stay readable.
+
+// encodeLine returns v as a line of a JSON Lines file
+func (s *Store) encodeLine(v interface{}) ([]byte, error) {
+	if s.sealErr != nil {
+		return nil, s.sealErr
+	}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if line, err = s.sealer.Seal(line); err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// decodeLine parses a line of a JSON Lines file into v. It returns false
+// for a line torn by a crash, and an error for an encrypted line that the
+// configured key cannot decrypt.
+func (s *Store) decodeLine(line []byte, v interface{}) (bool, error) {
+	if s.sealErr != nil {
+		return false, s.sealErr
+	}
+	line, err := s.sealer.Open(line)
+	if errors.Is(err, seal.ErrKey) {
+		return false, err
+	}
+	if err != nil {
+		return false, nil
+	}
+	return json.Unmarshal(line, v) == nil, nil
+}
+
+// LoadState reads a state file holding personal data, such as those other
+// packages keep in the store directory, decrypting it with encryption
+// enabled
+func (s *Store) LoadState(path string, v interface{}) error {
+	if s.sealErr != nil {
+		return s.sealErr
+	}
+	return state.LoadSealed(path, v, s.sealer)
+}
+
+// SaveState writes a state file holding personal data, encrypted with
+// encryption enabled
+func (s *Store) SaveState(path string, v interface{}) error {
+	if s.sealErr != nil {
+		return s.sealErr
+	}
+	return state.SaveSealed(path, v, s.sealer)
+}
+
+// UpdateState updates a state file holding personal data like state.Update,
+// encrypted with encryption enabled
+func (s *Store) UpdateState(path string, v interface{}, fn func() error) error {
+	if s.sealErr != nil {
+		return s.sealErr
+	}
+	return state.UpdateSealed(path, v, s.sealer, fn)
+}
@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/campaign" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/chain"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/store"    //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"         //nolint:depguard
+)
+
+// TestEncryptionKeepsIPsOffDisk records a ban with encryption, the chain and
+// campaigns enabled and checks that no file in the store directory holds
+// the IP, while the store and the chain still read back
+func TestEncryptionKeepsIPsOffDisk(t *testing.T) {
+	const ip = "203.0.113.77"
+	t.Setenv("F2B_STORE_KEY", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+
+	dir := t.TempDir()
+	cfg := config.StoreConfig{
+		Enabled:   true,
+		Dir:       dir,
+		Retention: 86400,
+		Chain: config.ChainConfig{
+			Enabled: true,
+			Path:    filepath.Join(dir, "chain.jsonl"),
+			KeyFile: filepath.Join(dir, "chain.key"),
+		},
+		Encryption: config.EncryptionConfig{Enabled: true, Key: config.DefaultEncryptionKey},
+	}
+
+	data := &types.NotificationData{IP: ip, Jail: "sshd", Action: "ban", Time: time.Now().UTC(), ASN: "AS64500"}
+	st := store.New(cfg)
+	if err := st.Append(data); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	tracker := campaign.NewTracker(config.CampaignConfig{Enabled: true, Window: 3600, MinIPs: 1}, cfg, config.PrivacyConfig{})
+	if _, err := tracker.Assign(data); err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(content, []byte(ip)) {
+			t.Errorf("%s holds the IP in plain text", file.Name())
+		}
+	}
+	for _, name := range []string{store.EventsFile, store.BansFile, "chain.jsonl", campaign.File} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not written: %v", name, err)
+		}
+	}
+
+	history, err := st.History(ip, time.Now().Add(time.Minute))
+	if err != nil || history.BanCount != 1 {
+		t.Errorf("History = %+v, %v; want 1 ban", history, err)
+	}
+	if result, err := chain.Verify(cfg.Chain.Path, nil); err != nil || result.Records != 1 {
+		t.Errorf("Verify = %+v, %v; want 1 record", result, err)
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// importWindow is how far apart an imported event and a stored one of the
+// same address, jail and action may be to count as the same event. Events
+// recorded live are timestamped when the action ran, slightly after the log
+// line.
+const importWindow = 5 * time.Second
+
+// Import merges historical events into the store, keeping it ordered by
+// time. Events already stored, such as those recorded live or by an earlier
+// import, are skipped, so importing the same log twice is harmless. Active
+// bans are left alone: the log doesn't tell which old bans are still in
+// place. It returns the number of events added.
+func (s *Store) Import(events []types.NotificationData) (int, error) {
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var stored []types.NotificationData
+	seen := make(map[string][]time.Time)
+	err = s.Scan(func(data *types.NotificationData) error {
+		stored = append(stored, *data)
+		key := importKey(data)
+		seen[key] = append(seen[key], data.Time)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var imported []types.NotificationData
+	for i := range events {
+		key := importKey(&events[i])
+		if containsNear(seen[key], events[i].Time) {
+			continue
+		}
+		seen[key] = append(seen[key], events[i].Time)
+		stored = append(stored, events[i])
+		imported = append(imported, events[i])
+	}
+	if len(imported) == 0 {
+		return 0, nil
+	}
+
+	// Imported events land before counted ones, so the rollups are brought
+	// up to date first and count the imported events directly
+	now := time.Now()
+	if s.cfg.Rollups.Enabled {
+		if _, err := s.rollUp(now); err != nil {
+			return 0, fmt.Errorf("failed to roll up events: %w", err)
+		}
+	}
+
+	sort.SliceStable(stored, func(i, j int) bool {
+		return stored[i].Time.Before(stored[j].Time)
+	})
+	if err := s.rewriteEvents(stored); err != nil {
+		return 0, err
+	}
+	if s.cfg.Rollups.Enabled {
+		if err := s.rebaseRollups(now, imported); err != nil {
+			return len(imported), fmt.Errorf("failed to roll up events: %w", err)
+		}
+	}
+
+	// The chain is append-only, so imported events go to its end
+	if s.chain != nil {
+		for i := range imported {
+			if err := s.chain.Append(&imported[i], now); err != nil {
+				return len(imported), fmt.Errorf("failed to extend event chain: %w", err)
+			}
+		}
+	}
+	return len(imported), nil
+}
+
+// importKey identifies the events Import compares by time
+func importKey(data *types.NotificationData) string {
+	return data.Jail + "|" + data.Action + "|" + data.Subject()
+}
+
+// containsNear reports whether one of times is within importWindow of t
+func containsNear(times []time.Time, t time.Time) bool {
+	for _, other := range times {
+		d := other.Sub(t)
+		if d > -importWindow && d < importWindow {
+			return true
+		}
+	}
+	return false
+}
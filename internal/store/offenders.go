@@ -0,0 +1,99 @@
+package store
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// Defaults of the top offenders list
+const (
+	DefaultOffenderLimit   = 100
+	DefaultOffenderMinBans = 2
+	DefaultOffenderDays    = 30
+)
+
+// Offender is an address banned repeatedly
+type Offender struct {
+	IP       string    `json:"ip"`
+	Bans     int       `json:"bans"`
+	FirstBan time.Time `json:"first_ban"`
+	LastBan  time.Time `json:"last_ban"`
+	Jails    []string  `json:"jails"`
+	Country  string    `json:"country,omitempty"` // Country code of the last ban
+}
+
+// OffenderQuery selects the top offenders
+type OffenderQuery struct {
+	Since   time.Time // Only bans since then are counted
+	MinBans int       // Fewest bans to be listed
+	Limit   int       // Most offenders listed, 0 for all
+}
+
+// TopOffenders returns the addresses banned at least MinBans times since the
+// given time, most bans first, then the most recently banned. Pseudonymized,
+// private and other non-public addresses are left out, so the list can be
+// published.
+func (s *Store) TopOffenders(q OffenderQuery) ([]Offender, error) {
+	byIP := make(map[string]*Offender)
+	jails := make(map[string]map[string]bool)
+
+	err := s.Scan(func(data *types.NotificationData) error {
+		if !data.IsBan() || data.Time.Before(q.Since) || !publicIP(data.IP) {
+			return nil
+		}
+
+		offender, found := byIP[data.IP]
+		if !found {
+			offender = &Offender{IP: data.IP, FirstBan: data.Time}
+			byIP[data.IP] = offender
+			jails[data.IP] = make(map[string]bool)
+		}
+		offender.Bans++
+		if data.Time.After(offender.LastBan) {
+			offender.LastBan = data.Time
+			offender.Country = data.CountryCode
+		}
+		if data.Time.Before(offender.FirstBan) {
+			offender.FirstBan = data.Time
+		}
+		if !jails[data.IP][data.Jail] {
+			jails[data.IP][data.Jail] = true
+			offender.Jails = append(offender.Jails, data.Jail)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offenders := make([]Offender, 0, len(byIP))
+	for _, offender := range byIP {
+		if offender.Bans >= q.MinBans {
+			sort.Strings(offender.Jails)
+			offenders = append(offenders, *offender)
+		}
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Bans != offenders[j].Bans {
+			return offenders[i].Bans > offenders[j].Bans
+		}
+		if !offenders[i].LastBan.Equal(offenders[j].LastBan) {
+			return offenders[i].LastBan.After(offenders[j].LastBan)
+		}
+		return offenders[i].IP < offenders[j].IP
+	})
+
+	if q.Limit > 0 && len(offenders) > q.Limit {
+		offenders = offenders[:q.Limit]
+	}
+	return offenders, nil
+}
+
+// publicIP reports whether ip is a public unicast address
+func publicIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsGlobalUnicast() && !parsed.IsPrivate()
+}
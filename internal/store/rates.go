@@ -0,0 +1,70 @@
+package store
+
+import (
+	"path/filepath"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// RatesFile holds the per-jail ban counts used for surge detection
+const RatesFile = "rates.json"
+
+// jailRate counts the bans of one jail per window
+type jailRate struct {
+	Window  int           `json:"window"`            // Window length the buckets were counted with
+	Buckets map[int64]int `json:"buckets"`           // Window start (Unix seconds) -> bans
+	Alerted int64         `json:"alerted,omitempty"` // Start of the last window that raised a surge
+}
+
+// RecordRate counts a ban toward the rate of its jail and returns a surge
+// when the bans in the current window reach cfg.Factor times the average of
+// the previous cfg.Baseline windows. A surge is returned once per window.
+func (s *Store) RecordRate(cfg *config.SurgeConfig, data *types.NotificationData) (*types.Surge, error) {
+	if !data.IsBan() {
+		return nil, nil
+	}
+
+	window := int64(cfg.Window)
+	current := data.Time.Unix() / window * window
+	oldest := current - int64(cfg.Baseline)*window
+
+	rates := make(map[string]*jailRate)
+	var surge *types.Surge
+	err := state.Update(filepath.Join(s.cfg.Dir, RatesFile), &rates, func() error {
+		rate := rates[data.Jail]
+		if rate == nil || rate.Window != cfg.Window {
+			// Counts of a different window length cannot be compared
+			rate = &jailRate{Window: cfg.Window, Buckets: make(map[int64]int)}
+			rates[data.Jail] = rate
+		}
+
+		rate.Buckets[current]++
+
+		total := 0
+		for start, bans := range rate.Buckets {
+			switch {
+			case start < oldest:
+				delete(rate.Buckets, start)
+			case start < current:
+				total += bans
+			}
+		}
+
+		bans := rate.Buckets[current]
+		baseline := float64(total) / float64(cfg.Baseline)
+		if rate.Alerted == current || bans < cfg.MinBans || float64(bans) < cfg.Factor*baseline {
+			return nil
+		}
+
+		rate.Alerted = current
+		surge = &types.Surge{Bans: bans, Window: cfg.Window, Baseline: baseline}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return surge, nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// ResultsFile holds one Result per line, oldest first
+const ResultsFile = "results.jsonl"
+
+// Result is the outcome of delivering an event through one connector
+type Result struct {
+	Event  string    `json:"event_id,omitempty"` // ID of the event, empty in results recorded before event IDs
+	Time   time.Time `json:"time"`               // Time of the event
+	IP     string    `json:"ip"`
+	Jail   string    `json:"jail"`
+	Action string    `json:"action"`
+	types.ExecutionResult
+}
+
+// EventID returns the ID of the event the result belongs to
+func (r *Result) EventID() string {
+	if r.Event != "" {
+		return r.Event
+	}
+	return types.EventID(r.Time, r.IP, r.Jail, r.Action)
+}
+
+// AppendResults records the per-connector results of delivering an event,
+// including those of escalation connectors
+func (s *Store) AppendResults(batch *types.BatchResult) error {
+	if len(batch.Results) == 0 && len(batch.Escalation) == 0 {
+		return nil
+	}
+
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := filepath.Join(s.cfg.Dir, ResultsFile)
+	file, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, state.FilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to open result store: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	data := &batch.NotificationData
+	for _, result := range append(batch.Results[:len(batch.Results):len(batch.Results)], batch.Escalation...) {
+		record := Result{Event: data.EventID, Time: data.Time, IP: data.IP, Jail: data.Jail, Action: data.Action, ExecutionResult: result}
+		line, err := s.encodeLine(&record)
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return file.Close()
+}
+
+// ScanResults calls fn for every stored connector result, oldest first.
+// Returning an error from fn stops the scan and returns that error.
+func (s *Store) ScanResults(fn func(result *Result) error) error {
+	file, err := os.Open(filepath.Join(s.cfg.Dir, ResultsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open result store: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var result Result
+		ok, err := s.decodeLine(scanner.Bytes(), &result)
+		if err != nil {
+			return fmt.Errorf("failed to read result store: %w", err)
+		}
+		if !ok {
+			continue // Skip a line torn by a crash
+		}
+		if err := fn(&result); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read result store: %w", err)
+	}
+
+	return nil
+}
+
+// EventResults returns the connector results of the event with the given ID
+func (s *Store) EventResults(id string) ([]Result, error) {
+	var results []Result
+	err := s.ScanResults(func(result *Result) error {
+		if result.EventID() == id {
+			results = append(results, *result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// pruneResults removes results of events before cutoff. The caller holds
+// the store lock.
+func (s *Store) pruneResults(cutoff time.Time) error {
+	var kept []Result
+	removed := 0
+	err := s.ScanResults(func(result *Result) error {
+		if result.Time.Before(cutoff) {
+			removed++
+		} else {
+			kept = append(kept, *result)
+		}
+		return nil
+	})
+	if err != nil || removed == 0 {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for i := range kept {
+		line, err := s.encodeLine(&kept[i])
+		if err != nil {
+			return fmt.Errorf("failed to write result store: %w", err)
+		}
+		buf.Write(line)
+	}
+
+	return state.WriteFileAtomic(filepath.Join(s.cfg.Dir, ResultsFile), buf.Bytes(), state.FilePermission)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types" //nolint:depguard
+)
+
+// retentionHookTimeout limits how long the retention hook may run
+const retentionHookTimeout = 5 * time.Minute
+
+// runRetentionHook runs the configured retention hook with the pruned events
+// on stdin, one JSON object per line, so copies kept elsewhere, such as chat
+// messages or SIEM records, can be deleted on the same schedule
+func (s *Store) runRetentionHook(cutoff time.Time, pruned []types.NotificationData) error {
+	if s.cfg.RetentionHook == "" {
+		return nil
+	}
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for i := range pruned {
+		if err := encoder.Encode(&pruned[i]); err != nil {
+			return fmt.Errorf("failed to marshal pruned event: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), retentionHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.cfg.RetentionHook)
+	cmd.Stdin = &input
+	cmd.Env = append(os.Environ(),
+		"F2B_RETENTION_CUTOFF="+cutoff.UTC().Format(time.RFC3339),
+		"F2B_PRUNED_EVENTS="+strconv.Itoa(len(pruned)),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("retention hook failed: %w, output: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
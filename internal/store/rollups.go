@@ -0,0 +1,291 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// RollupsFile holds the hourly and daily counts of the stored events
+const RollupsFile = "rollups.json"
+
+// day is the period of daily rollups, which start at midnight UTC
+const day = 24 * time.Hour
+
+// Rollup counts the bans and unbans of one jail, country and network in an
+// hour or a day
+type Rollup struct {
+	Start       time.Time `json:"start"`
+	Jail        string    `json:"jail"`
+	Country     string    `json:"country,omitempty"`
+	CountryCode string    `json:"country_code,omitempty"`
+	ASN         string    `json:"asn,omitempty"`
+	Bans        int       `json:"bans,omitempty"`
+	Unbans      int       `json:"unbans,omitempty"`
+}
+
+// rollupState is the content of RollupsFile. The rollups count exactly the
+// events in the first Offset bytes of the events file; those after it are
+// counted by the next run.
+type rollupState struct {
+	Offset int64    `json:"offset"`
+	Hourly []Rollup `json:"hourly"`
+	Daily  []Rollup `json:"daily"`
+}
+
+// rollupKey identifies a rollup
+type rollupKey struct {
+	start                      int64
+	jail, country, countryCode string
+	asn                        string
+}
+
+// rollupSet indexes rollups for counting
+type rollupSet map[rollupKey]*Rollup
+
+func newRollupSet(rollups []Rollup) rollupSet {
+	set := make(rollupSet, len(rollups))
+	for i := range rollups {
+		r := rollups[i]
+		set[rollupKey{r.Start.Unix(), r.Jail, r.Country, r.CountryCode, r.ASN}] = &r
+	}
+	return set
+}
+
+// count adds a ban or unban to the rollup of its period starting at start
+func (set rollupSet) count(start time.Time, data *types.NotificationData) {
+	if !data.IsBan() && !data.IsUnban() {
+		return
+	}
+	key := rollupKey{start.Unix(), data.Jail, data.Country, data.CountryCode, data.ASN}
+	r, ok := set[key]
+	if !ok {
+		r = &Rollup{Start: start, Jail: data.Jail, Country: data.Country, CountryCode: data.CountryCode, ASN: data.ASN}
+		set[key] = r
+	}
+	if data.IsBan() {
+		r.Bans++
+	} else {
+		r.Unbans++
+	}
+}
+
+// list returns the rollups starting at or after cutoff, oldest first
+func (set rollupSet) list(cutoff time.Time) []Rollup {
+	rollups := make([]Rollup, 0, len(set))
+	for _, r := range set {
+		if !r.Start.Before(cutoff) {
+			rollups = append(rollups, *r)
+		}
+	}
+	sortRollups(rollups)
+	return rollups
+}
+
+// sortRollups orders rollups by start, then jail, country and network
+func sortRollups(rollups []Rollup) {
+	sort.Slice(rollups, func(i, j int) bool {
+		a, b := &rollups[i], &rollups[j]
+		switch {
+		case !a.Start.Equal(b.Start):
+			return a.Start.Before(b.Start)
+		case a.Jail != b.Jail:
+			return a.Jail < b.Jail
+		case a.CountryCode != b.CountryCode:
+			return a.CountryCode < b.CountryCode
+		case a.Country != b.Country:
+			return a.Country < b.Country
+		}
+		return a.ASN < b.ASN
+	})
+}
+
+// hourOf returns the start of the hourly rollup of t
+func hourOf(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+// dayOf returns the start of the daily rollup of t
+func dayOf(t time.Time) time.Time {
+	return t.UTC().Truncate(day)
+}
+
+// HourlyFrom returns the start of the oldest hourly rollup kept at now.
+// Earlier periods are only counted per day.
+func (s *Store) HourlyFrom(now time.Time) time.Time {
+	return dayOf(now.Add(-time.Duration(s.cfg.Rollups.HourlyRetention) * time.Second))
+}
+
+// rollupsPath is the state file holding the rollups
+func (s *Store) rollupsPath() string {
+	return filepath.Join(s.cfg.Dir, RollupsFile)
+}
+
+// RollUp counts the events stored since its last run into the hourly and
+// daily rollups, and removes the rollups older than their retention. It
+// returns the number of events read.
+func (s *Store) RollUp(now time.Time) (int, error) {
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return s.rollUp(now)
+}
+
+// rollUp is RollUp with the store's lock held
+func (s *Store) rollUp(now time.Time) (int, error) {
+	read := 0
+	err := s.updateRollups(now, func(rollups *rollupState, hourly, daily rollupSet) error {
+		var err error
+		rollups.Offset, err = s.scanFrom(rollups.Offset, func(data *types.NotificationData) {
+			hourly.count(hourOf(data.Time), data)
+			daily.count(dayOf(data.Time), data)
+			read++
+		})
+		return err
+	})
+	return read, err
+}
+
+// rebaseRollups counts events added by rewriting the events file, which
+// rollUp must have counted up to just before, and moves the offset to its
+// new end
+func (s *Store) rebaseRollups(now time.Time, added []types.NotificationData) error {
+	return s.updateRollups(now, func(rollups *rollupState, hourly, daily rollupSet) error {
+		for i := range added {
+			hourly.count(hourOf(added[i].Time), &added[i])
+			daily.count(dayOf(added[i].Time), &added[i])
+		}
+		info, err := os.Stat(filepath.Join(s.cfg.Dir, EventsFile))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read event store: %w", err)
+		}
+		rollups.Offset = 0
+		if info != nil {
+			rollups.Offset = info.Size()
+		}
+		return nil
+	})
+}
+
+// updateRollups lets fn count into the rollups and saves them without those
+// past their retention
+func (s *Store) updateRollups(now time.Time, fn func(rollups *rollupState, hourly, daily rollupSet) error) error {
+	var rollups rollupState
+	return state.Update(s.rollupsPath(), &rollups, func() error {
+		hourly, daily := newRollupSet(rollups.Hourly), newRollupSet(rollups.Daily)
+		if err := fn(&rollups, hourly, daily); err != nil {
+			return err
+		}
+
+		var dailyFrom time.Time
+		if retention := s.cfg.Rollups.DailyRetention; retention > 0 {
+			dailyFrom = dayOf(now.Add(-time.Duration(retention) * time.Second))
+		}
+		rollups.Hourly = hourly.list(s.HourlyFrom(now))
+		rollups.Daily = daily.list(dailyFrom)
+		return nil
+	})
+}
+
+// scanFrom calls fn for every event in the complete lines of the events
+// file after offset and returns the offset of the end of the last one. An
+// offset beyond the end of the file, which was replaced while rollups were
+// not kept, starts over.
+func (s *Store) scanFrom(offset int64, fn func(data *types.NotificationData)) (int64, error) {
+	file, err := os.Open(filepath.Join(s.cfg.Dir, EventsFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return offset, fmt.Errorf("failed to open event store: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return offset, fmt.Errorf("failed to read event store: %w", err)
+	}
+	if offset > info.Size() {
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, fmt.Errorf("failed to read event store: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			offset += int64(len(line))
+			var data types.NotificationData
+			ok, err := s.decodeLine(line, &data)
+			if err != nil {
+				return offset - int64(len(line)), fmt.Errorf("failed to read event store: %w", err)
+			}
+			if ok {
+				fn(&data)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// A line still being written is counted next time
+			return offset, nil
+		}
+		if err != nil {
+			return offset, fmt.Errorf("failed to read event store: %w", err)
+		}
+	}
+}
+
+// Rollups returns the counts of the bans and unbans from since to until,
+// or to the end with a zero until. Rolled-up periods are counted per hour,
+// and per day before HourlyFrom, so since and until are rounded down to the
+// start of their period; events not rolled up yet are counted per hour of
+// the exact times.
+func (s *Store) Rollups(since, until, now time.Time) ([]Rollup, error) {
+	var rollups rollupState
+	if err := state.Load(s.rollupsPath(), &rollups); err != nil {
+		return nil, err
+	}
+
+	hourlyFrom := s.HourlyFrom(now)
+	inRange := func(start, from time.Time) bool {
+		return !start.Before(from) && (until.IsZero() || start.Before(until))
+	}
+
+	var result []Rollup
+	for _, r := range rollups.Daily {
+		if r.Start.Before(hourlyFrom) && inRange(r.Start, dayOf(since)) {
+			result = append(result, r)
+		}
+	}
+	for _, r := range rollups.Hourly {
+		if !r.Start.Before(hourlyFrom) && inRange(r.Start, hourOf(since)) {
+			result = append(result, r)
+		}
+	}
+
+	recent := make(rollupSet)
+	_, err := s.scanFrom(rollups.Offset, func(data *types.NotificationData) {
+		if !data.Time.Before(since) && (until.IsZero() || data.Time.Before(until)) {
+			recent.count(hourOf(data.Time), data)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, recent.list(time.Time{})...)
+	sortRollups(result)
+	return result, nil
+}
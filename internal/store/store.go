@@ -0,0 +1,391 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/chain"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/seal"   //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Files inside the store directory
+const (
+	EventsFile = "events.jsonl" // One NotificationData per line, oldest first
+	BansFile   = "bans.json"    // Currently banned IPs
+	prunedFile = "pruned.json"  // Time of the last retention run
+)
+
+// pruneInterval is how often Append applies the retention period
+const pruneInterval = 24 * time.Hour
+
+// Ban is an IP currently banned in a jail
+type Ban struct {
+	IP          string    `json:"ip"`
+	Jail        string    `json:"jail"`
+	Since       time.Time `json:"since"`
+	Expires     time.Time `json:"expires,omitempty"` // Zero if permanent or unknown
+	Country     string    `json:"country,omitempty"`
+	CountryCode string    `json:"country_code,omitempty"`
+	Failures    int       `json:"failures,omitempty"`
+}
+
+// Expired reports whether the ban has run out at now
+func (b *Ban) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+// Store keeps the history of ban and unban events and the set of active bans
+type Store struct {
+	cfg   config.StoreConfig
+	chain *chain.Log // nil unless the hash-chained log is enabled
+
+	sealer  *seal.Sealer // nil unless encryption is enabled
+	sealErr error        // Failure to read the encryption key, returned by every operation
+}
+
+// New creates a store for the given configuration
+func New(cfg config.StoreConfig) *Store {
+	s := &Store{cfg: cfg}
+	if s.sealer, s.sealErr = cfg.Encryption.Sealer(); s.sealErr != nil {
+		s.sealErr = fmt.Errorf("store: %w", s.sealErr)
+	}
+	if cfg.Chain.Enabled {
+		s.chain = chain.New(cfg.Chain, s.sealer)
+	}
+	return s
+}
+
+// bansPath is the state file holding the active bans. Its lock also guards
+// the events file.
+func (s *Store) bansPath() string {
+	return filepath.Join(s.cfg.Dir, BansFile)
+}
+
+// Append records an event and updates the active bans
+func (s *Store) Append(data *types.NotificationData) error {
+	bans := make(map[string]Ban)
+	var chainErr error
+	err := s.UpdateState(s.bansPath(), &bans, func() error {
+		if err := s.appendEvent(data); err != nil {
+			return err
+		}
+		// Under the same lock, so the chain has the events in store order.
+		// A failure is reported once the bans are saved.
+		if s.chain != nil {
+			chainErr = s.chain.Append(data, time.Now())
+		}
+
+		key := banKey(data.Jail, data.Subject())
+		switch {
+		case data.IsBan():
+			ban := Ban{
+				IP:          data.IP,
+				Jail:        data.Jail,
+				Since:       data.Time,
+				Country:     data.Country,
+				CountryCode: data.CountryCode,
+				Failures:    data.Failures,
+			}
+			if data.BanTime > 0 {
+				ban.Expires = data.Time.Add(time.Duration(data.BanTime) * time.Second)
+			}
+			bans[key] = ban
+		case data.IsUnban():
+			delete(bans, key)
+		}
+
+		for key, ban := range bans {
+			if ban.Expired(data.Time) {
+				delete(bans, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if chainErr != nil {
+		return fmt.Errorf("failed to extend event chain: %w", chainErr)
+	}
+
+	if err := s.maybePrune(data.Time); err != nil {
+		return fmt.Errorf("failed to apply retention: %w", err)
+	}
+	return nil
+}
+
+// ActiveBans returns the bans that have not expired at now, oldest first
+func (s *Store) ActiveBans(now time.Time) ([]Ban, error) {
+	bans := make(map[string]Ban)
+	if err := s.LoadState(s.bansPath(), &bans); err != nil {
+		return nil, err
+	}
+
+	active := make([]Ban, 0, len(bans))
+	for _, ban := range bans {
+		if !ban.Expired(now) {
+			active = append(active, ban)
+		}
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Since.Before(active[j].Since)
+	})
+	return active, nil
+}
+
+// IsBanned reports whether subject has a ban in jail that has not expired
+// at now
+func (s *Store) IsBanned(jail, subject string, now time.Time) (bool, error) {
+	bans := make(map[string]Ban)
+	if err := s.LoadState(s.bansPath(), &bans); err != nil {
+		return false, err
+	}
+	ban, ok := bans[banKey(jail, subject)]
+	return ok && !ban.Expired(now), nil
+}
+
+// Modified returns when the active bans last changed
+func (s *Store) Modified() time.Time {
+	info, err := os.Stat(s.bansPath())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Scan calls fn for every stored event, oldest first. Returning an error
+// from fn stops the scan and returns that error.
+func (s *Store) Scan(fn func(data *types.NotificationData) error) error {
+	file, err := os.Open(filepath.Join(s.cfg.Dir, EventsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open event store: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var data types.NotificationData
+		ok, err := s.decodeLine(scanner.Bytes(), &data)
+		if err != nil {
+			return fmt.Errorf("failed to read event store: %w", err)
+		}
+		if !ok {
+			continue // Skip a line torn by a crash
+		}
+		if err := fn(&data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event store: %w", err)
+	}
+
+	return nil
+}
+
+// ErrEventNotFound is returned by Event for an unknown event ID
+var ErrEventNotFound = errors.New("event not found")
+
+// Event returns the event whose ID is id or starts with it, like an
+// abbreviated git commit. A prefix matching several events is an error.
+func (s *Store) Event(id string) (*types.NotificationData, error) {
+	var found *types.NotificationData
+	err := s.Scan(func(data *types.NotificationData) error {
+		if !strings.HasPrefix(data.ID(), id) {
+			return nil
+		}
+		if found != nil && found.ID() != data.ID() {
+			return fmt.Errorf("event ID %s is ambiguous", id)
+		}
+		event := *data
+		found = &event
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, id)
+	}
+	return found, nil
+}
+
+// Prune removes events older than the retention period and passes them to
+// the retention hook, if one is configured
+func (s *Store) Prune(now time.Time) (int, error) {
+	cutoff := now.Add(-time.Duration(s.cfg.Retention) * time.Second)
+
+	pruned, err := s.pruneEvents(cutoff, now)
+	if err != nil || len(pruned) == 0 {
+		return 0, err
+	}
+
+	// Run the hook without holding the lock
+	if err := s.runRetentionHook(cutoff, pruned); err != nil {
+		return len(pruned), err
+	}
+	return len(pruned), nil
+}
+
+// pruneEvents removes the events, results and acknowledgments older than
+// cutoff and returns the removed events. With rollups, the events are
+// counted before they are removed.
+func (s *Store) pruneEvents(cutoff, now time.Time) ([]types.NotificationData, error) {
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if s.cfg.Rollups.Enabled {
+		if _, err := s.rollUp(now); err != nil {
+			return nil, fmt.Errorf("failed to roll up events: %w", err)
+		}
+	}
+
+	var kept, pruned []types.NotificationData
+	err = s.Scan(func(data *types.NotificationData) error {
+		if data.Time.Before(cutoff) {
+			pruned = append(pruned, *data)
+		} else {
+			kept = append(kept, *data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.pruneResults(cutoff); err != nil {
+		return nil, err
+	}
+	if err := s.pruneAcks(cutoff); err != nil {
+		return nil, err
+	}
+
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+	if err := s.rewriteEvents(kept); err != nil {
+		return nil, err
+	}
+	if s.cfg.Rollups.Enabled {
+		if err := s.rebaseRollups(now, nil); err != nil {
+			return nil, fmt.Errorf("failed to roll up events: %w", err)
+		}
+	}
+	return pruned, nil
+}
+
+// maybePrune applies the retention period at most once per pruneInterval
+func (s *Store) maybePrune(now time.Time) error {
+	var last time.Time
+	return state.Update(filepath.Join(s.cfg.Dir, prunedFile), &last, func() error {
+		if now.Sub(last) < pruneInterval {
+			return nil
+		}
+		last = now
+		_, err := s.Prune(now)
+		return err
+	})
+}
+
+// appendEvent writes one event to the end of the events file
+func (s *Store) appendEvent(data *types.NotificationData) error {
+	line, err := s.encodeLine(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	path := filepath.Join(s.cfg.Dir, EventsFile)
+	file, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, state.FilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to open event store: %w", err)
+	}
+
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+
+	return file.Close()
+}
+
+// rewriteEvents atomically replaces the events file
+func (s *Store) rewriteEvents(events []types.NotificationData) error {
+	var buf bytes.Buffer
+	for i := range events {
+		line, err := s.encodeLine(&events[i])
+		if err != nil {
+			return fmt.Errorf("failed to write event store: %w", err)
+		}
+		buf.Write(line)
+	}
+
+	return state.WriteFileAtomic(filepath.Join(s.cfg.Dir, EventsFile), buf.Bytes(), state.FilePermission)
+}
+
+// banKey identifies a ban of an address in a jail
+func banKey(jail, subject string) string {
+	return jail + "|" + subject
+}
+
+// LastBan returns the most recent stored ban of an address before the given
+// time, in any jail, or nil if there is none. subject is as for History.
+func (s *Store) LastBan(subject string, before time.Time) (*types.NotificationData, error) {
+	var last *types.NotificationData
+	err := s.Scan(func(data *types.NotificationData) error {
+		if data.Subject() != subject || !data.IsBan() || !data.Time.Before(before) {
+			return nil
+		}
+		if last == nil || !data.Time.Before(last.Time) {
+			ban := *data
+			last = &ban
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return last, nil
+}
+
+// History summarizes the stored bans of an address before the given time.
+// subject is the IP, or its hash in privacy mode (see NotificationData.Subject).
+func (s *Store) History(subject string, before time.Time) (*types.History, error) {
+	history := &types.History{}
+	err := s.Scan(func(data *types.NotificationData) error {
+		if data.Subject() != subject || !data.IsBan() || !data.Time.Before(before) {
+			return nil
+		}
+		if history.BanCount == 0 || data.Time.Before(history.FirstSeen) {
+			history.FirstSeen = data.Time
+		}
+		if data.Time.After(history.LastSeen) {
+			history.LastSeen = data.Time
+		}
+		history.BanCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
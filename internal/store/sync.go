@@ -0,0 +1,103 @@
+package store
+
+import (
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/state" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"      //nolint:depguard
+)
+
+// SyncResult lists the changes Reconcile made to the active bans
+type SyncResult struct {
+	Jails   []string `json:"jails"`
+	Added   []Ban    `json:"added"`   // Banned in fail2ban but missing from the store
+	Removed []Ban    `json:"removed"` // Active in the store but no longer banned in fail2ban
+	Kept    int      `json:"kept"`    // Bans both agree on
+}
+
+// Reconcile makes the active bans of the given jails match what fail2ban
+// reports. current has a ban event for every address banned in those jails
+// now. Missing bans are added with the time and location of their latest
+// stored ban event, if any; bans fail2ban no longer has are removed. The
+// event history is not changed. With dryRun the bans are not saved.
+func (s *Store) Reconcile(jails []string, current []types.NotificationData, now time.Time, dryRun bool) (*SyncResult, error) {
+	unlock, err := state.Lock(s.bansPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	bans := make(map[string]Ban)
+	if err := s.LoadState(s.bansPath(), &bans); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Jails: jails, Added: []Ban{}, Removed: []Ban{}}
+	queried := make(map[string]bool, len(jails))
+	for _, jail := range jails {
+		queried[jail] = true
+	}
+
+	wanted := make(map[string]*types.NotificationData, len(current))
+	for i := range current {
+		wanted[banKey(current[i].Jail, current[i].Subject())] = &current[i]
+	}
+
+	for key, ban := range bans {
+		if !queried[ban.Jail] {
+			continue
+		}
+		if _, ok := wanted[key]; ok && !ban.Expired(now) {
+			result.Kept++
+			continue
+		}
+		// Expired bans fail2ban still holds are added again below
+		delete(bans, key)
+		if !ban.Expired(now) {
+			result.Removed = append(result.Removed, ban)
+		}
+	}
+
+	latest, err := s.latestBans(wanted)
+	if err != nil {
+		return nil, err
+	}
+	for key, data := range wanted {
+		if _, ok := bans[key]; ok {
+			continue
+		}
+		ban := Ban{IP: data.IP, Jail: data.Jail, Since: now}
+		if event, ok := latest[key]; ok {
+			ban.Since = event.Time
+			ban.Country = event.Country
+			ban.CountryCode = event.CountryCode
+			ban.Failures = event.Failures
+		}
+		bans[key] = ban
+		result.Added = append(result.Added, ban)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := s.SaveState(s.bansPath(), bans); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// latestBans returns the most recent stored ban event for each of the keys
+func (s *Store) latestBans(keys map[string]*types.NotificationData) (map[string]types.NotificationData, error) {
+	latest := make(map[string]types.NotificationData)
+	err := s.Scan(func(data *types.NotificationData) error {
+		if !data.IsBan() {
+			return nil
+		}
+		key := banKey(data.Jail, data.Subject())
+		if _, ok := keys[key]; ok && !data.Time.Before(latest[key].Time) {
+			latest[key] = *data
+		}
+		return nil
+	})
+	return latest, err
+}
@@ -0,0 +1,164 @@
+package tail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPollInterval is how often the file is checked for new data
+const DefaultPollInterval = time.Second
+
+// Follow reads lines appended to the file at path and sends them to lines
+// until ctx is canceled. It starts at the end of the file and reopens it
+// when it is rotated or truncated, like "tail -F".
+func Follow(ctx context.Context, path string, poll time.Duration, lines chan<- string) error {
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+
+	f := &follower{path: filepath.Clean(path)}
+	defer f.close()
+
+	if err := f.open(true); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		if err := f.readLines(ctx, lines); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := f.checkRotation(); err != nil {
+			return err
+		}
+	}
+}
+
+// follower tracks the currently open file and a partial trailing line
+type follower struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+}
+
+// open opens the file, optionally seeking to its end
+func (f *follower) open(atEnd bool) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	f.offset = 0
+	if atEnd {
+		f.offset, err = file.Seek(0, io.SeekEnd)
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to seek %s: %w", f.path, err)
+		}
+	}
+
+	f.file = file
+	f.info = info
+	f.reader = bufio.NewReader(file)
+	f.partial = ""
+	return nil
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		_ = f.file.Close()
+		f.file = nil
+	}
+}
+
+// readLines sends all complete lines currently available
+func (f *follower) readLines(ctx context.Context, lines chan<- string) error {
+	if f.file == nil {
+		return nil
+	}
+
+	for {
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+
+		if err != nil {
+			// Keep incomplete lines until the writer finishes them
+			f.partial += chunk
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+
+		line := f.partial + chunk[:len(chunk)-1]
+		f.partial = ""
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// checkRotation reopens the file if it was replaced or truncated
+func (f *follower) checkRotation() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Rotated away; wait for the new file
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	switch {
+	case f.file == nil:
+		// The file appeared after startup: read it from the beginning
+		return ignoreMissing(f.open(false))
+	case !os.SameFile(info, f.info):
+		// Replaced by rotation: finish nothing, start the new file from the top
+		f.close()
+		return ignoreMissing(f.open(false))
+	case info.Size() < f.offset:
+		// Truncated in place (copytruncate)
+		f.close()
+		return ignoreMissing(f.open(false))
+	}
+
+	return nil
+}
+
+func ignoreMissing(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package throttle
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/state"  //nolint:depguard
+)
+
+// Limiter enforces per-connector message caps using state shared
+// between invocations
+type Limiter struct {
+	dir string
+}
+
+// Decision is the outcome of a throttle check
+type Decision struct {
+	Allowed    bool // The message may be delivered
+	Suppressed int  // Messages suppressed since the last delivered one
+}
+
+type connectorState struct {
+	Sent       []time.Time `json:"sent"`
+	Suppressed int         `json:"suppressed"`
+}
+
+// NewLimiter creates a limiter storing its state below stateDir
+func NewLimiter(stateDir string) *Limiter {
+	return &Limiter{dir: filepath.Join(stateDir, "throttle")}
+}
+
+// Allow records an attempt to deliver through the named connector and
+// reports whether it stays within the configured cap. When a message is
+// allowed after others were suppressed, the suppressed count is returned
+// and reset so the connector can mention it.
+func (l *Limiter) Allow(connector string, cfg *config.ThrottleConfig, now time.Time) (Decision, error) {
+	var decision Decision
+	var st connectorState
+
+	path := filepath.Join(l.dir, filepath.Base(connector)+".json")
+	err := state.Update(path, &st, func() error {
+		window := time.Duration(cfg.Window) * time.Second
+		recent := st.Sent[:0]
+		for _, sent := range st.Sent {
+			if now.Sub(sent) < window {
+				recent = append(recent, sent)
+			}
+		}
+		st.Sent = recent
+
+		if len(st.Sent) >= cfg.MaxMessages {
+			st.Suppressed++
+			return nil
+		}
+
+		decision.Allowed = true
+		decision.Suppressed = st.Suppressed
+		st.Suppressed = 0
+		st.Sent = append(st.Sent, now)
+		return nil
+	})
+	if err != nil {
+		return Decision{Allowed: true}, err
+	}
+
+	return decision, nil
+}
+
+// Pending returns the number of suppressed messages not yet reported
+func (l *Limiter) Pending(connector string) (int, error) {
+	var st connectorState
+	if err := state.Load(filepath.Join(l.dir, filepath.Base(connector)+".json"), &st); err != nil {
+		return 0, err
+	}
+	return st.Suppressed, nil
+}
@@ -0,0 +1,81 @@
+package transform
+
+import (
+	"strings"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config"  //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/internal/privacy" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"        //nolint:depguard
+)
+
+// Default prefixes kept by PayloadFields.MaskIP when privacy mode doesn't
+// set them
+const (
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 48
+)
+
+// Select returns a copy of the event with only the fields the connector's
+// payload selection lets through. When the IP is masked or left out, it is
+// also removed from the matched log lines.
+func Select(payload *config.PayloadFields, privacyCfg *config.PrivacyConfig, data *types.NotificationData) (*types.NotificationData, error) {
+	ip := data.IP
+	replacement := config.DefaultRedaction
+	if payload.MaskIP && payload.KeepsIP() {
+		v4, v6 := privacyCfg.IPv4Prefix, privacyCfg.IPv6Prefix
+		if v4 == 0 {
+			v4 = defaultIPv4Prefix
+		}
+		if v6 == 0 {
+			v6 = defaultIPv6Prefix
+		}
+		replacement = privacy.Mask(ip, v4, v6)
+	}
+
+	fields, err := toFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload.Include) > 0 {
+		selected := map[string]interface{}{"action": fields["action"], "time": fields["time"]}
+		for _, key := range payload.Include {
+			field, label, nested := strings.Cut(key, ".")
+			if !nested {
+				if value, ok := fields[key]; ok {
+					selected[key] = value
+				}
+				continue
+			}
+			labels, _ := fields[field].(map[string]interface{})
+			if value, ok := labels[label]; ok {
+				kept, _ := selected[field].(map[string]interface{})
+				if kept == nil {
+					kept = make(map[string]interface{})
+					selected[field] = kept
+				}
+				kept[label] = value
+			}
+		}
+		fields = selected
+	}
+	for _, key := range payload.Exclude {
+		dropField(fields, key)
+	}
+
+	selected, err := fromFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	if ip == "" || (payload.KeepsIP() && !payload.MaskIP) {
+		return selected, nil
+	}
+
+	if payload.KeepsIP() {
+		selected.IP = replacement
+	}
+	for i, line := range selected.Matches {
+		selected.Matches[i] = strings.ReplaceAll(line, ip, replacement)
+	}
+	return selected, nil
+}
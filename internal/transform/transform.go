@@ -0,0 +1,194 @@
+// Package transform applies the configured transforms to events before
+// connectors run: templates setting fields, dropped fields and external
+// mutator scripts
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/eyeskiller/fail2ban-notifier/internal/config" //nolint:depguard
+	"github.com/eyeskiller/fail2ban-notifier/pkg/types"       //nolint:depguard
+)
+
+// Apply runs the transforms whose match selects the event, in order, and
+// returns the transformed copy. A failing transform is skipped and its error
+// returned alongside the result of the others, so one broken tweak doesn't
+// stop notifications.
+func Apply(transforms []config.Transform, data *types.NotificationData) (*types.NotificationData, error) {
+	var errs []error
+	for i := range transforms {
+		t := &transforms[i]
+		if t.Match != nil && !t.Match.Matches(data) {
+			continue
+		}
+
+		transformed, err := apply(t, data)
+		if err == nil {
+			err = check(transformed)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transform %s: %w", t.Label(i), err))
+			continue
+		}
+		data = transformed
+	}
+	return data, errors.Join(errs...)
+}
+
+// apply runs one transform: set, then drop, then the script
+func apply(t *config.Transform, data *types.NotificationData) (*types.NotificationData, error) {
+	if len(t.Set) > 0 || len(t.Drop) > 0 {
+		fields, err := toFields(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, tmpl := range t.SetTemplates() {
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, data); err != nil {
+				return nil, fmt.Errorf("set %s: %w", key, err)
+			}
+			if err := setField(fields, key, b.String()); err != nil {
+				return nil, err
+			}
+		}
+		for _, key := range t.Drop {
+			dropField(fields, key)
+		}
+
+		if data, err = fromFields(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Script != "" {
+		return runScript(t, data)
+	}
+	return data, nil
+}
+
+// toFields converts the event into its JSON fields
+func toFields(data *types.NotificationData) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return fields, nil
+}
+
+// fromFields converts JSON fields back into an event
+func fromFields(fields map[string]interface{}) (*types.NotificationData, error) {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	var data types.NotificationData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	return &data, nil
+}
+
+// setField sets a field or label to a rendered value. Text fields take the
+// value as is; other fields take it as JSON, e.g. "5" for failures.
+func setField(fields map[string]interface{}, key, value string) error {
+	if field, label, nested := strings.Cut(key, "."); nested {
+		labels, _ := fields[field].(map[string]interface{})
+		if labels == nil {
+			labels = make(map[string]interface{})
+			fields[field] = labels
+		}
+		labels[label] = value
+		return nil
+	}
+
+	if isText(key) {
+		fields[key] = value
+		return nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("set %s: value must be JSON: %s", key, value)
+	}
+	fields[key] = parsed
+	return nil
+}
+
+// isText reports whether the event field with the JSON name key is a string
+func isText(key string) bool {
+	fields := reflect.TypeOf(types.NotificationData{})
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == key {
+			return field.Type.Kind() == reflect.String
+		}
+	}
+	return true
+}
+
+// dropField removes a field or label
+func dropField(fields map[string]interface{}, key string) {
+	if field, label, nested := strings.Cut(key, "."); nested {
+		if labels, ok := fields[field].(map[string]interface{}); ok {
+			delete(labels, label)
+		}
+		return
+	}
+	delete(fields, key)
+}
+
+// runScript passes the event as JSON to a mutator script and reads the new
+// event from its output. An empty output leaves the event unchanged.
+func runScript(t *config.Transform, data *types.NotificationData) (*types.NotificationData, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.Timeout)*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Script) //nolint:gosec // Path comes from the configuration
+	cmd.Stdin = bytes.NewReader(encoded)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("script timed out after %ds", t.Timeout)
+		}
+		return nil, fmt.Errorf("script failed: %w, stderr: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return data, nil
+	}
+	var transformed types.NotificationData
+	if err := json.Unmarshal(stdout.Bytes(), &transformed); err != nil {
+		return nil, fmt.Errorf("script wrote an invalid event: %w", err)
+	}
+	return &transformed, nil
+}
+
+// check rejects events connectors can't deliver
+func check(data *types.NotificationData) error {
+	if data.Jail == "" || data.Action == "" {
+		return fmt.Errorf("jail and action cannot be removed")
+	}
+	if data.Action != types.ActionSurge && data.IP == "" {
+		return fmt.Errorf("ip cannot be removed")
+	}
+	return nil
+}
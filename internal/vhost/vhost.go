@@ -0,0 +1,50 @@
+// Package vhost finds the site an attack targeted in the log lines fail2ban
+// matched, e.g. from the Host header or the virtual host of the log format
+package vhost
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Find returns the host named in the lines, newest line first, or "" if
+// none does. Ports are removed and names lowercased.
+func Find(patterns []*regexp.Regexp, lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		for _, re := range patterns {
+			for _, match := range re.FindAllStringSubmatch(lines[i], -1) {
+				if host := normalize(match[re.SubexpIndex("host")]); host != "" {
+					return host
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// normalize cleans up a captured host, returning "" for values that are
+// not host names or addresses, such as nginx's catch-all "_"
+func normalize(host string) string {
+	host = strings.Trim(strings.TrimSpace(host), `"'`)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if host == "" || len(host) > 253 {
+		return ""
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return ""
+		}
+		if strings.Trim(strings.ToLower(label), "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return ""
+		}
+	}
+	return strings.ToLower(host)
+}
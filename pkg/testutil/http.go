@@ -0,0 +1,91 @@
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// DoerFunc adapts a function to types.HTTPDoer
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req)
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Request is a request received by an HTTPRecorder
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// HTTPRecorder is a types.HTTPDoer that records every request and answers it
+// with Handler in-process. Without a handler it answers 200 OK with an
+// empty body.
+type HTTPRecorder struct {
+	Handler http.Handler
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewHTTPRecorder creates a recorder answering with handler, which may be nil
+func NewHTTPRecorder(handler http.Handler) *HTTPRecorder {
+	return &HTTPRecorder{Handler: handler}
+}
+
+// Do records req and returns the handler's response
+func (r *HTTPRecorder) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	r.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	if r.Handler != nil {
+		r.Handler.ServeHTTP(recorder, req)
+	}
+	resp := recorder.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// Requests returns the requests received so far, oldest first
+func (r *HTTPRecorder) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// Respond returns a handler answering every request with status and body
+func Respond(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	})
+}
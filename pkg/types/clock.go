@@ -0,0 +1,28 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// Clock tells the time and waits. Components take it so tests can control
+// time without sleeping; see pkg/testutil for a fake.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// HTTPDoer sends HTTP requests. *http.Client implements it; tests can
+// substitute one that never touches the network.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// systemClock is the real clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SystemClock is the Clock used unless another one is injected
+var SystemClock Clock = systemClock{}
@@ -1,28 +1,301 @@
 package types
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Action types
+const (
+	ActionBan       = "ban"
+	ActionUnban     = "unban"
+	ActionSurge     = "surge"      // Meta-event: the ban rate of a jail far exceeds its baseline
+	ActionRestore   = "restore"    // Meta-event: summary of the bans fail2ban re-applied after a restart
+	ActionBulkUnban = "bulk_unban" // Meta-event: summary of a burst of unbans from one jail, as when it is flushed
+)
+
 type NotificationData struct {
-	IP        string    `json:"ip"`
-	Jail      string    `json:"jail"`
-	Action    string    `json:"action"` // "ban" or "unban"
-	Time      time.Time `json:"time"`
-	Country   string    `json:"country"`
-	Region    string    `json:"region"`
-	City      string    `json:"city"`
-	ISP       string    `json:"isp"`
-	Hostname  string    `json:"hostname,omitempty"`
-	Failures  int       `json:"failures,omitempty"`
-	Timezone  string    `json:"timezone,nil"`
-	Latitude  float64   `json:"latitude,nil"`
-	Longitude float64   `json:"longitude,nil"`
+	IP string `json:"ip"`
+	// IPHash is the keyed hash of the IP when privacy mode is enabled, which
+	// correlates the events of an address without revealing it
+	IPHash  string    `json:"ip_hash,omitempty"`
+	Jail    string    `json:"jail"`
+	Action  string    `json:"action"` // "ban", "unban", "surge", "restore" or "bulk_unban"
+	Time    time.Time `json:"time"`
+	Country string    `json:"country"`
+	// CountryCode is the ISO 3166-1 alpha-2 code of Country, e.g. "DE"
+	CountryCode string `json:"country_code,omitempty"`
+	// Continent is the continent code of Country: AF, AN, AS, EU, NA, OC or SA
+	Continent string  `json:"continent,omitempty"`
+	Region    string  `json:"region"`
+	City      string  `json:"city"`
+	ISP       string  `json:"isp"`
+	ASN       string  `json:"asn,omitempty"` // Autonomous system number, e.g. "AS4134"
+	Hostname  string  `json:"hostname,omitempty"`
+	Failures  int     `json:"failures,omitempty"`
+	BanTime   int     `json:"bantime,omitempty"` // Ban duration in seconds, -1 for permanent
+	Timezone  string  `json:"timezone,nil"`
+	Latitude  float64 `json:"latitude,nil"`
+	Longitude float64 `json:"longitude,nil"`
+	// TargetHost is the site that was attacked, e.g. from the Host header in
+	// the matched log lines
+	TargetHost string `json:"target_host,omitempty"`
+	// Suppressed is the number of earlier notifications dropped by throttling
+	Suppressed int `json:"suppressed,omitempty"`
+	// Artifact is the path of a gzipped bundle of log lines mentioning the IP
+	Artifact string `json:"artifact,omitempty"`
+	// ArtifactURL links to the artifact when served by the daemon
+	ArtifactURL string `json:"artifact_url,omitempty"`
+	// EventID is a UUID tracing the event through every connector, the
+	// event store, delivery results and acknowledgments
+	EventID string `json:"event_id,omitempty"`
+	// AckURL is a signed link acknowledging the event, set when the
+	// daemon's ack endpoint is public
+	AckURL string `json:"ack_url,omitempty"`
+	// Unacknowledged is the number of earlier critical bans nobody
+	// acknowledged
+	Unacknowledged int `json:"unacknowledged_critical,omitempty"`
+	// HoneypotSessions is the number of recent local honeypot sessions from the IP
+	HoneypotSessions int `json:"honeypot_sessions,omitempty"`
+	// Domains recently resolved to the IP according to passive DNS, most recent first
+	Domains []string `json:"domains,omitempty"`
+	// RiskScore combines the enrichments into 0-100, 0 when not scored
+	RiskScore int `json:"risk_score,omitempty"`
+	// TrustedRegion marks a ban from one of the trusted countries, which
+	// may be credential stuffing against real accounts
+	TrustedRegion bool `json:"trusted_region,omitempty"`
+	// Logins are successful logins from the IP or its network shortly
+	// before the ban, most recent first; any is a possible breach
+	Logins []Login `json:"logins,omitempty"`
+	// Client is the address a banned reverse proxy forwarded for, taken from
+	// the matched log lines, nil when not resolved
+	Client *Client `json:"client,omitempty"`
+	// Anonymity holds VPN/proxy detection results, nil when not looked up
+	Anonymity *Anonymity `json:"anonymity,omitempty"`
+	// Surge describes the ban rate of a surge event, nil for other actions
+	Surge *Surge `json:"surge,omitempty"`
+	// Restore counts the bans of a restore event, nil for other actions
+	Restore *Restore `json:"restore,omitempty"`
+	// BulkUnban counts the unbans of a bulk_unban event, nil for other actions
+	BulkUnban *BulkUnban `json:"bulk_unban,omitempty"`
+	// Coalesced marks an unban summarized by a bulk_unban event, which the
+	// connectors coalescing unbans don't receive
+	Coalesced bool `json:"-"`
+	// Campaign is the cluster of related bans the IP is part of, nil if none
+	Campaign *Campaign `json:"campaign,omitempty"`
+	// Labels are configured key/values such as the environment or owner
+	Labels map[string]string `json:"labels,omitempty"`
+	// History summarizes earlier bans of the IP, nil without an event store
+	History *History `json:"history,omitempty"`
+	// Matches are the log lines fail2ban matched for the ban, redacted
+	Matches []string `json:"matches,omitempty"`
+	// Escalation explains why the event is escalated, set only for the
+	// escalation connectors
+	Escalation string `json:"escalation,omitempty"`
+}
+
+// History describes the earlier bans of an IP recorded in the event store
+type History struct {
+	FirstSeen time.Time `json:"first_seen,omitempty"` // Zero if never banned before
+	LastSeen  time.Time `json:"last_seen,omitempty"`
+	BanCount  int       `json:"ban_count"`
+}
+
+// Summary describes the history, e.g. "Banned 12 times before, first on
+// 4 Mar 2026" or "First time seen"
+func (h *History) Summary() string {
+	switch h.BanCount {
+	case 0:
+		return "First time seen"
+	case 1:
+		return "Banned once before, on " + h.FirstSeen.Format("2 Jan 2006")
+	default:
+		return fmt.Sprintf("Banned %d times before, first on %s", h.BanCount, h.FirstSeen.Format("2 Jan 2006"))
+	}
+}
+
+// Campaign is a cluster of bans from the same network or autonomous system
+// that are close in time
+type Campaign struct {
+	ID    int       `json:"id"`
+	Kind  string    `json:"kind"` // "subnet" or "asn"
+	Key   string    `json:"key"`  // e.g. "203.0.113.0/24" or "AS4134"
+	IPs   int       `json:"ips"`  // Distinct IPs banned so far
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// Summary describes the campaign, e.g. "#42: 37 IPs from AS4134 in 2h"
+func (c *Campaign) Summary() string {
+	span := c.Last.Sub(c.First)
+	var spanText string
+	switch {
+	case span < time.Hour:
+		spanText = fmt.Sprintf("%dm", int(span.Minutes()))
+	case span < 48*time.Hour:
+		spanText = fmt.Sprintf("%dh", int(span.Hours()))
+	default:
+		spanText = fmt.Sprintf("%dd", int(span.Hours()/24))
+	}
+	return fmt.Sprintf("#%d: %d IPs from %s in %s", c.ID, c.IPs, c.Key, spanText)
+}
+
+// Client is the client behind a reverse proxy, as reported by the proxy's
+// forwarding headers
+type Client struct {
+	IP          string `json:"ip"`
+	Header      string `json:"header,omitempty"` // Header or pattern it was found in, e.g. "X-Forwarded-For"
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	City        string `json:"city,omitempty"`
+	ISP         string `json:"isp,omitempty"`
+	ASN         string `json:"asn,omitempty"`
+}
+
+// Summary describes the client, e.g. "203.0.113.9 (Berlin, Germany, AS3320)"
+func (c *Client) Summary() string {
+	var details []string
+	if c.City != "" && c.Country != "" {
+		details = append(details, c.City+", "+c.Country)
+	} else if c.Country != "" {
+		details = append(details, c.Country)
+	}
+	if c.ASN != "" {
+		details = append(details, c.ASN)
+	} else if c.ISP != "" {
+		details = append(details, c.ISP)
+	}
+	if len(details) == 0 {
+		return c.IP
+	}
+	return c.IP + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Login is a successful login found before a ban
+type Login struct {
+	User    string    `json:"user,omitempty"`
+	IP      string    `json:"ip"`
+	Time    time.Time `json:"time"`
+	Service string    `json:"service,omitempty"` // e.g. "sshd", or the terminal of a wtmp record
+	Source  string    `json:"source,omitempty"`  // Name of the configured source it was found in
+}
+
+// Summary describes the login, e.g. "root from 203.0.113.9 via sshd at
+// 14:02:11"
+func (l *Login) Summary() string {
+	summary := l.IP
+	if l.User != "" {
+		summary = l.User + " from " + l.IP
+	}
+	if l.Service != "" {
+		summary += " via " + l.Service
+	}
+	return summary + " at " + l.Time.Format("15:04:05")
+}
+
+// Surge describes a jail whose ban rate exceeds its rolling baseline
+type Surge struct {
+	Bans     int     `json:"bans"`     // Bans in the current window
+	Window   int     `json:"window"`   // Window length in seconds
+	Baseline float64 `json:"baseline"` // Average bans per window before the current one
+}
+
+// Factor returns how many times the baseline the current ban count is
+func (s *Surge) Factor() float64 {
+	if s.Baseline <= 0 {
+		return float64(s.Bans)
+	}
+	return float64(s.Bans) / s.Baseline
+}
+
+// Restore counts the bans fail2ban re-applied after a restart
+type Restore struct {
+	Bans   int            `json:"bans"`   // Bans restored in the grace window
+	Jails  map[string]int `json:"jails"`  // Bans restored per jail
+	Window int            `json:"window"` // Grace window length in seconds
+}
+
+// JailSummary returns the per-jail counts, e.g. "nginx 3, sshd 12", in
+// jail order
+func (r *Restore) JailSummary() string {
+	jails := make([]string, 0, len(r.Jails))
+	for jail := range r.Jails {
+		jails = append(jails, jail)
+	}
+	sort.Strings(jails)
+
+	parts := make([]string, len(jails))
+	for i, jail := range jails {
+		parts[i] = fmt.Sprintf("%s %d", jail, r.Jails[jail])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BulkUnban counts a burst of unbans from one jail
+type BulkUnban struct {
+	Unbans int `json:"unbans"` // IPs unbanned in the burst
+	Window int `json:"window"` // Seconds the burst was collected over
+}
+
+// Anonymity describes whether an IP hides behind an anonymization service,
+// as reported by a detection service such as Spur or IPQualityScore
+type Anonymity struct {
+	VPN              bool   `json:"vpn"`
+	Proxy            bool   `json:"proxy"`
+	ResidentialProxy bool   `json:"residential_proxy"`
+	Tor              bool   `json:"tor"`
+	Bot              bool   `json:"bot"`
+	Service          string `json:"service,omitempty"`     // VPN or proxy operator, if known
+	FraudScore       int    `json:"fraud_score,omitempty"` // 0-100, IPQualityScore only
+	Source           string `json:"source"`                // Detection service that answered
+}
+
+// Anonymous returns true if the IP uses a VPN, proxy or Tor
+func (a *Anonymity) Anonymous() bool {
+	return a.VPN || a.Proxy || a.ResidentialProxy || a.Tor
+}
+
+// Labels returns the detected flags for display, e.g. "VPN (NordVPN)"
+func (a *Anonymity) Labels() []string {
+	var labels []string
+	if a.VPN {
+		if a.Service != "" {
+			labels = append(labels, "VPN ("+a.Service+")")
+		} else {
+			labels = append(labels, "VPN")
+		}
+	}
+	if a.ResidentialProxy {
+		labels = append(labels, "residential proxy")
+	} else if a.Proxy {
+		labels = append(labels, "proxy")
+	}
+	if a.Tor {
+		labels = append(labels, "Tor")
+	}
+	if a.Bot {
+		labels = append(labels, "bot")
+	}
+	return labels
 }
 
 // String returns a string representation of the notification data
 func (nd *NotificationData) String() string {
+	if nd.IsSurge() && nd.Surge != nil {
+		return fmt.Sprintf("attack surge in %s: %d bans in %s", nd.Jail, nd.Surge.Bans, time.Duration(nd.Surge.Window)*time.Second)
+	}
+	if nd.IsRestore() && nd.Restore != nil {
+		return fmt.Sprintf("restored %d bans after fail2ban restart", nd.Restore.Bans)
+	}
+	if nd.IsBulkUnban() && nd.BulkUnban != nil {
+		return fmt.Sprintf("%d IPs unbanned from %s", nd.BulkUnban.Unbans, nd.Jail)
+	}
 	return nd.IP + " " + nd.Action + "ned in " + nd.Jail
 }
 
@@ -43,6 +316,73 @@ func (nd *NotificationData) GetLocationString() string {
 	return nd.Country
 }
 
+// Flag returns the emoji flag of the country, e.g. "🇩🇪", or "" if the
+// country code is unknown
+func (nd *NotificationData) Flag() string {
+	code := strings.ToUpper(nd.CountryCode)
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+
+	// Flags are pairs of regional indicator symbols, 🇦 to 🇿
+	const indicatorA = 0x1F1E6
+	return string([]rune{indicatorA + rune(code[0]-'A'), indicatorA + rune(code[1]-'A')})
+}
+
+// LabelString returns the labels as sorted "key=value" pairs separated by
+// ", ", e.g. "dc=fra1, env=prod"
+func (nd *NotificationData) LabelString() string {
+	keys := make([]string, 0, len(nd.Labels))
+	for key := range nd.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + nd.Labels[key]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// ID identifies the event in the event store: its EventID, or for events
+// recorded without one, an ID derived from the event, e.g. "3f2a9c01b7e4"
+func (nd *NotificationData) ID() string {
+	if nd.EventID != "" {
+		return nd.EventID
+	}
+	return EventID(nd.Time, nd.IP, nd.Jail, nd.Action)
+}
+
+// NewEventID returns a random (version 4) UUID for a new event
+func NewEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		panic(fmt.Sprintf("failed to generate event ID: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// EventID derives an event ID from the fields that identify an event, so
+// records keeping only these fields, such as connector results, can be
+// matched to the event
+func EventID(t time.Time, ip, jail, action string) string {
+	sum := sha256.Sum256([]byte(t.UTC().Format(time.RFC3339Nano) + "|" + ip + "|" + jail + "|" + action))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Subject identifies the address of the event: its IP hash when privacy
+// mode is enabled, otherwise the IP
+func (nd *NotificationData) Subject() string {
+	if nd.IPHash != "" {
+		return nd.IPHash
+	}
+	return nd.IP
+}
+
 // IsValid checks if the notification data has required fields
 func (nd *NotificationData) IsValid() bool {
 	return nd.IP != "" && nd.Jail != "" && nd.Action != ""
@@ -50,12 +390,32 @@ func (nd *NotificationData) IsValid() bool {
 
 // IsBan returns true if this is a ban action
 func (nd *NotificationData) IsBan() bool {
-	return nd.Action == "ban"
+	return nd.Action == ActionBan
 }
 
 // IsUnban returns true if this is an unban action
 func (nd *NotificationData) IsUnban() bool {
-	return nd.Action == "unban"
+	return nd.Action == ActionUnban
+}
+
+// IsSurge returns true if this is an attack surge meta-event
+func (nd *NotificationData) IsSurge() bool {
+	return nd.Action == ActionSurge
+}
+
+// IsRestore returns true if this is a restart summary meta-event
+func (nd *NotificationData) IsRestore() bool {
+	return nd.Action == ActionRestore
+}
+
+// PossibleBreach returns true if a successful login preceded the ban
+func (nd *NotificationData) PossibleBreach() bool {
+	return nd.IsBan() && len(nd.Logins) > 0
+}
+
+// IsBulkUnban returns true if this is a bulk unban meta-event
+func (nd *NotificationData) IsBulkUnban() bool {
+	return nd.Action == ActionBulkUnban
 }
 
 // ToJSON returns the notification data as JSON
@@ -71,10 +431,19 @@ type ExecutionResult struct {
 	Duration      time.Duration `json:"duration"`
 	Timestamp     time.Time     `json:"timestamp"`
 	Attempts      int           `json:"attempts"`
+	// DeliveredBy is the member that delivered the event when ConnectorName
+	// is a failover group
+	DeliveredBy string `json:"delivered_by,omitempty"`
+	// Output is the truncated stdout and stderr of a script or the status
+	// and body of an HTTP response, of the last attempt
+	Output string `json:"output,omitempty"`
+	// BestEffort is set for connectors whose failures don't fail the run
+	BestEffort bool `json:"best_effort,omitempty"`
 }
 
 // BatchResult represents the result of executing multiple connectors
 type BatchResult struct {
+	EventID          string            `json:"event_id,omitempty"` // Trace ID of the delivered event
 	TotalConnectors  int               `json:"total_connectors"`
 	SuccessfulCount  int               `json:"successful_count"`
 	FailedCount      int               `json:"failed_count"`
@@ -82,11 +451,37 @@ type BatchResult struct {
 	Results          []ExecutionResult `json:"results"`
 	NotificationData NotificationData  `json:"notification_data"`
 	Timestamp        time.Time         `json:"timestamp"`
+	// Quorum is the number of successful connectors required, 0 for all
+	Quorum int `json:"quorum,omitempty"`
+	// Escalation holds the results of the escalation connectors notified
+	// because the quorum was missed
+	Escalation []ExecutionResult `json:"escalation,omitempty"`
 }
 
-// IsSuccess returns true if all connectors executed successfully
+// IsSuccess returns true if all critical connectors executed successfully,
+// or with a quorum, if at least that many did. Best-effort connectors are
+// not considered.
 func (br *BatchResult) IsSuccess() bool {
-	return br.FailedCount == 0
+	succeeded, failed := br.CriticalCounts()
+	if br.Quorum > 0 {
+		return succeeded >= br.Quorum
+	}
+	return failed == 0
+}
+
+// CriticalCounts returns the number of successful and failed connectors,
+// leaving out best-effort ones
+func (br *BatchResult) CriticalCounts() (succeeded, failed int) {
+	for _, result := range br.Results {
+		switch {
+		case result.BestEffort:
+		case result.Success:
+			succeeded++
+		default:
+			failed++
+		}
+	}
+	return succeeded, failed
 }
 
 // GetSuccessRate returns the success rate as a percentage
@@ -97,11 +492,11 @@ func (br *BatchResult) GetSuccessRate() float64 {
 	return float64(br.SuccessfulCount) / float64(br.TotalConnectors) * 100
 }
 
-// GetFailedConnectors returns a list of failed connector names
+// GetFailedConnectors returns a list of failed critical connector names
 func (br *BatchResult) GetFailedConnectors() []string {
 	var failed []string
 	for _, result := range br.Results {
-		if !result.Success {
+		if !result.Success && !result.BestEffort {
 			failed = append(failed, result.ConnectorName)
 		}
 	}
@@ -141,6 +536,8 @@ type Metrics struct {
 	GeoIPCacheHits          int64                       `json:"geoip_cache_hits"`
 	GeoIPCacheMisses        int64                       `json:"geoip_cache_misses"`
 	AverageExecutionTime    time.Duration               `json:"average_execution_time"`
+	SpoolDepth              int64                       `json:"spool_depth"`
+	SpoolBytes              int64                       `json:"spool_bytes"`
 	LastReset               time.Time                   `json:"last_reset"`
 }
 
@@ -209,4 +606,3 @@ type APIResponse struct {
 	Timestamp time.Time   `json:"timestamp"`
 	Version   string      `json:"version,omitempty"`
 }
-